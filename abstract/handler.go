@@ -1,6 +1,8 @@
 package abstract
 
 import (
+	"net/http"
+
 	"github.com/TixiaOTA/gokit/types"
 	"github.com/gofiber/fiber/v2"
 	"google.golang.org/grpc"
@@ -11,6 +13,11 @@ type RestHandler interface {
 	Router(r fiber.Router)
 }
 
+// HTTPHandler abstraction for net/http Handler
+type HTTPHandler interface {
+	Mount(mux *http.ServeMux)
+}
+
 // GRPCHandler abstraction for gRPC Handler
 type GRPCHandler interface {
 	Register(srv *grpc.Server)
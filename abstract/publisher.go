@@ -10,3 +10,9 @@ import (
 type Publisher interface {
 	PublishMessage(ctx context.Context, req types.PublisherArgument) error
 }
+
+// BatchPublisher publisher publishing several messages at once, e.g. on one broker channel
+type BatchPublisher interface {
+	Publisher
+	PublishMessages(ctx context.Context, req []types.PublisherArgument) error
+}
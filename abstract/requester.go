@@ -0,0 +1,14 @@
+package abstract
+
+import (
+	"context"
+
+	"github.com/TixiaOTA/gokit/types"
+)
+
+// Requester request-reply over the broker, publish a message and wait for the correlated reply
+type Requester interface {
+	Request(ctx context.Context, req types.PublisherArgument) (reply []byte, err error)
+
+	Closer
+}
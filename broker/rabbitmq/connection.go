@@ -0,0 +1,95 @@
+package rabbitmq
+
+import "github.com/streadway/amqp"
+
+// Channel subset of *amqp.Channel used by broker and consumers
+type Channel interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+	QueueInspect(name string) (amqp.Queue, error)
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	Qos(prefetchCount, prefetchSize int, global bool) error
+	Cancel(consumer string, noWait bool) error
+	NotifyClose(receiver chan *amqp.Error) chan *amqp.Error
+	NotifyCancel(receiver chan string) chan string
+	Close() error
+}
+
+// Connection subset of *amqp.Connection used by broker
+type Connection interface {
+	Channel() (Channel, error)
+	NotifyClose(receiver chan *amqp.Error) chan *amqp.Error
+	Close() error
+}
+
+// Dialer open connection to url
+type Dialer func(url string) (Connection, error)
+
+// amqpConnection Connection of *amqp.Connection
+type amqpConnection struct {
+	*amqp.Connection
+}
+
+func (c amqpConnection) Channel() (Channel, error) {
+	return c.Connection.Channel()
+}
+
+// dialAMQP Dialer of streadway/amqp
+func dialAMQP(url string) (Connection, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return amqpConnection{conn}, nil
+}
+
+// currentChannel Channel delegating every call to the channel of the current connection
+type currentChannel struct {
+	broker *Broker
+}
+
+func (c currentChannel) channel() Channel {
+	return c.broker.Session().Channel
+}
+
+func (c currentChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	return c.channel().Publish(exchange, key, mandatory, immediate, msg)
+}
+
+func (c currentChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	return c.channel().QueueDeclare(name, durable, autoDelete, exclusive, noWait, args)
+}
+
+func (c currentChannel) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	return c.channel().QueueBind(name, key, exchange, noWait, args)
+}
+
+func (c currentChannel) QueueInspect(name string) (amqp.Queue, error) {
+	return c.channel().QueueInspect(name)
+}
+
+func (c currentChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	return c.channel().Consume(queue, consumer, autoAck, exclusive, noLocal, noWait, args)
+}
+
+func (c currentChannel) Qos(prefetchCount, prefetchSize int, global bool) error {
+	return c.channel().Qos(prefetchCount, prefetchSize, global)
+}
+
+func (c currentChannel) Cancel(consumer string, noWait bool) error {
+	return c.channel().Cancel(consumer, noWait)
+}
+
+func (c currentChannel) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	return c.channel().NotifyClose(receiver)
+}
+
+func (c currentChannel) NotifyCancel(receiver chan string) chan string {
+	return c.channel().NotifyCancel(receiver)
+}
+
+func (c currentChannel) Close() error {
+	return c.channel().Close()
+}
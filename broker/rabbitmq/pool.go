@@ -0,0 +1,196 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/streadway/amqp"
+)
+
+// ErrPublishNacked broker refused a message of a confirmed publish, see Config.PublishConfirm
+var ErrPublishNacked = errors.New("rabbitmq: publish nacked by broker")
+
+// errConfirmsClosed channel closed while waiting for publisher confirms
+var errConfirmsClosed = errors.New("rabbitmq: channel closed awaiting publish confirms")
+
+// confirmWindow buffered confirmations of a pooled channel, drained while publishing
+const confirmWindow = 256
+
+// confirmChannel Channel supporting publisher confirms, e.g. *amqp.Channel
+type confirmChannel interface {
+	Confirm(noWait bool) error
+	NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation
+}
+
+// pooledChannel channel of channelPool opened on the connection of generation
+type pooledChannel struct {
+	ch         Channel
+	generation uint64
+	confirms   chan amqp.Confirmation // nil without publisher confirms
+	closed     chan *amqp.Error       // closed with the channel, e.g. by a channel exception
+	broken     bool
+}
+
+// dead report whether pc was closed while idle, e.g. by a 404 on exchange of the previous publish
+func (pc *pooledChannel) dead() bool {
+	select {
+	case <-pc.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// channelPool publisher channels borrowed per publish, at most size channels are open at once.
+// a channel failing a publish is closed and replaced, channels of a lost connection or closed by the broker
+// are dropped on borrow
+type channelPool struct {
+	broker  *Broker
+	confirm bool
+	idle    chan *pooledChannel
+	slots   chan struct{}
+}
+
+func newChannelPool(b *Broker, size int, confirm bool) *channelPool {
+	return &channelPool{
+		broker:  b,
+		confirm: confirm,
+		idle:    make(chan *pooledChannel, size),
+		slots:   make(chan struct{}, size),
+	}
+}
+
+// borrow idle channel of the current connection or open a new one, waits for a free channel until ctx is done
+func (p *channelPool) borrow(ctx context.Context) (*pooledChannel, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("rabbitmq: publisher pool exhausted: %w", ctx.Err())
+	}
+
+	if pc := p.takeIdle(p.broker.Session().Generation); pc != nil {
+		return pc, nil
+	}
+
+	pc, err := p.open()
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+
+	return pc, nil
+}
+
+// takeIdle open idle channel of generation, nil when none
+func (p *channelPool) takeIdle(generation uint64) *pooledChannel {
+	for {
+		select {
+		case pc := <-p.idle:
+			if pc.generation == generation && !pc.dead() {
+				return pc
+			}
+			// opened on a lost connection or closed by the broker
+			_ = pc.ch.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// open channel on the current connection, in confirm mode when enabled and supported
+func (p *channelPool) open() (*pooledChannel, error) {
+	b := p.broker
+	b.mu.Lock()
+	ch, err := b.conn.Channel()
+	generation := b.generation
+	b.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("rabbitmq: open publisher channel: %w", err)
+	}
+
+	pc := &pooledChannel{ch: ch, generation: generation, closed: ch.NotifyClose(make(chan *amqp.Error, 1))}
+	if cc, ok := ch.(confirmChannel); ok && p.confirm {
+		if err = cc.Confirm(false); err != nil {
+			_ = ch.Close()
+			return nil, fmt.Errorf("rabbitmq: confirm mode: %w", err)
+		}
+		pc.confirms = cc.NotifyPublish(make(chan amqp.Confirmation, confirmWindow))
+	}
+
+	return pc, nil
+}
+
+// release give pc back to the pool, a broken channel is closed to be replaced by the next borrow
+func (p *channelPool) release(pc *pooledChannel) {
+	if pc.broken {
+		_ = pc.ch.Close()
+	} else {
+		p.idle <- pc
+	}
+
+	<-p.slots
+}
+
+// publish messages in order on one borrowed channel, with confirms every message is awaited as one window
+func (p *channelPool) publish(ctx context.Context, messages []types.PublisherArgument) error {
+	pc, err := p.borrow(ctx)
+	if err != nil {
+		return err
+	}
+	defer p.release(pc)
+
+	var pending, nacked int
+	for i, args := range messages {
+		if err = publish(pc.ch, args); err != nil {
+			pc.broken = true
+			return fmt.Errorf("rabbitmq: publish message %d/%d: %w", i+1, len(messages), err)
+		}
+
+		if pc.confirms == nil {
+			continue
+		}
+		pending++
+
+		// drain available confirmations so the window never fills up
+		for drained := false; !drained; {
+			select {
+			case c, ok := <-pc.confirms:
+				if !ok {
+					pc.broken = true
+					return errConfirmsClosed
+				}
+				pending--
+				if !c.Ack {
+					nacked++
+				}
+			default:
+				drained = true
+			}
+		}
+	}
+
+	for ; pending > 0; pending-- {
+		select {
+		case c, ok := <-pc.confirms:
+			if !ok {
+				pc.broken = true
+				return errConfirmsClosed
+			}
+			if !c.Ack {
+				nacked++
+			}
+		case <-ctx.Done():
+			// confirmations left on the channel would be read by the next borrower
+			pc.broken = true
+			return ctx.Err()
+		}
+	}
+
+	if nacked > 0 {
+		return fmt.Errorf("%w: %d of %d messages", ErrPublishNacked, nacked, len(messages))
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package rabbitmq
+
+import (
+	"context"
+	"time"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/streadway/amqp"
+)
+
+// publisher publish to Exchange with Key as routing key, Queue is used as routing key of the default
+// exchange when Key is empty. While recovering the broker Config.PublishPolicy applies.
+// With Config.PublisherPoolSize every publish borrows a channel of the pool instead of sharing one
+type publisher struct {
+	broker *Broker
+}
+
+func (p *publisher) PublishMessage(ctx context.Context, args types.PublisherArgument) error {
+	return p.PublishMessages(ctx, []types.PublisherArgument{args})
+}
+
+// PublishMessages publish messages in order on one channel, with Config.PublishConfirm the messages share
+// one confirm window. While recovering a batch is buffered as a whole or not at all
+func (p *publisher) PublishMessages(ctx context.Context, messages []types.PublisherArgument) error {
+	encoded := make([]types.PublisherArgument, 0, len(messages))
+	for _, args := range messages {
+		if err := args.Validate(); err != nil {
+			return err
+		}
+
+		args, err := args.Encode(p.broker.cfg.PayloadLimits)
+		if err != nil {
+			return err
+		}
+		args.Headers = logger.InjectBaggage(ctx, args.Headers)
+		encoded = append(encoded, args)
+	}
+
+	b := p.broker
+	for {
+		b.mu.Lock()
+		if !b.recovering {
+			ch := b.ch
+			b.mu.Unlock()
+			if b.pool != nil {
+				return b.pool.publish(ctx, encoded)
+			}
+
+			for _, args := range encoded {
+				if err := publish(ch, args); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		switch b.cfg.PublishPolicy {
+		case PublishBuffer:
+			err := ErrPublishBufferFull
+			if len(b.buffer)+len(encoded) <= b.cfg.PublishBufferSize {
+				b.buffer = append(b.buffer, encoded...)
+				err = nil
+			}
+			b.mu.Unlock()
+
+			return err
+		case PublishBlock:
+			recovered := b.recovered
+			b.mu.Unlock()
+
+			select {
+			case <-recovered:
+			case <-time.After(b.cfg.PublishWait):
+				return ErrRecovering
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-b.done:
+				return ErrClosed
+			}
+		default:
+			b.mu.Unlock()
+			return ErrRecovering
+		}
+	}
+}
+
+func publish(ch Channel, args types.PublisherArgument) error {
+	key := args.Key
+	if key == "" {
+		key = args.Queue
+	}
+
+	return ch.Publish(args.Exchange, key, false, false, amqp.Publishing{
+		Headers:       amqp.Table(args.Headers),
+		CorrelationId: args.CorrelationId,
+		Priority:      uint8(args.PriorityMessage),
+		DeliveryMode:  amqp.Persistent,
+		Timestamp:     time.Now(),
+		Body:          args.Message,
+	})
+}
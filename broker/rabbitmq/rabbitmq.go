@@ -0,0 +1,354 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/TixiaOTA/gokit/abstract"
+	"github.com/TixiaOTA/gokit/factory"
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/streadway/amqp"
+)
+
+var (
+	// ErrRecovering connection is lost and being recovered
+	ErrRecovering = errors.New("rabbitmq: connection is recovering")
+	// ErrPublishBufferFull PublishBuffer policy reached Config.PublishBufferSize
+	ErrPublishBufferFull = errors.New("rabbitmq: publish buffer is full")
+	// ErrClosed broker is disconnected
+	ErrClosed = errors.New("rabbitmq: broker is closed")
+)
+
+// Broker RabbitMQ implementation of abstract.Broker recovering lost connection with exponential backoff,
+// consumers follow the recovered channel through Session
+type Broker struct {
+	cfg       Config
+	publisher *publisher
+	pool      *channelPool // nil without Config.PublisherPoolSize
+
+	mu         sync.Mutex
+	conn       Connection
+	ch         Channel
+	generation uint64
+	recovering bool
+	recovered  chan struct{}
+	buffer     []types.PublisherArgument
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// Session channel of the current connection, Recovered is closed once the connection is replaced
+type Session struct {
+	Channel    Channel
+	Generation uint64
+	Recovered  <-chan struct{}
+}
+
+// defaultReconnectMin first delay of reconnect when Config.ReconnectMin is not positive
+const defaultReconnectMin = 500 * time.Millisecond
+
+// New dial RabbitMQ and watch the connection, a lost connection is re-dialed until Disconnect
+func New(opts ...OptionFunc) (*Broker, error) {
+	cfg := getDefaultConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	// a zero delay never grows and re-dials in a busy loop
+	if cfg.ReconnectMin <= 0 {
+		cfg.ReconnectMin = defaultReconnectMin
+	}
+	if cfg.ReconnectMax < cfg.ReconnectMin {
+		cfg.ReconnectMax = cfg.ReconnectMin
+	}
+	// confirms are awaited on pooled channels only
+	if cfg.PublishConfirm && cfg.PublisherPoolSize < 1 {
+		cfg.PublisherPoolSize = 1
+	}
+
+	b := &Broker{cfg: cfg, recovered: make(chan struct{}), done: make(chan struct{})}
+	b.publisher = &publisher{broker: b}
+	if cfg.PublisherPoolSize > 0 {
+		b.pool = newChannelPool(b, cfg.PublisherPoolSize, cfg.PublishConfirm)
+	}
+
+	conn, ch, err := b.connect()
+	if err != nil {
+		return nil, err
+	}
+	b.conn, b.ch = conn, ch
+
+	factory.GoSafe("rabbitmq-broker", b.watch)
+	return b, nil
+}
+
+// GetPublisher publisher following the publish policy while recovering, implements abstract.BatchPublisher
+func (b *Broker) GetPublisher() abstract.Publisher {
+	return b.publisher
+}
+
+// GetName types.RabbitMQ
+func (b *Broker) GetName() types.Broker {
+	return types.RabbitMQ
+}
+
+// GetConfiguration channel of the current connection, *amqp.Channel unless a custom Dialer is used
+func (b *Broker) GetConfiguration() interface{} {
+	return b.Session().Channel
+}
+
+// Session channel of the current connection
+func (b *Broker) Session() Session {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return Session{Channel: b.ch, Generation: b.generation, Recovered: b.recovered}
+}
+
+// Channel channel following the recovered connection, consumers registered on it must be registered again
+// once Session().Recovered is closed
+func (b *Broker) Channel() Channel {
+	return currentChannel{broker: b}
+}
+
+// Recovering report whether the connection is lost and being recovered
+func (b *Broker) Recovering() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.recovering
+}
+
+// Check health check reporting ErrRecovering while recovering, see rest.WithHealthCheck
+func (b *Broker) Check(_ context.Context) error {
+	if b.Recovering() {
+		return ErrRecovering
+	}
+
+	return nil
+}
+
+// Disconnect stop recovering and close the connection
+func (b *Broker) Disconnect(_ context.Context) error {
+	var err error
+	b.closeOnce.Do(func() {
+		close(b.done)
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		err = b.conn.Close()
+	})
+
+	return err
+}
+
+func (b *Broker) connect() (Connection, Channel, error) {
+	conn, err := b.cfg.Dialer(b.cfg.URL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("rabbitmq: dial: %w", err)
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, fmt.Errorf("rabbitmq: open channel: %w", err)
+	}
+
+	return conn, ch, nil
+}
+
+// watch recover the connection every time it is closed by error, and the channel every time it is closed
+// by a channel exception or a consumer is cancelled by the broker, e.g. its queue is deleted
+func (b *Broker) watch() {
+	for {
+		b.mu.Lock()
+		closed := b.conn.NotifyClose(make(chan *amqp.Error, 1))
+		chClosed := b.ch.NotifyClose(make(chan *amqp.Error, 1))
+		cancelled := make(chan string, 1)
+		go drainCancels(b.ch.NotifyCancel(make(chan string, 1)), cancelled)
+		b.mu.Unlock()
+
+		var recovered bool
+		select {
+		case <-b.done:
+			return
+		case err := <-closed:
+			if b.isDone() {
+				return
+			}
+
+			log.Printf("rabbitmq > connection lost: %v, reconnecting", err)
+			b.setRecovering()
+			recovered = b.reconnect()
+		case err := <-chClosed:
+			if b.isDone() {
+				return
+			}
+
+			log.Printf("rabbitmq > channel closed: %v, reopening", err)
+			b.setRecovering()
+			recovered = b.reopen()
+		case consumer := <-cancelled:
+			if b.isDone() {
+				return
+			}
+
+			log.Printf("rabbitmq > consumer %s cancelled by broker, reopening channel", consumer)
+			b.setRecovering()
+			recovered = b.reopen()
+		}
+
+		if !recovered {
+			return
+		}
+	}
+}
+
+// drainCancels forward the first cancelled consumer to cancelled and discard the next ones until notify is
+// closed with its channel, the client delivers cancels on the connection reader which blocks on a full notify
+func drainCancels(notify <-chan string, cancelled chan<- string) {
+	for consumer := range notify {
+		select {
+		case cancelled <- consumer:
+		default:
+		}
+	}
+}
+
+func (b *Broker) isDone() bool {
+	select {
+	case <-b.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *Broker) setRecovering() {
+	b.mu.Lock()
+	b.recovering = true
+	b.mu.Unlock()
+}
+
+// reopen replace the channel on the current connection so consumers register again on a new generation,
+// re-dial when the connection is lost too
+func (b *Broker) reopen() bool {
+	b.mu.Lock()
+	conn, old := b.conn, b.ch
+	b.mu.Unlock()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		log.Printf("rabbitmq > reopen channel: %s, reconnecting", err)
+		return b.reconnect()
+	}
+
+	// a channel with a cancelled consumer is still open
+	_ = old.Close()
+	b.recover(conn, ch)
+	log.Print("rabbitmq > channel reopened")
+	return true
+}
+
+// reconnect re-dial with exponential backoff and jitter until connected or disconnected
+func (b *Broker) reconnect() bool {
+	delay := b.cfg.ReconnectMin
+	for attempt := 1; ; attempt++ {
+		wait := delay
+		if delay > 1 {
+			wait += time.Duration(rand.Int63n(int64(delay / 2)))
+		}
+
+		select {
+		case <-b.done:
+			return false
+		case <-time.After(wait):
+		}
+
+		conn, ch, err := b.connect()
+		if err == nil {
+			b.recover(conn, ch)
+			log.Printf("rabbitmq > connection recovered after %d attempt", attempt)
+			return true
+		}
+
+		log.Printf("rabbitmq > reconnect attempt %d: %s", attempt, err)
+		if delay *= 2; delay > b.cfg.ReconnectMax {
+			delay = b.cfg.ReconnectMax
+		}
+	}
+}
+
+// recover swap the connection and wake up waiters, publishers keep buffering until the buffered messages
+// are published
+func (b *Broker) recover(conn Connection, ch Channel) {
+	b.mu.Lock()
+	b.conn, b.ch = conn, ch
+	b.generation++
+	close(b.recovered)
+	b.recovered = make(chan struct{})
+	b.mu.Unlock()
+
+	b.flush(ch)
+}
+
+// flush publish buffered messages before any new message without holding the lock, messages buffered
+// meanwhile are published on the next round. A failed message and the ones after it stay buffered in order
+// for the next recovery, the channel is closed with the failure. With a publisher pool every round is
+// published on a pooled channel and kept whole on failure, its confirmed messages may be published twice
+func (b *Broker) flush(ch Channel) {
+	for {
+		b.mu.Lock()
+		buffered := b.buffer
+		b.buffer = nil
+		if len(buffered) == 0 {
+			b.recovering = false
+			b.mu.Unlock()
+			return
+		}
+		b.mu.Unlock()
+
+		if b.pool != nil {
+			if err := b.flushPool(buffered); err != nil {
+				log.Printf("rabbitmq > publish buffered messages: %s, %d message kept for the next recovery", err, len(buffered))
+				b.keep(buffered)
+				return
+			}
+			continue
+		}
+
+		for i, args := range buffered {
+			if err := publish(ch, args); err != nil {
+				log.Printf("rabbitmq > publish buffered message: %s, %d message kept for the next recovery", err, len(buffered)-i)
+				b.keep(buffered[i:])
+				return
+			}
+		}
+	}
+}
+
+// flushPool publish buffered messages on the publisher pool within Config.PublishWait
+func (b *Broker) flushPool(buffered []types.PublisherArgument) error {
+	ctx := context.Background()
+	if b.cfg.PublishWait > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.cfg.PublishWait)
+		defer cancel()
+	}
+
+	return b.pool.publish(ctx, buffered)
+}
+
+// keep put messages not published back in front of the buffer and end recovery
+func (b *Broker) keep(messages []types.PublisherArgument) {
+	b.mu.Lock()
+	b.buffer = append(messages, b.buffer...)
+	b.recovering = false
+	b.mu.Unlock()
+}
@@ -0,0 +1,677 @@
+package rabbitmq
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/abstract"
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/streadway/amqp"
+)
+
+type fakeChannel struct {
+	mu        sync.Mutex
+	published []amqp.Publishing
+	consumers []chan amqp.Delivery
+	closes    []chan *amqp.Error
+	cancels   []chan string
+	closed    bool
+}
+
+func (f *fakeChannel) Publish(_, _ string, _, _ bool, msg amqp.Publishing) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.published = append(f.published, msg)
+	return nil
+}
+
+func (f *fakeChannel) QueueDeclare(name string, _, _, _, _ bool, _ amqp.Table) (amqp.Queue, error) {
+	return amqp.Queue{Name: name}, nil
+}
+
+func (f *fakeChannel) QueueBind(_, _, _ string, _ bool, _ amqp.Table) error { return nil }
+
+func (f *fakeChannel) QueueInspect(name string) (amqp.Queue, error) {
+	return amqp.Queue{Name: name}, nil
+}
+
+func (f *fakeChannel) Consume(_, _ string, _, _, _, _ bool, _ amqp.Table) (<-chan amqp.Delivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := make(chan amqp.Delivery, 8)
+	f.consumers = append(f.consumers, c)
+	return c, nil
+}
+
+func (f *fakeChannel) Qos(_, _ int, _ bool) error { return nil }
+
+func (f *fakeChannel) Cancel(_ string, _ bool) error { return nil }
+
+func (f *fakeChannel) NotifyClose(c chan *amqp.Error) chan *amqp.Error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		close(c)
+		return c
+	}
+	f.closes = append(f.closes, c)
+	return c
+}
+
+func (f *fakeChannel) NotifyCancel(c chan string) chan string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cancels = append(f.cancels, c)
+	return c
+}
+
+func (f *fakeChannel) Close() error {
+	f.fail(nil)
+	return nil
+}
+
+// fail close the channel by err, like a channel exception, consumers are closed
+func (f *fakeChannel) fail(err *amqp.Error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return
+	}
+	f.closed = true
+	for _, c := range f.consumers {
+		close(c)
+	}
+	for _, c := range f.closes {
+		if err != nil {
+			c <- err
+		}
+		close(c)
+	}
+	for _, c := range f.cancels {
+		close(c)
+	}
+}
+
+// cancel cancel every consumer like a deleted queue, the channel stays open
+func (f *fakeChannel) cancel(consumer string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return
+	}
+	for _, c := range f.consumers {
+		close(c)
+	}
+	f.consumers = nil
+	for _, c := range f.cancels {
+		c <- consumer
+	}
+}
+
+func (f *fakeChannel) bodies() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []string
+	for _, p := range f.published {
+		out = append(out, string(p.Body))
+	}
+	return out
+}
+
+type fakeConnection struct {
+	mu     sync.Mutex
+	ch     *fakeChannel
+	notify []chan *amqp.Error
+	closed bool
+	opened int // channels opened on the connection
+}
+
+// Channel ch on the first call, a new channel on every next one
+func (f *fakeConnection) Channel() (Channel, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil, amqp.ErrClosed
+	}
+	if f.opened > 0 {
+		f.ch = &fakeChannel{}
+	}
+	f.opened++
+	return f.ch, nil
+}
+
+func (f *fakeConnection) NotifyClose(c chan *amqp.Error) chan *amqp.Error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		close(c)
+		return c
+	}
+	f.notify = append(f.notify, c)
+	return c
+}
+
+func (f *fakeConnection) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.closed {
+		f.closed = true
+		for _, c := range f.notify {
+			close(c)
+		}
+	}
+	return nil
+}
+
+// kill close the connection by error, like a restarted server
+func (f *fakeConnection) kill() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	f.ch.fail(&amqp.Error{Code: amqp.ConnectionForced, Reason: "CONNECTION_FORCED"})
+	for _, c := range f.notify {
+		c <- &amqp.Error{Code: amqp.ConnectionForced, Reason: "CONNECTION_FORCED"}
+		close(c)
+	}
+}
+
+// fakeServer dialer of fake connections, dial fails while down
+type fakeServer struct {
+	mu    sync.Mutex
+	down  bool
+	conns []*fakeConnection
+}
+
+func (f *fakeServer) dial(_ string) (Connection, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return nil, errors.New("connection refused")
+	}
+
+	conn := &fakeConnection{ch: &fakeChannel{}}
+	f.conns = append(f.conns, conn)
+	return conn, nil
+}
+
+func (f *fakeServer) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+func (f *fakeServer) conn(i int) *fakeConnection {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.conns[i]
+}
+
+// restart kill the current connection and keep the server down until up is called
+func (f *fakeServer) restart(t *testing.T, b *Broker) (up func()) {
+	f.setDown(true)
+	f.conn(0).kill()
+
+	waitFor(t, b.Recovering)
+	return func() { f.setDown(false) }
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func newTestBroker(t *testing.T, server *fakeServer, policy PublishPolicy) *Broker {
+	b, err := New(SetURL("amqp://test"), SetDialer(server.dial),
+		SetReconnectBackoff(time.Millisecond, 5*time.Millisecond),
+		SetPublishPolicy(policy, time.Second, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = b.Disconnect(context.Background()) })
+
+	return b
+}
+
+func TestPublishPolicyFail(t *testing.T) {
+	server := &fakeServer{}
+	b := newTestBroker(t, server, PublishFail)
+	up := server.restart(t, b)
+
+	if err := b.Check(context.Background()); !errors.Is(err, ErrRecovering) {
+		t.Errorf("expected degraded health while recovering, got %v", err)
+	}
+
+	if err := b.GetPublisher().PublishMessage(context.Background(), types.PublisherArgument{Key: "k"}); !errors.Is(err, ErrRecovering) {
+		t.Errorf("expected ErrRecovering, got %v", err)
+	}
+
+	up()
+	waitFor(t, func() bool { return !b.Recovering() })
+	if err := b.Check(context.Background()); err != nil {
+		t.Errorf("expected healthy after recovery, got %v", err)
+	}
+}
+
+func TestPublishPolicyBuffer(t *testing.T) {
+	server := &fakeServer{}
+	b := newTestBroker(t, server, PublishBuffer)
+	up := server.restart(t, b)
+
+	pub := b.GetPublisher()
+	for _, body := range []string{"1", "2"} {
+		if err := pub.PublishMessage(context.Background(), types.PublisherArgument{Key: "k", Message: []byte(body)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := pub.PublishMessage(context.Background(), types.PublisherArgument{Key: "k", Message: []byte("3")}); !errors.Is(err, ErrPublishBufferFull) {
+		t.Errorf("expected ErrPublishBufferFull, got %v", err)
+	}
+
+	up()
+	waitFor(t, func() bool { return !b.Recovering() })
+
+	if got := server.conn(1).ch.bodies(); len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Errorf("expected buffered messages published in order on the new connection, got %v", got)
+	}
+}
+
+func TestPublishPolicyBlock(t *testing.T) {
+	server := &fakeServer{}
+	b := newTestBroker(t, server, PublishBlock)
+	up := server.restart(t, b)
+
+	time.AfterFunc(20*time.Millisecond, up)
+	if err := b.GetPublisher().PublishMessage(context.Background(), types.PublisherArgument{Key: "k", Message: []byte("1")}); err != nil {
+		t.Fatalf("expected publish blocked until recovered, got %v", err)
+	}
+
+	if got := server.conn(1).ch.bodies(); len(got) != 1 {
+		t.Errorf("expected message published on the new connection, got %v", got)
+	}
+}
+
+// poolChannel fake channel failing every publish once broken, slow simulate the round trip of a channel
+type poolChannel struct {
+	fakeChannel
+	broken bool
+	closed bool
+	slow   time.Duration
+}
+
+func (f *poolChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	f.mu.Lock()
+	broken := f.broken
+	f.mu.Unlock()
+	if broken {
+		return amqp.ErrClosed
+	}
+	if f.slow > 0 {
+		// a channel serializes its frames
+		f.mu.Lock()
+		time.Sleep(f.slow)
+		f.mu.Unlock()
+	}
+
+	return f.fakeChannel.Publish(exchange, key, mandatory, immediate, msg)
+}
+
+func (f *poolChannel) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+// poolConnection fake connection opening a new channel on every Channel call
+type poolConnection struct {
+	fakeConnection
+	slow   time.Duration
+	opened []*poolChannel
+}
+
+func (f *poolConnection) Channel() (Channel, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch := &poolChannel{slow: f.slow}
+	f.opened = append(f.opened, ch)
+	return ch, nil
+}
+
+func (f *poolConnection) channels() []*poolChannel {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*poolChannel(nil), f.opened...)
+}
+
+func newPoolBroker(t testing.TB, size int, slow time.Duration) (*Broker, *poolConnection) {
+	conn := &poolConnection{fakeConnection: fakeConnection{ch: &fakeChannel{}}, slow: slow}
+	b, err := New(SetURL("amqp://test"), SetPublisherPool(size, false),
+		SetDialer(func(string) (Connection, error) { return conn, nil }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = b.Disconnect(context.Background()) })
+
+	return b, conn
+}
+
+func TestPublisherPoolReplaceBrokenChannel(t *testing.T) {
+	b, conn := newPoolBroker(t, 1, 0)
+	pub := b.GetPublisher().(abstract.BatchPublisher)
+	ctx := context.Background()
+
+	if err := pub.PublishMessage(ctx, types.PublisherArgument{Queue: "order", Message: []byte("1")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// channels()[0] is the channel of the connection opened by New
+	first := conn.channels()[1]
+	first.mu.Lock()
+	first.broken = true
+	first.mu.Unlock()
+	if err := pub.PublishMessage(ctx, types.PublisherArgument{Queue: "order", Message: []byte("2")}); !errors.Is(err, amqp.ErrClosed) {
+		t.Fatalf("expected publish failure on broken channel, got %v", err)
+	}
+
+	err := pub.PublishMessages(ctx, []types.PublisherArgument{
+		{Queue: "order", Message: []byte("3")},
+		{Queue: "order", Message: []byte("4")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	channels := conn.channels()[1:]
+	if len(channels) != 2 || !channels[0].closed {
+		t.Fatalf("expected broken channel closed and replaced, got %d channels", len(channels))
+	}
+	if got := channels[1].bodies(); len(got) != 2 || got[0] != "3" || got[1] != "4" {
+		t.Errorf("expected batch published in order on the new channel, got %v", got)
+	}
+}
+
+func TestPublisherPoolDropClosedChannel(t *testing.T) {
+	b, conn := newPoolBroker(t, 1, 0)
+	pub := b.GetPublisher()
+	ctx := context.Background()
+
+	if err := pub.PublishMessage(ctx, types.PublisherArgument{Queue: "order", Message: []byte("1")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// idle channel closed by a channel exception of the broker
+	conn.channels()[1].fail(&amqp.Error{Code: amqp.NotFound, Reason: "NOT_FOUND - no exchange 'order'"})
+	if err := pub.PublishMessage(ctx, types.PublisherArgument{Queue: "order", Message: []byte("2")}); err != nil {
+		t.Fatalf("expected closed channel replaced before publish, got %v", err)
+	}
+
+	channels := conn.channels()[1:]
+	if len(channels) != 2 || !channels[0].closed {
+		t.Fatalf("expected closed channel dropped and replaced, got %d channels", len(channels))
+	}
+	if got := channels[1].bodies(); len(got) != 1 || got[0] != "2" {
+		t.Errorf("expected publish on the new channel, got %v", got)
+	}
+}
+
+func TestPublishConfirmWithoutPool(t *testing.T) {
+	b, err := New(SetURL("amqp://test"), SetDialer((&fakeServer{}).dial), SetPublisherPool(0, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect(context.Background())
+
+	if b.pool == nil || cap(b.pool.slots) != 1 {
+		t.Error("expected confirms awaited on a pool of one channel")
+	}
+}
+
+func TestFlushPublisherPool(t *testing.T) {
+	b, conn := newPoolBroker(t, 1, 0)
+	b.mu.Lock()
+	b.recovering = true
+	b.buffer = []types.PublisherArgument{{Queue: "order", Message: []byte("1")}, {Queue: "order", Message: []byte("2")}}
+	b.mu.Unlock()
+
+	b.flush(b.Session().Channel)
+	if b.Recovering() {
+		t.Error("expected recovery finished")
+	}
+
+	channels := conn.channels()
+	if got := channels[0].bodies(); len(got) != 0 {
+		t.Errorf("expected buffered messages off the shared channel, got %v", got)
+	}
+	if got := channels[1].bodies(); len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Errorf("expected buffered messages published in order on the pool, got %v", got)
+	}
+}
+
+func TestPublisherPoolExhausted(t *testing.T) {
+	b, _ := newPoolBroker(t, 1, 0)
+
+	held, err := b.pool.borrow(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = b.GetPublisher().PublishMessage(ctx, types.PublisherArgument{Queue: "order", Message: []byte("1")})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded on exhausted pool, got %v", err)
+	}
+
+	b.pool.release(held)
+	if err = b.GetPublisher().PublishMessage(context.Background(), types.PublisherArgument{Queue: "order", Message: []byte("2")}); err != nil {
+		t.Errorf("expected publish once the channel is released, got %v", err)
+	}
+}
+
+func benchmarkPublish(b *testing.B, poolSize int) {
+	broker, _ := newPoolBroker(b, poolSize, 20*time.Microsecond)
+	if poolSize == 0 {
+		// shared channel of the connection
+		broker.pool = nil
+		broker.ch = &poolChannel{slow: 20 * time.Microsecond}
+	}
+	pub := broker.GetPublisher()
+	args := types.PublisherArgument{Queue: "order", Message: []byte(`{"order_id":"1"}`)}
+
+	b.SetParallelism(4)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := pub.PublishMessage(context.Background(), args); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
+func BenchmarkPublishSharedChannel(b *testing.B) { benchmarkPublish(b, 0) }
+func BenchmarkPublishPool8(b *testing.B)         { benchmarkPublish(b, 8) }
+
+func (f *fakeConnection) channel() *fakeChannel {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.ch
+}
+
+func TestChannelRecovery(t *testing.T) {
+	tests := []struct {
+		name  string
+		close func(ch *fakeChannel)
+	}{
+		{"channel exception", func(ch *fakeChannel) {
+			ch.fail(&amqp.Error{Code: amqp.PreconditionFailed, Reason: "PRECONDITION_FAILED - unknown delivery tag 1"})
+		}},
+		{"consumer cancelled", func(ch *fakeChannel) { ch.cancel("order.created") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &fakeServer{}
+			b := newTestBroker(t, server, PublishFail)
+			conn := server.conn(0)
+			old := b.Session()
+
+			deliveries, err := b.Channel().Consume("order.created", "order.created", false, false, false, false, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// broker is watching the channel
+			waitFor(t, func() bool {
+				ch := conn.channel()
+				ch.mu.Lock()
+				defer ch.mu.Unlock()
+				return len(ch.closes) > 0 && len(ch.cancels) > 0
+			})
+
+			tt.close(conn.channel())
+			if _, ok := <-deliveries; ok {
+				t.Fatal("expected consumer closed")
+			}
+
+			// consumers wait for the next generation to register again
+			select {
+			case <-old.Recovered:
+			case <-time.After(2 * time.Second):
+				t.Fatal("expected channel recovered")
+			}
+			if s := b.Session(); s.Generation != old.Generation+1 || s.Channel == old.Channel {
+				t.Errorf("expected new channel on next generation, got %d", s.Generation)
+			}
+			if err = b.Check(context.Background()); err != nil {
+				t.Errorf("expected healthy once recovered, got %v", err)
+			}
+
+			if err = b.GetPublisher().PublishMessage(context.Background(), types.PublisherArgument{Key: "k", Message: []byte("after")}); err != nil {
+				t.Fatal(err)
+			}
+			if got := conn.channel().bodies(); len(got) != 1 || got[0] != "after" {
+				t.Errorf("expected publish on reopened channel, got %v", got)
+			}
+			if len(server.conns) != 1 {
+				t.Errorf("expected connection kept, got %d dials", len(server.conns))
+			}
+		})
+	}
+}
+
+func TestReconnectBackoffDefault(t *testing.T) {
+	b, err := New(SetDialer((&fakeServer{}).dial), SetReconnectBackoff(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect(context.Background())
+
+	if b.cfg.ReconnectMin != defaultReconnectMin || b.cfg.ReconnectMax != defaultReconnectMin {
+		t.Errorf("expected zero backoff defaulted, got %s and %s", b.cfg.ReconnectMin, b.cfg.ReconnectMax)
+	}
+}
+
+func TestConsumerCancelFlood(t *testing.T) {
+	server := &fakeServer{}
+	b := newTestBroker(t, server, PublishFail)
+	conn := server.conn(0)
+	old := b.Session()
+
+	waitFor(t, func() bool {
+		ch := conn.channel()
+		ch.mu.Lock()
+		defer ch.mu.Unlock()
+		return len(ch.cancels) > 0
+	})
+
+	// every consumer of a deleted queue is cancelled at once, the client must not block on the second one
+	flooded := make(chan struct{})
+	go func() {
+		ch := conn.channel()
+		for i := 0; i < 8; i++ {
+			ch.cancel("order.created")
+		}
+		close(flooded)
+	}()
+
+	select {
+	case <-flooded:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected cancels delivered without blocking")
+	}
+	select {
+	case <-old.Recovered:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected channel recovered")
+	}
+}
+
+func TestFlushKeepFailedMessages(t *testing.T) {
+	b := &Broker{recovering: true, buffer: []types.PublisherArgument{
+		{Key: "k", Message: []byte("1")},
+		{Key: "k", Message: []byte("2")},
+	}}
+
+	b.flush(&poolChannel{broken: true})
+	if b.recovering {
+		t.Error("expected recovery finished")
+	}
+	if len(b.buffer) != 2 || string(b.buffer[0].Message) != "1" || string(b.buffer[1].Message) != "2" {
+		t.Fatalf("expected failed messages kept in order, got %v", b.buffer)
+	}
+
+	ch := &fakeChannel{}
+	b.flush(ch)
+	if got := ch.bodies(); len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Errorf("expected kept messages published on the next recovery, got %v", got)
+	}
+	if len(b.buffer) != 0 {
+		t.Errorf("expected buffer flushed, got %d message", len(b.buffer))
+	}
+}
+
+func TestPublishPayloadLimits(t *testing.T) {
+	server := &fakeServer{}
+	b, err := New(SetURL("amqp://test"), SetDialer(server.dial),
+		SetPayloadLimits(types.PayloadLimits{MaxMessageBytes: 64, CompressThreshold: 32}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = b.Disconnect(context.Background()) })
+
+	payload := bytes.Repeat([]byte("x"), 128)
+	if err = b.GetPublisher().PublishMessage(context.Background(), types.PublisherArgument{Key: "k", Message: payload}); err != nil {
+		t.Fatal(err)
+	}
+
+	published := server.conn(0).channel().published
+	if len(published) != 1 || published[0].Headers[types.HeaderContentEncoding] != types.EncodingGzip {
+		t.Fatalf("expected compressed payload published, got %+v", published)
+	}
+
+	// limits are per publisher, another broker publishes as is
+	other, err := New(SetURL("amqp://test"), SetDialer(server.dial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = other.Disconnect(context.Background()) })
+
+	if err = other.GetPublisher().PublishMessage(context.Background(), types.PublisherArgument{Key: "k", Message: payload}); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.conn(1).channel().published; len(got) != 1 || len(got[0].Body) != len(payload) {
+		t.Errorf("expected payload published uncompressed, got %+v", got)
+	}
+}
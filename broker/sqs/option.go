@@ -0,0 +1,138 @@
+package sqs
+
+import (
+	"time"
+
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/TixiaOTA/gokit/utils/env"
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const (
+	// defaultVisibilityTimeout visibility of received message when none is configured
+	defaultVisibilityTimeout = 30 * time.Second
+	// maxVisibilityTimeout maximum visibility timeout accepted by SQS
+	maxVisibilityTimeout = 12 * time.Hour
+)
+
+// Config configuration of SQS/SNS broker
+type Config struct {
+	// Region aws region, default from the aws credentials chain (AWS_REGION, shared config)
+	Region string
+	// Endpoint custom endpoint, e.g. localstack "http://localhost:4566"
+	Endpoint string
+	// Credentials custom credentials provider, default is the aws credentials chain
+	Credentials aws.CredentialsProvider
+	// WaitTime long polling wait time, maximum 20s
+	WaitTime time.Duration
+	// MaxMessages maximum messages received on each poll, maximum 10
+	MaxMessages int32
+	// VisibilityTimeout visibility of received message, extended every half of it while handler runs, 1s to 12h
+	VisibilityTimeout time.Duration
+	// PayloadLimits size guard and compression of published payloads, MaxMessageBytes also guards
+	// consumed payloads after decompression
+	PayloadLimits types.PayloadLimits
+}
+
+// OptionFunc setter of Config
+type OptionFunc func(*Config)
+
+func getDefaultConfig() Config {
+	return Config{
+		Region:            env.GetString("AWS_REGION"),
+		Endpoint:          env.GetString("AWS_SQS_ENDPOINT"),
+		WaitTime:          env.GetDuration("SQS_WAIT_TIME", 20*time.Second),
+		MaxMessages:       int32(env.GetInteger("SQS_MAX_MESSAGES", 10)),
+		VisibilityTimeout: env.GetDuration("SQS_VISIBILITY_TIMEOUT", defaultVisibilityTimeout),
+	}
+}
+
+// SetRegion set aws region
+func SetRegion(region string) OptionFunc {
+	return func(c *Config) {
+		c.Region = region
+	}
+}
+
+// SetEndpoint set custom endpoint of SQS and SNS, e.g. localstack
+func SetEndpoint(endpoint string) OptionFunc {
+	return func(c *Config) {
+		c.Endpoint = endpoint
+	}
+}
+
+// SetCredentials set credentials provider instead of the aws credentials chain
+func SetCredentials(provider aws.CredentialsProvider) OptionFunc {
+	return func(c *Config) {
+		c.Credentials = provider
+	}
+}
+
+// SetWaitTime set long polling wait time
+func SetWaitTime(wait time.Duration) OptionFunc {
+	return func(c *Config) {
+		c.WaitTime = wait
+	}
+}
+
+// SetMaxMessages set maximum messages received on each poll
+func SetMaxMessages(max int32) OptionFunc {
+	return func(c *Config) {
+		c.MaxMessages = max
+	}
+}
+
+// SetVisibilityTimeout set visibility timeout of received message
+func SetVisibilityTimeout(visibility time.Duration) OptionFunc {
+	return func(c *Config) {
+		c.VisibilityTimeout = visibility
+	}
+}
+
+// SetPayloadLimits set size guard and compression of published and consumed payloads
+func SetPayloadLimits(limits types.PayloadLimits) OptionFunc {
+	return func(c *Config) {
+		c.PayloadLimits = limits
+	}
+}
+
+// workerOption options of SQS consumer
+type workerOption struct {
+	maxGoroutines int
+	serviceName   string
+
+	// never install the logger store of consumed messages
+	disableMessageLog bool
+}
+
+// WorkerOptionFunc setter of consumer options
+type WorkerOptionFunc func(*workerOption)
+
+func getDefaultWorkerOption() workerOption {
+	return workerOption{
+		maxGoroutines:     env.GetInteger("BROKER_MAX_GOROUTINES", 20),
+		disableMessageLog: env.GetBool("BROKER_DISABLE_MESSAGE_LOG"),
+	}
+}
+
+// SetMaxGoroutines set maximum messages handled concurrently
+func SetMaxGoroutines(maxGoroutines int) WorkerOptionFunc {
+	return func(o *workerOption) {
+		o.maxGoroutines = maxGoroutines
+	}
+}
+
+// SetServiceName set service name on data logger
+func SetServiceName(serviceName string) WorkerOptionFunc {
+	return func(o *workerOption) {
+		o.serviceName = serviceName
+	}
+}
+
+// DisableMessageLog never wrap consumed messages in a logger store, handlers log without context
+// and no entry is written per message
+func DisableMessageLog() WorkerOptionFunc {
+	return func(o *workerOption) {
+		o.disableMessageLog = true
+	}
+}
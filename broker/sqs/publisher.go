@@ -0,0 +1,131 @@
+package sqs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/TixiaOTA/gokit/utils/convert"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	awssqs "github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+var (
+	// ErrNoDestination publisher argument has neither Topic nor Queue
+	ErrNoDestination = errors.New("sqs: publish needs a Topic (SNS topic arn) or a Queue")
+	// ErrMissingGroupId FIFO destination needs Key as message group id
+	ErrMissingGroupId = errors.New("sqs: FIFO destination needs Key as message group id")
+)
+
+// publisher publish to SNS topic when Topic is set, otherwise to SQS Queue.
+// Headers are sent as string message attributes, on FIFO destination Key is the message group id
+// and CorrelationId the deduplication id (content based deduplication is used when empty).
+// Compressed payload, see types.PayloadLimits, is sent base64 encoded
+type publisher struct {
+	broker *Broker
+}
+
+func (p *publisher) PublishMessage(ctx context.Context, args types.PublisherArgument) error {
+	if err := args.Validate(); err != nil {
+		return err
+	}
+
+	// message body must be text, the limit applies to the base64 encoded payload
+	args, err := args.EncodeBase64(p.broker.cfg.PayloadLimits)
+	if err != nil {
+		return err
+	}
+	args.Headers = logger.InjectBaggage(ctx, args.Headers)
+
+	switch {
+	case args.Topic != "":
+		return p.publishTopic(ctx, args)
+	case args.Queue != "":
+		return p.sendQueue(ctx, args)
+	default:
+		return ErrNoDestination
+	}
+}
+
+func (p *publisher) publishTopic(ctx context.Context, args types.PublisherArgument) error {
+	in := &sns.PublishInput{
+		TopicArn: aws.String(args.Topic),
+		Message:  aws.String(string(args.Message)),
+	}
+
+	if len(args.Headers) > 0 {
+		in.MessageAttributes = make(map[string]snstypes.MessageAttributeValue, len(args.Headers))
+		for k, v := range args.Headers {
+			in.MessageAttributes[k] = snstypes.MessageAttributeValue{
+				DataType:    aws.String("String"),
+				StringValue: aws.String(convert.ToString(v)),
+			}
+		}
+	}
+
+	if isFIFO(args.Topic) {
+		if args.Key == "" {
+			return ErrMissingGroupId
+		}
+
+		in.MessageGroupId = aws.String(args.Key)
+		if args.CorrelationId != "" {
+			in.MessageDeduplicationId = aws.String(args.CorrelationId)
+		}
+	}
+
+	if _, err := p.broker.sns.Publish(ctx, in); err != nil {
+		return fmt.Errorf("sqs: publish to topic %q: %w", args.Topic, err)
+	}
+
+	return nil
+}
+
+func (p *publisher) sendQueue(ctx context.Context, args types.PublisherArgument) error {
+	url, err := p.broker.queueURL(ctx, args.Queue)
+	if err != nil {
+		return err
+	}
+
+	in := &awssqs.SendMessageInput{
+		QueueUrl:    aws.String(url),
+		MessageBody: aws.String(string(args.Message)),
+	}
+
+	if len(args.Headers) > 0 {
+		in.MessageAttributes = make(map[string]sqstypes.MessageAttributeValue, len(args.Headers))
+		for k, v := range args.Headers {
+			in.MessageAttributes[k] = sqstypes.MessageAttributeValue{
+				DataType:    aws.String("String"),
+				StringValue: aws.String(convert.ToString(v)),
+			}
+		}
+	}
+
+	if isFIFO(url) {
+		if args.Key == "" {
+			return ErrMissingGroupId
+		}
+
+		in.MessageGroupId = aws.String(args.Key)
+		if args.CorrelationId != "" {
+			in.MessageDeduplicationId = aws.String(args.CorrelationId)
+		}
+	}
+
+	if _, err = p.broker.sqs.SendMessage(ctx, in); err != nil {
+		return fmt.Errorf("sqs: send to queue %q: %w", args.Queue, err)
+	}
+
+	return nil
+}
+
+func isFIFO(destination string) bool {
+	return strings.HasSuffix(destination, ".fifo")
+}
@@ -0,0 +1,132 @@
+package sqs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TixiaOTA/gokit/abstract"
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	awssqs "github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// sqsAPI subset of SQS client used by broker
+type sqsAPI interface {
+	GetQueueUrl(ctx context.Context, params *awssqs.GetQueueUrlInput, optFns ...func(*awssqs.Options)) (*awssqs.GetQueueUrlOutput, error)
+	SendMessage(ctx context.Context, params *awssqs.SendMessageInput, optFns ...func(*awssqs.Options)) (*awssqs.SendMessageOutput, error)
+	ReceiveMessage(ctx context.Context, params *awssqs.ReceiveMessageInput, optFns ...func(*awssqs.Options)) (*awssqs.ReceiveMessageOutput, error)
+	DeleteMessage(ctx context.Context, params *awssqs.DeleteMessageInput, optFns ...func(*awssqs.Options)) (*awssqs.DeleteMessageOutput, error)
+	ChangeMessageVisibility(ctx context.Context, params *awssqs.ChangeMessageVisibilityInput, optFns ...func(*awssqs.Options)) (*awssqs.ChangeMessageVisibilityOutput, error)
+}
+
+// snsAPI subset of SNS client used by broker
+type snsAPI interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+// Broker SQS/SNS implementation of abstract.Broker
+type Broker struct {
+	cfg       Config
+	sqs       sqsAPI
+	sns       snsAPI
+	publisher *publisher
+	// tail publish the buffered messages of workers through publisher
+	tail *types.TailPublisher
+
+	// queue url by queue name
+	queueURLs sync.Map
+}
+
+// New create SQS/SNS broker, credentials are resolved by the aws credentials chain unless SetCredentials is used
+func New(ctx context.Context, opts ...OptionFunc) (*Broker, error) {
+	cfg := getDefaultConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if cfg.VisibilityTimeout < time.Second || cfg.VisibilityTimeout > maxVisibilityTimeout {
+		return nil, fmt.Errorf("sqs: visibility timeout %s out of range 1s to %s", cfg.VisibilityTimeout, maxVisibilityTimeout)
+	}
+
+	var loadOpts []func(*awsconfig.LoadOptions) error
+	if cfg.Region != "" {
+		loadOpts = append(loadOpts, awsconfig.WithRegion(cfg.Region))
+	}
+	if cfg.Credentials != nil {
+		loadOpts = append(loadOpts, awsconfig.WithCredentialsProvider(cfg.Credentials))
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("sqs: load aws config: %w", err)
+	}
+
+	sqsClient := awssqs.NewFromConfig(awsCfg, func(o *awssqs.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+	})
+	snsClient := sns.NewFromConfig(awsCfg, func(o *sns.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+	})
+
+	return newBroker(cfg, sqsClient, snsClient), nil
+}
+
+func newBroker(cfg Config, sqsClient sqsAPI, snsClient snsAPI) *Broker {
+	if cfg.VisibilityTimeout <= 0 {
+		cfg.VisibilityTimeout = defaultVisibilityTimeout
+	}
+
+	b := &Broker{cfg: cfg, sqs: sqsClient, sns: snsClient}
+	b.publisher = &publisher{broker: b}
+	b.tail = types.NewTailPublisher(b.publisher.PublishMessage)
+
+	return b
+}
+
+// GetPublisher publisher to SNS topic or SQS queue
+func (b *Broker) GetPublisher() abstract.Publisher {
+	return b.publisher
+}
+
+// GetName types.SQS
+func (b *Broker) GetName() types.Broker {
+	return types.SQS
+}
+
+// GetConfiguration Config of broker
+func (b *Broker) GetConfiguration() interface{} {
+	return b.cfg
+}
+
+// Disconnect nothing to close, aws clients are stateless
+func (b *Broker) Disconnect(_ context.Context) error {
+	return nil
+}
+
+// queueURL resolve queue url of queue name, url is returned as is
+func (b *Broker) queueURL(ctx context.Context, queue string) (string, error) {
+	if strings.HasPrefix(queue, "https://") || strings.HasPrefix(queue, "http://") {
+		return queue, nil
+	}
+
+	if url, ok := b.queueURLs.Load(queue); ok {
+		return url.(string), nil
+	}
+
+	out, err := b.sqs.GetQueueUrl(ctx, &awssqs.GetQueueUrlInput{QueueName: aws.String(queue)})
+	if err != nil {
+		return "", fmt.Errorf("sqs: get url of queue %q: %w", queue, err)
+	}
+
+	b.queueURLs.Store(queue, aws.ToString(out.QueueUrl))
+	return aws.ToString(out.QueueUrl), nil
+}
@@ -0,0 +1,349 @@
+package sqs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	awssqs "github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+type fakeSQS struct {
+	mu         sync.Mutex
+	sent       []*awssqs.SendMessageInput
+	deleted    []string
+	extensions int
+	// sendLimit fails sending beyond the first sendLimit messages, zero means unlimited
+	sendLimit int
+	// received messages returned by the next receive
+	received []sqstypes.Message
+}
+
+func (f *fakeSQS) GetQueueUrl(_ context.Context, in *awssqs.GetQueueUrlInput, _ ...func(*awssqs.Options)) (*awssqs.GetQueueUrlOutput, error) {
+	return &awssqs.GetQueueUrlOutput{QueueUrl: aws.String("https://sqs.local/000/" + aws.ToString(in.QueueName))}, nil
+}
+
+func (f *fakeSQS) SendMessage(_ context.Context, in *awssqs.SendMessageInput, _ ...func(*awssqs.Options)) (*awssqs.SendMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.sendLimit > 0 && len(f.sent) >= f.sendLimit {
+		return nil, errors.New("throttled")
+	}
+	f.sent = append(f.sent, in)
+	return &awssqs.SendMessageOutput{}, nil
+}
+
+func (f *fakeSQS) ReceiveMessage(ctx context.Context, _ *awssqs.ReceiveMessageInput, _ ...func(*awssqs.Options)) (*awssqs.ReceiveMessageOutput, error) {
+	f.mu.Lock()
+	received := f.received
+	f.received = nil
+	f.mu.Unlock()
+	if len(received) > 0 {
+		return &awssqs.ReceiveMessageOutput{Messages: received}, nil
+	}
+
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (f *fakeSQS) DeleteMessage(_ context.Context, in *awssqs.DeleteMessageInput, _ ...func(*awssqs.Options)) (*awssqs.DeleteMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deleted = append(f.deleted, aws.ToString(in.ReceiptHandle))
+	return &awssqs.DeleteMessageOutput{}, nil
+}
+
+func (f *fakeSQS) ChangeMessageVisibility(_ context.Context, _ *awssqs.ChangeMessageVisibilityInput, _ ...func(*awssqs.Options)) (*awssqs.ChangeMessageVisibilityOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.extensions++
+	return &awssqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+type fakeSNS struct {
+	published []*sns.PublishInput
+}
+
+func (f *fakeSNS) Publish(_ context.Context, in *sns.PublishInput, _ ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	f.published = append(f.published, in)
+	return &sns.PublishOutput{}, nil
+}
+
+func TestProcessMessage(t *testing.T) {
+	client := &fakeSQS{}
+	broker := newBroker(Config{VisibilityTimeout: 40 * time.Millisecond}, client, &fakeSNS{})
+	w := newWorker(broker)
+
+	var delivery types.Delivery
+	slow := types.BrokerHandler{Queue: "orders", HandlerFunc: func(ec *types.EventContext) error {
+		delivery, _ = types.DeliveryFromContext(ec.Context())
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	}}
+
+	w.processMessage("https://sqs.local/000/orders", slow, sqstypes.Message{
+		MessageId:         aws.String("1"),
+		ReceiptHandle:     aws.String("receipt-1"),
+		Body:              aws.String(`{"id":1}`),
+		Attributes:        map[string]string{"ApproximateReceiveCount": "2"},
+		MessageAttributes: map[string]sqstypes.MessageAttributeValue{"tenant": {StringValue: aws.String("acme")}},
+	}, "worker-1")
+
+	client.mu.Lock()
+	extensions, deleted := client.extensions, client.deleted
+	client.mu.Unlock()
+
+	if extensions < 2 {
+		t.Errorf("expected visibility extended while handler runs, got %d extensions", extensions)
+	}
+
+	if len(deleted) != 1 || deleted[0] != "receipt-1" {
+		t.Errorf("expected message deleted on success, got %v", deleted)
+	}
+
+	if delivery.Attempt != 2 || !delivery.Redelivered || delivery.Headers["tenant"] != "acme" {
+		t.Errorf("expected delivery metadata, got %+v", delivery)
+	}
+
+	// failed message is left for redrive
+	failing := types.BrokerHandler{Queue: "orders", HandlerFunc: func(ec *types.EventContext) error {
+		return errors.New("boom")
+	}}
+	w.processMessage("https://sqs.local/000/orders", failing, sqstypes.Message{ReceiptHandle: aws.String("receipt-2"), Body: aws.String("{}")}, "worker-1")
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if len(client.deleted) != 1 {
+		t.Errorf("expected failed message not deleted, got %v", client.deleted)
+	}
+}
+
+func TestVisibilityTimeout(t *testing.T) {
+	if b := newBroker(Config{}, &fakeSQS{}, &fakeSNS{}); b.cfg.VisibilityTimeout != defaultVisibilityTimeout {
+		t.Errorf("expected default visibility timeout, got %s", b.cfg.VisibilityTimeout)
+	}
+
+	for _, visibility := range []time.Duration{0, time.Nanosecond, 13 * time.Hour} {
+		if _, err := New(context.Background(), SetRegion("ap-southeast-1"), SetVisibilityTimeout(visibility)); err == nil {
+			t.Errorf("expected visibility timeout %s rejected", visibility)
+		}
+	}
+}
+
+func TestPublishBufferPartialFailurePublishesTail(t *testing.T) {
+	client := &fakeSQS{sendLimit: 1}
+	w := newWorker(newBroker(Config{VisibilityTimeout: time.Minute}, client, &fakeSNS{}))
+
+	handler := types.BrokerHandler{Queue: "orders", HandlerFunc: func(ec *types.EventContext) error {
+		for _, queue := range []string{"payments", "shipments"} {
+			ec.PublishBuffer().Publish(types.PublisherArgument{Queue: queue, Message: []byte(`{}`)})
+		}
+		return nil
+	}}
+	message := sqstypes.Message{MessageId: aws.String("msg-1"), ReceiptHandle: aws.String("receipt-1"), Body: aws.String("{}")}
+
+	// second publish fails, the message is left on queue
+	w.processMessage("https://sqs.local/000/orders", handler, message, "worker-1")
+	if len(client.deleted) != 0 || len(client.sent) != 1 {
+		t.Fatalf("expected message kept after one publish, got deleted=%v sent=%d", client.deleted, len(client.sent))
+	}
+
+	// the message reappears, only the unsent tail is published
+	client.sendLimit = 0
+	w.processMessage("https://sqs.local/000/orders", handler, message, "worker-1")
+	if len(client.deleted) != 1 || len(client.sent) != 2 || !strings.HasSuffix(aws.ToString(client.sent[1].QueueUrl), "/shipments") {
+		t.Errorf("expected each buffered message sent once, got deleted=%v sent=%d", client.deleted, len(client.sent))
+	}
+}
+
+func TestPublishMessage(t *testing.T) {
+	client, topic := &fakeSQS{}, &fakeSNS{}
+	p := newBroker(Config{}, client, topic).GetPublisher()
+	ctx := context.Background()
+
+	err := p.PublishMessage(ctx, types.PublisherArgument{
+		Queue:         "orders.fifo",
+		Key:           "order-1",
+		CorrelationId: "event-1",
+		Headers:       map[string]interface{}{"attempt": 1},
+		Message:       []byte(`{}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := client.sent[0]
+	if aws.ToString(sent.QueueUrl) != "https://sqs.local/000/orders.fifo" || aws.ToString(sent.MessageGroupId) != "order-1" ||
+		aws.ToString(sent.MessageDeduplicationId) != "event-1" || aws.ToString(sent.MessageAttributes["attempt"].StringValue) != "1" {
+		t.Errorf("expected FIFO message with attributes, got %+v", sent)
+	}
+
+	if err = p.PublishMessage(ctx, types.PublisherArgument{Queue: "orders.fifo", Message: []byte(`{}`)}); !errors.Is(err, ErrMissingGroupId) {
+		t.Errorf("expected missing group id, got %v", err)
+	}
+
+	if err = p.PublishMessage(ctx, types.PublisherArgument{Topic: "arn:aws:sns:ap-southeast-1:000:order-created", Message: []byte(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if len(topic.published) != 1 || aws.ToString(topic.published[0].TopicArn) != "arn:aws:sns:ap-southeast-1:000:order-created" {
+		t.Errorf("expected published to sns topic, got %v", topic.published)
+	}
+
+	if err = p.PublishMessage(ctx, types.PublisherArgument{Message: []byte(`{}`)}); !errors.Is(err, ErrNoDestination) {
+		t.Errorf("expected no destination, got %v", err)
+	}
+}
+
+func TestShutdownStopsPolling(t *testing.T) {
+	w := newWorker(newBroker(Config{VisibilityTimeout: time.Second}, &fakeSQS{}, &fakeSNS{}))
+	w.handlers = []types.BrokerHandler{{Queue: "orders", HandlerFunc: func(ec *types.EventContext) error { return nil }}}
+
+	done := make(chan struct{})
+	go func() {
+		w.Serve()
+		close(done)
+	}()
+
+	w.Shutdown(context.Background())
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected Serve returns after Shutdown")
+	}
+}
+
+func TestUnorderedWorkerIds(t *testing.T) {
+	const workers = 3
+
+	client := &fakeSQS{}
+	for i := 0; i < workers; i++ {
+		client.received = append(client.received, sqstypes.Message{ReceiptHandle: aws.String("receipt"), Body: aws.String(`{}`)})
+	}
+
+	var (
+		mu      sync.Mutex
+		ids     = make(map[string]bool)
+		running sync.WaitGroup
+	)
+	running.Add(workers)
+	w := newWorker(newBroker(Config{VisibilityTimeout: time.Second}, client, &fakeSNS{}), SetMaxGoroutines(workers))
+	w.handlers = []types.BrokerHandler{{Queue: "orders", HandlerFunc: func(ec *types.EventContext) error {
+		mu.Lock()
+		ids[logger.WorkerID(ec.Context())] = true
+		mu.Unlock()
+
+		// hold every slot until all handlers run concurrently
+		running.Done()
+		running.Wait()
+		return nil
+	}}}
+
+	go w.Serve()
+	running.Wait()
+	w.Shutdown(context.Background())
+
+	for i := 0; i < workers; i++ {
+		if id := fmt.Sprintf("orders#%d", i); !ids[id] {
+			t.Errorf("expected worker %s, got %v", id, ids)
+		}
+	}
+}
+
+func TestPublishMessageCompression(t *testing.T) {
+	limits := types.PayloadLimits{CompressThreshold: 64, Encoding: types.EncodingZstd}
+	client := &fakeSQS{}
+	broker := newBroker(Config{VisibilityTimeout: time.Second, PayloadLimits: limits}, client, &fakeSNS{})
+	payload := strings.Repeat(`{"id":1},`, 50)
+	before := types.GetPayloadStats()
+
+	if err := broker.GetPublisher().PublishMessage(context.Background(), types.PublisherArgument{Queue: "orders", Message: []byte(payload)}); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := client.sent[0]
+	if aws.ToString(sent.MessageAttributes[types.HeaderContentEncoding].StringValue) != types.EncodingZstd || len(aws.ToString(sent.MessageBody)) >= len(payload) {
+		t.Fatalf("expected compressed message with content encoding, got %+v", sent)
+	}
+
+	var got string
+	handler := types.BrokerHandler{Queue: "orders", HandlerFunc: func(ec *types.EventContext) error {
+		got = string(ec.Message())
+		return nil
+	}}
+	newWorker(broker).processMessage("https://sqs.local/000/orders", handler, sqstypes.Message{
+		ReceiptHandle:     aws.String("receipt-1"),
+		Body:              sent.MessageBody,
+		MessageAttributes: sent.MessageAttributes,
+	}, "worker-1")
+
+	if got != payload {
+		t.Errorf("expected handler get decompressed payload, got %q", got)
+	}
+
+	if after := types.GetPayloadStats(); after.Published-before.Published != 1 || after.DecodedMessages-before.DecodedMessages != 1 {
+		t.Errorf("expected message counted once on publish and consume, got %+v", after)
+	}
+
+	// the limit applies to the base64 encoded body
+	limits.MaxMessageBytes = len(aws.ToString(sent.MessageBody)) - 1
+	broker = newBroker(Config{VisibilityTimeout: time.Second, PayloadLimits: limits}, client, &fakeSNS{})
+	var tl *types.ErrMessageTooLarge
+	if err := broker.GetPublisher().PublishMessage(context.Background(), types.PublisherArgument{Queue: "orders", Message: []byte(payload)}); !errors.As(err, &tl) {
+		t.Errorf("expected encoded body over the limit rejected, got %v", err)
+	}
+}
+
+func TestMessageLog(t *testing.T) {
+	client := &fakeSQS{}
+	w := newWorker(newBroker(Config{VisibilityTimeout: time.Minute}, client, &fakeSNS{}))
+
+	var flushed []*logger.DataLogger
+	w.finalize = func(ctx context.Context, ol *logger.DataLogger) {
+		ol.Collect(ctx, nil)
+		flushed = append(flushed, ol)
+	}
+
+	handler := types.BrokerHandler{Queue: "orders", HandlerFunc: func(ec *types.EventContext) error {
+		logger.Log.Print(ec.Context(), "request ", logger.GetRequestId(ec.Context()))
+		if ec.Header()["fail"] != "" {
+			return errors.New("boom")
+		}
+		return nil
+	}}
+
+	for _, fail := range []string{"", "yes"} {
+		w.processMessage("https://sqs.local/000/orders", handler, sqstypes.Message{
+			MessageId:     aws.String("msg-" + fail),
+			ReceiptHandle: aws.String("receipt"),
+			Body:          aws.String("{}"),
+			MessageAttributes: map[string]sqstypes.MessageAttributeValue{
+				types.HeaderRequestId: {StringValue: aws.String("req-" + fail)},
+				"fail":                {StringValue: aws.String(fail)},
+			},
+		}, "orders")
+	}
+
+	if len(flushed) != 2 {
+		t.Fatalf("expected one entry per message, got %d", len(flushed))
+	}
+
+	for i, want := range []struct{ id, outcome string }{{"", logger.OutcomeAck}, {"yes", logger.OutcomeNack}} {
+		ol := flushed[i]
+		if ol.RequestId != "req-"+want.id || ol.Message == nil || ol.Message.Queue != "orders" || ol.Message.MessageId != "msg-"+want.id || ol.Message.Outcome != want.outcome {
+			t.Errorf("message %d: unexpected entry %s %+v", i, ol.RequestId, ol.Message)
+		}
+
+		if len(ol.LogMessages) != 1 || ol.LogMessages[0].Message != "request req-"+want.id {
+			t.Errorf("message %d: expected handler messages with request id of header, got %+v", i, ol.LogMessages)
+		}
+	}
+}
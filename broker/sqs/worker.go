@@ -0,0 +1,462 @@
+package sqs
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/TixiaOTA/gokit/factory"
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/TixiaOTA/gokit/tracer"
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/TixiaOTA/gokit/utils/lanes"
+	"github.com/TixiaOTA/gokit/utils/timezone"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awssqs "github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/google/uuid"
+)
+
+// sqsWorker long polling consumer of SQS queues, failed messages are left to reappear after visibility
+// timeout and moved to dead letter queue by the redrive policy of the queue
+type sqsWorker struct {
+	ctx        context.Context
+	cancelFunc func()
+	broker     *Broker
+	opt        workerOption
+	tz         *time.Location
+	handlers   []types.BrokerHandler
+	lanes      map[string]*lanes.Dispatcher
+	semaphore  chan int
+	wg         sync.WaitGroup
+
+	// finalize write the data logger of a message, default to DataLogger.Finalize
+	finalize func(ctx context.Context, ol *logger.DataLogger)
+
+	// set once every queue is polled, see Ready
+	ready factory.ReadySignal
+}
+
+// NewWorker create SQS consumer of handlers registered for types.SQS, the broker of types.SQS must be created by New
+func NewWorker(service factory.ServiceFactory, opts ...WorkerOptionFunc) factory.ApplicationFactory {
+	broker, ok := service.GetBroker(types.SQS).(*Broker)
+	if !ok {
+		log.Fatalf("missing dependencies sqs")
+	}
+
+	worker := newWorker(broker, opts...)
+	if worker.opt.serviceName == "" {
+		worker.opt.serviceName = service.Name()
+	}
+
+	if h := service.BrokerHandler(types.SQS); h != nil {
+		var hg types.BrokerHandlerGroup
+		h.Register(&hg)
+
+		for _, handler := range hg.Handlers {
+			logger.Purple(fmt.Sprintf(`[SQS-CONSUMER] (queue): %-15s`, `"`+handler.Queue+`"`))
+			worker.addHandler(handler)
+		}
+	}
+
+	logger.PurpleBold(fmt.Sprintf("⇨ SQS consumer running with %d queue", len(worker.handlers)))
+	return worker
+}
+
+func newWorker(broker *Broker, opts ...WorkerOptionFunc) *sqsWorker {
+	worker := &sqsWorker{
+		broker: broker,
+		opt:    getDefaultWorkerOption(),
+		tz:     timezone.JakartaTz(),
+	}
+	for _, opt := range opts {
+		opt(&worker.opt)
+	}
+
+	worker.ctx, worker.cancelFunc = context.WithCancel(context.Background())
+	worker.semaphore = newSlots(worker.opt.maxGoroutines)
+	worker.lanes = make(map[string]*lanes.Dispatcher)
+
+	return worker
+}
+
+// addHandler register handler, ordered handler gets its own lanes
+func (w *sqsWorker) addHandler(handler types.BrokerHandler) {
+	w.handlers = append(w.handlers, handler)
+	if handler.KeyFunc == nil {
+		return
+	}
+
+	n := handler.Lanes
+	if n < 1 {
+		n = w.opt.maxGoroutines
+	}
+	w.lanes[handler.Queue] = lanes.New(n, handler.LaneSize)
+}
+
+func (w *sqsWorker) Name() string {
+	return types.SQS.String()
+}
+
+func (w *sqsWorker) Serve() {
+	var pollers sync.WaitGroup
+	for _, handler := range w.handlers {
+		url, err := w.broker.queueURL(w.ctx, handler.Queue)
+		if err != nil {
+			panic(err)
+		}
+
+		pollers.Add(1)
+		factory.GoSafe("sqs-consumer", func() {
+			defer pollers.Done()
+			w.poll(url, handler)
+		})
+	}
+	w.ready.Set()
+
+	pollers.Wait()
+}
+
+// Ready wait until every queue is polled, see factory.Readier
+func (w *sqsWorker) Ready(ctx context.Context) error {
+	return w.ready.Wait(ctx)
+}
+
+func (w *sqsWorker) Shutdown(_ context.Context) {
+	defer logger.RedBold("Stopping SQS Consumer")
+
+	// stop polling, then wait running handlers
+	w.cancelFunc()
+	running := cap(w.semaphore) - len(w.semaphore)
+	for _, l := range w.lanes {
+		running += l.Pending()
+	}
+	if running > 0 {
+		fmt.Printf("\x1b[34;1mSQS Consumer:\x1b[0m waiting %d job until done...\x1b[0m\n", running)
+	}
+	w.wg.Wait()
+
+	// drain ordered lanes, queued messages are handled in order
+	for _, l := range w.lanes {
+		l.Close()
+	}
+}
+
+// Validate check handlers without touching the broker
+func (w *sqsWorker) Validate(_ context.Context) error {
+	var (
+		errs   []error
+		queues = make(map[string]bool)
+	)
+
+	for _, h := range w.handlers {
+		switch {
+		case h.Queue == "":
+			errs = append(errs, errors.New("sqs_consumer: handler has no queue"))
+		case queues[h.Queue]:
+			errs = append(errs, fmt.Errorf("sqs_consumer: queue %q registered more than once", h.Queue))
+		case h.HandlerFunc == nil:
+			errs = append(errs, fmt.Errorf("sqs_consumer: queue %q has no handler func", h.Queue))
+		}
+
+		queues[h.Queue] = true
+	}
+
+	return errors.Join(errs...)
+}
+
+// poll receive messages of queue until worker is shutdown
+func (w *sqsWorker) poll(url string, handler types.BrokerHandler) {
+	for w.ctx.Err() == nil {
+		out, err := w.broker.sqs.ReceiveMessage(w.ctx, &awssqs.ReceiveMessageInput{
+			QueueUrl:                    aws.String(url),
+			MaxNumberOfMessages:         w.broker.cfg.MaxMessages,
+			WaitTimeSeconds:             int32(w.broker.cfg.WaitTime.Seconds()),
+			VisibilityTimeout:           visibilitySeconds(w.broker.cfg.VisibilityTimeout),
+			MessageAttributeNames:       []string{"All"},
+			MessageSystemAttributeNames: []sqstypes.MessageSystemAttributeName{sqstypes.MessageSystemAttributeNameAll},
+		})
+		if err != nil {
+			if w.ctx.Err() == nil {
+				log.Printf("sqs_consumer > receive from %s: %s", url, err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+
+		for _, message := range out.Messages {
+			if l := w.lanes[handler.Queue]; l != nil {
+				// blocks while the lane is full, stop receiving until the lane has room
+				// decoded once, the key and the handler see the same payload
+				body, decodeErr := w.messageBody(message, messageHeader(message))
+				key := handler.KeyFunc(body, messageHeader(message))
+				workerId := fmt.Sprintf("%s#%d", handler.Queue, l.Lane(key))
+				l.Dispatch(key, func() { w.handleMessage(url, handler, message, body, decodeErr, workerId) })
+				continue
+			}
+
+			slot := <-w.semaphore
+			w.wg.Add(1)
+			go func(message sqstypes.Message) {
+				defer func() {
+					w.semaphore <- slot
+					w.wg.Done()
+				}()
+
+				w.processMessage(url, handler, message, fmt.Sprintf("%s#%d", handler.Queue, slot))
+			}(message)
+		}
+	}
+}
+
+// processMessage decode payload of message and handle it, log messages of handler are stamped with workerId
+func (w *sqsWorker) processMessage(url string, handler types.BrokerHandler, message sqstypes.Message, workerId string) {
+	body, err := w.messageBody(message, messageHeader(message))
+	w.handleMessage(url, handler, message, body, err, workerId)
+}
+
+// handleMessage handle message with its payload decoded by messageBody, a decode error settles the message
+// as failed without calling the handler
+func (w *sqsWorker) handleMessage(url string, handler types.BrokerHandler, message sqstypes.Message, body []byte, decodeErr error, workerId string) {
+	start := logger.Now().In(w.tz)
+
+	// handler keeps running on shutdown, only polling is stopped
+	ctx := context.WithoutCancel(w.ctx)
+
+	// extend visibility while handler runs
+	stopExtend := w.extendVisibility(ctx, url, message.ReceiptHandle)
+
+	header := messageHeader(message)
+	headers := make(map[string]interface{}, len(header))
+	for key, val := range header {
+		headers[key] = val
+	}
+
+	var err error
+	trace, ctx := tracer.StartTraceWithContext(ctx, "SQSConsumer")
+
+	requestId := header[types.HeaderRequestId]
+	if requestId == "" {
+		requestId = uuid.NewString()
+	}
+
+	ol := &logger.DataLogger{
+		TimeStart:     start,
+		RequestId:     requestId,
+		Type:          logger.ServiceType(types.SQS.String()),
+		Service:       w.opt.serviceName,
+		Endpoint:      fmt.Sprintf("queue: %s", handler.Queue),
+		RequestBody:   aws.ToString(message.Body),
+		RequestMethod: "CONSUME",
+		RequestHeader: fmt.Sprintf("Queue: %s | Message Id: %s | Header: %v", handler.Queue, aws.ToString(message.MessageId), header),
+		Message:       &logger.MessageLog{Queue: handler.Queue, MessageId: aws.ToString(message.MessageId), Outcome: logger.OutcomeNack},
+	}
+
+	defer func() {
+		if re := recover(); re != nil {
+			err = fmt.Errorf("%s", re)
+		}
+		stopExtend()
+
+		sc := http.StatusOK
+		if err != nil {
+			trace.SetError(err)
+
+			// left on queue, reappears after visibility timeout until moved to dead letter queue
+			sc = http.StatusInternalServerError
+			ol.ErrorMessage = fmt.Sprintf("%s", err)
+		} else {
+			ol.Response = "success"
+			if de := w.deleteMessage(url, message.ReceiptHandle); de != nil {
+				ol.ErrorMessage = de.Error()
+			} else {
+				ol.Message.Outcome = logger.OutcomeAck
+			}
+		}
+
+		trace.SetTag("trace_id", tracer.GetTraceID(ctx))
+		ol.StatusCode = sc
+		ol.ExecTime = logger.Now().Sub(start).Seconds()
+		logger.Response(ctx, sc, ol.Response, err)
+		trace.Finish()
+		w.flush(ctx, ol)
+	}()
+
+	// logger store of the message, flushed as one entry once the message is settled
+	if !w.opt.disableMessageLog {
+		ctx = logger.NewContext(ctx, requestId)
+	}
+	ctx = logger.WithWorkerID(ctx, workerId)
+	logger.RestoreBaggage(ctx, header)
+
+	attempt, _ := strconv.Atoi(message.Attributes[string(sqstypes.MessageSystemAttributeNameApproximateReceiveCount)])
+	var enqueuedAt time.Time
+	if ms, e := strconv.ParseInt(message.Attributes[string(sqstypes.MessageSystemAttributeNameSentTimestamp)], 10, 64); e == nil {
+		enqueuedAt = time.UnixMilli(ms)
+	}
+	ctx = types.ContextWithDelivery(ctx, types.Delivery{
+		Queue:       handler.Queue,
+		Redelivered: attempt > 1,
+		Attempt:     attempt,
+		Headers:     headers,
+		EnqueuedAt:  enqueuedAt,
+	})
+
+	trace.SetTag("queue", handler.Queue)
+	trace.SetTag("body", aws.ToString(message.Body))
+	trace.SetTag("header", header)
+
+	var ec = types.EventContext{}
+	ec.SetContext(ctx)
+	ec.SetWorkerType(types.SQS.String())
+	ec.SetHandlerRoute(handler.Queue)
+	ec.SetKey(message.Attributes[string(sqstypes.MessageSystemAttributeNameMessageGroupId)])
+	ec.SetHeader(header)
+
+	if err = decodeErr; err != nil {
+		ec.SetError(err)
+		return
+	}
+	_, _ = ec.Write(body)
+
+	if err = handler.Call(&ec); err != nil {
+		if errors.Is(err, types.ErrHandlerTimeout) {
+			logger.Log.Errorf(ctx, "slow handler on queue %s, message id %s: %s", handler.Queue, aws.ToString(message.MessageId), err)
+		}
+		ec.SetError(err)
+		return
+	}
+
+	// publish buffered messages before deleting, the message reappears on failure
+	if err = w.flushPublishBuffer(ctx, &ec, aws.ToString(message.MessageId)); err != nil {
+		ec.SetError(err)
+	}
+}
+
+// flush write the data logger of a message, see DisableMessageLog
+func (w *sqsWorker) flush(ctx context.Context, ol *logger.DataLogger) {
+	if w.finalize != nil {
+		w.finalize(ctx, ol)
+		return
+	}
+
+	ol.Finalize(ctx)
+}
+
+// extendVisibility extend visibility of message every half of visibility timeout until stopped,
+// stop returns once no extension is in flight
+func (w *sqsWorker) extendVisibility(ctx context.Context, url string, receipt *string) (stop func()) {
+	visibility := w.broker.cfg.VisibilityTimeout
+	done, exited := make(chan struct{}), make(chan struct{})
+
+	go func() {
+		defer close(exited)
+
+		ticker := time.NewTicker(visibility / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				_, err := w.broker.sqs.ChangeMessageVisibility(ctx, &awssqs.ChangeMessageVisibilityInput{
+					QueueUrl:          aws.String(url),
+					ReceiptHandle:     receipt,
+					VisibilityTimeout: visibilitySeconds(visibility),
+				})
+				if err != nil {
+					log.Printf("sqs_consumer > extend visibility: %s", err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-exited
+	}
+}
+
+func (w *sqsWorker) deleteMessage(url string, receipt *string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := w.broker.sqs.DeleteMessage(ctx, &awssqs.DeleteMessageInput{QueueUrl: aws.String(url), ReceiptHandle: receipt})
+	if err != nil {
+		return fmt.Errorf("sqs_consumer: delete message: %w", err)
+	}
+
+	return nil
+}
+
+// flushPublishBuffer publish messages buffered by handler in order, stop on the first failure.
+// messages published before the failure are skipped once the message of id reappears
+func (w *sqsWorker) flushPublishBuffer(ctx context.Context, ec *types.EventContext, id string) error {
+	buf := ec.PublishBuffer()
+	if buf.Len() < 1 {
+		return nil
+	}
+	defer buf.Reset()
+
+	// validate every payload first so an invalid one never leaves the others published
+	for _, args := range buf.Messages() {
+		if err := args.Validate(); err != nil {
+			return err
+		}
+	}
+
+	messages := buf.Messages()
+	if i, err := w.broker.tail.Publish(ctx, id, messages); err != nil {
+		return fmt.Errorf("sqs_consumer: publish buffered message %d/%d: %w", i+1, buf.Len(), err)
+	}
+
+	return nil
+}
+
+// messageBody payload of message, compressed payload is base64 decoded and decompressed
+func (w *sqsWorker) messageBody(message sqstypes.Message, header map[string]string) ([]byte, error) {
+	body := []byte(aws.ToString(message.Body))
+	if header[types.HeaderContentEncoding] == "" {
+		return types.DecodePayload(body, header, w.broker.cfg.PayloadLimits)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("%w: decode base64 payload: %v", types.ErrNonRetryable, err)
+	}
+
+	return types.DecodePayload(decoded, header, w.broker.cfg.PayloadLimits)
+}
+
+// newSlots semaphore of n free worker slots, the slot index taken by a handler stamps its worker id
+func newSlots(n int) chan int {
+	slots := make(chan int, n)
+	for i := 0; i < n; i++ {
+		slots <- i
+	}
+
+	return slots
+}
+
+func messageHeader(message sqstypes.Message) map[string]string {
+	header := make(map[string]string, len(message.MessageAttributes))
+	for key, val := range message.MessageAttributes {
+		header[key] = aws.ToString(val.StringValue)
+	}
+
+	return header
+}
+
+// visibilitySeconds visibility timeout rounded up to seconds
+func visibilitySeconds(d time.Duration) int32 {
+	return int32(math.Ceil(d.Seconds()))
+}
@@ -1,13 +1,95 @@
 package config
 
 import (
+	"errors"
 	"log"
+	"strings"
 
+	"github.com/TixiaOTA/gokit/utils/env"
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 )
 
+var (
+	// ErrNotFound config file does not exist
+	ErrNotFound = errors.New("config: file not found")
+	// ErrParse config file exists but can not be parsed, e.g. malformed .env line
+	ErrParse = errors.New("config: file can not be parsed")
+)
+
+// Option setter of load options
+type Option func(*options)
+
+// options of LoadE
+type options struct {
+	required bool
+	fileName string
+
+	// directories searched after the config path, see SearchPaths and MergePaths
+	searchPaths []string
+	merge       bool
+
+	// export loaded keys into process env, see ExportToOSEnv
+	export       bool
+	exportOnly   []string
+	exportExcept []string
+}
+
+// Required missing or unparseable config file is a hard error
+func Required() Option {
+	return func(o *options) {
+		o.required = true
+	}
+}
+
+// FileName name of config file inside the config path, default is .env
+func FileName(name string) Option {
+	return func(o *options) {
+		o.fileName = name
+	}
+}
+
+// SearchPaths directories searched in order after the config path when it has no config file
+func SearchPaths(paths ...string) Option {
+	return func(o *options) {
+		o.searchPaths = append(o.searchPaths, paths...)
+	}
+}
+
+// MergePaths load the config file of every searched directory instead of the first one,
+// keys of a directory earlier in the search order win
+func MergePaths() Option {
+	return func(o *options) {
+		o.merge = true
+	}
+}
+
+// ExportToOSEnv export loaded keys into process env for libraries reading os.Getenv directly,
+// keys already set in the real environment are never overridden
+func ExportToOSEnv() Option {
+	return func(o *options) {
+		o.export = true
+	}
+}
+
+// ExportOnly export only environment variable names matching patterns, e.g. "AWS_*", implies ExportToOSEnv
+func ExportOnly(patterns ...string) Option {
+	return func(o *options) {
+		o.export = true
+		o.exportOnly = append(o.exportOnly, patterns...)
+	}
+}
+
+// ExportExcept never export environment variable names matching patterns, e.g. "*_PASSWORD", implies ExportToOSEnv
+func ExportExcept(patterns ...string) Option {
+	return func(o *options) {
+		o.export = true
+		o.exportExcept = append(o.exportExcept, patterns...)
+	}
+}
+
 // Load any configuration like open connection database, open connection redis, monitoring, e.t.c
-func Load(serviceName string, configPath string) {
+func Load(serviceName string, configPaths ...string) {
 
 	// serviceName = strings.ToLower(serviceName)
 	// serviceName = strings.ReplaceAll(serviceName, "-", "_")
@@ -15,19 +97,89 @@ func Load(serviceName string, configPath string) {
 
 	// load all configuration needed
 	// init viper first time
-	Config(configPath)
+	Config(configPaths...)
 }
 
-func Config(configPath string) {
+// LoadE load config file from path, missing or unparseable file is only logged unless Required is set,
+// returned error wraps ErrNotFound or ErrParse.
+// directories of CONFIG_PATHS are searched first, then path, SearchPaths and the working directory, the first
+// config file found wins unless MergePaths is set. path and CONFIG_PATHS may list several directories separated
+// by the OS list separator, e.g. "./config:/etc/app", a relative directory resolves against the working directory
+// then the directory of the executable
+func LoadE(serviceName string, path string, opts ...Option) error {
+	o := options{fileName: ".env"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	viper.AutomaticEnv()
-	viper.AddConfigPath(configPath)
-	viper.SetConfigFile(".env")
+	viper.SetEnvKeyReplacer(env.KeyReplacer())
 
-	err := viper.ReadInConfig()
+	s := search{dirs: searchPaths(path, o.searchPaths), name: o.fileName, merge: o.merge}
+	files, err := readConfigFiles(s.dirs, s.name, s.merge)
+	setSourcePaths(files, s)
 	if err != nil {
+		if o.required {
+			return err
+		}
+
 		log.Printf("Warning: Config file could not be loaded: %v", err)
 		log.Print("Using environment variables only")
 	} else {
-		log.Print("Config file loaded successfully")
+		log.Printf("Config file loaded successfully from %s", strings.Join(files, ", "))
+	}
+
+	// expand ${VAR} references before any value is read
+	if err := interpolate(); err != nil {
+		return err
+	}
+
+	// nested file keys resolve the same environment variable as their dotted lookup
+	env.BindAll()
+
+	if o.export {
+		log.Printf("Config exported %d keys into environment", exportToOSEnv(o.exportOnly, o.exportExcept))
 	}
+
+	return nil
+}
+
+// Config load the config file of the first of configPaths holding one, see LoadE
+func Config(configPaths ...string) {
+	var path string
+	if len(configPaths) > 0 {
+		path = configPaths[0]
+	}
+
+	var opts []Option
+	if len(configPaths) > 1 {
+		opts = append(opts, SearchPaths(configPaths[1:]...))
+	}
+
+	if err := LoadE("", path, opts...); err != nil {
+		log.Fatalf("Config file could not be interpolated: %v", err)
+	}
+}
+
+// Watch reload config file when it changes, ${VAR} references are expanded again and nested keys re-bound
+// before onChange is called. getters of env read the reloaded values right away, e.g. featureflag.
+// with MergePaths the winning file is watched and every file is merged again on its change
+func Watch(onChange ...func()) {
+	viper.OnConfigChange(func(fsnotify.Event) {
+		if err := reloadMerged(); err != nil {
+			log.Printf("Warning: reloaded config files could not be merged: %v", err)
+			return
+		}
+
+		if err := interpolate(); err != nil {
+			log.Printf("Warning: reloaded config file could not be interpolated: %v", err)
+			return
+		}
+
+		env.BindAll()
+		for _, fn := range onChange {
+			fn()
+		}
+	})
+	viper.WatchConfig()
 }
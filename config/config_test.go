@@ -0,0 +1,198 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/utils/env"
+	"github.com/spf13/viper"
+)
+
+func TestLoadE(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := LoadE("svc", dir); err != nil {
+		t.Errorf("expected missing file allowed on lenient mode, got %v", err)
+	}
+
+	if err := LoadE("svc", dir, Required()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected not found error on required mode, got %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "app.env"), []byte("GOOD=1\nthis line is malformed\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadE("svc", dir, Required(), FileName("app.env")); !errors.Is(err, ErrParse) {
+		t.Errorf("expected parse error on malformed file, got %v", err)
+	}
+}
+
+func TestKeyResolution(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("DB_FILE=file\nDB_BOTH=file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DB_ENV", "env")
+	t.Setenv("DB_BOTH", "env")
+
+	if err := LoadE("svc", dir, Required()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		want   string
+		source env.ValueSource
+	}{
+		{"db.file", "file", env.SourceFile},
+		{"db.env", "env", env.SourceEnv},
+		{"db.both", "env", env.SourceEnv},
+		{"db.none", "", env.SourceNone},
+	}
+
+	for _, tt := range tests {
+		for _, key := range []string{tt.name, env.EnvName(tt.name)} {
+			if got := env.GetString(key); got != tt.want {
+				t.Errorf("GetString(%q) expected %q, got %q", key, tt.want, got)
+			}
+
+			if got := env.Source(key); got != tt.source {
+				t.Errorf("Source(%q) expected %s, got %s", key, tt.source, got)
+			}
+		}
+	}
+}
+
+func TestKeyResolutionNestedFile(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.yaml"), []byte("database:\n  host: file\n  port: 5432\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DATABASE_HOST", "env")
+
+	if err := LoadE("svc", dir, Required(), FileName("app.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"database.host", "DATABASE_HOST"} {
+		if got := env.GetString(key); got != "env" {
+			t.Errorf("GetString(%q) expected env value, got %q", key, got)
+		}
+	}
+
+	for _, key := range []string{"database.port", "DATABASE_PORT"} {
+		if got := env.GetInteger(key); got != 5432 || env.Source(key) != env.SourceFile {
+			t.Errorf("GetInteger(%q) expected file value, got %d from %s", key, got, env.Source(key))
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, ".env")
+	if err := os.WriteFile(file, []byte("FLAG_WATCHED=false\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadE("svc", dir, Required()); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := make(chan struct{}, 1)
+	Watch(func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+
+	if err := os.WriteFile(file, []byte("FLAG_WATCHED=true\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected config change notified")
+	}
+
+	if !env.GetBool("FLAG_WATCHED") {
+		t.Error("expected reloaded value")
+	}
+}
+
+func TestExportToOSEnv(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	dir := t.TempDir()
+	content := "EXPORT_REGION=ap-southeast-1\nEXPORT_EXISTING=file\nEXPORT_DB_PASSWORD=secret\nOTHER_KEY=1\n"
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("EXPORT_EXISTING", "real")
+	for _, key := range []string{"EXPORT_REGION", "EXPORT_DB_PASSWORD", "OTHER_KEY"} {
+		key := key
+		t.Cleanup(func() { _ = os.Unsetenv(key) })
+	}
+
+	if err := LoadE("svc", dir, ExportOnly("EXPORT_*"), ExportExcept("*_PASSWORD")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := os.Getenv("EXPORT_REGION"); got != "ap-southeast-1" {
+		t.Errorf("expected loaded key exported, got %q", got)
+	}
+
+	if got := os.Getenv("EXPORT_EXISTING"); got != "real" {
+		t.Errorf("expected real environment never overridden, got %q", got)
+	}
+
+	if _, ok := os.LookupEnv("EXPORT_DB_PASSWORD"); ok {
+		t.Error("expected denylisted key not exported")
+	}
+
+	if _, ok := os.LookupEnv("OTHER_KEY"); ok {
+		t.Error("expected key outside allowlist not exported")
+	}
+}
+
+func TestLoadEWorkingDirectory(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err = os.WriteFile(filepath.Join(dir, ".env"), []byte("LEGACY_KEY=cwd\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	// config path without .env keeps reading the one of the working directory
+	if err = LoadE("svc", t.TempDir(), Required()); err != nil {
+		t.Fatal(err)
+	}
+	if got := viper.GetString("LEGACY_KEY"); got != "cwd" {
+		t.Errorf("expected config of working directory, got %q", got)
+	}
+}
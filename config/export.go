@@ -0,0 +1,44 @@
+package config
+
+import (
+	"os"
+	"path"
+	"strings"
+
+	"github.com/TixiaOTA/gokit/utils/env"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// exportToOSEnv set loaded keys missing from the real environment, filtered by only and except patterns,
+// returns number of exported keys
+func exportToOSEnv(only, except []string) int {
+	exported := 0
+	for _, key := range viper.AllKeys() {
+		name := env.EnvName(key)
+		if _, ok := os.LookupEnv(name); ok {
+			continue
+		}
+
+		if (len(only) > 0 && !matchAny(name, only)) || matchAny(name, except) {
+			continue
+		}
+
+		if err := os.Setenv(name, cast.ToString(viper.Get(key))); err == nil {
+			exported++
+		}
+	}
+
+	return exported
+}
+
+// matchAny report whether name matches one of patterns, case-insensitive
+func matchAny(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(strings.ToUpper(p), name); ok {
+			return true
+		}
+	}
+
+	return false
+}
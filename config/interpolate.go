@@ -0,0 +1,189 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// interpolator expand ${VAR} and ${VAR:-default} on config values,
+// $${literal} is escaped into ${literal}
+type interpolator struct {
+	// env lookup real environment, env values are taken literally
+	env func(name string) (string, bool)
+	// file lookup raw value of config file
+	file func(name string) (string, bool)
+
+	resolved  map[string]string
+	resolving map[string]bool
+}
+
+func newInterpolator(env, file func(name string) (string, bool)) *interpolator {
+	return &interpolator{
+		env:       env,
+		file:      file,
+		resolved:  make(map[string]string),
+		resolving: make(map[string]bool),
+	}
+}
+
+// interpolate expand every string value loaded by viper, values from real environment win
+// over the config file and are never expanded
+func interpolate() error {
+	in := newInterpolator(os.LookupEnv, func(name string) (string, bool) {
+		key := strings.ToLower(name)
+		if !viper.InConfig(key) {
+			return "", false
+		}
+
+		return viper.GetString(key), true
+	})
+
+	for _, key := range viper.AllKeys() {
+		if _, ok := os.LookupEnv(strings.ToUpper(key)); ok {
+			continue
+		}
+
+		raw, ok := viper.Get(key).(string)
+		if !ok || !strings.Contains(raw, "$") {
+			continue
+		}
+
+		val, err := in.resolve(strings.ToUpper(key))
+		if err != nil {
+			return err
+		}
+
+		viper.Set(key, val)
+	}
+
+	return nil
+}
+
+// resolve get expanded value of variable name
+func (in *interpolator) resolve(name string) (string, error) {
+	if val, ok := in.env(name); ok {
+		return val, nil
+	}
+
+	key := strings.ToLower(name)
+	if val, ok := in.resolved[key]; ok {
+		return val, nil
+	}
+
+	raw, ok := in.file(name)
+	if !ok {
+		return "", nil
+	}
+
+	if in.resolving[key] {
+		return "", fmt.Errorf("config: circular reference on ${%s}", name)
+	}
+
+	in.resolving[key] = true
+	val, err := in.expand(raw)
+	delete(in.resolving, key)
+	if err != nil {
+		return "", err
+	}
+
+	in.resolved[key] = val
+	return val, nil
+}
+
+// isSet report whether variable exist on environment or config file
+func (in *interpolator) isSet(name string) bool {
+	if _, ok := in.env(name); ok {
+		return true
+	}
+
+	_, ok := in.file(name)
+	return ok
+}
+
+// expand replace every reference on value
+func (in *interpolator) expand(value string) (string, error) {
+	var sb strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		if value[i] != '$' {
+			sb.WriteByte(value[i])
+			continue
+		}
+
+		// escaped, $${literal}
+		if strings.HasPrefix(value[i:], "$${") {
+			end := closingBrace(value, i+2)
+			if end < 0 {
+				return "", fmt.Errorf("config: unterminated reference on %q", value)
+			}
+
+			sb.WriteString(value[i+1 : end+1])
+			i = end
+			continue
+		}
+
+		if !strings.HasPrefix(value[i:], "${") {
+			sb.WriteByte(value[i])
+			continue
+		}
+
+		end := closingBrace(value, i+1)
+		if end < 0 {
+			return "", fmt.Errorf("config: unterminated reference on %q", value)
+		}
+
+		val, err := in.reference(value[i+2 : end])
+		if err != nil {
+			return "", err
+		}
+
+		sb.WriteString(val)
+		i = end
+	}
+
+	return sb.String(), nil
+}
+
+// reference resolve content of ${...}, either NAME or NAME:-default
+func (in *interpolator) reference(ref string) (string, error) {
+	name, def, hasDefault := strings.Cut(ref, ":-")
+	if name == "" {
+		return "", fmt.Errorf("config: empty reference ${%s}", ref)
+	}
+
+	if hasDefault && !in.isSet(name) {
+		return in.expand(def)
+	}
+
+	val, err := in.resolve(name)
+	if err != nil {
+		return "", err
+	}
+
+	if hasDefault && val == "" {
+		return in.expand(def)
+	}
+
+	return val, nil
+}
+
+// closingBrace index of brace closing the one at open, supports nested reference on default
+func closingBrace(value string, open int) int {
+	depth := 0
+	for i := open; i < len(value); i++ {
+		switch value[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
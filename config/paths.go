@@ -0,0 +1,161 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// sourceMu guards sourcePaths, the config files of the last load, and sourceSearch, the search finding them
+var (
+	sourceMu     sync.RWMutex
+	sourcePaths  []string
+	sourceSearch search
+)
+
+// search config files of name in dirs, see readConfigFiles
+type search struct {
+	dirs  []string
+	name  string
+	merge bool
+}
+
+// SourcePath config file of the last load, the winning one when files are merged, empty when none was loaded
+func SourcePath() string {
+	sourceMu.RLock()
+	defer sourceMu.RUnlock()
+
+	if len(sourcePaths) < 1 {
+		return ""
+	}
+
+	return sourcePaths[0]
+}
+
+// SourcePaths config files of the last load in search order, several when files are merged
+func SourcePaths() []string {
+	sourceMu.RLock()
+	defer sourceMu.RUnlock()
+
+	return append([]string(nil), sourcePaths...)
+}
+
+func setSourcePaths(files []string, s search) {
+	sourceMu.Lock()
+	sourcePaths = files
+	sourceSearch = s
+	sourceMu.Unlock()
+}
+
+// reloadMerged merge every config file of the last load again once viper re-read the winning one on change,
+// files of lower precedence are not watched
+func reloadMerged() error {
+	sourceMu.RLock()
+	s := sourceSearch
+	sourceMu.RUnlock()
+
+	if !s.merge {
+		return nil
+	}
+
+	files, err := readConfigFiles(s.dirs, s.name, true)
+	if err != nil {
+		return err
+	}
+	setSourcePaths(files, s)
+
+	return nil
+}
+
+// searchPaths config directories in search order, CONFIG_PATHS then path, extra and the working directory,
+// each entry may list several directories separated by the OS list separator
+func searchPaths(path string, extra []string) []string {
+	var dirs []string
+	for _, entry := range append([]string{os.Getenv("CONFIG_PATHS"), path}, extra...) {
+		for _, dir := range filepath.SplitList(entry) {
+			if dir = strings.TrimSpace(dir); dir != "" {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+
+	// the working directory is searched last, where Config always read the config file
+	dirs = append(dirs, ".")
+
+	return dirs
+}
+
+// candidates config files of name in dirs, a relative directory resolves against the working directory
+// then the directory of the executable
+func candidates(dirs []string, name string) []string {
+	var exeDir string
+	if exe, err := os.Executable(); err == nil {
+		exeDir = filepath.Dir(exe)
+	}
+
+	seen := make(map[string]bool)
+	var files []string
+	add := func(file string) {
+		if abs, err := filepath.Abs(file); err == nil {
+			file = abs
+		}
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+
+	for _, dir := range dirs {
+		add(filepath.Join(dir, name))
+		if !filepath.IsAbs(dir) && exeDir != "" {
+			add(filepath.Join(exeDir, dir, name))
+		}
+	}
+
+	return files
+}
+
+// readConfigFiles read the first existing config file into viper, or every one when merge is set with
+// files earlier in the search order winning. found files are returned in search order
+func readConfigFiles(dirs []string, name string, merge bool) ([]string, error) {
+	var found []string
+	for _, file := range candidates(dirs, name) {
+		if _, err := os.Stat(file); err != nil {
+			continue
+		}
+
+		found = append(found, file)
+		if !merge {
+			break
+		}
+	}
+
+	if len(found) < 1 {
+		return nil, fmt.Errorf("%w: %s in %s: %v", ErrNotFound, name, strings.Join(dirs, ", "), fs.ErrNotExist)
+	}
+
+	// lowest precedence first, the winning file stays the config file of viper, e.g. for Watch
+	for i := len(found) - 1; i >= 0; i-- {
+		viper.SetConfigFile(found[i])
+
+		read := viper.MergeInConfig
+		if i == len(found)-1 {
+			read = viper.ReadInConfig
+		}
+
+		if err := read(); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil, fmt.Errorf("%w: %s: %v", ErrNotFound, found[i], err)
+			}
+			return nil, fmt.Errorf("%w: %s: %v", ErrParse, found[i], err)
+		}
+	}
+
+	return found, nil
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func writeEnv(t *testing.T, dir, content string) string {
+	t.Helper()
+
+	file := filepath.Join(dir, ".env")
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+func TestSearchPaths(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	first, second, empty := t.TempDir(), t.TempDir(), t.TempDir()
+	firstFile := writeEnv(t, first, "PATHS_SHARED=first\n")
+	secondFile := writeEnv(t, second, "PATHS_SHARED=second\nPATHS_ONLY_SECOND=second\n")
+
+	tests := []struct {
+		name        string
+		configPaths string
+		path        string
+		opts        []Option
+		want        string
+	}{
+		{"first wins", "", first, []Option{SearchPaths(second)}, firstFile},
+		{"missing path falls through", "", empty, []Option{SearchPaths(second, first)}, secondFile},
+		{"list separated path", "", empty + string(os.PathListSeparator) + second, nil, secondFile},
+		{"CONFIG_PATHS searched first", second, first, nil, secondFile},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			t.Setenv("CONFIG_PATHS", tt.configPaths)
+
+			if err := LoadE("svc", tt.path, append(tt.opts, Required())...); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := SourcePath(); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	viper.Reset()
+	if err := LoadE("svc", empty, Required()); !errors.Is(err, ErrNotFound) || SourcePath() != "" {
+		t.Errorf("expected not found without source path, got %v %q", err, SourcePath())
+	}
+}
+
+func TestMergePaths(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	first, second := t.TempDir(), t.TempDir()
+	firstFile := writeEnv(t, first, "MERGE_SHARED=first\n")
+	secondFile := writeEnv(t, second, "MERGE_SHARED=second\nMERGE_ONLY_SECOND=second\n")
+
+	if err := LoadE("svc", first, Required(), SearchPaths(second), MergePaths()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := viper.GetString("MERGE_SHARED"); got != "first" {
+		t.Errorf("expected earlier path to win, got %s", got)
+	}
+
+	if got := viper.GetString("MERGE_ONLY_SECOND"); got != "second" {
+		t.Errorf("expected keys of later path merged, got %s", got)
+	}
+
+	if got := SourcePaths(); len(got) != 2 || got[0] != firstFile || got[1] != secondFile || SourcePath() != firstFile {
+		t.Errorf("expected both files in search order, got %v", got)
+	}
+}
+
+func TestCandidatesExecutableDir(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	files := candidates([]string{"conf"}, ".env")
+	want := filepath.Join(filepath.Dir(exe), "conf", ".env")
+	if len(files) != 2 || files[1] != want {
+		t.Errorf("expected relative path resolved against executable directory %s, got %v", want, files)
+	}
+}
+
+func TestWatchMergePaths(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	first, second := t.TempDir(), t.TempDir()
+	firstFile := writeEnv(t, first, "WATCH_SHARED=first\n")
+	writeEnv(t, second, "WATCH_SHARED=second\nWATCH_ONLY_SECOND=second\n")
+
+	if err := LoadE("svc", first, Required(), SearchPaths(second), MergePaths()); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := make(chan struct{}, 1)
+	Watch(func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+
+	if err := os.WriteFile(firstFile, []byte("WATCH_SHARED=edited\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected config change notified")
+	}
+
+	if got := viper.GetString("WATCH_SHARED"); got != "edited" {
+		t.Errorf("expected edited value of winning file, got %s", got)
+	}
+	if got := viper.GetString("WATCH_ONLY_SECOND"); got != "second" {
+		t.Errorf("expected keys of later path merged again, got %q", got)
+	}
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"sort"
+
+	"github.com/TixiaOTA/gokit/utils/env"
+	"github.com/spf13/viper"
+)
+
+// Usage classification of config keys by their environment variable name, see UsageReport
+type Usage struct {
+	// Read declared keys read through the env getters
+	Read []env.KeyUsage `json:"read"`
+	// Unread declared keys never read, candidates for pruning
+	Unread []string `json:"unread"`
+	// Undeclared keys read but not declared on config file or viper defaults, e.g. optional or process env keys
+	Undeclared []env.KeyUsage `json:"undeclared"`
+}
+
+// UsageReport compare keys declared on loaded config against keys read through the env getters so far,
+// keys read only later at runtime show up as unread until then
+func UsageReport() Usage {
+	reads := env.Usage()
+
+	report := Usage{Read: []env.KeyUsage{}, Unread: []string{}, Undeclared: []env.KeyUsage{}}
+	declared := make(map[string]bool)
+	for _, key := range viper.AllKeys() {
+		name := env.EnvName(key)
+		if declared[name] {
+			continue
+		}
+		declared[name] = true
+
+		if u, ok := reads[name]; ok {
+			report.Read = append(report.Read, u)
+		} else {
+			report.Unread = append(report.Unread, name)
+		}
+	}
+
+	for name, u := range reads {
+		if !declared[name] {
+			report.Undeclared = append(report.Undeclared, u)
+		}
+	}
+
+	sort.Slice(report.Read, func(i, j int) bool { return report.Read[i].Key < report.Read[j].Key })
+	sort.Strings(report.Unread)
+	sort.Slice(report.Undeclared, func(i, j int) bool { return report.Undeclared[i].Key < report.Undeclared[j].Key })
+
+	return report
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/TixiaOTA/gokit/utils/env"
+	"github.com/spf13/viper"
+)
+
+func TestUsageReport(t *testing.T) {
+	viper.Set("usage.read", "a")
+	viper.Set("usage_unread", "b")
+	defer viper.Set("usage.read", nil)
+	defer viper.Set("usage_unread", nil)
+
+	env.GetString("usage.read")
+	env.GetString("USAGE_READ")
+	env.GetInteger("USAGE_UNDECLARED", 1)
+
+	report := UsageReport()
+
+	var read, undeclared *env.KeyUsage
+	for i := range report.Read {
+		if report.Read[i].Key == "USAGE_READ" {
+			read = &report.Read[i]
+		}
+	}
+	for i := range report.Undeclared {
+		if report.Undeclared[i].Key == "USAGE_UNDECLARED" {
+			undeclared = &report.Undeclared[i]
+		}
+	}
+
+	if read == nil || read.Count != 2 || read.FirstRead.IsZero() {
+		t.Errorf("expected USAGE_READ read twice, got %+v", read)
+	}
+	if undeclared == nil || undeclared.Count != 1 {
+		t.Errorf("expected USAGE_UNDECLARED read but undeclared, got %+v", report.Undeclared)
+	}
+
+	found := false
+	for _, name := range report.Unread {
+		found = found || name == "USAGE_UNREAD"
+	}
+	if !found {
+		t.Errorf("expected USAGE_UNREAD declared but unread, got %v", report.Unread)
+	}
+}
@@ -0,0 +1,207 @@
+// Package discovery service discovery registrars, see factory.Registrar
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/TixiaOTA/gokit/factory"
+	"github.com/TixiaOTA/gokit/utils/env"
+)
+
+// OptionFunc setter of consul options
+type OptionFunc func(*option)
+
+type option struct {
+	address         string
+	token           string
+	tags            []string
+	ttl             time.Duration
+	deregisterAfter time.Duration
+	httpClient      *http.Client
+}
+
+func defaultOption() option {
+	return option{
+		address:         env.GetString("CONSUL_HTTP_ADDR", "http://127.0.0.1:8500"),
+		token:           env.GetString("CONSUL_HTTP_TOKEN"),
+		ttl:             env.GetDuration("CONSUL_CHECK_TTL", 15*time.Second),
+		deregisterAfter: env.GetDuration("CONSUL_DEREGISTER_AFTER", time.Minute),
+		httpClient:      &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// SetAddress set consul agent address, default from CONSUL_HTTP_ADDR
+func SetAddress(address string) OptionFunc {
+	return func(o *option) {
+		o.address = address
+	}
+}
+
+// SetToken set ACL token, default from CONSUL_HTTP_TOKEN
+func SetToken(token string) OptionFunc {
+	return func(o *option) {
+		o.token = token
+	}
+}
+
+// SetTags add tags on every registered instance
+func SetTags(tags ...string) OptionFunc {
+	return func(o *option) {
+		o.tags = append(o.tags, tags...)
+	}
+}
+
+// SetTTL set TTL of health check, heartbeat is sent every third of ttl, default 15s
+func SetTTL(ttl time.Duration) OptionFunc {
+	return func(o *option) {
+		o.ttl = ttl
+	}
+}
+
+// SetDeregisterAfter set how long the check stays critical before consul deregisters the instance, default 1m
+func SetDeregisterAfter(d time.Duration) OptionFunc {
+	return func(o *option) {
+		o.deregisterAfter = d
+	}
+}
+
+// SetHTTPClient set http client of consul agent API
+func SetHTTPClient(client *http.Client) OptionFunc {
+	return func(o *option) {
+		o.httpClient = client
+	}
+}
+
+// Consul factory.Registrar registering instances on consul agent with TTL health check
+type Consul struct {
+	opt option
+}
+
+// NewConsul create consul registrar
+func NewConsul(opts ...OptionFunc) *Consul {
+	c := &Consul{opt: defaultOption()}
+	for _, opt := range opts {
+		opt(&c.opt)
+	}
+
+	c.opt.address = strings.TrimSuffix(c.opt.address, "/")
+	return c
+}
+
+// consulRegistration body of agent service register API
+type consulRegistration struct {
+	ID      string            `json:"ID"`
+	Name    string            `json:"Name"`
+	Address string            `json:"Address,omitempty"`
+	Port    int               `json:"Port,omitempty"`
+	Tags    []string          `json:"Tags,omitempty"`
+	Meta    map[string]string `json:"Meta,omitempty"`
+	Check   consulCheck       `json:"Check"`
+}
+
+type consulCheck struct {
+	CheckID                        string `json:"CheckID"`
+	TTL                            string `json:"TTL"`
+	DeregisterCriticalServiceAfter string `json:"DeregisterCriticalServiceAfter,omitempty"`
+}
+
+// Register register instance with TTL check and keep the check passing until deregistered
+func (c *Consul) Register(ctx context.Context, instance factory.ServiceInstance) (func(context.Context) error, error) {
+	checkId := "service:" + instance.ID
+	err := c.put(ctx, "/v1/agent/service/register", consulRegistration{
+		ID:      instance.ID,
+		Name:    instance.Name,
+		Address: instance.Address,
+		Port:    instance.Port,
+		Tags:    append(append([]string(nil), instance.Tags...), c.opt.tags...),
+		Meta:    instance.Meta,
+		Check: consulCheck{
+			CheckID:                        checkId,
+			TTL:                            c.opt.ttl.String(),
+			DeregisterCriticalServiceAfter: c.opt.deregisterAfter.String(),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("consul: register %s: %w", instance.ID, err)
+	}
+
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go c.heartbeat(checkId, stop, stopped)
+
+	return func(ctx context.Context) error {
+		close(stop)
+		<-stopped
+
+		if err := c.put(ctx, "/v1/agent/service/deregister/"+url.PathEscape(instance.ID), nil); err != nil {
+			return fmt.Errorf("consul: deregister %s: %w", instance.ID, err)
+		}
+
+		return nil
+	}, nil
+}
+
+// heartbeat pass TTL check every third of ttl until stopped
+func (c *Consul) heartbeat(checkId string, stop <-chan struct{}, stopped chan<- struct{}) {
+	defer close(stopped)
+
+	pass := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), c.opt.ttl)
+		defer cancel()
+
+		if err := c.put(ctx, "/v1/agent/check/pass/"+url.PathEscape(checkId), nil); err != nil {
+			log.Printf("consul: heartbeat %s: %s", checkId, err)
+		}
+	}
+
+	pass()
+	ticker := time.NewTicker(c.opt.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			pass()
+		}
+	}
+}
+
+func (c *Consul) put(ctx context.Context, path string, body interface{}) error {
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.opt.address+path, &buf)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if c.opt.token != "" {
+		req.Header.Set("X-Consul-Token", c.opt.token)
+	}
+
+	resp, err := c.opt.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
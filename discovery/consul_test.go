@@ -0,0 +1,85 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/factory"
+)
+
+// fakeConsul agent API recording calls in order
+type fakeConsul struct {
+	mu       sync.Mutex
+	calls    []string
+	register consulRegistration
+	token    string
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.token = r.Header.Get("X-Consul-Token")
+	switch {
+	case r.URL.Path == "/v1/agent/service/register":
+		_ = json.NewDecoder(r.Body).Decode(&f.register)
+		f.calls = append(f.calls, "register")
+	case strings.HasPrefix(r.URL.Path, "/v1/agent/check/pass/"):
+		f.calls = append(f.calls, "pass")
+	case strings.HasPrefix(r.URL.Path, "/v1/agent/service/deregister/"):
+		f.calls = append(f.calls, "deregister")
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeConsul) recorded() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+func TestConsulRegister(t *testing.T) {
+	fc := &fakeConsul{}
+	srv := httptest.NewServer(fc)
+	defer srv.Close()
+
+	c := NewConsul(SetAddress(srv.URL+"/"), SetToken("secret"), SetTags("v1"), SetTTL(30*time.Millisecond))
+	deregister, err := c.Register(context.Background(), factory.ServiceInstance{
+		ID: "order-grpc-1", Name: "order", Address: "10.0.0.1", Port: 6060, Tags: []string{"grpc"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if err = deregister(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := fc.recorded()
+	if calls[0] != "register" || calls[len(calls)-1] != "deregister" {
+		t.Fatalf("expected register first and deregister last, got %v", calls)
+	}
+
+	if passes := len(calls) - 2; passes < 3 {
+		t.Errorf("expected heartbeats every third of ttl, got %d", passes)
+	}
+
+	reg := fc.register
+	if reg.Check.CheckID != "service:order-grpc-1" || reg.Check.TTL != "30ms" || strings.Join(reg.Tags, ",") != "grpc,v1" || fc.token != "secret" {
+		t.Errorf("unexpected registration %+v token %q", reg, fc.token)
+	}
+
+	// no heartbeat after deregistered
+	time.Sleep(30 * time.Millisecond)
+	if got := fc.recorded(); len(got) != len(calls) {
+		t.Errorf("expected heartbeat stopped, got %v", got)
+	}
+}
@@ -1,6 +1,15 @@
 package factory
 
-import "context"
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/TixiaOTA/gokit/utils/env"
+)
+
+// RunModeValidate value of RUN_MODE env to validate wiring of applications instead of serving them
+const RunModeValidate = "validate"
 
 // ApplicationFactory factory for server and/or worker abstraction
 type ApplicationFactory interface {
@@ -11,3 +20,47 @@ type ApplicationFactory interface {
 	// Shutdown stop the server or worker
 	Shutdown(ctx context.Context)
 }
+
+// Validator optional abstraction of ApplicationFactory to validate its wiring
+// without binding ports or touching brokers, application without Validator is considered valid
+type Validator interface {
+	Validate(ctx context.Context) error
+}
+
+// IsValidateMode report whether application is running on validate mode, see RunModeValidate
+func IsValidateMode() bool {
+	return strings.EqualFold(env.GetString("RUN_MODE"), RunModeValidate)
+}
+
+// Readier optional abstraction of ApplicationFactory reporting it is serving, Ready blocks until then or ctx is done.
+// rest, http and the broker workers implement it with ReadySignal. application without Readier is ready once
+// listening, see Addresser, or right after Serve is called
+type Readier interface {
+	Ready(ctx context.Context) error
+}
+
+// ReadySignal readiness of an application implementing Readier, the zero value is not ready
+type ReadySignal struct {
+	init, set sync.Once
+	ch        chan struct{}
+}
+
+func (s *ReadySignal) done() chan struct{} {
+	s.init.Do(func() { s.ch = make(chan struct{}) })
+	return s.ch
+}
+
+// Set mark the application ready, calling it again is a no-op
+func (s *ReadySignal) Set() {
+	s.set.Do(func() { close(s.done()) })
+}
+
+// Wait block until Set is called or ctx is done
+func (s *ReadySignal) Wait(ctx context.Context) error {
+	select {
+	case <-s.done():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,16 @@
+package factory
+
+import "github.com/TixiaOTA/gokit/utils/safe"
+
+// CrashHandler handle panic of a component goroutine started by GoSafe, see safe.CrashHandler
+type CrashHandler = safe.CrashHandler
+
+// SetCrashHandler set handler of panics recovered by GoSafe, set by the app runner on Run
+func SetCrashHandler(handler CrashHandler) {
+	safe.SetCrashHandler(handler)
+}
+
+// GoSafe run fn of component on a goroutine, a panic is handed to the crash handler, see safe.Go
+func GoSafe(component string, fn func()) {
+	safe.Go(component, fn)
+}
@@ -0,0 +1,35 @@
+package factory
+
+import (
+	"context"
+	"net"
+)
+
+// ServiceInstance instance of running application registered on service discovery
+type ServiceInstance struct {
+	ID      string
+	Name    string
+	Address string
+	Port    int
+	Tags    []string
+	Meta    map[string]string
+}
+
+// Registrar register instance on service discovery, the returned deregister is called before shutdown
+type Registrar interface {
+	Register(ctx context.Context, instance ServiceInstance) (deregister func(ctx context.Context) error, err error)
+}
+
+// NoopRegistrar Registrar registering nothing, default of the app runner
+type NoopRegistrar struct{}
+
+// Register register nothing
+func (NoopRegistrar) Register(context.Context, ServiceInstance) (func(context.Context) error, error) {
+	return func(context.Context) error { return nil }, nil
+}
+
+// Addresser optional abstraction of ApplicationFactory exposing its bound addresses,
+// empty until the application is listening
+type Addresser interface {
+	Addr() []net.Addr
+}
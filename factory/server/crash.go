@@ -0,0 +1,211 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// defaultCrashHookTimeout time given to all crash hooks before the process exits
+const defaultCrashHookTimeout = 5 * time.Second
+
+// CrashReport report of a component goroutine panic, the process exits once hooks are done
+type CrashReport struct {
+	Service   string    `json:"service"`
+	Component string    `json:"component"`
+	Panic     string    `json:"panic"`
+	Stack     string    `json:"stack"`
+	Time      time.Time `json:"time"`
+
+	// build info of the binary
+	GoVersion string `json:"go_version,omitempty"`
+	Module    string `json:"module,omitempty"`
+	Version   string `json:"version,omitempty"`
+
+	// RecentLogs last log lines before the crash, see WithCrashLogs
+	RecentLogs []string `json:"recent_logs,omitempty"`
+}
+
+// CrashHook receive crash report before the process exits, e.g. CrashFileHook or CrashWebhook,
+// ctx is done once the hook timeout elapses
+type CrashHook func(ctx context.Context, report CrashReport) error
+
+// WithCrashHook run hook on component panic before the process exits
+func WithCrashHook(hook CrashHook) OptionFunc {
+	return func(s *server) {
+		s.crashHooks = append(s.crashHooks, hook)
+	}
+}
+
+// SetCrashHookTimeout hard timeout shared by all crash hooks, default 5s
+func SetCrashHookTimeout(timeout time.Duration) OptionFunc {
+	return func(s *server) {
+		s.crashHookTimeout = timeout
+	}
+}
+
+// WithCrashFlush run flush after crash hooks before the process exits, e.g. Close of the logger.Logger
+// shipping to loki so the entries of the crash are not lost
+func WithCrashFlush(flush func() error) OptionFunc {
+	return func(s *server) {
+		s.crashFlush = append(s.crashFlush, flush)
+	}
+}
+
+// WithCrashLogs attach recent log lines of source on crash report, default logger.RecentLines
+func WithCrashLogs(source func() []string) OptionFunc {
+	return func(s *server) {
+		s.crashLogs = source
+	}
+}
+
+// CrashFileHook write crash report as json into path
+func CrashFileHook(path string) CrashHook {
+	return func(_ context.Context, report CrashReport) error {
+		raw, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(path, raw, 0o644)
+	}
+}
+
+// CrashWebhook POST crash report as json to url
+func CrashWebhook(url string) CrashHook {
+	return func(ctx context.Context, report CrashReport) error {
+		raw, err := json.Marshal(report)
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(raw))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("crash webhook: %s", resp.Status)
+		}
+		return nil
+	}
+}
+
+// goSafe run fn of component on a goroutine, a panic is reported by crash, see factory.GoSafe for
+// goroutines of components
+func (s *server) goSafe(component string, fn func()) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				s.crash(component, r, debug.Stack())
+			}
+		}()
+
+		fn()
+	}()
+}
+
+// crash build crash report, leave service discovery and run crash hooks within their timeout, then flush
+// and exit non-zero. a concurrent panic is only logged and waits for the first one to exit
+func (s *server) crash(component string, r interface{}, stack []byte) {
+	log.Printf("Component %s panicked: %v\n%s", component, r, stack)
+	s.crashOnce.Do(func() { s.crashExit(component, r, stack) })
+}
+
+// crashExit crash of the first panic
+func (s *server) crashExit(component string, r interface{}, stack []byte) {
+	report := CrashReport{
+		Service:   s.service.Name(),
+		Component: component,
+		Panic:     fmt.Sprint(r),
+		Stack:     string(stack),
+		Time:      time.Now(),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		report.GoVersion = bi.GoVersion
+		report.Module = bi.Main.Path
+		report.Version = bi.Main.Version
+	}
+	if s.crashLogs != nil {
+		report.RecentLogs = s.crashLogs()
+	}
+
+	timeout := s.crashHookTimeout
+	if timeout <= 0 {
+		timeout = defaultCrashHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// no more traffic routed to the crashing instance
+	s.deregister(ctx)
+
+	var wg sync.WaitGroup
+	for i, hook := range s.crashHooks {
+		wg.Add(1)
+		go func(i int, hook CrashHook) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("Crash hook %d panicked: %v\n", i, r)
+				}
+			}()
+
+			if err := hook(ctx, report); err != nil {
+				log.Printf("Crash hook %d failed: %s\n", i, err)
+			}
+		}(i, hook)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("Crash hooks timed out after %s\n", timeout)
+	}
+
+	s.flushCrash(timeout)
+	s.exit(2)
+}
+
+// flushCrash run WithCrashFlush functions within timeout
+func (s *server) flushCrash(timeout time.Duration) {
+	if len(s.crashFlush) < 1 {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i, flush := range s.crashFlush {
+			if err := flush(); err != nil {
+				log.Printf("Crash flush %d failed: %s\n", i, err)
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("Crash flush timed out after %s\n", timeout)
+	}
+}
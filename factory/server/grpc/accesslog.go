@@ -0,0 +1,266 @@
+package grpc
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// accessLogKey context key of access log entry
+type accessLogKey struct{}
+
+// accessLogEntry mutable data of access log shared with inner interceptors
+type accessLogEntry struct {
+	mu        sync.Mutex
+	principal string
+
+	// request checksum, see WithRequestChecksum
+	checksum     string
+	duplicate    bool
+	duplicateAge time.Duration
+
+	// api version of request, see WithAPIVersionPolicy
+	apiVersion string
+
+	// request id assigned by the tracer or trailer interceptor
+	requestId string
+}
+
+// SetPrincipal set authenticated principal of current RPC, written on the access log line
+func SetPrincipal(ctx context.Context, principal string) {
+	if e, ok := ctx.Value(accessLogKey{}).(*accessLogEntry); ok {
+		e.mu.Lock()
+		e.principal = principal
+		e.mu.Unlock()
+	}
+}
+
+func (e *accessLogEntry) getPrincipal() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.principal
+}
+
+func (e *accessLogEntry) setChecksum(checksum string, age time.Duration, duplicate bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.checksum, e.duplicateAge, e.duplicate = checksum, age, duplicate
+}
+
+func (e *accessLogEntry) setAPIVersion(version string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.apiVersion = version
+}
+
+func (e *accessLogEntry) getAPIVersion() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.apiVersion
+}
+
+// setAccessLogRequestId record request id assigned to current RPC, so the access log line carries the same id
+func setAccessLogRequestId(ctx context.Context, requestId string) {
+	if e, ok := ctx.Value(accessLogKey{}).(*accessLogEntry); ok {
+		e.mu.Lock()
+		e.requestId = requestId
+		e.mu.Unlock()
+	}
+}
+
+func (e *accessLogEntry) getRequestId() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.requestId
+}
+
+// checksumFields request checksum fields, duplicate requests are flagged with the age of the previous one
+func (e *accessLogEntry) checksumFields() []zap.Field {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.checksum == "" {
+		return nil
+	}
+
+	fields := []zap.Field{zap.String("request_checksum", e.checksum)}
+	if e.duplicate {
+		fields = append(fields, zap.Duration("duplicate_within", e.duplicateAge))
+	}
+
+	return fields
+}
+
+// unaryServerAccessLogInterceptor write exactly one line per completed unary RPC
+func (i *interceptor) unaryServerAccessLogInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (resp interface{}, err error) {
+	start := time.Now()
+	entry := &accessLogEntry{}
+	ctx = context.WithValue(ctx, accessLogKey{}, entry)
+
+	resp, err = handler(ctx, req)
+
+	i.writeAccessLog(ctx, info.FullMethod, err, time.Since(start), entry,
+		zap.Int("request_bytes", messageSize(req)),
+		zap.Int("response_bytes", messageSize(resp)),
+	)
+	return
+}
+
+// streamServerAccessLogInterceptor write exactly one line per stream on close with message counts
+func (i *interceptor) streamServerAccessLogInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	start := time.Now()
+	entry := &accessLogEntry{}
+	stream := &accessLogStream{
+		ServerStream: ss,
+		ctx:          context.WithValue(ss.Context(), accessLogKey{}, entry),
+	}
+
+	err := handler(srv, stream)
+
+	i.writeAccessLog(stream.ctx, info.FullMethod, err, time.Since(start), entry,
+		zap.Int("request_bytes", stream.recvBytes),
+		zap.Int("response_bytes", stream.sentBytes),
+		zap.Int("messages_received", stream.recvCount),
+		zap.Int("messages_sent", stream.sentCount),
+	)
+	return err
+}
+
+func (i *interceptor) writeAccessLog(ctx context.Context, method string, err error, d time.Duration, entry *accessLogEntry, fields ...zap.Field) {
+	code := status.Code(err)
+	if code == codes.OK && !i.sampleAccessLog(method) {
+		return
+	}
+
+	var peerIP string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		peerIP = p.Addr.String()
+	}
+
+	var userAgent string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ua := md.Get("user-agent"); len(ua) > 0 {
+			userAgent = ua[0]
+		}
+	}
+
+	requestId := entry.getRequestId()
+	if requestId == "" {
+		requestId = incomingRequestId(ctx)
+	}
+
+	fields = append(fields,
+		zap.String("method", method),
+		zap.String("code", code.String()),
+		zap.Duration("duration", d),
+		zap.String("peer", peerIP),
+		zap.String("user_agent", userAgent),
+		zap.String("request_id", requestId),
+	)
+
+	if md := allowlistedMetadata(ctx, i.opt.metadataKeys); md != nil {
+		fields = append(fields, zap.Any("metadata", md))
+	}
+
+	if principal := entry.getPrincipal(); principal != "" {
+		fields = append(fields, zap.String("principal", principal))
+	}
+
+	if version := entry.getAPIVersion(); version != "" {
+		fields = append(fields, zap.String("api_version", version))
+	}
+
+	fields = append(fields, entry.checksumFields()...)
+
+	if err != nil {
+		fields = append(fields, zap.String("error", status.Convert(err).Message()))
+	}
+
+	if ce := i.opt.accessLog.Check(accessLogLevel(code), "grpc access"); ce != nil {
+		ce.Write(fields...)
+	}
+}
+
+// sampleAccessLog report whether successful RPC of method is logged, failures are always logged
+func (i *interceptor) sampleAccessLog(method string) bool {
+	rate, ok := i.opt.accessLogSampling[method]
+	if !ok || rate >= 1 {
+		return true
+	}
+
+	return rand.Float64() < rate
+}
+
+// accessLogLevel info for OK, warn for client errors and error for server errors
+func accessLogLevel(code codes.Code) zapcore.Level {
+	switch code {
+	case codes.OK:
+		return zapcore.InfoLevel
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal, codes.Unavailable, codes.DataLoss:
+		return zapcore.ErrorLevel
+	default:
+		return zapcore.WarnLevel
+	}
+}
+
+// messageSize wire size of proto message, zero for non proto message
+func messageSize(m interface{}) int {
+	if pm, ok := m.(proto.Message); ok {
+		return proto.Size(pm)
+	}
+
+	return 0
+}
+
+// accessLogStream server stream counting messages and bytes
+type accessLogStream struct {
+	grpc.ServerStream
+	ctx context.Context
+
+	sentCount, recvCount int
+	sentBytes, recvBytes int
+}
+
+func (s *accessLogStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *accessLogStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sentCount++
+		s.sentBytes += messageSize(m)
+	}
+
+	return err
+}
+
+func (s *accessLogStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.recvCount++
+		s.recvBytes += messageSize(m)
+	}
+
+	return err
+}
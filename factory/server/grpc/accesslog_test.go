@@ -0,0 +1,112 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func newAccessLogInterceptor() (*interceptor, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	return &interceptor{opt: &option{accessLog: &logger.Logger{Logger: zap.New(core)}}}, logs
+}
+
+func accessLogContext() context.Context {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("user-agent", "grpc-go/test", "x-request-id", "req-1"))
+	return peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}})
+}
+
+func TestAccessLogUnary(t *testing.T) {
+	i, logs := newAccessLogInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}
+
+	_, _ = i.unaryServerAccessLogInterceptor(accessLogContext(), wrapperspb.String("hello"), info, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		SetPrincipal(ctx, "user-42")
+		return wrapperspb.String("world!"), nil
+	})
+
+	_, _ = i.unaryServerAccessLogInterceptor(accessLogContext(), nil, info, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return nil, status.Error(codes.Internal, "boom")
+	})
+
+	entries := logs.AllUntimed()
+	if len(entries) != 2 {
+		t.Fatalf("expected one line per RPC, got %d", len(entries))
+	}
+
+	ok := entries[0].ContextMap()
+	for _, key := range []string{"method", "code", "duration", "request_bytes", "response_bytes", "peer", "user_agent", "request_id", "principal"} {
+		if _, exist := ok[key]; !exist {
+			t.Errorf("expected field %s on access log, got %v", key, ok)
+		}
+	}
+
+	if entries[0].Level != zapcore.InfoLevel || ok["user_agent"] != "grpc-go/test" || ok["request_id"] != "req-1" {
+		t.Errorf("unexpected success line %v %v", entries[0].Level, ok)
+	}
+
+	failed := entries[1].ContextMap()
+	if entries[1].Level != zapcore.ErrorLevel || failed["code"] != "Internal" || failed["error"] != "boom" {
+		t.Errorf("unexpected failure line %v %v", entries[1].Level, failed)
+	}
+}
+
+func TestAccessLogGeneratedRequestId(t *testing.T) {
+	i, logs := newAccessLogInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}})
+
+	requestId := "generated-1"
+	_, _ = i.unaryServerAccessLogInterceptor(ctx, nil, info, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		// request id generated by the tracer interceptor
+		setAccessLogRequestId(ctx, requestId)
+		return nil, nil
+	})
+
+	if got := logs.AllUntimed()[0].ContextMap()["request_id"]; got != requestId {
+		t.Errorf("expected request id %s assigned to the RPC, got %v", requestId, got)
+	}
+}
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (f *fakeServerStream) Context() context.Context    { return f.ctx }
+func (f *fakeServerStream) SendMsg(_ interface{}) error { return nil }
+func (f *fakeServerStream) RecvMsg(_ interface{}) error { return nil }
+
+func TestAccessLogStream(t *testing.T) {
+	i, logs := newAccessLogInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/test.Service/Watch", IsServerStream: true}
+
+	_ = i.streamServerAccessLogInterceptor(nil, &fakeServerStream{ctx: accessLogContext()}, info, func(_ interface{}, ss grpc.ServerStream) error {
+		_ = ss.RecvMsg(wrapperspb.String("subscribe"))
+		for n := 0; n < 3; n++ {
+			_ = ss.SendMsg(wrapperspb.String("event"))
+		}
+		return nil
+	})
+
+	entries := logs.AllUntimed()
+	if len(entries) != 1 {
+		t.Fatalf("expected one line on stream close, got %d", len(entries))
+	}
+
+	fields := entries[0].ContextMap()
+	if fields["messages_sent"] != int64(3) || fields["messages_received"] != int64(1) {
+		t.Errorf("unexpected message counts %v", fields)
+	}
+}
@@ -0,0 +1,205 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// MetadataAPIVersion request metadata key carrying the api version of client, e.g. "1.4.0"
+	MetadataAPIVersion = "x-api-version"
+	// HeaderAPIDeprecation response header carrying the deprecation message of requested api version
+	HeaderAPIDeprecation = "x-api-deprecation"
+
+	// apiVersionOther metric label of versions not named by the policy, bounding the label cardinality
+	apiVersionOther = "other"
+)
+
+var (
+	apiVersionRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_server_api_version_requests_total",
+		Help: "How many RPCs received, partitioned by method and api version, versions not named by the policy are counted as other.",
+	}, []string{"method", "version"})
+	apiVersionRegisterOnce sync.Once
+)
+
+// apiVersion parsed semantic version, missing minor and patch are zero
+type apiVersion [3]int
+
+func parseAPIVersion(s string) (apiVersion, error) {
+	var v apiVersion
+
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "v"), ".")
+	if len(parts) > 3 || parts[0] == "" {
+		return v, fmt.Errorf("invalid api version %q", s)
+	}
+
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid api version %q", s)
+		}
+		v[i] = n
+	}
+
+	return v, nil
+}
+
+func (v apiVersion) compare(o apiVersion) int {
+	for i := range v {
+		if v[i] != o[i] {
+			if v[i] < o[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func (v apiVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+}
+
+// apiVersionPolicy supported api versions, see WithAPIVersionPolicy
+type apiVersionPolicy struct {
+	min, max     apiVersion
+	deprecations map[apiVersion]string
+}
+
+func newAPIVersionPolicy(min, max string, deprecations map[string]string) (*apiVersionPolicy, error) {
+	p := &apiVersionPolicy{deprecations: make(map[apiVersion]string, len(deprecations))}
+
+	var err error
+	if p.min, err = parseAPIVersion(min); err != nil {
+		return nil, err
+	}
+	if p.max, err = parseAPIVersion(max); err != nil {
+		return nil, err
+	}
+	if p.min.compare(p.max) > 0 {
+		return nil, fmt.Errorf("api version min %s is above max %s", p.min, p.max)
+	}
+
+	for version, message := range deprecations {
+		v, err := parseAPIVersion(version)
+		if err != nil {
+			return nil, err
+		}
+		p.deprecations[v] = message
+	}
+
+	return p, nil
+}
+
+// check resolve api version of request, returns deprecation message of the version when deprecated.
+// request without version is rejected when required, otherwise assumed the max version
+func (p *apiVersionPolicy) check(ctx context.Context, required bool) (apiVersion, string, error) {
+	var raw string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(MetadataAPIVersion); len(v) > 0 {
+			raw = v[0]
+		}
+	}
+
+	if raw == "" {
+		if required {
+			return apiVersion{}, "", status.Errorf(codes.InvalidArgument, "missing %s metadata", MetadataAPIVersion)
+		}
+
+		return p.max, "", nil
+	}
+
+	v, err := parseAPIVersion(raw)
+	if err != nil {
+		return apiVersion{}, "", status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if v.compare(p.min) < 0 {
+		return v, "", status.Errorf(codes.FailedPrecondition,
+			"api version %s is no longer supported, upgrade client to api version %s or later", v, p.min)
+	}
+
+	if v.compare(p.max) > 0 {
+		return v, "", status.Errorf(codes.FailedPrecondition,
+			"api version %s is not supported yet, latest supported api version is %s", v, p.max)
+	}
+
+	return v, p.deprecations[v], nil
+}
+
+// label metric label of v, only the min, max and deprecated versions are labeled as is
+func (p *apiVersionPolicy) label(v apiVersion) string {
+	if _, deprecated := p.deprecations[v]; deprecated || v == p.min || v == p.max {
+		return v.String()
+	}
+
+	return apiVersionOther
+}
+
+// enforce check api version of request, record it on access log and metrics
+func (i *interceptor) enforceAPIVersion(ctx context.Context, method string, setHeader func(metadata.MD) error) error {
+	apiVersionRegisterOnce.Do(func() {
+		_ = prometheus.Register(apiVersionRequests)
+	})
+
+	// probes and tooling of built-in services send no version
+	required := i.opt.apiVersionRequired && !builtinService(methodService(method))
+	v, deprecation, err := i.opt.apiVersionPolicy.check(ctx, required)
+	if err != nil {
+		return err
+	}
+
+	apiVersionRequests.WithLabelValues(method, i.opt.apiVersionPolicy.label(v)).Inc()
+	if e, ok := ctx.Value(accessLogKey{}).(*accessLogEntry); ok {
+		e.setAPIVersion(v.String())
+	}
+
+	if deprecation != "" {
+		_ = setHeader(metadata.Pairs(HeaderAPIDeprecation, deprecation))
+	}
+
+	return nil
+}
+
+func (i *interceptor) unaryServerAPIVersionInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	if err := i.enforceAPIVersion(ctx, info.FullMethod, func(md metadata.MD) error { return grpc.SetHeader(ctx, md) }); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (i *interceptor) streamServerAPIVersionInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	if err := i.enforceAPIVersion(ss.Context(), info.FullMethod, ss.SetHeader); err != nil {
+		return err
+	}
+
+	return handler(srv, ss)
+}
+
+// methodService service name of full method, e.g. grpc.health.v1.Health of /grpc.health.v1.Health/Check
+func methodService(fullMethod string) string {
+	service, _, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	return service
+}
@@ -0,0 +1,151 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAPIVersionPolicyBands(t *testing.T) {
+	policy, err := newAPIVersionPolicy("1.2", "2.0.0", map[string]string{"v1.2.0": "1.2 is removed on 2027-01-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		version     string
+		required    bool
+		want        codes.Code
+		deprecation bool
+	}{
+		{version: "1.1.9", want: codes.FailedPrecondition},
+		{version: "1.2", want: codes.OK, deprecation: true},
+		{version: "v1.5.3", want: codes.OK},
+		{version: "2.1.0", want: codes.FailedPrecondition},
+		{version: "latest", want: codes.InvalidArgument},
+		{version: "", want: codes.OK},
+		{version: "", required: true, want: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.version != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(MetadataAPIVersion, tt.version))
+		}
+
+		_, deprecation, err := policy.check(ctx, tt.required)
+		if code := status.Code(err); code != tt.want || (deprecation != "") != tt.deprecation {
+			t.Errorf("version %q required %v: expected %s deprecated %v, got %s %q", tt.version, tt.required, tt.want, tt.deprecation, code, deprecation)
+		}
+	}
+}
+
+func TestAPIVersionMetricLabel(t *testing.T) {
+	opt := defaultOption()
+	WithAPIVersionPolicy("1.0.0", "2.0.0", map[string]string{"1.2.0": "upgrade to 2.0.0"})(&opt)
+	i := &interceptor{opt: &opt}
+
+	const method = "/test.Label/Get"
+	defer func() {
+		for _, version := range []string{"1.2.0", "2.0.0", apiVersionOther} {
+			apiVersionRequests.DeleteLabelValues(method, version)
+		}
+	}()
+
+	handler := func(context.Context, interface{}) (interface{}, error) { return nil, nil }
+	for _, version := range []string{"1.2", "2.0.0", "1.3.7", "1.9.12345", "1.0.99"} {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataAPIVersion, version))
+		if _, err := i.unaryServerAPIVersionInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for version, want := range map[string]float64{"1.2.0": 1, "2.0.0": 1, apiVersionOther: 3} {
+		if got := testutil.ToFloat64(apiVersionRequests.WithLabelValues(method, version)); got != want {
+			t.Errorf("version %s: expected %v requests, got %v", version, want, got)
+		}
+	}
+}
+
+func TestAPIVersionInterceptor(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+
+	srv := New(fakeService{},
+		SetTCPHost("127.0.0.1"), SetTCPPort(0),
+		WithAccessLog(&logger.Logger{Logger: zap.New(core)}),
+		WithAPIVersionPolicy("1.0.0", "2.0.0", map[string]string{"1.0.0": "upgrade to 2.0.0"}),
+	).(*rpc)
+	go srv.Serve()
+	defer srv.Shutdown(context.Background())
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(srv.Addr()) < 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	conn, err := grpc.NewClient(srv.Addr()[0].String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var header metadata.MD
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(metadata.AppendToOutgoingContext(ctx, MetadataAPIVersion, "1.0.0"),
+		&grpc_health_v1.HealthCheckRequest{}, grpc.Header(&header))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := header.Get(HeaderAPIDeprecation); len(v) < 1 || v[0] != "upgrade to 2.0.0" {
+		t.Errorf("expected deprecation header, got %v", header)
+	}
+
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(metadata.AppendToOutgoingContext(ctx, MetadataAPIVersion, "0.9.0"),
+		&grpc_health_v1.HealthCheckRequest{})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected below-min version rejected, got %v", err)
+	}
+
+	entries := logs.AllUntimed()
+	if len(entries) != 2 || entries[0].ContextMap()["api_version"] != "1.0.0" {
+		t.Errorf("expected api version on access log, got %v", entries)
+	}
+}
+
+func TestAPIVersionRequiredExemptsBuiltinServices(t *testing.T) {
+	opt := defaultOption()
+	WithAPIVersionPolicy("1.0.0", "2.0.0", nil)(&opt)
+	WithAPIVersionRequired(true)(&opt)
+	i := &interceptor{opt: &opt}
+
+	handler := func(context.Context, interface{}) (interface{}, error) { return nil, nil }
+	defer func() {
+		apiVersionRequests.DeleteLabelValues("/grpc.health.v1.Health/Check", "2.0.0")
+		apiVersionRequests.DeleteLabelValues("/grpc.reflection.v1.ServerReflection/ServerReflectionInfo", "2.0.0")
+	}()
+	for method, want := range map[string]codes.Code{
+		"/grpc.health.v1.Health/Check":                              codes.OK,
+		"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo": codes.OK,
+		"/test.Internal/Ping":                                       codes.InvalidArgument,
+	} {
+		_, err := i.unaryServerAPIVersionInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		if status.Code(err) != want {
+			t.Errorf("%s: expected %s without version, got %v", method, want, err)
+		}
+	}
+}
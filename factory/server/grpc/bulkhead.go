@@ -0,0 +1,160 @@
+package grpc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultBulkheadName label of the default bulkhead covering unlisted methods
+const defaultBulkheadName = "default"
+
+var (
+	bulkheadInUse = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "grpc_server_bulkhead_in_use",
+		Help: "Number of permits in use, partitioned by bulkhead.",
+	}, []string{"bulkhead"})
+	bulkheadRegisterOnce sync.Once
+)
+
+// bulkheadConfig limit of concurrent calls
+type bulkheadConfig struct {
+	maxConcurrent int
+	queueTimeout  time.Duration
+}
+
+// bulkhead semaphore isolating concurrency of a method
+type bulkhead struct {
+	name         string
+	sem          chan struct{}
+	queueTimeout time.Duration
+	inUse        prometheus.Gauge
+}
+
+func newBulkhead(name string, cfg bulkheadConfig) *bulkhead {
+	bulkheadRegisterOnce.Do(func() {
+		_ = prometheus.Register(bulkheadInUse)
+	})
+
+	return &bulkhead{
+		name:         name,
+		sem:          make(chan struct{}, cfg.maxConcurrent),
+		queueTimeout: cfg.queueTimeout,
+		inUse:        bulkheadInUse.WithLabelValues(name),
+	}
+}
+
+// acquire take a permit, waiting up to queue timeout when all permits are in use
+func (b *bulkhead) acquire(ctx context.Context) error {
+	select {
+	case b.sem <- struct{}{}:
+		b.inUse.Inc()
+		return nil
+	default:
+	}
+
+	if b.queueTimeout <= 0 {
+		return status.Errorf(codes.ResourceExhausted, "bulkhead %s is full", b.name)
+	}
+
+	timer := time.NewTimer(b.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case b.sem <- struct{}{}:
+		b.inUse.Inc()
+		return nil
+	case <-timer.C:
+		return status.Errorf(codes.ResourceExhausted, "bulkhead %s is full", b.name)
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+func (b *bulkhead) release() {
+	<-b.sem
+	b.inUse.Dec()
+}
+
+// bulkheads per method bulkheads with optional default bulkhead
+type bulkheads struct {
+	methods map[string]*bulkhead
+	def     *bulkhead
+}
+
+// newBulkheads bulkheads of methods and default, maxConcurrent <= 0 means no bulkhead, such a method is
+// also exempt from the default bulkhead
+func newBulkheads(methods map[string]bulkheadConfig, def *bulkheadConfig) *bulkheads {
+	if def != nil && def.maxConcurrent < 1 {
+		def = nil
+	}
+	if len(methods) < 1 && def == nil {
+		return nil
+	}
+
+	b := &bulkheads{methods: make(map[string]*bulkhead, len(methods))}
+	for method, cfg := range methods {
+		if cfg.maxConcurrent < 1 {
+			b.methods[method] = nil
+			continue
+		}
+		b.methods[method] = newBulkhead(method, cfg)
+	}
+
+	if def != nil {
+		b.def = newBulkhead(defaultBulkheadName, *def)
+	}
+
+	return b
+}
+
+func (b *bulkheads) get(method string) *bulkhead {
+	if bh, ok := b.methods[method]; ok {
+		return bh
+	}
+
+	return b.def
+}
+
+func (i *interceptor) unaryServerBulkheadInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	bh := i.bulkheads.get(info.FullMethod)
+	if bh == nil {
+		return handler(ctx, req)
+	}
+
+	if err := bh.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer bh.release()
+
+	return handler(ctx, req)
+}
+
+func (i *interceptor) streamServerBulkheadInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	bh := i.bulkheads.get(info.FullMethod)
+	if bh == nil {
+		return handler(srv, ss)
+	}
+
+	if err := bh.acquire(ss.Context()); err != nil {
+		return err
+	}
+	defer bh.release()
+
+	return handler(srv, ss)
+}
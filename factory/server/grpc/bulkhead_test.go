@@ -0,0 +1,107 @@
+package grpc
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBulkheadIsolatesSlowMethod(t *testing.T) {
+	i := &interceptor{opt: &option{}, bulkheads: newBulkheads(map[string]bulkheadConfig{
+		"/report.ReportService/Generate": {maxConcurrent: 2, queueTimeout: 10 * time.Millisecond},
+	}, &bulkheadConfig{maxConcurrent: 10})}
+
+	slow := &grpc.UnaryServerInfo{FullMethod: "/report.ReportService/Generate"}
+	fast := &grpc.UnaryServerInfo{FullMethod: "/order.OrderService/Get"}
+
+	release := make(chan struct{})
+	slowHandler := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		<-release
+		return "ok", nil
+	}
+	fastHandler := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		exhausted int
+	)
+	for n := 0; n < 20; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := i.unaryServerBulkheadInterceptor(context.Background(), nil, slow, slowHandler)
+			if status.Code(err) == codes.ResourceExhausted {
+				mu.Lock()
+				exhausted++
+				mu.Unlock()
+			}
+		}()
+	}
+
+	// wait until the flood is either running or rejected
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	for n := 0; n < 5; n++ {
+		if _, err := i.unaryServerBulkheadInterceptor(context.Background(), nil, fast, fastHandler); err != nil {
+			t.Fatalf("expected fast method served, got %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("expected fast method unaffected by slow method, took %s", elapsed)
+	}
+
+	close(release)
+	wg.Wait()
+
+	if exhausted != 18 {
+		t.Errorf("expected 18 calls rejected with RESOURCE_EXHAUSTED, got %d", exhausted)
+	}
+
+	if got := len(i.bulkheads.get(slow.FullMethod).sem); got != 0 {
+		t.Errorf("expected all permits released, got %d in use", got)
+	}
+}
+
+func TestBulkheadQueue(t *testing.T) {
+	bh := newBulkhead("queue", bulkheadConfig{maxConcurrent: 1, queueTimeout: time.Second})
+	if err := bh.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		bh.release()
+	}()
+
+	if err := bh.acquire(context.Background()); err != nil {
+		t.Errorf("expected queued call to get permit, got %v", err)
+	}
+}
+
+func TestBulkheadNonPositive(t *testing.T) {
+	if b := newBulkheads(nil, &bulkheadConfig{maxConcurrent: 0}); b != nil {
+		t.Errorf("expected no bulkheads for zero default, got %+v", b)
+	}
+
+	b := newBulkheads(map[string]bulkheadConfig{
+		"/order.OrderService/Get":    {maxConcurrent: 0},
+		"/order.OrderService/Create": {maxConcurrent: -1},
+	}, &bulkheadConfig{maxConcurrent: 1})
+	for _, method := range []string{"/order.OrderService/Get", "/order.OrderService/Create"} {
+		if bh := b.get(method); bh != nil {
+			t.Errorf("expected %s without bulkhead, got %+v", method, bh)
+		}
+	}
+	if bh := b.get("/order.OrderService/List"); bh == nil {
+		t.Error("expected default bulkhead for unlisted method")
+	}
+}
@@ -0,0 +1,149 @@
+package grpc
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/cespare/xxhash/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// trailerRequestChecksum trailer key of request checksum
+	trailerRequestChecksum = "x-request-checksum"
+	// checksumCapacity recent checksums remembered per method
+	checksumCapacity = 256
+)
+
+// requestChecksums recent request checksums per method, see WithRequestChecksum
+type requestChecksums struct {
+	mu      sync.Mutex
+	methods map[string]struct{}
+	window  time.Duration
+	recent  map[string]*checksumLRU
+}
+
+func newRequestChecksums(window time.Duration, methods []string) *requestChecksums {
+	c := &requestChecksums{
+		methods: make(map[string]struct{}, len(methods)),
+		window:  window,
+		recent:  make(map[string]*checksumLRU, len(methods)),
+	}
+	for _, m := range methods {
+		c.methods[m] = struct{}{}
+	}
+
+	return c
+}
+
+// seen remember checksum of method, returns age of the previous identical request within window
+func (c *requestChecksums) seen(method string, sum uint64, now time.Time) (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lru, ok := c.recent[method]
+	if !ok {
+		lru = newChecksumLRU(checksumCapacity)
+		c.recent[method] = lru
+	}
+
+	prev, ok := lru.put(sum, now)
+	if !ok || now.Sub(prev) > c.window {
+		return 0, false
+	}
+
+	return now.Sub(prev), true
+}
+
+// checksumLRU bounded checksums with the time they were last seen
+type checksumLRU struct {
+	capacity int
+	order    *list.List
+	items    map[uint64]*list.Element
+}
+
+type checksumItem struct {
+	sum uint64
+	at  time.Time
+}
+
+func newChecksumLRU(capacity int) *checksumLRU {
+	return &checksumLRU{capacity: capacity, order: list.New(), items: make(map[uint64]*list.Element)}
+}
+
+// put record sum at now, returns the previous time sum was seen
+func (l *checksumLRU) put(sum uint64, now time.Time) (time.Time, bool) {
+	if el, ok := l.items[sum]; ok {
+		item := el.Value.(*checksumItem)
+		prev := item.at
+		item.at = now
+		l.order.MoveToFront(el)
+		return prev, true
+	}
+
+	l.items[sum] = l.order.PushFront(&checksumItem{sum: sum, at: now})
+	if l.order.Len() > l.capacity {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*checksumItem).sum)
+	}
+
+	return time.Time{}, false
+}
+
+// requestChecksum xxhash of deterministically marshaled request, json for non proto request
+func requestChecksum(req interface{}) (uint64, error) {
+	var (
+		b   []byte
+		err error
+	)
+	if pm, ok := req.(proto.Message); ok {
+		b, err = proto.MarshalOptions{Deterministic: true}.Marshal(pm)
+	} else {
+		b, err = json.Marshal(req)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return xxhash.Sum64(b), nil
+}
+
+// unaryServerChecksumInterceptor attach checksum of allowlisted request on trailer and logs,
+// identical requests within the window are flagged as duplicate. the RPC itself is never changed
+func (i *interceptor) unaryServerChecksumInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	if _, ok := i.opt.requestChecksums.methods[info.FullMethod]; !ok {
+		return handler(ctx, req)
+	}
+
+	sum, err := requestChecksum(req)
+	if err != nil {
+		return handler(ctx, req)
+	}
+
+	checksum := fmt.Sprintf("%016x", sum)
+	_ = grpc.SetTrailer(ctx, metadata.Pairs(trailerRequestChecksum, checksum))
+
+	age, duplicate := i.opt.requestChecksums.seen(info.FullMethod, sum, time.Now())
+	if e, ok := ctx.Value(accessLogKey{}).(*accessLogEntry); ok {
+		e.setChecksum(checksum, age, duplicate)
+	}
+
+	if duplicate {
+		logger.Log.Warnf(ctx, "duplicate request %s of %s within %s", checksum, info.FullMethod, age)
+	}
+
+	return handler(ctx, req)
+}
@@ -0,0 +1,87 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRequestChecksumDuplicate(t *testing.T) {
+	const method = "/grpc.health.v1.Health/Check"
+	core, logs := observer.New(zapcore.DebugLevel)
+
+	srv := New(fakeService{},
+		SetTCPHost("127.0.0.1"), SetTCPPort(0),
+		WithAccessLog(&logger.Logger{Logger: zap.New(core)}),
+		WithRequestChecksum(time.Minute, method),
+	).(*rpc)
+	go srv.Serve()
+	defer srv.Shutdown(context.Background())
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(srv.Addr()) < 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	conn, err := grpc.NewClient(srv.Addr()[0].String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	check := func(service string) string {
+		var trailer metadata.MD
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		if _, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: service}, grpc.Trailer(&trailer)); err != nil && service == "" {
+			t.Fatal(err)
+		}
+
+		if v := trailer.Get(trailerRequestChecksum); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+
+	first, second, other := check(""), check(""), check("other")
+	if first == "" || first != second || first == other {
+		t.Fatalf("expected matching checksums of identical requests only, got %q %q %q", first, second, other)
+	}
+
+	entries := logs.AllUntimed()
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 access log lines, got %d", len(entries))
+	}
+
+	for i, want := range []bool{false, true, false} {
+		fields := entries[i].ContextMap()
+		if _, duplicate := fields["duplicate_within"]; duplicate != want || fields["request_checksum"] == nil {
+			t.Errorf("line %d: expected duplicate %v with checksum, got %v", i, want, fields)
+		}
+	}
+}
+
+func TestRequestChecksumWindow(t *testing.T) {
+	c := newRequestChecksums(time.Second, []string{"/m"})
+	now := time.Unix(100, 0)
+
+	if _, dup := c.seen("/m", 1, now); dup {
+		t.Error("expected first request not duplicate")
+	}
+	if age, dup := c.seen("/m", 1, now.Add(500*time.Millisecond)); !dup || age != 500*time.Millisecond {
+		t.Errorf("expected duplicate within window, got %v %s", dup, age)
+	}
+	if _, dup := c.seen("/m", 1, now.Add(2*time.Second)); dup {
+		t.Error("expected request out of window not duplicate")
+	}
+}
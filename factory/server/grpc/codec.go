@@ -0,0 +1,65 @@
+package grpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// JSONCodec codec of content-subtype "json" (application/grpc+json), proto messages are encoded with protojson
+// so plain JSON tooling can call methods without generated clients
+type JSONCodec struct{}
+
+// Name content-subtype of codec
+func (JSONCodec) Name() string {
+	return "json"
+}
+
+// Marshal encode v into json
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	if m, ok := v.(proto.Message); ok {
+		return protojson.Marshal(m)
+	}
+
+	return json.Marshal(v)
+}
+
+// Unmarshal decode json data into v, unknown fields of proto messages are ignored
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	if m, ok := v.(proto.Message); ok {
+		return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, m)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("json codec: %w", err)
+	}
+
+	return nil
+}
+
+var (
+	codecMu sync.Mutex
+	// registeredCodecs names of codecs already on the grpc codec registry
+	registeredCodecs = make(map[string]bool)
+)
+
+// registerCodecs register codecs on the grpc codec registry, picked by the content-subtype of request.
+// the registry is global and not safe for concurrent use, each name is registered once for the process
+// so servers created later never write into the registry while others serve requests
+func registerCodecs(codecs []encoding.Codec) {
+	codecMu.Lock()
+	defer codecMu.Unlock()
+
+	for _, c := range codecs {
+		if registeredCodecs[c.Name()] {
+			continue
+		}
+
+		encoding.RegisterCodec(c)
+		registeredCodecs[c.Name()] = true
+	}
+}
@@ -0,0 +1,71 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestJSONCodec(t *testing.T) {
+	srv := New(fakeService{}, SetTCPHost("127.0.0.1"), SetTCPPort(0), WithJSONCodec()).(*rpc)
+	go srv.Serve()
+	defer srv.Shutdown(context.Background())
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(srv.Addr()) < 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	conn, err := grpc.NewClient(srv.Addr()[0].String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	client := grpc_health_v1.NewHealthClient(conn)
+	resp, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{}, grpc.CallContentSubtype("json"))
+	if err != nil {
+		t.Fatalf("expected json round-trip, got %v", err)
+	}
+	if resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		t.Errorf("expected SERVING, got %s", resp.Status)
+	}
+
+	// proto keeps working next to json
+	if _, err = client.Check(ctx, &grpc_health_v1.HealthCheckRequest{}); err != nil {
+		t.Errorf("expected proto request, got %v", err)
+	}
+
+	// protojson names enums, plain json would write the number
+	raw, _ := JSONCodec{}.Marshal(&grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING})
+	var out map[string]string
+	if err = json.Unmarshal(raw, &out); err != nil || out["status"] != "SERVING" {
+		t.Errorf("expected protojson encoding, got %s", raw)
+	}
+}
+
+func TestRegisterCodecsOnce(t *testing.T) {
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			registerCodecs([]encoding.Codec{JSONCodec{}})
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+
+	if !registeredCodecs["json"] || encoding.GetCodec("json") == nil {
+		t.Errorf("expected json codec registered")
+	}
+}
@@ -0,0 +1,67 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"google.golang.org/grpc"
+)
+
+// nearDeadlineRatio portion of budget left considered as near deadline (5%)
+const nearDeadlineRatio = 20
+
+// RemainingDeadline return the remaining time budget of ctx clamped between floor and ceiling,
+// use it to derive timeout for outbound dependencies (database, http, grpc client).
+// When ctx has no deadline, ceiling is returned
+func RemainingDeadline(ctx context.Context, floor, ceiling time.Duration) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return ceiling
+	}
+
+	remaining := time.Until(deadline)
+	if remaining < floor {
+		return floor
+	}
+
+	if ceiling > 0 && remaining > ceiling {
+		return ceiling
+	}
+
+	return remaining
+}
+
+func (i *interceptor) unaryServerDeadlineInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok && i.opt != nil && i.opt.defaultDeadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, i.opt.defaultDeadline)
+		defer cancel()
+
+		deadline, ok = ctx.Deadline()
+	}
+
+	if !ok {
+		return handler(ctx, req)
+	}
+
+	// budget of the request on every message logged by the handler
+	budget := time.Until(deadline)
+	logger.SetFields(ctx, map[string]interface{}{"grpc_timeout_remaining": budget.String()})
+
+	resp, err := handler(ctx, req)
+
+	if i.opt != nil && i.opt.warnNearDeadline {
+		if left := time.Until(deadline); left < budget/nearDeadlineRatio {
+			logger.Log.WarnKV(ctx, "returned near deadline", "method", info.FullMethod, "left", left.String(), "budget", budget.String())
+		}
+	}
+
+	return resp, err
+}
@@ -0,0 +1,66 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"google.golang.org/grpc"
+)
+
+func TestRemainingDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if d := RemainingDeadline(ctx, 100*time.Millisecond, time.Second); d != time.Second {
+		t.Errorf("expected ceiling, got %s", d)
+	}
+
+	if d := RemainingDeadline(ctx, 3*time.Second, 5*time.Second); d != 3*time.Second {
+		t.Errorf("expected floor, got %s", d)
+	}
+
+	if d := RemainingDeadline(context.Background(), time.Second, 5*time.Second); d != 5*time.Second {
+		t.Errorf("expected ceiling without deadline, got %s", d)
+	}
+}
+
+func TestDeadlineInterceptorDefault(t *testing.T) {
+	i := &interceptor{opt: &option{defaultDeadline: time.Second}}
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"}
+
+	_, _ = i.unaryServerDeadlineInterceptor(context.Background(), nil, info, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("expected default deadline applied")
+		}
+		return nil, nil
+	})
+}
+
+func TestDeadlineInterceptorWarnNearDeadline(t *testing.T) {
+	i := &interceptor{opt: &option{warnNearDeadline: true}}
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Slow"}
+
+	lock := new(logger.Locker)
+	ctx := context.WithValue(context.Background(), logger.LogKey, lock)
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+
+	_, _ = i.unaryServerDeadlineInterceptor(ctx, nil, info, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return nil, nil
+	})
+
+	val, _ := lock.Load(logger.Flags("LogMessages"))
+	messages, _ := val.([]logger.LogMessage)
+	if len(messages) != 1 || messages[0].Level != "WARN" || !strings.Contains(messages[0].Message, "near deadline") {
+		t.Fatalf("expected near deadline warning, got %v", messages)
+	}
+
+	fields := messages[0].Fields
+	if fields["method"] != info.FullMethod || fields["grpc_timeout_remaining"] == nil || fields["budget"] != fields["grpc_timeout_remaining"] {
+		t.Errorf("expected method and budget as fields, got %v", fields)
+	}
+}
@@ -0,0 +1,207 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/TixiaOTA/gokit/utils/env"
+	"github.com/TixiaOTA/gokit/utils/errorkit"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+const (
+	// internalMessage message of unknown error on production
+	internalMessage = "internal server error"
+	// reasonInternal reason of unknown error on errdetails.ErrorInfo
+	reasonInternal = "INTERNAL"
+	// errorInfoRequestId key of request id on errdetails.ErrorInfo metadata
+	errorInfoRequestId = "request_id"
+)
+
+// mappedError status sent to the client while keeping the original error for logging
+type mappedError struct {
+	status *status.Status
+	cause  error
+}
+
+func (e *mappedError) Error() string {
+	return e.cause.Error()
+}
+
+func (e *mappedError) Unwrap() error {
+	return e.cause
+}
+
+// GRPCStatus status sent to the client
+func (e *mappedError) GRPCStatus() *status.Status {
+	return e.status
+}
+
+func (i *interceptor) unaryServerErrorInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	return resp, i.mapError(ctx, err)
+}
+
+func (i *interceptor) streamServerErrorInterceptor(
+	srv interface{},
+	stream grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	return i.mapError(stream.Context(), handler(srv, stream))
+}
+
+// mapError convert error into status with details, types.CodedError gets its registered code with
+// errdetails.ErrorInfo and errdetails.BadRequest for InvalidArgument, errorkit.ErrorResponse gets
+// the code of its http status, status errors are kept as is, unknown errors become Internal with
+// generic message on production
+func (i *interceptor) mapError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var me *mappedError
+	if errors.As(err, &me) {
+		return err
+	}
+
+	requestId := requestIdFromContext(ctx)
+	info := &errdetails.ErrorInfo{
+		Domain:   i.errorDomain(),
+		Metadata: map[string]string{errorInfoRequestId: requestId},
+	}
+
+	var st *status.Status
+	var er *errorkit.ErrorResponse
+	if ce, ok := types.AsCodedError(err); ok {
+		info.Reason = ce.Code
+		st = withDetails(status.New(ce.GRPCCode(), ce.Message), info, badRequest(ce))
+	} else if errors.As(err, &er) {
+		info.Reason = httpReason(er.StatusCode())
+		st = withDetails(status.New(httpCode(er.StatusCode()), er.ErrorMessage()), info)
+	} else if s, ok := status.FromError(err); ok {
+		return s.Err()
+	} else {
+		switch {
+		case errors.Is(err, context.Canceled):
+			st = status.New(codes.Canceled, err.Error())
+		case errors.Is(err, context.DeadlineExceeded):
+			st = status.New(codes.DeadlineExceeded, err.Error())
+		default:
+			msg := err.Error()
+			if isProduction() {
+				msg = internalMessage
+			}
+
+			info.Reason = reasonInternal
+			st = withDetails(status.New(codes.Internal, msg), info)
+		}
+	}
+
+	return &mappedError{status: st, cause: err}
+}
+
+// errorDomain domain of errdetails.ErrorInfo, default to service name
+func (i *interceptor) errorDomain() string {
+	if i.opt != nil && i.opt.errorDomain != "" {
+		return i.opt.errorDomain
+	}
+
+	return i.serviceName
+}
+
+// badRequest field violations from details of validation error, nil when not InvalidArgument
+func badRequest(ce *types.CodedError) *errdetails.BadRequest {
+	if ce.GRPCCode() != codes.InvalidArgument || len(ce.Details) < 1 {
+		return nil
+	}
+
+	fields := make([]string, 0, len(ce.Details))
+	for field := range ce.Details {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	br := &errdetails.BadRequest{}
+	for _, field := range fields {
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: fmt.Sprint(ce.Details[field]),
+		})
+	}
+
+	return br
+}
+
+// httpCode grpc code of http status code
+func httpCode(sc int) codes.Code {
+	switch sc {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+
+	if sc >= 400 && sc < 500 {
+		return codes.FailedPrecondition
+	}
+
+	return codes.Internal
+}
+
+// httpReason reason of errdetails.ErrorInfo from http status code, e.g. NOT_FOUND
+func httpReason(sc int) string {
+	text := http.StatusText(sc)
+	if text == "" {
+		return reasonInternal
+	}
+
+	return strings.ToUpper(strings.ReplaceAll(text, " ", "_"))
+}
+
+func withDetails(st *status.Status, info *errdetails.ErrorInfo, br ...*errdetails.BadRequest) *status.Status {
+	details := []protoadapt.MessageV1{info}
+	for _, b := range br {
+		if b != nil {
+			details = append(details, b)
+		}
+	}
+
+	if ds, err := st.WithDetails(details...); err == nil {
+		return ds
+	}
+
+	return st
+}
+
+func isProduction() bool {
+	return strings.ToUpper(env.GetString("APP_ENV")) == "PRODUCTION"
+}
@@ -0,0 +1,100 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/TixiaOTA/gokit/utils/env/envtest"
+	"github.com/TixiaOTA/gokit/utils/errorkit"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func mapErrorOf(t *testing.T, handlerErr error) *status.Status {
+	t.Helper()
+
+	i := &interceptor{serviceName: "order", opt: &option{}}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "req-1"))
+
+	_, err := i.unaryServerErrorInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, func(context.Context, interface{}) (interface{}, error) {
+		return nil, handlerErr
+	})
+
+	return status.Convert(err)
+}
+
+func errorDetails(st *status.Status) (info *errdetails.ErrorInfo, br *errdetails.BadRequest) {
+	for _, d := range st.Details() {
+		switch v := d.(type) {
+		case *errdetails.ErrorInfo:
+			info = v
+		case *errdetails.BadRequest:
+			br = v
+		}
+	}
+
+	return info, br
+}
+
+func TestErrorMappingCodedError(t *testing.T) {
+	types.RegisterError("ORDER_INVALID", http.StatusBadRequest, codes.InvalidArgument, "invalid order")
+	types.RegisterError("ORDER_NOT_FOUND", http.StatusNotFound, codes.NotFound, "order not found")
+
+	st := mapErrorOf(t, types.NewCodedError("ORDER_INVALID", nil).WithDetail("quantity", "must be positive").WithDetail("sku", "required"))
+	info, br := errorDetails(st)
+	if st.Code() != codes.InvalidArgument || st.Message() != "invalid order" {
+		t.Errorf("unexpected validation status %s %s", st.Code(), st.Message())
+	}
+	if info == nil || info.Reason != "ORDER_INVALID" || info.Domain != "order" || info.Metadata["request_id"] != "req-1" {
+		t.Errorf("unexpected error info %v", info)
+	}
+	if br == nil || len(br.FieldViolations) != 2 || br.FieldViolations[0].Field != "quantity" || br.FieldViolations[1].Description != "required" {
+		t.Errorf("unexpected bad request %v", br)
+	}
+
+	st = mapErrorOf(t, fmt.Errorf("get order: %w", types.NewCodedError("ORDER_NOT_FOUND", errors.New("no rows"))))
+	info, br = errorDetails(st)
+	if st.Code() != codes.NotFound || info == nil || info.Reason != "ORDER_NOT_FOUND" || br != nil {
+		t.Errorf("unexpected not found status %s %v %v", st.Code(), info, br)
+	}
+}
+
+func TestErrorMappingUnknownError(t *testing.T) {
+	for appEnv, want := range map[string]string{"development": "db: connection refused", "production": internalMessage} {
+		envtest.OverrideForTest(t, "APP_ENV", appEnv)
+
+		st := mapErrorOf(t, errors.New("db: connection refused"))
+		info, _ := errorDetails(st)
+		if st.Code() != codes.Internal || st.Message() != want {
+			t.Errorf("%s: expected Internal %q, got %s %q", appEnv, want, st.Code(), st.Message())
+		}
+		if info == nil || info.Reason != reasonInternal || info.Metadata["request_id"] != "req-1" {
+			t.Errorf("%s: unexpected error info %v", appEnv, info)
+		}
+	}
+}
+
+func TestErrorMappingErrorResponse(t *testing.T) {
+	notFound := errorkit.Error(errors.New("no rows"), "order not found", http.StatusNotFound)
+
+	for name, err := range map[string]error{
+		"plain":   notFound,
+		"wrapped": fmt.Errorf("get order: %w", notFound),
+	} {
+		st := mapErrorOf(t, err)
+		info, _ := errorDetails(st)
+		if st.Code() != codes.NotFound || st.Message() != "order not found" {
+			t.Errorf("%s: expected NotFound %q, got %s %q", name, "order not found", st.Code(), st.Message())
+		}
+		if info == nil || info.Reason != "NOT_FOUND" || info.Metadata["request_id"] != "req-1" {
+			t.Errorf("%s: unexpected error info %v", name, info)
+		}
+	}
+}
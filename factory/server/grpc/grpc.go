@@ -2,24 +2,38 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/TixiaOTA/gokit/factory"
 	"github.com/TixiaOTA/gokit/logger"
 	"github.com/TixiaOTA/gokit/types"
 	"github.com/TixiaOTA/gokit/utils/env"
+	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/keepalive"
 )
 
 type rpc struct {
 	opt          option
 	serverEngine *grpc.Server
+	listenerMu   sync.Mutex
 	listener     net.Listener
+	additional   []*additionalListener
+	webServer    *http.Server
 	service      factory.ServiceFactory
+	maintenance  *maintenance
+	stats        *statsHandler
+	slowRPC      *slowRPC
+	peerLimiter  *peerLimiter
 }
 
 // New create a new gRPC server
@@ -42,31 +56,122 @@ func New(svc factory.ServiceFactory, opts ...OptionFunc) factory.ApplicationFact
 	srv := &rpc{
 		service: svc,
 		opt:     defaultOption(),
-		serverEngine: grpc.NewServer(
-			grpc.KeepaliveEnforcementPolicy(keepAliveEnforce),
-			grpc.KeepaliveParams(keepAliveServer),
-			grpc.UnaryInterceptor(
-				intercept.chainUnaryServer(
-					intercept.unaryServerTracerInterceptor,
-				),
-			),
-		),
 	}
 
 	for _, opt := range opts {
 		opt(&srv.opt)
 	}
 
-	tcpURI := srv.opt.tcpHost + ":" + srv.opt.tcpPort
-	var err error
-	srv.listener, err = net.Listen("tcp", tcpURI)
-	if err != nil {
-		panic(err)
-	}
+	// codecs are looked up on the global registry, registered before the server accepts requests
+	registerCodecs(srv.opt.codecs)
 
+	healthServer := health.NewServer()
+	srv.maintenance = newMaintenance(healthServer)
 	intercept.opt = &srv.opt
-	if h := srv.service.GRPCHandler(); h != nil {
-		h.Register(srv.serverEngine)
+	intercept.maintenance = srv.maintenance
+	intercept.slowRPC = newSlowRPC(srv.opt.slowRPC)
+	srv.slowRPC = intercept.slowRPC
+
+	unaryInterceptors := []grpc.UnaryServerInterceptor{
+		intercept.unaryServerMaintenanceInterceptor,
+		intercept.unaryServerTracerInterceptor,
+		intercept.unaryServerErrorInterceptor,
+		intercept.unaryServerDeadlineInterceptor,
+	}
+	streamInterceptors := []grpc.StreamServerInterceptor{
+		intercept.streamServerMaintenanceInterceptor,
+		intercept.streamServerErrorInterceptor,
+	}
+
+	// reject unsupported api versions right after maintenance, before any work is done
+	if srv.opt.apiVersionPolicy != nil {
+		unaryInterceptors = append(unaryInterceptors[:1], append([]grpc.UnaryServerInterceptor{intercept.unaryServerAPIVersionInterceptor}, unaryInterceptors[1:]...)...)
+		streamInterceptors = append(streamInterceptors[:1], append([]grpc.StreamServerInterceptor{intercept.streamServerAPIVersionInterceptor}, streamInterceptors[1:]...)...)
+	}
+
+	if srv.opt.requestChecksums != nil {
+		unaryInterceptors = append(unaryInterceptors, intercept.unaryServerChecksumInterceptor)
+	}
+
+	if !srv.opt.disableTrailers {
+		streamInterceptors = append([]grpc.StreamServerInterceptor{intercept.streamServerTrailerInterceptor}, streamInterceptors...)
+	}
+
+	if intercept.bulkheads = newBulkheads(srv.opt.bulkheads, srv.opt.defaultBulkhead); intercept.bulkheads != nil {
+		unaryInterceptors = append(unaryInterceptors, intercept.unaryServerBulkheadInterceptor)
+		streamInterceptors = append(streamInterceptors, intercept.streamServerBulkheadInterceptor)
+	}
+
+	// innermost, so the interceptors above are not reported as handler time
+	unaryInterceptors = append(unaryInterceptors, intercept.unaryServerHandlerStartInterceptor)
+	streamInterceptors = append(streamInterceptors, intercept.streamServerHandlerStartInterceptor)
+
+	// always installed so the limit can be enabled at runtime, disabled limit cost an atomic load
+	intercept.peerLimiter = newPeerLimiter(srv.opt.perPeerStreamLimit, srv.opt.peerIdentity)
+	srv.peerLimiter = intercept.peerLimiter
+	unaryInterceptors = append([]grpc.UnaryServerInterceptor{intercept.unaryServerPeerLimitInterceptor}, unaryInterceptors...)
+	streamInterceptors = append([]grpc.StreamServerInterceptor{intercept.streamServerPeerLimitInterceptor}, streamInterceptors...)
+
+	// authenticated identity keys the per peer limit
+	if srv.opt.authUnary != nil {
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{srv.opt.authUnary}, unaryInterceptors...)
+	}
+	if srv.opt.authStream != nil {
+		streamInterceptors = append([]grpc.StreamServerInterceptor{srv.opt.authStream}, streamInterceptors...)
+	}
+
+	// reject methods hidden on the listener before any work is done
+	if srv.opt.methodVisibility != nil {
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{intercept.unaryServerVisibilityInterceptor}, unaryInterceptors...)
+		streamInterceptors = append([]grpc.StreamServerInterceptor{intercept.streamServerVisibilityInterceptor}, streamInterceptors...)
+	}
+
+	if intercept.serviceInfo = serviceInfoHeader(); intercept.serviceInfo != nil {
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{intercept.unaryServerInfoInterceptor}, unaryInterceptors...)
+		streamInterceptors = append([]grpc.StreamServerInterceptor{intercept.streamServerInfoInterceptor}, streamInterceptors...)
+	}
+
+	if srv.opt.accessLog != nil {
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{intercept.unaryServerAccessLogInterceptor}, unaryInterceptors...)
+		streamInterceptors = append([]grpc.StreamServerInterceptor{intercept.streamServerAccessLogInterceptor}, streamInterceptors...)
+	}
+
+	// connection level stats exposed on prometheus, registered once per process
+	srv.stats = newStatsHandler()
+	_ = prometheus.Register(srv.stats)
+
+	serverOptions := []grpc.ServerOption{
+		grpc.KeepaliveEnforcementPolicy(keepAliveEnforce),
+		grpc.KeepaliveParams(keepAliveServer),
+		grpc.StatsHandler(srv.stats),
+		grpc.UnaryInterceptor(intercept.chainUnaryServer(unaryInterceptors...)),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	}
+
+	if srv.opt.otelTracerProvider != nil {
+		serverOptions = append(serverOptions, srv.opt.otelServerOption())
+	}
+
+	register := func(s *grpc.Server) {
+		if h := srv.service.GRPCHandler(); h != nil {
+			h.Register(s)
+		}
+
+		// keep health server of the service, grpc refuses a second registration of a service
+		if _, ok := s.GetServiceInfo()[grpc_health_v1.Health_ServiceDesc.ServiceName]; !ok {
+			grpc_health_v1.RegisterHealthServer(s, healthServer)
+		}
+	}
+
+	srv.serverEngine = grpc.NewServer(serverOptions...)
+	register(srv.serverEngine)
+	srv.additional = newAdditionalListeners(srv.opt.additionalListeners, srv.serverEngine, serverOptions, register)
+	if srv.opt.maintenanceReason != "" {
+		srv.EnterMaintenance(srv.opt.maintenanceReason, srv.opt.maintenanceRetryAfter)
+	}
+
+	if srv.opt.grpcWebPort != "" {
+		srv.webServer = srv.newGRPCWebServer()
 	}
 
 	for root, info := range srv.serverEngine.GetServiceInfo() {
@@ -75,24 +180,148 @@ func New(svc factory.ServiceFactory, opts ...OptionFunc) factory.ApplicationFact
 		}
 	}
 
-	logger.GreenBold(fmt.Sprintf("⇨ GRPC server run at %s\n", tcpURI))
+	logger.GreenBold(fmt.Sprintf("⇨ GRPC server run at %s\n", srv.opt.tcpHost+":"+srv.opt.tcpPort))
+	for _, al := range srv.additional {
+		logger.GreenBold(fmt.Sprintf("⇨ GRPC server also run at %s (tls: %v)\n", al.config.addr, al.config.secure()))
+	}
 	return srv
 }
 
+// Serve run warm-up then listen, the listener is opened only after warm-up succeed
 func (r *rpc) Serve() {
-	err := r.serverEngine.Serve(r.listener)
+	if err := r.warmup(); err != nil {
+		panic(fmt.Errorf("grpc server: %w", err))
+	}
+
+	listener, err := net.Listen("tcp", r.opt.tcpHost+":"+r.opt.tcpPort)
 	if err != nil {
+		panic(fmt.Errorf("grpc server: %w", err))
+	}
+
+	r.listenerMu.Lock()
+	r.listener = listener
+	r.listenerMu.Unlock()
+
+	if err = r.listenAdditional(); err != nil {
+		_ = listener.Close()
+		panic(fmt.Errorf("grpc server: additional listener: %w", err))
+	}
+
+	for _, al := range r.additional {
+		factory.GoSafe("grpc-"+al.config.name, func() {
+			if err := al.server.Serve(&namedListener{Listener: al.listener, name: al.config.name}); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+				log.Fatal(err)
+			}
+		})
+	}
+
+	factory.GoSafe("grpc-web", r.serveGRPCWeb)
+
+	// stopped before listening when shutdown arrives during warm-up
+	if err = r.serverEngine.Serve(&namedListener{Listener: listener, name: MainListener}); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
 		log.Fatal(err)
 	}
 }
 
-func (r *rpc) Shutdown(_ context.Context) {
+// warmup run warm-up of WithWarmup within its timeout, zero timeout means unlimited
+func (r *rpc) warmup() error {
+	if r.opt.warmup == nil {
+		return nil
+	}
+
+	ctx := context.Background()
+	if r.opt.warmupTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.opt.warmupTimeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- r.opt.warmup(ctx) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("warm-up: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("warm-up timed out after %s", r.opt.warmupTimeout)
+	}
+}
+
+func (r *rpc) Shutdown(ctx context.Context) {
 	defer logger.RedBold("Stopping GRPC Server")
 
+	r.shutdownGRPCWeb(ctx)
+
+	// the server engine also stops plaintext additional listeners
 	r.serverEngine.GracefulStop()
-	_ = r.listener.Close()
+	for _, al := range r.additional {
+		if al.server != r.serverEngine {
+			al.server.GracefulStop()
+		}
+	}
+	r.slowRPC.stop()
+
+	r.listenerMu.Lock()
+	defer r.listenerMu.Unlock()
+	if r.listener != nil {
+		_ = r.listener.Close()
+	}
+	for _, al := range r.additional {
+		if al.listener != nil {
+			_ = al.listener.Close()
+		}
+	}
+}
+
+// Addr bound addresses of the main and additional listeners, empty until listening
+func (r *rpc) Addr() []net.Addr {
+	r.listenerMu.Lock()
+	defer r.listenerMu.Unlock()
+
+	if r.listener == nil {
+		return nil
+	}
+
+	addrs := []net.Addr{r.listener.Addr()}
+	for _, al := range r.additional {
+		if al.listener != nil {
+			addrs = append(addrs, al.listener.Addr())
+		}
+	}
+
+	return addrs
 }
 
 func (r *rpc) Name() string {
 	return types.GRPC.String()
 }
+
+// Validate check that certificate files of TLS listeners load and at least one grpc service is registered
+// besides the built-in ones, see builtinService
+func (r *rpc) Validate(_ context.Context) error {
+	for _, al := range r.additional {
+		if al.config.certFile == "" {
+			continue
+		}
+		if _, err := al.config.keyPair(); err != nil {
+			return fmt.Errorf("grpc server: listener %s: %w", al.config.name, err)
+		}
+	}
+
+	for name := range r.serverEngine.GetServiceInfo() {
+		if !builtinService(name) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("grpc server: no service registered")
+}
+
+// builtinService report whether service is registered by the server or its tooling instead of the application,
+// i.e. health and reflection
+func builtinService(name string) bool {
+	return name == grpc_health_v1.Health_ServiceDesc.ServiceName || strings.HasPrefix(name, "grpc.reflection.")
+}
@@ -0,0 +1,39 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TixiaOTA/gokit/abstract"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestValidateIgnoresBuiltinServices(t *testing.T) {
+	if err := New(fakeService{}, SetTCPPort(0)).(*rpc).Validate(context.Background()); err == nil {
+		t.Errorf("expected health service alone rejected")
+	}
+
+	if err := New(internalService{}, SetTCPPort(0)).(*rpc).Validate(context.Background()); err != nil {
+		t.Errorf("expected registered service accepted, got %v", err)
+	}
+}
+
+type healthService struct{ fakeService }
+
+func (healthService) GRPCHandler() abstract.GRPCHandler { return healthHandler{} }
+
+type healthHandler struct{}
+
+func (healthHandler) Register(server *grpc.Server) {
+	grpc_health_v1.RegisterHealthServer(server, health.NewServer())
+}
+
+func TestServiceHealthServerKept(t *testing.T) {
+	// a second registration of the health service exits the process
+	srv := New(healthService{}, SetTCPPort(0), WithAdditionalListener(ListenerAddr("127.0.0.1:0"))).(*rpc)
+	if _, ok := srv.serverEngine.GetServiceInfo()[grpc_health_v1.Health_ServiceDesc.ServiceName]; !ok {
+		t.Error("expected health service registered")
+	}
+}
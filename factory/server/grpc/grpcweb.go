@@ -0,0 +1,95 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/improbable-eng/grpc-web/go/grpcweb"
+)
+
+// defaultGRPCWebHeaders request headers allowed on grpc-web cors preflight
+var defaultGRPCWebHeaders = []string{
+	"content-type",
+	"x-grpc-web",
+	"x-user-agent",
+	"grpc-timeout",
+	"authorization",
+	metadataRequestId,
+	MetadataAPIVersion,
+}
+
+// newGRPCWebServer wrap grpc server engine with grpc-web translator served on a companion http port,
+// the wrapped server share the same interceptors and registered handlers
+func (r *rpc) newGRPCWebServer() *http.Server {
+	wrapped := grpcweb.WrapServer(
+		r.serverEngine,
+		grpcweb.WithOriginFunc(allowOrigin(r.opt.grpcWebOrigins)),
+		grpcweb.WithAllowedRequestHeaders(append(append([]string{}, defaultGRPCWebHeaders...), r.opt.grpcWebHeaders...)),
+		grpcweb.WithWebsockets(r.opt.grpcWebWebsockets),
+		grpcweb.WithWebsocketOriginFunc(func(req *http.Request) bool {
+			return allowOrigin(r.opt.grpcWebOrigins)(req.Header.Get("Origin"))
+		}),
+	)
+
+	addr := r.opt.tcpHost + ":" + strings.TrimPrefix(r.opt.grpcWebPort, ":")
+	logger.GreenBold(fmt.Sprintf("⇨ GRPC-WEB server run at %s\n", addr))
+
+	return &http.Server{
+		Addr: addr,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			// handle grpc-web request and its cors preflight
+			if wrapped.IsGrpcWebRequest(req) || wrapped.IsAcceptableGrpcCorsRequest(req) || wrapped.IsGrpcWebSocketRequest(req) {
+				wrapped.ServeHTTP(w, withGRPCWebListener(req))
+				return
+			}
+
+			http.NotFound(w, req)
+		}),
+	}
+}
+
+// withGRPCWebListener tag req with GRPCWebListener, surfaced as peer.Peer.LocalAddr for method visibility
+func withGRPCWebListener(req *http.Request) *http.Request {
+	local, ok := req.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	if !ok {
+		local = &net.TCPAddr{}
+	}
+	return req.WithContext(context.WithValue(req.Context(), http.LocalAddrContextKey, listenerAddr{Addr: local, name: GRPCWebListener}))
+}
+
+func (r *rpc) serveGRPCWeb() {
+	if r.webServer == nil {
+		return
+	}
+
+	if err := r.webServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("grpc-web server: %s", err)
+	}
+}
+
+func (r *rpc) shutdownGRPCWeb(ctx context.Context) {
+	if r.webServer == nil {
+		return
+	}
+
+	_ = r.webServer.Shutdown(ctx)
+}
+
+// allowOrigin allow the listed origins, "*" allows all origins and an empty list denies every origin
+func allowOrigin(origins []string) func(origin string) bool {
+	return func(origin string) bool {
+		for _, o := range origins {
+			if o == "*" || strings.EqualFold(o, origin) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
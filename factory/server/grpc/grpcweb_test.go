@@ -0,0 +1,128 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newGRPCWebTest(t *testing.T, opts ...OptionFunc) *httptest.Server {
+	t.Helper()
+
+	srv := New(internalService{}, append([]OptionFunc{SetTCPHost("127.0.0.1"), SetTCPPort(0)}, opts...)...).(*rpc)
+	t.Cleanup(func() { srv.Shutdown(context.Background()) })
+
+	web := httptest.NewServer(srv.webServer.Handler)
+	t.Cleanup(web.Close)
+	return web
+}
+
+// preflight send a cors preflight for health Check from origin requesting headers
+func preflight(t *testing.T, web *httptest.Server, origin string, headers ...string) *http.Response {
+	t.Helper()
+
+	req, _ := http.NewRequest(http.MethodOptions, web.URL+"/grpc.health.v1.Health/Check", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", strings.Join(headers, ","))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestGRPCWebOrigins(t *testing.T) {
+	cases := []struct {
+		name    string
+		origins []string
+		origin  string
+		allowed bool
+	}{
+		{name: "empty list denies", origins: nil, origin: "https://app.example.com"},
+		{name: "listed origin", origins: []string{"https://app.example.com"}, origin: "https://APP.example.com", allowed: true},
+		{name: "unlisted origin", origins: []string{"https://app.example.com"}, origin: "https://evil.example.com"},
+		{name: "wildcard", origins: []string{"*"}, origin: "https://evil.example.com", allowed: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			web := newGRPCWebTest(t, WithGRPCWeb(":0", c.origins))
+
+			resp := preflight(t, web, c.origin, "content-type", "x-grpc-web")
+			got := resp.Header.Get("Access-Control-Allow-Origin")
+			if c.allowed && got != c.origin {
+				t.Errorf("expected origin %q allowed, got %q", c.origin, got)
+			}
+			if !c.allowed && got != "" {
+				t.Errorf("expected origin %q denied, got %q", c.origin, got)
+			}
+		})
+	}
+}
+
+func TestGRPCWebHeaders(t *testing.T) {
+	const origin = "https://app.example.com"
+
+	t.Run("default headers", func(t *testing.T) {
+		web := newGRPCWebTest(t, WithGRPCWeb(":0", []string{origin}))
+
+		resp := preflight(t, web, origin, "content-type", "x-grpc-web", metadataRequestId, MetadataAPIVersion)
+		if resp.Header.Get("Access-Control-Allow-Origin") != origin {
+			t.Errorf("expected default headers allowed, got %v", resp.Header)
+		}
+	})
+
+	t.Run("unlisted header", func(t *testing.T) {
+		web := newGRPCWebTest(t, WithGRPCWeb(":0", []string{origin}))
+
+		resp := preflight(t, web, origin, "content-type", "x-grpc-web", "x-tenant")
+		if resp.StatusCode == http.StatusNotFound {
+			t.Fatal("expected preflight handled by grpc-web cors")
+		}
+		if got := resp.Header.Get("Access-Control-Allow-Headers"); strings.Contains(strings.ToLower(got), "x-tenant") {
+			t.Errorf("expected x-tenant not allowed, got %q", got)
+		}
+		if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("expected preflight with unlisted header refused, got origin %q", got)
+		}
+	})
+
+	t.Run("extra header", func(t *testing.T) {
+		web := newGRPCWebTest(t, WithGRPCWeb(":0", []string{origin}), WithGRPCWebHeaders("x-tenant"))
+
+		resp := preflight(t, web, origin, "content-type", "x-grpc-web", "x-tenant")
+		if got := resp.Header.Get("Access-Control-Allow-Headers"); !strings.Contains(strings.ToLower(got), "x-tenant") {
+			t.Errorf("expected x-tenant allowed, got %q", got)
+		}
+	})
+}
+
+func TestGRPCWebCall(t *testing.T) {
+	const origin = "https://app.example.com"
+	web := newGRPCWebTest(t, WithGRPCWeb(":0", []string{origin}))
+
+	// grpc-web unary call of an empty HealthCheckRequest
+	req, _ := http.NewRequest(http.MethodPost, web.URL+"/grpc.health.v1.Health/Check", bytes.NewReader([]byte{0, 0, 0, 0, 0}))
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+	req.Header.Set("Origin", origin)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != origin {
+		t.Errorf("expected cors origin %q on response, got %q", origin, got)
+	}
+	if got := resp.Header.Get("Grpc-Status"); got != "" && got != "0" {
+		t.Errorf("expected health check ok, got status %q", got)
+	}
+}
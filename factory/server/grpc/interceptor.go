@@ -3,6 +3,7 @@ package grpc
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -16,6 +17,7 @@ import (
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
@@ -24,6 +26,11 @@ type interceptor struct {
 	serviceName string
 	host        string
 	opt         *option
+	maintenance *maintenance
+	bulkheads   *bulkheads
+	peerLimiter *peerLimiter
+	serviceInfo metadata.MD
+	slowRPC     *slowRPC
 }
 
 // newInterceptor init an instance interceptor
@@ -59,10 +66,11 @@ func (i *interceptor) unaryServerTracerInterceptor(
 	info *grpc.UnaryServerInfo,
 	handler grpc.UnaryHandler,
 ) (resp interface{}, err error) {
-	start := time.Now()
+	start := logger.Now()
+	var handlerDuration time.Duration
 
 	dl := logger.DataLogger{
-		RequestId:     logger.GetRequestId(ctx),
+		RequestId:     requestIdFromContext(ctx),
 		Type:          logger.ServiceType(string(types.GRPC)),
 		Service:       i.serviceName,
 		Host:          i.host,
@@ -78,9 +86,16 @@ func (i *interceptor) unaryServerTracerInterceptor(
 			err = status.Errorf(codes.Aborted, "%s", r)
 		}
 		var sc = http.StatusOK
-		if err != nil {
-			switch er := err.(type) {
-			case *errorkit.ErrorResponse:
+		if ce, ok := types.AsCodedError(err); ok {
+			sc = ce.HTTPStatus()
+			// already mapped with details by unaryServerErrorInterceptor
+			if _, mapped := err.(*mappedError); !mapped {
+				err = ce.GRPCStatus().Err()
+			}
+		} else if err != nil {
+			var er *errorkit.ErrorResponse
+			switch {
+			case errors.As(err, &er):
 				sc = er.StatusCode()
 			default:
 				c := status.Code(err)
@@ -126,13 +141,22 @@ func (i *interceptor) unaryServerTracerInterceptor(
 		trace.SetTag("request_id", dl.RequestId)
 		trace.SetTag("trace_id", tracer.GetTraceID(ctx))
 		trace.Finish()
+		// before Finalize, which takes the context logger messages
+		i.slowRPC.observe(ctx, info.FullMethod, dl.RequestId, start, logger.Now().Sub(start), err)
 		dl.Finalize(ctx)
-		monitoring.PrometheusRecord(dl.StatusCode, dl.RequestMethod, dl.Endpoint, dl.Service, time.Since(dl.TimeStart))
+		monitoring.PrometheusRecord(dl.StatusCode, dl.RequestMethod, dl.Endpoint, dl.Service, logger.Now().Sub(dl.TimeStart))
+		i.setTrailer(ctx, dl.RequestId, handlerDuration, logger.Now().Sub(start))
 	}()
 
 	lock := new(logger.Locker)
 	ctx = context.WithValue(ctx, logger.LogKey, lock)
 	lock.Set(logger.RequestId, dl.RequestId)
+	setAccessLogRequestId(ctx, dl.RequestId)
+	logger.SetMetadata(ctx, allowlistedMetadata(ctx, i.opt.metadataKeys))
+	if i.opt.incomingBaggage && trustedPeer(ctx, i.opt.baggagePeers) {
+		logger.RestoreBaggage(ctx, incomingBaggage(ctx))
+	}
+	setSpanRequestId(ctx, dl.RequestId)
 
 	reqBody, _ := json.Marshal(req)
 	if len(reqBody) > 1000 {
@@ -141,6 +165,8 @@ func (i *interceptor) unaryServerTracerInterceptor(
 		trace.Log("request.body", len(reqBody))
 	}
 
+	ctx, hs := withHandlerStart(ctx)
 	resp, err = handler(ctx, req)
+	handlerDuration = hs.elapsed()
 	return
 }
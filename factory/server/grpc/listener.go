@@ -0,0 +1,136 @@
+package grpc
+
+import (
+	"crypto/tls"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// ListenerOption setter of additional listener, see WithAdditionalListener
+type ListenerOption func(*listenerConfig)
+
+// listenerConfig additional listener
+type listenerConfig struct {
+	name string
+	addr string
+	tls  *tls.Config
+
+	// certificate files loaded when listening and checked by Validate, see ListenerTLSFiles
+	certFile string
+	keyFile  string
+}
+
+// ListenerName set name of listener used by WithMethodVisibility, default to its address
+func ListenerName(name string) ListenerOption {
+	return func(c *listenerConfig) {
+		c.name = name
+	}
+}
+
+// ListenerAddr set address of listener, e.g. "127.0.0.1:6061" or "[::]:6061"
+func ListenerAddr(addr string) ListenerOption {
+	return func(c *listenerConfig) {
+		c.addr = addr
+	}
+}
+
+// ListenerTLS serve listener with TLS, set ClientAuth and ClientCAs of config for mTLS
+func ListenerTLS(config *tls.Config) ListenerOption {
+	return func(c *listenerConfig) {
+		c.tls = config
+	}
+}
+
+// ListenerTLSFiles serve listener with TLS of the PEM certificate and key files, loaded when the server starts
+// listening and checked by Validate, combine with ListenerTLS to set ClientAuth and ClientCAs for mTLS
+func ListenerTLSFiles(certFile, keyFile string) ListenerOption {
+	return func(c *listenerConfig) {
+		c.certFile = certFile
+		c.keyFile = keyFile
+	}
+}
+
+// secure report whether listener serves TLS
+func (c listenerConfig) secure() bool {
+	return c.tls != nil || c.certFile != ""
+}
+
+// keyPair load certificate of ListenerTLSFiles
+func (c listenerConfig) keyPair() (tls.Certificate, error) {
+	return tls.LoadX509KeyPair(c.certFile, c.keyFile)
+}
+
+// additionalListener listener served next to the main listener, plaintext listeners share the server engine,
+// TLS listeners get their own server with the same interceptors and handlers since credentials are per server
+type additionalListener struct {
+	config   listenerConfig
+	server   *grpc.Server
+	listener net.Listener
+	// cert of ListenerTLSFiles, loaded before the listener is opened
+	cert *tls.Certificate
+}
+
+// newAdditionalListeners create server of each TLS listener with serverOptions and register
+func newAdditionalListeners(configs []listenerConfig, engine *grpc.Server, serverOptions []grpc.ServerOption, register func(*grpc.Server)) []*additionalListener {
+	listeners := make([]*additionalListener, 0, len(configs))
+	for _, config := range configs {
+		al := &additionalListener{config: config, server: engine}
+		if config.secure() {
+			tlsConfig := config.tls.Clone()
+			if tlsConfig == nil {
+				tlsConfig = &tls.Config{}
+			}
+			if config.certFile != "" {
+				tlsConfig.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+					return al.cert, nil
+				}
+			}
+
+			options := append(append([]grpc.ServerOption(nil), serverOptions...), grpc.Creds(credentials.NewTLS(tlsConfig)))
+			al.server = grpc.NewServer(options...)
+			register(al.server)
+		}
+
+		listeners = append(listeners, al)
+	}
+
+	return listeners
+}
+
+// listenAdditional load certificate files and open every additional listener, already opened listeners are closed on failure
+func (r *rpc) listenAdditional() error {
+	r.listenerMu.Lock()
+	defer r.listenerMu.Unlock()
+
+	for i, al := range r.additional {
+		if err := al.listen(); err != nil {
+			for _, opened := range r.additional[:i] {
+				_ = opened.listener.Close()
+				opened.listener = nil
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (al *additionalListener) listen() error {
+	if al.config.certFile != "" {
+		cert, err := al.config.keyPair()
+		if err != nil {
+			return err
+		}
+		al.cert = &cert
+	}
+
+	l, err := net.Listen("tcp", al.config.addr)
+	if err != nil {
+		return err
+	}
+
+	al.listener = l
+	return nil
+}
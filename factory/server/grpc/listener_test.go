@@ -0,0 +1,193 @@
+package grpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/abstract"
+	"github.com/TixiaOTA/gokit/factory"
+	"github.com/TixiaOTA/gokit/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type fakeService struct{}
+
+func (fakeService) Name() string                                           { return "test" }
+func (fakeService) GetApplications() map[string]factory.ApplicationFactory { return nil }
+func (fakeService) RESTHandler() abstract.RestHandler                      { return nil }
+func (fakeService) GRPCHandler() abstract.GRPCHandler                      { return nil }
+func (fakeService) BrokerHandler(types.Broker) abstract.BrokerHandler      { return nil }
+func (fakeService) GetBroker(types.Broker) abstract.Broker                 { return nil }
+
+// selfSignedTLS server TLS config of a certificate valid for 127.0.0.1 and its pool
+func selfSignedTLS(t *testing.T) (*tls.Config, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}, pool
+}
+
+func TestAdditionalListeners(t *testing.T) {
+	serverTLS, pool := selfSignedTLS(t)
+
+	srv := New(fakeService{},
+		SetTCPHost("127.0.0.1"), SetTCPPort(0),
+		WithAdditionalListener(ListenerAddr("127.0.0.1:0")),
+		WithAdditionalListener(ListenerAddr("127.0.0.1:0"), ListenerTLS(serverTLS)),
+	).(*rpc)
+	go srv.Serve()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(srv.Addr()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	addrs := srv.Addr()
+	if len(addrs) != 3 {
+		t.Fatalf("expected 3 bound addresses, got %v", addrs)
+	}
+
+	creds := []credentials.TransportCredentials{
+		insecure.NewCredentials(),
+		insecure.NewCredentials(),
+		credentials.NewTLS(&tls.Config{RootCAs: pool}),
+	}
+	for i, addr := range addrs {
+		conn, err := grpc.NewClient(addr.String(), grpc.WithTransportCredentials(creds[i]))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		resp, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+		cancel()
+		_ = conn.Close()
+
+		if err != nil || resp.GetStatus() != grpc_health_v1.HealthCheckResponse_SERVING {
+			t.Errorf("listener %s: expected serving, got %v %v", addr, resp, err)
+		}
+	}
+
+	srv.Shutdown(context.Background())
+
+	for _, addr := range addrs {
+		if conn, err := net.DialTimeout("tcp", addr.String(), 200*time.Millisecond); err == nil {
+			_ = conn.Close()
+			t.Errorf("expected listener %s closed after shutdown", addr)
+		}
+	}
+}
+
+// writeKeyPair write certificate and key of config as PEM files
+func writeKeyPair(t *testing.T, config *tls.Config) (certFile, keyFile string) {
+	t.Helper()
+
+	cert := config.Certificates[0]
+	key, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	if err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestListenerTLSFiles(t *testing.T) {
+	serverTLS, pool := selfSignedTLS(t)
+	certFile, keyFile := writeKeyPair(t, serverTLS)
+
+	garbage := filepath.Join(t.TempDir(), "garbage.pem")
+	if err := os.WriteFile(garbage, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name      string
+		cert, key string
+		valid     bool
+	}{
+		{"valid", certFile, keyFile, true},
+		{"missing", filepath.Join(t.TempDir(), "missing.crt"), keyFile, false},
+		{"garbage", garbage, keyFile, false},
+		{"mismatched", certFile, garbage, false},
+	} {
+		srv := New(internalService{}, SetTCPPort(0),
+			WithAdditionalListener(ListenerAddr("127.0.0.1:0"), ListenerName("internal"), ListenerTLSFiles(tc.cert, tc.key)),
+		).(*rpc)
+
+		if err := srv.Validate(context.Background()); (err == nil) != tc.valid {
+			t.Errorf("%s: expected valid %v, got %v", tc.name, tc.valid, err)
+		}
+	}
+
+	srv := New(fakeService{},
+		SetTCPHost("127.0.0.1"), SetTCPPort(0),
+		WithAdditionalListener(ListenerAddr("127.0.0.1:0"), ListenerTLSFiles(certFile, keyFile)),
+	).(*rpc)
+	go srv.Serve()
+	defer srv.Shutdown(context.Background())
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(srv.Addr()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	addrs := srv.Addr()
+	if len(addrs) != 2 {
+		t.Fatalf("expected 2 bound addresses, got %v", addrs)
+	}
+
+	conn, err := grpc.NewClient(addrs[1].String(), grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: pool})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if resp, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{}); err != nil || resp.GetStatus() != grpc_health_v1.HealthCheckResponse_SERVING {
+		t.Errorf("expected TLS listener of certificate files serving, got %v %v", resp, err)
+	}
+}
@@ -0,0 +1,113 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// healthServicePrefix methods of health service always served during maintenance
+	healthServicePrefix = "/grpc.health.v1.Health/"
+	// headerMaintenanceReason metadata carrying reason of maintenance
+	headerMaintenanceReason = "x-maintenance-reason"
+)
+
+// MaintenanceController control maintenance mode of grpc server, implemented by the grpc ApplicationFactory
+type MaintenanceController interface {
+	// EnterMaintenance refuse all non-health RPC with UNAVAILABLE and retry pushback
+	EnterMaintenance(reason string, retryAfter time.Duration)
+	// ExitMaintenance serve traffic again
+	ExitMaintenance()
+}
+
+// maintenanceState current maintenance of server
+type maintenanceState struct {
+	reason     string
+	retryAfter time.Duration
+}
+
+// maintenance maintenance mode shared by server and interceptors
+type maintenance struct {
+	state  atomic.Pointer[maintenanceState]
+	health *health.Server
+}
+
+func newMaintenance(hs *health.Server) *maintenance {
+	return &maintenance{health: hs}
+}
+
+func (m *maintenance) enter(reason string, retryAfter time.Duration) {
+	m.state.Store(&maintenanceState{reason: reason, retryAfter: retryAfter})
+	m.health.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+}
+
+func (m *maintenance) exit() {
+	m.state.Store(nil)
+	m.health.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+}
+
+// refuse error returned to non-health RPC during maintenance, nil when serving
+func (m *maintenance) refuse(ctx context.Context, method string) error {
+	st := m.state.Load()
+	if st == nil || strings.HasPrefix(method, healthServicePrefix) {
+		return nil
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(headerMaintenanceReason, st.reason))
+
+	s, err := status.New(codes.Unavailable, "service under maintenance: "+st.reason).
+		WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(st.retryAfter)})
+	if err != nil {
+		return status.Error(codes.Unavailable, "service under maintenance: "+st.reason)
+	}
+
+	return s.Err()
+}
+
+// EnterMaintenance refuse all non-health RPC with UNAVAILABLE carrying RetryInfo of retryAfter,
+// built-in health service reports NOT_SERVING, a health server registered by the service is left as is
+func (r *rpc) EnterMaintenance(reason string, retryAfter time.Duration) {
+	r.maintenance.enter(reason, retryAfter)
+}
+
+// ExitMaintenance serve traffic again, health service reports SERVING
+func (r *rpc) ExitMaintenance() {
+	r.maintenance.exit()
+}
+
+func (i *interceptor) unaryServerMaintenanceInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	if err := i.maintenance.refuse(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (i *interceptor) streamServerMaintenanceInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	if err := i.maintenance.refuse(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+
+	return handler(srv, ss)
+}
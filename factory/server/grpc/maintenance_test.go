@@ -0,0 +1,59 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	hs := health.NewServer()
+	srv := &rpc{maintenance: newMaintenance(hs)}
+	i := &interceptor{opt: &option{}, maintenance: srv.maintenance}
+
+	called := 0
+	handler := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		called++
+		return "ok", nil
+	}
+	order := &grpc.UnaryServerInfo{FullMethod: "/order.OrderService/Get"}
+
+	srv.EnterMaintenance("database migration", 2*time.Minute)
+
+	_, err := i.unaryServerMaintenanceInterceptor(context.Background(), nil, order, handler)
+	st := status.Convert(err)
+	if st.Code() != codes.Unavailable || called != 0 {
+		t.Fatalf("expected UNAVAILABLE without calling handler, got %s", st.Code())
+	}
+
+	var retry *errdetails.RetryInfo
+	for _, d := range st.Details() {
+		if ri, ok := d.(*errdetails.RetryInfo); ok {
+			retry = ri
+		}
+	}
+	if retry == nil || retry.RetryDelay.AsDuration() != 2*time.Minute {
+		t.Errorf("expected retry info of 2m, got %v", st.Details())
+	}
+
+	// health methods still respond
+	check := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+	resp, err := i.unaryServerMaintenanceInterceptor(context.Background(), &grpc_health_v1.HealthCheckRequest{}, check, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return hs.Check(ctx, req.(*grpc_health_v1.HealthCheckRequest))
+	})
+	if err != nil || resp.(*grpc_health_v1.HealthCheckResponse).Status != grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("expected health NOT_SERVING during maintenance, got %v %v", resp, err)
+	}
+
+	srv.ExitMaintenance()
+	if _, err = i.unaryServerMaintenanceInterceptor(context.Background(), nil, order, handler); err != nil || called != 1 {
+		t.Errorf("expected traffic served after maintenance, got %v", err)
+	}
+}
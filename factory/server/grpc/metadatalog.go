@@ -0,0 +1,138 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+const (
+	// maxMetadataValueLen maximum runes of logged metadata value
+	maxMetadataValueLen = 128
+	// metadataAuthorization value reduced to scheme and last 4 characters
+	metadataAuthorization = "authorization"
+)
+
+// allowlistedMetadata incoming metadata of allowlisted keys, absent keys are omitted
+func allowlistedMetadata(ctx context.Context, keys []string) map[string]string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(keys) < 1 {
+		return nil
+	}
+
+	fields := make(map[string]string, len(keys))
+	for _, key := range keys {
+		key = strings.ToLower(key)
+
+		vals := md.Get(key)
+		if len(vals) < 1 {
+			continue
+		}
+
+		val := strings.Join(vals, ",")
+		if key == metadataAuthorization {
+			val = redactAuthorization(val)
+		}
+
+		fields[key] = truncateMetadata(val)
+	}
+
+	if len(fields) < 1 {
+		return nil
+	}
+
+	return fields
+}
+
+// redactAuthorization keep scheme and last 4 characters of credential, e.g. "Bearer ****abcd"
+func redactAuthorization(val string) string {
+	scheme, credential, found := strings.Cut(strings.TrimSpace(val), " ")
+	if !found {
+		scheme, credential = "", scheme
+	}
+
+	if len(credential) > 4 {
+		credential = credential[len(credential)-4:]
+	}
+
+	return strings.TrimSpace(scheme + " ****" + credential)
+}
+
+func truncateMetadata(val string) string {
+	if r := []rune(val); len(r) > maxMetadataValueLen {
+		return string(r[:maxMetadataValueLen])
+	}
+
+	return val
+}
+
+// incomingBaggage baggage prefixed keys of incoming metadata, see logger.RestoreBaggage
+func incomingBaggage(ctx context.Context) map[string]string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	var baggage map[string]string
+	for key, vals := range md {
+		if !strings.HasPrefix(key, logger.BaggagePrefix) || len(vals) < 1 {
+			continue
+		}
+
+		if baggage == nil {
+			baggage = make(map[string]string)
+		}
+		baggage[key] = vals[0]
+	}
+
+	return baggage
+}
+
+// parsePeerNets parse CIDRs and IPs, invalid entries are skipped
+func parsePeerNets(cidrs []string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		if !strings.Contains(c, "/") {
+			if ip := net.ParseIP(c); ip != nil && ip.To4() != nil {
+				c += "/32"
+			} else {
+				c += "/128"
+			}
+		}
+
+		if _, ipNet, err := net.ParseCIDR(c); err == nil {
+			nets = append(nets, ipNet)
+		}
+	}
+
+	return nets
+}
+
+// trustedPeer report whether the peer of ctx is within nets, every peer when nets is empty
+func trustedPeer(ctx context.Context, nets []*net.IPNet) bool {
+	if len(nets) < 1 {
+		return true
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	ip := net.ParseIP(host)
+	for _, n := range nets {
+		if ip != nil && n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
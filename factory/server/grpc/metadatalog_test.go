@@ -0,0 +1,97 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestMetadataLogging(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	i := &interceptor{opt: &option{
+		accessLog:    &logger.Logger{Logger: zap.New(core)},
+		metadataKeys: []string{"Authorization", "client-version", "x-tenant-id", "x-missing"},
+	}}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"authorization", "Bearer secret-token-abcd",
+		"client-version", "1.2.3",
+		"x-tenant-id", strings.Repeat("t", 200),
+		"cookie", "session=secret",
+	))
+
+	_, _ = i.unaryServerAccessLogInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return nil, nil
+	})
+
+	entries := logs.AllUntimed()
+	if len(entries) != 1 {
+		t.Fatalf("expected one access log line, got %d", len(entries))
+	}
+
+	md, ok := entries[0].ContextMap()["metadata"].(map[string]string)
+	if !ok {
+		t.Fatalf("expected metadata field on access log, got %v", entries[0].ContextMap())
+	}
+
+	if md["authorization"] != "Bearer ****abcd" || md["client-version"] != "1.2.3" {
+		t.Errorf("unexpected metadata %v", md)
+	}
+
+	if len(md["x-tenant-id"]) != maxMetadataValueLen {
+		t.Errorf("expected value truncated to %d, got %d", maxMetadataValueLen, len(md["x-tenant-id"]))
+	}
+
+	for _, key := range []string{"cookie", "x-missing"} {
+		if _, exist := md[key]; exist {
+			t.Errorf("expected %s omitted, got %v", key, md)
+		}
+	}
+}
+
+func TestIncomingBaggage(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}
+	tenant := func(opts []OptionFunc, from string) string {
+		opt := defaultOption()
+		for _, o := range opts {
+			o(&opt)
+		}
+		i := &interceptor{opt: &opt}
+
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("baggage-tenant-id", "acme"))
+		ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(from), Port: 5000}})
+
+		var got string
+		_, _ = i.unaryServerTracerInterceptor(ctx, wrapperspb.String("req"), info, func(ctx context.Context, _ interface{}) (interface{}, error) {
+			got = logger.GetBaggage(ctx)["tenant_id"]
+			return wrapperspb.String("ok"), nil
+		})
+		return got
+	}
+
+	for _, tc := range []struct {
+		name string
+		opts []OptionFunc
+		from string
+		want string
+	}{
+		{"disabled", nil, "10.0.0.1", ""},
+		{"any peer", []OptionFunc{WithIncomingBaggage()}, "203.0.113.7", "acme"},
+		{"trusted peer", []OptionFunc{WithIncomingBaggage("10.0.0.0/8")}, "10.0.0.1", "acme"},
+		{"untrusted peer", []OptionFunc{WithIncomingBaggage("10.0.0.0/8")}, "203.0.113.7", ""},
+	} {
+		if got := tenant(tc.opts, tc.from); got != tc.want {
+			t.Errorf("%s: expected tenant %q, got %q", tc.name, tc.want, got)
+		}
+	}
+}
@@ -1,9 +1,18 @@
 package grpc
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"net"
+	"time"
 
+	"github.com/TixiaOTA/gokit/logger"
 	"github.com/TixiaOTA/gokit/utils/env"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
 )
 
 // OptionFunc setter to set grpc option
@@ -13,11 +22,94 @@ type OptionFunc func(*option)
 type option struct {
 	tcpPort string
 	tcpHost string
+
+	// defaultDeadline applied when an incoming RPC arrives without deadline
+	defaultDeadline  time.Duration
+	warnNearDeadline bool
+
+	// grpc-web companion http server
+	grpcWebPort       string
+	grpcWebOrigins    []string
+	grpcWebHeaders    []string
+	grpcWebWebsockets bool
+
+	// OpenTelemetry instrumentation
+	otelTracerProvider trace.TracerProvider
+	otelPropagators    propagation.TextMapPropagator
+
+	// maintenance mode at startup
+	maintenanceReason     string
+	maintenanceRetryAfter time.Duration
+
+	// per method concurrency isolation
+	bulkheads       map[string]bulkheadConfig
+	defaultBulkhead *bulkheadConfig
+
+	// request id and server timing on trailing metadata
+	disableTrailers        bool
+	trailerRequestIdKey    string
+	trailerServerTimingKey string
+
+	// domain of errdetails.ErrorInfo on mapped errors, default to service name
+	errorDomain string
+
+	// warm-up run by Serve before the listener is opened
+	warmup        func(ctx context.Context) error
+	warmupTimeout time.Duration
+
+	// incoming metadata keys written on the request log
+	metadataKeys []string
+
+	// restore baggage-* metadata of peers on the request context, see WithIncomingBaggage
+	incomingBaggage bool
+	baggagePeers    []*net.IPNet
+
+	// checksum of allowlisted requests, debugging retries and hedging
+	requestChecksums *requestChecksums
+
+	// listeners served next to the main listener
+	additionalListeners []listenerConfig
+
+	// allowed method patterns per listener name
+	methodVisibility methodVisibility
+
+	// supported api versions of x-api-version metadata
+	apiVersionPolicy   *apiVersionPolicy
+	apiVersionRequired bool
+
+	// codecs registered next to proto, picked by content-subtype
+	codecs []encoding.Codec
+
+	// access log, one line per completed RPC
+	accessLog         *logger.Logger
+	accessLogSampling map[string]float64
+
+	// diagnostics of RPC exceeding a latency budget
+	slowRPC *slowRPCConfig
+
+	// concurrent calls per peer, identity defaults to the peer address
+	perPeerStreamLimit int
+	peerIdentity       func(ctx context.Context) string
+
+	// authentication run before the per peer limit, see WithAuth
+	authUnary  grpc.UnaryServerInterceptor
+	authStream grpc.StreamServerInterceptor
 }
 
 func defaultOption() option {
 	return option{
-		tcpPort: fmt.Sprintf(":%d", env.GetInteger("GRPC_PORT", 6060)),
+		tcpPort:          fmt.Sprintf(":%d", env.GetInteger("GRPC_PORT", 6060)),
+		defaultDeadline:  env.GetDuration("GRPC_DEFAULT_DEADLINE", 0),
+		warnNearDeadline: env.GetBool("GRPC_WARN_NEAR_DEADLINE"),
+
+		disableTrailers:        env.GetBool("GRPC_DISABLE_TRAILERS"),
+		trailerRequestIdKey:    defaultTrailerRequestId,
+		trailerServerTimingKey: defaultTrailerServerTiming,
+
+		maintenanceReason:     env.GetString("GRPC_MAINTENANCE_REASON"),
+		maintenanceRetryAfter: env.GetDuration("GRPC_MAINTENANCE_RETRY_AFTER", 30*time.Second),
+
+		perPeerStreamLimit: env.GetInteger("GRPC_PER_PEER_STREAM_LIMIT", 0),
 	}
 }
 
@@ -33,3 +125,269 @@ func SetTCPHost(host string) OptionFunc {
 		o.tcpHost = host
 	}
 }
+
+// SetDefaultDeadline set default deadline for incoming RPC without deadline
+func SetDefaultDeadline(d time.Duration) OptionFunc {
+	return func(o *option) {
+		o.defaultDeadline = d
+	}
+}
+
+// SetWarnNearDeadline log a warning when handler returns with less than 5% of the deadline budget left
+func SetWarnNearDeadline(warn bool) OptionFunc {
+	return func(o *option) {
+		o.warnNearDeadline = warn
+	}
+}
+
+// WithGRPCWeb serve grpc-web on a companion http port with cors for the allowed origins,
+// empty allowed origins denies every cross origin request, use "*" to allow all origins
+func WithGRPCWeb(port string, allowedOrigins []string) OptionFunc {
+	return func(o *option) {
+		o.grpcWebPort = port
+		o.grpcWebOrigins = allowedOrigins
+	}
+}
+
+// WithGRPCWebHeaders allow extra request headers on grpc-web cors preflight besides the default ones
+func WithGRPCWebHeaders(headers ...string) OptionFunc {
+	return func(o *option) {
+		o.grpcWebHeaders = append(o.grpcWebHeaders, headers...)
+	}
+}
+
+// WithGRPCWebWebsockets enable grpc-web over websockets for streaming methods
+func WithGRPCWebWebsockets(enabled bool) OptionFunc {
+	return func(o *option) {
+		o.grpcWebWebsockets = enabled
+	}
+}
+
+// WithOpenTelemetry install OpenTelemetry instrumentation with the tracer provider and propagators,
+// propagators default to the global propagator when nil
+func WithOpenTelemetry(tp trace.TracerProvider, propagators propagation.TextMapPropagator) OptionFunc {
+	return func(o *option) {
+		o.otelTracerProvider = tp
+		o.otelPropagators = propagators
+	}
+}
+
+// WithAccessLog write one access log line per completed RPC into log,
+// info for OK and warn/error for failures
+func WithAccessLog(log *logger.Logger) OptionFunc {
+	return func(o *option) {
+		o.accessLog = log
+	}
+}
+
+// SetAccessLogSampling sample successful RPC of high-QPS method on access log with rate between 0 and 1,
+// failed RPC are always logged
+func SetAccessLogSampling(method string, rate float64) OptionFunc {
+	return func(o *option) {
+		if o.accessLogSampling == nil {
+			o.accessLogSampling = make(map[string]float64)
+		}
+
+		o.accessLogSampling[method] = rate
+	}
+}
+
+// WithSlowRPCDiagnostics write one "slow_rpc" entry for unary RPC exceeding threshold with goroutine count,
+// GC pause since start and the context logger messages of the request, see SetSlowRPCLogger and WithSlowRPCTrace
+func WithSlowRPCDiagnostics(threshold time.Duration, opts ...SlowRPCOption) OptionFunc {
+	return func(o *option) {
+		o.slowRPC = &slowRPCConfig{threshold: threshold}
+		for _, opt := range opts {
+			opt(o.slowRPC)
+		}
+	}
+}
+
+// SetMaintenance start server on maintenance mode with reason and retry pushback,
+// see MaintenanceController to toggle it at runtime
+func SetMaintenance(reason string, retryAfter time.Duration) OptionFunc {
+	return func(o *option) {
+		o.maintenanceReason = reason
+		o.maintenanceRetryAfter = retryAfter
+	}
+}
+
+// WithBulkhead limit concurrent calls of method (full method, e.g. /pkg.Service/Method),
+// excess calls wait up to queueTimeout for a permit or get ResourceExhausted, zero queueTimeout rejects immediately.
+// maxConcurrent <= 0 means no bulkhead, the method is then exempt from WithDefaultBulkhead too
+func WithBulkhead(method string, maxConcurrent int, queueTimeout time.Duration) OptionFunc {
+	return func(o *option) {
+		if o.bulkheads == nil {
+			o.bulkheads = make(map[string]bulkheadConfig)
+		}
+
+		o.bulkheads[method] = bulkheadConfig{maxConcurrent: maxConcurrent, queueTimeout: queueTimeout}
+	}
+}
+
+// WithDefaultBulkhead limit concurrent calls shared by all methods without their own bulkhead,
+// maxConcurrent <= 0 means no default bulkhead
+func WithDefaultBulkhead(maxConcurrent int, queueTimeout time.Duration) OptionFunc {
+	return func(o *option) {
+		o.defaultBulkhead = &bulkheadConfig{maxConcurrent: maxConcurrent, queueTimeout: queueTimeout}
+	}
+}
+
+// WithPerPeerStreamLimit limit concurrent unary and stream calls of a single peer, excess calls get ResourceExhausted
+// and are counted on grpc_server_peer_streams_rejected_total. zero disables the limit, see PeerStreamLimiter to
+// adjust it at runtime and SetPeerIdentity to key peers by an authenticated identity
+func WithPerPeerStreamLimit(n int) OptionFunc {
+	return func(o *option) {
+		o.perPeerStreamLimit = n
+	}
+}
+
+// SetPeerIdentity identity of the caller of ctx keying WithPerPeerStreamLimit, e.g. the client id set by the
+// interceptors of WithAuth, empty identity falls back to the peer IP
+func SetPeerIdentity(fn func(ctx context.Context) string) OptionFunc {
+	return func(o *option) {
+		o.peerIdentity = fn
+	}
+}
+
+// WithAuth authenticate calls with unary and stream interceptors before the per peer limit and any handler,
+// e.g. to set the identity read by SetPeerIdentity. nil interceptor leaves its kind of call unauthenticated
+func WithAuth(unary grpc.UnaryServerInterceptor, stream grpc.StreamServerInterceptor) OptionFunc {
+	return func(o *option) {
+		o.authUnary = unary
+		o.authStream = stream
+	}
+}
+
+// SetTrailerKeys set trailer keys of request id and server timing, empty key keeps the default
+// x-request-id and x-server-timing
+func SetTrailerKeys(requestIdKey, serverTimingKey string) OptionFunc {
+	return func(o *option) {
+		if requestIdKey != "" {
+			o.trailerRequestIdKey = requestIdKey
+		}
+		if serverTimingKey != "" {
+			o.trailerServerTimingKey = serverTimingKey
+		}
+	}
+}
+
+// DisableTrailers never set request id and server timing on trailing metadata
+func DisableTrailers() OptionFunc {
+	return func(o *option) {
+		o.disableTrailers = true
+	}
+}
+
+// WithMetadataLogging write allowlisted incoming metadata keys on the request log and access log,
+// values are truncated at 128 characters and authorization is reduced to scheme and last 4 characters
+func WithMetadataLogging(keys ...string) OptionFunc {
+	return func(o *option) {
+		o.metadataKeys = append(o.metadataKeys, keys...)
+	}
+}
+
+// WithIncomingBaggage restore baggage-* metadata of requests on the context, propagated to downstream calls.
+// only peers within cidrs (CIDRs or IPs) are restored, every peer when empty, e.g. services reached only
+// through the mesh. baggage of untrusted peers would set tenant and user of downstream calls
+func WithIncomingBaggage(cidrs ...string) OptionFunc {
+	return func(o *option) {
+		o.incomingBaggage = true
+		o.baggagePeers = parsePeerNets(cidrs)
+	}
+}
+
+// SetErrorDomain set domain of errdetails.ErrorInfo attached on returned errors, default to service name
+func SetErrorDomain(domain string) OptionFunc {
+	return func(o *option) {
+		o.errorDomain = domain
+	}
+}
+
+// WithWarmup run fn before the listener accepts traffic, e.g. priming caches and pools,
+// startup fails when fn returns error or does not finish within timeout, zero timeout means unlimited
+func WithWarmup(fn func(ctx context.Context) error, timeout time.Duration) OptionFunc {
+	return func(o *option) {
+		o.warmup = fn
+		o.warmupTimeout = timeout
+	}
+}
+
+// WithAdditionalListener serve the same services on another address, e.g. a mesh-local plaintext port next to
+// an internal mTLS port. plaintext listeners share the server engine, TLS listeners get their own server
+// sharing the interceptor chain and handler registration
+func WithAdditionalListener(opts ...ListenerOption) OptionFunc {
+	return func(o *option) {
+		var config listenerConfig
+		for _, opt := range opts {
+			opt(&config)
+		}
+		if config.name == "" {
+			config.name = config.addr
+		}
+
+		o.additionalListeners = append(o.additionalListeners, config)
+	}
+}
+
+// WithMethodVisibility expose only methods matching allow patterns on listener (MainListener or the name of
+// ListenerName), e.g. "/pkg.Service/Method" or "/pkg.Service/*", other methods get PermissionDenied.
+// listeners without visibility expose every method, GRPCWebListener follows MainListener unless configured
+// and connections of an unknown listener are refused
+func WithMethodVisibility(listener string, allow []string) OptionFunc {
+	return func(o *option) {
+		if o.methodVisibility == nil {
+			o.methodVisibility = make(methodVisibility)
+		}
+
+		patterns := o.methodVisibility[listener]
+		for _, pattern := range allow {
+			patterns = append(patterns, normalizeMethodPattern(pattern))
+		}
+		o.methodVisibility[listener] = patterns
+	}
+}
+
+// WithRequestChecksum attach xxhash checksum of request of methods (full method, e.g. /pkg.Service/Method) on the
+// x-request-checksum trailer and access log, identical requests within window are logged as duplicate
+func WithRequestChecksum(window time.Duration, methods ...string) OptionFunc {
+	return func(o *option) {
+		o.requestChecksums = newRequestChecksums(window, methods)
+	}
+}
+
+// WithAPIVersionPolicy require x-api-version metadata between min and max (semver, e.g. "1.4.0"), requests below min
+// are rejected with FailedPrecondition asking to upgrade, deprecated versions get the message of deprecations on the
+// x-api-deprecation header. request without version is assumed the max version, see WithAPIVersionRequired
+func WithAPIVersionPolicy(min, max string, deprecations map[string]string) OptionFunc {
+	return func(o *option) {
+		policy, err := newAPIVersionPolicy(min, max, deprecations)
+		if err != nil {
+			log.Fatalf("grpc: api version policy: %s", err)
+		}
+
+		o.apiVersionPolicy = policy
+	}
+}
+
+// WithAPIVersionRequired reject request without x-api-version metadata instead of assuming the max version,
+// used with WithAPIVersionPolicy. health and reflection services never require it, probes send no version
+func WithAPIVersionRequired(required bool) OptionFunc {
+	return func(o *option) {
+		o.apiVersionRequired = required
+	}
+}
+
+// WithCodec register codec picked by content-subtype of request (application/grpc+<name>), proto stays the default.
+// registering a codec named "proto" replaces the default codec. the grpc codec registry is process wide,
+// the first codec of a name is kept and applies to every server of the process
+func WithCodec(codec encoding.Codec) OptionFunc {
+	return func(o *option) {
+		o.codecs = append(o.codecs, codec)
+	}
+}
+
+// WithJSONCodec accept application/grpc+json requests, see JSONCodec
+func WithJSONCodec() OptionFunc {
+	return WithCodec(JSONCodec{})
+}
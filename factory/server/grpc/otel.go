@@ -0,0 +1,60 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// metadataRequestId metadata key carrying the request id
+const metadataRequestId = "x-request-id"
+
+// otelServerOption grpc server option installing OpenTelemetry stats handler,
+// spans are named by full method and extract W3C traceparent from incoming metadata
+func (o *option) otelServerOption() grpc.ServerOption {
+	propagators := o.otelPropagators
+	if propagators == nil {
+		propagators = otel.GetTextMapPropagator()
+	}
+
+	return grpc.StatsHandler(otelgrpc.NewServerHandler(
+		otelgrpc.WithTracerProvider(o.otelTracerProvider),
+		otelgrpc.WithPropagators(propagators),
+	))
+}
+
+// requestIdFromContext get request id from incoming metadata,
+// reuse the trace id when the request carries trace context without request id
+func requestIdFromContext(ctx context.Context) string {
+	if requestId := incomingRequestId(ctx); requestId != "" {
+		return requestId
+	}
+
+	return logger.GetRequestId(ctx)
+}
+
+// incomingRequestId request id from incoming metadata or trace context, empty when the request carries none
+func incomingRequestId(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if val := md.Get(metadataRequestId); len(val) > 0 && val[0] != "" {
+			return val[0]
+		}
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+
+	return ""
+}
+
+// setSpanRequestId attach request id as attribute of the active span
+func setSpanRequestId(ctx context.Context, requestId string) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("request_id", requestId))
+}
@@ -0,0 +1,145 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/abstract"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// serverSpan wait for the exported server span named name, the server ends it after the client got the response
+func serverSpan(exp *tracetest.InMemoryExporter, name string) *tracetest.SpanStub {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		spans := exp.GetSpans()
+		for i, s := range spans {
+			if s.Name == name && s.SpanKind == trace.SpanKindServer {
+				return &spans[i]
+			}
+		}
+	}
+	return nil
+}
+
+// failHandler register test.Fail/Ping failing with internal error
+type failHandler struct{}
+
+func (failHandler) Register(s *grpc.Server) {
+	s.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "test.Fail",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Ping",
+			Handler: func(_ interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(emptypb.Empty)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				info := &grpc.UnaryServerInfo{FullMethod: "/test.Fail/Ping"}
+				return interceptor(ctx, in, info, func(context.Context, interface{}) (interface{}, error) {
+					return nil, status.Error(codes.Internal, "boom")
+				})
+			},
+		}},
+	}, struct{}{})
+}
+
+type failService struct{ fakeService }
+
+func (failService) GRPCHandler() abstract.GRPCHandler { return failHandler{} }
+
+func TestOpenTelemetry(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	propagators := propagation.TraceContext{}
+
+	srv := New(failService{}, SetTCPPort(0), WithOpenTelemetry(tp, propagators)).(*rpc)
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = srv.serverEngine.Serve(lis) }()
+	defer srv.serverEngine.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler(otelgrpc.WithTracerProvider(tp), otelgrpc.WithPropagators(propagators))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	t.Run("traceparent", func(t *testing.T) {
+		exp.Reset()
+		ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+		var trailer metadata.MD
+		_, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{}, grpc.Trailer(&trailer))
+		parent.End()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		server := serverSpan(exp, "grpc.health.v1.Health/Check")
+		if server == nil {
+			t.Fatalf("expected server span named by method, got %v", exp.GetSpans())
+		}
+
+		traceId := parent.SpanContext().TraceID()
+		if server.SpanContext.TraceID() != traceId || !server.Parent.IsRemote() {
+			t.Errorf("expected server span joined the caller trace, got parent %v", server.Parent)
+		}
+		if got := trailer.Get(defaultTrailerRequestId); len(got) != 1 || got[0] != traceId.String() {
+			t.Errorf("expected request id reusing trace id %s, got %v", traceId, got)
+		}
+	})
+
+	t.Run("request id attribute", func(t *testing.T) {
+		exp.Reset()
+		ctx := metadata.AppendToOutgoingContext(context.Background(), metadataRequestId, "req-1")
+		if _, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{}); err != nil {
+			t.Fatal(err)
+		}
+
+		server := serverSpan(exp, "grpc.health.v1.Health/Check")
+		if server == nil {
+			t.Fatalf("expected server span, got %v", exp.GetSpans())
+		}
+		var found bool
+		for _, a := range server.Attributes {
+			found = found || (a.Key == "request_id" && a.Value.AsString() == "req-1")
+		}
+		if !found {
+			t.Errorf("expected request id attached to the server span, got %v", server.Attributes)
+		}
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		exp.Reset()
+		err := conn.Invoke(context.Background(), "/test.Fail/Ping", &emptypb.Empty{}, &emptypb.Empty{})
+		if status.Code(err) != codes.Internal {
+			t.Fatalf("expected internal error, got %v", err)
+		}
+
+		server := serverSpan(exp, "test.Fail/Ping")
+		if server == nil {
+			t.Fatalf("expected server span of failed call, got %v", exp.GetSpans())
+		}
+		if server.Status.Code != otelcodes.Error {
+			t.Errorf("expected error status recorded, got %v", server.Status)
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// peerLimitBuckets label values of the rejection metric, identities are hashed into buckets to bound cardinality
+	peerLimitBuckets = 64
+	// peerLimitShards lock shards of the calls in flight, identities are hashed into shards
+	peerLimitShards = 32
+)
+
+var (
+	peerStreamsRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_server_peer_streams_rejected_total",
+		Help: "Number of calls rejected by the per peer stream limit, partitioned by hashed peer identity.",
+	}, []string{"peer"})
+	peerLimitRegisterOnce sync.Once
+)
+
+// PeerStreamLimiter adjust the per peer stream limit of grpc server at runtime, implemented by the grpc ApplicationFactory
+type PeerStreamLimiter interface {
+	// SetPerPeerStreamLimit limit concurrent calls of a single peer, zero or less disables the limit
+	SetPerPeerStreamLimit(n int)
+}
+
+// peerLimiter concurrent calls per peer identity, see WithPerPeerStreamLimit
+type peerLimiter struct {
+	limit    atomic.Int64
+	identity func(ctx context.Context) string
+	shards   [peerLimitShards]peerLimitShard
+}
+
+// peerLimitShard calls in flight of the identities hashed into the shard
+type peerLimitShard struct {
+	mu     sync.Mutex
+	active map[string]int64 // identity to calls in flight, removed when the last call ends
+}
+
+func newPeerLimiter(limit int, identity func(ctx context.Context) string) *peerLimiter {
+	peerLimitRegisterOnce.Do(func() {
+		_ = prometheus.Register(peerStreamsRejected)
+	})
+
+	l := &peerLimiter{identity: identity}
+	for i := range l.shards {
+		l.shards[i].active = make(map[string]int64)
+	}
+	l.limit.Store(int64(limit))
+	return l
+}
+
+// peerIdentity identity of the caller of ctx, identity func of SetPeerIdentity first, then the peer IP so
+// every connection of a client shares its calls
+func (l *peerLimiter) peerIdentity(ctx context.Context) string {
+	if l.identity != nil {
+		if id := l.identity(ctx); id != "" {
+			return id
+		}
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+
+	return p.Addr.String()
+}
+
+func (l *peerLimiter) shard(id string) *peerLimitShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	return &l.shards[h.Sum32()%peerLimitShards]
+}
+
+// acquire count a call of the peer of ctx, release must be called when the call ends.
+// calls beyond the limit get ResourceExhausted, calls without identity are never limited
+func (l *peerLimiter) acquire(ctx context.Context) (release func(), err error) {
+	limit := l.limit.Load()
+	if limit <= 0 {
+		return func() {}, nil
+	}
+
+	id := l.peerIdentity(ctx)
+	if id == "" {
+		return func() {}, nil
+	}
+
+	s := l.shard(id)
+	s.mu.Lock()
+	if s.active[id] >= limit {
+		s.mu.Unlock()
+		peerStreamsRejected.WithLabelValues(peerBucket(id)).Inc()
+		return nil, status.Errorf(codes.ResourceExhausted, "too many concurrent calls from peer, limit is %d", limit)
+	}
+	s.active[id]++
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.active[id]--; s.active[id] <= 0 {
+			delete(s.active, id)
+		}
+	}, nil
+}
+
+// peerBucket metric label of identity, one of peerLimitBuckets hashed buckets so raw addresses never reach prometheus
+func peerBucket(id string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	return fmt.Sprintf("%02d", h.Sum32()%peerLimitBuckets)
+}
+
+func (i *interceptor) unaryServerPeerLimitInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	release, err := i.peerLimiter.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return handler(ctx, req)
+}
+
+func (i *interceptor) streamServerPeerLimitInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	release, err := i.peerLimiter.acquire(ss.Context())
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return handler(srv, ss)
+}
+
+// SetPerPeerStreamLimit limit concurrent calls of a single peer at runtime, zero or less disables the limit
+func (r *rpc) SetPerPeerStreamLimit(n int) {
+	r.peerLimiter.limit.Store(int64(n))
+}
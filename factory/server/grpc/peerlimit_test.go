@@ -0,0 +1,161 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// clientIdKey context key of the client id set by the auth interceptors of the test
+type clientIdKey struct{}
+
+func clientIdFromMetadata(ctx context.Context) context.Context {
+	if id := metadata.ValueFromIncomingContext(ctx, "x-client-id"); len(id) > 0 {
+		return context.WithValue(ctx, clientIdKey{}, id[0])
+	}
+	return ctx
+}
+
+// authStream server stream of the authenticated context
+type authStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authStream) Context() context.Context { return s.ctx }
+
+func TestPerPeerStreamLimit(t *testing.T) {
+	const limit = 3
+
+	srv := New(fakeService{}, SetTCPHost("127.0.0.1"), SetTCPPort(0), WithPerPeerStreamLimit(limit),
+		WithAuth(
+			func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				return handler(clientIdFromMetadata(ctx), req)
+			},
+			func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				return handler(srv, &authStream{ServerStream: ss, ctx: clientIdFromMetadata(ss.Context())})
+			},
+		),
+		SetPeerIdentity(func(ctx context.Context) string {
+			id, _ := ctx.Value(clientIdKey{}).(string)
+			return id
+		}),
+	).(*rpc)
+	go srv.Serve()
+	defer srv.Shutdown(context.Background())
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(srv.Addr()) < 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(srv.Addr()) < 1 {
+		t.Fatal("server not listening")
+	}
+
+	dial := func() grpc_health_v1.HealthClient {
+		conn, err := grpc.NewClient(srv.Addr()[0].String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		return grpc_health_v1.NewHealthClient(conn)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// watch streams stay open, each holds a slot of the peer
+	watch := func(ctx context.Context, client grpc_health_v1.HealthClient) error {
+		stream, err := client.Watch(ctx, &grpc_health_v1.HealthCheckRequest{})
+		if err != nil {
+			return err
+		}
+		_, err = stream.Recv()
+		return err
+	}
+
+	noisyCtx := metadata.AppendToOutgoingContext(ctx, "x-client-id", "noisy")
+	noisy := dial()
+	for n := 0; n < limit; n++ {
+		if err := watch(noisyCtx, noisy); err != nil {
+			t.Fatalf("stream %d within the limit: %v", n, err)
+		}
+	}
+
+	// identity set by auth is shared by every connection of the client
+	again := dial()
+	for n := 0; n < 5; n++ {
+		if err := watch(noisyCtx, again); status.Code(err) != codes.ResourceExhausted {
+			t.Fatalf("expected RESOURCE_EXHAUSTED beyond the limit, got %v", err)
+		}
+		if _, err := noisy.Check(noisyCtx, &grpc_health_v1.HealthCheckRequest{}); status.Code(err) != codes.ResourceExhausted {
+			t.Fatalf("expected unary call rejected beyond the limit, got %v", err)
+		}
+	}
+
+	// another client is unaffected
+	otherCtx := metadata.AppendToOutgoingContext(ctx, "x-client-id", "other")
+	for n := 0; n < limit; n++ {
+		if err := watch(otherCtx, noisy); err != nil {
+			t.Fatalf("expected other client served, got %v", err)
+		}
+	}
+
+	// without identity connections of the same IP share the limit
+	for n := 0; n < limit; n++ {
+		if err := watch(ctx, dial()); err != nil {
+			t.Fatalf("stream %d of the IP within the limit: %v", n, err)
+		}
+	}
+	if err := watch(ctx, dial()); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected new connection of the IP rejected beyond the limit, got %v", err)
+	}
+
+	srv.SetPerPeerStreamLimit(limit + 1)
+	if err := watch(noisyCtx, noisy); err != nil {
+		t.Errorf("expected raised limit to admit one more stream, got %v", err)
+	}
+
+	srv.SetPerPeerStreamLimit(0)
+	if _, err := noisy.Check(noisyCtx, &grpc_health_v1.HealthCheckRequest{}); err != nil {
+		t.Errorf("expected disabled limit to admit calls, got %v", err)
+	}
+}
+
+func TestPeerLimiterIdentity(t *testing.T) {
+	l := newPeerLimiter(1, func(ctx context.Context) string {
+		id, _ := ctx.Value(rpcServiceKey{}).(string)
+		return id
+	})
+
+	ctx := context.WithValue(context.Background(), rpcServiceKey{}, "client-a")
+	release, err := l.acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := l.acquire(ctx); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected second call of the identity rejected, got %v", err)
+	}
+
+	// without identity nor peer the call is never limited
+	if _, err := l.acquire(context.Background()); err != nil {
+		t.Errorf("expected call without identity admitted, got %v", err)
+	}
+
+	release()
+	if s := l.shard("client-a"); len(s.active) != 0 {
+		t.Errorf("expected identity removed after its last call, got %v", s.active)
+	}
+
+	if b := peerBucket("10.0.0.1:5000"); len(b) != 2 || b != peerBucket("10.0.0.1:5000") {
+		t.Errorf("expected stable two digit bucket, got %q", b)
+	}
+}
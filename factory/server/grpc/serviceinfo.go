@@ -0,0 +1,51 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/TixiaOTA/gokit/factory"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// headerServicePrefix prefix of response headers carrying factory.ServiceInfo, e.g. x-service-team
+const headerServicePrefix = "x-service-"
+
+// serviceInfoHeader response header of registered service metadata, nil when not registered
+func serviceInfoHeader() metadata.MD {
+	labels := factory.GetServiceInfo().Labels()
+	if len(labels) < 1 {
+		return nil
+	}
+
+	md := metadata.MD{}
+	for k, v := range labels {
+		if k == "service" {
+			k = "name"
+		}
+
+		md.Set(headerServicePrefix+k, v)
+	}
+
+	return md
+}
+
+func (i *interceptor) unaryServerInfoInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	_ = grpc.SetHeader(ctx, i.serviceInfo)
+	return handler(ctx, req)
+}
+
+func (i *interceptor) streamServerInfoInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	_ = ss.SetHeader(i.serviceInfo)
+	return handler(srv, ss)
+}
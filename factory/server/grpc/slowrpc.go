@@ -0,0 +1,206 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"go.uber.org/zap"
+)
+
+// slowRPCTraceInterval minimum interval between two execution trace snapshots of slow RPCs
+const slowRPCTraceInterval = 10 * time.Second
+
+// SlowRPCOption optional setting of WithSlowRPCDiagnostics
+type SlowRPCOption func(*slowRPCConfig)
+
+// slowRPCConfig settings of slow RPC diagnostics, see WithSlowRPCDiagnostics
+type slowRPCConfig struct {
+	threshold time.Duration
+	log       *logger.Logger
+
+	// directory of execution trace snapshots, empty disables flight recording
+	traceDir      string
+	traceMaxBytes uint64
+}
+
+// SetSlowRPCLogger write slow RPC entries into log, default to logger.Default
+func SetSlowRPCLogger(log *logger.Logger) SlowRPCOption {
+	return func(c *slowRPCConfig) {
+		c.log = log
+	}
+}
+
+// WithSlowRPCTrace keep a flight recording of the runtime execution trace of about maxBytes, zero for the
+// runtime default, and write a snapshot into dir when an RPC is slow. snapshots are written at most every 10s
+func WithSlowRPCTrace(dir string, maxBytes uint64) SlowRPCOption {
+	return func(c *slowRPCConfig) {
+		c.traceDir = dir
+		c.traceMaxBytes = maxBytes
+	}
+}
+
+// slowRPC diagnostics written post-hoc by the tracer interceptor for RPC exceeding the threshold,
+// nothing is captured before the handler so fast RPC cost a single comparison
+type slowRPC struct {
+	threshold time.Duration
+	log       *logger.Logger
+
+	traceDir  string
+	recorder  flightRecorder // nil without flight recording
+	lastTrace atomic.Int64   // unix nano of the last snapshot
+	tracing   atomic.Bool
+}
+
+func newSlowRPC(config *slowRPCConfig) *slowRPC {
+	if config == nil {
+		return nil
+	}
+
+	s := &slowRPC{
+		threshold: config.threshold,
+		log:       config.log,
+		traceDir:  config.traceDir,
+	}
+	if s.log == nil {
+		s.log = logger.Default()
+	}
+
+	if s.traceDir != "" {
+		recorder, err := startFlightRecorder(config.traceMaxBytes)
+		if err != nil {
+			log.Printf("grpc server > slow rpc trace disabled: %v", err)
+		} else {
+			s.recorder = recorder
+		}
+	}
+
+	return s
+}
+
+// stop flight recording
+func (s *slowRPC) stop() {
+	if s != nil && s.recorder != nil {
+		s.recorder.Stop()
+	}
+}
+
+// observe write one slow_rpc entry when d exceeds the threshold, ctx must still hold the context logger messages
+func (s *slowRPC) observe(ctx context.Context, method, requestId string, start time.Time, d time.Duration, err error) {
+	if s == nil || d <= s.threshold {
+		return
+	}
+
+	fields := []zap.Field{
+		zap.String("method", method),
+		zap.Duration("duration", d),
+		zap.Duration("threshold", s.threshold),
+		zap.String("request_id", requestId),
+		zap.Int("goroutines", runtime.NumGoroutine()),
+		zap.Duration("gc_pause", gcPauseSince(start)),
+	}
+
+	if messages := logger.Messages(ctx); messages != nil {
+		fields = append(fields, zap.Any("log_messages", messages))
+	}
+
+	if path := s.snapshot(requestId); path != "" {
+		fields = append(fields, zap.String("trace_file", path))
+	}
+
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+
+	s.log.Warn("slow_rpc", fields...)
+}
+
+// snapshot write the flight recording into the trace directory, empty when disabled, throttled or failed
+func (s *slowRPC) snapshot(requestId string) string {
+	if s.recorder == nil {
+		return ""
+	}
+
+	now := time.Now()
+	if last := s.lastTrace.Load(); last > 0 && now.Sub(time.Unix(0, last)) < slowRPCTraceInterval {
+		return ""
+	}
+
+	// one snapshot at a time, the flight recorder refuses concurrent writes
+	if !s.tracing.CompareAndSwap(false, true) {
+		return ""
+	}
+	defer s.tracing.Store(false)
+	s.lastTrace.Store(now.UnixNano())
+
+	path, err := traceFile(s.traceDir, requestId, now)
+	if err != nil {
+		log.Printf("grpc server > slow rpc trace: %v", err)
+		return ""
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		log.Printf("grpc server > slow rpc trace: %v", err)
+		return ""
+	}
+	defer f.Close()
+
+	if _, err = s.recorder.WriteTo(f); err != nil {
+		log.Printf("grpc server > slow rpc trace: %v", err)
+		_ = os.Remove(path)
+		return ""
+	}
+
+	return path
+}
+
+// maxTraceIdLength characters of the request id kept on a trace file name
+const maxTraceIdLength = 64
+
+// traceFile path of the trace snapshot of requestId inside dir. the request id comes from client metadata,
+// only [A-Za-z0-9-] of it is kept so the name never leaves dir
+func traceFile(dir, requestId string, now time.Time) (string, error) {
+	id := strings.Map(func(r rune) rune {
+		if r == '-' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return -1
+	}, requestId)
+	if len(id) > maxTraceIdLength {
+		id = id[:maxTraceIdLength]
+	}
+	if id == "" {
+		id = "unknown"
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("slow_rpc-%s-%d.trace", id, now.UnixNano()))
+	if rel, err := filepath.Rel(dir, path); err != nil || rel != filepath.Base(path) {
+		return "", fmt.Errorf("trace file %s outside of %s", path, dir)
+	}
+
+	return path, nil
+}
+
+// gcPauseSince total stop-the-world pause of collections ended after start, within the last 256 collections
+func gcPauseSince(start time.Time) time.Duration {
+	var stats debug.GCStats
+	debug.ReadGCStats(&stats)
+
+	var total time.Duration
+	for i, end := range stats.PauseEnd {
+		if end.Before(start) {
+			break
+		}
+		total += stats.Pause[i]
+	}
+
+	return total
+}
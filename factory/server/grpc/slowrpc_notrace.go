@@ -0,0 +1,19 @@
+//go:build !go1.25
+
+package grpc
+
+import (
+	"errors"
+	"io"
+)
+
+// flightRecorder moving window of the runtime execution trace, requires go1.25
+type flightRecorder interface {
+	WriteTo(w io.Writer) (int64, error)
+	Stop()
+}
+
+// startFlightRecorder flight recording is not available before go1.25
+func startFlightRecorder(uint64) (flightRecorder, error) {
+	return nil, errors.New("flight recording requires go1.25")
+}
@@ -0,0 +1,97 @@
+package grpc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestSlowRPCDiagnostics(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	opt := defaultOption()
+	WithSlowRPCDiagnostics(20*time.Millisecond,
+		SetSlowRPCLogger(&logger.Logger{Logger: zap.New(core)}),
+		WithSlowRPCTrace(t.TempDir(), 0),
+	)(&opt)
+
+	i := &interceptor{opt: &opt, slowRPC: newSlowRPC(opt.slowRPC)}
+	defer i.slowRPC.stop()
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "req-slow"))
+
+	// fast RPC write nothing
+	_, _ = i.unaryServerTracerInterceptor(ctx, wrapperspb.String("fast"), info, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		logger.Log.Print(ctx, "fast path")
+		return wrapperspb.String("ok"), nil
+	})
+	if logs.Len() != 0 {
+		t.Fatalf("expected no entry for fast RPC, got %v", logs.AllUntimed())
+	}
+
+	_, _ = i.unaryServerTracerInterceptor(ctx, wrapperspb.String("slow"), info, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		logger.Log.Print(ctx, "querying inventory")
+		time.Sleep(40 * time.Millisecond)
+		logger.Log.Print(ctx, "inventory returned")
+		return wrapperspb.String("ok"), nil
+	})
+
+	entries := logs.FilterMessage("slow_rpc").AllUntimed()
+	if len(entries) != 1 {
+		t.Fatalf("expected one slow_rpc entry, got %v", logs.AllUntimed())
+	}
+
+	fields := entries[0].ContextMap()
+	for _, key := range []string{"method", "duration", "threshold", "request_id", "goroutines", "gc_pause", "log_messages"} {
+		if _, exist := fields[key]; !exist {
+			t.Errorf("expected field %s on slow_rpc, got %v", key, fields)
+		}
+	}
+
+	if entries[0].Level != zapcore.WarnLevel || fields["method"] != "/test.Service/Get" || fields["request_id"] != "req-slow" {
+		t.Errorf("unexpected slow_rpc entry %v %v", entries[0].Level, fields)
+	}
+
+	if d, _ := fields["duration"].(time.Duration); d < 40*time.Millisecond {
+		t.Errorf("expected duration of the slow handler, got %v", fields["duration"])
+	}
+
+	messages, _ := fields["log_messages"].([]logger.LogMessage)
+	if len(messages) != 2 || messages[0].Message != "querying inventory" || messages[1].Message != "inventory returned" {
+		t.Errorf("expected context logger messages of the request, got %v", fields["log_messages"])
+	}
+
+	path, _ := fields["trace_file"].(string)
+	if st, err := os.Stat(path); err != nil || st.Size() == 0 {
+		t.Errorf("expected execution trace snapshot, got %q: %v", path, err)
+	}
+}
+
+func TestTraceFileStaysInDir(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Unix(0, 42)
+
+	for id, want := range map[string]string{
+		"req-slow":               "slow_rpc-req-slow-42.trace",
+		"../../../etc/cron.d/x":  "slow_rpc-etccrondx-42.trace",
+		`..\..\windows`:          "slow_rpc-windows-42.trace",
+		"/..":                    "slow_rpc-unknown-42.trace",
+		strings.Repeat("a", 100): "slow_rpc-" + strings.Repeat("a", maxTraceIdLength) + "-42.trace",
+	} {
+		path, err := traceFile(dir, id, now)
+		if err != nil || path != filepath.Join(dir, want) {
+			t.Errorf("%q: expected %s in trace dir, got %s: %v", id, want, path, err)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+//go:build go1.25
+
+package grpc
+
+import (
+	"io"
+	"runtime/trace"
+)
+
+// flightRecorder moving window of the runtime execution trace, see runtime/trace.FlightRecorder
+type flightRecorder interface {
+	WriteTo(w io.Writer) (int64, error)
+	Stop()
+}
+
+// startFlightRecorder start the flight recorder of the process, at most one may be active at once
+func startFlightRecorder(maxBytes uint64) (flightRecorder, error) {
+	fr := trace.NewFlightRecorder(trace.FlightRecorderConfig{MaxBytes: maxBytes})
+	if err := fr.Start(); err != nil {
+		return nil, err
+	}
+
+	return fr, nil
+}
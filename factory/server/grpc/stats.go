@@ -0,0 +1,131 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/stats"
+)
+
+// Stats snapshot of connection level stats of grpc server
+type Stats struct {
+	OpenConnections int64            `json:"open_connections"`
+	ActiveStreams   map[string]int64 `json:"active_streams"`
+	BytesIn         int64            `json:"bytes_in"`
+	BytesOut        int64            `json:"bytes_out"`
+}
+
+var (
+	openConnectionsDesc = prometheus.NewDesc("grpc_server_open_connections", "Number of open client connections.", nil, nil)
+	activeStreamsDesc   = prometheus.NewDesc("grpc_server_active_streams", "Number of active streams, partitioned by service.", []string{"grpc_service"}, nil)
+	bytesInDesc         = prometheus.NewDesc("grpc_server_received_bytes_total", "Cumulative bytes received from clients.", nil, nil)
+	bytesOutDesc        = prometheus.NewDesc("grpc_server_sent_bytes_total", "Cumulative bytes sent to clients.", nil, nil)
+)
+
+// rpcServiceKey context key of service name of rpc
+type rpcServiceKey struct{}
+
+// statsHandler stats.Handler maintaining connection and stream gauges with atomic counters
+type statsHandler struct {
+	conns    atomic.Int64
+	bytesIn  atomic.Int64
+	bytesOut atomic.Int64
+	// streams service name to *atomic.Int64
+	streams sync.Map
+}
+
+func newStatsHandler() *statsHandler {
+	return &statsHandler{}
+}
+
+func (h *statsHandler) streamCounter(service string) *atomic.Int64 {
+	if c, ok := h.streams.Load(service); ok {
+		return c.(*atomic.Int64)
+	}
+
+	c, _ := h.streams.LoadOrStore(service, new(atomic.Int64))
+	return c.(*atomic.Int64)
+}
+
+func (h *statsHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	// full method is /package.Service/Method
+	service := strings.TrimPrefix(info.FullMethodName, "/")
+	if i := strings.LastIndex(service, "/"); i >= 0 {
+		service = service[:i]
+	}
+
+	return context.WithValue(ctx, rpcServiceKey{}, service)
+}
+
+func (h *statsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	switch st := s.(type) {
+	case *stats.Begin:
+		service, _ := ctx.Value(rpcServiceKey{}).(string)
+		h.streamCounter(service).Add(1)
+	case *stats.End:
+		service, _ := ctx.Value(rpcServiceKey{}).(string)
+		h.streamCounter(service).Add(-1)
+	case *stats.InPayload:
+		h.bytesIn.Add(int64(st.WireLength))
+	case *stats.OutPayload:
+		h.bytesOut.Add(int64(st.WireLength))
+	}
+}
+
+func (h *statsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h *statsHandler) HandleConn(_ context.Context, s stats.ConnStats) {
+	switch s.(type) {
+	case *stats.ConnBegin:
+		h.conns.Add(1)
+	case *stats.ConnEnd:
+		h.conns.Add(-1)
+	}
+}
+
+// snapshot current stats
+func (h *statsHandler) snapshot() Stats {
+	st := Stats{
+		OpenConnections: h.conns.Load(),
+		ActiveStreams:   make(map[string]int64),
+		BytesIn:         h.bytesIn.Load(),
+		BytesOut:        h.bytesOut.Load(),
+	}
+
+	h.streams.Range(func(k, v interface{}) bool {
+		st.ActiveStreams[k.(string)] = v.(*atomic.Int64).Load()
+		return true
+	})
+
+	return st
+}
+
+// Describe implements prometheus.Collector
+func (h *statsHandler) Describe(ch chan<- *prometheus.Desc) {
+	ch <- openConnectionsDesc
+	ch <- activeStreamsDesc
+	ch <- bytesInDesc
+	ch <- bytesOutDesc
+}
+
+// Collect implements prometheus.Collector
+func (h *statsHandler) Collect(ch chan<- prometheus.Metric) {
+	st := h.snapshot()
+
+	ch <- prometheus.MustNewConstMetric(openConnectionsDesc, prometheus.GaugeValue, float64(st.OpenConnections))
+	for service, n := range st.ActiveStreams {
+		ch <- prometheus.MustNewConstMetric(activeStreamsDesc, prometheus.GaugeValue, float64(n), service)
+	}
+	ch <- prometheus.MustNewConstMetric(bytesInDesc, prometheus.CounterValue, float64(st.BytesIn))
+	ch <- prometheus.MustNewConstMetric(bytesOutDesc, prometheus.CounterValue, float64(st.BytesOut))
+}
+
+// Stats snapshot of open connections, active streams per service and cumulative bytes
+func (r *rpc) Stats() Stats {
+	return r.stats.snapshot()
+}
@@ -0,0 +1,79 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before deadline")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStatsHandler(t *testing.T) {
+	h := newStatsHandler()
+	srv := grpc.NewServer(grpc.StatsHandler(h))
+	grpc_health_v1.RegisterHealthServer(srv, health.NewServer())
+
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var conns []*grpc.ClientConn
+	for n := 0; n < 2; n++ {
+		conn, err := grpc.NewClient("passthrough:///bufnet",
+			grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, conn)
+
+		// watch keeps the stream open
+		stream, err := grpc_health_v1.NewHealthClient(conn).Watch(ctx, &grpc_health_v1.HealthCheckRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = stream.Recv(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	waitFor(t, func() bool {
+		st := h.snapshot()
+		return st.OpenConnections == 2 && st.ActiveStreams["grpc.health.v1.Health"] == 2
+	})
+
+	if st := h.snapshot(); st.BytesIn == 0 || st.BytesOut == 0 {
+		t.Errorf("expected bytes counted, got %+v", st)
+	}
+
+	cancel()
+	for _, conn := range conns {
+		_ = conn.Close()
+	}
+
+	waitFor(t, func() bool {
+		st := h.snapshot()
+		return st.OpenConnections == 0 && st.ActiveStreams["grpc.health.v1.Health"] == 0
+	})
+}
@@ -0,0 +1,118 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// default trailer keys of request id and server timing
+	defaultTrailerRequestId    = "x-request-id"
+	defaultTrailerServerTiming = "x-server-timing"
+)
+
+// serverTiming value in Server-Timing format, e.g. "handler;dur=12.345, total;dur=12.501"
+func serverTiming(handler, total time.Duration) string {
+	return fmt.Sprintf("handler;dur=%.3f, total;dur=%.3f",
+		float64(handler)/float64(time.Millisecond), float64(total)/float64(time.Millisecond))
+}
+
+// handlerStartKey context key of handlerStart
+type handlerStartKey struct{}
+
+// handlerStart start of the service handler, moved by the innermost interceptor right before the handler
+// so the remaining interceptors are reported as queue time instead of handler time
+type handlerStart struct {
+	nanos atomic.Int64
+}
+
+// withHandlerStart context tracking the handler start, initially now
+func withHandlerStart(ctx context.Context) (context.Context, *handlerStart) {
+	hs := &handlerStart{}
+	hs.nanos.Store(logger.Now().UnixNano())
+	return context.WithValue(ctx, handlerStartKey{}, hs), hs
+}
+
+// markHandlerStart record now as the handler start of ctx
+func markHandlerStart(ctx context.Context) {
+	if hs, ok := ctx.Value(handlerStartKey{}).(*handlerStart); ok {
+		hs.nanos.Store(logger.Now().UnixNano())
+	}
+}
+
+// elapsed time since the handler start
+func (hs *handlerStart) elapsed() time.Duration {
+	return logger.Now().Sub(time.Unix(0, hs.nanos.Load()))
+}
+
+// unaryServerHandlerStartInterceptor innermost interceptor marking the handler start
+func (i *interceptor) unaryServerHandlerStartInterceptor(
+	ctx context.Context,
+	req interface{},
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	markHandlerStart(ctx)
+	return handler(ctx, req)
+}
+
+// streamServerHandlerStartInterceptor innermost interceptor marking the handler start
+func (i *interceptor) streamServerHandlerStartInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	_ *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	markHandlerStart(ss.Context())
+	return handler(srv, ss)
+}
+
+// handlerStartStream server stream carrying the handler start on its context
+type handlerStartStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *handlerStartStream) Context() context.Context {
+	return s.ctx
+}
+
+// setTrailer set request id and server timing into trailing metadata of unary RPC
+func (i *interceptor) setTrailer(ctx context.Context, requestId string, handler, total time.Duration) {
+	if i.opt.disableTrailers {
+		return
+	}
+
+	_ = grpc.SetTrailer(ctx, metadata.Pairs(
+		i.opt.trailerRequestIdKey, requestId,
+		i.opt.trailerServerTimingKey, serverTiming(handler, total),
+	))
+}
+
+// streamServerTrailerInterceptor set request id and server timing into trailing metadata on stream close
+func (i *interceptor) streamServerTrailerInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	start := logger.Now()
+	requestId := requestIdFromContext(ss.Context())
+	setAccessLogRequestId(ss.Context(), requestId)
+
+	ctx, hs := withHandlerStart(ss.Context())
+	err := handler(srv, &handlerStartStream{ServerStream: ss, ctx: ctx})
+
+	ss.SetTrailer(metadata.Pairs(
+		i.opt.trailerRequestIdKey, requestId,
+		i.opt.trailerServerTimingKey, serverTiming(hs.elapsed(), logger.Now().Sub(start)),
+	))
+
+	return err
+}
@@ -0,0 +1,106 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+var timingPattern = regexp.MustCompile(`^handler;dur=([0-9.]+), total;dur=([0-9.]+)$`)
+
+func parseTiming(t *testing.T, md metadata.MD, key string) (handler, total float64) {
+	t.Helper()
+
+	val := md.Get(key)
+	if len(val) != 1 {
+		t.Fatalf("expected %s trailer, got %v", key, md)
+	}
+
+	m := timingPattern.FindStringSubmatch(val[0])
+	if m == nil {
+		t.Fatalf("unexpected server timing %q", val[0])
+	}
+
+	handler, _ = strconv.ParseFloat(m[1], 64)
+	total, _ = strconv.ParseFloat(m[2], 64)
+	return handler, total
+}
+
+func TestTrailers(t *testing.T) {
+	opt := defaultOption()
+	SetTrailerKeys("", "x-timing")(&opt)
+	i := &interceptor{opt: &opt}
+
+	slow := func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		time.Sleep(20 * time.Millisecond)
+		return handler(ctx, req)
+	}
+
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(i.chainUnaryServer(i.unaryServerTracerInterceptor, slow, i.unaryServerHandlerStartInterceptor)),
+		grpc.ChainStreamInterceptor(i.streamServerTrailerInterceptor, i.streamServerHandlerStartInterceptor),
+		// streams of unknown services finish immediately
+		grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+			return nil
+		}),
+	)
+	grpc_health_v1.RegisterHealthServer(srv, health.NewServer())
+
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := grpc_health_v1.NewHealthClient(conn)
+
+	// unary
+	var trailer metadata.MD
+	ctx := metadata.AppendToOutgoingContext(context.Background(), metadataRequestId, "req-1")
+	start := time.Now()
+	if _, err = client.Check(ctx, &grpc_health_v1.HealthCheckRequest{}, grpc.Trailer(&trailer)); err != nil {
+		t.Fatal(err)
+	}
+	elapsed := float64(time.Since(start)) / float64(time.Millisecond)
+
+	if got := trailer.Get("x-request-id"); len(got) != 1 || got[0] != "req-1" {
+		t.Errorf("expected request id echoed, got %v", trailer)
+	}
+
+	handler, total := parseTiming(t, trailer, "x-timing")
+	// the slow interceptor runs before the handler start
+	if handler >= 20 || total < 20 || total > elapsed {
+		t.Errorf("implausible timing handler=%.3f total=%.3f elapsed=%.3f", handler, total, elapsed)
+	}
+
+	// stream
+	stream, err := conn.NewStream(context.Background(), &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, "/echo.Echo/Stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = stream.CloseSend()
+	for err == nil {
+		err = stream.RecvMsg(&grpc_health_v1.HealthCheckResponse{})
+	}
+
+	if got := stream.Trailer().Get("x-request-id"); len(got) != 1 {
+		t.Errorf("expected request id on stream close, got %v", stream.Trailer())
+	}
+	parseTiming(t, stream.Trailer(), "x-timing")
+}
@@ -0,0 +1,131 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"path"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// MainListener name of the main listener, see WithMethodVisibility
+const MainListener = "main"
+
+// GRPCWebListener name of the grpc-web companion port, see WithGRPCWeb. it follows the visibility of
+// MainListener unless configured on its own
+const GRPCWebListener = "grpc-web"
+
+// namedListener tag accepted connections with the name of listener
+type namedListener struct {
+	net.Listener
+	name string
+}
+
+func (l *namedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &namedConn{Conn: conn, local: listenerAddr{Addr: conn.LocalAddr(), name: l.name}}, nil
+}
+
+// namedConn connection reporting listenerAddr as local address, surfaced as peer.Peer.LocalAddr
+type namedConn struct {
+	net.Conn
+	local listenerAddr
+}
+
+func (c *namedConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// listenerAddr local address carrying the name of listener
+type listenerAddr struct {
+	net.Addr
+	name string
+}
+
+// listenerName name of listener the connection of ctx arrived on, empty when unknown
+func listenerName(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	la, _ := p.LocalAddr.(listenerAddr)
+	return la.name
+}
+
+// methodVisibility allowed method patterns per listener, listeners without patterns expose every method
+type methodVisibility map[string][]string
+
+// allowed report whether fullMethod (e.g. /pkg.Service/Method) is exposed on listener.
+// a connection of an unknown listener is refused once any visibility is configured
+func (v methodVisibility) allowed(listener, fullMethod string) bool {
+	patterns, ok := v[listener]
+	if !ok && listener == GRPCWebListener {
+		patterns, ok = v[MainListener]
+	}
+	if !ok {
+		return listener != "" || len(v) < 1
+	}
+
+	for _, pattern := range patterns {
+		if pattern == "*" {
+			return true
+		}
+		if matched, _ := path.Match(pattern, fullMethod); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// normalizeMethodPattern prefix pattern with "/", e.g. "pkg.Service/*" is "/pkg.Service/*"
+func normalizeMethodPattern(pattern string) string {
+	if pattern == "*" || strings.HasPrefix(pattern, "/") {
+		return pattern
+	}
+
+	return "/" + pattern
+}
+
+func (i *interceptor) checkVisibility(ctx context.Context, fullMethod string) error {
+	if name := listenerName(ctx); !i.opt.methodVisibility.allowed(name, fullMethod) {
+		return status.Errorf(codes.PermissionDenied, "method %s is not exposed on listener %s", fullMethod, name)
+	}
+
+	return nil
+}
+
+func (i *interceptor) unaryServerVisibilityInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	if err := i.checkVisibility(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (i *interceptor) streamServerVisibilityInterceptor(
+	srv interface{},
+	stream grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	if err := i.checkVisibility(stream.Context(), info.FullMethod); err != nil {
+		return err
+	}
+
+	return handler(srv, stream)
+}
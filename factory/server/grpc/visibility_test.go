@@ -0,0 +1,145 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/abstract"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// internalHandler register test.Internal/Ping
+type internalHandler struct{}
+
+func (internalHandler) Register(s *grpc.Server) {
+	s.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "test.Internal",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Ping",
+			Handler: func(_ interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(emptypb.Empty)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				info := &grpc.UnaryServerInfo{FullMethod: "/test.Internal/Ping"}
+				return interceptor(ctx, in, info, func(context.Context, interface{}) (interface{}, error) {
+					return &emptypb.Empty{}, nil
+				})
+			},
+		}},
+	}, struct{}{})
+}
+
+type internalService struct{ fakeService }
+
+func (internalService) GRPCHandler() abstract.GRPCHandler { return internalHandler{} }
+
+func TestMethodVisibility(t *testing.T) {
+	srv := New(internalService{},
+		SetTCPHost("127.0.0.1"), SetTCPPort(0),
+		WithAdditionalListener(ListenerAddr("127.0.0.1:0"), ListenerName("internal")),
+		WithMethodVisibility(MainListener, []string{"grpc.health.v1.Health/*"}),
+		WithMethodVisibility("internal", []string{"*"}),
+	).(*rpc)
+	go srv.Serve()
+	defer srv.Shutdown(context.Background())
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(srv.Addr()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	addrs := srv.Addr()
+	if len(addrs) != 2 {
+		t.Fatalf("expected 2 bound addresses, got %v", addrs)
+	}
+
+	call := func(addr, method string) error {
+		conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if method == "health" {
+			_, err = grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+			return err
+		}
+		return conn.Invoke(ctx, method, &emptypb.Empty{}, &emptypb.Empty{})
+	}
+
+	main, internal := addrs[0].String(), addrs[1].String()
+	if err := call(main, "/test.Internal/Ping"); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected internal method hidden on main listener, got %v", err)
+	}
+	if err := call(main, "health"); err != nil {
+		t.Errorf("expected health exposed on main listener, got %v", err)
+	}
+	if err := call(internal, "/test.Internal/Ping"); err != nil {
+		t.Errorf("expected internal method on internal listener, got %v", err)
+	}
+}
+
+func TestMethodVisibilityGRPCWeb(t *testing.T) {
+	srv := New(internalService{},
+		SetTCPHost("127.0.0.1"), SetTCPPort(0),
+		WithGRPCWeb(":0", nil),
+		WithMethodVisibility(MainListener, []string{"grpc.health.v1.Health/*"}),
+	).(*rpc)
+	defer srv.Shutdown(context.Background())
+
+	web := httptest.NewServer(srv.webServer.Handler)
+	defer web.Close()
+
+	// grpc-web unary call of an empty message, status is on headers or on the trailer frame
+	call := func(method string) string {
+		req, _ := http.NewRequest(http.MethodPost, web.URL+method, bytes.NewReader([]byte{0, 0, 0, 0, 0}))
+		req.Header.Set("Content-Type", "application/grpc-web+proto")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if code := resp.Header.Get("Grpc-Status"); code != "" {
+			return code
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if i := bytes.Index(body, []byte("grpc-status:")); i >= 0 {
+			return strings.TrimSpace(strings.SplitN(string(body[i+len("grpc-status:"):]), "\r\n", 2)[0])
+		}
+		return ""
+	}
+
+	if got := call("/test.Internal/Ping"); got != strconv.Itoa(int(codes.PermissionDenied)) {
+		t.Errorf("expected method hidden on main hidden on grpc-web too, got status %q", got)
+	}
+	if got := call("/grpc.health.v1.Health/Check"); got != "0" {
+		t.Errorf("expected health exposed on grpc-web, got status %q", got)
+	}
+}
+
+func TestMethodVisibilityUnknownListener(t *testing.T) {
+	v := methodVisibility{MainListener: {"/grpc.health.v1.Health/*"}}
+	if v.allowed("", "/test.Internal/Ping") || v.allowed("", "/grpc.health.v1.Health/Check") {
+		t.Error("expected unknown listener refused once visibility is configured")
+	}
+	if !(methodVisibility{}).allowed("", "/test.Internal/Ping") {
+		t.Error("expected every method exposed without visibility")
+	}
+}
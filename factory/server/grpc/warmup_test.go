@@ -0,0 +1,104 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// serveWarmup run Serve of server with warm-up, return the startup failure
+func serveWarmup(t *testing.T, fn func(ctx context.Context) error, timeout time.Duration) (*rpc, <-chan error) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	_ = l.Close()
+
+	srv := &rpc{serverEngine: grpc.NewServer(), opt: defaultOption()}
+	for _, opt := range []OptionFunc{SetTCPHost("127.0.0.1"), SetTCPPort(port), WithWarmup(fn, timeout)} {
+		opt(&srv.opt)
+	}
+
+	failed := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				failed <- fmt.Errorf("%v", r)
+			}
+		}()
+		srv.Serve()
+	}()
+
+	return srv, failed
+}
+
+func TestWarmupFailurePreventsListening(t *testing.T) {
+	srv, failed := serveWarmup(t, func(context.Context) error {
+		return errors.New("cache unavailable")
+	}, time.Second)
+
+	select {
+	case err := <-failed:
+		if !strings.Contains(err.Error(), "warm-up: cache unavailable") {
+			t.Errorf("unexpected startup error %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected startup to fail")
+	}
+
+	if len(srv.Addr()) > 0 {
+		t.Error("expected server never listening after failed warm-up")
+	}
+	srv.Shutdown(context.Background())
+}
+
+func TestWarmupTimeout(t *testing.T) {
+	start := time.Now()
+	srv, failed := serveWarmup(t, func(context.Context) error {
+		// ignores context on purpose
+		time.Sleep(time.Second)
+		return nil
+	}, 50*time.Millisecond)
+	defer srv.Shutdown(context.Background())
+
+	select {
+	case err := <-failed:
+		if !strings.Contains(err.Error(), "timed out") || time.Since(start) > 500*time.Millisecond {
+			t.Errorf("expected timeout after 50ms, got %v after %s", err, time.Since(start))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected warm-up timeout")
+	}
+
+	if len(srv.Addr()) > 0 {
+		t.Error("expected server never listening after warm-up timeout")
+	}
+}
+
+func TestWarmupBeforeListening(t *testing.T) {
+	warmed := make(chan struct{})
+	srv, _ := serveWarmup(t, func(context.Context) error {
+		close(warmed)
+		return nil
+	}, time.Second)
+	defer srv.Shutdown(context.Background())
+
+	<-warmed
+	deadline := time.Now().Add(2 * time.Second)
+	for len(srv.Addr()) < 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if len(srv.Addr()) < 1 {
+		t.Fatal("expected server listening after warm-up")
+	}
+}
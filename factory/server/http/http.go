@@ -0,0 +1,113 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/TixiaOTA/gokit/factory"
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/hellofresh/health-go/v4"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// server an instance of net/http server
+type server struct {
+	serverEngine *http.Server
+	service      factory.ServiceFactory
+	opt          option
+
+	// set once the http port is bound, see Ready
+	ready factory.ReadySignal
+}
+
+// New creates new plain net/http server
+func New(svc factory.ServiceFactory, opts ...OptionFunc) factory.ApplicationFactory {
+	srv := &server{
+		service: svc,
+		opt:     defaultOption(),
+	}
+
+	for _, o := range opts {
+		o(&srv.opt)
+	}
+
+	// start handler for health-check and metrics
+	mux := http.NewServeMux()
+	h, err := health.New(health.WithChecks(srv.opt.healthChecks...))
+	if err != nil {
+		panic(fmt.Errorf("http server: %w", err))
+	}
+	mux.Handle("/live/status", h.Handler())
+	mux.Handle("/metrics", promhttp.Handler())
+
+	// root path for http handler, the service provides it through factory.HTTPServiceFactory
+	root := http.NewServeMux()
+	if hs, ok := svc.(factory.HTTPServiceFactory); ok {
+		if h := hs.HTTPHandler(); h != nil {
+			h.Mount(root)
+		}
+	}
+	mux.Handle("/", srv.traceLogger(root)) // implement http logging
+
+	srv.serverEngine = &http.Server{
+		Addr:         srv.opt.httpHost + ":" + srv.opt.httpPort,
+		Handler:      mux,
+		TLSConfig:    srv.opt.tlsConfig,
+		ReadTimeout:  srv.opt.readTimeout,
+		WriteTimeout: srv.opt.writeTimeout,
+		IdleTimeout:  srv.opt.idleTimeout,
+	}
+
+	logger.GreenBold(fmt.Sprintf("⇨ HTTP server run at %s\n", srv.serverEngine.Addr))
+	return srv
+}
+
+func (s *server) Serve() {
+	listener, err := net.Listen("tcp", s.serverEngine.Addr)
+	if err != nil {
+		panic(fmt.Errorf("http server: %s", err))
+	}
+	s.ready.Set()
+
+	if s.opt.tlsConfig != nil || s.opt.certFile != "" {
+		err = s.serverEngine.ServeTLS(listener, s.opt.certFile, s.opt.keyFile)
+	} else {
+		err = s.serverEngine.Serve(listener)
+	}
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		panic(fmt.Errorf("http server: %s", err))
+	}
+}
+
+// Ready wait until the http port is bound, see factory.Readier
+func (s *server) Ready(ctx context.Context) error {
+	return s.ready.Wait(ctx)
+}
+
+func (s *server) Shutdown(ctx context.Context) {
+	defer logger.RedBold("Stopping HTTP Server")
+	_ = s.serverEngine.Shutdown(ctx)
+}
+
+func (s *server) Name() string {
+	return types.HTTP.String()
+}
+
+// Validate check that tls certificate and key files load as a key pair
+func (s *server) Validate(_ context.Context) error {
+	if s.opt.certFile == "" && s.opt.keyFile == "" {
+		return nil
+	}
+
+	if _, err := tls.LoadX509KeyPair(s.opt.certFile, s.opt.keyFile); err != nil {
+		return fmt.Errorf("http server: %w", err)
+	}
+
+	return nil
+}
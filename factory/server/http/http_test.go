@@ -0,0 +1,180 @@
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/abstract"
+	"github.com/TixiaOTA/gokit/factory"
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/TixiaOTA/gokit/types"
+)
+
+type fakeService struct{}
+
+func (fakeService) Name() string                                           { return "test" }
+func (fakeService) GetApplications() map[string]factory.ApplicationFactory { return nil }
+func (fakeService) RESTHandler() abstract.RestHandler                      { return nil }
+func (fakeService) GRPCHandler() abstract.GRPCHandler                      { return nil }
+func (fakeService) BrokerHandler(types.Broker) abstract.BrokerHandler      { return nil }
+func (fakeService) GetBroker(types.Broker) abstract.Broker                 { return nil }
+
+// fakeHTTPService service providing a net/http handler
+type fakeHTTPService struct {
+	fakeService
+}
+
+func (fakeHTTPService) HTTPHandler() abstract.HTTPHandler { return fakeHandler{} }
+
+type fakeHandler struct{}
+
+func (fakeHandler) Mount(mux *http.ServeMux) {
+	mux.HandleFunc("/hello", func(w http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(w, logger.GetRequestId(req.Context()))
+	})
+}
+
+func get(t *testing.T, url string, header http.Header) (int, string) {
+	t.Helper()
+
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	for key := range header {
+		req.Header.Set(key, header.Get(key))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestMountHTTPHandler(t *testing.T) {
+	srv := httptest.NewServer(New(fakeHTTPService{}).(*server).serverEngine.Handler)
+	defer srv.Close()
+
+	if code, body := get(t, srv.URL+"/hello", http.Header{"X-Request-Id": {"req-1"}}); code != http.StatusOK || body != "req-1" {
+		t.Errorf("expected handler served with request id of the logging middleware, got %d %q", code, body)
+	}
+
+	if code, _ := get(t, srv.URL+"/live/status", nil); code != http.StatusOK {
+		t.Errorf("expected health check served, got %d", code)
+	}
+}
+
+func TestWithoutHTTPHandler(t *testing.T) {
+	// services without factory.HTTPServiceFactory still get health check and metrics
+	srv := httptest.NewServer(New(fakeService{}).(*server).serverEngine.Handler)
+	defer srv.Close()
+
+	if code, _ := get(t, srv.URL+"/hello", nil); code != http.StatusNotFound {
+		t.Errorf("expected no handler mounted, got %d", code)
+	}
+
+	if code, _ := get(t, srv.URL+"/metrics", nil); code != http.StatusOK {
+		t.Errorf("expected metrics served, got %d", code)
+	}
+}
+
+func TestServeShutdown(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	_ = l.Close()
+
+	app := New(fakeHTTPService{}, SetHTTPHost("127.0.0.1"), SetHTTPPort(port))
+	done := make(chan struct{})
+	go func() {
+		app.Serve()
+		close(done)
+	}()
+
+	// ready once the port is bound, no request is refused from then on
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err = app.(factory.Readier).Ready(ctx); err != nil {
+		t.Fatalf("http server not ready: %v", err)
+	}
+	if _, err = http.Get("http://" + net.JoinHostPort("127.0.0.1", strconv.Itoa(port)) + "/hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	app.Shutdown(context.Background())
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected Serve returns after Shutdown")
+	}
+}
+
+// writeKeyPair write PEM certificate and key files of a self-signed certificate
+func writeKeyPair(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	if err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestValidateTLSFiles(t *testing.T) {
+	certFile, keyFile := writeKeyPair(t)
+	garbage := filepath.Join(t.TempDir(), "garbage.pem")
+	if err := os.WriteFile(garbage, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name      string
+		cert, key string
+		valid     bool
+	}{
+		{"valid", certFile, keyFile, true},
+		{"missing", filepath.Join(t.TempDir(), "missing.crt"), keyFile, false},
+		{"garbage", garbage, keyFile, false},
+		{"mismatched", certFile, garbage, false},
+	} {
+		if err := New(fakeService{}, SetHTTPPort(0), SetTLS(tc.cert, tc.key)).(*server).Validate(context.Background()); (err == nil) != tc.valid {
+			t.Errorf("%s: expected valid %v, got %v", tc.name, tc.valid, err)
+		}
+	}
+}
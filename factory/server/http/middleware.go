@@ -0,0 +1,141 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/TixiaOTA/gokit/tracer"
+	"github.com/TixiaOTA/gokit/utils/timezone"
+	"github.com/google/uuid"
+)
+
+// responseWriter record status code and body of the response
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.body.Len() <= 1000 {
+		w.body.Write(p)
+	}
+
+	return w.ResponseWriter.Write(p)
+}
+
+func (s *server) traceLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		start := logger.Now().In(timezone.JakartaTz())
+
+		var err error
+		var resp string
+
+		requestId := req.Header.Get("x-request-id")
+		if requestId == "" {
+			requestId = uuid.NewString()
+		}
+
+		// dump header and body
+		dumpHeader := dumpHeaderFromRequest(req)
+		dumpBody := dumpBodyFromRequest(req)
+		// init logger
+		dl := logger.DataLogger{
+			RequestId:     requestId,
+			Ip:            clientIP(req),
+			Device:        req.UserAgent(),
+			Type:          logger.ServiceType("http"),
+			TimeStart:     start,
+			Service:       s.service.Name(),
+			Host:          req.Host,
+			RequestMethod: req.Method,
+			RequestHeader: dumpHeader,
+			RequestBody:   dumpBody,
+			Endpoint:      req.URL.Path,
+		}
+
+		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+		operationName := fmt.Sprintf("%s %s", req.Method, req.URL.Path)
+		trace, ctx := tracer.StartTraceWithContext(ctx, operationName)
+		defer func() {
+			if re := recover(); re != nil {
+				err = fmt.Errorf("%s", re)
+				rw.WriteHeader(http.StatusInternalServerError)
+			}
+
+			if err != nil {
+				trace.SetError(err)
+			}
+
+			if rw.body.Len() > 1000 {
+				resp = "success request"
+			} else {
+				resp = rw.body.String()
+			}
+
+			trace.SetTag("http.status_code", rw.statusCode)
+			// set response
+			logger.Response(ctx, rw.statusCode, resp, err)
+			// get all data logging from context with mutext
+			dl.Finalize(ctx)
+			// finish the tracing
+			trace.Finish()
+		}()
+
+		// set logger into context with key LogKey
+		lock := new(logger.Locker)
+		ctx = context.WithValue(ctx, logger.LogKey, lock)
+		lock.Set(logger.RequestId, dl.RequestId)
+
+		trace.SetTag("tracer_id", tracer.GetTraceID(ctx))
+		trace.SetTag("request_id", dl.RequestId)
+		trace.SetTag("http.method", req.Method)
+		trace.SetTag("http.url", req.URL.Path)
+		trace.SetTag("http.request", dumpHeader)
+		trace.SetTag("http.request_body", dl.RequestBody)
+
+		next.ServeHTTP(rw, req.WithContext(ctx))
+	})
+}
+
+func dumpHeaderFromRequest(req *http.Request) string {
+	header, _ := json.Marshal(req.Header)
+	return fmt.Sprintf("%s %s %s", req.Method, req.URL.RequestURI(), string(header))
+}
+
+// dumpBodyFromRequest read the body and restore it for the next handler
+func dumpBodyFromRequest(req *http.Request) string {
+	if req.Body == nil {
+		return ""
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return ""
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	return string(body)
+}
+
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/TixiaOTA/gokit/utils/env"
+	"github.com/hellofresh/health-go/v4"
+)
+
+// OptionFunc setter net/http server options
+type OptionFunc func(*option)
+
+// option an instance of net/http server options
+type option struct {
+	httpPort     string
+	httpHost     string
+	tlsConfig    *tls.Config
+	certFile     string
+	keyFile      string
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	idleTimeout  time.Duration
+	healthChecks []health.Config
+}
+
+// defaultOption default options for net/http server
+func defaultOption() option {
+	return option{
+		httpPort:     fmt.Sprintf("%d", env.GetInteger("HTTP_SERVER_PORT", 8081)),
+		readTimeout:  env.GetDuration("HTTP_SERVER_READ_TIMEOUT", 30*time.Second),
+		writeTimeout: env.GetDuration("HTTP_SERVER_WRITE_TIMEOUT", 30*time.Second),
+		idleTimeout:  env.GetDuration("HTTP_SERVER_IDLE_TIMEOUT", 60*time.Second),
+	}
+}
+
+// SetHTTPPort set http port
+func SetHTTPPort(httpPort int) OptionFunc {
+	return func(o *option) {
+		o.httpPort = fmt.Sprintf("%d", httpPort)
+	}
+}
+
+// SetHTTPHost set http host
+func SetHTTPHost(httpHost string) OptionFunc {
+	return func(o *option) {
+		o.httpHost = httpHost
+	}
+}
+
+// SetTLS serve https with certificate and key file
+func SetTLS(certFile, keyFile string) OptionFunc {
+	return func(o *option) {
+		o.certFile = certFile
+		o.keyFile = keyFile
+	}
+}
+
+// SetTLSConfig set tls configuration, certificates can be provided on the config itself
+func SetTLSConfig(tlsConfig *tls.Config) OptionFunc {
+	return func(o *option) {
+		o.tlsConfig = tlsConfig
+	}
+}
+
+// SetReadTimeout set maximum duration for reading the entire request
+func SetReadTimeout(d time.Duration) OptionFunc {
+	return func(o *option) {
+		o.readTimeout = d
+	}
+}
+
+// SetWriteTimeout set maximum duration before timing out writes of the response
+func SetWriteTimeout(d time.Duration) OptionFunc {
+	return func(o *option) {
+		o.writeTimeout = d
+	}
+}
+
+// SetIdleTimeout set maximum amount of time to wait for the next request when keep-alive are enabled
+func SetIdleTimeout(d time.Duration) OptionFunc {
+	return func(o *option) {
+		o.idleTimeout = d
+	}
+}
+
+// SetHealthCheck report checks on /live/status, e.g. health.Config{Name: "rabbitmq", Check: broker.Check}
+func SetHealthCheck(checks ...health.Config) OptionFunc {
+	return func(o *option) {
+		o.healthChecks = append(o.healthChecks, checks...)
+	}
+}
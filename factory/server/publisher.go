@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+
+	"github.com/TixiaOTA/gokit/abstract"
+	brokerrabbitmq "github.com/TixiaOTA/gokit/broker/rabbitmq"
+	"github.com/TixiaOTA/gokit/broker/sqs"
+	"github.com/TixiaOTA/gokit/types"
+)
+
+// validatedBroker broker whose publisher validates payloads before publishing, see types.PublisherArgument.Validate
+type validatedBroker struct {
+	abstract.Broker
+}
+
+// withPayloadValidation wrap user provided broker, the brokers of gokit validate on PublishMessage already
+func withPayloadValidation(broker abstract.Broker) abstract.Broker {
+	switch broker.(type) {
+	case nil, *brokerrabbitmq.Broker, *sqs.Broker, validatedBroker:
+		return broker
+	}
+
+	return validatedBroker{Broker: broker}
+}
+
+func (b validatedBroker) GetPublisher() abstract.Publisher {
+	p := b.Broker.GetPublisher()
+	if p == nil {
+		return nil
+	}
+
+	if bp, ok := p.(abstract.BatchPublisher); ok {
+		return validatedBatchPublisher{validatedPublisher: validatedPublisher{Publisher: bp}, batch: bp}
+	}
+
+	return validatedPublisher{Publisher: p}
+}
+
+// validatedPublisher publisher rejecting invalid payloads before delegating
+type validatedPublisher struct {
+	abstract.Publisher
+}
+
+func (p validatedPublisher) PublishMessage(ctx context.Context, req types.PublisherArgument) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	return p.Publisher.PublishMessage(ctx, req)
+}
+
+// validatedBatchPublisher batch publisher rejecting the batch when one payload is invalid, nothing is published then
+type validatedBatchPublisher struct {
+	validatedPublisher
+	batch abstract.BatchPublisher
+}
+
+func (p validatedBatchPublisher) PublishMessages(ctx context.Context, req []types.PublisherArgument) error {
+	for _, args := range req {
+		if err := args.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return p.batch.PublishMessages(ctx, req)
+}
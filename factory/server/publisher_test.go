@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/TixiaOTA/gokit/abstract"
+	"github.com/TixiaOTA/gokit/types"
+)
+
+// fakeBroker user provided broker recording published messages
+type fakeBroker struct {
+	published []types.PublisherArgument
+}
+
+func (b *fakeBroker) GetPublisher() abstract.Publisher { return b }
+func (b *fakeBroker) GetName() types.Broker            { return types.Kafka }
+func (b *fakeBroker) GetConfiguration() interface{}    { return nil }
+func (b *fakeBroker) Disconnect(context.Context) error { return nil }
+func (b *fakeBroker) PublishMessage(_ context.Context, req types.PublisherArgument) error {
+	b.published = append(b.published, req)
+	return nil
+}
+
+func (b *fakeBroker) PublishMessages(_ context.Context, req []types.PublisherArgument) error {
+	b.published = append(b.published, req...)
+	return nil
+}
+
+func TestUserBrokerPayloadValidation(t *testing.T) {
+	types.RegisterPayloadValidator("user.created", func(payload []byte) error {
+		if string(payload) != `{"id":"1"}` {
+			return errors.New("id is required")
+		}
+		return nil
+	})
+
+	fb := &fakeBroker{}
+	svc := NewService(SetServiceName("user-service"), SetBroker(types.Kafka, fb))
+	pub := svc.GetBroker(types.Kafka).GetPublisher()
+
+	var ip *types.ErrInvalidPayload
+	if err := pub.PublishMessage(context.Background(), types.PublisherArgument{Topic: "user.created", Message: []byte(`{}`)}); !errors.As(err, &ip) {
+		t.Errorf("expected invalid payload rejected, got %v", err)
+	}
+	if err := pub.PublishMessage(context.Background(), types.PublisherArgument{Topic: "user.created", Message: []byte(`{"id":"1"}`)}); err != nil {
+		t.Errorf("expected valid payload published, got %v", err)
+	}
+
+	batch, ok := pub.(abstract.BatchPublisher)
+	if !ok {
+		t.Fatal("expected batch publisher kept")
+	}
+	err := batch.PublishMessages(context.Background(), []types.PublisherArgument{
+		{Topic: "user.created", Message: []byte(`{"id":"1"}`)},
+		{Topic: "user.created", Message: []byte(`{}`)},
+	})
+	if !errors.As(err, &ip) {
+		t.Errorf("expected batch with invalid payload rejected, got %v", err)
+	}
+
+	if len(fb.published) != 1 {
+		t.Errorf("expected only the valid payload published, got %d", len(fb.published))
+	}
+}
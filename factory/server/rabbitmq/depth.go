@@ -0,0 +1,114 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/streadway/amqp"
+)
+
+var (
+	queueMessages = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rabbitmq_queue_messages",
+		Help: "Number of messages ready on the queue, partitioned by queue.",
+	}, []string{"queue"})
+	queueConsumers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rabbitmq_queue_consumers",
+		Help: "Number of consumers of the queue, partitioned by queue.",
+	}, []string{"queue"})
+	depthRegisterOnce sync.Once
+)
+
+// queueInspector passive queue declaration, implemented by *amqp.Channel
+type queueInspector interface {
+	QueueInspect(name string) (amqp.Queue, error)
+}
+
+// DepthInspector queue depth of a running consumer, assert the rabbitmq application against it
+// for readiness checks, e.g. not ready when depth is above threshold
+type DepthInspector interface {
+	QueueDepth(ctx context.Context, queue string) (int, error)
+}
+
+// QueueDepth number of messages ready on a registered queue
+func (r *rabbitMqWorker) QueueDepth(ctx context.Context, queue string) (int, error) {
+	q, err := r.inspectQueue(ctx, queue)
+	if err != nil {
+		return 0, err
+	}
+
+	return q.Messages, nil
+}
+
+// inspectQueue inspect registered queue only, inspecting a missing queue closes the channel
+func (r *rabbitMqWorker) inspectQueue(ctx context.Context, queue string) (amqp.Queue, error) {
+	if _, ok := r.handlers[queue]; !ok {
+		return amqp.Queue{}, fmt.Errorf("rabbitmq: queue %s is not registered", queue)
+	}
+
+	type result struct {
+		q   amqp.Queue
+		err error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		q, err := r.inspector.QueueInspect(queue)
+		done <- result{q, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return amqp.Queue{}, ctx.Err()
+	case res := <-done:
+		return res.q, res.err
+	}
+}
+
+// collectDepth update queue depth gauges of every registered queue until the worker stopped
+func (r *rabbitMqWorker) collectDepth() {
+	if r.opt.depthInterval <= 0 || r.inspector == nil || len(r.handlers) < 1 {
+		return
+	}
+
+	depthRegisterOnce.Do(func() {
+		_ = prometheus.Register(queueMessages)
+		_ = prometheus.Register(queueConsumers)
+	})
+
+	for {
+		wait := r.opt.depthInterval
+		if r.opt.depthJitter > 0 {
+			wait += time.Duration(rand.Int63n(int64(r.opt.depthJitter)))
+		}
+
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		r.updateDepth(r.ctx)
+	}
+}
+
+// updateDepth inspect every registered queue once
+func (r *rabbitMqWorker) updateDepth(ctx context.Context) {
+	for queue := range r.handlers {
+		ctxTimeout, cancel := context.WithTimeout(ctx, r.opt.depthInterval)
+		q, err := r.inspectQueue(ctxTimeout, queue)
+		cancel()
+		if err != nil {
+			log.Printf("rabbitmq_consumer > inspect queue %s: %s", queue, err)
+			continue
+		}
+
+		queueMessages.WithLabelValues(queue).Set(float64(q.Messages))
+		queueConsumers.WithLabelValues(queue).Set(float64(q.Consumers))
+	}
+}
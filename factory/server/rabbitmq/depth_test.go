@@ -0,0 +1,49 @@
+package rabbitmq
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/streadway/amqp"
+)
+
+type fakeInspector struct {
+	queues map[string]amqp.Queue
+}
+
+func (f *fakeInspector) QueueInspect(name string) (amqp.Queue, error) {
+	return f.queues[name], nil
+}
+
+func TestQueueDepth(t *testing.T) {
+	w := &rabbitMqWorker{
+		ctx:      context.Background(),
+		opt:      option{depthInterval: time.Second},
+		handlers: map[string]types.BrokerHandler{"order.created": {}},
+		inspector: &fakeInspector{queues: map[string]amqp.Queue{
+			"order.created": {Name: "order.created", Messages: 42, Consumers: 3},
+		}},
+	}
+
+	var di DepthInspector = w
+	depth, err := di.QueueDepth(context.Background(), "order.created")
+	if err != nil || depth != 42 {
+		t.Fatalf("expected depth 42, got %d %v", depth, err)
+	}
+
+	if _, err = w.QueueDepth(context.Background(), "unknown"); err == nil {
+		t.Error("expected unregistered queue rejected")
+	}
+
+	w.updateDepth(context.Background())
+	if got := testutil.ToFloat64(queueMessages.WithLabelValues("order.created")); got != 42 {
+		t.Errorf("expected messages gauge 42, got %v", got)
+	}
+
+	if got := testutil.ToFloat64(queueConsumers.WithLabelValues("order.created")); got != 3 {
+		t.Errorf("expected consumers gauge 3, got %v", got)
+	}
+}
@@ -1,6 +1,11 @@
 package rabbitmq
 
-import "github.com/TixiaOTA/gokit/utils/env"
+import (
+	"time"
+
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/TixiaOTA/gokit/utils/env"
+)
 
 type option struct {
 	exchangeName  string
@@ -10,6 +15,14 @@ type option struct {
 	debugMode     bool
 	isAutoAck     bool
 	serviceName   string
+	depthInterval time.Duration
+	depthJitter   time.Duration
+
+	// never install the logger store of consumed messages
+	disableMessageLog bool
+
+	// size guard of consumed payloads, see types.DecodePayload
+	payloadLimits types.PayloadLimits
 }
 
 type OptionFunc func(*option)
@@ -18,6 +31,10 @@ func getDefaultOption() option {
 	return option{
 		maxGoroutines: env.GetInteger("BROKER_MAX_GOROUTINES", 20),
 		debugMode:     env.GetBool("DEBUG_MODE"),
+		depthInterval: env.GetDuration("BROKER_DEPTH_INTERVAL", 30*time.Second),
+		depthJitter:   env.GetDuration("BROKER_DEPTH_JITTER", 5*time.Second),
+
+		disableMessageLog: env.GetBool("BROKER_DISABLE_MESSAGE_LOG"),
 	}
 }
 
@@ -55,3 +72,28 @@ func SetServiceName(serviceName string) OptionFunc {
 		o.serviceName = serviceName
 	}
 }
+
+// SetDepthInterval option func, interval of queue depth collection plus random jitter up to jitter,
+// zero interval disables the collector
+func SetDepthInterval(interval, jitter time.Duration) OptionFunc {
+	return func(o *option) {
+		o.depthInterval = interval
+		o.depthJitter = jitter
+	}
+}
+
+// DisableMessageLog never wrap consumed messages in a logger store, handlers log without context
+// and no entry is written per message
+func DisableMessageLog() OptionFunc {
+	return func(o *option) {
+		o.disableMessageLog = true
+	}
+}
+
+// SetPayloadLimits option func, consumed payload bigger than MaxMessageBytes after decompression is
+// rejected without requeue. publish limits are set on the publisher, see broker/rabbitmq SetPayloadLimits
+func SetPayloadLimits(limits types.PayloadLimits) OptionFunc {
+	return func(o *option) {
+		o.payloadLimits = limits
+	}
+}
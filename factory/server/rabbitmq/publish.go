@@ -0,0 +1,46 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/TixiaOTA/gokit/types"
+)
+
+// errNoPublisher returned when handler buffer messages but broker has no publisher
+var errNoPublisher = errors.New("rabbitmq_consumer: broker has no publisher for buffered messages")
+
+// flushPublishBuffer publish messages buffered by handler in order, stop on the first failure.
+// messages published before the failure are skipped once the message of id is redelivered.
+// combine with an outbox publisher when follow-up events must be fully transactional
+func (r *rabbitMqWorker) flushPublishBuffer(ctx context.Context, ec *types.EventContext, id string) error {
+	buf := ec.PublishBuffer()
+	if buf.Len() < 1 {
+		return nil
+	}
+	defer buf.Reset()
+
+	if r.publisher == nil {
+		return errNoPublisher
+	}
+
+	// validate every payload first so an invalid one never leaves the others published,
+	// payloads are encoded once by the publisher
+	messages := make([]types.PublisherArgument, 0, buf.Len())
+	for _, args := range buf.Messages() {
+		if err := args.Validate(); err != nil {
+			return err
+		}
+
+		args.Headers = logger.InjectBaggage(ctx, args.Headers)
+		messages = append(messages, args)
+	}
+
+	if i, err := r.publisher.Publish(ctx, id, messages); err != nil {
+		return fmt.Errorf("rabbitmq_consumer: publish buffered message %d/%d: %w", i+1, buf.Len(), err)
+	}
+
+	return nil
+}
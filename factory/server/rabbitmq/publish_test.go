@@ -0,0 +1,355 @@
+package rabbitmq
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/streadway/amqp"
+)
+
+type fakeAcknowledger struct {
+	acked, nacked bool
+	requeue       bool
+	settled       int
+}
+
+func (f *fakeAcknowledger) Ack(_ uint64, _ bool) error {
+	f.acked = true
+	f.settled++
+	return nil
+}
+
+func (f *fakeAcknowledger) Nack(_ uint64, _ bool, requeue bool) error {
+	f.nacked, f.requeue = true, requeue
+	f.settled++
+	return nil
+}
+
+func (f *fakeAcknowledger) Reject(_ uint64, requeue bool) error {
+	f.nacked, f.requeue = true, requeue
+	f.settled++
+	return nil
+}
+
+type fakePublisher struct {
+	err       error
+	published []types.PublisherArgument
+
+	// limits encode published payloads like a broker publisher
+	limits *types.PayloadLimits
+}
+
+func (f *fakePublisher) PublishMessage(_ context.Context, req types.PublisherArgument) error {
+	if f.err != nil {
+		return f.err
+	}
+
+	if f.limits != nil {
+		var err error
+		if req, err = req.Encode(*f.limits); err != nil {
+			return err
+		}
+	}
+
+	f.published = append(f.published, req)
+	return nil
+}
+
+func newTestWorker(pub *fakePublisher) *rabbitMqWorker {
+	w := &rabbitMqWorker{
+		ctx: context.Background(),
+		opt: option{isAutoAck: true, serviceName: "test", queue: "order.created"},
+		tz:  time.UTC,
+	}
+	if pub != nil {
+		w.publisher = types.NewTailPublisher(pub.PublishMessage)
+	}
+	w.handlers = map[string]types.BrokerHandler{
+		"order.created": {HandlerFunc: func(ec *types.EventContext) error {
+			ec.PublishBuffer().Publish(types.PublisherArgument{Exchange: "order", Key: "order.paid"})
+			return nil
+		}},
+	}
+
+	return w
+}
+
+func TestPublishBufferFailureRedelivers(t *testing.T) {
+	pub := &fakePublisher{err: errors.New("channel closed")}
+	ack := &fakeAcknowledger{}
+
+	newTestWorker(pub).processMessage(amqp.Delivery{Acknowledger: ack, RoutingKey: "order.created"}, "worker-1")
+
+	if ack.acked || !ack.nacked || !ack.requeue {
+		t.Errorf("expected original message requeued, got acked=%v nacked=%v requeue=%v", ack.acked, ack.nacked, ack.requeue)
+	}
+
+	if len(pub.published) != 0 {
+		t.Errorf("expected no orphan event, got %d", len(pub.published))
+	}
+}
+
+func TestPublishBufferPartialFailurePublishesTail(t *testing.T) {
+	pub := &fakePublisher{}
+	w := newTestWorker(pub)
+	w.handlers["order.created"] = types.BrokerHandler{HandlerFunc: func(ec *types.EventContext) error {
+		for _, key := range []string{"order.paid", "order.packed", "order.shipped"} {
+			ec.PublishBuffer().Publish(types.PublisherArgument{Exchange: "order", Key: key})
+		}
+		return nil
+	}}
+
+	// second publish fails, the first is already sent
+	failing := &failAfterPublisher{fakePublisher: pub, after: 1}
+	w.publisher = types.NewTailPublisher(failing.PublishMessage)
+	ack := &fakeAcknowledger{}
+	w.processMessage(amqp.Delivery{Acknowledger: ack, RoutingKey: "order.created", MessageId: "msg-1"}, "worker-1")
+	if !ack.nacked || !ack.requeue || len(pub.published) != 1 {
+		t.Fatalf("expected message requeued after one publish, got requeue=%v published=%d", ack.requeue, len(pub.published))
+	}
+
+	// redelivery publishes only the unsent tail, once the publisher recovered
+	failing.after = 3
+	ack = &fakeAcknowledger{}
+	w.processMessage(amqp.Delivery{Acknowledger: ack, RoutingKey: "order.created", MessageId: "msg-1", Redelivered: true}, "worker-1")
+	if !ack.acked {
+		t.Errorf("expected redelivered message acked")
+	}
+
+	var keys []string
+	for _, p := range pub.published {
+		keys = append(keys, p.Key)
+	}
+	if len(keys) != 3 || keys[0] != "order.paid" || keys[1] != "order.packed" || keys[2] != "order.shipped" {
+		t.Errorf("expected each buffered message published once, got %v", keys)
+	}
+	if w.publisher.Sent("msg-1") != 0 {
+		t.Errorf("expected progress forgotten once the buffer is published")
+	}
+}
+
+// failAfterPublisher publish the first after messages then fail
+type failAfterPublisher struct {
+	*fakePublisher
+	after int
+}
+
+func (f *failAfterPublisher) PublishMessage(ctx context.Context, req types.PublisherArgument) error {
+	if len(f.published) >= f.after {
+		return errors.New("channel closed")
+	}
+
+	return f.fakePublisher.PublishMessage(ctx, req)
+}
+
+func TestPublishBufferSuccessAcks(t *testing.T) {
+	pub := &fakePublisher{}
+	ack := &fakeAcknowledger{}
+
+	newTestWorker(pub).processMessage(amqp.Delivery{Acknowledger: ack, RoutingKey: "order.created"}, "worker-1")
+
+	if !ack.acked || ack.nacked {
+		t.Errorf("expected message acked, got acked=%v nacked=%v", ack.acked, ack.nacked)
+	}
+
+	if len(pub.published) != 1 || pub.published[0].Key != "order.paid" {
+		t.Errorf("expected buffered event published, got %v", pub.published)
+	}
+}
+
+func TestDeliveryMetadata(t *testing.T) {
+	var got types.Delivery
+	enqueuedAt := time.Unix(1700000000, 0)
+
+	w := newTestWorker(&fakePublisher{})
+	w.handlers["order.created"] = types.BrokerHandler{
+		Queue: "order.created",
+		HandlerFunc: func(ec *types.EventContext) error {
+			got, _ = types.DeliveryFromContext(ec.Context())
+			return nil
+		},
+	}
+
+	w.processMessage(amqp.Delivery{
+		Acknowledger: &fakeAcknowledger{},
+		Exchange:     "order",
+		RoutingKey:   "order.created",
+		Redelivered:  true,
+		Timestamp:    enqueuedAt,
+		Headers:      amqp.Table{headerRetryAttempt: int32(2)},
+	}, "worker-1")
+
+	if got.Queue != "order.created" || got.Exchange != "order" || !got.Redelivered || got.Attempt != 2 || !got.EnqueuedAt.Equal(enqueuedAt) {
+		t.Errorf("unexpected delivery metadata %+v", got)
+	}
+}
+
+func TestPublishBufferCompression(t *testing.T) {
+	limits := types.PayloadLimits{MaxMessageBytes: 1024, CompressThreshold: 64}
+	payload := bytes.Repeat([]byte(`{"id":1},`), 50)
+	pub := &fakePublisher{limits: &limits}
+	w := newTestWorker(pub)
+	SetPayloadLimits(limits)(&w.opt)
+	w.handlers["order.created"] = types.BrokerHandler{HandlerFunc: func(ec *types.EventContext) error {
+		ec.PublishBuffer().Publish(types.PublisherArgument{Exchange: "order", Key: "order.paid", Message: payload})
+		return nil
+	}}
+
+	before := types.GetPayloadStats()
+	w.processMessage(amqp.Delivery{Acknowledger: &fakeAcknowledger{}, RoutingKey: "order.created"}, "worker-1")
+	if len(pub.published) != 1 || pub.published[0].Headers[types.HeaderContentEncoding] != types.EncodingGzip {
+		t.Fatalf("expected compressed event published, got %v", pub.published)
+	}
+
+	// consume the published event back
+	var got []byte
+	w.handlers["order.paid"] = types.BrokerHandler{HandlerFunc: func(ec *types.EventContext) error {
+		got = append([]byte(nil), ec.Message()...)
+		return nil
+	}}
+	w.processMessage(amqp.Delivery{
+		Acknowledger: &fakeAcknowledger{},
+		RoutingKey:   "order.paid",
+		Headers:      amqp.Table{types.HeaderContentEncoding: types.EncodingGzip},
+		Body:         pub.published[0].Message,
+	}, "worker-1")
+	if !bytes.Equal(got, payload) {
+		t.Errorf("expected handler get decompressed payload, got %s", got)
+	}
+
+	// encoded by the publisher only, decoded once by the worker
+	if after := types.GetPayloadStats(); after.Published-before.Published != 1 || after.DecodedMessages-before.DecodedMessages != 1 {
+		t.Errorf("expected message counted once on publish and consume, got %+v", after)
+	}
+
+	// incompressible oversized payload is rejected on publish, the original message is requeued
+	pub.published = nil
+	payload = make([]byte, 2048)
+	_, _ = rand.Read(payload)
+	ack := &fakeAcknowledger{}
+	w.processMessage(amqp.Delivery{Acknowledger: ack, RoutingKey: "order.created"}, "worker-1")
+	if len(pub.published) != 0 || !ack.requeue {
+		t.Fatalf("expected oversized event rejected and original requeued, got %d published requeue=%v", len(pub.published), ack.requeue)
+	}
+
+	// oversized payload is dead-lettered on consume
+	w.opt.isAutoAck = false
+	ack = &fakeAcknowledger{}
+	w.processMessage(amqp.Delivery{Acknowledger: ack, RoutingKey: "order.paid", Body: payload}, "worker-1")
+	if !ack.nacked || ack.requeue {
+		t.Errorf("expected oversized message rejected without requeue, got nacked=%v requeue=%v", ack.nacked, ack.requeue)
+	}
+}
+
+func TestHandlerTimeout(t *testing.T) {
+	w := newTestWorker(&fakePublisher{})
+	w.opt.isAutoAck = false
+	w.handlers["order.created"] = types.BrokerHandler{
+		Queue:          "order.created",
+		HandlerTimeout: 20 * time.Millisecond,
+		HandlerFunc: func(ec *types.EventContext) error {
+			<-ec.Context().Done()
+			return ec.Context().Err()
+		},
+	}
+
+	ack := &fakeAcknowledger{}
+	start := time.Now()
+	w.processMessage(amqp.Delivery{Acknowledger: ack, RoutingKey: "order.created", MessageId: "msg-1"}, "worker-1")
+	if elapsed := time.Since(start); elapsed > 80*time.Millisecond {
+		t.Errorf("expected message settled on timeout, took %s", elapsed)
+	}
+	if ack.acked || !ack.nacked || !ack.requeue || ack.settled != 1 {
+		t.Errorf("expected single requeue nack, got acked=%v nacked=%v requeue=%v settled=%d", ack.acked, ack.nacked, ack.requeue, ack.settled)
+	}
+
+	// a handler ignoring its context is not awaited, its late nil is not a success
+	returned := make(chan struct{})
+	w.opt.isAutoAck = true
+	w.handlers["order.created"] = types.BrokerHandler{
+		Queue:          "order.created",
+		HandlerTimeout: 20 * time.Millisecond,
+		HandlerFunc: func(ec *types.EventContext) error {
+			defer close(returned)
+			time.Sleep(50 * time.Millisecond)
+			ec.PublishBuffer().Publish(types.PublisherArgument{Exchange: "order", Key: "order.paid"})
+			return nil
+		},
+	}
+
+	ack = &fakeAcknowledger{}
+	start = time.Now()
+	w.processMessage(amqp.Delivery{Acknowledger: ack, RoutingKey: "order.created", MessageId: "msg-2"}, "worker-1")
+	if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+		t.Errorf("expected message settled at the deadline, took %s", elapsed)
+	}
+
+	<-returned
+	if ack.acked || !ack.nacked || !ack.requeue || ack.settled != 1 {
+		t.Errorf("expected single requeue nack, got acked=%v nacked=%v requeue=%v settled=%d", ack.acked, ack.nacked, ack.requeue, ack.settled)
+	}
+}
+
+func TestSettleOnce(t *testing.T) {
+	ack := &fakeAcknowledger{}
+	message := amqp.Delivery{Acknowledger: &onceAcknowledger{Acknowledger: ack}}
+
+	_ = message.Nack(false, true)
+	_ = message.Ack(false)
+	if ack.settled != 1 || ack.acked {
+		t.Errorf("expected delivery settled once, got settled=%d acked=%v", ack.settled, ack.acked)
+	}
+}
+
+// routingPublisher in-memory broker delivering published messages straight into worker
+type routingPublisher struct {
+	worker *rabbitMqWorker
+}
+
+func (p *routingPublisher) PublishMessage(_ context.Context, req types.PublisherArgument) error {
+	p.worker.processMessage(amqp.Delivery{
+		Acknowledger: &fakeAcknowledger{},
+		RoutingKey:   req.Key,
+		Headers:      amqp.Table(req.Headers),
+		Body:         req.Message,
+	}, "worker-1")
+	return nil
+}
+
+func TestBaggageSurvivesHops(t *testing.T) {
+	w := newTestWorker(nil)
+	w.publisher = types.NewTailPublisher((&routingPublisher{worker: w}).PublishMessage)
+
+	var tenant, locale string
+	w.handlers = map[string]types.BrokerHandler{
+		"order.created": {HandlerFunc: func(ec *types.EventContext) error {
+			ec.PublishBuffer().Publish(types.PublisherArgument{Key: "order.paid"})
+			return nil
+		}},
+		"order.paid": {HandlerFunc: func(ec *types.EventContext) error {
+			ec.PublishBuffer().Publish(types.PublisherArgument{Key: "order.shipped"})
+			return nil
+		}},
+		"order.shipped": {HandlerFunc: func(ec *types.EventContext) error {
+			bag := logger.GetBaggage(ec.Context())
+			tenant, locale = bag["tenant_id"], bag["locale"]
+			return nil
+		}},
+	}
+
+	w.processMessage(amqp.Delivery{
+		Acknowledger: &fakeAcknowledger{},
+		RoutingKey:   "order.created",
+		Headers:      amqp.Table{"baggage-tenant-id": "acme", "baggage-locale": "id", "baggage-secret": "x"},
+	}, "worker-1")
+
+	if tenant != "acme" || locale != "id" {
+		t.Errorf("expected baggage after two hops, got tenant %q locale %q", tenant, locale)
+	}
+}
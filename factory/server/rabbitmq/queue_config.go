@@ -6,7 +6,24 @@ import (
 	"github.com/streadway/amqp"
 )
 
-func setupQueueConfig(ch *amqp.Channel, exchangeName, queueName string) (<-chan amqp.Delivery, error) {
+// topologyChannel channel declaring and consuming queues, implemented by *amqp.Channel
+type topologyChannel interface {
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	Qos(prefetchCount, prefetchSize int, global bool) error
+}
+
+// workerChannel channel used by worker, implemented by *amqp.Channel
+type workerChannel interface {
+	topologyChannel
+	channelPublisher
+	queueInspector
+	Close() error
+}
+
+// setupQueueConfig declare and bind queue, then consume it with at most prefetch unsettled deliveries
+func setupQueueConfig(ch topologyChannel, exchangeName, queueName string, prefetch int) (<-chan amqp.Delivery, error) {
 	queue, err := ch.QueueDeclare(queueName, true, false, false, false, nil)
 	if err != nil {
 		return nil, fmt.Errorf("error in declaring the queue %s", err)
@@ -14,6 +31,10 @@ func setupQueueConfig(ch *amqp.Channel, exchangeName, queueName string) (<-chan
 	if err = ch.QueueBind(queue.Name, queue.Name, exchangeName, false, nil); err != nil {
 		return nil, fmt.Errorf("error binding queue: %s", err)
 	}
+	// applies to the consumer registered next on the channel
+	if err = ch.Qos(prefetch, 0, false); err != nil {
+		return nil, fmt.Errorf("error setting prefetch: %s", err)
+	}
 
 	return ch.Consume(
 		queue.Name,
@@ -2,6 +2,7 @@ package rabbitmq
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -9,11 +10,13 @@ import (
 	"sync"
 	"time"
 
+	rabbitbroker "github.com/TixiaOTA/gokit/broker/rabbitmq"
 	"github.com/TixiaOTA/gokit/factory"
 	"github.com/TixiaOTA/gokit/logger"
 	"github.com/TixiaOTA/gokit/tracer"
 	"github.com/TixiaOTA/gokit/types"
 	"github.com/TixiaOTA/gokit/utils/convert"
+	"github.com/TixiaOTA/gokit/utils/lanes"
 	"github.com/TixiaOTA/gokit/utils/timezone"
 	"github.com/google/uuid"
 
@@ -25,13 +28,30 @@ type rabbitMqWorker struct {
 	cancelFunc func()
 	opt        option
 	tz         *time.Location
-	ch         *amqp.Channel
+	ch         workerChannel
 	shutdown   chan struct{}
 	isShutdown bool
-	semaphore  []chan struct{}
+	semaphore  []chan int
+	lanes      []*lanes.Dispatcher
 	wg         sync.WaitGroup
 	channels   []reflect.SelectCase
 	handlers   map[string]types.BrokerHandler
+	publisher  *types.TailPublisher // nil when the broker has no publisher
+	topology   []types.BrokerHandler
+	retryCh    channelPublisher
+	replyCh    channelPublisher
+	inspector  queueInspector
+
+	// connection recovery of broker, nil when the broker does not recover its connection
+	recovery   *rabbitbroker.Broker
+	generation uint64
+	queues     []string
+
+	// finalize write the data logger of a message, default to DataLogger.Finalize
+	finalize func(ctx context.Context, ol *logger.DataLogger)
+
+	// set once Serve consumes the queues declared by New, see Ready
+	ready factory.ReadySignal
 }
 
 // New create new rabbitmq consumer
@@ -53,7 +73,19 @@ func New(service factory.ServiceFactory, opts ...OptionFunc) factory.Application
 	}
 
 	worker.ctx, worker.cancelFunc = context.WithCancel(context.Background())
-	worker.ch = service.GetBroker(types.RabbitMQ).GetConfiguration().(*amqp.Channel)
+	if rb, ok := service.GetBroker(types.RabbitMQ).(*rabbitbroker.Broker); ok {
+		worker.recovery = rb
+		worker.ch = rb.Channel()
+		worker.generation = rb.Session().Generation
+	} else {
+		worker.ch = service.GetBroker(types.RabbitMQ).GetConfiguration().(*amqp.Channel)
+	}
+	if p := service.GetBroker(types.RabbitMQ).GetPublisher(); p != nil {
+		worker.publisher = types.NewTailPublisher(p.PublishMessage)
+	}
+	worker.retryCh = worker.ch
+	worker.replyCh = worker.ch
+	worker.inspector = worker.ch
 	worker.shutdown = make(chan struct{}, 1)
 	worker.handlers = make(map[string]types.BrokerHandler)
 
@@ -64,19 +96,23 @@ func New(service factory.ServiceFactory, opts ...OptionFunc) factory.Application
 		for _, handler := range hg.Handlers {
 			worker.opt.exchangeName, worker.opt.queue, worker.opt.isAutoAck = handler.Exchange, handler.Queue, handler.IsAutoAck
 
-			queueChan, err := setupQueueConfig(worker.ch, worker.opt.exchangeName, worker.opt.queue)
+			// validate mode never touch the broker
+			if factory.IsValidateMode() {
+				worker.topology = append(worker.topology, handler)
+				continue
+			}
+
+			consumer, err := subscribe(worker.ch, handler, worker.prefetch(handler))
 			if err != nil {
 				panic(err)
 			}
 			logger.Purple(fmt.Sprintf(`[RABBITMQ-CONSUMER] (exchange): %-15s (queue): %-15s`, `"`+worker.opt.exchangeName+`"`, `"`+worker.opt.queue+`"`))
 
-			worker.channels = append(
-				worker.channels, reflect.SelectCase{
-					Dir: reflect.SelectRecv, Chan: reflect.ValueOf(queueChan),
-				},
-			)
+			worker.channels = append(worker.channels, consumer)
+			worker.queues = append(worker.queues, handler.Queue)
 			worker.handlers[worker.opt.queue] = handler
-			worker.semaphore = append(worker.semaphore, make(chan struct{}, 1))
+			worker.semaphore = append(worker.semaphore, newSlots(1))
+			worker.lanes = append(worker.lanes, worker.newLanes(handler))
 		}
 	}
 	logger.PurpleBold(fmt.Sprintf("⇨ RabbitMQ consumer running with %d queue", len(worker.channels)))
@@ -92,7 +128,12 @@ func (r *rabbitMqWorker) Shutdown(_ context.Context) {
 	r.isShutdown = true
 	var runningJob int
 	for _, semp := range r.semaphore {
-		runningJob += len(semp)
+		runningJob += cap(semp) - len(semp)
+	}
+	for _, l := range r.lanes {
+		if l != nil {
+			runningJob += l.Pending()
+		}
 	}
 
 	if runningJob != 0 {
@@ -100,12 +141,21 @@ func (r *rabbitMqWorker) Shutdown(_ context.Context) {
 	}
 
 	r.wg.Wait()
+	// drain ordered lanes, queued messages are handled in order before the channel is closed
+	for _, l := range r.lanes {
+		if l != nil {
+			l.Close()
+		}
+	}
 	defer logger.RedBold("Stopping RabbitMQ Broker")
 	_ = r.ch.Close()
 	r.cancelFunc()
 }
 
 func (r *rabbitMqWorker) Serve() {
+	factory.GoSafe("rabbitmq-depth", r.collectDepth)
+	r.ready.Set()
+
 	for {
 		select {
 		case <-r.shutdown:
@@ -115,28 +165,60 @@ func (r *rabbitMqWorker) Serve() {
 
 		chosen, value, ok := reflect.Select(r.channels)
 		if !ok {
+			// consumer channel is closed with the lost connection
+			if r.recovery != nil && !r.resume() {
+				return
+			}
 			continue
 		}
 
 		// execute handler
 		if msg, ok := value.Interface().(amqp.Delivery); ok {
-			r.semaphore[chosen] <- struct{}{}
+			if l := r.lanes[chosen]; l != nil {
+				if r.isShutdown {
+					return
+				}
+
+				// blocks while the lane is full, stop fetching until the lane has room
+				// decoded once, the key and the handler see the same payload
+				header := deliveryHeader(msg)
+				body, decodeErr := types.DecodePayload(msg.Body, header, r.opt.payloadLimits)
+				key := r.handlers[msg.RoutingKey].KeyFunc(body, header)
+				workerId := fmt.Sprintf("%s#%d", r.handlers[msg.RoutingKey].Queue, l.Lane(key))
+				l.Dispatch(key, func() { r.handleMessage(msg, body, decodeErr, workerId) })
+				continue
+			}
+
+			slot := <-r.semaphore[chosen]
 			if r.isShutdown {
 				return
 			}
 
 			r.wg.Add(1)
 			go func(message amqp.Delivery, index int) {
-				r.processMessage(message)
+				r.processMessage(message, fmt.Sprintf("%s#%d", r.handlers[message.RoutingKey].Queue, slot))
 				r.wg.Done()
-				<-r.semaphore[index]
+				r.semaphore[index] <- slot
 			}(msg, chosen)
 		}
 	}
 }
 
-func (r *rabbitMqWorker) processMessage(message amqp.Delivery) {
-	start := time.Now().In(r.tz)
+// Ready wait until Serve consumes the queues, see factory.Readier
+func (r *rabbitMqWorker) Ready(ctx context.Context) error {
+	return r.ready.Wait(ctx)
+}
+
+// processMessage decode payload of message and handle it, log messages of handler are stamped with workerId
+func (r *rabbitMqWorker) processMessage(message amqp.Delivery, workerId string) {
+	body, err := types.DecodePayload(message.Body, deliveryHeader(message), r.opt.payloadLimits)
+	r.handleMessage(message, body, err, workerId)
+}
+
+// handleMessage handle message with its decoded payload, a decode error settles the message as failed
+// without calling the handler
+func (r *rabbitMqWorker) handleMessage(message amqp.Delivery, body []byte, decodeErr error, workerId string) {
+	start := logger.Now().In(r.tz)
 
 	if r.ctx.Err() != nil {
 		log.Printf("rabbitmq_consumer > ctx root err: %s", r.ctx.Err())
@@ -145,26 +227,32 @@ func (r *rabbitMqWorker) processMessage(message amqp.Delivery) {
 
 	ctx := r.ctx
 	selectedHandler := r.handlers[message.RoutingKey]
+	message.Acknowledger = &onceAcknowledger{Acknowledger: message.Acknowledger}
 
-	header := map[string]string{}
-	for key, val := range message.Headers {
-		header[key] = convert.ToString(val)
-	}
+	header := deliveryHeader(message)
 
 	var err error
+	var requeue, retried bool
+	var reply []byte
 	trace, ctx := tracer.StartTraceWithContext(ctx, "RabbitMqConsumer")
 
+	requestId := header[types.HeaderRequestId]
+	if requestId == "" {
+		requestId = uuid.NewString()
+	}
+
 	// implement logging
 	// init logger data
 	ol := &logger.DataLogger{
 		TimeStart:     start,
-		RequestId:     uuid.NewString(),
+		RequestId:     requestId,
 		Type:          logger.ServiceType(types.RabbitMQ.String()),
 		Service:       r.opt.serviceName,
-		Endpoint:      fmt.Sprintf("queue: %s", r.opt.queue),
+		Endpoint:      fmt.Sprintf("queue: %s", selectedHandler.Queue),
 		RequestBody:   string(message.Body),
 		RequestMethod: "CONSUME",
 		RequestHeader: fmt.Sprintf("Exchange: %s | Routing Key: %s | Header: %v", message.Exchange, message.RoutingKey, header),
+		Message:       &logger.MessageLog{Queue: selectedHandler.Queue, MessageId: message.MessageId},
 	}
 
 	defer func() {
@@ -175,7 +263,7 @@ func (r *rabbitMqWorker) processMessage(message amqp.Delivery) {
 		sc := http.StatusOK
 
 		ack := false
-		if r.opt.isAutoAck {
+		if r.opt.isAutoAck && !requeue {
 			ack = true
 		}
 
@@ -184,31 +272,65 @@ func (r *rabbitMqWorker) processMessage(message amqp.Delivery) {
 
 			sc = http.StatusInternalServerError
 			ol.ErrorMessage = fmt.Sprintf("%s", err)
+
+			// delay redelivery on retry queue, the original message is acked once its copy is scheduled
+			var re error
+			if retried, re = r.scheduleRetry(message, selectedHandler, err); re != nil {
+				ol.ErrorMessage = fmt.Sprintf("%s, %s", err, re)
+			} else if retried {
+				ack = true
+			}
 		} else {
 
 			ol.Response = "success"
 		}
 
+		// reply once, handler error is replied only when the message is never redelivered
+		if err == nil || (!retried && (ack || errors.Is(err, types.ErrNonRetryable))) {
+			r.publishReply(message, reply, err)
+		}
+
+		// settle only this delivery, deliveries of other lanes may still be in progress
 		if ack {
-			_ = message.Ack(true)
+			_ = message.Ack(false)
+			ol.Message.Outcome = logger.OutcomeAck
+			if retried {
+				ol.Message.Outcome = logger.OutcomeRetry
+			}
+		} else if errors.Is(err, types.ErrNonRetryable) {
+			// dead-letter instead of redelivering a message that can never succeed
+			_ = message.Reject(false)
+			ol.Message.Outcome = logger.OutcomeDLQ
 		} else {
-			_ = message.Reject(true)
-			_ = message.Nack(true, true)
+			// settle once, a second settlement of the same delivery tag closes the channel
+			_ = message.Nack(false, true)
+			ol.Message.Outcome = logger.OutcomeNack
 		}
 
 		trace.SetTag("trace_id", tracer.GetTraceID(ctx))
 		ol.StatusCode = sc
-		ol.ExecTime = time.Since(start).Seconds()
+		ol.ExecTime = logger.Now().Sub(start).Seconds()
 		logger.Response(ctx, sc, ol.Response, err)
 		// finish trace and logging
 		trace.Finish()
-		ol.Finalize(ctx)
+		r.flush(ctx, ol)
 	}()
 
-	// implement locking logging stdout
-	var lock = new(logger.Locker)
-	// set to context with logger.LogKey as a context key
-	ctx = context.WithValue(ctx, logger.LogKey, lock)
+	// logger store of the message, flushed as one entry once the message is settled
+	if !r.opt.disableMessageLog {
+		ctx = logger.NewContext(ctx, requestId)
+	}
+	ctx = logger.WithWorkerID(ctx, workerId)
+	logger.RestoreBaggage(ctx, header)
+	ctx = types.ContextWithDelivery(ctx, types.Delivery{
+		Queue:       selectedHandler.Queue,
+		Exchange:    message.Exchange,
+		RoutingKey:  message.RoutingKey,
+		Redelivered: message.Redelivered,
+		Attempt:     retryAttempt(message),
+		Headers:     message.Headers,
+		EnqueuedAt:  message.Timestamp,
+	})
 
 	trace.SetTag("exchange", message.Exchange)
 	trace.SetTag("routing_key", message.RoutingKey)
@@ -223,10 +345,188 @@ func (r *rabbitMqWorker) processMessage(message amqp.Delivery) {
 	ec.SetHandlerRoute(message.RoutingKey)
 	ec.SetKey(message.Exchange)
 	ec.SetHeader(header)
-	_, _ = ec.Write(message.Body)
+	ec.SetContentType(message.ContentType)
 
-	handlerFunc := selectedHandler
-	if err = handlerFunc.HandlerFunc(&ec); err != nil {
+	if err = decodeErr; err != nil {
+		ec.SetError(err)
+		return
+	}
+	_, _ = ec.Write(body)
+
+	if err = selectedHandler.Call(&ec); err != nil {
+		if errors.Is(err, types.ErrHandlerTimeout) {
+			// redelivered even with auto ack, the handler may still be running
+			logger.Log.Errorf(ctx, "slow handler on queue %s, message id %s: %s", selectedHandler.Queue, message.MessageId, err)
+			requeue = true
+		}
 		ec.SetError(err)
+		return
+	}
+
+	// publish buffered messages just before acking, the original message is redelivered on failure
+	if err = r.flushPublishBuffer(ctx, &ec, message.MessageId); err != nil {
+		ec.SetError(err)
+		requeue = true
+		return
+	}
+
+	reply = ec.ReplyPayload()
+}
+
+// onceAcknowledger settle a delivery once, a second settlement of the same delivery tag closes the channel
+type onceAcknowledger struct {
+	amqp.Acknowledger
+	once sync.Once
+}
+
+func (o *onceAcknowledger) Ack(tag uint64, multiple bool) (err error) {
+	o.once.Do(func() { err = o.Acknowledger.Ack(tag, multiple) })
+	return err
+}
+
+func (o *onceAcknowledger) Nack(tag uint64, multiple, requeue bool) (err error) {
+	o.once.Do(func() { err = o.Acknowledger.Nack(tag, multiple, requeue) })
+	return err
+}
+
+func (o *onceAcknowledger) Reject(tag uint64, requeue bool) (err error) {
+	o.once.Do(func() { err = o.Acknowledger.Reject(tag, requeue) })
+	return err
+}
+
+// flush write the data logger of a message, see DisableMessageLog
+func (r *rabbitMqWorker) flush(ctx context.Context, ol *logger.DataLogger) {
+	if r.finalize != nil {
+		r.finalize(ctx, ol)
+		return
 	}
+
+	ol.Finalize(ctx)
+}
+
+// subscribe declare topology of handler and consume its queue
+func subscribe(ch topologyChannel, handler types.BrokerHandler, prefetch int) (reflect.SelectCase, error) {
+	queueChan, err := setupQueueConfig(ch, handler.Exchange, handler.Queue, prefetch)
+	if err != nil {
+		return reflect.SelectCase{}, err
+	}
+	if err = setupRetryQueues(ch, handler.Exchange, handler.Queue, handler.RetryTiers); err != nil {
+		return reflect.SelectCase{}, err
+	}
+
+	return reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(queueChan)}, nil
+}
+
+// resume wait until the broker recovers the connection, then re-declare topology and re-register consumers,
+// returns false when the worker is shut down while waiting
+func (r *rabbitMqWorker) resume() bool {
+	for {
+		s := r.recovery.Session()
+		if s.Generation == r.generation {
+			select {
+			case <-r.shutdown:
+				return false
+			case <-s.Recovered:
+			}
+			continue
+		}
+
+		channels := make([]reflect.SelectCase, 0, len(r.queues))
+		for _, queue := range r.queues {
+			consumer, err := subscribe(r.ch, r.handlers[queue], r.prefetch(r.handlers[queue]))
+			if err != nil {
+				// wait for the next recovery, the channel is closed again
+				log.Printf("rabbitmq_consumer > resume queue %s: %s", queue, err)
+				break
+			}
+			channels = append(channels, consumer)
+		}
+
+		r.generation = s.Generation
+		if len(channels) == len(r.queues) {
+			r.channels = channels
+			log.Printf("rabbitmq_consumer > resumed %d queue", len(channels))
+			return true
+		}
+	}
+}
+
+// newLanes ordered lanes of handler, nil when handler is unordered
+func (r *rabbitMqWorker) newLanes(handler types.BrokerHandler) *lanes.Dispatcher {
+	if handler.KeyFunc == nil {
+		return nil
+	}
+
+	return lanes.New(r.laneCount(handler), handler.LaneSize)
+}
+
+// laneCount number of ordered lanes of handler, default to max goroutines
+func (r *rabbitMqWorker) laneCount(handler types.BrokerHandler) int {
+	if handler.Lanes > 0 {
+		return handler.Lanes
+	}
+	if r.opt.maxGoroutines > 0 {
+		return r.opt.maxGoroutines
+	}
+
+	return 1
+}
+
+// prefetch unsettled deliveries of the queue of handler, the capacity of its worker: one message at a time
+// when unordered, the queued and running message of every lane when ordered
+func (r *rabbitMqWorker) prefetch(handler types.BrokerHandler) int {
+	if handler.KeyFunc == nil {
+		return 1
+	}
+
+	size := handler.LaneSize
+	if size < 1 {
+		size = 1
+	}
+
+	return r.laneCount(handler) * (size + 1)
+}
+
+// newSlots semaphore of n free worker slots, the slot index taken by a handler stamps its worker id
+func newSlots(n int) chan int {
+	slots := make(chan int, n)
+	for i := 0; i < n; i++ {
+		slots <- i
+	}
+
+	return slots
+}
+
+func deliveryHeader(message amqp.Delivery) map[string]string {
+	header := make(map[string]string, len(message.Headers))
+	for key, val := range message.Headers {
+		header[key] = convert.ToString(val)
+	}
+
+	return header
+}
+
+// Validate check declared topology of handlers without touching the broker
+func (r *rabbitMqWorker) Validate(_ context.Context) error {
+	var (
+		errs   []error
+		queues = make(map[string]bool)
+	)
+
+	for _, h := range r.topology {
+		switch {
+		case h.Queue == "":
+			errs = append(errs, fmt.Errorf("rabbitmq_consumer: handler of exchange %q has no queue", h.Exchange))
+		case h.Exchange == "":
+			errs = append(errs, fmt.Errorf("rabbitmq_consumer: queue %q has no exchange", h.Queue))
+		case queues[h.Queue]:
+			errs = append(errs, fmt.Errorf("rabbitmq_consumer: queue %q registered more than once", h.Queue))
+		case h.HandlerFunc == nil:
+			errs = append(errs, fmt.Errorf("rabbitmq_consumer: queue %q has no handler func", h.Queue))
+		}
+
+		queues[h.Queue] = true
+	}
+
+	return errors.Join(errs...)
 }
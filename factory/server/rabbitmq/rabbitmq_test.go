@@ -0,0 +1,224 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/TixiaOTA/gokit/utils/lanes"
+	"github.com/streadway/amqp"
+)
+
+func TestMessageLog(t *testing.T) {
+	var flushed []*logger.DataLogger
+	var requestIds []string
+
+	w := &rabbitMqWorker{
+		ctx: context.Background(),
+		opt: option{serviceName: "test", isAutoAck: true},
+		tz:  time.UTC,
+		finalize: func(ctx context.Context, ol *logger.DataLogger) {
+			ol.Collect(ctx, nil)
+			flushed = append(flushed, ol)
+		},
+		handlers: map[string]types.BrokerHandler{
+			"order.created": {
+				Queue: "order.created",
+				HandlerFunc: func(ec *types.EventContext) error {
+					requestIds = append(requestIds, logger.GetRequestId(ec.Context()))
+					logger.Log.Print(ec.Context(), "handling order")
+
+					switch ec.Header()["fail"] {
+					case "retryable":
+						return errors.New("downstream unavailable")
+					case "poison":
+						return fmt.Errorf("%w: malformed order", types.ErrNonRetryable)
+					}
+					return nil
+				},
+			},
+		},
+	}
+
+	// auto ack settles failed messages too, manual ack redelivers or dead-letters them
+	tests := []struct {
+		name    string
+		autoAck bool
+		headers amqp.Table
+		outcome string
+		status  int
+	}{
+		{"success", true, amqp.Table{types.HeaderRequestId: "req-1"}, logger.OutcomeAck, 200},
+		{"failure", false, amqp.Table{types.HeaderRequestId: "req-2", "fail": "retryable"}, logger.OutcomeNack, 500},
+		{"non retryable", false, amqp.Table{types.HeaderRequestId: "req-3", "fail": "poison"}, logger.OutcomeDLQ, 500},
+	}
+
+	for i, tt := range tests {
+		w.opt.isAutoAck = tt.autoAck
+		w.processMessage(amqp.Delivery{
+			Acknowledger: &fakeAcknowledger{},
+			RoutingKey:   "order.created",
+			MessageId:    fmt.Sprintf("msg-%d", i),
+			Headers:      tt.headers,
+			Body:         []byte(`{"id":1}`),
+		}, "order.created")
+
+		if len(flushed) != i+1 {
+			t.Fatalf("%s: expected one entry per message, got %d", tt.name, len(flushed))
+		}
+
+		ol := flushed[i]
+		if ol.RequestId != tt.headers[types.HeaderRequestId] || requestIds[i] != ol.RequestId {
+			t.Errorf("%s: expected request id of header on entry and handler, got %s and %s", tt.name, ol.RequestId, requestIds[i])
+		}
+
+		if ol.Message == nil || ol.Message.Queue != "order.created" || ol.Message.MessageId != fmt.Sprintf("msg-%d", i) || ol.Message.Outcome != tt.outcome {
+			t.Errorf("%s: unexpected message block %+v", tt.name, ol.Message)
+		}
+
+		if ol.StatusCode != tt.status || ol.ExecTime <= 0 {
+			t.Errorf("%s: unexpected status %d or duration %v", tt.name, ol.StatusCode, ol.ExecTime)
+		}
+
+		if len(ol.LogMessages) != 1 || ol.LogMessages[0].Message != "handling order" || ol.LogMessages[0].WorkerId != "order.created" {
+			t.Errorf("%s: expected handler messages on entry, got %+v", tt.name, ol.LogMessages)
+		}
+	}
+
+	// a message without request id header gets a generated one
+	w.processMessage(amqp.Delivery{Acknowledger: &fakeAcknowledger{}, RoutingKey: "order.created", Body: []byte(`{}`)}, "order.created")
+	if id := flushed[len(flushed)-1].RequestId; id == "" || id != requestIds[len(requestIds)-1] {
+		t.Errorf("expected generated request id shared with handler, got %q and %q", id, requestIds[len(requestIds)-1])
+	}
+}
+
+func TestMessageLogDisabled(t *testing.T) {
+	var collected bool
+
+	w := &rabbitMqWorker{
+		ctx: context.Background(),
+		opt: option{serviceName: "test", isAutoAck: true},
+		tz:  time.UTC,
+		finalize: func(ctx context.Context, ol *logger.DataLogger) {
+			collected = ol.Collect(ctx, nil)
+		},
+		handlers: map[string]types.BrokerHandler{
+			"order.created": {Queue: "order.created", HandlerFunc: func(ec *types.EventContext) error { return nil }},
+		},
+	}
+	DisableMessageLog()(&w.opt)
+
+	w.processMessage(amqp.Delivery{Acknowledger: &fakeAcknowledger{}, RoutingKey: "order.created", Body: []byte(`{}`)}, "order.created")
+	if collected {
+		t.Error("expected no logger store when message log is disabled")
+	}
+}
+
+// tagAcknowledger settlements of every delivery tag
+type tagAcknowledger struct {
+	mu       sync.Mutex
+	settled  map[uint64]int
+	multiple bool
+}
+
+func (a *tagAcknowledger) settle(tag uint64, multiple bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.settled[tag]++
+	a.multiple = a.multiple || multiple
+	return nil
+}
+
+func (a *tagAcknowledger) Ack(tag uint64, multiple bool) error { return a.settle(tag, multiple) }
+
+func (a *tagAcknowledger) Nack(tag uint64, multiple bool, _ bool) error {
+	return a.settle(tag, multiple)
+}
+
+func (a *tagAcknowledger) Reject(tag uint64, _ bool) error { return a.settle(tag, false) }
+
+// qosChannel killableChannel recording prefetch
+type qosChannel struct {
+	killableChannel
+	prefetch int
+}
+
+func (q *qosChannel) Qos(prefetchCount, _ int, _ bool) error {
+	q.prefetch = prefetchCount
+	return nil
+}
+
+func TestOrderedLanesSettleOwnDelivery(t *testing.T) {
+	const messages = 20
+
+	var (
+		ack  = &tagAcknowledger{settled: make(map[uint64]int)}
+		done sync.WaitGroup
+	)
+	done.Add(messages)
+
+	handler := types.BrokerHandler{
+		Exchange: "order",
+		Queue:    "order.created",
+		KeyFunc:  func(message []byte, _ map[string]string) string { return string(message) },
+		Lanes:    4,
+		LaneSize: 2,
+		HandlerFunc: func(ec *types.EventContext) error {
+			defer done.Done()
+			// later deliveries of other lanes finish first
+			if ec.Message()[0] == 'a' {
+				time.Sleep(20 * time.Millisecond)
+			}
+			return nil
+		},
+	}
+
+	ch := &qosChannel{}
+	w := newTestWorker(&fakePublisher{})
+	w.finalize = func(context.Context, *logger.DataLogger) {}
+	w.cancelFunc = func() {}
+	w.ch = ch
+	w.handlers = map[string]types.BrokerHandler{handler.Queue: handler}
+	w.semaphore = []chan int{newSlots(1)}
+	w.lanes = []*lanes.Dispatcher{w.newLanes(handler)}
+	w.shutdown = make(chan struct{}, 1)
+
+	consumer, err := subscribe(ch, handler, w.prefetch(handler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ch.prefetch != 12 {
+		t.Errorf("expected prefetch of lane capacity 12, got %d", ch.prefetch)
+	}
+	w.channels = []reflect.SelectCase{consumer}
+	go w.Serve()
+
+	deliveries := ch.consumer(t)
+	for i := 1; i <= messages; i++ {
+		deliveries <- amqp.Delivery{
+			Acknowledger: ack,
+			DeliveryTag:  uint64(i),
+			RoutingKey:   handler.Queue,
+			Body:         []byte(fmt.Sprintf("%c%d", 'a'+rune(i%3), i)),
+		}
+	}
+	done.Wait()
+	w.Shutdown(context.Background())
+
+	ack.mu.Lock()
+	defer ack.mu.Unlock()
+	if ack.multiple {
+		t.Error("expected every delivery settled on its own")
+	}
+	for i := uint64(1); i <= messages; i++ {
+		if ack.settled[i] != 1 {
+			t.Errorf("expected delivery %d settled once, got %d", i, ack.settled[i])
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package rabbitmq
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	rabbitbroker "github.com/TixiaOTA/gokit/broker/rabbitmq"
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/TixiaOTA/gokit/utils/lanes"
+	"github.com/streadway/amqp"
+)
+
+// killableChannel channel of killableConnection, deliveries are pushed by test
+type killableChannel struct {
+	fakeInspector
+	mu        sync.Mutex
+	consumers []chan amqp.Delivery
+}
+
+func (k *killableChannel) Publish(_, _ string, _, _ bool, _ amqp.Publishing) error { return nil }
+
+func (k *killableChannel) QueueDeclare(name string, _, _, _, _ bool, _ amqp.Table) (amqp.Queue, error) {
+	return amqp.Queue{Name: name}, nil
+}
+
+func (k *killableChannel) QueueBind(_, _, _ string, _ bool, _ amqp.Table) error { return nil }
+
+func (k *killableChannel) Consume(_, _ string, _, _, _, _ bool, _ amqp.Table) (<-chan amqp.Delivery, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	c := make(chan amqp.Delivery, 1)
+	k.consumers = append(k.consumers, c)
+	return c, nil
+}
+
+func (k *killableChannel) Qos(_, _ int, _ bool) error { return nil }
+
+func (k *killableChannel) Cancel(_ string, _ bool) error { return nil }
+
+func (k *killableChannel) NotifyClose(c chan *amqp.Error) chan *amqp.Error { return c }
+
+func (k *killableChannel) NotifyCancel(c chan string) chan string { return c }
+
+func (k *killableChannel) Close() error { return nil }
+
+// consumer wait for a registered consumer
+func (k *killableChannel) consumer(t *testing.T) chan amqp.Delivery {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		k.mu.Lock()
+		if len(k.consumers) > 0 {
+			c := k.consumers[0]
+			k.mu.Unlock()
+			return c
+		}
+		k.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatal("expected consumer registered")
+	return nil
+}
+
+type killableConnection struct {
+	mu     sync.Mutex
+	ch     *killableChannel
+	notify []chan *amqp.Error
+	killed bool
+}
+
+func (k *killableConnection) Channel() (rabbitbroker.Channel, error) { return k.ch, nil }
+
+func (k *killableConnection) NotifyClose(c chan *amqp.Error) chan *amqp.Error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.killed {
+		close(c)
+		return c
+	}
+	k.notify = append(k.notify, c)
+	return c
+}
+
+func (k *killableConnection) Close() error { return nil }
+
+// kill close the connection by error, consumer channels are closed like the amqp client does
+func (k *killableConnection) kill() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.killed = true
+	k.ch.mu.Lock()
+	for _, c := range k.ch.consumers {
+		close(c)
+	}
+	k.ch.mu.Unlock()
+	for _, c := range k.notify {
+		c <- &amqp.Error{Code: amqp.ConnectionForced}
+		close(c)
+	}
+}
+
+func TestWorkerResumeAfterConnectionLoss(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		conns []*killableConnection
+	)
+	dial := func(string) (rabbitbroker.Connection, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		conn := &killableConnection{ch: &killableChannel{}}
+		conns = append(conns, conn)
+		return conn, nil
+	}
+	connAt := func(i int) *killableConnection {
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			mu.Lock()
+			if len(conns) > i {
+				defer mu.Unlock()
+				return conns[i]
+			}
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatalf("expected connection %d dialed", i)
+		return nil
+	}
+
+	rb, err := rabbitbroker.New(rabbitbroker.SetDialer(dial), rabbitbroker.SetReconnectBackoff(time.Millisecond, 5*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rb.Disconnect(context.Background())
+
+	received := make(chan string, 4)
+	handler := types.BrokerHandler{Exchange: "order", Queue: "order.created", HandlerFunc: func(ec *types.EventContext) error {
+		received <- string(ec.Message())
+		return nil
+	}}
+
+	w := newTestWorker(&fakePublisher{})
+	w.recovery, w.ch, w.generation = rb, rb.Channel(), rb.Session().Generation
+	w.handlers = map[string]types.BrokerHandler{handler.Queue: handler}
+	w.queues = []string{handler.Queue}
+	w.semaphore = []chan int{newSlots(1)}
+	w.lanes = []*lanes.Dispatcher{nil}
+	w.shutdown = make(chan struct{}, 1)
+
+	consumer, err := subscribe(w.ch, handler, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.channels = []reflect.SelectCase{consumer}
+	go w.Serve()
+
+	deliver := func(conn *killableConnection, body string) {
+		conn.ch.consumer(t) <- amqp.Delivery{Acknowledger: &fakeAcknowledger{}, RoutingKey: handler.Queue, Body: []byte(body)}
+		select {
+		case got := <-received:
+			if got != body {
+				t.Fatalf("expected %s consumed, got %s", body, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected %s consumed", body)
+		}
+	}
+
+	deliver(connAt(0), "before")
+	connAt(0).kill()
+	deliver(connAt(1), "after")
+}
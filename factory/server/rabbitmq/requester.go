@@ -0,0 +1,205 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TixiaOTA/gokit/abstract"
+	"github.com/TixiaOTA/gokit/factory"
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+)
+
+// requestChannel channel operations of requester, implemented by *amqp.Channel
+type requestChannel interface {
+	channelPublisher
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	Cancel(consumer string, noWait bool) error
+}
+
+// Requester request-reply over rabbitmq using an exclusive reply queue per requester,
+// replies are matched to requests by correlation id
+type Requester struct {
+	ch       requestChannel
+	queue    string
+	consumer string
+
+	mu      sync.Mutex
+	pending map[string]chan amqp.Delivery
+	orphans atomic.Int64
+
+	closeOnce sync.Once
+	done      chan struct{}
+
+	// size guard and compression of requests and replies
+	payloadLimits types.PayloadLimits
+}
+
+// RequesterOptionFunc option of Requester
+type RequesterOptionFunc func(*Requester)
+
+// SetRequestPayloadLimits size guard and compression of requests, MaxMessageBytes also guards replies
+func SetRequestPayloadLimits(limits types.PayloadLimits) RequesterOptionFunc {
+	return func(r *Requester) {
+		r.payloadLimits = limits
+	}
+}
+
+var _ abstract.Requester = (*Requester)(nil)
+
+// NewRequester declare exclusive reply queue on channel and start receiving replies
+func NewRequester(ch requestChannel, opts ...RequesterOptionFunc) (*Requester, error) {
+	q, err := ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("rabbitmq_requester: declare reply queue: %w", err)
+	}
+
+	r := &Requester{
+		ch:       ch,
+		queue:    q.Name,
+		consumer: "requester-" + uuid.NewString(),
+		pending:  make(map[string]chan amqp.Delivery),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	replies, err := ch.Consume(r.queue, r.consumer, true, true, false, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("rabbitmq_requester: consume reply queue: %w", err)
+	}
+
+	factory.GoSafe("rabbitmq-requester", func() { r.dispatch(replies) })
+	return r, nil
+}
+
+// Request publish req with reply-to address and wait for its reply until ctx is done,
+// the request expires in queue at the deadline of ctx
+func (r *Requester) Request(ctx context.Context, req types.PublisherArgument) ([]byte, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	req, err := req.Encode(r.payloadLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	id := req.CorrelationId
+	if id == "" {
+		id = uuid.NewString()
+	}
+
+	wait := make(chan amqp.Delivery, 1)
+	r.mu.Lock()
+	r.pending[id] = wait
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		delete(r.pending, id)
+		r.mu.Unlock()
+	}()
+
+	key := req.Key
+	if key == "" {
+		key = req.Queue
+	}
+
+	msg := amqp.Publishing{
+		Headers:       amqp.Table(logger.InjectBaggage(ctx, req.Headers)),
+		CorrelationId: id,
+		ReplyTo:       r.queue,
+		Priority:      uint8(req.PriorityMessage),
+		Timestamp:     time.Now(),
+		Body:          req.Message,
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		msg.Expiration = strconv.FormatInt(max(time.Until(deadline).Milliseconds(), 1), 10)
+	}
+
+	if err = r.ch.Publish(req.Exchange, key, false, false, msg); err != nil {
+		return nil, fmt.Errorf("rabbitmq_requester: publish request: %w", err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: correlation id %s: %w", types.ErrReplyTimeout, id, ctx.Err())
+	case <-r.done:
+		return nil, types.ErrRequesterClosed
+	case d := <-wait:
+		if msg, ok := d.Headers[types.HeaderReplyError]; ok {
+			return nil, &types.ReplyError{CorrelationId: id, Message: fmt.Sprint(msg)}
+		}
+
+		return types.DecodePayload(d.Body, deliveryHeader(d), r.payloadLimits)
+	}
+}
+
+// Orphans number of replies received without a waiting request
+func (r *Requester) Orphans() int64 {
+	return r.orphans.Load()
+}
+
+// Disconnect stop receiving replies, waiting requests return types.ErrRequesterClosed
+func (r *Requester) Disconnect(_ context.Context) error {
+	var err error
+	r.closeOnce.Do(func() {
+		err = r.ch.Cancel(r.consumer, false)
+		close(r.done)
+	})
+
+	return err
+}
+
+func (r *Requester) dispatch(replies <-chan amqp.Delivery) {
+	for d := range replies {
+		r.mu.Lock()
+		wait, ok := r.pending[d.CorrelationId]
+		r.mu.Unlock()
+
+		if !ok {
+			r.orphans.Add(1)
+			log.Printf("rabbitmq_requester > %s: correlation id %s", types.ErrOrphanReply, d.CorrelationId)
+			continue
+		}
+
+		select {
+		case wait <- d:
+		default:
+			// duplicate reply of the same request
+			r.orphans.Add(1)
+		}
+	}
+}
+
+// publishReply publish reply of handler into the reply-to address of message, handler error is carried by header
+func (r *rabbitMqWorker) publishReply(message amqp.Delivery, reply []byte, err error) {
+	if message.ReplyTo == "" || r.replyCh == nil {
+		return
+	}
+
+	msg := amqp.Publishing{
+		Headers:       amqp.Table{},
+		CorrelationId: message.CorrelationId,
+		Timestamp:     time.Now(),
+		Body:          reply,
+	}
+	if err != nil {
+		msg.Headers[types.HeaderReplyError] = err.Error()
+		msg.Body = nil
+	}
+
+	if pe := r.replyCh.Publish("", message.ReplyTo, false, false, msg); pe != nil {
+		log.Printf("rabbitmq_consumer > publish reply %s: %s", message.CorrelationId, pe)
+	}
+}
@@ -0,0 +1,105 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/streadway/amqp"
+)
+
+// fakeBroker in-memory channel routing requests into worker and replies back into the reply queue
+type fakeBroker struct {
+	worker  *rabbitMqWorker
+	replies chan amqp.Delivery
+}
+
+func (f *fakeBroker) QueueDeclare(_ string, _, _, _, _ bool, _ amqp.Table) (amqp.Queue, error) {
+	return amqp.Queue{Name: "amq.gen-reply"}, nil
+}
+
+func (f *fakeBroker) Consume(_, _ string, _, _, _, _ bool, _ amqp.Table) (<-chan amqp.Delivery, error) {
+	return f.replies, nil
+}
+
+func (f *fakeBroker) Cancel(_ string, _ bool) error {
+	close(f.replies)
+	return nil
+}
+
+func (f *fakeBroker) Publish(_, key string, _, _ bool, msg amqp.Publishing) error {
+	if key == "amq.gen-reply" {
+		f.replies <- amqp.Delivery{CorrelationId: msg.CorrelationId, Headers: msg.Headers, Body: msg.Body}
+		return nil
+	}
+
+	go f.worker.processMessage(amqp.Delivery{
+		Acknowledger:  &fakeAcknowledger{},
+		RoutingKey:    key,
+		CorrelationId: msg.CorrelationId,
+		ReplyTo:       msg.ReplyTo,
+		Headers:       msg.Headers,
+		Body:          msg.Body,
+	}, "worker-1")
+	return nil
+}
+
+func TestRequestReply(t *testing.T) {
+	broker := &fakeBroker{replies: make(chan amqp.Delivery, 8)}
+	w := newTestWorker(&fakePublisher{})
+	w.opt.isAutoAck = false
+	w.replyCh = broker
+	w.handlers = map[string]types.BrokerHandler{
+		"price.quote": {HandlerFunc: func(ec *types.EventContext) error {
+			ec.Reply([]byte(strings.ToUpper(string(ec.Message()))))
+			return nil
+		}},
+		"price.invalid": {HandlerFunc: func(ec *types.EventContext) error {
+			return errors.Join(types.ErrNonRetryable, errors.New("unknown product"))
+		}},
+		"price.slow": {HandlerFunc: func(ec *types.EventContext) error {
+			time.Sleep(50 * time.Millisecond)
+			ec.Reply([]byte("late"))
+			return nil
+		}},
+	}
+	broker.worker = w
+
+	requester, err := NewRequester(broker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer requester.Disconnect(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	reply, err := requester.Request(ctx, types.PublisherArgument{Key: "price.quote", Message: []byte("sku-1")})
+	if err != nil || string(reply) != "SKU-1" {
+		t.Fatalf("expected reply SKU-1, got %q %v", reply, err)
+	}
+
+	_, err = requester.Request(ctx, types.PublisherArgument{Key: "price.invalid"})
+	var re *types.ReplyError
+	if !errors.As(err, &re) || !strings.Contains(re.Message, "unknown product") {
+		t.Fatalf("expected handler error replied, got %v", err)
+	}
+
+	short, cancelShort := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelShort()
+	if _, err = requester.Request(short, types.PublisherArgument{Key: "price.slow"}); !errors.Is(err, types.ErrReplyTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected reply timeout, got %v", err)
+	}
+
+	// the late reply of the timed out request is an orphan
+	deadline := time.Now().Add(time.Second)
+	for requester.Orphans() < 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if requester.Orphans() != 1 {
+		t.Errorf("expected one orphan reply, got %d", requester.Orphans())
+	}
+}
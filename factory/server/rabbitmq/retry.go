@@ -0,0 +1,81 @@
+package rabbitmq
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/spf13/cast"
+	"github.com/streadway/amqp"
+)
+
+// headerRetryAttempt header carrying number of retry attempts of message
+const headerRetryAttempt = "x-retry-attempt"
+
+// channelPublisher publish raw message into channel, implemented by *amqp.Channel
+type channelPublisher interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+}
+
+// retryQueueName name of retry queue of tier, e.g. retry.order.created.0
+func retryQueueName(queue string, tier int) string {
+	return fmt.Sprintf("retry.%s.%d", queue, tier)
+}
+
+// setupRetryQueues declare retry queue for each tier with ttl of the tier,
+// expired messages are dead-lettered back into the original queue
+func setupRetryQueues(ch topologyChannel, exchangeName, queueName string, tiers []time.Duration) error {
+	for i, delay := range tiers {
+		_, err := ch.QueueDeclare(retryQueueName(queueName, i), true, false, false, false, amqp.Table{
+			"x-message-ttl":             delay.Milliseconds(),
+			"x-dead-letter-exchange":    exchangeName,
+			"x-dead-letter-routing-key": queueName,
+		})
+		if err != nil {
+			return fmt.Errorf("error in declaring the retry queue %s", err)
+		}
+	}
+
+	return nil
+}
+
+// retryAttempt number of retry attempts of message
+func retryAttempt(message amqp.Delivery) int {
+	return cast.ToInt(message.Headers[headerRetryAttempt])
+}
+
+// scheduleRetry republish failed message into retry queue of the tier chosen by attempt count,
+// returns false when handler has no tiers, error is non-retryable or all tiers are exhausted
+func (r *rabbitMqWorker) scheduleRetry(message amqp.Delivery, handler types.BrokerHandler, err error) (bool, error) {
+	if len(handler.RetryTiers) < 1 || errors.Is(err, types.ErrNonRetryable) || r.retryCh == nil {
+		return false, nil
+	}
+
+	attempt := retryAttempt(message)
+	if attempt >= len(handler.RetryTiers) {
+		return false, nil
+	}
+
+	headers := amqp.Table{}
+	for k, v := range message.Headers {
+		headers[k] = v
+	}
+	headers[headerRetryAttempt] = int32(attempt + 1)
+
+	err = r.retryCh.Publish("", retryQueueName(handler.Queue, attempt), false, false, amqp.Publishing{
+		Headers:       headers,
+		ContentType:   message.ContentType,
+		CorrelationId: message.CorrelationId,
+		MessageId:     message.MessageId,
+		Priority:      message.Priority,
+		DeliveryMode:  amqp.Persistent,
+		Timestamp:     time.Now(),
+		Body:          message.Body,
+	})
+	if err != nil {
+		return false, fmt.Errorf("rabbitmq_consumer: schedule retry: %w", err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,71 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/streadway/amqp"
+)
+
+type fakeChannel struct {
+	keys     []string
+	messages []amqp.Publishing
+}
+
+func (f *fakeChannel) Publish(_, key string, _, _ bool, msg amqp.Publishing) error {
+	f.keys = append(f.keys, key)
+	f.messages = append(f.messages, msg)
+	return nil
+}
+
+func TestRetryTiersSchedule(t *testing.T) {
+	ch := &fakeChannel{}
+	w := &rabbitMqWorker{
+		ctx:     context.Background(),
+		opt:     option{serviceName: "test", queue: "order.created"},
+		tz:      time.UTC,
+		retryCh: ch,
+		handlers: map[string]types.BrokerHandler{
+			"order.created": {
+				Queue:      "order.created",
+				RetryTiers: []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute},
+				HandlerFunc: func(ec *types.EventContext) error {
+					return errors.New("downstream unavailable")
+				},
+			},
+		},
+	}
+
+	// simulate round trips through retry queues dead-lettering back to the original queue
+	delivery := amqp.Delivery{RoutingKey: "order.created", Headers: amqp.Table{"tenant": "acme"}}
+	for round := 0; round < 4; round++ {
+		ack := &fakeAcknowledger{}
+		delivery.Acknowledger = ack
+		w.processMessage(delivery, "worker-1")
+
+		if round == 3 {
+			if len(ch.keys) != 3 || ack.acked || !ack.nacked {
+				t.Errorf("expected tiers exhausted and message nacked, got %v acked=%v", ch.keys, ack.acked)
+			}
+			break
+		}
+
+		if !ack.acked {
+			t.Fatalf("round %d: expected original acked once retry is scheduled", round)
+		}
+
+		msg := ch.messages[round]
+		if want := retryQueueName("order.created", round); ch.keys[round] != want {
+			t.Errorf("round %d: expected retry queue %s, got %s", round, want, ch.keys[round])
+		}
+
+		if msg.Headers[headerRetryAttempt] != int32(round+1) || msg.Headers["tenant"] != "acme" {
+			t.Errorf("round %d: expected attempt counter and headers preserved, got %v", round, msg.Headers)
+		}
+
+		delivery = amqp.Delivery{RoutingKey: "order.created", Headers: msg.Headers, Body: msg.Body}
+	}
+}
@@ -0,0 +1,43 @@
+package rest
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// accessLogSampler sample access log of fast successful requests, failed and slow requests are always logged
+type accessLogSampler struct {
+	rate          float64
+	slowThreshold time.Duration
+	random        func() float64
+}
+
+func newAccessLogSampler(rate float64, slowThreshold time.Duration) *accessLogSampler {
+	return &accessLogSampler{
+		rate:          rate,
+		slowThreshold: slowThreshold,
+		random:        rand.Float64,
+	}
+}
+
+// sample decide after the response whether request is logged and whether it is logged as a sample
+func (s *accessLogSampler) sample(statusCode int, latency time.Duration) (log, sampled bool) {
+	if s == nil || s.rate >= 1 {
+		return true, false
+	}
+
+	if statusCode >= http.StatusBadRequest {
+		return true, false
+	}
+
+	if s.slowThreshold > 0 && latency >= s.slowThreshold {
+		return true, false
+	}
+
+	if s.random() < s.rate {
+		return true, true
+	}
+
+	return false, false
+}
@@ -0,0 +1,65 @@
+package rest
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/logger"
+)
+
+func TestAccessLogSampling(t *testing.T) {
+	s := newAccessLogSampler(0.1, 500*time.Millisecond)
+	s.random = rand.New(rand.NewSource(1)).Float64
+
+	var fast, fastLogged int
+	statuses := []int{http.StatusOK, http.StatusCreated, http.StatusNotFound, http.StatusInternalServerError}
+	rnd := rand.New(rand.NewSource(2))
+	for i := 0; i < 10000; i++ {
+		sc := statuses[rnd.Intn(len(statuses))]
+		latency := time.Duration(rnd.Intn(1000)) * time.Millisecond
+
+		log, sampled := s.sample(sc, latency)
+		switch {
+		case sc >= http.StatusBadRequest, latency >= 500*time.Millisecond:
+			if !log || sampled {
+				t.Fatalf("expected %d in %s always logged unsampled, got log=%v sampled=%v", sc, latency, log, sampled)
+			}
+		default:
+			fast++
+			if log {
+				fastLogged++
+				if !sampled {
+					t.Fatalf("expected fast success logged as sample")
+				}
+			}
+		}
+	}
+
+	if ratio := float64(fastLogged) / float64(fast); ratio < 0.08 || ratio > 0.12 {
+		t.Errorf("expected about 10%% of fast successes logged, got %.3f", ratio)
+	}
+}
+
+func TestAccessLogSamplingDisabled(t *testing.T) {
+	var s *accessLogSampler
+	if log, sampled := s.sample(http.StatusOK, 0); !log || sampled {
+		t.Errorf("expected every request logged without sampling, got log=%v sampled=%v", log, sampled)
+	}
+
+	if log, sampled := newAccessLogSampler(1, 0).sample(http.StatusOK, 0); !log || sampled {
+		t.Errorf("expected full rate logged unsampled, got log=%v sampled=%v", log, sampled)
+	}
+}
+
+func TestAccessLogSampledField(t *testing.T) {
+	for sampled, want := range map[bool]bool{true: true, false: false} {
+		raw, _ := json.Marshal(logger.DataLogger{Sampled: sampled})
+		if got := strings.Contains(string(raw), `"sampled":true`); got != want {
+			t.Errorf("expected sampled field %v, got %s", want, raw)
+		}
+	}
+}
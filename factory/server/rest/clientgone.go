@@ -0,0 +1,85 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// StatusClientClosed status logged for requests whose client closed the connection before the response, as nginx 499
+	StatusClientClosed = 499
+
+	// clientClosedLabel status label of StatusClientClosed on request metrics
+	clientClosedLabel = "client_closed"
+
+	// defaultClientGoneInterval interval of checking the connection of a running request
+	defaultClientGoneInterval = 200 * time.Millisecond
+
+	// localClientGone local of fiber context set once the client is known to be gone
+	localClientGone = "rest.client_gone"
+)
+
+// ErrClientGone cause of the canceled user context of a request whose client closed the connection
+var ErrClientGone = errors.New("client closed the connection")
+
+// IsClientGone report whether the client of c closed the connection, e.g. to stop a slow handler early.
+// c.UserContext() is canceled with ErrClientGone as soon as it is detected, see SetClientGoneInterval
+func IsClientGone(c *fiber.Ctx) bool {
+	if gone, _ := c.Locals(localClientGone).(bool); gone {
+		return true
+	}
+
+	if errors.Is(context.Cause(c.UserContext()), ErrClientGone) || connClosed(c.Context().Conn()) {
+		c.Locals(localClientGone, true)
+		return true
+	}
+
+	return false
+}
+
+// watchClient context canceled with ErrClientGone when the client closes conn, checked every interval
+// while the request runs, fast requests only cost a timer. stop must be called when the request ends
+func watchClient(ctx context.Context, conn net.Conn, interval time.Duration) (_ context.Context, stop func()) {
+	if interval <= 0 || conn == nil {
+		return ctx, func() {}
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	var (
+		mu      sync.Mutex
+		stopped bool
+		timer   *time.Timer
+	)
+
+	check := func() {
+		if connClosed(conn) {
+			cancel(ErrClientGone)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if !stopped {
+			timer.Reset(interval)
+		}
+	}
+
+	mu.Lock()
+	timer = time.AfterFunc(interval, check)
+	mu.Unlock()
+
+	return ctx, func() {
+		mu.Lock()
+		stopped = true
+		timer.Stop()
+		mu.Unlock()
+
+		cancel(context.Canceled)
+	}
+}
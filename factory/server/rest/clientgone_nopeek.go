@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package rest
+
+import "net"
+
+// connClosed peeking the socket is not supported on this platform, the client is never known to be gone
+func connClosed(net.Conn) bool {
+	return false
+}
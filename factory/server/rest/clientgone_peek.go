@@ -0,0 +1,50 @@
+//go:build linux || darwin
+
+package rest
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// connClosed report whether the peer closed conn, peeking the socket without consuming nor blocking,
+// so pipelined requests stay buffered. connections without socket, e.g. of app.Test, are never closed
+func connClosed(conn net.Conn) bool {
+	for {
+		nc, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			break
+		}
+		conn = nc.NetConn()
+	}
+
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return false
+	}
+
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return false
+	}
+
+	var closed bool
+	_ = raw.Read(func(fd uintptr) bool {
+		var buf [1]byte
+		n, _, err := syscall.Recvfrom(int(fd), buf[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		switch {
+		case errors.Is(err, syscall.EAGAIN), errors.Is(err, syscall.EWOULDBLOCK), errors.Is(err, syscall.EINTR):
+			// nothing to read, still open
+		case err != nil:
+			closed = true
+		case n == 0:
+			closed = true
+		}
+
+		// never wait for the socket to become readable
+		return true
+	})
+
+	return closed
+}
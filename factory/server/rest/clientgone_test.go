@@ -0,0 +1,124 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/abstract"
+	"github.com/TixiaOTA/gokit/factory"
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type fakeService struct{}
+
+func (fakeService) Name() string                                           { return "test" }
+func (fakeService) GetApplications() map[string]factory.ApplicationFactory { return nil }
+func (fakeService) RESTHandler() abstract.RestHandler                      { return nil }
+func (fakeService) GRPCHandler() abstract.GRPCHandler                      { return nil }
+func (fakeService) BrokerHandler(types.Broker) abstract.BrokerHandler      { return nil }
+func (fakeService) GetBroker(types.Broker) abstract.Broker                 { return nil }
+
+func TestClientGone(t *testing.T) {
+	opt := defaultOption()
+	SetClientGoneInterval(10 * time.Millisecond)(&opt)
+	srv := &rest{service: fakeService{}, opt: opt}
+
+	// metrics are global, leave no label set behind for other tests
+	defer func() {
+		for _, class := range []string{clientClosedLabel, "5xx"} {
+			httpRequests.DeleteLabelValues(http.MethodGet, "/slow", class)
+			httpDuration.DeleteLabelValues(http.MethodGet, "/slow", class)
+			httpResponseSize.DeleteLabelValues(http.MethodGet, "/slow", class)
+		}
+	}()
+
+	type outcome struct {
+		status   int
+		gone     bool
+		canceled bool
+	}
+	outcomes := make(chan outcome, 1)
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(routeMetrics())
+	app.Use(func(c *fiber.Ctx) error {
+		err := c.Next()
+		outcomes <- outcome{status: c.Response().StatusCode(), gone: IsClientGone(c), canceled: c.Locals("canceled") == true}
+		return err
+	})
+	app.Use(srv.restTraceLogger)
+	app.Get("/slow", func(c *fiber.Ctx) error {
+		select {
+		case <-c.UserContext().Done():
+			c.Locals("canceled", errors.Is(context.Cause(c.UserContext()), ErrClientGone))
+			return context.Cause(c.UserContext())
+		case <-time.After(2 * time.Second):
+			return c.SendString("too late")
+		}
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = app.Listener(ln) }()
+	defer func() { _ = app.Shutdown() }()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = conn.Write([]byte("GET /slow HTTP/1.1\r\nHost: test\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// client disconnect during the slow handler
+	time.Sleep(50 * time.Millisecond)
+	_ = conn.Close()
+
+	select {
+	case o := <-outcomes:
+		if !o.canceled {
+			t.Errorf("expected user context canceled with ErrClientGone")
+		}
+		if !o.gone || o.status != StatusClientClosed {
+			t.Errorf("expected request classified as client closed, got gone %v status %d", o.gone, o.status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected handler to stop once the client is gone")
+	}
+
+	// the metrics middleware record after the outcome middleware returns
+	closed := httpRequests.WithLabelValues(http.MethodGet, "/slow", clientClosedLabel)
+	deadline := time.Now().Add(time.Second)
+	for testutil.ToFloat64(closed) != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := testutil.ToFloat64(closed); n != 1 {
+		t.Errorf("expected request counted as client_closed, got %v", n)
+	}
+	if n := testutil.ToFloat64(httpRequests.WithLabelValues(http.MethodGet, "/slow", "5xx")); n != 0 {
+		t.Errorf("expected no 5xx for a client closed request, got %v", n)
+	}
+}
+
+func TestClientNotGone(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		if IsClientGone(c) {
+			t.Error("expected connected client")
+		}
+		return c.SendStatus(http.StatusNoContent)
+	})
+
+	if _, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil)); err != nil {
+		t.Fatal(err)
+	}
+}
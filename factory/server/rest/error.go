@@ -0,0 +1,84 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/TixiaOTA/gokit/utils/errorkit"
+	"github.com/gofiber/fiber/v2"
+)
+
+// headerRequestId response header carrying the request id
+const headerRequestId = "X-Request-Id"
+
+// errorBody error detail on the envelope
+type errorBody struct {
+	Code    string                 `json:"code,omitempty"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// errorEnvelope standard error response
+type errorEnvelope struct {
+	Error     errorBody `json:"error"`
+	RequestId string    `json:"request_id,omitempty"`
+}
+
+// renderedError error already rendered by the error handler, returned by restTraceLogger so outer middlewares
+// still see the error while the app error handler does not render it again
+type renderedError struct {
+	error
+}
+
+func (e *renderedError) Unwrap() error {
+	return e.error
+}
+
+// isRendered report whether err is already rendered into the response
+func isRendered(err error) bool {
+	var re *renderedError
+	return errors.As(err, &re)
+}
+
+// appErrorHandler error handler of the app, skip errors already rendered by restTraceLogger
+func appErrorHandler(handler fiber.ErrorHandler) fiber.ErrorHandler {
+	return func(c *fiber.Ctx, err error) error {
+		if isRendered(err) {
+			return nil
+		}
+
+		return handler(c, err)
+	}
+}
+
+// ErrorHandler standard fiber error handler rendering error into error envelope,
+// supports types.CodedError, errorkit.ErrorResponse and fiber.Error, enable it with SetErrorHandler
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	sc, body := errorResponse(err)
+	body.Message = translateMessage(c, body.Message)
+	body.Details = translateDetails(c, body.Details)
+
+	return c.Status(sc).JSON(errorEnvelope{
+		Error:     body,
+		RequestId: string(c.Response().Header.Peek(headerRequestId)),
+	})
+}
+
+func errorResponse(err error) (int, errorBody) {
+	if ce, ok := types.AsCodedError(err); ok {
+		return ce.HTTPStatus(), errorBody{Code: ce.Code, Message: ce.Message, Details: ce.Details}
+	}
+
+	var er *errorkit.ErrorResponse
+	if errors.As(err, &er) {
+		return er.StatusCode(), errorBody{Message: er.ErrorMessage()}
+	}
+
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return fe.Code, errorBody{Message: fe.Message}
+	}
+
+	return http.StatusInternalServerError, errorBody{Message: errorkit.InternalServer}
+}
@@ -0,0 +1,56 @@
+package rest
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestErrorHandlerOptIn(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []OptionFunc
+		want string
+	}{
+		{"default", nil, "order not found"},
+		{"envelope", []OptionFunc{SetErrorHandler(ErrorHandler)}, `{"error":{"message":"order not found"}`},
+	} {
+		opt := defaultOption()
+		for _, o := range tc.opts {
+			o(&opt)
+		}
+		srv := &rest{service: fakeService{}, opt: opt}
+
+		var seen error
+		app := fiber.New(fiber.Config{ErrorHandler: appErrorHandler(opt.errorHandler)})
+		app.Use(func(c *fiber.Ctx) error {
+			seen = c.Next()
+			return seen
+		})
+		app.Use(srv.restTraceLogger)
+		app.Get("/orders/1", func(c *fiber.Ctx) error {
+			return fiber.NewError(http.StatusNotFound, "order not found")
+		})
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/orders/1", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+
+		if resp.StatusCode != http.StatusNotFound || !strings.HasPrefix(string(body), tc.want) {
+			t.Errorf("%s: expected 404 %s, got %d %s", tc.name, tc.want, resp.StatusCode, body)
+		}
+
+		// the error reaches outer middlewares, rendered once
+		var fe *fiber.Error
+		if !errors.As(seen, &fe) || fe.Code != http.StatusNotFound {
+			t.Errorf("%s: expected outer middleware see the handler error, got %v", tc.name, seen)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package rest
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// newHTTP2Server serve fiber app through net/http server to speak HTTP/2, fasthttp only speaks HTTP/1.1.
+//
+// Limitation: every request is converted from net/http into fasthttp by adaptor.FiberApp, request and
+// response bodies are buffered (no streaming, no SSE) and fiber Prefork is not applied.
+// Use the net/http factory (factory/server/http) when full HTTP/2 semantics are needed.
+func (r *rest) newHTTP2Server() *http.Server {
+	var handler http.Handler = adaptor.FiberApp(r.serverEngine)
+	if r.opt.h2c {
+		// prior knowledge and HTTP/1.1 upgrade
+		handler = h2c.NewHandler(handler, &http2.Server{})
+	}
+
+	srv := &http.Server{
+		Addr:    r.opt.httpHost + ":" + r.opt.httpPort,
+		Handler: handler,
+	}
+
+	if r.opt.certFile != "" {
+		// HTTP/2 is negotiated via ALPN, HTTP/1.1 remains available
+		srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12, NextProtos: []string{http2.NextProtoTLS, "http/1.1"}}
+	}
+
+	return srv
+}
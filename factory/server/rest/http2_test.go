@@ -0,0 +1,83 @@
+package rest
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/net/http2"
+)
+
+func TestH2CPriorKnowledge(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	_ = l.Close()
+
+	app := fiber.New()
+	app.Post("/echo", func(c *fiber.Ctx) error {
+		return c.Send(c.Body())
+	})
+
+	opt := defaultOption()
+	WithH2C()(&opt)
+	SetHTTPHost("127.0.0.1")(&opt)
+	SetHTTPPort(port)(&opt)
+
+	srv := &rest{serverEngine: app, opt: opt}
+	srv.http2 = srv.newHTTP2Server()
+	go srv.Serve()
+	defer srv.Shutdown(context.Background())
+
+	// prior knowledge, speak HTTP/2 without TLS nor upgrade
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}}
+
+	url := "http://127.0.0.1:" + strconv.Itoa(port) + "/echo"
+	var resp *http.Response
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		req, _ := http.NewRequest(http.MethodPost, url, http.NoBody)
+		if resp, err = client.Do(req); err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader("ping"))
+	if resp, err = client.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.ProtoMajor != 2 || string(body) != "ping" {
+		t.Errorf("expected HTTP/2 echo, got %s %q", resp.Proto, body)
+	}
+
+	// HTTP/1.1 clients keep working
+	if resp, err = http.Post(url, "text/plain", strings.NewReader("pong")); err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 1 {
+		t.Errorf("expected HTTP/1.1 fallback, got %s", resp.Proto)
+	}
+}
@@ -0,0 +1,225 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/gofiber/fiber/v2"
+)
+
+// fiber locals keys of resolved language and catalog, see WithI18n
+const (
+	i18nLanguageKey = "gokit.i18n.language"
+	i18nCatalogKey  = "gokit.i18n.catalog"
+)
+
+// Catalog message catalogs per language, e.g. "en" and "id", see WithI18n
+type Catalog struct {
+	defaultLanguage string
+	messages        map[string]map[string]string
+
+	// warned keys missing on a language, warned once per language and key
+	warned sync.Map
+}
+
+// LoadTranslations catalog of messages by language and key, defaultLanguage is used when the requested
+// language is not supported or misses a key
+func LoadTranslations(messages map[string]map[string]string, defaultLanguage string) *Catalog {
+	cat := &Catalog{defaultLanguage: normalizeLanguage(defaultLanguage), messages: make(map[string]map[string]string)}
+	for lang, m := range messages {
+		cat.messages[normalizeLanguage(lang)] = m
+	}
+
+	return cat
+}
+
+// LoadTranslationsFS catalog of "<language>.json" files of fsys, e.g. an embed.FS of "en.json" and "id.json"
+// holding flat objects of key and message
+func LoadTranslationsFS(fsys fs.FS, defaultLanguage string) (*Catalog, error) {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make(map[string]map[string]string, len(files))
+	for _, file := range files {
+		raw, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		m := make(map[string]string)
+		if err = json.Unmarshal(raw, &m); err != nil {
+			return nil, fmt.Errorf("i18n: %s: %w", file, err)
+		}
+		messages[strings.TrimSuffix(path.Base(file), ".json")] = m
+	}
+
+	return LoadTranslations(messages, defaultLanguage), nil
+}
+
+// has report whether key exists on any language
+func (cat *Catalog) has(key string) bool {
+	for _, m := range cat.messages {
+		if _, ok := m[key]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// translate message of key on lang, falls back to the default language then the key itself
+func (cat *Catalog) translate(lang, key string, args ...interface{}) string {
+	msg, ok := cat.messages[lang][key]
+	if !ok {
+		cat.warnMissing(lang, key)
+		if msg, ok = cat.messages[cat.defaultLanguage][key]; !ok {
+			msg = key
+		}
+	}
+
+	return formatMessage(msg, args)
+}
+
+// formatMessage format msg with args, msg is returned as is without args
+func formatMessage(msg string, args []interface{}) string {
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+
+	return msg
+}
+
+func (cat *Catalog) warnMissing(lang, key string) {
+	if _, warned := cat.warned.LoadOrStore(lang+"\x00"+key, struct{}{}); !warned {
+		logger.Logrus().Warnf("i18n > missing key %q on language %q, falling back to %q", key, lang, cat.defaultLanguage)
+	}
+}
+
+// negotiate best supported language of Accept-Language header by q-value, a region tag (e.g. "en-US")
+// falls back to its base language, the default language when nothing matches
+func (cat *Catalog) negotiate(acceptLanguage string) string {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{tag: normalizeLanguage(tag), q: q})
+		}
+	}
+
+	// stable keeps header order of equal q-values
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		if _, ok := cat.messages[c.tag]; ok {
+			return c.tag
+		}
+		if base, _, ok := strings.Cut(c.tag, "-"); ok {
+			if _, ok = cat.messages[base]; ok {
+				return base
+			}
+		}
+	}
+
+	return cat.defaultLanguage
+}
+
+// handler middleware resolving language of request from Accept-Language
+func (cat *Catalog) handler(c *fiber.Ctx) error {
+	lang := cat.negotiate(c.Get(fiber.HeaderAcceptLanguage))
+	c.Locals(i18nLanguageKey, lang)
+	c.Locals(i18nCatalogKey, cat)
+	c.Set(fiber.HeaderContentLanguage, lang)
+
+	return c.Next()
+}
+
+// Language resolved language of request, empty when WithI18n is not set
+func Language(c *fiber.Ctx) string {
+	lang, _ := c.Locals(i18nLanguageKey).(string)
+	return lang
+}
+
+// T message of key on the language of request formatted with args, the key itself is returned when
+// WithI18n is not set or no language has the key
+func T(c *fiber.Ctx, key string, args ...interface{}) string {
+	cat, ok := c.Locals(i18nCatalogKey).(*Catalog)
+	if !ok {
+		return formatMessage(key, args)
+	}
+
+	return cat.translate(Language(c), key, args...)
+}
+
+// translateMessage translate message of error envelope when it is a catalog key
+func translateMessage(c *fiber.Ctx, message string) string {
+	if cat, ok := c.Locals(i18nCatalogKey).(*Catalog); ok && cat.has(message) {
+		return cat.translate(Language(c), message)
+	}
+
+	return message
+}
+
+// translateDetails translate validation messages of error envelope details that are catalog keys,
+// e.g. {"email": "validation.email"}, a field may hold one message or a list of them
+func translateDetails(c *fiber.Ctx, details map[string]interface{}) map[string]interface{} {
+	cat, ok := c.Locals(i18nCatalogKey).(*Catalog)
+	if !ok || len(details) < 1 {
+		return details
+	}
+
+	lang := Language(c)
+	translate := func(msg string) string {
+		if cat.has(msg) {
+			return cat.translate(lang, msg)
+		}
+		return msg
+	}
+
+	out := make(map[string]interface{}, len(details))
+	for field, v := range details {
+		switch msg := v.(type) {
+		case string:
+			out[field] = translate(msg)
+		case []string:
+			msgs := make([]string, len(msg))
+			for i := range msg {
+				msgs[i] = translate(msg[i])
+			}
+			out[field] = msgs
+		default:
+			out[field] = v
+		}
+	}
+
+	return out
+}
+
+// normalizeLanguage lower case language tag with "-" separator, e.g. "en_US" is "en-us"
+func normalizeLanguage(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+}
@@ -0,0 +1,141 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/TixiaOTA/gokit/utils/errorkit"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestI18nNegotiate(t *testing.T) {
+	cat := LoadTranslations(map[string]map[string]string{
+		"en":    {"greeting": "hello"},
+		"id":    {"greeting": "halo"},
+		"pt-BR": {"greeting": "olá"},
+	}, "en")
+
+	cases := map[string]string{
+		"":                          "en",
+		"id":                        "id",
+		"fr-CH, fr;q=0.9, id;q=0.8": "id",
+		"en;q=0.5, id;q=0.9":        "id",
+		"en-US,en;q=0.9":            "en",
+		"pt-br":                     "pt-br",
+		"id;q=0, en;q=0.1":          "en",
+		"de, *;q=0.5":               "en",
+		"id-ID;q=0.8, pt-BR;q=0.8":  "id",
+		"es;q=invalid, id;q=0.3":    "id",
+		"ja":                        "en",
+		"pt-PT;q=0.9, en-GB;q=0.95": "en",
+	}
+	for header, want := range cases {
+		if got := cat.negotiate(header); got != want {
+			t.Errorf("%q: expected %s, got %s", header, want, got)
+		}
+	}
+}
+
+func TestI18nTranslation(t *testing.T) {
+	cat, err := LoadTranslationsFS(fstest.MapFS{
+		"en.json": {Data: []byte(`{"order.not_found": "order %s not found", "error.forbidden": "forbidden"}`)},
+		"id.json": {Data: []byte(`{"order.not_found": "pesanan %s tidak ditemukan"}`)},
+	}, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(cat.handler)
+	app.Get("/orders/:id", func(c *fiber.Ctx) error {
+		return c.SendString(T(c, "order.not_found", c.Params("id")))
+	})
+	app.Get("/admin", func(c *fiber.Ctx) error {
+		return errorkit.Error(errors.New("denied"), "error.forbidden", http.StatusForbidden)
+	})
+	app.Get("/plain", func(c *fiber.Ctx) error {
+		return errorkit.Error(errors.New("denied"), errorkit.Forbidden, http.StatusForbidden)
+	})
+
+	get := func(path, lang string) (*http.Response, string) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(fiber.HeaderAcceptLanguage, lang)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	if resp, body := get("/orders/7", "id-ID,id;q=0.9"); body != "pesanan 7 tidak ditemukan" || resp.Header.Get(fiber.HeaderContentLanguage) != "id" {
+		t.Errorf("expected indonesian message, got %q (%s)", body, resp.Header.Get(fiber.HeaderContentLanguage))
+	}
+
+	// missing key on id falls back to the default language
+	var envelope errorEnvelope
+	_, body := get("/admin", "id")
+	if _ = json.Unmarshal([]byte(body), &envelope); envelope.Error.Message != "forbidden" {
+		t.Errorf("expected envelope message translated on default language, got %s", body)
+	}
+
+	// message which is not a catalog key is kept
+	_, body = get("/plain", "id")
+	if _ = json.Unmarshal([]byte(body), &envelope); envelope.Error.Message != errorkit.Forbidden {
+		t.Errorf("expected plain message kept, got %s", body)
+	}
+}
+
+func TestI18nValidationMessages(t *testing.T) {
+	cat := LoadTranslations(map[string]map[string]string{
+		"en": {"validation.failed": "invalid data", "validation.required": "is required", "validation.email": "must be an email"},
+		"id": {"validation.failed": "data tidak valid", "validation.required": "wajib diisi", "validation.email": "harus berupa email"},
+	}, "en")
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(cat.handler)
+	app.Post("/users", func(c *fiber.Ctx) error {
+		return &types.CodedError{
+			Code:    "INVALID_USER",
+			Message: "validation.failed",
+			Details: map[string]interface{}{
+				"name":  "validation.required",
+				"email": []string{"validation.required", "validation.email"},
+				"age":   "must be 18 or older",
+				"max":   120,
+			},
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/users", nil)
+	req.Header.Set(fiber.HeaderAcceptLanguage, "id")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var envelope errorEnvelope
+	if err = json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		t.Fatal(err)
+	}
+
+	if envelope.Error.Message != "data tidak valid" {
+		t.Errorf("expected message translated, got %q", envelope.Error.Message)
+	}
+	want := map[string]interface{}{
+		"name":  "wajib diisi",
+		"email": []interface{}{"wajib diisi", "harus berupa email"},
+		"age":   "must be 18 or older",
+		"max":   float64(120),
+	}
+	if !reflect.DeepEqual(envelope.Error.Details, want) {
+		t.Errorf("expected validation messages translated, got %v", envelope.Error.Details)
+	}
+}
@@ -0,0 +1,154 @@
+package rest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/TixiaOTA/gokit/utils/errorkit"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	headerIdempotencyKey      = "Idempotency-Key"
+	headerIdempotencyReplayed = "Idempotency-Replayed"
+
+	// idempotencyPollInterval interval of waiting concurrent duplicate to complete
+	idempotencyPollInterval = 50 * time.Millisecond
+	// defaultIdempotencyLockTTL time a request holds its key while the handler runs, see SetIdempotencyLockTTL
+	defaultIdempotencyLockTTL = time.Minute
+)
+
+// idempotencyReplayHeaders response headers stored and replayed
+var idempotencyReplayHeaders = []string{fiber.HeaderContentType, fiber.HeaderLocation, headerRequestId}
+
+// idempotencyRecord stored state of idempotency key
+type idempotencyRecord struct {
+	BodyHash string            `json:"body_hash"`
+	Done     bool              `json:"done"`
+	Status   int               `json:"status,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Body     []byte            `json:"body,omitempty"`
+}
+
+// idempotency replay stored response of POST/PATCH request with the same Idempotency-Key
+type idempotency struct {
+	store   CacheStore
+	ttl     time.Duration
+	lockTTL time.Duration
+	wait    time.Duration
+}
+
+func newIdempotency(store CacheStore, ttl time.Duration) *idempotency {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+
+	return &idempotency{store: store, ttl: ttl, lockTTL: defaultIdempotencyLockTTL}
+}
+
+func (i *idempotency) handler(c *fiber.Ctx) error {
+	key := c.Get(headerIdempotencyKey)
+	if key == "" || (c.Method() != http.MethodPost && c.Method() != http.MethodPatch) {
+		return c.Next()
+	}
+
+	var (
+		ctx      = c.UserContext()
+		storeKey = fmt.Sprintf("idempotency:%s:%s:%s", c.Method(), c.Path(), key)
+		sum      = sha256.Sum256(c.Body())
+		bodyHash = hex.EncodeToString(sum[:])
+		deadline = time.Now().Add(i.wait)
+	)
+
+	for {
+		raw, ok, err := i.store.Get(ctx, storeKey)
+		if err != nil {
+			// fail open, the store is unavailable
+			logger.Log.Errorf(ctx, "idempotency store: %s", err)
+			return c.Next()
+		}
+
+		if !ok {
+			pending, _ := json.Marshal(idempotencyRecord{BodyHash: bodyHash})
+			acquired, err := i.store.SetNX(ctx, storeKey, pending, i.lockTTL)
+			if err != nil {
+				logger.Log.Errorf(ctx, "idempotency store: %s", err)
+				return c.Next()
+			}
+
+			if acquired {
+				return i.execute(c, storeKey, bodyHash)
+			}
+
+			// lost the race, read the record of the winner
+			continue
+		}
+
+		var rec idempotencyRecord
+		if err = json.Unmarshal(raw, &rec); err != nil {
+			logger.Log.Errorf(ctx, "idempotency store: %s", err)
+			return fiber.NewError(http.StatusInternalServerError, errorkit.InternalServer)
+		}
+
+		if rec.BodyHash != bodyHash {
+			return fiber.NewError(http.StatusUnprocessableEntity, errorkit.UnprocessableEntity)
+		}
+
+		if rec.Done {
+			return replay(c, rec)
+		}
+
+		if i.wait <= 0 || time.Now().After(deadline) {
+			return fiber.NewError(http.StatusConflict, errorkit.Conflict)
+		}
+
+		time.Sleep(idempotencyPollInterval)
+	}
+}
+
+// execute run handler and store its response, failed request release the key so it can be retried
+func (i *idempotency) execute(c *fiber.Ctx, storeKey, bodyHash string) error {
+	ctx := c.UserContext()
+
+	err := c.Next()
+	status := c.Response().StatusCode()
+	if err != nil || status >= http.StatusInternalServerError {
+		_ = i.store.Delete(ctx, storeKey)
+		return err
+	}
+
+	rec := idempotencyRecord{
+		BodyHash: bodyHash,
+		Done:     true,
+		Status:   status,
+		Headers:  make(map[string]string),
+		Body:     append([]byte(nil), c.Response().Body()...),
+	}
+
+	for _, h := range idempotencyReplayHeaders {
+		if v := c.Response().Header.Peek(h); len(v) > 0 {
+			rec.Headers[h] = string(v)
+		}
+	}
+
+	raw, _ := json.Marshal(rec)
+	if err = i.store.Set(ctx, storeKey, raw, i.ttl); err != nil {
+		logger.Log.Errorf(ctx, "idempotency store: %s", err)
+	}
+
+	return nil
+}
+
+func replay(c *fiber.Ctx, rec idempotencyRecord) error {
+	for k, v := range rec.Headers {
+		c.Set(k, v)
+	}
+	c.Set(headerIdempotencyReplayed, "true")
+
+	return c.Status(rec.Status).Send(rec.Body)
+}
@@ -0,0 +1,159 @@
+package rest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestIdempotency(t *testing.T) {
+	store := NewMemoryStore()
+	idem := newIdempotency(store, time.Hour)
+
+	// default error handler of the server
+	calls := 0
+	app := fiber.New()
+	app.Use(idem.handler)
+	app.Post("/payments", func(c *fiber.Ctx) error {
+		calls++
+		return Created(c, map[string]int{"payment": calls})
+	})
+
+	do := func(key, body string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(body))
+		req.Header.Set(headerIdempotencyKey, key)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	first := do("key-1", `{"amount":10}`)
+	firstBody, _ := io.ReadAll(first.Body)
+	if first.StatusCode != http.StatusCreated || calls != 1 {
+		t.Fatalf("expected first request executed, got %d", first.StatusCode)
+	}
+
+	// replay
+	replayed := do("key-1", `{"amount":10}`)
+	replayedBody, _ := io.ReadAll(replayed.Body)
+	if replayed.StatusCode != http.StatusCreated || replayed.Header.Get(headerIdempotencyReplayed) != "true" ||
+		string(replayedBody) != string(firstBody) || calls != 1 {
+		t.Errorf("expected stored response replayed, got %d %s", replayed.StatusCode, replayedBody)
+	}
+
+	// key reused with different body
+	if resp := do("key-1", `{"amount":99}`); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422, got %d", resp.StatusCode)
+	}
+}
+
+func TestIdempotencyConcurrentDuplicate(t *testing.T) {
+	store := NewMemoryStore()
+	idem := newIdempotency(store, time.Hour)
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(idem.handler)
+	app.Post("/payments", func(c *fiber.Ctx) error { return OK(c, "done") })
+
+	// simulate first request still in progress with the same body
+	req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(`{}`))
+	req.Header.Set(headerIdempotencyKey, "key-3")
+
+	holder := newIdempotency(store, time.Hour)
+	holderApp := fiber.New()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	holderApp.Use(holder.handler)
+	holderApp.Post("/payments", func(c *fiber.Ctx) error {
+		close(started)
+		<-release
+		return OK(c, "done")
+	})
+
+	go func() {
+		r := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(`{}`))
+		r.Header.Set(headerIdempotencyKey, "key-3")
+		_, _ = holderApp.Test(r, -1)
+	}()
+	<-started
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected 409 for concurrent duplicate, got %d", resp.StatusCode)
+	}
+
+	// waiting duplicate gets the replay once the first completes
+	idem.wait = 2 * time.Second
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+	}()
+
+	req = httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(`{}`))
+	req.Header.Set(headerIdempotencyKey, "key-3")
+	resp, err = app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get(headerIdempotencyReplayed) != "true" {
+		t.Errorf("expected waiting duplicate replayed, got %d", resp.StatusCode)
+	}
+}
+
+func TestIdempotencyLockTTL(t *testing.T) {
+	run := func(lockTTL time.Duration) (calls int, status int) {
+		opt := defaultOption()
+		WithIdempotency(NewMemoryStore(), time.Hour)(&opt)
+		SetIdempotencyLockTTL(lockTTL)(&opt)
+
+		var mu sync.Mutex
+		app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		app.Use(opt.idempotency.handler)
+		app.Post("/payments", func(c *fiber.Ctx) error {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+			time.Sleep(200 * time.Millisecond)
+			return OK(c, "done")
+		})
+
+		do := func() *http.Response {
+			req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(`{}`))
+			req.Header.Set(headerIdempotencyKey, "key-4")
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Error(err)
+			}
+			return resp
+		}
+
+		done := make(chan struct{})
+		go func() { do(); close(done) }()
+		time.Sleep(100 * time.Millisecond)
+		status = do().StatusCode
+		<-done
+
+		mu.Lock()
+		defer mu.Unlock()
+		return calls, status
+	}
+
+	// the lock expires while the slow handler runs, the duplicate runs it again
+	if calls, _ := run(20 * time.Millisecond); calls != 2 {
+		t.Errorf("expected duplicate executed past a short lock, got %d calls", calls)
+	}
+	if calls, status := run(time.Minute); calls != 1 || status != http.StatusConflict {
+		t.Errorf("expected duplicate rejected while the lock holds, got %d calls and %d", calls, status)
+	}
+}
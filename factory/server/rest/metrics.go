@@ -0,0 +1,111 @@
+package rest
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// routeNotFound route label of requests matching no route
+const routeNotFound = "not_found"
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_server_requests_total",
+		Help: "How many requests processed, partitioned by method, route pattern and status class or client_closed.",
+	}, []string{"method", "route", "status"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_server_request_duration_seconds",
+		Help:    "How long it took to process the request, partitioned by method, route pattern and status class.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+	httpResponseSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_server_response_size_bytes",
+		Help:    "Size of response body, partitioned by method, route pattern and status class.",
+		Buckets: prometheus.ExponentialBuckets(100, 10, 6),
+	}, []string{"method", "route", "status"})
+	httpInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "http_server_requests_in_flight",
+		Help: "Number of requests being processed, partitioned by method.",
+	}, []string{"method"})
+	routeMetricsRegisterOnce sync.Once
+)
+
+// routeMetrics middleware recording request metrics labeled by route pattern, e.g. /orders/:id,
+// so concrete ids never become label values
+func routeMetrics() fiber.Handler {
+	routeMetricsRegisterOnce.Do(func() {
+		for _, c := range []prometheus.Collector{httpRequests, httpDuration, httpResponseSize, httpInFlight} {
+			_ = prometheus.Register(c)
+		}
+	})
+
+	// non middleware routes, resolved on the first request once every route is registered
+	var (
+		routesOnce sync.Once
+		routes     map[string]bool
+	)
+
+	return func(c *fiber.Ctx) error {
+		routesOnce.Do(func() {
+			routes = make(map[string]bool)
+			for _, r := range c.App().GetRoutes(true) {
+				routes[r.Method+" "+r.Path] = true
+			}
+		})
+
+		start := time.Now()
+		method := c.Method()
+
+		inFlight := httpInFlight.WithLabelValues(method)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		err := c.Next()
+
+		route := c.Route().Path
+		if !matchedRoute(routes, c) {
+			route = routeNotFound
+		}
+
+		status := c.Response().StatusCode()
+		if err != nil && !isRendered(err) {
+			// error not rendered yet by the error handler
+			status = fiber.StatusInternalServerError
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				status = fe.Code
+			}
+		}
+
+		class := strconv.Itoa(status/100) + "xx"
+		if IsClientGone(c) {
+			class = clientClosedLabel
+		}
+		httpRequests.WithLabelValues(method, route, class).Inc()
+		httpDuration.WithLabelValues(method, route, class).Observe(time.Since(start).Seconds())
+		httpResponseSize.WithLabelValues(method, route, class).Observe(float64(len(c.Response().Body())))
+
+		return err
+	}
+}
+
+// matchedRoute report whether the last route of c is a handler route instead of a middleware, which stays
+// the route of c when nothing matched. a middleware may share its prefix with a static route, e.g. "/",
+// a static route only matches its own path
+func matchedRoute(routes map[string]bool, c *fiber.Ctx) bool {
+	r := c.Route()
+	if !routes[r.Method+" "+r.Path] {
+		return false
+	}
+	if len(r.Params) > 0 {
+		return true
+	}
+
+	return strings.EqualFold(strings.TrimSuffix(c.Path(), "/"), strings.TrimSuffix(r.Path, "/"))
+}
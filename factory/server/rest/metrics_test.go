@@ -0,0 +1,38 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRouteMetrics(t *testing.T) {
+	app := fiber.New()
+	root := app.Group("")
+	root.Use(routeMetrics())
+	root.Use(func(c *fiber.Ctx) error { return c.Next() })
+	root.Get("/", func(c *fiber.Ctx) error { return c.SendString("home") })
+	root.Get("/orders/:id", func(c *fiber.Ctx) error { return c.SendString("order " + c.Params("id")) })
+
+	for _, path := range []string{"/orders/12345", "/orders/67890", "/", "/nope"} {
+		if _, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := testutil.ToFloat64(httpRequests.WithLabelValues(http.MethodGet, "/orders/:id", "2xx")); n != 2 {
+		t.Errorf("expected both ids counted on the route pattern, got %v", n)
+	}
+	if n := testutil.ToFloat64(httpRequests.WithLabelValues(http.MethodGet, routeNotFound, "4xx")); n != 1 {
+		t.Errorf("expected unmatched route labeled not_found, got %v", n)
+	}
+	if n := testutil.CollectAndCount(httpRequests); n != 3 {
+		t.Errorf("expected 3 label sets, got %d", n)
+	}
+	if n := testutil.ToFloat64(httpInFlight.WithLabelValues(http.MethodGet)); n != 0 {
+		t.Errorf("expected no request in flight, got %v", n)
+	}
+}
@@ -7,10 +7,10 @@ import (
 	"net/http"
 	"reflect"
 	"strings"
-	"time"
 
 	"github.com/TixiaOTA/gokit/logger"
 	"github.com/TixiaOTA/gokit/tracer"
+	"github.com/TixiaOTA/gokit/utils/errorkit"
 	"github.com/TixiaOTA/gokit/utils/timezone"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -18,13 +18,17 @@ import (
 
 func (r *rest) restTraceLogger(c *fiber.Ctx) error {
 	ctx := c.UserContext()
-	start := time.Now().In(timezone.JakartaTz())
+	start := logger.Now().In(timezone.JakartaTz())
 
 	var err error
 	var sc = http.StatusOK
 	var resp string
+	var clientGone bool
 
 	requestId := c.Get("x-request-id")
+	if reflect.ValueOf(requestId).IsZero() {
+		requestId = requestIdFromTrace(ctx)
+	}
 	if reflect.ValueOf(requestId).IsZero() {
 		requestId = uuid.NewString()
 	}
@@ -36,7 +40,7 @@ func (r *rest) restTraceLogger(c *fiber.Ctx) error {
 	// init logger
 	dl := logger.DataLogger{
 		RequestId:     requestId,
-		Ip:            c.IP(),
+		Ip:            RealIP(c),
 		Device:        c.Get("user-agent"),
 		Type:          logger.ServiceType("rest_api"),
 		TimeStart:     start,
@@ -54,9 +58,15 @@ func (r *rest) restTraceLogger(c *fiber.Ctx) error {
 	defer func() {
 		if re := recover(); re != nil {
 			err = fmt.Errorf("%s", re)
+			// panic without the recovery middleware, see DisableRecovery, the panic value stays in the logs
+			if herr := r.opt.errorHandler(c, fiber.NewError(http.StatusInternalServerError, errorkit.InternalServer)); herr != nil {
+				_ = c.SendStatus(http.StatusInternalServerError)
+			}
+			sc, resp = c.Response().StatusCode(), string(c.Response().Body())
 		}
 
-		if err != nil {
+		// canceled by the client, not a failure of the service
+		if err != nil && !clientGone {
 			trace.SetError(err)
 		}
 
@@ -66,6 +76,12 @@ func (r *rest) restTraceLogger(c *fiber.Ctx) error {
 
 		// set response
 		logger.Response(ctx, sc, resp, err)
+		// sample after the response so status and latency are known
+		if log, sampled := r.opt.accessLogSampler.sample(sc, logger.Now().Sub(start)); !log {
+			dl.Discard()
+		} else {
+			dl.Sampled = sampled
+		}
 		// get all data logging from context with mutext
 		dl.Finalize(ctx)
 		// finish the tracing
@@ -76,9 +92,18 @@ func (r *rest) restTraceLogger(c *fiber.Ctx) error {
 	lock := new(logger.Locker)
 	ctx = context.WithValue(ctx, logger.LogKey, lock)
 	lock.Set(logger.RequestId, dl.RequestId)
+	// baggage of public clients would set tenant and user of downstream calls
+	if r.opt.incomingBaggage && trustedPeer(c) {
+		logger.RestoreBaggage(ctx, requestBaggage(c))
+	}
+
+	// canceled with ErrClientGone when the client closes the connection
+	ctx, stopWatch := watchClient(ctx, c.Context().Conn(), r.opt.clientGoneInterval)
+	defer stopWatch()
 
 	// set current context into fiber-context
 	c.SetUserContext(ctx)
+	c.Set(headerRequestId, dl.RequestId)
 
 	// set log request
 	trace.SetTag("tracer_id", tracer.GetTraceID(ctx))
@@ -92,6 +117,17 @@ func (r *rest) restTraceLogger(c *fiber.Ctx) error {
 
 	// next handler
 	err = c.Next()
+	if err != nil {
+		// render error response before logging, so the status code is recorded
+		if herr := r.opt.errorHandler(c, err); herr != nil {
+			_ = c.SendStatus(http.StatusInternalServerError)
+		}
+	}
+	// the response never reaches a gone client, logged as warn with 499 instead of the rendered error
+	if clientGone = IsClientGone(c); clientGone {
+		c.Status(StatusClientClosed)
+		trace.SetTag("http.client_closed", true)
+	}
 	trace.SetTag("user_code", dl.UserCode)
 	trace.SetTag("device", dl.Device)
 
@@ -108,7 +144,12 @@ func (r *rest) restTraceLogger(c *fiber.Ctx) error {
 		resp = string(respBody)
 	}
 
-	return err
+	// outer middlewares still see the error, the app error handler does not render it again
+	if err != nil {
+		return &renderedError{err}
+	}
+
+	return nil
 }
 
 func dumpHeaderFromRequest(c *fiber.Ctx) []byte {
@@ -143,6 +184,11 @@ func parseUrl(c *fiber.Ctx) string {
 func dumpBodyFromRequest(c *fiber.Ctx) string {
 	var reqBody string
 
+	// streamed body is read by the handler, reading it here would buffer the whole body
+	if c.Request().IsBodyStream() {
+		return "[streamed body]"
+	}
+
 	// NOTES:
 	// - before version v1.1.0, only support formValue with key 'content' (application/x-www-formurlencoded)
 	// - after version v1.1.0, support both. form-value with key 'content' or raw json
@@ -154,3 +200,18 @@ func dumpBodyFromRequest(c *fiber.Ctx) string {
 
 	return reqBody
 }
+
+// requestBaggage baggage prefixed headers of request, see logger.RestoreBaggage
+func requestBaggage(c *fiber.Ctx) map[string]string {
+	var baggage map[string]string
+	c.Request().Header.VisitAll(func(key, value []byte) {
+		if len(key) > len(logger.BaggagePrefix) && strings.EqualFold(string(key[:len(logger.BaggagePrefix)]), logger.BaggagePrefix) {
+			if baggage == nil {
+				baggage = make(map[string]string)
+			}
+			baggage[string(key)] = string(value)
+		}
+	})
+
+	return baggage
+}
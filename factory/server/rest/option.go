@@ -2,10 +2,14 @@ package rest
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/TixiaOTA/gokit/logger"
 	"github.com/gofiber/fiber/v2"
+	"github.com/hellofresh/health-go/v4"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // OptionFunc setter rest options
@@ -19,19 +23,77 @@ type option struct {
 	engineOption func(app *fiber.App)
 	log          *logrus.Logger
 
-	// it's recomended to set error handling, default is fiber.DefaultErrorHandler
+	rateLimit   *rateLimiter
+	otel        *otelMiddleware
+	idempotency *idempotency
+
+	websocket *websocketHub
+
+	accessLogSampler *accessLogSampler
+
+	// panic recovery, enabled by default
+	recovery *recovery
+
+	// message catalogs negotiated from Accept-Language
+	i18n *Catalog
+
+	// checks reported on /live/status
+	healthChecks []health.Config
+
+	// path of the service metadata route, empty disables it, see SetVersionPath
+	versionPath string
+
+	// expose config.UsageReport on /debug/config-usage
+	configUsage bool
+
+	// expose logger.RecentEntries on /debug/logs
+	debugLogs bool
+
+	// logger whose loki client is described on /debug/loki
+	debugLoki *logger.Logger
+
+	// request metrics labeled by route pattern, see routeMetrics
+	routeMetrics bool
+
+	// port serving /metrics and /debug endpoints instead of httpPort, empty serves them on httpPort
+	debugPort string
+
+	// proxies allowed to set X-Forwarded-* headers, see RealIP
+	trustedProxies []string
+
+	// restore baggage-* headers on the request context, see WithIncomingBaggage
+	incomingBaggage bool
+
+	// HTTP/2, see newHTTP2Server
+	h2c      bool
+	certFile string
+	keyFile  string
+
+	// request bodies bigger than bodyLimit are streamed to handlers, see WithUploadStreaming
+	streamRequestBody bool
+	bodyLimit         int
+
+	// interval of checking whether the client of a running request is gone, see IsClientGone
+	clientGoneInterval time.Duration
+
+	// it's recomended to set error handling, default is fiber.DefaultErrorHandler,
+	// set ErrorHandler to render the standard error envelope
 	errorHandler fiber.ErrorHandler
 }
 
 // defaultOption default options for rest
 func defaultOption() option {
 	return option{
-		httpPort: "8080",
-		log:      logger.Logrus(),
+		httpPort:    "8080",
+		versionPath: "/version",
+		log:         logger.Logrus(),
 		cors: func(c *fiber.Ctx) error {
 			return c.Next()
 		},
 		errorHandler: fiber.DefaultErrorHandler,
+		recovery:     newRecovery(logger.Default(), defaultRecoveryBodyBytes),
+
+		clientGoneInterval: defaultClientGoneInterval,
 	}
 }
 
@@ -42,6 +104,14 @@ func SetHTTPPort(httpPort int) OptionFunc {
 	}
 }
 
+// SetVersionPath serve service metadata on path instead of /version, empty path disables the route.
+// a GET route of the service on the same path takes precedence over the built-in one
+func SetVersionPath(path string) OptionFunc {
+	return func(o *option) {
+		o.versionPath = path
+	}
+}
+
 // SetCors set cors options
 func SetCors(cors fiber.Handler) OptionFunc {
 	return func(o *option) {
@@ -62,8 +132,211 @@ func SetHTTPHost(httpHost string) OptionFunc {
 	}
 }
 
+// SetErrorHandler set error handler rendering errors of handlers, e.g. ErrorHandler for the standard error envelope
 func SetErrorHandler(errorHandler fiber.ErrorHandler) OptionFunc {
 	return func(o *option) {
 		o.errorHandler = errorHandler
 	}
 }
+
+// WithRateLimit limit request per client key over sliding window, keyFn default to client IP and
+// store default to memory store, use redis store to share the limit across replicas.
+// it panics when limit or window is not positive
+func WithRateLimit(keyFn func(*fiber.Ctx) string, limit int, window time.Duration, store CacheStore) OptionFunc {
+	if limit <= 0 || window <= 0 {
+		panic(fmt.Errorf("rest server: rate limit of %d per %s, limit and window must be positive", limit, window))
+	}
+
+	return func(o *option) {
+		o.rateLimit = newRateLimiter(keyFn, limit, window, store)
+	}
+}
+
+// WithRateLimitAllowlist skip rate limit for CIDRs, IPs or client keys, must be set after WithRateLimit
+func WithRateLimitAllowlist(entries ...string) OptionFunc {
+	return func(o *option) {
+		if o.rateLimit == nil {
+			return
+		}
+
+		for _, e := range entries {
+			o.rateLimit.allow(e)
+		}
+	}
+}
+
+// WithOpenTelemetry install OpenTelemetry server span middleware with the tracer provider and propagators,
+// propagators default to the global propagator when nil
+func WithOpenTelemetry(tp trace.TracerProvider, propagators propagation.TextMapPropagator) OptionFunc {
+	return func(o *option) {
+		o.otel = newOtelMiddleware(tp, propagators)
+	}
+}
+
+// WithIdempotency replay stored response of POST/PATCH request having the same Idempotency-Key header for ttl,
+// store default to memory store, use redis store to share the keys across replicas
+func WithIdempotency(store CacheStore, ttl time.Duration) OptionFunc {
+	return func(o *option) {
+		o.idempotency = newIdempotency(store, ttl)
+	}
+}
+
+// SetIdempotencyWait concurrent duplicate waits up to timeout for the first request instead of getting 409,
+// must be set after WithIdempotency
+func SetIdempotencyWait(timeout time.Duration) OptionFunc {
+	return func(o *option) {
+		if o.idempotency != nil {
+			o.idempotency.wait = timeout
+		}
+	}
+}
+
+// SetIdempotencyLockTTL time a request holds its Idempotency-Key while the handler runs, default 1 minute.
+// set it above the longest handler, a duplicate arriving after the lock expired runs the handler again.
+// must be set after WithIdempotency
+func SetIdempotencyLockTTL(ttl time.Duration) OptionFunc {
+	return func(o *option) {
+		if o.idempotency != nil && ttl > 0 {
+			o.idempotency.lockTTL = ttl
+		}
+	}
+}
+
+// WithH2C serve HTTP/2 cleartext (prior knowledge or upgrade) alongside HTTP/1.1,
+// the fiber app is served through net/http, see newHTTP2Server for the limitation
+func WithH2C() OptionFunc {
+	return func(o *option) {
+		o.h2c = true
+	}
+}
+
+// WithTLS serve TLS with HTTP/2 negotiated via ALPN,
+// the fiber app is served through net/http, see newHTTP2Server for the limitation
+func WithTLS(certFile, keyFile string) OptionFunc {
+	return func(o *option) {
+		o.certFile = certFile
+		o.keyFile = keyFile
+	}
+}
+
+// WithUploadStreaming stream request bodies bigger than bodyLimit bytes to handlers instead of buffering them,
+// ParseUpload then reads the file from the connection and rejects an oversize file mid-stream. smaller bodies
+// are buffered as usual, zero bodyLimit keeps the default of 4MB. multipart forms are no longer parsed before
+// the handler, c.FormFile still parses them on demand
+func WithUploadStreaming(bodyLimit int) OptionFunc {
+	return func(o *option) {
+		o.streamRequestBody = true
+		o.bodyLimit = bodyLimit
+	}
+}
+
+// WithWebsocket enable websocket handlers, see Websocket. Reads and writes of connection time out after
+// readTimeout and writeTimeout, messages bigger than maxMessageSize close the connection, zero means unlimited
+func WithWebsocket(readTimeout, writeTimeout time.Duration, maxMessageSize int64) OptionFunc {
+	return func(o *option) {
+		o.websocket = newWebsocketHub(readTimeout, writeTimeout, maxMessageSize)
+	}
+}
+
+// SetClientGoneInterval interval of checking whether the client of a running request closed the connection,
+// the user context is then canceled with ErrClientGone. zero disables the check while the request runs,
+// requests are still logged as StatusClientClosed when the client is gone on completion
+func SetClientGoneInterval(interval time.Duration) OptionFunc {
+	return func(o *option) {
+		o.clientGoneInterval = interval
+	}
+}
+
+// WithTrustedProxies trust X-Forwarded-For, X-Forwarded-Proto and X-Real-IP headers set by proxies of cidrs,
+// headers of requests arriving directly from untrusted sources are ignored, see RealIP and IsSecure
+func WithTrustedProxies(cidrs []string) OptionFunc {
+	return func(o *option) {
+		o.trustedProxies = cidrs
+	}
+}
+
+// WithIncomingBaggage restore baggage-* headers of requests on the context, propagated to downstream calls.
+// only requests of trusted proxies are restored when WithTrustedProxies is set, every request otherwise,
+// so a public service should enable it only behind a gateway stripping or setting baggage headers
+func WithIncomingBaggage() OptionFunc {
+	return func(o *option) {
+		o.incomingBaggage = true
+	}
+}
+
+// WithAccessLogSampling log only rate (between 0 and 1) of successful requests faster than slowThreshold,
+// requests with status >= 400 or taking slowThreshold or longer are always logged, sampled logs have sampled=true
+func WithAccessLogSampling(rate float64, slowThreshold time.Duration) OptionFunc {
+	return func(o *option) {
+		o.accessLogSampler = newAccessLogSampler(rate, slowThreshold)
+	}
+}
+
+// WithRecovery log recovered handler panic with request snapshot on log, request body is capped to maxBodyBytes,
+// default to logger.Default and 4KB
+func WithRecovery(log *logger.Logger, maxBodyBytes int) OptionFunc {
+	return func(o *option) {
+		o.recovery = newRecovery(log, maxBodyBytes)
+	}
+}
+
+// DisableRecovery let handler panic reach the trace logger without request snapshot
+func DisableRecovery() OptionFunc {
+	return func(o *option) {
+		o.recovery = nil
+	}
+}
+
+// WithConfigUsage expose config.UsageReport on GET /debug/config-usage, keep it off public listeners
+func WithConfigUsage() OptionFunc {
+	return func(o *option) {
+		o.configUsage = true
+	}
+}
+
+// WithDebugLogs expose logger.RecentEntries on GET /debug/logs, "level" query keeps entries of
+// at least that level, e.g. /debug/logs?level=warn. keep it off public listeners
+func WithDebugLogs() OptionFunc {
+	return func(o *option) {
+		o.debugLogs = true
+	}
+}
+
+// WithDebugLoki expose the loki client of log on GET /debug/loki: push URL, tenant, labels, batching,
+// queue depth and last push status with secrets masked. keep it off public listeners
+func WithDebugLoki(log *logger.Logger) OptionFunc {
+	return func(o *option) {
+		o.debugLoki = log
+	}
+}
+
+// WithRouteMetrics record request count, duration, response size and in-flight requests labeled by
+// method, route pattern (e.g. /orders/:id) and status class, requests matching no route are labeled "not_found"
+func WithRouteMetrics() OptionFunc {
+	return func(o *option) {
+		o.routeMetrics = true
+	}
+}
+
+// SetDebugPort serve /metrics and /debug endpoints on port instead of the http port,
+// keeping them off the public listener. Serve panics when the port cannot be bound
+func SetDebugPort(port int) OptionFunc {
+	return func(o *option) {
+		o.debugPort = fmt.Sprintf("%d", port)
+	}
+}
+
+// WithI18n resolve language of request from Accept-Language, see T and Language.
+// error envelope message and validation messages of its details being keys of catalog are translated
+func WithI18n(catalog *Catalog) OptionFunc {
+	return func(o *option) {
+		o.i18n = catalog
+	}
+}
+
+// WithHealthCheck report checks on /live/status, e.g. health.Config{Name: "rabbitmq", Check: broker.Check}
+func WithHealthCheck(checks ...health.Config) OptionFunc {
+	return func(o *option) {
+		o.healthChecks = append(o.healthChecks, checks...)
+	}
+}
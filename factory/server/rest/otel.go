@@ -0,0 +1,102 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName name of OpenTelemetry tracer of rest server
+const instrumentationName = "github.com/TixiaOTA/gokit/factory/server/rest"
+
+// otelMiddleware OpenTelemetry server span middleware
+type otelMiddleware struct {
+	tracer      trace.Tracer
+	propagators propagation.TextMapPropagator
+}
+
+func newOtelMiddleware(tp trace.TracerProvider, propagators propagation.TextMapPropagator) *otelMiddleware {
+	if propagators == nil {
+		propagators = otel.GetTextMapPropagator()
+	}
+
+	return &otelMiddleware{
+		tracer:      tp.Tracer(instrumentationName),
+		propagators: propagators,
+	}
+}
+
+// headerCarrier propagation.TextMapCarrier over fiber request header
+type headerCarrier struct {
+	c *fiber.Ctx
+}
+
+func (hc headerCarrier) Get(key string) string {
+	return hc.c.Get(key)
+}
+
+func (hc headerCarrier) Set(key, value string) {
+	hc.c.Request().Header.Set(key, value)
+}
+
+func (hc headerCarrier) Keys() []string {
+	keys := make([]string, 0)
+	hc.c.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+
+	return keys
+}
+
+func (o *otelMiddleware) handler(c *fiber.Ctx) error {
+	// extract W3C traceparent from incoming request
+	ctx := o.propagators.Extract(c.UserContext(), headerCarrier{c: c})
+	ctx, span := o.tracer.Start(ctx, fmt.Sprintf("%s %s", c.Method(), c.Path()),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.method", c.Method()),
+			attribute.String("http.target", c.OriginalURL()),
+		),
+	)
+	defer span.End()
+
+	c.SetUserContext(ctx)
+	err := c.Next()
+
+	// name span by route pattern, known after routing
+	route := c.Route().Path
+	span.SetName(fmt.Sprintf("%s %s", c.Method(), route))
+
+	sc := c.Response().StatusCode()
+	span.SetAttributes(
+		attribute.String("http.route", route),
+		attribute.Int("http.status_code", sc),
+		attribute.String("request_id", string(c.Response().Header.Peek(headerRequestId))),
+	)
+
+	if err != nil {
+		span.RecordError(err)
+	}
+
+	if sc >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(sc))
+	}
+
+	return err
+}
+
+// requestIdFromTrace reuse trace id as request id when the request carries trace context
+func requestIdFromTrace(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+
+	return ""
+}
@@ -0,0 +1,113 @@
+package rest
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TixiaOTA/gokit/abstract"
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// tracedHandler register GET /orders/:id and GET /fail
+type tracedHandler struct{}
+
+func (tracedHandler) Router(r fiber.Router) {
+	r.Get("/orders/:id", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+	r.Get("/fail", func(c *fiber.Ctx) error { return fiber.NewError(fiber.StatusInternalServerError, "boom") })
+}
+
+type tracedService struct{ fakeService }
+
+func (tracedService) RESTHandler() abstract.RestHandler { return tracedHandler{} }
+
+func spanAttr(s tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, a := range s.Attributes {
+		if a.Key == key {
+			return a.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestOpenTelemetry(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	srv := New(tracedService{}, SetHTTPPort(0), WithOpenTelemetry(tp, propagation.TraceContext{})).(*rest)
+
+	const traceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const parentId = "00f067aa0ba902b7"
+
+	t.Run("traceparent", func(t *testing.T) {
+		exp.Reset()
+		req := httptest.NewRequest(fiber.MethodGet, "/orders/42", nil)
+		req.Header.Set("traceparent", "00-"+traceId+"-"+parentId+"-01")
+		resp, err := srv.serverEngine.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		spans := exp.GetSpans()
+		if len(spans) != 1 {
+			t.Fatalf("expected one server span, got %d", len(spans))
+		}
+		s := spans[0]
+		if s.Name != "GET /orders/:id" {
+			t.Errorf("expected span named by route, got %q", s.Name)
+		}
+		if s.SpanContext.TraceID().String() != traceId || s.Parent.SpanID().String() != parentId || !s.Parent.IsRemote() {
+			t.Errorf("expected span child of the incoming traceparent, got parent %v", s.Parent)
+		}
+		if got := spanAttr(s, "http.status_code").AsInt64(); got != fiber.StatusOK {
+			t.Errorf("expected status code recorded, got %d", got)
+		}
+		// request id reuse the trace id without x-request-id
+		if got := resp.Header.Get(headerRequestId); got != traceId {
+			t.Errorf("expected request id %s, got %q", traceId, got)
+		}
+		if got := spanAttr(s, "request_id").AsString(); got != traceId {
+			t.Errorf("expected request id attached to span, got %q", got)
+		}
+	})
+
+	t.Run("request id", func(t *testing.T) {
+		exp.Reset()
+		req := httptest.NewRequest(fiber.MethodGet, "/orders/42", nil)
+		req.Header.Set("traceparent", "00-"+traceId+"-"+parentId+"-01")
+		req.Header.Set("x-request-id", "req-1")
+		resp, err := srv.serverEngine.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := resp.Header.Get(headerRequestId); got != "req-1" {
+			t.Errorf("expected incoming request id kept, got %q", got)
+		}
+		if spans := exp.GetSpans(); len(spans) != 1 || spanAttr(spans[0], "request_id").AsString() != "req-1" {
+			t.Errorf("expected request id attached to span, got %v", spans)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		exp.Reset()
+		if _, err := srv.serverEngine.Test(httptest.NewRequest(fiber.MethodGet, "/fail", nil)); err != nil {
+			t.Fatal(err)
+		}
+
+		spans := exp.GetSpans()
+		if len(spans) != 1 {
+			t.Fatalf("expected one server span, got %d", len(spans))
+		}
+		s := spans[0]
+		if s.Status.Code != otelcodes.Error || spanAttr(s, "http.status_code").AsInt64() != fiber.StatusInternalServerError {
+			t.Errorf("expected error status recorded, got %v %v", s.Status, s.Attributes)
+		}
+		if s.Parent.IsValid() {
+			t.Errorf("expected root span without traceparent, got parent %v", s.Parent)
+		}
+	})
+}
@@ -0,0 +1,127 @@
+package rest
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/TixiaOTA/gokit/utils/errorkit"
+	"github.com/gofiber/fiber/v2"
+)
+
+// rateLimiter sliding window rate limiter over CacheStore
+type rateLimiter struct {
+	keyFn     func(*fiber.Ctx) string
+	limit     int
+	window    time.Duration
+	store     CacheStore
+	allowNets []*net.IPNet
+	allowKeys map[string]struct{}
+	now       func() time.Time
+}
+
+func newRateLimiter(keyFn func(*fiber.Ctx) string, limit int, window time.Duration, store CacheStore) *rateLimiter {
+	if keyFn == nil {
+		keyFn = func(c *fiber.Ctx) string {
+			return RealIP(c)
+		}
+	}
+
+	if store == nil {
+		store = NewMemoryStore()
+	}
+
+	return &rateLimiter{
+		keyFn:     keyFn,
+		limit:     limit,
+		window:    window,
+		store:     store,
+		allowKeys: make(map[string]struct{}),
+		now:       time.Now,
+	}
+}
+
+// allow add allowlist entry, entry can be a CIDR, an IP or a client key
+func (rl *rateLimiter) allow(entry string) {
+	if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+		rl.allowNets = append(rl.allowNets, ipNet)
+		return
+	}
+
+	if ip := net.ParseIP(entry); ip != nil {
+		bits := 8 * len(ip.To16())
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		rl.allowNets = append(rl.allowNets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		return
+	}
+
+	rl.allowKeys[entry] = struct{}{}
+}
+
+func (rl *rateLimiter) allowed(c *fiber.Ctx, key string) bool {
+	if _, ok := rl.allowKeys[key]; ok {
+		return true
+	}
+
+	ip := net.ParseIP(RealIP(c))
+	for _, n := range rl.allowNets {
+		if ip != nil && n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (rl *rateLimiter) handler(c *fiber.Ctx) error {
+	key := rl.keyFn(c)
+	if rl.allowed(c, key) {
+		return c.Next()
+	}
+
+	var (
+		ctx         = c.UserContext()
+		now         = rl.now()
+		windowIndex = now.UnixNano() / rl.window.Nanoseconds()
+		windowStart = time.Unix(0, windowIndex*rl.window.Nanoseconds())
+	)
+
+	current, err := rl.store.Incr(ctx, fmt.Sprintf("ratelimit:%s:%d", key, windowIndex), 2*rl.window)
+	if err != nil {
+		// fail open, rate limit store must not take down the endpoint
+		logger.Log.Errorf(ctx, "rate limit store: %s", err)
+		return c.Next()
+	}
+
+	var previous int64
+	if val, ok, err := rl.store.Get(ctx, fmt.Sprintf("ratelimit:%s:%d", key, windowIndex-1)); err == nil && ok {
+		previous, _ = strconv.ParseInt(string(val), 10, 64)
+	}
+
+	// sliding window estimation, previous window weighted by its remaining overlap
+	weight := 1 - float64(now.Sub(windowStart))/float64(rl.window)
+	estimate := int(math.Ceil(float64(previous)*weight)) + int(current)
+
+	remaining := rl.limit - estimate
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	c.Set("X-RateLimit-Limit", strconv.Itoa(rl.limit))
+	c.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+
+	if estimate > rl.limit {
+		retryAfter := int(math.Ceil(windowStart.Add(rl.window).Sub(now).Seconds()))
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+
+		return fiber.NewError(http.StatusTooManyRequests, errorkit.TooManyRequests)
+	}
+
+	return c.Next()
+}
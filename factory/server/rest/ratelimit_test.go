@@ -0,0 +1,88 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRateLimitWindowEdges(t *testing.T) {
+	now := time.Unix(1000, 0)
+	rl := newRateLimiter(func(*fiber.Ctx) string { return "client" }, 2, time.Minute, NewMemoryStore())
+	rl.now = func() time.Time { return now }
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(rl.handler)
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendStatus(http.StatusOK) })
+
+	do := func() *http.Response {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	// window starts at 960s, two requests allowed
+	for i, remaining := range []string{"1", "0"} {
+		resp := do()
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("X-RateLimit-Remaining") != remaining {
+			t.Fatalf("request %d: expected 200 with remaining %s, got %d %s", i, remaining, resp.StatusCode, resp.Header.Get("X-RateLimit-Remaining"))
+		}
+	}
+
+	resp := do()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get(fiber.HeaderRetryAfter) != "20" {
+		t.Fatalf("expected 429 with retry after 20s, got %d %s", resp.StatusCode, resp.Header.Get(fiber.HeaderRetryAfter))
+	}
+
+	// previous window almost decayed at the end of next window
+	now = time.Unix(1079, 0)
+	if resp := do(); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 after previous window decayed, got %d", resp.StatusCode)
+	}
+
+	if resp := do(); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 while previous window still weighs in, got %d", resp.StatusCode)
+	}
+}
+
+func TestRateLimitDefaultErrorHandler(t *testing.T) {
+	rl := newRateLimiter(func(*fiber.Ctx) string { return "client" }, 1, time.Minute, NewMemoryStore())
+
+	app := fiber.New()
+	app.Use(rl.handler)
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendStatus(http.StatusOK) })
+
+	var status []int
+	for i := 0; i < 2; i++ {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		status = append(status, resp.StatusCode)
+	}
+
+	if status[0] != http.StatusOK || status[1] != http.StatusTooManyRequests {
+		t.Errorf("expected 200 then 429, got %v", status)
+	}
+}
+
+func TestRateLimitRejectsNonPositive(t *testing.T) {
+	for _, c := range []struct {
+		limit  int
+		window time.Duration
+	}{{limit: 10, window: 0}, {limit: 10, window: -time.Second}, {limit: 0, window: time.Second}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected rate limit of %d per %s rejected", c.limit, c.window)
+				}
+			}()
+			WithRateLimit(nil, c.limit, c.window, nil)
+		}()
+	}
+}
@@ -0,0 +1,169 @@
+package rest
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/TixiaOTA/gokit/utils/errorkit"
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultRecoveryBodyBytes default maximum request body bytes captured on panic
+	defaultRecoveryBodyBytes = 4096
+	// redacted value of sensitive header
+	redacted = "[REDACTED]"
+)
+
+var (
+	panicTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_server_panics_total",
+		Help: "How many handler panics recovered, partitioned by method and route.",
+	}, []string{"method", "path"})
+	panicRegisterOnce sync.Once
+
+	// sensitiveHeaders headers redacted from the panic snapshot
+	sensitiveHeaders = map[string]bool{
+		"authorization":       true,
+		"proxy-authorization": true,
+		"cookie":              true,
+		"set-cookie":          true,
+		"x-api-key":           true,
+	}
+)
+
+// recovery recover handler panic into the standard 500 envelope, logging a snapshot of the request
+type recovery struct {
+	log          *logger.Logger
+	maxBodyBytes int
+}
+
+func newRecovery(log *logger.Logger, maxBodyBytes int) *recovery {
+	panicRegisterOnce.Do(func() {
+		_ = prometheus.Register(panicTotal)
+	})
+
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = defaultRecoveryBodyBytes
+	}
+
+	return &recovery{log: log, maxBodyBytes: maxBodyBytes}
+}
+
+// panicSnapshot request of recovered panic
+type panicSnapshot struct {
+	RequestId string
+	Method    string
+	Path      string
+	Headers   map[string]string
+	Body      string
+}
+
+func (rc *recovery) handler(c *fiber.Ctx) (err error) {
+	defer func() {
+		re := recover()
+		if re == nil {
+			return
+		}
+
+		stack := debug.Stack()
+		snap := rc.snapshot(c)
+		panicTotal.WithLabelValues(snap.Method, c.Route().Path).Inc()
+
+		// the panic value often carries internal state, it is logged once and never written to the client
+		if rc.log == nil {
+			logger.Logrus().Errorf("panic recovered on %s %s: %v\n%s", snap.Method, snap.Path, re, stack)
+		} else {
+			rc.log.Error("panic recovered",
+				zap.String("request_id", snap.RequestId),
+				zap.String("method", snap.Method),
+				zap.String("path", snap.Path),
+				zap.Any("headers", snap.Headers),
+				zap.String("body", snap.Body),
+				zap.String("panic", fmt.Sprint(re)),
+				zap.ByteString("stack", stack),
+			)
+		}
+
+		err = fiber.NewError(http.StatusInternalServerError, errorkit.InternalServer)
+	}()
+
+	return c.Next()
+}
+
+// snapshot method, path, sanitized headers and capped body of request
+func (rc *recovery) snapshot(c *fiber.Ctx) panicSnapshot {
+	snap := panicSnapshot{
+		RequestId: logger.GetRequestId(c.UserContext()),
+		Method:    c.Method(),
+		Path:      c.OriginalURL(),
+		Headers:   make(map[string]string),
+		Body:      rc.body(c),
+	}
+
+	if snap.RequestId == "" {
+		snap.RequestId = string(c.Response().Header.Peek(headerRequestId))
+	}
+
+	for k, v := range c.GetReqHeaders() {
+		value := strings.Join(v, ", ")
+		if sensitiveHeaders[strings.ToLower(k)] {
+			value = redacted
+		}
+		snap.Headers[k] = value
+	}
+
+	return snap
+}
+
+// body capped request body, binary content is replaced by its size
+func (rc *recovery) body(c *fiber.Ctx) string {
+	if c.Request().IsBodyStream() {
+		return "[streamed body]"
+	}
+
+	body := c.Request().Body()
+	if len(body) < 1 {
+		return ""
+	}
+
+	if !textualContentType(c.Get(fiber.HeaderContentType)) {
+		return fmt.Sprintf("[binary %d bytes]", len(body))
+	}
+
+	if len(body) > rc.maxBodyBytes {
+		return fmt.Sprintf("%s...[truncated %d bytes]", body[:rc.maxBodyBytes], len(body)-rc.maxBodyBytes)
+	}
+
+	return string(body)
+}
+
+// textualContentType report whether body of content type is safe to log as text
+func textualContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// missing content type, assume text
+		return contentType == ""
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+
+	switch mediaType {
+	case fiber.MIMEApplicationJSON, fiber.MIMEApplicationXML, fiber.MIMEApplicationForm:
+		return true
+	}
+
+	return false
+}
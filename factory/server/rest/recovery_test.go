@@ -0,0 +1,121 @@
+package rest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRecoverySnapshot(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(newRecovery(&logger.Logger{Logger: zap.New(core)}, 16).handler)
+	app.Post("/orders/:id", func(c *fiber.Ctx) error {
+		panic("nil order")
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/orders/1", strings.NewReader(`{"order_id":"1","amount":100}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer secret-token")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	var envelope errorEnvelope
+	if resp.StatusCode != http.StatusInternalServerError || json.Unmarshal(body, &envelope) != nil || envelope.Error.Message == "" {
+		t.Fatalf("expected 500 error envelope, got %d %s", resp.StatusCode, body)
+	}
+
+	entries := logs.FilterMessage("panic recovered").AllUntimed()
+	if len(entries) != 1 || entries[0].Level != zapcore.ErrorLevel {
+		t.Fatalf("expected one error log of panic, got %v", logs.AllUntimed())
+	}
+
+	fields := entries[0].ContextMap()
+	if fields["method"] != http.MethodPost || fields["path"] != "/orders/1" || fields["panic"] != "nil order" {
+		t.Errorf("unexpected snapshot %v", fields)
+	}
+
+	if fields["body"] != `{"order_id":"1",...[truncated 13 bytes]` {
+		t.Errorf("expected body capped to 16 bytes, got %v", fields["body"])
+	}
+
+	headers, _ := fields["headers"].(map[string]string)
+	if headers[fiber.HeaderAuthorization] != redacted {
+		t.Errorf("expected authorization redacted, got %v", headers)
+	}
+
+	if stack, _ := fields["stack"].(string); !strings.Contains(stack, "recovery_test.go") {
+		t.Error("expected stack of panicking handler")
+	}
+}
+
+func TestRecoveryHidesPanicValue(t *testing.T) {
+	// default error handler of the server writes the error message to the client
+	app := fiber.New()
+	app.Use(newRecovery(&logger.Logger{Logger: zap.NewNop()}, 0).handler)
+	app.Get("/", func(c *fiber.Ctx) error {
+		panic("pq: password authentication failed for user orders")
+	})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusInternalServerError || strings.Contains(string(body), "password") {
+		t.Errorf("expected generic 500 without the panic value, got %d %s", resp.StatusCode, body)
+	}
+}
+
+func TestPanicWithoutRecovery(t *testing.T) {
+	opt := defaultOption()
+	DisableRecovery()(&opt)
+	srv := &rest{service: fakeService{}, opt: opt}
+
+	app := fiber.New(fiber.Config{ErrorHandler: appErrorHandler(opt.errorHandler)})
+	app.Use(srv.restTraceLogger)
+	app.Get("/", func(c *fiber.Ctx) error {
+		panic("pq: password authentication failed for user orders")
+	})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusInternalServerError || strings.Contains(string(body), "password") {
+		t.Errorf("expected generic 500 without the panic value, got %d %s", resp.StatusCode, body)
+	}
+}
+
+func TestRecoveryBinaryBody(t *testing.T) {
+	app := fiber.New()
+	app.Post("/", func(c *fiber.Ctx) error {
+		if got := newRecovery(nil, 0).body(c); got != "[binary 4 bytes]" {
+			t.Errorf("expected binary body skipped, got %q", got)
+		}
+		return nil
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("\x89PNG"))
+	req.Header.Set(fiber.HeaderContentType, "image/png")
+	if _, err := app.Test(req); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,80 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultPageSize page size used when size query is missing
+const defaultPageSize = 20
+
+// Page pagination metadata of list response
+type Page struct {
+	Number     int   `json:"page"`
+	Size       int   `json:"size"`
+	TotalItems int64 `json:"total_items"`
+	TotalPages int   `json:"total_pages"`
+}
+
+// Offset number of items to skip for the page
+func (p Page) Offset() int {
+	return (p.Number - 1) * p.Size
+}
+
+// envelope standard success response, shares request_id with errorEnvelope
+type envelope struct {
+	Data      interface{} `json:"data"`
+	Meta      *Page       `json:"meta,omitempty"`
+	RequestId string      `json:"request_id,omitempty"`
+}
+
+// OK render data with status 200 on the standard envelope
+func OK(c *fiber.Ctx, data interface{}) error {
+	return render(c, http.StatusOK, data, nil)
+}
+
+// Created render data with status 201 on the standard envelope
+func Created(c *fiber.Ctx, data interface{}) error {
+	return render(c, http.StatusCreated, data, nil)
+}
+
+// Paginated render list data with pagination metadata, total pages is computed when empty
+func Paginated(c *fiber.Ctx, data interface{}, page Page) error {
+	if page.TotalPages == 0 && page.Size > 0 {
+		page.TotalPages = int((page.TotalItems + int64(page.Size) - 1) / int64(page.Size))
+	}
+
+	return render(c, http.StatusOK, data, &page)
+}
+
+func render(c *fiber.Ctx, sc int, data interface{}, meta *Page) error {
+	return c.Status(sc).JSON(envelope{
+		Data:      data,
+		Meta:      meta,
+		RequestId: string(c.Response().Header.Peek(headerRequestId)),
+	})
+}
+
+// ParsePagination read page and size query params, page is at least 1
+// and size is clamped between 1 and maxSize
+func ParsePagination(c *fiber.Ctx, maxSize int) Page {
+	page := Page{
+		Number: c.QueryInt("page", 1),
+		Size:   c.QueryInt("size", defaultPageSize),
+	}
+
+	if page.Number < 1 {
+		page.Number = 1
+	}
+
+	if page.Size < 1 {
+		page.Size = defaultPageSize
+	}
+
+	if maxSize > 0 && page.Size > maxSize {
+		page.Size = maxSize
+	}
+
+	return page
+}
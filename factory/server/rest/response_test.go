@@ -0,0 +1,43 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestPaginatedEnvelope(t *testing.T) {
+	app := fiber.New()
+	app.Get("/orders", func(c *fiber.Ctx) error {
+		c.Set(headerRequestId, "req-1")
+
+		page := ParsePagination(c, 50)
+		page.TotalItems = 101
+		return Paginated(c, []string{"a", "b"}, page)
+	})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/orders?page=0&size=500", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body struct {
+		Data      []string `json:"data"`
+		Meta      Page     `json:"meta"`
+		RequestId string   `json:"request_id"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+
+	if body.RequestId != "req-1" || len(body.Data) != 2 {
+		t.Errorf("unexpected envelope %+v", body)
+	}
+
+	if want := (Page{Number: 1, Size: 50, TotalItems: 101, TotalPages: 3}); body.Meta != want {
+		t.Errorf("expected clamped pagination %+v, got %+v", want, body.Meta)
+	}
+}
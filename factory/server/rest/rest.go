@@ -2,12 +2,16 @@ package rest
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"runtime/debug"
 	"strings"
 	"time"
 
+	"github.com/TixiaOTA/gokit/config"
 	"github.com/TixiaOTA/gokit/factory"
 	"github.com/TixiaOTA/gokit/logger"
 	"github.com/TixiaOTA/gokit/types"
@@ -16,14 +20,24 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	"github.com/hellofresh/health-go/v4"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap/zapcore"
 )
 
 // rest an instance of rest handler
 type rest struct {
 	serverEngine *fiber.App
+	debugEngine  *fiber.App // nil without SetDebugPort
+	http2        *http.Server
 	service      factory.ServiceFactory
 	opt          option
 	tz           *time.Location
+
+	// number of routes registered by the server itself, see Validate
+	builtinRoutes int
+	// service registered its own GET route on the version path, see version
+	versionTaken bool
+	// set once the http port is bound, see Ready
+	ready factory.ReadySignal
 }
 
 // New creates new handler for rest server
@@ -51,27 +65,86 @@ func New(svc factory.ServiceFactory, opts ...OptionFunc) factory.ApplicationFact
 	}
 
 	// set custom fiber error handling
-	fiberConfig.ErrorHandler = srv.opt.errorHandler
+	fiberConfig.ErrorHandler = appErrorHandler(srv.opt.errorHandler)
+	trustProxies(&fiberConfig, srv.opt.trustedProxies)
+	fiberConfig.StreamRequestBody = srv.opt.streamRequestBody
+	fiberConfig.DisablePreParseMultipartForm = srv.opt.streamRequestBody
+	if srv.opt.bodyLimit > 0 {
+		fiberConfig.BodyLimit = srv.opt.bodyLimit
+	}
 	srv.serverEngine = fiber.New(fiberConfig)
 
 	// add cors middleware
 	srv.serverEngine.Use(srv.opt.cors)
 	// start handler for health-check
-	h, _ := health.New()
+	h, err := health.New(health.WithChecks(srv.opt.healthChecks...))
+	if err != nil {
+		panic(fmt.Errorf("rest server: %w", err))
+	}
 	lg := srv.serverEngine.Group("/live")
 	lg.Get("/status", adaptor.HTTPHandler(h.Handler()))
+	// metadata of service, see factory.ServiceInfo
+	if srv.opt.versionPath != "" {
+		srv.serverEngine.Get(srv.opt.versionPath, srv.version)
+	}
+
+	// metrics and debug endpoints, on the debug port when set
+	var debugRouter fiber.Router = srv.serverEngine
+	if srv.opt.debugPort != "" {
+		srv.debugEngine = fiber.New(fiber.Config{AppName: svc.Name(), DisableStartupMessage: true})
+		debugRouter = srv.debugEngine
+	}
 	// metrics for prometheus
-	mg := srv.serverEngine.Group("/metrics")
-	mg.Get("", adaptor.HTTPHandler(promhttp.Handler()))
+	debugRouter.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()))
+	if srv.opt.configUsage {
+		debugRouter.Get("/debug/config-usage", func(c *fiber.Ctx) error {
+			return c.JSON(config.UsageReport())
+		})
+	}
+	if srv.opt.debugLogs {
+		debugRouter.Get("/debug/logs", debugLogs)
+	}
+	if log := srv.opt.debugLoki; log != nil {
+		debugRouter.Get("/debug/loki", func(c *fiber.Ctx) error {
+			d, ok := log.LokiStatus()
+			if !ok {
+				return fiber.NewError(fiber.StatusNotFound, "loki is not configured")
+			}
+			return c.JSON(d)
+		})
+	}
 
 	// root path for http handler
 	rootPath := srv.serverEngine.Group("")
+	if srv.opt.otel != nil {
+		rootPath.Use(srv.opt.otel.handler)
+	}
+	if srv.opt.routeMetrics {
+		rootPath.Use(routeMetrics())
+	}
+	if srv.opt.i18n != nil {
+		rootPath.Use(srv.opt.i18n.handler)
+	}
 	rootPath.Use(srv.restTraceLogger) // implement http logging
+	if srv.opt.recovery != nil {
+		rootPath.Use(srv.opt.recovery.handler)
+	}
+	if srv.opt.rateLimit != nil {
+		rootPath.Use(srv.opt.rateLimit.handler)
+	}
+	if srv.opt.idempotency != nil {
+		rootPath.Use(srv.opt.idempotency.handler)
+	}
+	if srv.opt.websocket != nil {
+		rootPath.Use(srv.opt.websocket.handler)
+	}
 
 	// apply handler to root path
+	srv.builtinRoutes = len(srv.serverEngine.GetRoutes(true))
 	if h := svc.RESTHandler(); h != nil {
 		h.Router(rootPath)
 	}
+	srv.versionTaken = srv.opt.versionPath != "" && srv.routes(http.MethodGet, srv.opt.versionPath) > 1
 
 	// print all routes
 	for _, route := range srv.serverEngine.GetRoutes(true) {
@@ -82,11 +155,39 @@ func New(svc factory.ServiceFactory, opts ...OptionFunc) factory.ApplicationFact
 		logger.Blue(fmt.Sprintf(`[REST-API-ROUTE] (method): %-6s (route): %s`, `"`+route.Method+`"`, `"`+route.Path+`"`))
 	}
 
+	if srv.opt.h2c || srv.opt.certFile != "" {
+		srv.http2 = srv.newHTTP2Server()
+	}
+
 	return srv
 }
 
 func (r *rest) Serve() {
-	err := r.serverEngine.Listen(r.opt.httpHost + ":" + r.opt.httpPort)
+	// bind the debug port before serving, a taken port fails the start as the http port does
+	if r.debugEngine != nil {
+		listener, err := net.Listen("tcp", r.opt.httpHost+":"+r.opt.debugPort)
+		if err != nil {
+			panic(fmt.Errorf("rest debug server: %w", err))
+		}
+		go func() {
+			if err := r.debugEngine.Listener(listener); err != nil {
+				logger.RedBold(fmt.Sprintf("rest debug server: %s", err))
+			}
+		}()
+	}
+
+	listener, err := net.Listen("tcp", r.opt.httpHost+":"+r.opt.httpPort)
+	if err != nil {
+		panic(fmt.Errorf("rest server: %s", err))
+	}
+	r.ready.Set()
+
+	if r.http2 != nil {
+		r.serveHTTP2(listener)
+		return
+	}
+
+	err = r.serverEngine.Listener(listener)
 
 	switch e := err.(type) {
 	case *net.OpError:
@@ -94,11 +195,117 @@ func (r *rest) Serve() {
 	}
 }
 
-func (r *rest) Shutdown(_ context.Context) {
+func (r *rest) serveHTTP2(listener net.Listener) {
+	var err error
+	if r.opt.certFile != "" {
+		err = r.http2.ServeTLS(listener, r.opt.certFile, r.opt.keyFile)
+	} else {
+		err = r.http2.Serve(listener)
+	}
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		panic(fmt.Errorf("rest server: %s", err))
+	}
+}
+
+// Ready wait until the http port is bound, see factory.Readier
+func (r *rest) Ready(ctx context.Context) error {
+	return r.ready.Wait(ctx)
+}
+
+func (r *rest) Shutdown(ctx context.Context) {
 	defer logger.RedBold("Stopping REST Server")
+
+	// close active websockets with close frame, hijacked connections are not closed by the server
+	if r.opt.websocket != nil {
+		_ = r.opt.websocket.shutdown(ctx)
+	}
+	if r.debugEngine != nil {
+		_ = r.debugEngine.Shutdown()
+	}
+
+	if r.http2 != nil {
+		_ = r.http2.Shutdown(ctx)
+		return
+	}
+
 	_ = r.serverEngine.Shutdown()
 }
 
 func (r *rest) Name() string {
 	return types.REST.String()
 }
+
+// routes number of routes of method and path, middlewares excluded
+func (r *rest) routes(method, path string) int {
+	var n int
+	for _, route := range r.serverEngine.GetRoutes(true) {
+		if route.Method == method && route.Path == path {
+			n++
+		}
+	}
+
+	return n
+}
+
+// version render registered factory.ServiceInfo with build info of the binary,
+// pass to the route of the service when it registered the same path
+func (r *rest) version(c *fiber.Ctx) error {
+	if r.versionTaken {
+		return c.Next()
+	}
+
+	var resp = struct {
+		factory.ServiceInfo
+		GoVersion string `json:"go_version,omitempty"`
+		Version   string `json:"version,omitempty"`
+	}{ServiceInfo: factory.GetServiceInfo()}
+
+	if resp.Name == "" {
+		resp.Name = r.service.Name()
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		resp.GoVersion = bi.GoVersion
+		resp.Version = bi.Main.Version
+	}
+
+	return c.JSON(resp)
+}
+
+// debugLogs recent log entries of logger.RecentEntries, filtered by minimum "level" query
+func debugLogs(c *fiber.Ctx) error {
+	entries := logger.RecentEntries()
+	if q := c.Query("level"); q != "" {
+		minLevel, err := zapcore.ParseLevel(q)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		filtered := entries[:0]
+		for _, e := range entries {
+			if lvl, err := zapcore.ParseLevel(e.Level); err == nil && lvl >= minLevel {
+				filtered = append(filtered, e)
+			}
+		}
+		entries = filtered
+	}
+
+	return c.JSON(entries)
+}
+
+// Validate check that WithTLS certificate and key files load and the service registered routes besides the
+// built-in ones, e.g. /live/status
+func (r *rest) Validate(_ context.Context) error {
+	if r.opt.certFile != "" {
+		if _, err := tls.LoadX509KeyPair(r.opt.certFile, r.opt.keyFile); err != nil {
+			return fmt.Errorf("rest server: %w", err)
+		}
+	}
+
+	if len(r.serverEngine.GetRoutes(true)) <= r.builtinRoutes {
+		return fmt.Errorf("rest server: no route registered")
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package rest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/abstract"
+	"github.com/gofiber/fiber/v2"
+	"github.com/hellofresh/health-go/v4"
+)
+
+// orderHandler register GET /orders
+type orderHandler struct{}
+
+func (orderHandler) Router(r fiber.Router) {
+	r.Get("/orders", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+}
+
+type orderService struct{ fakeService }
+
+func (orderService) RESTHandler() abstract.RestHandler { return orderHandler{} }
+
+func TestValidateIgnoresBuiltinRoutes(t *testing.T) {
+	if err := New(fakeService{}, SetHTTPPort(0)).(*rest).Validate(context.Background()); err == nil {
+		t.Errorf("expected built-in routes alone rejected")
+	}
+
+	if err := New(orderService{}, SetHTTPPort(0)).(*rest).Validate(context.Background()); err != nil {
+		t.Errorf("expected registered routes accepted, got %v", err)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	check := health.Config{Name: "rabbitmq", Check: func(context.Context) error { return errors.New("recovering") }}
+	srv := New(orderService{}, SetHTTPPort(0), WithHealthCheck(check)).(*rest)
+
+	resp, err := srv.serverEngine.Test(httptest.NewRequest(fiber.MethodGet, "/live/status", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusServiceUnavailable {
+		t.Errorf("expected failing check reported, got %d", resp.StatusCode)
+	}
+}
+
+// writeKeyPair write PEM certificate and key files of a self-signed certificate
+func writeKeyPair(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	if err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestValidateTLSFiles(t *testing.T) {
+	certFile, keyFile := writeKeyPair(t)
+	garbage := filepath.Join(t.TempDir(), "garbage.pem")
+	if err := os.WriteFile(garbage, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name      string
+		cert, key string
+		valid     bool
+	}{
+		{"valid", certFile, keyFile, true},
+		{"missing", filepath.Join(t.TempDir(), "missing.crt"), keyFile, false},
+		{"garbage", garbage, keyFile, false},
+		{"mismatched", certFile, garbage, false},
+	} {
+		if err := New(orderService{}, SetHTTPPort(0), WithTLS(tc.cert, tc.key)).(*rest).Validate(context.Background()); (err == nil) != tc.valid {
+			t.Errorf("%s: expected valid %v, got %v", tc.name, tc.valid, err)
+		}
+	}
+}
+
+// versionHandler register the service own GET /version
+type versionHandler struct{}
+
+func (versionHandler) Router(r fiber.Router) {
+	r.Get("/version", func(c *fiber.Ctx) error { return c.SendString("v2-api") })
+}
+
+type versionService struct{ fakeService }
+
+func (versionService) RESTHandler() abstract.RestHandler { return versionHandler{} }
+
+func TestVersionPath(t *testing.T) {
+	get := func(srv *rest, path string) (int, string) {
+		resp, err := srv.serverEngine.Test(httptest.NewRequest(fiber.MethodGet, path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if sc, body := get(New(orderService{}, SetHTTPPort(0)).(*rest), "/version"); sc != fiber.StatusOK || !strings.Contains(body, `"name":"test"`) {
+		t.Errorf("expected built-in /version, got %d %s", sc, body)
+	}
+
+	if sc, body := get(New(versionService{}, SetHTTPPort(0)).(*rest), "/version"); sc != fiber.StatusOK || body != "v2-api" {
+		t.Errorf("expected route of the service served, got %d %s", sc, body)
+	}
+
+	srv := New(versionService{}, SetHTTPPort(0), SetVersionPath("/meta/version")).(*rest)
+	if sc, body := get(srv, "/meta/version"); sc != fiber.StatusOK || !strings.Contains(body, `"name":"test"`) {
+		t.Errorf("expected metadata on configured path, got %d %s", sc, body)
+	}
+	if _, body := get(srv, "/version"); body != "v2-api" {
+		t.Errorf("expected route of the service kept, got %s", body)
+	}
+
+	if sc, _ := get(New(orderService{}, SetHTTPPort(0), SetVersionPath("")).(*rest), "/version"); sc != fiber.StatusNotFound {
+		t.Errorf("expected /version disabled, got %d", sc)
+	}
+}
+
+func TestDebugPortTaken(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	srv := New(fakeService{}, SetHTTPHost("127.0.0.1"), SetHTTPPort(0), SetDebugPort(l.Addr().(*net.TCPAddr).Port)).(*rest)
+	defer func() {
+		r := recover()
+		if err, ok := r.(error); !ok || !strings.Contains(err.Error(), "rest debug server") {
+			t.Errorf("expected Serve to panic on a taken debug port, got %v", r)
+		}
+	}()
+	srv.Serve()
+}
+
+func TestReady(t *testing.T) {
+	srv := New(fakeService{}, SetHTTPHost("127.0.0.1"), SetHTTPPort(0)).(*rest)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := srv.Ready(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected not ready before Serve, got %v", err)
+	}
+
+	go srv.Serve()
+	defer srv.Shutdown(context.Background())
+
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := srv.Ready(ctx); err != nil {
+		t.Fatalf("expected ready once the port is bound, got %v", err)
+	}
+}
@@ -0,0 +1,251 @@
+package rest
+
+import (
+	"container/heap"
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// CacheStore shared state store used by rest middlewares (rate limit, idempotency),
+// use redis store for multi replicas and memory store for single replica
+type CacheStore interface {
+	// Get value of key, ok is false when key not exists or expired
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Set value of key with ttl
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// SetNX set value of key with ttl only when key not exists
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	// Incr increment counter of key, ttl is applied when the key is created
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// Delete key
+	Delete(ctx context.Context, key string) error
+}
+
+// memoryStoreSize maximum keys of memory store, keys expiring first are evicted beyond it
+const memoryStoreSize = 100000
+
+type memoryItem struct {
+	key       string
+	value     []byte
+	expiredAt time.Time
+	index     int // position on expiryHeap
+}
+
+// expiryHeap items ordered by expiry, keys without ttl last
+type expiryHeap []*memoryItem
+
+func (h expiryHeap) Len() int { return len(h) }
+
+func (h expiryHeap) Less(i, j int) bool {
+	a, b := h[i].expiredAt, h[j].expiredAt
+	if a.IsZero() || b.IsZero() {
+		return !a.IsZero() && b.IsZero()
+	}
+
+	return a.Before(b)
+}
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x interface{}) {
+	item := x.(*memoryItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+
+	return item
+}
+
+// memoryStore in memory CacheStore
+type memoryStore struct {
+	mu       sync.Mutex
+	items    map[string]*memoryItem
+	expiries expiryHeap
+	now      func() time.Time
+	size     int
+}
+
+// NewMemoryStore create in memory CacheStore, state is not shared across replicas.
+// expired keys are removed on access and swept on write, keys expiring first are evicted beyond 100000 keys
+func NewMemoryStore() CacheStore {
+	return &memoryStore{
+		items: make(map[string]*memoryItem),
+		now:   time.Now,
+		size:  memoryStoreSize,
+	}
+}
+
+// load get item, must be called with lock held
+func (m *memoryStore) load(key string) (*memoryItem, bool) {
+	item, ok := m.items[key]
+	if ok && !item.expiredAt.IsZero() && !m.now().Before(item.expiredAt) {
+		m.remove(item)
+		return nil, false
+	}
+
+	return item, ok
+}
+
+// store put value of key, sweep expired keys then evict the key expiring first when full,
+// must be called with lock held
+func (m *memoryStore) store(key string, value []byte, expiredAt time.Time) {
+	if item, ok := m.items[key]; ok {
+		item.value, item.expiredAt = value, expiredAt
+		heap.Fix(&m.expiries, item.index)
+		return
+	}
+
+	m.sweep(m.now())
+	if len(m.items) >= m.size {
+		m.remove(m.expiries[0])
+	}
+
+	item := &memoryItem{key: key, value: value, expiredAt: expiredAt}
+	heap.Push(&m.expiries, item)
+	m.items[key] = item
+}
+
+// sweep remove expired keys from the head of the expiry heap, must be called with lock held
+func (m *memoryStore) sweep(now time.Time) {
+	for len(m.expiries) > 0 {
+		item := m.expiries[0]
+		if item.expiredAt.IsZero() || now.Before(item.expiredAt) {
+			return
+		}
+
+		m.remove(item)
+	}
+}
+
+// remove item from map and expiry heap, must be called with lock held
+func (m *memoryStore) remove(item *memoryItem) {
+	heap.Remove(&m.expiries, item.index)
+	delete(m.items, item.key)
+}
+
+func (m *memoryStore) expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+
+	return m.now().Add(ttl)
+}
+
+func (m *memoryStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, ok := m.load(key)
+	if !ok {
+		return nil, false, nil
+	}
+
+	return item.value, true, nil
+}
+
+func (m *memoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.store(key, value, m.expiry(ttl))
+	return nil
+}
+
+func (m *memoryStore) SetNX(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.load(key); ok {
+		return false, nil
+	}
+
+	m.store(key, value, m.expiry(ttl))
+	return true, nil
+}
+
+func (m *memoryStore) Incr(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var counter int64
+	expiredAt := m.expiry(ttl)
+	if item, ok := m.load(key); ok {
+		counter, _ = strconv.ParseInt(string(item.value), 10, 64)
+		expiredAt = item.expiredAt
+	}
+
+	counter++
+	m.store(key, []byte(strconv.FormatInt(counter, 10)), expiredAt)
+
+	return counter, nil
+}
+
+func (m *memoryStore) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if item, ok := m.items[key]; ok {
+		m.remove(item)
+	}
+	return nil
+}
+
+// redisStore redis CacheStore
+type redisStore struct {
+	client goredis.Cmdable
+}
+
+// NewRedisStore create CacheStore backed by redis, state is shared across replicas
+func NewRedisStore(client goredis.Cmdable) CacheStore {
+	return &redisStore{client: client}
+}
+
+func (r *redisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	val, err := r.client.Get(ctx, key).Bytes()
+	if err == goredis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return val, true, nil
+}
+
+func (r *redisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (r *redisStore) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, key, value, ttl).Result()
+}
+
+// Incr create the key with ttl by SET NX PX before incrementing it, unlike EXPIRE NX it works before redis 7
+func (r *redisStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	pipe := r.client.TxPipeline()
+	pipe.SetNX(ctx, key, 0, ttl)
+	incr := pipe.Incr(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	return incr.Val(), nil
+}
+
+func (r *redisStore) Delete(ctx context.Context, key string) error {
+	return r.client.Del(ctx, key).Err()
+}
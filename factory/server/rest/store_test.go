@@ -0,0 +1,43 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreSweepAndEvict(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	m := NewMemoryStore().(*memoryStore)
+	m.now = func() time.Time { return now }
+	m.size = 3
+
+	for i := 0; i < 3; i++ {
+		_, _ = m.Incr(ctx, fmt.Sprintf("window-%d", i), time.Second)
+	}
+
+	// expired keys never read again are swept on the next write
+	now = now.Add(time.Minute)
+	_ = m.Set(ctx, "fresh", []byte("1"), time.Hour)
+	if len(m.items) != 1 {
+		t.Fatalf("expected expired keys swept, got %d keys", len(m.items))
+	}
+
+	// beyond the size the key expiring first is evicted
+	_ = m.Set(ctx, "forever", []byte("1"), 0)
+	_ = m.Set(ctx, "soon", []byte("1"), time.Minute)
+	_ = m.Set(ctx, "later", []byte("1"), 2*time.Hour)
+	if len(m.items) != 3 {
+		t.Fatalf("expected store bounded to 3 keys, got %d", len(m.items))
+	}
+	if _, ok, _ := m.Get(ctx, "soon"); ok {
+		t.Errorf("expected key expiring first evicted")
+	}
+	for _, key := range []string{"fresh", "forever", "later"} {
+		if _, ok, _ := m.Get(ctx, key); !ok {
+			t.Errorf("expected %s kept", key)
+		}
+	}
+}
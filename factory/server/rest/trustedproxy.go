@@ -0,0 +1,123 @@
+package rest
+
+import (
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// headerRealIP client IP set by proxies not appending X-Forwarded-For
+const headerRealIP = "X-Real-Ip"
+
+// trustedNets parsed fiber.Config TrustedProxies by app
+var trustedNets sync.Map
+
+// trustProxies configure fiber to honor X-Forwarded-Proto and X-Forwarded-Host from cidrs only, nothing when cidrs is empty.
+// ProxyHeader stays unset so c.IP() is the peer address and never the client controlled leftmost X-Forwarded-For, use RealIP
+func trustProxies(cfg *fiber.Config, cidrs []string) {
+	if len(cidrs) < 1 {
+		return
+	}
+
+	cfg.EnableTrustedProxyCheck = true
+	cfg.TrustedProxies = cidrs
+}
+
+// RealIP client IP of request, X-Forwarded-For is walked from the nearest hop and the first hop not
+// in trusted proxies is the client, so entries prepended by the client are never trusted.
+// X-Real-IP is used when X-Forwarded-For is absent, headers of untrusted peers are ignored, see WithTrustedProxies
+func RealIP(c *fiber.Ctx) string {
+	remote := c.Context().RemoteIP().String()
+	nets := appTrustedNets(c.App())
+	if !isTrusted(nets, remote) {
+		return remote
+	}
+
+	xff := c.Get(fiber.HeaderXForwardedFor)
+	if xff == "" {
+		if ip := strings.TrimSpace(c.Get(headerRealIP)); net.ParseIP(ip) != nil {
+			return ip
+		}
+
+		return remote
+	}
+
+	client := remote
+	hops := strings.Split(xff, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+
+		client = hop
+		if !isTrusted(nets, hop) {
+			break
+		}
+	}
+
+	return client
+}
+
+// IsSecure report whether request reached the service over https, X-Forwarded-Proto is honored from trusted proxies only
+func IsSecure(c *fiber.Ctx) bool {
+	return c.Protocol() == "https"
+}
+
+// trustedPeer report whether the peer of request is a trusted proxy, every peer when no proxy is trusted
+func trustedPeer(c *fiber.Ctx) bool {
+	nets := appTrustedNets(c.App())
+	return len(nets) < 1 || isTrusted(nets, c.Context().RemoteIP().String())
+}
+
+// appTrustedNets trusted proxies of app, nil when fiber.Config EnableTrustedProxyCheck is disabled
+func appTrustedNets(app *fiber.App) []*net.IPNet {
+	if v, ok := trustedNets.Load(app); ok {
+		return v.([]*net.IPNet)
+	}
+
+	var nets []*net.IPNet
+	if cfg := app.Config(); cfg.EnableTrustedProxyCheck {
+		nets = parseTrustedProxies(cfg.TrustedProxies)
+	}
+
+	trustedNets.Store(app, nets)
+	return nets
+}
+
+// parseTrustedProxies parse CIDRs and IPs, invalid entries are skipped
+func parseTrustedProxies(proxies []string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, p := range proxies {
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+
+		if _, ipNet, err := net.ParseCIDR(p); err == nil {
+			nets = append(nets, ipNet)
+		}
+	}
+
+	return nets
+}
+
+func isTrusted(nets []*net.IPNet, addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,104 @@
+package rest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestTrustedProxies(t *testing.T) {
+	newApp := func(cidrs ...string) *fiber.App {
+		cfg := fiber.Config{}
+		trustProxies(&cfg, cidrs)
+
+		app := fiber.New(cfg)
+		app.Get("/", func(c *fiber.Ctx) error {
+			scheme := "http"
+			if IsSecure(c) {
+				scheme = "https"
+			}
+			return c.SendString(RealIP(c) + " " + scheme + " " + c.IP())
+		})
+		return app
+	}
+
+	// test requests arrive from 0.0.0.0, c.IP() stays the peer whatever the client prepends to X-Forwarded-For
+	trusted := newApp("0.0.0.0/32", "10.0.0.0/8")
+	untrusted := newApp("10.0.0.0/8")
+
+	for _, tc := range []struct {
+		name    string
+		app     *fiber.App
+		headers map[string]string
+		want    string
+	}{
+		{"trusted", trusted, map[string]string{"X-Forwarded-For": "203.0.113.7", "X-Forwarded-Proto": "https"}, "203.0.113.7 https 0.0.0.0"},
+		{"trusted real ip", trusted, map[string]string{"X-Real-Ip": "203.0.113.8"}, "203.0.113.8 http 0.0.0.0"},
+		{"untrusted", untrusted, map[string]string{"X-Forwarded-For": "203.0.113.7", "X-Forwarded-Proto": "https"}, "0.0.0.0 http 0.0.0.0"},
+		{"multi hop", trusted, map[string]string{"X-Forwarded-For": "198.51.100.1, 203.0.113.7, 10.1.2.3"}, "203.0.113.7 http 0.0.0.0"},
+		{"all hops trusted", trusted, map[string]string{"X-Forwarded-For": "10.0.0.5, 10.1.2.3"}, "10.0.0.5 http 0.0.0.0"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+
+		resp, err := tc.app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != tc.want {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.want, body)
+		}
+	}
+}
+
+func TestIncomingBaggage(t *testing.T) {
+	newApp := func(opts ...OptionFunc) *fiber.App {
+		opt := defaultOption()
+		for _, o := range opts {
+			o(&opt)
+		}
+		srv := &rest{service: fakeService{}, opt: opt}
+
+		cfg := fiber.Config{}
+		trustProxies(&cfg, opt.trustedProxies)
+		app := fiber.New(cfg)
+		app.Use(srv.restTraceLogger)
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString(logger.GetBaggage(c.UserContext())["tenant_id"])
+		})
+		return app
+	}
+
+	// test requests arrive from 0.0.0.0
+	for _, tc := range []struct {
+		name string
+		app  *fiber.App
+		want string
+	}{
+		{"disabled", newApp(), ""},
+		{"enabled", newApp(WithIncomingBaggage()), "acme"},
+		{"trusted proxy", newApp(WithIncomingBaggage(), WithTrustedProxies([]string{"0.0.0.0/32"})), "acme"},
+		{"untrusted peer", newApp(WithIncomingBaggage(), WithTrustedProxies([]string{"10.0.0.0/8"})), ""},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Baggage-Tenant-Id", "acme")
+
+		resp, err := tc.app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != tc.want {
+			t.Errorf("%s: expected tenant %q, got %q", tc.name, tc.want, body)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/TixiaOTA/gokit/utils/errorkit"
+	"github.com/gofiber/fiber/v2"
+)
+
+// sniffLen number of bytes used to detect content type, see http.DetectContentType
+const sniffLen = 512
+
+// UploadOptions limitation for multipart upload
+type UploadOptions struct {
+	// Field form field name of the file, default is "file"
+	Field string
+	// MaxSize maximum file size in bytes, zero means unlimited
+	MaxSize int64
+	// AllowedMIMETypes list of allowed mime types sniffed from file content, empty means allow all
+	AllowedMIMETypes []string
+}
+
+// Upload an instance of uploaded file
+type Upload struct {
+	Filename    string
+	ContentType string
+	// Size bytes written by Save, zero before
+	Size int64
+
+	maxSize int64
+	head    []byte
+	file    io.Reader
+	closer  io.Closer
+}
+
+// ParseUpload read multipart request up to the file part and validate its content type, the content type is
+// sniffed from the file content instead of trusted from the header. with WithUploadStreaming the file is read
+// from the connection by Save and an oversize file is rejected mid-stream, otherwise the buffered form is used
+func ParseUpload(c *fiber.Ctx, opts UploadOptions) (*Upload, error) {
+	if opts.Field == "" {
+		opts.Field = "file"
+	}
+
+	body := c.Request().BodyStream()
+	if body == nil {
+		return parseBufferedUpload(c, opts)
+	}
+
+	_, params, err := mime.ParseMediaType(c.Get(fiber.HeaderContentType))
+	if err != nil || params["boundary"] == "" {
+		return nil, fiber.NewError(http.StatusBadRequest, errorkit.BadRequest)
+	}
+
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return nil, fiber.NewError(http.StatusBadRequest, errorkit.BadRequest)
+		}
+
+		if part.FormName() == opts.Field && part.FileName() != "" {
+			return newUpload(part.FileName(), part, part, opts)
+		}
+	}
+}
+
+// parseBufferedUpload file of the multipart form parsed from the buffered body, its size is known upfront
+func parseBufferedUpload(c *fiber.Ctx, opts UploadOptions) (*Upload, error) {
+	fh, err := c.FormFile(opts.Field)
+	if err != nil {
+		return nil, fiber.NewError(http.StatusBadRequest, errorkit.BadRequest)
+	}
+
+	if opts.MaxSize > 0 && fh.Size > opts.MaxSize {
+		return nil, fiber.NewError(http.StatusRequestEntityTooLarge, errorkit.FileTooLarge)
+	}
+
+	file, err := fh.Open()
+	if err != nil {
+		return nil, fiber.NewError(http.StatusInternalServerError, errorkit.InternalServer)
+	}
+
+	return newUpload(fh.Filename, file, file, opts)
+}
+
+func newUpload(filename string, file io.Reader, closer io.Closer, opts UploadOptions) (*Upload, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		_ = closer.Close()
+		return nil, fiber.NewError(http.StatusBadRequest, errorkit.BadRequest)
+	}
+	head = head[:n]
+
+	if opts.MaxSize > 0 && int64(n) > opts.MaxSize {
+		_ = closer.Close()
+		return nil, fiber.NewError(http.StatusRequestEntityTooLarge, errorkit.FileTooLarge)
+	}
+
+	contentType := http.DetectContentType(head)
+	if !allowedMIMEType(contentType, opts.AllowedMIMETypes) {
+		_ = closer.Close()
+		return nil, fiber.NewError(http.StatusUnsupportedMediaType, errorkit.UnsupportedMediaType)
+	}
+
+	return &Upload{
+		Filename:    filename,
+		ContentType: contentType,
+		maxSize:     opts.MaxSize,
+		head:        head,
+		file:        file,
+		closer:      closer,
+	}, nil
+}
+
+// Save stream uploaded file into dst without copying the whole file into memory, reading stops at MaxSize
+// and the oversize file is rejected with 413, dst then holds a partial file to be discarded
+func (u *Upload) Save(ctx context.Context, dst io.Writer) (int64, error) {
+	defer u.Close()
+
+	var src io.Reader = &ctxReader{ctx: ctx, r: io.MultiReader(bytes.NewReader(u.head), u.file)}
+	if u.maxSize < 1 {
+		written, err := io.Copy(dst, src)
+		u.Size = written
+		return written, err
+	}
+
+	written, err := io.Copy(dst, io.LimitReader(src, u.maxSize))
+	u.Size = written
+	if err != nil {
+		return written, err
+	}
+
+	// a single byte past the limit is enough to reject the file
+	if n, _ := io.ReadFull(src, make([]byte, 1)); n > 0 {
+		return written, fiber.NewError(http.StatusRequestEntityTooLarge, errorkit.FileTooLarge)
+	}
+
+	return written, nil
+}
+
+// Close release uploaded file, the rest of a streamed request body is discarded with the request
+func (u *Upload) Close() error {
+	u.head = nil
+	return u.closer.Close()
+}
+
+func allowedMIMEType(contentType string, allowed []string) bool {
+	if len(allowed) < 1 {
+		return true
+	}
+
+	// strip parameter, e.g. "text/plain; charset=utf-8"
+	mediaType := strings.TrimSpace(strings.Split(contentType, ";")[0])
+	for _, a := range allowed {
+		if strings.EqualFold(a, mediaType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ctxReader stop reading when context is done
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *ctxReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return cr.r.Read(p)
+}
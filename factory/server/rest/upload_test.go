@@ -0,0 +1,188 @@
+package rest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/textproto"
+	"runtime"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// uploadApp app streaming bodies bigger than 64KB into handler, as configured by WithUploadStreaming
+func uploadApp(handler fiber.Handler) *fiber.App {
+	app := fiber.New(fiber.Config{
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+		BodyLimit:                    64 << 10,
+		DisableStartupMessage:        true,
+	})
+	app.Post("/upload", handler)
+	return app
+}
+
+// uploadBoundary boundary of multipartBody, fixed so the length of the form is known upfront
+const uploadBoundary = "gokit-upload-boundary"
+
+// multipartBody multipart form of a file with content type and size bytes of content generated while read,
+// and its length
+func multipartBody(contentType string, head []byte, size int64) (io.Reader, string, int64) {
+	write := func(w io.Writer, content io.Reader) (string, error) {
+		mw := multipart.NewWriter(w)
+		_ = mw.SetBoundary(uploadBoundary)
+
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", `form-data; name="file"; filename="file.bin"`)
+		h.Set("Content-Type", contentType)
+		part, err := mw.CreatePart(h)
+		if err == nil {
+			_, err = io.Copy(part, content)
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+
+		return mw.FormDataContentType(), err
+	}
+
+	var empty bytes.Buffer
+	formContentType, _ := write(&empty, bytes.NewReader(nil))
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := write(pw, io.MultiReader(bytes.NewReader(head), io.LimitReader(zeroReader{}, size-int64(len(head)))))
+		_ = pw.CloseWithError(err)
+	}()
+
+	return pr, formContentType, int64(empty.Len()) + size
+}
+
+// zeroReader endless zero bytes
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// serveUpload serve app on a local listener and post body streamed by the client
+func serveUpload(t *testing.T, app *fiber.App, body io.Reader, contentType string, length int64) (int, error) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = app.Listener(l) }()
+	t.Cleanup(func() { _ = app.Shutdown() })
+
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s/upload", l.Addr()), body)
+	req.Header.Set(fiber.HeaderContentType, contentType)
+	req.ContentLength = length
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+func TestUploadOversizeRejectedMidStream(t *testing.T) {
+	const maxSize = 1 << 20
+
+	var saved int64
+	app := uploadApp(func(c *fiber.Ctx) error {
+		up, err := ParseUpload(c, UploadOptions{MaxSize: maxSize})
+		if err != nil {
+			return err
+		}
+
+		saved, err = up.Save(c.UserContext(), io.Discard)
+		return err
+	})
+
+	// the server answers before the client sent the whole body, the client may see the reset instead
+	body, contentType, length := multipartBody("application/octet-stream", nil, 8<<20)
+	if status, err := serveUpload(t, app, body, contentType, length); err == nil && status != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d", status)
+	}
+	if saved != maxSize {
+		t.Errorf("expected reading stopped at %d bytes, saved %d", maxSize, saved)
+	}
+}
+
+func TestUploadSniffMismatch(t *testing.T) {
+	app := fiber.New()
+	app.Post("/upload", func(c *fiber.Ctx) error {
+		_, err := ParseUpload(c, UploadOptions{AllowedMIMETypes: []string{"image/png"}})
+		return err
+	})
+
+	// declared as png, content is plain text
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", `form-data; name="file"; filename="avatar.png"`)
+	h.Set("Content-Type", "image/png")
+	part, _ := mw.CreatePart(h)
+	_, _ = part.Write([]byte("definitely not an image"))
+	_ = mw.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, "/upload", &buf)
+	req.Header.Set(fiber.HeaderContentType, mw.FormDataContentType())
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("expected 415, got %d", resp.StatusCode)
+	}
+}
+
+func TestUploadStreamsWithBoundedMemory(t *testing.T) {
+	const size = 32 << 20
+	png := []byte("\x89PNG\r\n\x1a\n")
+
+	var (
+		sum   []byte
+		saved int64
+	)
+	app := uploadApp(func(c *fiber.Ctx) error {
+		up, err := ParseUpload(c, UploadOptions{MaxSize: size, AllowedMIMETypes: []string{"image/png"}})
+		if err != nil {
+			return err
+		}
+
+		h := sha256.New()
+		if saved, err = up.Save(c.UserContext(), h); err != nil {
+			return err
+		}
+		sum = h.Sum(nil)
+
+		return c.SendStatus(http.StatusCreated)
+	})
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	body, contentType, length := multipartBody("image/png", png, size)
+	if status, err := serveUpload(t, app, body, contentType, length); err != nil || status != http.StatusCreated {
+		t.Fatalf("expected 201, got %d %v", status, err)
+	}
+
+	runtime.ReadMemStats(&after)
+	if saved != size || len(sum) == 0 {
+		t.Errorf("expected %d bytes saved, got %d", size, saved)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > size/2 {
+		t.Errorf("expected file streamed, allocated %d bytes for a %d bytes file", allocated, size)
+	}
+}
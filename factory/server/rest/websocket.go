@@ -0,0 +1,185 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+// websocketHubKey fiber locals key of websocket hub
+const websocketHubKey = "gokit.websocket.hub"
+
+// errWebsocketDisabled returned by Websocket handler when WithWebsocket is not set
+var errWebsocketDisabled = fiber.NewError(http.StatusInternalServerError, "websocket is not enabled, see rest.WithWebsocket")
+
+// WebsocketConn websocket connection with connection scoped context,
+// ReadMessage and WriteMessage apply the configured deadlines
+type WebsocketConn struct {
+	*websocket.Conn
+
+	ctx context.Context
+	hub *websocketHub
+}
+
+// Context connection scoped context carrying request id and logger of the upgrade request,
+// canceled when connection is closed
+func (c *WebsocketConn) Context() context.Context {
+	return c.ctx
+}
+
+// ReadMessage read next message within read timeout
+func (c *WebsocketConn) ReadMessage() (messageType int, p []byte, err error) {
+	if c.hub.readTimeout > 0 {
+		_ = c.SetReadDeadline(time.Now().Add(c.hub.readTimeout))
+	}
+
+	return c.Conn.ReadMessage()
+}
+
+// WriteMessage write message within write timeout
+func (c *WebsocketConn) WriteMessage(messageType int, data []byte) error {
+	if c.hub.writeTimeout > 0 {
+		_ = c.SetWriteDeadline(time.Now().Add(c.hub.writeTimeout))
+	}
+
+	return c.Conn.WriteMessage(messageType, data)
+}
+
+// Websocket upgrade request into websocket connection handled by handler, use inside RestHandler.Router, e.g.
+//
+//	r.Get("/ws", rest.Websocket(func(conn *rest.WebsocketConn) { ... }))
+func Websocket(handler func(conn *WebsocketConn)) fiber.Handler {
+	upgrade := func(hub *websocketHub, ctx context.Context) fiber.Handler {
+		return websocket.New(func(conn *websocket.Conn) {
+			hub.serve(ctx, conn, handler)
+		})
+	}
+
+	return func(c *fiber.Ctx) error {
+		hub, ok := c.Locals(websocketHubKey).(*websocketHub)
+		if !ok {
+			return errWebsocketDisabled
+		}
+
+		if !websocket.IsWebSocketUpgrade(c) {
+			return fiber.ErrUpgradeRequired
+		}
+
+		return upgrade(hub, connectionContext(c.UserContext()))(c)
+	}
+}
+
+// connectionContext context outliving the upgrade request, with its own logger locker
+// since the request data logger is finalized once upgraded
+func connectionContext(requestCtx context.Context) context.Context {
+	lock := new(logger.Locker)
+	lock.Set(logger.RequestId, logger.GetRequestId(requestCtx))
+
+	return context.WithValue(context.WithoutCancel(requestCtx), logger.LogKey, lock)
+}
+
+// websocketHub active websocket connections, closed with close frame on shutdown
+type websocketHub struct {
+	readTimeout    time.Duration
+	writeTimeout   time.Duration
+	maxMessageSize int64
+
+	mu      sync.Mutex
+	conns   map[*WebsocketConn]struct{}
+	closing bool
+	wg      sync.WaitGroup
+}
+
+func newWebsocketHub(readTimeout, writeTimeout time.Duration, maxMessageSize int64) *websocketHub {
+	return &websocketHub{
+		readTimeout:    readTimeout,
+		writeTimeout:   writeTimeout,
+		maxMessageSize: maxMessageSize,
+		conns:          make(map[*WebsocketConn]struct{}),
+	}
+}
+
+// handler middleware exposing hub to Websocket handlers
+func (h *websocketHub) handler(c *fiber.Ctx) error {
+	c.Locals(websocketHubKey, h)
+	return c.Next()
+}
+
+func (h *websocketHub) serve(ctx context.Context, conn *websocket.Conn, handler func(conn *WebsocketConn)) {
+	ctx, cancel := context.WithCancel(ctx)
+	wc := &WebsocketConn{Conn: conn, ctx: ctx, hub: h}
+
+	h.mu.Lock()
+	if h.closing {
+		h.mu.Unlock()
+		cancel()
+		_ = wc.closeGoingAway()
+		return
+	}
+	h.conns[wc] = struct{}{}
+	h.wg.Add(1)
+	h.mu.Unlock()
+
+	defer func() {
+		h.mu.Lock()
+		delete(h.conns, wc)
+		h.mu.Unlock()
+
+		cancel()
+		h.wg.Done()
+	}()
+
+	if h.maxMessageSize > 0 {
+		conn.SetReadLimit(h.maxMessageSize)
+	}
+
+	handler(wc)
+}
+
+// closeGoingAway send close frame telling client the server is going away
+func (c *WebsocketConn) closeGoingAway() error {
+	return c.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutdown"),
+		time.Now().Add(time.Second),
+	)
+}
+
+// shutdown send close frame to every active connection and wait their handlers to return,
+// connections still open when ctx is done are closed forcefully
+func (h *websocketHub) shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	h.closing = true
+	conns := make([]*WebsocketConn, 0, len(h.conns))
+	for wc := range h.conns {
+		conns = append(conns, wc)
+	}
+	h.mu.Unlock()
+
+	for _, wc := range conns {
+		_ = wc.closeGoingAway()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		for _, wc := range conns {
+			_ = wc.Close()
+		}
+
+		return errors.New("rest server: websocket connections closed forcefully")
+	}
+}
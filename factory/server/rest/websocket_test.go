@@ -0,0 +1,90 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/logger"
+	fws "github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestWebsocketEchoAndShutdown(t *testing.T) {
+	opt := defaultOption()
+	WithWebsocket(time.Minute, time.Second, 1024)(&opt)
+	srv := &rest{serverEngine: fiber.New(), opt: opt}
+
+	requestIds := make(chan string, 1)
+	srv.serverEngine.Use(func(c *fiber.Ctx) error {
+		lock := new(logger.Locker)
+		lock.Set(logger.RequestId, "req-ws")
+		c.SetUserContext(context.WithValue(c.UserContext(), logger.LogKey, lock))
+		return c.Next()
+	})
+	srv.serverEngine.Use(srv.opt.websocket.handler)
+	srv.serverEngine.Get("/ws", Websocket(func(conn *WebsocketConn) {
+		requestIds <- logger.GetRequestId(conn.Context())
+		for {
+			mt, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err = conn.WriteMessage(mt, msg); err != nil {
+				return
+			}
+		}
+	}))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = srv.serverEngine.Listener(l) }()
+
+	client, _, err := fws.DefaultDialer.Dial("ws://"+l.Addr().String()+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if id := <-requestIds; id != "req-ws" {
+		t.Errorf("expected request id carried into connection context, got %q", id)
+	}
+
+	for _, msg := range []string{"hello", "world"} {
+		if err = client.WriteMessage(fws.TextMessage, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		if _, got, err := client.ReadMessage(); err != nil || string(got) != msg {
+			t.Fatalf("expected echo %q, got %q %v", msg, got, err)
+		}
+	}
+
+	// client answers the close frame, letting the handler return
+	shutdown := make(chan struct{})
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+		close(shutdown)
+	}()
+
+	_, _, err = client.ReadMessage()
+	var ce *fws.CloseError
+	if !errors.As(err, &ce) || ce.Code != fws.CloseGoingAway {
+		t.Fatalf("expected going away close frame, got %v", err)
+	}
+
+	select {
+	case <-shutdown:
+	case <-time.After(3 * time.Second):
+		t.Fatal("expected Shutdown returns once sockets are closed")
+	}
+
+	if n := len(srv.opt.websocket.conns); n != 0 {
+		t.Errorf("expected no active socket after shutdown, got %d", n)
+	}
+}
@@ -2,30 +2,76 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/TixiaOTA/gokit/factory"
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/TixiaOTA/gokit/utils/env"
 )
 
 // server an instance for running services with factory.ApplicationFactory
 type server struct {
 	service factory.ServiceFactory
+
+	// service discovery, see WithRegistrar
+	registrar     factory.Registrar
+	registerFatal bool
+	deregistersMu sync.Mutex
+	deregisters   []func(ctx context.Context) error
+
+	// crash handling of component panic, see WithCrashHook
+	crashHooks       []CrashHook
+	crashHookTimeout time.Duration
+	crashLogs        func() []string
+	crashFlush       []func() error
+	crashOnce        sync.Once
+	exit             func(code int)
+
+	// ordered startup of components, see WithDependsOn
+	dependsOn       map[string][]string
+	startupTimeouts map[string]time.Duration
+	startupErr      error
+	servingMu       sync.Mutex
+	serving         []string
+}
+
+// OptionFunc setter of server options
+type OptionFunc func(*server)
+
+// WithRegistrar register every application exposing factory.Addresser on service discovery once listening,
+// and deregister them before shutdown starts draining. Registration failure stops the application when fatal,
+// otherwise it is only logged
+func WithRegistrar(registrar factory.Registrar, fatal bool) OptionFunc {
+	return func(s *server) {
+		s.registrar = registrar
+		s.registerFatal = fatal
+	}
 }
 
 // Server is abstraction of application Server
 type Server interface {
 	// Run all server actives
 	Run()
+	// Validate wiring of all applications without serving them
+	Validate(ctx context.Context) error
 }
 
 // New initiate server to running the application
-func New(svc factory.ServiceFactory) Server {
-	return &server{service: svc}
+func New(svc factory.ServiceFactory, opts ...OptionFunc) Server {
+	s := &server{service: svc, registrar: factory.NoopRegistrar{}, crashLogs: logger.RecentLines, exit: os.Exit}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
 func (s *server) Run() {
@@ -33,32 +79,57 @@ func (s *server) Run() {
 		log.Fatal(fmt.Errorf("no server/worker/broker running"))
 	}
 
-	err := make(chan error, len(s.service.GetApplications()))
-	for _, app := range s.service.GetApplications() {
-		go func(srv factory.ApplicationFactory) {
-			defer func() {
-				if r := recover(); r != nil {
-					err <- fmt.Errorf("%s", r)
-				}
-			}()
+	// validate mode, exit with aggregated report instead of serving
+	if factory.IsValidateMode() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := s.Validate(ctx)
+		cancel()
 
-			// run the server
-			srv.Serve()
-		}(app)
+		if err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
 	}
 
+	// panics of component goroutines started with factory.GoSafe run the crash hooks
+	factory.SetCrashHandler(s.crash)
+
 	quitSignal := make(chan os.Signal, 1)
 	signal.Notify(quitSignal, os.Interrupt)
 	signal.Notify(quitSignal, syscall.SIGTERM)
 
-	log.Printf("Application %s ready to run\n", s.service.Name())
-
-	select {
-	case e := <-err:
-		panic(e)
-	case <-quitSignal:
+	// start components after their dependencies, a component not ready in time stops the application
+	quit, e := s.startOrQuit(s.service.GetApplications(), quitSignal)
+	if quit {
+		log.Printf("Application %s stopped while starting\n", s.service.Name())
+		s.shutdown(quitSignal)
+		return
+	}
+	if e != nil {
+		log.Printf("Application %s failed to start: %s\n", s.service.Name(), e)
 		s.shutdown(quitSignal)
+		s.exit(1)
+		return
 	}
+
+	registerCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	if e := s.register(registerCtx); e != nil {
+		if s.registerFatal {
+			cancel()
+			log.Printf("Service discovery registration failed: %s\n", e)
+			s.shutdown(quitSignal)
+			s.exit(1)
+			return
+		}
+
+		log.Printf("Service discovery registration failed: %s\n", e)
+	}
+	cancel()
+
+	log.Printf("Application %s ready to run\n", s.service.Name())
+
+	<-quitSignal
+	s.shutdown(quitSignal)
 }
 
 func (s *server) shutdown(forceShutdown chan os.Signal) {
@@ -71,8 +142,13 @@ func (s *server) shutdown(forceShutdown chan os.Signal) {
 	go func() {
 		defer close(done)
 
-		for _, srv := range s.service.GetApplications() {
-			srv.Shutdown(ctx)
+		// leave service discovery before draining
+		s.deregister(ctx)
+
+		// dependents stop before their dependencies
+		apps := s.service.GetApplications()
+		for _, name := range s.shutdownOrder() {
+			apps[name].Shutdown(ctx)
 		}
 
 		done <- struct{}{}
@@ -87,3 +163,123 @@ func (s *server) shutdown(forceShutdown chan os.Signal) {
 		log.Println("Context Timeout")
 	}
 }
+
+// Validate call Validate of every application implementing factory.Validator and log the report,
+// returns aggregated error of all invalid applications
+func (s *server) Validate(ctx context.Context) error {
+	var errs []error
+	if _, err := s.startupOrder(s.service.GetApplications()); err != nil {
+		log.Printf("[VALIDATE] %-12s failed: %s\n", "startup", err)
+		errs = append(errs, err)
+	}
+
+	for name, app := range s.service.GetApplications() {
+		v, ok := app.(factory.Validator)
+		if !ok {
+			log.Printf("[VALIDATE] %-12s ok (no validator)\n", name)
+			continue
+		}
+
+		if err := v.Validate(ctx); err != nil {
+			log.Printf("[VALIDATE] %-12s failed: %s\n", name, err)
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+
+		log.Printf("[VALIDATE] %-12s ok\n", name)
+	}
+
+	if len(errs) > 0 {
+		log.Printf("Application %s is invalid, %d of %d applications failed\n", s.service.Name(), len(errs), len(s.service.GetApplications()))
+		return errors.Join(errs...)
+	}
+
+	log.Printf("Application %s is valid\n", s.service.Name())
+	return nil
+}
+
+// register register every listening application on registrar, waiting until each factory.Addresser is bound
+func (s *server) register(ctx context.Context) error {
+	var errs []error
+	for name, app := range s.service.GetApplications() {
+		a, ok := app.(factory.Addresser)
+		if !ok {
+			continue
+		}
+
+		addrs, err := waitAddr(ctx, a)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+
+		for _, addr := range addrs {
+			deregister, err := s.registrar.Register(ctx, s.serviceInstance(name, addr))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				continue
+			}
+
+			s.deregistersMu.Lock()
+			s.deregisters = append(s.deregisters, deregister)
+			s.deregistersMu.Unlock()
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// deregister leave service discovery, failures are only logged
+func (s *server) deregister(ctx context.Context) {
+	s.deregistersMu.Lock()
+	deregisters := s.deregisters
+	s.deregisters = nil
+	s.deregistersMu.Unlock()
+
+	for _, deregister := range deregisters {
+		if err := deregister(ctx); err != nil {
+			log.Printf("Service discovery deregistration failed: %s\n", err)
+		}
+	}
+}
+
+// serviceInstance instance of application bound on addr, unspecified IP is replaced by
+// SERVICE_ADDRESS env or hostname
+func (s *server) serviceInstance(app string, addr net.Addr) factory.ServiceInstance {
+	host, portStr, _ := net.SplitHostPort(addr.String())
+	port, _ := net.LookupPort("tcp", portStr)
+
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		host = env.GetString("SERVICE_ADDRESS")
+		if host == "" {
+			host, _ = os.Hostname()
+		}
+	}
+
+	return factory.ServiceInstance{
+		ID:      fmt.Sprintf("%s-%s-%s-%d", s.service.Name(), app, host, port),
+		Name:    s.service.Name(),
+		Address: host,
+		Port:    port,
+		Tags:    []string{app},
+		Meta:    factory.GetServiceInfo().Labels(),
+	}
+}
+
+// waitAddr wait until application is listening
+func waitAddr(ctx context.Context, a factory.Addresser) ([]net.Addr, error) {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if addrs := a.Addr(); len(addrs) > 0 {
+			return addrs, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("listener not ready: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
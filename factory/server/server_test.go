@@ -0,0 +1,267 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/discovery"
+	"github.com/TixiaOTA/gokit/factory"
+	"github.com/TixiaOTA/gokit/utils/env/envtest"
+)
+
+type fakeApp struct {
+	name string
+	err  error
+}
+
+func (f *fakeApp) Name() string                     { return f.name }
+func (f *fakeApp) Serve()                           {}
+func (f *fakeApp) Shutdown(_ context.Context)       {}
+func (f *fakeApp) Validate(_ context.Context) error { return f.err }
+
+func TestValidateAggregate(t *testing.T) {
+	svc := &service{
+		name: "order-service",
+		applications: map[string]factory.ApplicationFactory{
+			"rest":      &fakeApp{name: "rest"},
+			"grpc":      &fakeApp{name: "grpc", err: errors.New("no service registered")},
+			"rabbit-mq": &fakeApp{name: "rabbit-mq", err: errors.New(`queue "order" has no exchange`)},
+		},
+	}
+
+	err := New(svc).Validate(context.Background())
+	if err == nil {
+		t.Fatal("expected aggregated validation error")
+	}
+
+	for _, want := range []string{"grpc: no service registered", "rabbit-mq: queue"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q on report, got %v", want, err)
+		}
+	}
+}
+
+type listeningApp struct {
+	fakeApp
+	addr     net.Addr
+	shutdown func()
+}
+
+func (l *listeningApp) Addr() []net.Addr           { return []net.Addr{l.addr} }
+func (l *listeningApp) Shutdown(_ context.Context) { l.shutdown() }
+
+func TestRegistrarLifecycle(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	record := func(call string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, call)
+	}
+
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/agent/service/register":
+			var body struct{ ID, Address string }
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			record("register " + body.ID)
+		case strings.HasPrefix(r.URL.Path, "/v1/agent/check/pass/"):
+			record("pass")
+		case strings.HasPrefix(r.URL.Path, "/v1/agent/service/deregister/"):
+			record("deregister")
+		}
+	}))
+	defer consul.Close()
+
+	envtest.OverrideForTest(t, "SERVICE_ADDRESS", "10.0.0.7")
+	svc := &service{
+		name: "order",
+		applications: map[string]factory.ApplicationFactory{
+			"grpc": &listeningApp{
+				fakeApp:  fakeApp{name: "grpc"},
+				addr:     &net.TCPAddr{IP: net.IPv6unspecified, Port: 6060},
+				shutdown: func() { record("shutdown") },
+			},
+		},
+	}
+
+	s := New(svc, WithRegistrar(discovery.NewConsul(discovery.SetAddress(consul.URL), discovery.SetTTL(30*time.Millisecond)), true)).(*server)
+	if err := s.register(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	s.shutdown(make(chan os.Signal))
+
+	mu.Lock()
+	defer mu.Unlock()
+	n := len(calls)
+	if n < 4 || calls[0] != "register order-grpc-10.0.0.7-6060" || calls[n-2] != "deregister" || calls[n-1] != "shutdown" {
+		t.Fatalf("expected register, heartbeats, deregister then shutdown, got %v", calls)
+	}
+}
+
+func TestCrashHook(t *testing.T) {
+	svc := &service{name: "order", applications: map[string]factory.ApplicationFactory{}}
+
+	reports := make(chan CrashReport, 1)
+	s := New(svc,
+		WithCrashHook(func(_ context.Context, report CrashReport) error {
+			reports <- report
+			return nil
+		}),
+		// a hook stuck past the timeout must not block the exit
+		WithCrashHook(func(ctx context.Context, _ CrashReport) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+		SetCrashHookTimeout(20*time.Millisecond),
+		WithCrashLogs(func() []string { return []string{"processing order 42"} }),
+	).(*server)
+
+	exited := make(chan int, 1)
+	s.exit = func(code int) { exited <- code }
+
+	s.goSafe("grpc", func() { panic("nil map") })
+
+	select {
+	case code := <-exited:
+		if code == 0 {
+			t.Error("expected non-zero exit code")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected exit after crash hooks")
+	}
+
+	report := <-reports
+	if report.Service != "order" || report.Component != "grpc" || report.Panic != "nil map" {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if !strings.Contains(report.Stack, "TestCrashHook") {
+		t.Errorf("expected stack of panicking goroutine, got %s", report.Stack)
+	}
+	if len(report.RecentLogs) != 1 || report.RecentLogs[0] != "processing order 42" {
+		t.Errorf("expected recent logs, got %v", report.RecentLogs)
+	}
+}
+
+func TestGoSafeCrashDeregistersAndFlushes(t *testing.T) {
+	svc := &service{name: "order", applications: map[string]factory.ApplicationFactory{}}
+
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	record := func(call string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, call)
+	}
+
+	s := New(svc,
+		WithCrashHook(func(context.Context, CrashReport) error { record("hook"); return nil }),
+		WithCrashFlush(func() error { record("flush"); return nil }),
+	).(*server)
+	s.deregisters = []func(context.Context) error{func(context.Context) error { record("deregister"); return nil }}
+
+	exited := make(chan int, 1)
+	s.exit = func(code int) { exited <- code }
+
+	factory.SetCrashHandler(s.crash)
+	defer factory.SetCrashHandler(nil)
+	factory.GoSafe("cron", func() { panic("nil pointer") })
+
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Fatal("expected exit after crash of a component goroutine")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(calls, ",") != "deregister,hook,flush" {
+		t.Errorf("expected deregister, hooks then flush, got %v", calls)
+	}
+}
+
+// failingRegistrar registrar rejecting every instance
+type failingRegistrar struct{}
+
+func (failingRegistrar) Register(context.Context, factory.ServiceInstance) (func(context.Context) error, error) {
+	return nil, errors.New("consul unavailable")
+}
+
+func TestRegisterFatalShutsDown(t *testing.T) {
+	shutdown := make(chan struct{})
+	svc := &service{
+		name: "order",
+		applications: map[string]factory.ApplicationFactory{
+			"grpc": &listeningApp{
+				fakeApp:  fakeApp{name: "grpc"},
+				addr:     &net.TCPAddr{IP: net.IPv4(10, 0, 0, 7), Port: 6060},
+				shutdown: func() { close(shutdown) },
+			},
+		},
+	}
+
+	s := New(svc, WithRegistrar(failingRegistrar{}, true)).(*server)
+	defer factory.SetCrashHandler(nil)
+	exited := make(chan int, 1)
+	s.exit = func(code int) { exited <- code }
+
+	s.Run()
+
+	select {
+	case <-shutdown:
+	default:
+		t.Error("expected started components shut down")
+	}
+	select {
+	case code := <-exited:
+		if code != 1 {
+			t.Errorf("expected exit code 1, got %d", code)
+		}
+	default:
+		t.Error("expected exit on fatal registration failure")
+	}
+}
+
+func TestConcurrentCrashRunsOnce(t *testing.T) {
+	svc := &service{name: "order", applications: map[string]factory.ApplicationFactory{}}
+
+	var hooks, deregisters atomic.Int32
+	s := New(svc, WithCrashHook(func(context.Context, CrashReport) error {
+		hooks.Add(1)
+		return nil
+	})).(*server)
+	s.deregisters = []func(context.Context) error{func(context.Context) error { deregisters.Add(1); return nil }}
+
+	var exits atomic.Int32
+	s.exit = func(int) { exits.Add(1) }
+
+	var wg sync.WaitGroup
+	for _, component := range []string{"grpc", "cron"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.crash(component, "nil map", nil)
+		}()
+	}
+	wg.Wait()
+
+	if hooks.Load() != 1 || deregisters.Load() != 1 || exits.Load() != 1 {
+		t.Errorf("expected one crash, got %d hook, %d deregister and %d exit", hooks.Load(), deregisters.Load(), exits.Load())
+	}
+}
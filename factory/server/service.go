@@ -2,11 +2,14 @@ package server
 
 import (
 	"github.com/TixiaOTA/gokit/abstract"
+	"github.com/TixiaOTA/gokit/broker/sqs"
 	"github.com/TixiaOTA/gokit/factory"
 	"github.com/TixiaOTA/gokit/factory/server/grpc"
+	"github.com/TixiaOTA/gokit/factory/server/http"
 	"github.com/TixiaOTA/gokit/factory/server/rabbitmq"
 	"github.com/TixiaOTA/gokit/factory/server/rest"
 	"github.com/TixiaOTA/gokit/types"
+	"github.com/TixiaOTA/gokit/utils/monitoring"
 )
 
 // ServiceFunc setter to set service instance
@@ -20,9 +23,12 @@ type service struct {
 	brokers              map[types.Broker]abstract.Broker
 	rest                 abstract.RestHandler
 	restOptions          []rest.OptionFunc
+	http                 abstract.HTTPHandler
+	httpOptions          []http.OptionFunc
 	grpc                 abstract.GRPCHandler
 	grpcOptions          []grpc.OptionFunc
 	applications         map[string]factory.ApplicationFactory
+	info                 *factory.ServiceInfo
 }
 
 // SetServiceName setter
@@ -32,6 +38,15 @@ func SetServiceName(svcName string) ServiceFunc {
 	}
 }
 
+// SetServiceInfo setter metadata of service, also set service name,
+// see factory.ServiceInfo for every consumer of the metadata
+func SetServiceInfo(info factory.ServiceInfo) ServiceFunc {
+	return func(s *service) {
+		s.info = &info
+		s.name = info.Name
+	}
+}
+
 // SetBrokerHandler setter brokerHandler
 func SetBrokerHandler(broker types.Broker, handler abstract.BrokerHandler) ServiceFunc {
 	return func(s *service) {
@@ -54,14 +69,15 @@ func SetBrokerHandlerOptions(broker types.Broker, opts ...interface{}) ServiceFu
 	}
 }
 
-// SetBroker setter broker
+// SetBroker setter broker, publisher of user provided broker validates payloads like the brokers of gokit,
+// see types.PublisherArgument.Validate
 func SetBroker(brokerName types.Broker, broker abstract.Broker) ServiceFunc {
 	return func(s *service) {
 		if len(s.brokers) < 1 || s.brokers == nil {
 			s.brokers = make(map[types.Broker]abstract.Broker)
 		}
 
-		s.brokers[brokerName] = broker
+		s.brokers[brokerName] = withPayloadValidation(broker)
 	}
 }
 
@@ -79,6 +95,20 @@ func SetRestHandlerOptions(opts ...rest.OptionFunc) ServiceFunc {
 	}
 }
 
+// SetHTTPHandler setter net/http handler
+func SetHTTPHandler(httpHandler abstract.HTTPHandler) ServiceFunc {
+	return func(s *service) {
+		s.http = httpHandler
+	}
+}
+
+// SetHTTPHandlerOptions setter options for net/http handler
+func SetHTTPHandlerOptions(opts ...http.OptionFunc) ServiceFunc {
+	return func(s *service) {
+		s.httpOptions = opts
+	}
+}
+
 // SetGrpcHandler setter
 func SetGrpcHandler(grpcHandler abstract.GRPCHandler) ServiceFunc {
 	return func(s *service) {
@@ -100,6 +130,12 @@ func NewService(serviceFuncs ...ServiceFunc) factory.ServiceFactory {
 		service(svc)
 	}
 
+	// register metadata before applications are created
+	if svc.info != nil {
+		factory.SetServiceInfo(*svc.info)
+		monitoring.NewPrometheusWithLabels(svc.info.Labels())
+	}
+
 	return svc
 }
 
@@ -124,6 +160,13 @@ func (s *service) GetApplications() map[string]factory.ApplicationFactory {
 		s.applications[types.REST.String()] = rest.New(s, s.restOptions...)
 	}
 
+	// set net/http handler into application factory
+	if s.http != nil {
+		if _, ok := s.applications[types.HTTP.String()]; !ok {
+			s.applications[types.HTTP.String()] = http.New(s, s.httpOptions...)
+		}
+	}
+
 	// set grpc handler into application factory
 	if s.grpc != nil {
 		if _, ok := s.applications[types.GRPC.String()]; !ok {
@@ -146,6 +189,22 @@ func (s *service) GetApplications() map[string]factory.ApplicationFactory {
 		}
 	}
 
+	// set sqs handler into applications factory
+	if s.brokerHandler[types.SQS] != nil {
+		if _, ok := s.applications[types.SQS.String()]; !ok {
+			var sqsOpts = make([]sqs.WorkerOptionFunc, 0)
+			if in, ok := s.brokerHandlerOptions[types.SQS].([]interface{}); ok {
+				for _, opt := range in {
+					if val, ok := opt.(sqs.WorkerOptionFunc); ok {
+						sqsOpts = append(sqsOpts, val)
+					}
+				}
+			}
+
+			s.applications[types.SQS.String()] = sqs.NewWorker(s, sqsOpts...)
+		}
+	}
+
 	// return all applications factory
 	return s.applications
 }
@@ -154,6 +213,10 @@ func (s *service) RESTHandler() abstract.RestHandler {
 	return s.rest
 }
 
+func (s *service) HTTPHandler() abstract.HTTPHandler {
+	return s.http
+}
+
 func (s *service) GRPCHandler() abstract.GRPCHandler {
 	return s.grpc
 }
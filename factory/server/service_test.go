@@ -0,0 +1,277 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/factory"
+	"github.com/TixiaOTA/gokit/factory/server/grpc"
+	gohttp "github.com/TixiaOTA/gokit/factory/server/http"
+	"github.com/TixiaOTA/gokit/factory/server/rest"
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/TixiaOTA/gokit/types"
+	"github.com/TixiaOTA/gokit/utils/monitoring"
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+type fakeGRPCHandler struct{}
+
+func (fakeGRPCHandler) Register(*ggrpc.Server) {}
+
+type fakeHTTPHandler struct{}
+
+func (fakeHTTPHandler) Mount(mux *http.ServeMux) {
+	mux.HandleFunc("/hello", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestServiceInfoConsumers(t *testing.T) {
+	info := factory.ServiceInfo{Name: "order-service", Team: "checkout", Tier: "critical", Environment: "staging"}
+	restPort, grpcPort := freePort(t), freePort(t)
+
+	svc := NewService(
+		SetServiceInfo(info),
+		SetRestHandlerOptions(rest.SetHTTPHost("127.0.0.1"), rest.SetHTTPPort(restPort)),
+		SetGrpcHandler(fakeGRPCHandler{}),
+		SetGrpcHandlerOptions(grpc.SetTCPHost("127.0.0.1"), grpc.SetTCPPort(grpcPort)),
+	)
+
+	if svc.Name() != info.Name || factory.GetServiceInfo() != info {
+		t.Fatalf("expected service info registered, got %q %+v", svc.Name(), factory.GetServiceInfo())
+	}
+
+	for _, app := range svc.GetApplications() {
+		go app.Serve()
+		defer app.Shutdown(context.Background())
+	}
+
+	// rest /version
+	var version factory.ServiceInfo
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/version", restPort))
+		if err == nil {
+			_ = json.NewDecoder(resp.Body).Decode(&version)
+			resp.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("rest server not ready: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if version != info {
+		t.Errorf("expected /version %+v, got %+v", info, version)
+	}
+
+	// grpc response header
+	conn, err := ggrpc.NewClient(fmt.Sprintf("127.0.0.1:%d", grpcPort), ggrpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var header metadata.MD
+	if _, err = grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{}, ggrpc.Header(&header)); err != nil {
+		t.Fatal(err)
+	}
+	if got := header.Get("x-service-team"); len(got) != 1 || got[0] != "checkout" {
+		t.Errorf("expected x-service-team header, got %v", header)
+	}
+
+	// data logger service
+	if _, dl := logger.InitializeCron("/cron"); dl.Service != info.Name {
+		t.Errorf("expected data logger service %q, got %q", info.Name, dl.Service)
+	}
+
+	// prometheus const labels
+	monitoring.PrometheusRecord(http.StatusOK, http.MethodGet, "/orders", types.REST.String(), time.Millisecond)
+	families, _ := prometheus.DefaultGatherer.Gather()
+	var labels map[string]string
+	for _, mf := range families {
+		if mf.GetName() == "request_total" {
+			labels = make(map[string]string)
+			for _, lp := range mf.GetMetric()[0].GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+		}
+	}
+	if labels["team"] != "checkout" || labels["tier"] != "critical" || labels["environment"] != "staging" {
+		t.Errorf("expected service info const labels, got %v", labels)
+	}
+}
+
+func TestHTTPAlongsideRest(t *testing.T) {
+	restPort, httpPort := freePort(t), freePort(t)
+	svc := NewService(
+		SetServiceName("http-service"),
+		SetRestHandlerOptions(rest.SetHTTPHost("127.0.0.1"), rest.SetHTTPPort(restPort)),
+		SetHTTPHandler(fakeHTTPHandler{}),
+		SetHTTPHandlerOptions(gohttp.SetHTTPHost("127.0.0.1"), gohttp.SetHTTPPort(httpPort)),
+	)
+
+	apps := svc.GetApplications()
+	order := []string{types.HTTP.String(), types.REST.String()}
+	urls := map[string]string{
+		types.HTTP.String(): fmt.Sprintf("http://127.0.0.1:%d/hello", httpPort),
+		types.REST.String(): fmt.Sprintf("http://127.0.0.1:%d/version", restPort),
+	}
+
+	served := make(map[string]chan struct{})
+	for _, name := range order {
+		app, ok := apps[name]
+		if !ok {
+			t.Fatalf("expected %s application, got %v", name, apps)
+		}
+
+		served[name] = make(chan struct{})
+		go func() {
+			app.Serve()
+			close(served[name])
+		}()
+	}
+
+	for _, name := range order {
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			resp, err := http.Get(urls[name])
+			if err == nil {
+				resp.Body.Close()
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s server not ready: %v", name, err)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	// the net/http server stops first, the fiber server keeps serving until its own shutdown
+	for i, name := range order {
+		apps[name].Shutdown(context.Background())
+		select {
+		case <-served[name]:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected %s Serve returns after Shutdown", name)
+		}
+
+		for _, next := range order[i+1:] {
+			resp, err := http.Get(urls[next])
+			if err != nil {
+				t.Fatalf("expected %s still serving after %s shut down: %v", next, name, err)
+			}
+			resp.Body.Close()
+		}
+	}
+}
+
+// healthProxyHandler register GET /check calling grpc health Check over conn
+type healthProxyHandler struct{ conn *ggrpc.ClientConn }
+
+func (h healthProxyHandler) Router(r fiber.Router) {
+	r.Get("/check", func(c *fiber.Ctx) error {
+		if _, err := grpc_health_v1.NewHealthClient(h.conn).Check(c.UserContext(), &grpc_health_v1.HealthCheckRequest{}); err != nil {
+			return err
+		}
+		return c.SendStatus(http.StatusNoContent)
+	})
+}
+
+func TestOpenTelemetryRestToGrpc(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	propagators := propagation.TraceContext{}
+	restPort, grpcPort := freePort(t), freePort(t)
+
+	conn, err := ggrpc.NewClient(fmt.Sprintf("127.0.0.1:%d", grpcPort),
+		ggrpc.WithTransportCredentials(insecure.NewCredentials()),
+		ggrpc.WithStatsHandler(otelgrpc.NewClientHandler(otelgrpc.WithTracerProvider(tp), otelgrpc.WithPropagators(propagators))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	svc := NewService(
+		SetServiceName("otel-service"),
+		SetRestHandler(healthProxyHandler{conn: conn}),
+		SetRestHandlerOptions(rest.SetHTTPHost("127.0.0.1"), rest.SetHTTPPort(restPort), rest.WithOpenTelemetry(tp, propagators)),
+		SetGrpcHandler(fakeGRPCHandler{}),
+		SetGrpcHandlerOptions(grpc.SetTCPHost("127.0.0.1"), grpc.SetTCPPort(grpcPort), grpc.WithOpenTelemetry(tp, propagators)),
+	)
+	for _, app := range svc.GetApplications() {
+		go app.Serve()
+		defer app.Shutdown(context.Background())
+	}
+
+	const traceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d/check", restPort), nil)
+		req.Header.Set("traceparent", "00-"+traceId+"-00f067aa0ba902b7-01")
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusNoContent {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("rest to grpc call not ready: %v", err)
+		}
+		exp.Reset()
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// the grpc server ends its span after the client got the response
+	span := func(kind trace.SpanKind, name string) tracetest.SpanStub {
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			for _, s := range exp.GetSpans() {
+				if s.SpanKind == kind && s.Name == name {
+					return s
+				}
+			}
+		}
+		t.Fatalf("expected %s span %q, got %v", kind, name, exp.GetSpans())
+		return tracetest.SpanStub{}
+	}
+	server := span(trace.SpanKindServer, "GET /check")
+	client := span(trace.SpanKindClient, "grpc.health.v1.Health/Check")
+	rpc := span(trace.SpanKindServer, "grpc.health.v1.Health/Check")
+
+	if server.SpanContext.TraceID().String() != traceId {
+		t.Errorf("expected rest span joined the incoming trace, got %+v", server)
+	}
+	if client.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Errorf("expected grpc client span child of the rest span, got parent %v", client.Parent)
+	}
+	if rpc.Parent.SpanID() != client.SpanContext.SpanID() || rpc.SpanContext.TraceID().String() != traceId {
+		t.Errorf("expected grpc server span child of the grpc client span, got %+v", rpc)
+	}
+}
@@ -0,0 +1,295 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TixiaOTA/gokit/factory"
+)
+
+// defaultStartupTimeout time given to a component of WithDependsOn to be ready once its dependencies are ready
+const defaultStartupTimeout = 30 * time.Second
+
+// ErrStartupTimeout component not ready within its startup timeout, see SetStartupTimeout
+var ErrStartupTimeout = errors.New("startup timeout")
+
+// WithDependsOn start component only once all of deps are ready, e.g. WithDependsOn("rabbit-mq", "grpc")
+// so the worker does not consume before the grpc server it calls is serving. components are named by their
+// key on factory.ServiceFactory GetApplications and are shut down in reverse order.
+// a dependency cycle is reported by Run and Validate
+func WithDependsOn(component string, deps ...string) OptionFunc {
+	return func(s *server) {
+		if s.dependsOn == nil {
+			s.dependsOn = make(map[string][]string)
+		}
+		s.dependsOn[component] = append(s.dependsOn[component], deps...)
+
+		if cycle := findCycle(s.dependsOn); cycle != nil && s.startupErr == nil {
+			s.startupErr = fmt.Errorf("startup dependency cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+}
+
+// SetStartupTimeout time given to component to be ready once its dependencies are ready, default 30s for
+// components of WithDependsOn, other components are awaited without timeout
+func SetStartupTimeout(component string, timeout time.Duration) OptionFunc {
+	return func(s *server) {
+		if s.startupTimeouts == nil {
+			s.startupTimeouts = make(map[string]time.Duration)
+		}
+		s.startupTimeouts[component] = timeout
+	}
+}
+
+// findCycle components of the first dependency cycle of graph, closed by its first component, nil without cycle
+func findCycle(graph map[string][]string) []string {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+
+	var (
+		state = make(map[string]int)
+		path  []string
+		visit func(name string) []string
+	)
+	visit = func(name string) []string {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			for i, p := range path {
+				if p == name {
+					return append(append([]string{}, path[i:]...), name)
+				}
+			}
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range graph[name] {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+
+		return nil
+	}
+
+	for _, name := range sortedKeys(graph) {
+		if cycle := visit(name); cycle != nil {
+			return cycle
+		}
+	}
+
+	return nil
+}
+
+// startupOrder names of apps with every component after its dependencies, ties sorted by name
+func (s *server) startupOrder(apps map[string]factory.ApplicationFactory) ([]string, error) {
+	if s.startupErr != nil {
+		return nil, s.startupErr
+	}
+
+	for _, name := range sortedKeys(s.dependsOn) {
+		if _, ok := apps[name]; !ok {
+			continue
+		}
+		for _, dep := range s.dependsOn[name] {
+			if _, ok := apps[dep]; !ok {
+				return nil, fmt.Errorf("component %s depends on unknown component %s", name, dep)
+			}
+		}
+	}
+
+	var (
+		order []string
+		added = make(map[string]bool, len(apps))
+		add   func(name string)
+	)
+	add = func(name string) {
+		if added[name] {
+			return
+		}
+		added[name] = true
+		for _, dep := range s.dependsOn[name] {
+			add(dep)
+		}
+		order = append(order, name)
+	}
+
+	for _, name := range sortedKeys(apps) {
+		add(name)
+	}
+
+	return order, nil
+}
+
+// start serve every application once its dependencies are ready, independent components start concurrently.
+// returns the first component not ready within its startup timeout, components already serving are kept
+// on s.serving to be shut down
+func (s *server) start(ctx context.Context, apps map[string]factory.ApplicationFactory) error {
+	order, err := s.startupOrder(apps)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ready := make(map[string]chan struct{}, len(order))
+	for _, name := range order {
+		ready[name] = make(chan struct{})
+	}
+
+	errs := make(chan error, len(order))
+	for _, name := range order {
+		go func(name string) {
+			for _, dep := range s.dependsOn[name] {
+				select {
+				case <-ready[dep]:
+				case <-ctx.Done():
+					errs <- ctx.Err()
+					return
+				}
+			}
+
+			s.serve(name, apps[name])
+			if err := s.waitReady(ctx, name, apps[name]); err != nil {
+				errs <- err
+				return
+			}
+
+			log.Printf("Component %s ready\n", name)
+			close(ready[name])
+			errs <- nil
+		}(name)
+	}
+
+	for range order {
+		if err := <-errs; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// serve run Serve of app in background and keep it for shutdown
+func (s *server) serve(name string, app factory.ApplicationFactory) {
+	s.servingMu.Lock()
+	s.serving = append(s.serving, name)
+	s.servingMu.Unlock()
+
+	// a panic of any application runs crash hooks then exits the process
+	s.goSafe(name, app.Serve)
+}
+
+// startupTimeout startup timeout of component, false when neither configured nor part of WithDependsOn
+func (s *server) startupTimeout(name string) (time.Duration, bool) {
+	if t, ok := s.startupTimeouts[name]; ok && t > 0 {
+		return t, true
+	}
+
+	if len(s.dependsOn[name]) > 0 {
+		return defaultStartupTimeout, true
+	}
+	for _, deps := range s.dependsOn {
+		for _, dep := range deps {
+			if dep == name {
+				return defaultStartupTimeout, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// startOrQuit start applications until the quit signal, quit reports whether the signal was received
+// while starting
+func (s *server) startOrQuit(apps map[string]factory.ApplicationFactory, quitSignal chan os.Signal) (quit bool, err error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done, exited := make(chan struct{}), make(chan bool)
+	go func() {
+		select {
+		case <-quitSignal:
+			cancel()
+			exited <- true
+		case <-done:
+			exited <- false
+		}
+	}()
+
+	err = s.start(ctx, apps)
+	close(done)
+
+	return <-exited, err
+}
+
+// waitReady wait until app is ready, see factory.Readier, within the startup timeout of component
+func (s *server) waitReady(ctx context.Context, name string, app factory.ApplicationFactory) error {
+	timeout, ok := s.startupTimeout(name)
+	if ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var err error
+	switch a := app.(type) {
+	case factory.Readier:
+		err = a.Ready(ctx)
+	case factory.Addresser:
+		_, err = waitAddr(ctx, a)
+	}
+
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("component %s not ready within %s: %w", name, timeout, ErrStartupTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("component %s not ready: %w", name, err)
+	}
+
+	return nil
+}
+
+// shutdownOrder components to shut down, the reverse of their start. all applications in reverse
+// startup order when none was started by Run
+func (s *server) shutdownOrder() []string {
+	s.servingMu.Lock()
+	order := append([]string{}, s.serving...)
+	s.servingMu.Unlock()
+
+	if len(order) < 1 {
+		var err error
+		if order, err = s.startupOrder(s.service.GetApplications()); err != nil {
+			order = sortedKeys(s.service.GetApplications())
+		}
+	}
+
+	for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+
+	return order
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/factory"
+)
+
+type startupApp struct {
+	fakeApp
+	delay  time.Duration
+	record func(call string)
+}
+
+func (a *startupApp) Shutdown(_ context.Context) { a.record("shutdown " + a.name) }
+
+func (a *startupApp) Ready(ctx context.Context) error {
+	select {
+	case <-time.After(a.delay):
+		a.record("ready " + a.name)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func startupService(record func(string), grpcDelay time.Duration) *service {
+	return &service{
+		name: "order",
+		applications: map[string]factory.ApplicationFactory{
+			"grpc":      &startupApp{fakeApp: fakeApp{name: "grpc"}, delay: grpcDelay, record: record},
+			"rabbit-mq": &startupApp{fakeApp: fakeApp{name: "rabbit-mq"}, record: record},
+			"rest":      &startupApp{fakeApp: fakeApp{name: "rest"}, record: record},
+		},
+	}
+}
+
+func TestStartupOrder(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	record := func(call string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, call)
+	}
+	index := func(call string) int {
+		for i, c := range calls {
+			if c == call {
+				return i
+			}
+		}
+		return -1
+	}
+
+	s := New(startupService(record, 50*time.Millisecond),
+		WithDependsOn("rabbit-mq", "grpc"),
+		WithDependsOn("rest", "rabbit-mq"),
+	).(*server)
+
+	if err := s.start(context.Background(), s.service.GetApplications()); err != nil {
+		t.Fatal(err)
+	}
+	s.shutdown(make(chan os.Signal))
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"ready grpc", "ready rabbit-mq", "ready rest", "shutdown rest", "shutdown rabbit-mq", "shutdown grpc"}
+	for i := 1; i < len(want); i++ {
+		if index(want[i-1]) < 0 || index(want[i-1]) > index(want[i]) {
+			t.Fatalf("expected %v, got %v", want, calls)
+		}
+	}
+}
+
+func TestStartupTimeout(t *testing.T) {
+	s := New(startupService(func(string) {}, time.Second),
+		WithDependsOn("rabbit-mq", "grpc"),
+		SetStartupTimeout("grpc", 20*time.Millisecond),
+	).(*server)
+
+	err := s.start(context.Background(), s.service.GetApplications())
+	if !errors.Is(err, ErrStartupTimeout) || !strings.Contains(err.Error(), "component grpc") {
+		t.Fatalf("expected startup timeout of grpc, got %v", err)
+	}
+
+	served := s.shutdownOrder()
+	for _, name := range served {
+		if name == "rabbit-mq" {
+			t.Errorf("expected dependent of grpc not served, got %v", served)
+		}
+	}
+}
+
+func TestStartupTimeoutScope(t *testing.T) {
+	s := New(startupService(func(string) {}, 0),
+		WithDependsOn("rabbit-mq", "grpc"),
+		SetStartupTimeout("rest", time.Minute),
+	).(*server)
+
+	for name, want := range map[string]time.Duration{"grpc": defaultStartupTimeout, "rabbit-mq": defaultStartupTimeout, "rest": time.Minute} {
+		if got, ok := s.startupTimeout(name); !ok || got != want {
+			t.Errorf("%s: expected startup timeout %s, got %s %v", name, want, got, ok)
+		}
+	}
+
+	// a component outside of WithDependsOn binding late is awaited without timeout
+	s = New(startupService(func(string) {}, 0)).(*server)
+	if _, ok := s.startupTimeout("grpc"); ok {
+		t.Errorf("expected no startup timeout without dependencies")
+	}
+}
+
+func TestStartupQuit(t *testing.T) {
+	s := New(startupService(func(string) {}, time.Minute)).(*server)
+
+	quitSignal := make(chan os.Signal, 1)
+	time.AfterFunc(20*time.Millisecond, func() { quitSignal <- os.Interrupt })
+
+	start := time.Now()
+	quit, _ := s.startOrQuit(s.service.GetApplications(), quitSignal)
+	if !quit || time.Since(start) > time.Second {
+		t.Errorf("expected startup stopped by quit signal, got quit=%v after %s", quit, time.Since(start))
+	}
+}
+
+func TestStartupCycle(t *testing.T) {
+	s := New(startupService(func(string) {}, 0),
+		WithDependsOn("rabbit-mq", "grpc"),
+		WithDependsOn("grpc", "rest"),
+		WithDependsOn("rest", "rabbit-mq"),
+	)
+
+	err := s.Validate(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "startup dependency cycle: grpc -> rest -> rabbit-mq -> grpc") {
+		t.Fatalf("expected dependency cycle, got %v", err)
+	}
+
+	s = New(startupService(func(string) {}, 0), WithDependsOn("rabbit-mq", "sqs"))
+	if err = s.Validate(context.Background()); err == nil || !strings.Contains(err.Error(), "unknown component sqs") {
+		t.Fatalf("expected unknown dependency, got %v", err)
+	}
+}
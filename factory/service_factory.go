@@ -25,3 +25,11 @@ type ServiceFactory interface {
 	// GetBroker return abstraction of broker configuration by types.Broker
 	GetBroker(broker types.Broker) abstract.Broker
 }
+
+// HTTPServiceFactory optional interface of ServiceFactory serving a net/http handler, see factory/server/http
+type HTTPServiceFactory interface {
+	ServiceFactory
+
+	// HTTPHandler return abstraction of net/http handler
+	HTTPHandler() abstract.HTTPHandler
+}
@@ -0,0 +1,50 @@
+package factory
+
+import "sync"
+
+var (
+	serviceInfoMu sync.RWMutex
+	serviceInfo   ServiceInfo
+)
+
+// ServiceInfo metadata of running service, set once and consumed by logger, loki, metrics,
+// grpc and rest servers
+type ServiceInfo struct {
+	Name        string `json:"name"`
+	Team        string `json:"team,omitempty"`
+	Tier        string `json:"tier,omitempty"`
+	Environment string `json:"environment,omitempty"`
+}
+
+// Labels non-empty metadata keyed by service, team, tier and environment
+func (s ServiceInfo) Labels() map[string]string {
+	labels := make(map[string]string, 4)
+	for k, v := range map[string]string{
+		"service":     s.Name,
+		"team":        s.Team,
+		"tier":        s.Tier,
+		"environment": s.Environment,
+	} {
+		if v != "" {
+			labels[k] = v
+		}
+	}
+
+	return labels
+}
+
+// SetServiceInfo register metadata of running service, must be set before logger and servers are created
+func SetServiceInfo(info ServiceInfo) {
+	serviceInfoMu.Lock()
+	defer serviceInfoMu.Unlock()
+
+	serviceInfo = info
+}
+
+// GetServiceInfo metadata of running service, zero value when not yet registered
+func GetServiceInfo() ServiceInfo {
+	serviceInfoMu.RLock()
+	defer serviceInfoMu.RUnlock()
+
+	return serviceInfo
+}
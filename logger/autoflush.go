@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// autoFlushThreshold number of accumulated messages flushed before the interval, see SetAutoFlushThreshold
+var autoFlushThreshold atomic.Int64
+
+func init() {
+	autoFlushThreshold.Store(100)
+}
+
+// SetAutoFlushThreshold flush accumulated messages of StartAutoFlush as soon as they reach n, default 100
+func SetAutoFlushThreshold(n int) {
+	if n > 0 {
+		autoFlushThreshold.Store(int64(n))
+	}
+}
+
+// autoFlush wake up auto flush goroutine when accumulated messages reach threshold
+type autoFlush struct {
+	threshold int
+	full      chan struct{}
+}
+
+// notify signal auto flush without blocking the writer
+func (af *autoFlush) notify(value interface{}) {
+	if messages, ok := value.([]LogMessage); ok && len(messages) >= af.threshold {
+		select {
+		case af.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// StartAutoFlush flush and clear accumulated messages of long-running context into sink every interval
+// or when they reach the threshold, see SetAutoFlushThreshold. It stops with a final flush when ctx is canceled,
+// nothing is started when ctx has no logger
+func StartAutoFlush(ctx context.Context, interval time.Duration, sink func([]LogMessage)) {
+	lock, ok := ctx.Value(LogKey).(*Locker)
+	if !ok || interval <= 0 || sink == nil {
+		return
+	}
+
+	af := &autoFlush{threshold: int(autoFlushThreshold.Load()), full: make(chan struct{}, 1)}
+	lock.autoFlush.Store(af)
+
+	go func() {
+		defer lock.autoFlush.CompareAndSwap(af, nil)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				lock.flushMessages(sink)
+				return
+			case <-ticker.C:
+				lock.flushMessages(sink)
+			case <-af.full:
+				lock.flushMessages(sink)
+			}
+		}
+	}()
+}
+
+// flushMessages take accumulated messages out of locker into sink, flushing is concurrent with
+// the request goroutine by design so it bypasses diagnostics
+func (l *Locker) flushMessages(sink func([]LogMessage)) {
+	if v, ok := l.data.LoadAndDelete(_LogMessages); ok {
+		if messages, ok := v.([]LogMessage); ok && len(messages) > 0 {
+			sink(messages)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package logger
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStartAutoFlush(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		flushed [][]LogMessage
+	)
+	sink := func(messages []LogMessage) {
+		mu.Lock()
+		defer mu.Unlock()
+		flushed = append(flushed, messages)
+	}
+	batches := func() [][]LogMessage {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([][]LogMessage(nil), flushed...)
+	}
+	waitBatches := func(n int) {
+		deadline := time.Now().Add(2 * time.Second)
+		for len(batches()) < n && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if got := len(batches()); got < n {
+			t.Fatalf("expected %d flushes, got %d", n, got)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), LogKey, new(Locker)))
+	StartAutoFlush(ctx, 20*time.Millisecond, sink)
+
+	Log.Print(ctx, "first")
+	waitBatches(1)
+
+	Log.Print(ctx, "second")
+	waitBatches(2)
+	cancel()
+
+	// terminal flush on cancel, interval is long enough to never tick
+	ctx, cancel = context.WithCancel(context.WithValue(context.Background(), LogKey, new(Locker)))
+	StartAutoFlush(ctx, time.Hour, sink)
+	Log.Print(ctx, "last")
+	cancel()
+	waitBatches(3)
+
+	got := batches()
+	for i, want := range []string{"first", "second", "last"} {
+		if len(got[i]) != 1 || got[i][0].Message != want {
+			t.Errorf("flush %d: expected %s, got %v", i, want, got[i])
+		}
+	}
+}
+
+func TestStartAutoFlushThreshold(t *testing.T) {
+	SetAutoFlushThreshold(3)
+	defer SetAutoFlushThreshold(100)
+
+	flushed := make(chan []LogMessage, 1)
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), LogKey, new(Locker)))
+	defer cancel()
+	StartAutoFlush(ctx, time.Hour, func(messages []LogMessage) { flushed <- messages })
+
+	for i := 0; i < 3; i++ {
+		Log.Print(ctx, "message")
+	}
+
+	select {
+	case messages := <-flushed:
+		if len(messages) != 3 {
+			t.Errorf("expected 3 messages flushed on threshold, got %d", len(messages))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected flush when threshold hits")
+	}
+}
@@ -0,0 +1,228 @@
+package logger
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// BaggagePrefix prefix of baggage keys on gRPC metadata, HTTP and message headers, e.g. "baggage-tenant-id".
+// underscores of keys are sent as hyphens since proxies like nginx drop headers having underscores
+const BaggagePrefix = "baggage-"
+
+const (
+	_Baggage Flags = "Baggage"
+
+	// defaultBaggageLimit total bytes of baggage keys and values
+	defaultBaggageLimit = 1024
+)
+
+var (
+	baggageMu    sync.RWMutex
+	baggageKeys  = map[string]bool{"tenant_id": true, "user_id": true, "locale": true}
+	baggageWire  = wireKeys(baggageKeys)
+	baggageLimit = defaultBaggageLimit
+
+	// baggageFields include baggage on DataLogger and Logger.WithContext, see SetBaggageFields
+	baggageFields atomic.Bool
+)
+
+// SetBaggageKeys replace the allowlist of baggage keys, default tenant_id, user_id and locale.
+// keys outside the allowlist are never set nor propagated
+func SetBaggageKeys(keys ...string) {
+	allowed := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		allowed[strings.ToLower(k)] = true
+	}
+
+	baggageMu.Lock()
+	baggageKeys = allowed
+	baggageWire = wireKeys(allowed)
+	baggageMu.Unlock()
+}
+
+// wireKey header form of baggage key, e.g. "tenant_id" is "tenant-id"
+func wireKey(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+// wireKeys allowlisted keys by their header form
+func wireKeys(allowed map[string]bool) map[string]string {
+	wire := make(map[string]string, len(allowed))
+	for k := range allowed {
+		wire[wireKey(k)] = k
+	}
+
+	return wire
+}
+
+// SetBaggageLimit maximum total bytes of baggage keys and values, default 1024.
+// a pair exceeding the limit is dropped
+func SetBaggageLimit(limit int) {
+	baggageMu.Lock()
+	baggageLimit = limit
+	baggageMu.Unlock()
+}
+
+// SetBaggageFields include baggage as "baggage" field of DataLogger and Logger.WithContext
+func SetBaggageFields(enabled bool) {
+	baggageFields.Store(enabled)
+}
+
+// SetBaggage set baggage key and value on context flowing to gRPC calls and published messages,
+// dropped when key is not allowlisted or the size limit is reached, see SetBaggageKeys
+func SetBaggage(ctx context.Context, key, val string) {
+	value, ok := extract(ctx)
+	if ctx == nil || !ok {
+		return
+	}
+
+	setBaggage(value, map[string]string{key: val})
+}
+
+// GetBaggage copy of baggage on context, nil when empty
+func GetBaggage(ctx context.Context) map[string]string {
+	value, ok := extract(ctx)
+	if ctx == nil || !ok {
+		return nil
+	}
+
+	current := loadBaggage(value)
+	if len(current) < 1 {
+		return nil
+	}
+
+	bag := make(map[string]string, len(current))
+	for k, v := range current {
+		bag[k] = v
+	}
+
+	return bag
+}
+
+// BaggageHeaders baggage of context as prefixed hyphenated keys, e.g. for gRPC metadata or message headers
+func BaggageHeaders(ctx context.Context) map[string]string {
+	bag := GetBaggage(ctx)
+	if bag == nil {
+		return nil
+	}
+
+	headers := make(map[string]string, len(bag))
+	for k, v := range bag {
+		headers[BaggagePrefix+wireKey(k)] = v
+	}
+
+	return headers
+}
+
+// InjectBaggage copy of message headers with baggage of context as prefixed keys,
+// headers already set are kept. headers is returned as is without baggage
+func InjectBaggage(ctx context.Context, headers map[string]interface{}) map[string]interface{} {
+	bag := BaggageHeaders(ctx)
+	if bag == nil {
+		return headers
+	}
+
+	out := make(map[string]interface{}, len(headers)+len(bag))
+	for k, v := range bag {
+		out[k] = v
+	}
+	for k, v := range headers {
+		out[k] = v
+	}
+
+	return out
+}
+
+// RestoreBaggage set baggage of prefixed keys of headers on context, other keys are ignored.
+// hyphenated keys and underscored keys of older senders both restore the allowlisted key
+func RestoreBaggage(ctx context.Context, headers map[string]string) {
+	value, ok := extract(ctx)
+	if ctx == nil || !ok {
+		return
+	}
+
+	bag := make(map[string]string)
+	for k, v := range headers {
+		if len(k) > len(BaggagePrefix) && strings.EqualFold(k[:len(BaggagePrefix)], BaggagePrefix) {
+			bag[k[len(BaggagePrefix):]] = v
+		}
+	}
+
+	setBaggage(value, bag)
+}
+
+// setBaggage merge allowlisted pairs into baggage of value within the size limit.
+// baggage map is replaced, never mutated, so a copy loaded by another goroutine stays consistent
+func setBaggage(value Values, pairs map[string]string) {
+	if len(pairs) < 1 {
+		return
+	}
+
+	baggageMu.RLock()
+	defer baggageMu.RUnlock()
+
+	current := loadBaggage(value)
+	next := make(map[string]string, len(current)+len(pairs))
+	size := 0
+	for k, v := range current {
+		next[k] = v
+		size += len(k) + len(v)
+	}
+
+	for k, v := range pairs {
+		k, ok := allowedBaggageKey(k)
+		if !ok {
+			continue
+		}
+
+		grow := len(k) + len(v)
+		if old, ok := next[k]; ok {
+			grow -= len(k) + len(old)
+		}
+		if size+grow > baggageLimit {
+			continue
+		}
+
+		next[k] = v
+		size += grow
+	}
+
+	value.Set(_Baggage, next)
+}
+
+// allowedBaggageKey allowlisted key of k given as is or in its header form, baggageMu must be held
+func allowedBaggageKey(k string) (string, bool) {
+	k = strings.ToLower(k)
+	if baggageKeys[k] {
+		return k, true
+	}
+
+	key, ok := baggageWire[wireKey(k)]
+	return key, ok
+}
+
+func loadBaggage(value Values) map[string]string {
+	if i, ok := value.Load(_Baggage); ok && i != nil {
+		return i.(map[string]string)
+	}
+
+	return nil
+}
+
+// baggageField baggage of context as zap field when SetBaggageFields is enabled
+func baggageField(ctx context.Context) (zap.Field, bool) {
+	if !baggageFields.Load() {
+		return zap.Field{}, false
+	}
+
+	bag := GetBaggage(ctx)
+	if bag == nil {
+		return zap.Field{}, false
+	}
+
+	return zap.Any("baggage", bag), true
+}
@@ -0,0 +1,64 @@
+package logger
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestBaggage(t *testing.T) {
+	ctx := context.WithValue(context.Background(), LogKey, new(Locker))
+
+	SetBaggage(ctx, "tenant_id", "acme")
+	SetBaggage(ctx, "password", "secret")
+	SetBaggage(ctx, "user_id", strings.Repeat("x", defaultBaggageLimit))
+
+	bag := GetBaggage(ctx)
+	if len(bag) != 1 || bag["tenant_id"] != "acme" {
+		t.Fatalf("expected only allowlisted baggage within limit, got %v", bag)
+	}
+
+	if headers := BaggageHeaders(ctx); len(headers) != 1 || headers["baggage-tenant-id"] != "acme" {
+		t.Errorf("expected hyphenated header key, got %v", headers)
+	}
+
+	headers := InjectBaggage(ctx, map[string]interface{}{"baggage-tenant-id": "kept"})
+	if headers["baggage-tenant-id"] != "kept" {
+		t.Errorf("expected existing header kept, got %v", headers)
+	}
+
+	next := context.WithValue(context.Background(), LogKey, new(Locker))
+	RestoreBaggage(next, map[string]string{"Baggage-Locale": "id", "x-request-id": "1"})
+	RestoreBaggage(next, BaggageHeaders(ctx))
+	if bag = GetBaggage(next); len(bag) != 2 || bag["locale"] != "id" || bag["tenant_id"] != "acme" {
+		t.Errorf("expected baggage restored from headers, got %v", bag)
+	}
+
+	// underscored keys of older senders
+	legacy := context.WithValue(context.Background(), LogKey, new(Locker))
+	RestoreBaggage(legacy, map[string]string{"baggage-user_id": "42", "Baggage-Tenant-Id": "acme"})
+	if bag = GetBaggage(legacy); len(bag) != 2 || bag["user_id"] != "42" || bag["tenant_id"] != "acme" {
+		t.Errorf("expected underscored and hyphenated keys restored, got %v", bag)
+	}
+}
+
+func TestStreamClientBaggageInterceptor(t *testing.T) {
+	ctx := context.WithValue(context.Background(), LogKey, new(Locker))
+	SetBaggage(ctx, "tenant_id", "acme")
+
+	var md metadata.MD
+	streamer := func(ctx context.Context, _ *grpc.StreamDesc, _ *grpc.ClientConn, _ string, _ ...grpc.CallOption) (grpc.ClientStream, error) {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil, nil
+	}
+
+	if _, err := NewInterceptor("localhost").StreamClientBaggageInterceptor(ctx, &grpc.StreamDesc{}, nil, "/test.Service/Watch", streamer); err != nil {
+		t.Fatal(err)
+	}
+	if got := md.Get("baggage-tenant-id"); len(got) != 1 || got[0] != "acme" {
+		t.Errorf("expected baggage propagated on stream, got %v", md)
+	}
+}
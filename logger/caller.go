@@ -0,0 +1,115 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	rdebug "runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// fullCallerPath show full file path on caller
+	fullCallerPath atomic.Bool
+
+	mainModuleOnce sync.Once
+	mainModulePath string
+
+	// moduleRoots cache of directory to its module root directory
+	moduleRoots sync.Map
+)
+
+// SetFullCallerPath show full file path instead of module relative path on caller
+func SetFullCallerPath(full bool) {
+	fullCallerPath.Store(full)
+}
+
+// formatCaller format file and line of caller, e.g. "internal/handler/order.go:42"
+func formatCaller(file string, line int) string {
+	if !fullCallerPath.Load() {
+		file = trimCallerPath(file)
+	}
+
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
+// trimCallerPath trim file path relative to its module
+func trimCallerPath(file string) string {
+	// dependency on module cache, e.g. /go/pkg/mod/github.com/foo/bar@v1.2.3/baz.go
+	if i := strings.LastIndex(file, "/pkg/mod/"); i >= 0 {
+		return stripModuleVersion(file[i+len("/pkg/mod/"):])
+	}
+
+	// vendored dependency, e.g. /app/vendor/github.com/foo/bar/baz.go
+	if i := strings.LastIndex(file, "/vendor/"); i >= 0 {
+		return file[i+len("/vendor/"):]
+	}
+
+	// main module built with -trimpath, e.g. github.com/acme/svc/internal/handler/order.go
+	if mod := mainModule(); mod != "" && strings.HasPrefix(file, mod+"/") {
+		return strings.TrimPrefix(file, mod+"/")
+	}
+
+	if root := moduleRoot(filepath.Dir(file)); root != "" {
+		return strings.TrimPrefix(file, root+"/")
+	}
+
+	// fallback to the last three segments
+	segments := strings.Split(file, "/")
+	if len(segments) > 3 {
+		segments = segments[len(segments)-3:]
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// stripModuleVersion remove version suffix of module path, e.g. github.com/foo/bar@v1.2.3/baz.go
+func stripModuleVersion(path string) string {
+	at := strings.Index(path, "@")
+	if at < 0 {
+		return path
+	}
+
+	rest := path[at:]
+	slash := strings.Index(rest, "/")
+	if slash < 0 {
+		return path[:at]
+	}
+
+	return path[:at] + rest[slash:]
+}
+
+// mainModule main module path from build info
+func mainModule() string {
+	mainModuleOnce.Do(func() {
+		if bi, ok := rdebug.ReadBuildInfo(); ok {
+			mainModulePath = bi.Main.Path
+		}
+	})
+
+	return mainModulePath
+}
+
+// moduleRoot find the nearest parent directory containing go.mod, result is cached per directory
+func moduleRoot(dir string) string {
+	if root, ok := moduleRoots.Load(dir); ok {
+		return root.(string)
+	}
+
+	var root string
+	for d := dir; d != "/" && d != "." && d != ""; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			root = d
+			break
+		}
+
+		if parent := filepath.Dir(d); parent == d {
+			break
+		}
+	}
+
+	moduleRoots.Store(dir, root)
+	return root
+}
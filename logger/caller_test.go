@@ -0,0 +1,96 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestTrimCallerPath(t *testing.T) {
+	_, self, _, _ := runtime.Caller(0)
+
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{"main module", self, "logger/caller_test.go"},
+		{"trimpath main module", "github.com/TixiaOTA/gokit/logger/caller_test.go", "logger/caller_test.go"},
+		{"module cache", "/root/go/pkg/mod/github.com/acme/lib@v1.2.3/client/file.go", "github.com/acme/lib/client/file.go"},
+		{"vendored", "/srv/app/vendor/github.com/acme/lib/file.go", "github.com/acme/lib/file.go"},
+		{"generated pb", "/nonexistent/app/proto/order/order.pb.go", "proto/order/order.pb.go"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trimCallerPath(tt.file); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFormatCallerFullPath(t *testing.T) {
+	SetFullCallerPath(true)
+	defer SetFullCallerPath(false)
+
+	if got := formatCaller("/srv/app/main.go", 10); got != "/srv/app/main.go:10" {
+		t.Errorf("expected full path, got %s", got)
+	}
+}
+
+// warnHelper wrapper of Log reporting the call site of the helper
+func warnHelper(ctx context.Context, msg string) {
+	Log.AddCallerSkip(1).Warn(ctx, msg)
+}
+
+func TestMessageCaller(t *testing.T) {
+	ctx := context.WithValue(context.Background(), LogKey, new(Locker))
+
+	methods := []func(){
+		func() { Log.Error(ctx, "error") },
+		func() { Log.Errorf(ctx, "%s", "errorf") },
+		func() { Log.Warn(ctx, "warn") },
+		func() { Log.Warnf(ctx, "%s", "warnf") },
+		func() { Log.Debug(ctx, "debug") },
+		func() { Log.DebugF(ctx, "%s", "debugf") },
+		func() { Log.Print(ctx, "print") },
+		func() { Log.Printf(ctx, "%s", "printf") },
+		func() { Log.ErrorT(ctx, []string{"t"}, "errort") },
+		func() { Log.PrintT(ctx, []string{"t"}, "printt") },
+		func() { Log.ErrorKV(ctx, "errorkv", "k", 1) },
+		func() { Log.WarnKV(ctx, "warnkv", "k", 1) },
+		func() { Log.DebugKV(ctx, "debugkv", "k", 1) },
+		func() { Log.PrintKV(ctx, "printkv", "k", 1) },
+	}
+
+	var want []string
+	for _, m := range methods {
+		want = append(want, callerOf(m))
+		m()
+	}
+
+	_, _, line, _ := runtime.Caller(0)
+	warnHelper(ctx, "helper")
+	want = append(want, fmt.Sprintf("logger/caller_test.go:%d", line+1))
+
+	messages := Messages(ctx)
+	if len(messages) != len(want) {
+		t.Fatalf("expected %d messages, got %d", len(want), len(messages))
+	}
+
+	for i, m := range messages {
+		if m.File != want[i] {
+			t.Errorf("message %q: expected caller %s, got %s", m.Message, want[i], m.File)
+		}
+	}
+}
+
+// callerOf file:line of the single statement of fn
+func callerOf(fn func()) string {
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	file, line := f.FileLine(f.Entry())
+	return formatCaller(file, line)
+}
@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock time source of log timestamps, see Config.Clock and SetClock
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock Clock of time.Now
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// FixedClock Clock always returning the same time, e.g. for byte-stable output on tests
+type FixedClock time.Time
+
+func (c FixedClock) Now() time.Time { return time.Time(c) }
+
+// StepClock Clock advancing by step on every Now, starting at start
+type StepClock struct {
+	mu   sync.Mutex
+	next time.Time
+	step time.Duration
+}
+
+// NewStepClock Clock returning start then advancing by step on every Now
+func NewStepClock(start time.Time, step time.Duration) *StepClock {
+	return &StepClock{next: start, step: step}
+}
+
+func (c *StepClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.next
+	c.next = c.next.Add(c.step)
+	return now
+}
+
+// clockHolder keeps the Clock of the context logger in an atomic.Value of one concrete type
+type clockHolder struct {
+	Clock
+}
+
+var clock atomic.Value
+
+func init() {
+	clock.Store(clockHolder{systemClock{}})
+}
+
+// SetClock time source of the context logger, e.g. LogMessage.Timestamp, DataLogger.TimeStart of the
+// middlewares and ExecTime, nil restores the system clock
+func SetClock(c Clock) {
+	if c == nil {
+		c = systemClock{}
+	}
+
+	clock.Store(clockHolder{c})
+}
+
+// Now current time of the context logger clock, e.g. DataLogger.TimeStart of a middleware
+func Now() time.Time {
+	return now()
+}
+
+// now current time of the context logger clock
+func now() time.Time {
+	return clock.Load().(clockHolder).Now()
+}
+
+// zapClock zapcore.Clock of Clock, tickers keep using the system clock
+type zapClock struct {
+	Clock
+}
+
+func (zapClock) NewTicker(d time.Duration) *time.Ticker {
+	return time.NewTicker(d)
+}
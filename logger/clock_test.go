@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/loki"
+)
+
+func TestFixedClockStableOutput(t *testing.T) {
+	fixed := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	outputs := make([]string, 2)
+	for i := range outputs {
+		path := filepath.Join(t.TempDir(), "app.log")
+		capture := loki.NewCaptureClient()
+		log := New(Config{
+			Level: "info", JSONOutput: true, FilePath: path, Clock: FixedClock(fixed),
+			Loki: &LokiConfig{Enabled: true, Client: capture},
+		})
+		log.Info("order created")
+		_ = log.Close()
+
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs[i] = string(raw)
+
+		if entries := capture.Entries(); len(entries) != 1 || !entries[0].Timestamp.Equal(fixed) {
+			t.Errorf("expected loki timestamp from clock, got %+v", entries)
+		}
+	}
+
+	if outputs[0] != outputs[1] {
+		t.Errorf("expected byte-stable output, got\n%s\n%s", outputs[0], outputs[1])
+	}
+}
+
+func TestSetClock(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	SetClock(NewStepClock(start, time.Second))
+	defer SetClock(nil)
+
+	ctx := context.WithValue(context.Background(), LogKey, new(Locker))
+	Log.Print(ctx, "first")
+	Log.Print(ctx, "second")
+
+	value, _ := extract(ctx)
+	tmp, _ := value.Load(_LogMessages)
+	messages := tmp.([]LogMessage)
+	if len(messages) != 2 || !messages[0].Timestamp.Equal(start) || !messages[1].Timestamp.Equal(start.Add(time.Second)) {
+		t.Errorf("expected stepped timestamps, got %+v", messages)
+	}
+}
+
+func TestClockExecTime(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	SetClock(NewStepClock(start, 2*time.Second))
+	defer SetClock(nil)
+
+	ctx := context.WithValue(context.Background(), LogKey, new(Locker))
+	d := DataLogger{TimeStart: Now()}
+	if !d.Collect(ctx, nil) {
+		t.Fatal("expected logger in context")
+	}
+
+	if !d.TimeStart.Equal(start) || d.ExecTime != 2 {
+		t.Errorf("expected start and exec time from clock, got %s and %v", d.TimeStart, d.ExecTime)
+	}
+}
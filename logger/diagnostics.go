@@ -0,0 +1,118 @@
+package logger
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/TixiaOTA/gokit/utils/env"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// diagnostics track revision of every Locker key to detect lost writes, see LOG_DIAGNOSTICS
+	diagnostics     atomic.Bool
+	diagnosticsOnce sync.Once
+
+	// diagnosticsLog destination of diagnostics warnings
+	diagnosticsLog = Logrus()
+)
+
+// diagnosticsEnabled report whether diagnostics mode is on, LOG_DIAGNOSTICS is read on first use
+// so it is resolved once config.Load made the config file and env visible
+func diagnosticsEnabled() bool {
+	diagnosticsOnce.Do(func() {
+		diagnostics.Store(env.GetBool("LOG_DIAGNOSTICS"))
+	})
+
+	return diagnostics.Load()
+}
+
+// SetDiagnostics enable or disable diagnostics mode, default from LOG_DIAGNOSTICS env.
+// On diagnostics mode a Set overwriting a key changed by another goroutine since its LoadAndDelete
+// reports "concurrent logger mutation detected" with stacks of both goroutines
+func SetDiagnostics(enabled bool) {
+	diagnosticsOnce.Do(func() {})
+	diagnostics.Store(enabled)
+}
+
+// revision of a key with stack of its last writer
+type revision struct {
+	n     uint64
+	stack []byte
+}
+
+// observation key read by a goroutine
+type observation struct {
+	key  Flags
+	goid uint64
+}
+
+// lockerDiagnostics revision tracking of Locker, zero value is ready to use
+type lockerDiagnostics struct {
+	mu       sync.Mutex
+	revs     map[Flags]revision
+	observed map[observation]uint64
+}
+
+// observe record revision of key seen by current goroutine
+func (d *lockerDiagnostics) observe(key Flags) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.observed == nil {
+		d.observed = make(map[observation]uint64)
+	}
+
+	d.observed[observation{key: key, goid: goroutineId()}] = d.revs[key].n
+}
+
+// write bump revision of key, report when key changed since observed by current goroutine
+func (d *lockerDiagnostics) write(key Flags) {
+	stack := currentStack()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.revs == nil {
+		d.revs = make(map[Flags]revision)
+	}
+
+	current := d.revs[key]
+	obs := observation{key: key, goid: goroutineId()}
+	if seen, ok := d.observed[obs]; ok {
+		delete(d.observed, obs)
+
+		if seen != current.n {
+			diagnosticsLog.WithFields(logrus.Fields{
+				"key":             string(key),
+				"revision":        current.n,
+				"expected":        seen,
+				"current_writer":  string(stack),
+				"previous_writer": string(current.stack),
+			}).Warnf("concurrent logger mutation detected on key %s", key)
+		}
+	}
+
+	d.revs[key] = revision{n: current.n + 1, stack: stack}
+}
+
+func currentStack() []byte {
+	buf := make([]byte, 4096)
+	return buf[:runtime.Stack(buf, false)]
+}
+
+// goroutineId parse id of current goroutine from "goroutine 123 [running]:"
+func goroutineId() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i > 0 {
+		buf = buf[:i]
+	}
+
+	id, _ := strconv.ParseUint(string(buf), 10, 64)
+	return id
+}
@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestDiagnosticsConcurrentMutation(t *testing.T) {
+	var out bytes.Buffer
+	diagnosticsLog.SetOutput(&out)
+	SetDiagnostics(true)
+	defer func() {
+		SetDiagnostics(false)
+		diagnosticsLog.SetOutput(os.Stderr)
+	}()
+
+	lock := new(Locker)
+	ctx := context.WithValue(context.Background(), LogKey, lock)
+	lock.Set(_LogMessages, []LogMessage{{Message: "first"}})
+
+	// goroutine A reads, B appends in between, A overwrites B's message
+	read, written := make(chan struct{}), make(chan struct{})
+	go func() {
+		<-read
+		Log.Print(ctx, "lost")
+		close(written)
+	}()
+
+	tmp, _ := lock.LoadAndDelete(_LogMessages)
+	close(read)
+	<-written
+	lock.Set(_LogMessages, append(tmp.([]LogMessage), LogMessage{Message: "second"}))
+
+	report := out.String()
+	if !strings.Contains(report, "concurrent logger mutation detected on key LogMessages") {
+		t.Fatalf("expected concurrent mutation warning, got %q", report)
+	}
+
+	if !strings.Contains(report, "current_writer") || !strings.Contains(report, "logger.(*logger).Print") {
+		t.Errorf("expected stacks of both writers, got %q", report)
+	}
+
+	// sequential writes are fine
+	out.Reset()
+	Log.Print(ctx, "third")
+	if out.Len() > 0 {
+		t.Errorf("expected no warning on sequential writes, got %q", out.String())
+	}
+}
+
+func TestDiagnosticsDisabled(t *testing.T) {
+	lock := new(Locker)
+	lock.LoadAndDelete(_LogMessages)
+	lock.Set(_LogMessages, nil)
+
+	if lock.diag.revs != nil || lock.diag.observed != nil {
+		t.Error("expected no tracking when diagnostics is disabled")
+	}
+}
+
+func TestDiagnosticsResolvedOnFirstUse(t *testing.T) {
+	// config.Load runs after package init
+	diagnosticsOnce = sync.Once{}
+	viper.Set("LOG_DIAGNOSTICS", true)
+	defer func() {
+		viper.Set("LOG_DIAGNOSTICS", nil)
+		SetDiagnostics(false)
+	}()
+
+	if !diagnosticsEnabled() {
+		t.Error("expected LOG_DIAGNOSTICS loaded after package init enabling diagnostics")
+	}
+}
@@ -0,0 +1,103 @@
+package logger
+
+import (
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// PresetDefault keys time, level, msg with lowercase levels
+	PresetDefault = "default"
+	// PresetGCP keys and severities of GCP Cloud Logging, e.g. "severity":"WARNING"
+	PresetGCP = "gcp"
+	// PresetECS keys of Elastic Common Schema, e.g. "@timestamp", "log.level"
+	PresetECS = "ecs"
+)
+
+// EncoderFieldNames keys of encoded entry, empty key keeps the key of preset
+type EncoderFieldNames struct {
+	TimeKey       string
+	LevelKey      string
+	MessageKey    string
+	CallerKey     string
+	StacktraceKey string
+}
+
+// presets field names and level encoder of each preset
+var presets = map[string]struct {
+	names       EncoderFieldNames
+	encodeLevel zapcore.LevelEncoder
+	encodeTime  zapcore.TimeEncoder
+}{
+	PresetDefault: {
+		names:       EncoderFieldNames{TimeKey: "time", LevelKey: "level", MessageKey: "msg", CallerKey: "caller", StacktraceKey: "stacktrace"},
+		encodeLevel: zapcore.LowercaseLevelEncoder,
+		encodeTime:  zapcore.ISO8601TimeEncoder,
+	},
+	PresetGCP: {
+		names:       EncoderFieldNames{TimeKey: "timestamp", LevelKey: "severity", MessageKey: "message", CallerKey: "caller", StacktraceKey: "stack_trace"},
+		encodeLevel: gcpLevelEncoder,
+		encodeTime:  zapcore.RFC3339NanoTimeEncoder,
+	},
+	PresetECS: {
+		names:       EncoderFieldNames{TimeKey: "@timestamp", LevelKey: "log.level", MessageKey: "message", CallerKey: "log.origin", StacktraceKey: "error.stack_trace"},
+		encodeLevel: zapcore.LowercaseLevelEncoder,
+		encodeTime:  zapcore.RFC3339NanoTimeEncoder,
+	},
+}
+
+// gcpSeverities severity of GCP Cloud Logging by zap level
+var gcpSeverities = map[zapcore.Level]string{
+	zapcore.DebugLevel:  "DEBUG",
+	zapcore.InfoLevel:   "INFO",
+	zapcore.WarnLevel:   "WARNING",
+	zapcore.ErrorLevel:  "ERROR",
+	zapcore.DPanicLevel: "CRITICAL",
+	zapcore.PanicLevel:  "ALERT",
+	zapcore.FatalLevel:  "EMERGENCY",
+}
+
+func gcpLevelEncoder(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	if s, ok := gcpSeverities[l]; ok {
+		enc.AppendString(s)
+		return
+	}
+
+	enc.AppendString("DEFAULT")
+}
+
+// encoderConfig encoder config of preset overridden by configured field names, unknown preset fallback to default
+func encoderConfig(config Config) zapcore.EncoderConfig {
+	preset, ok := presets[strings.ToLower(config.Preset)]
+	if !ok {
+		preset = presets[PresetDefault]
+	}
+
+	names := preset.names
+	for _, f := range []struct{ dst, src *string }{
+		{&names.TimeKey, &config.FieldNames.TimeKey},
+		{&names.LevelKey, &config.FieldNames.LevelKey},
+		{&names.MessageKey, &config.FieldNames.MessageKey},
+		{&names.CallerKey, &config.FieldNames.CallerKey},
+		{&names.StacktraceKey, &config.FieldNames.StacktraceKey},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+
+	return zapcore.EncoderConfig{
+		TimeKey:        names.TimeKey,
+		LevelKey:       names.LevelKey,
+		NameKey:        "logger",
+		CallerKey:      names.CallerKey,
+		MessageKey:     names.MessageKey,
+		StacktraceKey:  names.StacktraceKey,
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    preset.encodeLevel,
+		EncodeTime:     preset.encodeTime,
+		EncodeDuration: zapcore.MillisDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+	}
+}
@@ -0,0 +1,108 @@
+// Package fibermw fiber middleware of the request logger usable on a bare fiber.App,
+// handlers log through logger.Log with the request context, e.g. logger.Log.Errorf(c.UserContext(), ...)
+package fibermw
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// headerRequestId request and response header carrying the request id
+const headerRequestId = "X-Request-Id"
+
+// OptionFunc setter of middleware options
+type OptionFunc func(*option)
+
+// option an instance of middleware options
+type option struct {
+	service string
+	log     *logrus.Logger
+	filter  logger.MessageFilter
+}
+
+// SetServiceName set service name written on the log
+func SetServiceName(name string) OptionFunc {
+	return func(o *option) {
+		o.service = name
+	}
+}
+
+// SetLogger set logrus logger writing the log, default is logger.Logrus
+func SetLogger(log *logrus.Logger) OptionFunc {
+	return func(o *option) {
+		o.log = log
+	}
+}
+
+// SetMessageFilter keep only log messages accepted by filter, e.g. logger.DropTags("chatty")
+func SetMessageFilter(filter logger.MessageFilter) OptionFunc {
+	return func(o *option) {
+		o.filter = filter
+	}
+}
+
+// New middleware installing the logger store into the user context, timing the request and writing one block
+// per request after the handler. The severity of the block is the worst of the status code and the logged messages,
+// method, path, status, bytes, latency and client IP are attached as fields
+func New(opts ...OptionFunc) fiber.Handler {
+	o := option{log: logger.Logrus()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return func(c *fiber.Ctx) error {
+		start := logger.Now()
+
+		requestId := c.Get(headerRequestId)
+		if requestId == "" {
+			requestId = uuid.NewString()
+		}
+
+		lock := new(logger.Locker)
+		lock.Set(logger.RequestId, requestId)
+		ctx := context.WithValue(c.UserContext(), logger.LogKey, lock)
+		c.SetUserContext(ctx)
+		c.Set(headerRequestId, requestId)
+
+		err := c.Next()
+		if err != nil {
+			// render error before logging, so the status code is recorded
+			if herr := c.App().Config().ErrorHandler(c, err); herr != nil {
+				_ = c.SendStatus(http.StatusInternalServerError)
+			}
+		}
+
+		status := c.Response().StatusCode()
+		logger.Response(ctx, status, nil, err)
+
+		dl := logger.DataLogger{
+			RequestId:     requestId,
+			Ip:            c.IP(),
+			Device:        c.Get(fiber.HeaderUserAgent),
+			Type:          logger.ServiceType("rest_api"),
+			TimeStart:     start,
+			Service:       o.service,
+			Host:          c.BaseURL(),
+			RequestMethod: c.Method(),
+			Endpoint:      c.Path(),
+		}
+		dl.Collect(ctx, o.filter)
+
+		o.log.WithFields(logrus.Fields{
+			"data":    &dl,
+			"method":  c.Method(),
+			"path":    c.Path(),
+			"status":  status,
+			"bytes":   len(c.Response().Body()),
+			"latency": logger.Now().Sub(start).String(),
+			"ip":      c.IP(),
+		}).Log(dl.Severity(), dl.Type.String())
+
+		return nil
+	}
+}
@@ -0,0 +1,97 @@
+package fibermw
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TixiaOTA/gokit/logger"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestSeverityEscalation(t *testing.T) {
+	var buf bytes.Buffer
+	log := logger.Logrus()
+	log.SetOutput(&buf)
+
+	app := fiber.New()
+	app.Use(New(SetServiceName("order"), SetLogger(log)))
+	app.Get("/ok", func(c *fiber.Ctx) error {
+		logger.Log.Printf(c.UserContext(), "fine")
+		return c.SendString("ok")
+	})
+	app.Get("/warn", func(c *fiber.Ctx) error {
+		logger.Log.Warnf(c.UserContext(), "slow downstream")
+		return c.SendString("ok")
+	})
+	app.Get("/error", func(c *fiber.Ctx) error {
+		logger.Log.Errorf(c.UserContext(), "cache write failed")
+		logger.Log.Warnf(c.UserContext(), "slow downstream")
+		return c.SendString("ok")
+	})
+	app.Get("/fail", func(c *fiber.Ctx) error {
+		return fiber.NewError(http.StatusNotFound, "order not found")
+	})
+
+	for path, want := range map[string]struct {
+		level  string
+		status int
+	}{
+		"/ok":    {"info", http.StatusOK},
+		"/warn":  {"warning", http.StatusOK},
+		"/error": {"error", http.StatusOK},
+		"/fail":  {"warning", http.StatusNotFound},
+	} {
+		buf.Reset()
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var entry struct {
+			Level   string            `json:"level"`
+			Method  string            `json:"method"`
+			Path    string            `json:"path"`
+			Status  int               `json:"status"`
+			Bytes   int               `json:"bytes"`
+			Latency string            `json:"latency"`
+			Data    logger.DataLogger `json:"data"`
+		}
+		if err = json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("%s: %v: %s", path, err, buf.String())
+		}
+
+		if entry.Level != want.level || entry.Status != want.status || resp.StatusCode != want.status {
+			t.Errorf("%s: expected %s with status %d, got %s with status %d", path, want.level, want.status, entry.Level, entry.Status)
+		}
+
+		if entry.Method != http.MethodGet || entry.Path != path || entry.Latency == "" || entry.Data.Service != "order" ||
+			entry.Data.RequestId == "" || entry.Data.RequestId != resp.Header.Get(headerRequestId) {
+			t.Errorf("%s: expected request fields, got %+v", path, entry)
+		}
+	}
+}
+
+func TestErrorHandlerBeforeLogging(t *testing.T) {
+	var buf bytes.Buffer
+	log := logger.Logrus()
+	log.SetOutput(&buf)
+
+	app := fiber.New()
+	app.Use(New(SetLogger(log)))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return errors.New("boom")
+	})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusInternalServerError || !bytes.Contains(buf.Bytes(), []byte(`"level":"error"`)) {
+		t.Errorf("expected 500 logged as error, got %d %s", resp.StatusCode, buf.String())
+	}
+}
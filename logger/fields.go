@@ -0,0 +1,130 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+)
+
+const _Fields Flags = "Fields"
+
+// SetFields set structured fields on context included in every subsequent log message of the request,
+// e.g. user_id and order_id. fields are merged into the fields already set, the map is replaced,
+// never mutated, so goroutines sharing the context stay consistent
+func SetFields(ctx context.Context, fields map[string]interface{}) {
+	if ctx == nil || len(fields) < 1 {
+		return
+	}
+
+	lock, ok := ctx.Value(LogKey).(*Locker)
+	if !ok {
+		return
+	}
+
+	lock.fieldsMu.Lock()
+	defer lock.fieldsMu.Unlock()
+
+	lock.Set(_Fields, mergeFields(loadFields(lock), fields))
+}
+
+// Fields copy of structured fields set on context, nil when none
+func Fields(ctx context.Context) map[string]interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	value, ok := extract(ctx)
+	if !ok {
+		return nil
+	}
+
+	return mergeFields(nil, loadFields(value))
+}
+
+// ErrorKV log error message with structured fields of alternating keys and values, e.g. "order_id", 42
+func (l *logger) ErrorKV(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	l.appendMessage(ctx, err, 1, msg, nil, keysAndValues)
+}
+
+// WarnKV log warn message with structured fields of alternating keys and values
+func (l *logger) WarnKV(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	l.appendMessage(ctx, warn, 1, msg, nil, keysAndValues)
+}
+
+// DebugKV log debug message with structured fields of alternating keys and values
+func (l *logger) DebugKV(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	if debugDisabled() {
+		return
+	}
+
+	l.appendMessage(ctx, debug, 1, msg, nil, keysAndValues)
+}
+
+// PrintKV log print message with structured fields of alternating keys and values
+func (l *logger) PrintKV(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	l.appendMessage(ctx, print, 1, msg, nil, keysAndValues)
+}
+
+func loadFields(value Values) map[string]interface{} {
+	if i, ok := value.Load(_Fields); ok && i != nil {
+		return i.(map[string]interface{})
+	}
+
+	return nil
+}
+
+// messageFields fields of a message, fields of context overridden by keysAndValues.
+// the map of context is shared as is when there is nothing to add, it is never mutated
+func messageFields(value Values, keysAndValues []interface{}) map[string]interface{} {
+	current := loadFields(value)
+	if len(keysAndValues) < 1 {
+		return current
+	}
+
+	fields := make(map[string]interface{}, len(current)+len(keysAndValues)/2)
+	for k, v := range current {
+		fields[k] = v
+	}
+
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key, ok := keysAndValues[i].(string)
+		if !ok {
+			key = fmt.Sprint(keysAndValues[i])
+		}
+
+		// dangling key without value
+		var val interface{}
+		if i+1 < len(keysAndValues) {
+			val = keysAndValues[i+1]
+		}
+
+		fields[key] = fieldValue(val)
+	}
+
+	return fields
+}
+
+// mergeFields copy of base with fields added, nil when both are empty
+func mergeFields(base, fields map[string]interface{}) map[string]interface{} {
+	if len(base)+len(fields) < 1 {
+		return nil
+	}
+
+	merged := make(map[string]interface{}, len(base)+len(fields))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = fieldValue(v)
+	}
+
+	return merged
+}
+
+// fieldValue value of field as marshalled, an error is kept as its message instead of {}
+func fieldValue(v interface{}) interface{} {
+	if e, ok := v.(error); ok {
+		return e.Error()
+	}
+
+	return v
+}
@@ -0,0 +1,78 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFields(t *testing.T) {
+	ctx := context.WithValue(context.Background(), LogKey, new(Locker))
+
+	SetFields(ctx, map[string]interface{}{"user_id": "u-1"})
+	Log.Print(ctx, "plain")
+	SetFields(ctx, map[string]interface{}{"order_id": 42})
+	Log.ErrorKV(ctx, "payment failed", "order_id", 43, "amount", 9.5, "dangling")
+
+	messages := Messages(ctx)
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+
+	if got := messages[0].Fields; len(got) != 1 || got["user_id"] != "u-1" {
+		t.Errorf("expected fields of context on plain message, got %v", got)
+	}
+
+	got := messages[1].Fields
+	if got["user_id"] != "u-1" || got["order_id"] != 43 || got["amount"] != 9.5 || got["dangling"] != nil || len(got) != 4 {
+		t.Errorf("expected context fields overridden by message fields, got %v", got)
+	}
+
+	if f := Fields(ctx); f["order_id"] != 42 {
+		t.Errorf("expected message fields never written back to context, got %v", f)
+	}
+
+	raw, _ := json.Marshal(messages[1])
+	if !strings.Contains(string(raw), `"fields":{`) || !strings.Contains(string(raw), `"order_id":43`) {
+		t.Errorf("expected fields as json object, got %s", raw)
+	}
+}
+
+func TestFieldsConcurrent(t *testing.T) {
+	ctx := context.WithValue(context.Background(), LogKey, new(Locker))
+	SetFields(ctx, map[string]interface{}{"user_id": "u-1"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			SetFields(ctx, map[string]interface{}{fmt.Sprintf("k%d", i): i})
+			Log.PrintKV(ctx, "step", "i", i)
+			_ = Fields(ctx)
+		}(i)
+	}
+	wg.Wait()
+
+	if f := Fields(ctx); len(f) != 9 {
+		t.Errorf("expected every concurrent SetFields kept, got %v", f)
+	}
+}
+
+func TestFieldsError(t *testing.T) {
+	ctx := context.WithValue(context.Background(), LogKey, new(Locker))
+
+	SetFields(ctx, map[string]interface{}{"cause": errors.New("connection refused")})
+	Log.ErrorKV(ctx, "payment failed", "err", fmt.Errorf("charge: %w", errors.New("card declined")))
+
+	raw, _ := json.Marshal(Messages(ctx)[0])
+	for _, want := range []string{`"cause":"connection refused"`, `"err":"charge: card declined"`} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("expected error field as its message %s, got %s", want, raw)
+		}
+	}
+}
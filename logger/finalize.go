@@ -4,7 +4,6 @@ import (
 	"context"
 	"reflect"
 	"strings"
-	"time"
 
 	"github.com/TixiaOTA/gokit/utils/env"
 	"github.com/TixiaOTA/gokit/utils/monitoring"
@@ -13,9 +12,25 @@ import (
 
 // Finalize load from context and delete data context
 func (d *DataLogger) Finalize(ctx context.Context) {
+	d.FinalizeFiltered(ctx, nil)
+}
+
+// FinalizeFiltered same as Finalize with only log messages kept by filter, e.g. DropTags("chatty")
+func (d *DataLogger) FinalizeFiltered(ctx context.Context, filter MessageFilter) {
+	if !d.Collect(ctx, filter) {
+		return
+	}
+
+	monitoring.PrometheusRecord(d.StatusCode, d.RequestMethod, d.Endpoint, d.Service, now().Sub(d.TimeStart))
+	d.write()
+}
+
+// Collect load from context and delete data context like FinalizeFiltered without writing the log,
+// for middlewares writing the log themselves, e.g. fibermw. filter may be nil, false when logger is not in context
+func (d *DataLogger) Collect(ctx context.Context, filter MessageFilter) bool {
 	value, ok := extract(ctx)
 	if !ok {
-		return
+		return false
 	}
 
 	if i, ok := value.LoadAndDelete(_StatusCode); ok && i != nil {
@@ -31,7 +46,7 @@ func (d *DataLogger) Finalize(ctx context.Context) {
 	}
 
 	if i, ok := value.LoadAndDelete(_LogMessages); ok && i != nil {
-		d.LogMessages = i.([]LogMessage)
+		d.LogMessages = filterMessages(i.([]LogMessage), filter)
 	}
 
 	if i, ok := value.LoadAndDelete(_ErrorMessage); ok && i != nil {
@@ -46,7 +61,15 @@ func (d *DataLogger) Finalize(ctx context.Context) {
 		d.Device = i.(string)
 	}
 
-	d.ExecTime = time.Since(d.TimeStart).Seconds()
+	if i, ok := value.LoadAndDelete(_Metadata); ok && i != nil {
+		d.Metadata = i.(map[string]string)
+	}
+
+	if baggageFields.Load() {
+		d.Baggage = loadBaggage(value)
+	}
+
+	d.ExecTime = now().Sub(d.TimeStart).Seconds()
 
 	appEnv := strings.ToUpper(env.GetString("APP_ENV"))
 	if len(d.LogMessages) > 5 && !reflect.ValueOf(appEnv).IsZero() && appEnv == "PRODUCTION" {
@@ -55,13 +78,52 @@ func (d *DataLogger) Finalize(ctx context.Context) {
 
 	// delete context GetRequestId and GetSaltKey
 	value.Delete(_SaltKey)
+	value.Delete(_SaltKeys)
 	value.Delete(RequestId)
+	value.Delete(_StackCaptured)
 
-	monitoring.PrometheusRecord(d.StatusCode, d.RequestMethod, d.Endpoint, d.Service, time.Since(d.TimeStart))
-	d.write()
+	return true
+}
+
+// Severity worst level of status code and log messages, error > warn > info,
+// so a handler logging an error is reported as error even when it responds 200
+func (d *DataLogger) Severity() logrus.Level {
+	level := statusLevel(d.StatusCode)
+	for _, m := range d.LogMessages {
+		switch {
+		case m.Level == err:
+			return logrus.ErrorLevel
+		case m.Level == warn && level > logrus.WarnLevel:
+			level = logrus.WarnLevel
+		}
+	}
+
+	return level
+}
+
+// statusLevel info for 2xx and 3xx, warn for 4xx and error for the others
+func statusLevel(statusCode int) logrus.Level {
+	switch {
+	case statusCode >= 200 && statusCode < 400:
+		return logrus.InfoLevel
+	case statusCode >= 400 && statusCode < 500:
+		return logrus.WarnLevel
+	default:
+		return logrus.ErrorLevel
+	}
+}
+
+// Discard finalize without writing the log, e.g. request dropped by access log sampling,
+// the context is still cleaned up and the request still recorded on prometheus
+func (d *DataLogger) Discard() {
+	d.discarded = true
 }
 
 func (d *DataLogger) write() {
+	if d.discarded {
+		return
+	}
+
 	var (
 		level logrus.Level
 		// elasticStatus = env.GetBool("ELASTICSEARCH_ENABLED", false)
@@ -83,13 +145,7 @@ func (d *DataLogger) write() {
 	// 	}
 	// }
 
-	if d.StatusCode >= 200 && d.StatusCode < 400 {
-		level = logrus.InfoLevel
-	} else if d.StatusCode >= 400 && d.StatusCode < 500 {
-		level = logrus.WarnLevel
-	} else {
-		level = logrus.ErrorLevel
-	}
+	level = statusLevel(d.StatusCode)
 
 	Logrus().WithField("data", d).Log(level, d.Type.String())
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/TixiaOTA/gokit/utils/monitoring"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
@@ -26,6 +27,9 @@ type InterceptorInterface interface {
 
 	// UnaryClientTracerInterceptor trace the outcoming request (from client) to grpc server
 	UnaryClientTracerInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) (err error)
+
+	// StreamClientBaggageInterceptor propagate baggage of context on streaming calls, see SetBaggage
+	StreamClientBaggageInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error)
 }
 
 // NewInterceptor constructor interceptor grpc request
@@ -94,6 +98,19 @@ func (i *interceptor) UnaryClientTracerInterceptor(ctx context.Context, method s
 	trace.SetTag("target", tp.ServiceTarget)
 	trace.SetTag("request_body", req)
 
-	err = invoker(ctx, method, req, reply, cc, opts...)
+	err = invoker(outgoingBaggage(ctx), method, req, reply, cc, opts...)
 	return
 }
+
+func (i *interceptor) StreamClientBaggageInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(outgoingBaggage(ctx), desc, cc, method, opts...)
+}
+
+// outgoingBaggage ctx with baggage as prefixed outgoing metadata, see SetBaggage
+func outgoingBaggage(ctx context.Context) context.Context {
+	for k, v := range BaggageHeaders(ctx) {
+		ctx = metadata.AppendToOutgoingContext(ctx, k, v)
+	}
+
+	return ctx
+}
@@ -0,0 +1,97 @@
+package logger
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// sinkStats failure counter per sink, shared by every core derived with With
+type sinkStats struct {
+	mu     sync.Mutex
+	errors map[string]*atomic.Uint64
+}
+
+func newSinkStats() *sinkStats {
+	return &sinkStats{errors: make(map[string]*atomic.Uint64)}
+}
+
+func (s *sinkStats) counter(name string) *atomic.Uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.errors[name]
+	if !ok {
+		c = new(atomic.Uint64)
+		s.errors[name] = c
+	}
+
+	return c
+}
+
+func (s *sinkStats) snapshot() map[string]uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[string]uint64, len(s.errors))
+	for name, c := range s.errors {
+		out[name] = c.Load()
+	}
+
+	return out
+}
+
+// isolatedCore swallow write errors of a sink so a broken sink never affects the other sinks of the tee,
+// entries go to fallback after threshold consecutive failures until the sink recovers
+type isolatedCore struct {
+	zapcore.Core
+	name        string
+	errors      *atomic.Uint64
+	consecutive *atomic.Int64
+	threshold   int64
+	fallback    zapcore.Core
+}
+
+func newIsolatedCore(name string, core, fallback zapcore.Core, threshold int, stats *sinkStats) *isolatedCore {
+	return &isolatedCore{
+		Core:        core,
+		name:        name,
+		errors:      stats.counter(name),
+		consecutive: new(atomic.Int64),
+		threshold:   int64(threshold),
+		fallback:    fallback,
+	}
+}
+
+func (c *isolatedCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.Core = c.Core.With(fields)
+	if c.fallback != nil {
+		clone.fallback = c.fallback.With(fields)
+	}
+
+	return &clone
+}
+
+func (c *isolatedCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+
+	return ce
+}
+
+func (c *isolatedCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if err := c.Core.Write(ent, fields); err != nil {
+		c.errors.Add(1)
+		if n := c.consecutive.Add(1); c.fallback != nil && c.threshold > 0 && n >= c.threshold {
+			_ = c.fallback.Write(ent, fields)
+		}
+
+		return nil
+	}
+
+	c.consecutive.Store(0)
+	return nil
+}
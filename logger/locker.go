@@ -14,7 +14,17 @@ type Values interface {
 
 // Set value to keys
 func (l *Locker) Set(key Flags, value interface{}) {
+	if diagnosticsEnabled() {
+		l.diag.write(key)
+	}
+
 	l.data.Store(key, value)
+
+	if key == _LogMessages {
+		if af := l.autoFlush.Load(); af != nil {
+			af.notify(value)
+		}
+	}
 }
 
 // Delete value from sync
@@ -29,6 +39,10 @@ func (l *Locker) Load(key Flags) (interface{}, bool) {
 
 // LoadAndDelete from key
 func (l *Locker) LoadAndDelete(key Flags) (interface{}, bool) {
+	if diagnosticsEnabled() {
+		l.diag.observe(key)
+	}
+
 	return l.data.LoadAndDelete(key)
 }
 
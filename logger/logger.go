@@ -12,7 +12,11 @@ import (
 	"github.com/google/uuid"
 )
 
-type logger struct{}
+// logger context logger, messages are kept on the context and written by the data logger
+type logger struct {
+	// callerSkip frames skipped above the caller of the log method, see AddCallerSkip
+	callerSkip int
+}
 
 var (
 	Log  *logger
@@ -25,310 +29,168 @@ func init() {
 	})
 }
 
-func (l *logger) Errorf(ctx context.Context, format string, args ...interface{}) {
-	var (
-		messages []LogMessage
-		file     string
-	)
-
-	if ctx == nil {
-		fmt.Printf("ERROR: %v (nil context)\n", fmt.Sprintf(format, args...))
-		return
-	}
-
-	value, ok := extract(ctx)
-	if !ok {
-		fmt.Printf("ERROR: %v (logger not found in context)\n", fmt.Sprintf(format, args...))
-		return
-	}
-
-	// for get filename and line when developer called this method
-	_, fileName, line, _ := runtime.Caller(1)
-	files := strings.Split(fileName, "/")
-	if len(files) > 3 {
-		file = fmt.Sprintf("%s:%d", strings.Join(files[len(files)-2:], "/"), line)
-	} else {
-		file = fmt.Sprintf("%s:%d", strings.Join(files, "/"), line)
-	}
-
-	tmp, ok := value.LoadAndDelete(_LogMessages)
-	if ok && tmp != nil {
-		existingMessages, ok := tmp.([]LogMessage)
-		if ok {
-			messages = existingMessages
-		}
-	}
-
-	message := LogMessage{
-		File:    file,
-		Level:   err,
-		Message: fmt.Sprintf(format, args...),
-	}
-
-	messages = append(messages, message)
+// AddCallerSkip copy of logger reporting the caller skip frames above the caller of its methods,
+// e.g. 1 for a helper wrapping Log so messages point to the call site of the helper
+func (l *logger) AddCallerSkip(skip int) *logger {
+	return &logger{callerSkip: l.callerSkip + skip}
+}
 
-	value.Set(_LogMessages, messages)
+func (l *logger) Errorf(ctx context.Context, format string, args ...interface{}) {
+	l.appendMessage(ctx, err, 1, fmt.Sprintf(format, args...), nil, nil)
 }
 
 func (l *logger) Error(ctx context.Context, args ...interface{}) {
-	var (
-		messages []LogMessage
-		file     string
-	)
-
-	if ctx == nil {
-		fmt.Printf("ERROR: %v (nil context)\n", fmt.Sprint(args...))
-		return
-	}
-
-	value, ok := extract(ctx)
-	if !ok {
-		fmt.Printf("ERROR: %v (logger not found in context)\n", fmt.Sprint(args...))
-		return
-	}
-
-	// for get filename and line when developer called this method
-	_, fileName, line, _ := runtime.Caller(1)
-	files := strings.Split(fileName, "/")
-	if len(files) > 3 {
-		file = fmt.Sprintf("%s:%d", strings.Join(files[len(files)-2:], "/"), line)
-	} else {
-		file = fmt.Sprintf("%s:%d", strings.Join(files, "/"), line)
-	}
-
-	tmp, ok := value.LoadAndDelete(_LogMessages)
-	if ok && tmp != nil {
-		existingMessages, ok := tmp.([]LogMessage)
-		if ok {
-			messages = existingMessages
-		}
-	}
-
-	message := LogMessage{
-		File:    file,
-		Level:   err,
-		Message: fmt.Sprint(args...),
-	}
+	l.appendMessage(ctx, err, 1, fmt.Sprint(args...), nil, nil)
+}
 
-	messages = append(messages, message)
+func (l *logger) Warnf(ctx context.Context, format string, args ...interface{}) {
+	l.appendMessage(ctx, warn, 1, fmt.Sprintf(format, args...), nil, nil)
+}
 
-	value.Set(_LogMessages, messages)
+func (l *logger) Warn(ctx context.Context, args ...interface{}) {
+	l.appendMessage(ctx, warn, 1, fmt.Sprint(args...), nil, nil)
 }
 
 func (l *logger) DebugF(ctx context.Context, format string, args ...interface{}) {
-	var (
-		messages []LogMessage
-		file     string
-		appEnv   = strings.ToUpper(env.GetString("APP_ENV"))
-	)
-
-	// skip debug when app_env is production
-	if !reflect.ValueOf(appEnv).IsZero() && appEnv == "PRODUCTION" {
+	if debugDisabled() {
 		return
 	}
 
-	if ctx == nil {
-		fmt.Printf("DEBUG: %v (nil context)\n", fmt.Sprintf(format, args...))
-		return
-	}
+	l.appendMessage(ctx, debug, 1, fmt.Sprintf(format, args...), nil, nil)
+}
 
-	value, ok := extract(ctx)
-	if !ok {
-		fmt.Printf("DEBUG: %v (logger not found in context)\n", fmt.Sprintf(format, args...))
+func (l *logger) Debug(ctx context.Context, args ...interface{}) {
+	if debugDisabled() {
 		return
 	}
 
-	// for get filename and line when developer called this method
-	_, fileName, line, _ := runtime.Caller(1)
-	files := strings.Split(fileName, "/")
-	if len(files) > 3 {
-		file = fmt.Sprintf("%s:%d", strings.Join(files[len(files)-2:], "/"), line)
-	} else {
-		file = fmt.Sprintf("%s:%d", strings.Join(files, "/"), line)
-	}
-
-	tmp, ok := value.LoadAndDelete(_LogMessages)
-	if ok && tmp != nil {
-		existingMessages, ok := tmp.([]LogMessage)
-		if ok {
-			messages = existingMessages
-		}
-	}
-
-	message := LogMessage{
-		File:    file,
-		Level:   debug,
-		Message: fmt.Sprintf(format, args...),
-	}
+	l.appendMessage(ctx, debug, 1, fmt.Sprint(args...), nil, nil)
+}
 
-	messages = append(messages, message)
+func (l *logger) Printf(ctx context.Context, format string, args ...interface{}) {
+	l.appendMessage(ctx, print, 1, fmt.Sprintf(format, args...), nil, nil)
+}
 
-	value.Set(_LogMessages, messages)
+func (l *logger) Print(ctx context.Context, args ...interface{}) {
+	l.appendMessage(ctx, print, 1, fmt.Sprint(args...), nil, nil)
 }
 
-func (l *logger) Debug(ctx context.Context, args ...interface{}) {
-	var (
-		messages []LogMessage
-		file     string
-		appEnv   = strings.ToUpper(env.GetString("APP_ENV"))
-	)
-
-	// skip debug when app_env is production
-	if !reflect.ValueOf(appEnv).IsZero() && appEnv == "PRODUCTION" {
-		return
-	}
+// debugDisabled skip debug when app_env is production
+func debugDisabled() bool {
+	appEnv := strings.ToUpper(env.GetString("APP_ENV"))
+	return appEnv == "PRODUCTION"
+}
 
+// appendMessage append msg to the messages of context, skip is the number of frames between appendMessage
+// and the caller reported on the message, e.g. 1 for the public methods. tags are added to the context tags,
+// keysAndValues to the fields of context, see SetFields
+func (l *logger) appendMessage(ctx context.Context, level string, skip int, msg string, tags []string, keysAndValues []interface{}) {
 	if ctx == nil {
-		fmt.Printf("DEBUG: %v (nil context)\n", fmt.Sprint(args...))
+		fmt.Printf("%s: %v (nil context)\n", consoleLevel(level), msg)
 		return
 	}
 
 	value, ok := extract(ctx)
 	if !ok {
-		fmt.Printf("DEBUG: %v (logger not found in context)\n", fmt.Sprint(args...))
+		fmt.Printf("%s: %v (logger not found in context)\n", consoleLevel(level), msg)
 		return
 	}
 
-	// for get filename and line when developer called this method
-	_, fileName, line, _ := runtime.Caller(1)
-	files := strings.Split(fileName, "/")
-	if len(files) > 3 {
-		file = fmt.Sprintf("%s:%d", strings.Join(files[len(files)-2:], "/"), line)
-	} else {
-		file = fmt.Sprintf("%s:%d", strings.Join(files, "/"), line)
-	}
+	// for get filename and line when developer called the log method
+	_, fileName, line, _ := runtime.Caller(skip + 1 + l.callerSkip)
 
-	tmp, ok := value.LoadAndDelete(_LogMessages)
-	if ok && tmp != nil {
-		existingMessages, ok := tmp.([]LogMessage)
-		if ok {
+	var messages []LogMessage
+	if tmp, ok := value.LoadAndDelete(_LogMessages); ok && tmp != nil {
+		if existingMessages, ok := tmp.([]LogMessage); ok {
 			messages = existingMessages
 		}
 	}
 
 	message := LogMessage{
-		File:    file,
-		Level:   debug,
-		Message: fmt.Sprint(args...),
+		File:      formatCaller(fileName, line),
+		Level:     level,
+		Message:   msg,
+		Tags:      mergeTags(contextTags(ctx), tags),
+		Fields:    messageFields(value, keysAndValues),
+		WorkerId:  WorkerID(ctx),
+		Timestamp: now(),
+	}
+	if level == err {
+		message.Stack = errorStack(value)
 	}
 
-	messages = append(messages, message)
-
-	value.Set(_LogMessages, messages)
+	value.Set(_LogMessages, append(messages, message))
 }
 
-func (l *logger) Printf(ctx context.Context, format string, args ...interface{}) {
-	var (
-		messages []LogMessage
-		file     string
-	)
+// consoleLevel level printed when there is no context logger, print messages are shown as INFO
+func consoleLevel(level string) string {
+	if level == print {
+		return "INFO"
+	}
+
+	return level
+}
 
+// GetRequestId getting request id log from context
+func GetRequestId(ctx context.Context) string {
 	if ctx == nil {
-		fmt.Printf("INFO: %v (nil context)\n", fmt.Sprintf(format, args...))
-		return
+		return uuid.New().String()
 	}
 
 	value, ok := extract(ctx)
 	if !ok {
-		fmt.Printf("INFO: %v (logger not found in context)\n", fmt.Sprintf(format, args...))
-		return
-	}
-
-	// for get filename and line when developer called this method
-	_, fileName, line, _ := runtime.Caller(1)
-	files := strings.Split(fileName, "/")
-	if len(files) > 3 {
-		file = fmt.Sprintf("%s:%d", strings.Join(files[len(files)-2:], "/"), line)
-	} else {
-		file = fmt.Sprintf("%s:%d", strings.Join(files, "/"), line)
+		return uuid.New().String()
 	}
 
-	tmp, ok := value.LoadAndDelete(_LogMessages)
-	if ok && tmp != nil {
-		existingMessages, ok := tmp.([]LogMessage)
-		if ok {
-			messages = existingMessages
-		}
+	val, ok := value.Load(RequestId)
+	if !ok || val == nil {
+		return uuid.New().String()
 	}
 
-	message := LogMessage{
-		File:    file,
-		Level:   print,
-		Message: fmt.Sprintf(format, args...),
+	v, ok := val.(string)
+	if ok && v != "" {
+		return v
 	}
 
-	messages = append(messages, message)
-
-	value.Set(_LogMessages, messages)
+	return uuid.New().String()
 }
 
-func (l *logger) Print(ctx context.Context, args ...interface{}) {
-	var (
-		messages []LogMessage
-		file     string
-	)
-
+// Messages copy of messages logged on context so far, kept for the data logger, nil when none
+func Messages(ctx context.Context) []LogMessage {
 	if ctx == nil {
-		fmt.Printf("INFO: %v (nil context)\n", fmt.Sprint(args...))
-		return
+		return nil
 	}
 
 	value, ok := extract(ctx)
 	if !ok {
-		fmt.Printf("INFO: %v (logger not found in context)\n", fmt.Sprint(args...))
-		return
+		return nil
 	}
 
-	// for get filename and line when developer called this method
-	_, fileName, line, _ := runtime.Caller(1)
-	files := strings.Split(fileName, "/")
-	if len(files) > 3 {
-		file = fmt.Sprintf("%s:%d", strings.Join(files[len(files)-2:], "/"), line)
-	} else {
-		file = fmt.Sprintf("%s:%d", strings.Join(files, "/"), line)
+	tmp, ok := value.Load(_LogMessages)
+	if !ok || tmp == nil {
+		return nil
 	}
 
-	tmp, ok := value.LoadAndDelete(_LogMessages)
-	if ok && tmp != nil {
-		existingMessages, ok := tmp.([]LogMessage)
-		if ok {
-			messages = existingMessages
-		}
-	}
-
-	message := LogMessage{
-		File:    file,
-		Level:   print,
-		Message: fmt.Sprint(args...),
+	messages, _ := tmp.([]LogMessage)
+	if len(messages) < 1 {
+		return nil
 	}
 
-	messages = append(messages, message)
-
-	value.Set(_LogMessages, messages)
+	return append([]LogMessage(nil), messages...)
 }
 
-// GetRequestId getting request id log from context
-func GetRequestId(ctx context.Context) string {
-	if ctx == nil {
-		return uuid.New().String()
+// SetMetadata set request metadata written on the data logger, e.g. allowlisted headers
+func SetMetadata(ctx context.Context, md map[string]string) {
+	if ctx == nil || len(md) < 1 {
+		return
 	}
 
 	value, ok := extract(ctx)
 	if !ok {
-		return uuid.New().String()
-	}
-
-	val, ok := value.Load(RequestId)
-	if !ok || val == nil {
-		return uuid.New().String()
-	}
-
-	v, ok := val.(string)
-	if ok && v != "" {
-		return v
+		return
 	}
 
-	return uuid.New().String()
+	value.Set(_Metadata, md)
 }
 
 func SetSaltKey(ctx context.Context, val string) {
@@ -373,6 +235,46 @@ func GetSaltKey(ctx context.Context) string {
 	return defaultSaltKey
 }
 
+// SaltKeys versioned salt keys, Current is the version used to sign
+type SaltKeys struct {
+	Current string
+	Keys    map[string]string
+}
+
+// SetSaltKeys set versioned salt keys into context to support key rotation
+func SetSaltKeys(ctx context.Context, current string, keys map[string]string) {
+	if ctx == nil {
+		return
+	}
+
+	value, ok := extract(ctx)
+	if !ok {
+		return
+	}
+
+	value.Set(_SaltKeys, SaltKeys{Current: current, Keys: keys})
+}
+
+// GetSaltKeys get versioned salt keys from context, fallback to GetSaltKey
+// as the only key with version from NEW_SALT_KEY_VERSION (default "v1")
+func GetSaltKeys(ctx context.Context) SaltKeys {
+	if ctx != nil {
+		if value, ok := extract(ctx); ok {
+			if val, ok := value.Load(_SaltKeys); ok {
+				if keys, ok := val.(SaltKeys); ok && len(keys.Keys) > 0 {
+					return keys
+				}
+			}
+		}
+	}
+
+	version := env.GetString("NEW_SALT_KEY_VERSION", "v1")
+	return SaltKeys{
+		Current: version,
+		Keys:    map[string]string{version: GetSaltKey(ctx)},
+	}
+}
+
 func Red(val interface{}) {
 	fmt.Printf("\x1b[31;5m%v\x1b[0m\n", val)
 }
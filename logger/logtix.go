@@ -1,10 +1,13 @@
 package logger
 
 import (
+	"fmt"
 	"os"
+	"path"
 	"strings"
 	"time"
 
+	"github.com/TixiaOTA/gokit/factory"
 	"github.com/TixiaOTA/gokit/loki"
 	"github.com/natefinch/lumberjack"
 	"go.uber.org/zap"
@@ -14,7 +17,11 @@ import (
 // Logger is a wrapper around zap.Logger
 type Logger struct {
 	*zap.Logger
-	lokiClient *loki.Client
+	lokiClient loki.Sink
+	files      *namedFiles
+	sinks      *sinkStats
+
+	lokiFallback *lokiFallback
 }
 
 // Config represents logger configuration
@@ -24,6 +31,35 @@ type Config struct {
 	FilePath    string
 	Environment string
 	Loki        *LokiConfig
+
+	// FilePattern route entries into a file per Named logger, e.g. "/var/log/app-{logger}.log",
+	// entries of the unnamed logger go to "default", used instead of FilePath when set
+	FilePattern string
+	// MaxLoggerNames maximum distinct logger names of FilePattern and LokiConfig.NameLabel,
+	// new names beyond the limit share "other", default 20
+	MaxLoggerNames int
+
+	// Preset field names and level values of output, PresetDefault, PresetGCP or PresetECS
+	Preset string
+	// FieldNames override keys of Preset
+	FieldNames EncoderFieldNames
+
+	// StacktraceLevel minimum level capturing stack trace, empty or unknown means error
+	StacktraceLevel string
+	// DisableStacktrace never capture stack trace
+	DisableStacktrace bool
+	// TrimStacktrace strip gokit and zap frames from stack trace
+	TrimStacktrace bool
+	// StacktraceDepth maximum frames kept when TrimStacktrace is enabled, zero means unlimited
+	StacktraceDepth int
+
+	// Clock time source of entry timestamps, e.g. FixedClock for byte-stable output on tests,
+	// default the system clock
+	Clock Clock
+
+	// SinkFallbackAfter consecutive write failures of a sink before its entries go to stderr,
+	// zero never falls back, see Logger.SinkErrors
+	SinkFallbackAfter int
 }
 
 // LokiConfig represents Loki-specific configuration
@@ -33,25 +69,88 @@ type LokiConfig struct {
 	BatchSize int
 	BatchWait time.Duration
 	Labels    map[string]string
+
+	// basic auth, tenant and headers of every push, Password is read from PasswordEnv when empty, see loki.Config
+	Username    string
+	Password    string
+	PasswordEnv string
+	TenantID    string
+	Headers     map[string]string
+
+	// AllowedLabels whitelist of per entry label keys, see loki.Config
+	AllowedLabels []string
+
+	// AutoHostLabels add host, pod, namespace and node labels, see loki.Config
+	AutoHostLabels    bool
+	ExcludeHostLabels []string
+
+	// ValidateOnStart push an empty request on startup and log a diagnostic of the failure, see loki.Client.Validate.
+	// StrictStart fails the start with the validation error instead, see NewE
+	ValidateOnStart bool
+	StrictStart     bool
+
+	// NameLabel add "logger" label with the name of Named logger, entries of the unnamed logger have no label
+	NameLabel bool
+
+	// CallerMetadata attach file and line of caller as "caller" structured metadata
+	CallerMetadata bool
+	// PackageLabel add "package" label with directory of caller, bounded by Config.MaxLoggerNames
+	PackageLabel bool
+
+	// Level minimum level shipped to loki, empty means Config.Level
+	Level string
+
+	// FallbackPath file receiving entries while the client is unhealthy, entries are written there
+	// on top of being queued until the client recovers, see Logger.LokiFallbackWritten. with URL the
+	// file receives every entry the client drops instead, e.g. the batch failing before it turns unhealthy
+	FallbackPath string
+
+	// SecondaryURL endpoint receiving a best-effort copy of every push with its own basic auth and tenant,
+	// MirrorSampleRate mirror only a fraction of batches, see loki.Config
+	SecondaryURL      string
+	SecondaryUsername string
+	SecondaryPassword string
+	SecondaryTenantID string
+	MirrorSampleRate  float64
+
+	// MaxRetries retry failed pushes with exponential backoff between MinBackoff and MaxBackoff, honoring
+	// Retry-After up to MaxRetryAfter, for at most MaxRetryDuration per batch, see loki.Config
+	MaxRetries       int
+	MinBackoff       time.Duration
+	MaxBackoff       time.Duration
+	MaxRetryAfter    time.Duration
+	MaxRetryDuration time.Duration
+
+	// StopTimeout maximum time Close waits for queued entries to be pushed, see loki.Config
+	StopTimeout time.Duration
+
+	// OnError and OnDrop hooks of failed pushes and dropped entries, see loki.Config
+	OnError func(error)
+	OnDrop  func(loki.DroppedEntry)
+
+	// Client already constructed client used instead of creating one from URL,
+	// e.g. loki.NewCaptureClient on tests
+	Client loki.Sink
 }
 
-// New creates a new logger with the given configuration
+// New creates a new logger with the given configuration, it panics when Loki.StrictStart fails the start
 func New(config Config) *Logger {
-	// Set up encoder config
-	encoderConfig := zapcore.EncoderConfig{
-		TimeKey:        "time",
-		LevelKey:       "level",
-		NameKey:        "logger",
-		CallerKey:      "caller",
-		MessageKey:     "msg",
-		StacktraceKey:  "stacktrace",
-		LineEnding:     zapcore.DefaultLineEnding,
-		EncodeLevel:    zapcore.LowercaseLevelEncoder,
-		EncodeTime:     zapcore.ISO8601TimeEncoder,
-		EncodeDuration: zapcore.MillisDurationEncoder,
-		EncodeCaller:   zapcore.ShortCallerEncoder,
+	l, err := NewE(config)
+	if err != nil {
+		panic(err)
 	}
 
+	return l
+}
+
+// NewE same as New, with Loki.ValidateOnStart and Loki.StrictStart the failed validation of the loki
+// client is returned so the service can fail fast
+func NewE(config Config) (*Logger, error) {
+	errorStackMode()
+
+	// Set up encoder config
+	encoderConfig := encoderConfig(config)
+
 	// Determine encoder type
 	var encoder zapcore.Encoder
 	if config.JSONOutput {
@@ -62,14 +161,24 @@ func New(config Config) *Logger {
 
 	// Set up output
 	var core zapcore.Core
-	var lokiClient *loki.Client
+	var lokiClient loki.Sink
+	var files *namedFiles
+	var fallbackFile *lokiFallback
+	names := newLoggerNames(config.MaxLoggerNames)
 
-	// Setup cores
+	// Setup cores, named for per-sink failure isolation
 	cores := []zapcore.Core{}
+	sinkNames := []string{}
 
 	// In development environment, always log to stdout
 	if config.Environment == "development" {
 		cores = append(cores, zapcore.NewCore(encoder, zapcore.AddSync(os.Stdout), parseLevel(config.Level)))
+		sinkNames = append(sinkNames, "stdout")
+	} else if config.FilePattern != "" {
+		fileCore := newNamedFileCore(encoder.Clone(), parseLevel(config.Level), config.FilePattern, names)
+		files = fileCore.files
+		cores = append(cores, fileCore)
+		sinkNames = append(sinkNames, "file")
 	} else if config.FilePath != "" {
 		// Use lumberjack for log rotation in non-development environments
 		writer := &lumberjack.Logger{
@@ -80,38 +189,175 @@ func New(config Config) *Logger {
 			Compress:   true,
 		}
 		cores = append(cores, zapcore.NewCore(encoder, zapcore.AddSync(writer), parseLevel(config.Level)))
+		sinkNames = append(sinkNames, "file")
 	} else {
 		// Fallback to stdout for any environment if no file path specified
 		cores = append(cores, zapcore.NewCore(encoder, zapcore.AddSync(os.Stdout), parseLevel(config.Level)))
+		sinkNames = append(sinkNames, "stdout")
 	}
 
 	// Set up Loki client if enabled
-	if config.Loki != nil && config.Loki.Enabled && config.Loki.URL != "" {
-		lokiClient = loki.NewClient(loki.Config{
+	if config.Loki != nil && config.Loki.Enabled && config.Loki.FallbackPath != "" {
+		fallbackFile = newLokiFallback(config.Loki.FallbackPath)
+	}
+	if config.Loki != nil && config.Loki.Enabled && config.Loki.Client != nil {
+		lokiClient = config.Loki.Client
+	} else if config.Loki != nil && config.Loki.Enabled && config.Loki.URL != "" {
+		// loki client internal messages only go to the non-loki cores
+		internal := zap.New(zapcore.NewTee(cores...))
+
+		// entries of failed pushes go to the fallback, healthy or not
+		onDrop := config.Loki.OnDrop
+		if fallbackFile != nil {
+			fallbackFile.onDrop = true
+			onDrop = func(e loki.DroppedEntry) {
+				fallbackFile.drop(e)
+				if config.Loki.OnDrop != nil {
+					config.Loki.OnDrop(e)
+				}
+			}
+		}
+
+		client, err := loki.NewClientE(loki.Config{
 			URL:       config.Loki.URL,
 			BatchSize: config.Loki.BatchSize,
 			BatchWait: config.Loki.BatchWait,
-			Labels:    config.Loki.Labels,
+			Labels:    lokiLabels(config.Loki.Labels),
+
+			Username:    config.Loki.Username,
+			Password:    config.Loki.Password,
+			PasswordEnv: config.Loki.PasswordEnv,
+			TenantID:    config.Loki.TenantID,
+			Headers:     config.Loki.Headers,
+
+			AllowedLabels: config.Loki.AllowedLabels,
+
+			AutoHostLabels:    config.Loki.AutoHostLabels,
+			ExcludeHostLabels: config.Loki.ExcludeHostLabels,
+			ValidateOnStart:   config.Loki.ValidateOnStart,
+			StrictStart:       config.Loki.StrictStart,
+			Logger:            &lokiInternalLogger{log: internal},
+
+			SecondaryURL:      config.Loki.SecondaryURL,
+			SecondaryUsername: config.Loki.SecondaryUsername,
+			SecondaryPassword: config.Loki.SecondaryPassword,
+			SecondaryTenantID: config.Loki.SecondaryTenantID,
+			MirrorSampleRate:  config.Loki.MirrorSampleRate,
+
+			MaxRetries: config.Loki.MaxRetries,
+			MinBackoff: config.Loki.MinBackoff,
+			MaxBackoff: config.Loki.MaxBackoff,
+
+			MaxRetryAfter:    config.Loki.MaxRetryAfter,
+			MaxRetryDuration: config.Loki.MaxRetryDuration,
+
+			StopTimeout: config.Loki.StopTimeout,
+			OnError:     config.Loki.OnError,
+			OnDrop:      onDrop,
 		})
+		if err != nil {
+			return nil, fmt.Errorf("logger: loki: %w", err)
+		}
+		lokiClient = client
+	}
 
-		// Create a custom core that writes to both the primary core and Loki
-		cores = append(cores, zapcore.NewCore(
-			encoder,
-			zapcore.AddSync(&lokiWriter{client: lokiClient}),
-			parseLevel(config.Level),
-		))
+	// Create a custom core that writes to both the primary core and Loki
+	if lokiClient != nil {
+		level := config.Level
+		if config.Loki.Level != "" {
+			level = config.Loki.Level
+		}
+		lc := &lokiCore{
+			LevelEnabler: parseLevel(level),
+			enc:          encoder.Clone(),
+			client:       lokiClient,
+			fallback:     fallbackFile,
+		}
+		if config.Loki.NameLabel {
+			lc.names = names
+		}
+		if config.Loki.PackageLabel {
+			lc.packages = newLoggerNames(config.MaxLoggerNames)
+		}
+		lc.callerMetadata = config.Loki.CallerMetadata
+		cores = append(cores, lc)
+		sinkNames = append(sinkNames, "loki")
+	}
+
+	// strip frames of every core individually to keep level of each core
+	if config.TrimStacktrace && !config.DisableStacktrace {
+		for i := range cores {
+			cores[i] = &stackTrimCore{Core: cores[i], depth: config.StacktraceDepth}
+		}
 	}
 
+	// a failing sink never drops the entry of the other sinks
+	sinks := newSinkStats()
+	for i := range cores {
+		var fallback zapcore.Core
+		if config.SinkFallbackAfter > 0 {
+			fallback = zapcore.NewCore(encoder.Clone(), zapcore.Lock(os.Stderr), parseLevel(config.Level))
+		}
+		cores[i] = newIsolatedCore(sinkNames[i], cores[i], fallback, config.SinkFallbackAfter, sinks)
+	}
+
+	// recent entries ring buffer, entries of every level are kept regardless of the level of each sink
+	cores = append(cores, newRecentCore(recent, zapcore.DebugLevel))
+
 	// Combine cores
 	core = zapcore.NewTee(cores...)
 
 	// Create logger
-	zapLogger := zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
+	options := []zap.Option{zap.AddCaller()}
+	if config.Clock != nil {
+		options = append(options, zap.WithClock(zapClock{config.Clock}))
+	}
+	if !config.DisableStacktrace {
+		options = append(options, zap.AddStacktrace(parseStacktraceLevel(config.StacktraceLevel)))
+	}
+
+	// default fields from registered service metadata
+	if fields := serviceFields(); len(fields) > 0 {
+		options = append(options, zap.Fields(fields...))
+	}
+
+	zapLogger := zap.New(core, options...)
 
 	return &Logger{
 		Logger:     zapLogger,
 		lokiClient: lokiClient,
+		files:      files,
+		sinks:      sinks,
+
+		lokiFallback: fallbackFile,
+	}, nil
+}
+
+// serviceFields fields of factory.ServiceInfo, empty when not registered
+func serviceFields() []zap.Field {
+	labels := factory.GetServiceInfo().Labels()
+	fields := make([]zap.Field, 0, len(labels))
+	for _, k := range []string{"service", "team", "tier", "environment"} {
+		if v, ok := labels[k]; ok {
+			fields = append(fields, zap.String(k, v))
+		}
+	}
+
+	return fields
+}
+
+// lokiLabels labels of factory.ServiceInfo overridden by configured labels
+func lokiLabels(configured map[string]string) map[string]string {
+	labels := factory.GetServiceInfo().Labels()
+	if len(labels) < 1 {
+		return configured
+	}
+
+	for k, v := range configured {
+		labels[k] = v
 	}
+
+	return labels
 }
 
 // Default creates a default logger
@@ -128,15 +374,53 @@ func (l *Logger) With(fields ...zapcore.Field) *Logger {
 	return &Logger{
 		Logger:     l.Logger.With(fields...),
 		lokiClient: l.lokiClient,
+		files:      l.files,
+		sinks:      l.sinks,
+
+		lokiFallback: l.lokiFallback,
 	}
 }
 
-// Named returns a new Logger with the given name
+// Named returns a new Logger with the given name, the name becomes the "logger" loki label with
+// LokiConfig.NameLabel and selects the file with Config.FilePattern
 func (l *Logger) Named(name string) *Logger {
 	return &Logger{
 		Logger:     l.Logger.Named(name),
 		lokiClient: l.lokiClient,
+		files:      l.files,
+		sinks:      l.sinks,
+
+		lokiFallback: l.lokiFallback,
+	}
+}
+
+// SinkErrors number of write failures per sink, e.g. "file", "stdout" or "loki"
+func (l *Logger) SinkErrors() map[string]uint64 {
+	if l.sinks == nil {
+		return map[string]uint64{}
+	}
+
+	return l.sinks.snapshot()
+}
+
+// LokiFallbackWritten number of entries written to LokiConfig.FallbackPath, see LokiConfig.FallbackPath
+func (l *Logger) LokiFallbackWritten() uint64 {
+	if l.lokiFallback == nil {
+		return 0
 	}
+
+	return l.lokiFallback.written.Load()
+}
+
+// LokiStatus configuration and state of the loki client for diagnostics, false without loki
+// or when the client does not describe itself, see loki.Client.Describe
+func (l *Logger) LokiStatus() (loki.Description, bool) {
+	ds, ok := l.lokiClient.(loki.DescribedSink)
+	if !ok {
+		return loki.Description{}, false
+	}
+
+	return ds.Describe(), true
 }
 
 // Sync flushes any buffered log entries
@@ -149,6 +433,12 @@ func (l *Logger) Close() error {
 	if l.lokiClient != nil {
 		l.lokiClient.Stop()
 	}
+	if l.files != nil {
+		_ = l.files.close()
+	}
+	if l.lokiFallback != nil {
+		_ = l.lokiFallback.close()
+	}
 	return l.Sync()
 }
 
@@ -163,6 +453,8 @@ func parseLevel(level string) zapcore.Level {
 		return zapcore.WarnLevel
 	case "error":
 		return zapcore.ErrorLevel
+	case "panic":
+		return zapcore.PanicLevel
 	case "fatal":
 		return zapcore.FatalLevel
 	default:
@@ -170,41 +462,119 @@ func parseLevel(level string) zapcore.Level {
 	}
 }
 
-// lokiWriter implements zapcore.WriteSyncer for Loki
-type lokiWriter struct {
-	client *loki.Client
-}
-
-func (w *lokiWriter) Write(p []byte) (n int, err error) {
-	// Extract level from the log message (this is a simple approach)
-	// In a real implementation, you might want to parse the JSON log
-	level := "info"
-	if len(p) > 0 {
-		lowered := string(p)
-		switch {
-		case contains(lowered, "debug"):
-			level = "debug"
-		case contains(lowered, "info"):
-			level = "info"
-		case contains(lowered, "warn"):
-			level = "warn"
-		case contains(lowered, "error"):
-			level = "error"
-		case contains(lowered, "fatal"):
-			level = "fatal"
+// parseStacktraceLevel empty or unknown level falls back to error, so a typo does not attach
+// stack trace to every info line
+func parseStacktraceLevel(level string) zapcore.Level {
+	if lvl, err := zapcore.ParseLevel(level); err == nil && level != "" {
+		return lvl
+	}
+
+	return zapcore.ErrorLevel
+}
+
+// lokiCore zapcore.Core shipping encoded entries to Loki with the level of the entry
+type lokiCore struct {
+	zapcore.LevelEnabler
+	enc    zapcore.Encoder
+	client loki.Sink
+
+	// names resolve logger name label, nil when LokiConfig.NameLabel is disabled
+	names *loggerNames
+
+	// fallback nil when LokiConfig.FallbackPath is not set
+	fallback *lokiFallback
+
+	// packages resolve package label, nil when LokiConfig.PackageLabel is disabled
+	packages       *loggerNames
+	callerMetadata bool
+}
+
+func (c *lokiCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.enc = c.enc.Clone()
+	for _, f := range fields {
+		f.AddTo(clone.enc)
+	}
+
+	return &clone
+}
+
+func (c *lokiCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+
+	return ce
+}
+
+func (c *lokiCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	// drop loki client internal messages to avoid feeding them back into the queue
+	for _, f := range fields {
+		if f.Key == loki.InternalField {
+			return nil
 		}
 	}
 
-	w.client.Log(time.Now(), level, string(p))
-	return len(p), nil
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	line := strings.TrimSuffix(buf.String(), "\n")
+	if c.fallback != nil {
+		c.fallback.write(c.client, line)
+	}
+
+	labels, metadata := c.entryLabels(ent)
+	if ms, ok := c.client.(loki.MetadataSink); ok && metadata != nil {
+		ms.LogWithMetadata(ent.Time, ent.Level.String(), line, labels, metadata)
+		return nil
+	}
+	if ls, ok := c.client.(loki.LabeledSink); ok && labels != nil {
+		ls.LogWithLabels(ent.Time, ent.Level.String(), line, labels)
+		return nil
+	}
+
+	c.client.Log(ent.Time, ent.Level.String(), line)
+	return nil
+}
+
+// entryLabels logger name and package labels and caller metadata of entry, nil when none applies
+func (c *lokiCore) entryLabels(ent zapcore.Entry) (labels, metadata map[string]string) {
+	if c.names != nil && ent.LoggerName != "" {
+		labels = map[string]string{LoggerNameLabel: c.names.resolve(ent.LoggerName)}
+	}
+	if !ent.Caller.Defined {
+		return labels, nil
+	}
+
+	if c.packages != nil {
+		if labels == nil {
+			labels = make(map[string]string, 1)
+		}
+		labels[PackageLabel] = c.packages.resolve(path.Dir(trimCallerPath(ent.Caller.File)))
+	}
+	if c.callerMetadata {
+		metadata = map[string]string{CallerMetadata: formatCaller(ent.Caller.File, ent.Caller.Line)}
+	}
+
+	return labels, metadata
 }
 
-func (w *lokiWriter) Sync() error {
+func (c *lokiCore) Sync() error {
 	// Sync is a no-op for Loki client
 	return nil
 }
 
-// Helper function to check if a string contains a substring
-func contains(s, substr string) bool {
-	return strings.Contains(s, substr)
+// lokiInternalLogger report loki client internal messages through zap,
+// tagged with loki.InternalField so lokiCore never ships them back to loki
+type lokiInternalLogger struct {
+	log *zap.Logger
+}
+
+func (l *lokiInternalLogger) Logf(level, format string, args ...interface{}) {
+	if ce := l.log.Check(parseLevel(level), fmt.Sprintf(format, args...)); ce != nil {
+		ce.Write(zap.Bool(loki.InternalField, true))
+	}
 }
@@ -0,0 +1,73 @@
+package logger
+
+import (
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// trimmedStackPrefixes frames of these packages are stripped from stack trace
+var trimmedStackPrefixes = []string{
+	"github.com/TixiaOTA/gokit/",
+	"go.uber.org/zap",
+}
+
+// stackTrimCore strip gokit and zap frames from stack trace of entry and cap its depth
+type stackTrimCore struct {
+	zapcore.Core
+	depth int
+}
+
+func (c *stackTrimCore) With(fields []zapcore.Field) zapcore.Core {
+	return &stackTrimCore{Core: c.Core.With(fields), depth: c.depth}
+}
+
+func (c *stackTrimCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+
+	return ce
+}
+
+func (c *stackTrimCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ent.Stack != "" {
+		ent.Stack = trimStack(ent.Stack, c.depth, trimmedStackPrefixes...)
+	}
+
+	return c.Core.Write(ent, fields)
+}
+
+// trimStack filter zap formatted stack, each frame is a function line followed by a tab-indented file line,
+// frames of function with one of prefixes are dropped and at most depth frames are kept, zero means unlimited
+func trimStack(stack string, depth int, prefixes ...string) string {
+	lines := strings.Split(stack, "\n")
+	kept := make([]string, 0, len(lines))
+	frames := 0
+
+	for i := 0; i+1 < len(lines); i += 2 {
+		fn, file := lines[i], lines[i+1]
+		if trimmedFrame(fn, prefixes) {
+			continue
+		}
+
+		if depth > 0 && frames >= depth {
+			break
+		}
+
+		kept = append(kept, fn, file)
+		frames++
+	}
+
+	return strings.Join(kept, "\n")
+}
+
+func trimmedFrame(fn string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(fn, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/TixiaOTA/gokit/loki"
+	"github.com/natefinch/lumberjack"
+)
+
+// lokiFallback file receiving entries shipped to loki while the client is unhealthy,
+// entries are still handed to the client so nothing is lost whichever side recovers first.
+// with a client created from LokiConfig.URL the file receives the entries the client drops instead,
+// including the failed batches pushed before the client reports unhealthy
+type lokiFallback struct {
+	mu      sync.Mutex
+	writer  *lumberjack.Logger
+	written atomic.Uint64
+
+	// dropped entries are written by drop, write is skipped
+	onDrop bool
+}
+
+func newLokiFallback(path string) *lokiFallback {
+	return &lokiFallback{
+		writer: &lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    100, // MB
+			MaxBackups: 5,
+			MaxAge:     30, // days
+			Compress:   true,
+		},
+	}
+}
+
+// write line when client reports unhealthy, see loki.HealthySink
+func (f *lokiFallback) write(client loki.Sink, line string) {
+	if f.onDrop {
+		return
+	}
+
+	hs, ok := client.(loki.HealthySink)
+	if !ok || hs.Healthy() {
+		return
+	}
+
+	f.append(line)
+}
+
+// drop write entry lost by the client, see loki.Config.OnDrop
+func (f *lokiFallback) drop(e loki.DroppedEntry) {
+	f.append(e.Message)
+}
+
+func (f *lokiFallback) append(line string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.writer.Write([]byte(line + "\n")); err == nil {
+		f.written.Add(1)
+	}
+}
+
+func (f *lokiFallback) close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.writer.Close()
+}
@@ -2,12 +2,16 @@ package logger
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Locker is container data
 type Locker struct {
-	data sync.Map
+	data      sync.Map
+	diag      lockerDiagnostics
+	autoFlush atomic.Pointer[autoFlush]
+	fieldsMu  sync.Mutex // serializes SetFields of the request so concurrent merges are never lost
 }
 
 type (
@@ -27,50 +31,84 @@ const (
 	cron ServiceType = "cron"
 
 	// Flags for key of struct
-	_StatusCode   Flags = "StatusCode"
-	_Response     Flags = "Response"
-	_LogMessages  Flags = "LogMessages"
-	_ThirdParties Flags = "ThirdParties"
-	_ErrorMessage Flags = "ErrorMessage"
-	_UserCode     Flags = "UserCode"
-	_Device       Flags = "Device"
-	RequestId     Flags = "RequestId"
-	_SaltKey      Flags = "SaltKey"
+	_StatusCode    Flags = "StatusCode"
+	_Response      Flags = "Response"
+	_LogMessages   Flags = "LogMessages"
+	_ThirdParties  Flags = "ThirdParties"
+	_ErrorMessage  Flags = "ErrorMessage"
+	_UserCode      Flags = "UserCode"
+	_Device        Flags = "Device"
+	RequestId      Flags = "RequestId"
+	_SaltKey       Flags = "SaltKey"
+	_StackCaptured Flags = "StackCaptured"
+	_SaltKeys      Flags = "SaltKeys"
+	_Metadata      Flags = "Metadata"
 
 	// list type of logger
 	debug   = "DEBUG"
 	print   = "PRINT"
+	warn    = "WARN"
 	err     = "ERROR"
 	success = "Success request"
 )
 
 // DataLogger is standard output to terminal
 type DataLogger struct {
-	RequestId     string       `json:"request_id"`
-	UserCode      string       `json:"user_code"`
-	Device        string       `json:"device"`
-	Ip            string       `json:"ip"`
-	Type          ServiceType  `json:"type"`
-	TimeStart     time.Time    `json:"time_start"`
-	Service       string       `json:"service"`
-	Host          string       `json:"host"`
-	Endpoint      string       `json:"endpoint"`
-	RequestMethod string       `json:"request_method"`
-	RequestHeader string       `json:"request_header"`
-	RequestBody   string       `json:"request_body"`
-	StatusCode    int          `json:"status_code"`
-	Response      interface{}  `json:"response"`
-	ErrorMessage  string       `json:"error_message"`
-	ExecTime      float64      `json:"exec_time"`
-	LogMessages   []LogMessage `json:"log_message"`
-	ThirdParties  []ThirdParty `json:"outgoing_log"`
+	RequestId     string            `json:"request_id"`
+	UserCode      string            `json:"user_code"`
+	Device        string            `json:"device"`
+	Ip            string            `json:"ip"`
+	Type          ServiceType       `json:"type"`
+	TimeStart     time.Time         `json:"time_start"`
+	Service       string            `json:"service"`
+	Host          string            `json:"host"`
+	Endpoint      string            `json:"endpoint"`
+	RequestMethod string            `json:"request_method"`
+	RequestHeader string            `json:"request_header"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	Baggage       map[string]string `json:"baggage,omitempty"` // see SetBaggageFields
+	RequestBody   string            `json:"request_body"`
+	StatusCode    int               `json:"status_code"`
+	Response      interface{}       `json:"response"`
+	ErrorMessage  string            `json:"error_message"`
+	ExecTime      float64           `json:"exec_time"`
+	LogMessages   []LogMessage      `json:"log_message"`
+	ThirdParties  []ThirdParty      `json:"outgoing_log"`
+	Sampled       bool              `json:"sampled,omitempty"` // kept by access log sampling, re-weight counts by the sample rate
+	Message       *MessageLog       `json:"message,omitempty"` // consumed broker message, set by broker workers
+
+	// discarded skip writing on Finalize, see Discard
+	discarded bool
+}
+
+// outcomes of a consumed broker message
+const (
+	OutcomeAck   = "ack"   // handled, removed from the queue
+	OutcomeNack  = "nack"  // left or requeued for redelivery
+	OutcomeRetry = "retry" // acked once its delayed copy is scheduled on a retry queue
+	OutcomeDLQ   = "dlq"   // rejected to the dead letter queue
+)
+
+// MessageLog consumed broker message of a data logger
+type MessageLog struct {
+	Queue     string `json:"queue"`
+	MessageId string `json:"message_id"`
+	Outcome   string `json:"outcome"`
 }
 
 // LogMessage is data logging for developer want to debug or error
 type LogMessage struct {
-	File    string `json:"file"`
-	Level   string `json:"level"`
-	Message string `json:"message"`
+	File    string   `json:"file"`
+	Level   string   `json:"level"`
+	Message string   `json:"message"`
+	Stack   string   `json:"stack,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	// Fields structured fields of SetFields and the KV methods, e.g. ErrorKV
+	Fields   map[string]interface{} `json:"fields,omitempty"`
+	WorkerId string                 `json:"worker_id,omitempty"`
+
+	// Timestamp time of message from the clock of SetClock
+	Timestamp time.Time `json:"timestamp"`
 }
 
 // ThirdParty is data logging for any request to third party
@@ -0,0 +1,157 @@
+package logger
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/natefinch/lumberjack"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// LoggerNameLabel loki label carrying the name of Named logger
+	LoggerNameLabel = "logger"
+	// CallerMetadata loki structured metadata carrying file and line of caller, see LokiConfig.CallerMetadata
+	CallerMetadata = "caller"
+	// PackageLabel loki label carrying directory of caller, see LokiConfig.PackageLabel
+	PackageLabel = "package"
+	// FilePatternName placeholder of Config.FilePattern replaced by the name of Named logger
+	FilePatternName = "{logger}"
+
+	// defaultLoggerName name of entries logged by the unnamed logger
+	defaultLoggerName = "default"
+	// overflowLoggerName name of entries of new names beyond Config.MaxLoggerNames
+	overflowLoggerName = "other"
+	// defaultMaxLoggerNames default Config.MaxLoggerNames
+	defaultMaxLoggerNames = 20
+)
+
+// loggerNames bound distinct logger names used as label value or file name,
+// names beyond max share the overflow name
+type loggerNames struct {
+	mu   sync.Mutex
+	max  int
+	seen map[string]struct{}
+}
+
+func newLoggerNames(max int) *loggerNames {
+	if max <= 0 {
+		max = defaultMaxLoggerNames
+	}
+
+	return &loggerNames{max: max, seen: make(map[string]struct{})}
+}
+
+// resolve name of entry, empty name resolves to the default name
+func (n *loggerNames) resolve(name string) string {
+	if name == "" {
+		return defaultLoggerName
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, ok := n.seen[name]; ok {
+		return name
+	}
+
+	if len(n.seen) >= n.max {
+		return overflowLoggerName
+	}
+
+	n.seen[name] = struct{}{}
+	return name
+}
+
+// namedFiles lumberjack writer per logger name of Config.FilePattern
+type namedFiles struct {
+	mu      sync.Mutex
+	pattern string
+	names   *loggerNames
+	writers map[string]*lumberjack.Logger
+}
+
+func (f *namedFiles) writer(name string) *lumberjack.Logger {
+	name = f.names.resolve(name)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if w, ok := f.writers[name]; ok {
+		return w
+	}
+
+	// keep nested names of Named, e.g. "order.repo", within the pattern directory
+	filename := strings.ReplaceAll(f.pattern, FilePatternName, strings.ReplaceAll(name, "/", "_"))
+	w := &lumberjack.Logger{
+		Filename:   filename,
+		MaxSize:    100, // MB
+		MaxBackups: 5,
+		MaxAge:     30, // days
+		Compress:   true,
+	}
+	f.writers[name] = w
+
+	return w
+}
+
+func (f *namedFiles) close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, w := range f.writers {
+		if err := w.Close(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// namedFileCore zapcore.Core routing entries into the file of their logger name
+type namedFileCore struct {
+	zapcore.LevelEnabler
+	enc   zapcore.Encoder
+	files *namedFiles
+}
+
+func newNamedFileCore(enc zapcore.Encoder, level zapcore.LevelEnabler, pattern string, names *loggerNames) *namedFileCore {
+	return &namedFileCore{
+		LevelEnabler: level,
+		enc:          enc,
+		files:        &namedFiles{pattern: pattern, names: names, writers: make(map[string]*lumberjack.Logger)},
+	}
+}
+
+func (c *namedFileCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &namedFileCore{LevelEnabler: c.LevelEnabler, enc: c.enc.Clone(), files: c.files}
+	for _, f := range fields {
+		f.AddTo(clone.enc)
+	}
+
+	return clone
+}
+
+func (c *namedFileCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+
+	return ce
+}
+
+func (c *namedFileCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	_, err = c.files.writer(ent.LoggerName).Write(buf.Bytes())
+	return err
+}
+
+func (c *namedFileCore) Sync() error {
+	// lumberjack writes straight to the file, nothing is buffered
+	return nil
+}
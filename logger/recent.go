@@ -0,0 +1,174 @@
+package logger
+
+import (
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// recentCapacity entries kept by the recent entries ring buffer
+	recentCapacity = 500
+
+	// maxRecentMessage longer messages and fields are truncated to cap memory of the ring buffer
+	maxRecentMessage = 1024
+)
+
+// RecentEntry log entry kept by the recent entries ring buffer, see RecentEntries
+type RecentEntry struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Logger  string    `json:"logger,omitempty"`
+	Caller  string    `json:"caller,omitempty"`
+	Message string    `json:"message"`
+	Fields  string    `json:"fields,omitempty"` // json object of fields, e.g. {"error":"connection refused"}
+}
+
+// String single line form of entry, e.g. for crash reports
+func (e RecentEntry) String() string {
+	s := e.Time.Format(time.RFC3339Nano) + " " + e.Level
+	if e.Logger != "" {
+		s += " " + e.Logger
+	}
+	if e.Caller != "" {
+		s += " " + e.Caller
+	}
+
+	s += " " + e.Message
+	if e.Fields != "" {
+		s += " " + e.Fields
+	}
+
+	return s
+}
+
+// recentRing fixed size ring buffer of entries, the oldest entry is evicted once full
+type recentRing struct {
+	mu      sync.Mutex
+	entries []RecentEntry
+	next    int
+	full    bool
+}
+
+// recent process-wide ring buffer filled by every logger of New
+var recent = newRecentRing(recentCapacity)
+
+func newRecentRing(capacity int) *recentRing {
+	return &recentRing{entries: make([]RecentEntry, capacity)}
+}
+
+func (r *recentRing) add(e RecentEntry) {
+	r.mu.Lock()
+	r.entries[r.next] = e
+	r.next++
+	if r.next == len(r.entries) {
+		r.next = 0
+		r.full = true
+	}
+	r.mu.Unlock()
+}
+
+// snapshot entries oldest first
+func (r *recentRing) snapshot() []RecentEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.full {
+		return append([]RecentEntry(nil), r.entries[:r.next]...)
+	}
+
+	out := make([]RecentEntry, 0, len(r.entries))
+	out = append(out, r.entries[r.next:]...)
+	return append(out, r.entries[:r.next]...)
+}
+
+// RecentEntries last log entries of every logger of New oldest first, kept regardless of sink levels
+func RecentEntries() []RecentEntry {
+	return recent.snapshot()
+}
+
+// RecentLines RecentEntries as single lines, e.g. for server.WithCrashLogs
+func RecentLines() []string {
+	entries := RecentEntries()
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		lines[i] = e.String()
+	}
+
+	return lines
+}
+
+// recentCore zapcore.Core keeping entries on the ring buffer with their fields, its own enabler
+// is independent of the level of each sink
+type recentCore struct {
+	zapcore.LevelEnabler
+	ring *recentRing
+	enc  zapcore.Encoder // fields only, accumulated by With
+}
+
+func newRecentCore(ring *recentRing, enabler zapcore.LevelEnabler) *recentCore {
+	// empty keys leave entry metadata out of the encoded fields
+	enc := zapcore.NewJSONEncoder(zapcore.EncoderConfig{LineEnding: "\n"})
+	return &recentCore{LevelEnabler: enabler, ring: ring, enc: enc}
+}
+
+func (c *recentCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &recentCore{LevelEnabler: c.LevelEnabler, ring: c.ring, enc: c.enc.Clone()}
+	for _, f := range fields {
+		f.AddTo(clone.enc)
+	}
+
+	return clone
+}
+
+func (c *recentCore) Sync() error { return nil }
+
+func (c *recentCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+
+	return ce
+}
+
+func (c *recentCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	e := RecentEntry{
+		Time:    ent.Time,
+		Level:   ent.Level.String(),
+		Logger:  ent.LoggerName,
+		Message: truncateMessage(ent.Message, maxRecentMessage),
+	}
+	if ent.Caller.Defined {
+		e.Caller = formatCaller(ent.Caller.File, ent.Caller.Line)
+	}
+
+	buf, err := c.enc.EncodeEntry(zapcore.Entry{}, fields)
+	if err != nil {
+		return err
+	}
+	if encoded := strings.TrimSuffix(buf.String(), "\n"); encoded != "{}" {
+		e.Fields = truncateMessage(encoded, maxRecentMessage)
+	}
+	buf.Free()
+
+	c.ring.add(e)
+
+	return nil
+}
+
+// truncateMessage cut msg to at most max bytes on a rune boundary
+func truncateMessage(msg string, max int) string {
+	if len(msg) <= max {
+		return msg
+	}
+
+	cut := max
+	for cut > 0 && !utf8.RuneStart(msg[cut]) {
+		cut--
+	}
+
+	return msg[:cut] + "…"
+}
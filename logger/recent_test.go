@@ -0,0 +1,65 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TixiaOTA/gokit/loki"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRecentRing(t *testing.T) {
+	ring := newRecentRing(3)
+	core := newRecentCore(ring, zapcore.DebugLevel)
+
+	for i := 0; i < 5; i++ {
+		_ = core.Write(zapcore.Entry{Time: time.Now(), Level: zapcore.DebugLevel, Message: fmt.Sprintf("m%d", i)}, nil)
+	}
+
+	entries := ring.snapshot()
+	if len(entries) != 3 || entries[0].Message != "m2" || entries[2].Message != "m4" {
+		t.Fatalf("expected oldest entries evicted first, got %+v", entries)
+	}
+
+	_ = core.Write(zapcore.Entry{Level: zapcore.InfoLevel, Message: strings.Repeat("é", maxRecentMessage)}, nil)
+	last := ring.snapshot()[2].Message
+	if len(last) > maxRecentMessage+len("…") || !strings.HasSuffix(last, "…") {
+		t.Errorf("expected message truncated to %d bytes, got %d", maxRecentMessage, len(last))
+	}
+}
+
+func TestRecentEntriesSinkLevel(t *testing.T) {
+	l := New(Config{Level: "info", Loki: &LokiConfig{Enabled: true, Level: "error", Client: loki.NewCaptureClient()}})
+	l.Debug("recent debug entry")
+	l.Info("recent info entry", zap.Error(errors.New("connection refused")))
+
+	entries := RecentEntries()
+	last := entries[len(entries)-1]
+	if last.Message != "recent info entry" || len(entries) < 2 || entries[len(entries)-2].Message != "recent debug entry" {
+		t.Fatalf("expected debug and info entries kept below sink levels, got %+v", entries)
+	}
+	if last.Fields != `{"error":"connection refused"}` {
+		t.Errorf("expected fields of entry kept, got %q", last.Fields)
+	}
+	if line := RecentLines()[len(entries)-1]; !strings.Contains(line, "info") || !strings.Contains(line, "connection refused") {
+		t.Errorf("expected level and fields on line, got %q", line)
+	}
+}
+
+func TestRecentCoreWith(t *testing.T) {
+	ring := newRecentRing(2)
+	core := newRecentCore(ring, zapcore.InfoLevel).With([]zapcore.Field{zap.String("order_id", "42")})
+
+	if core.Enabled(zapcore.DebugLevel) {
+		t.Error("expected debug disabled below level")
+	}
+
+	_ = core.Write(zapcore.Entry{Level: zapcore.ErrorLevel, Message: "charge failed"}, []zapcore.Field{zap.Int("attempt", 3)})
+	if got := ring.snapshot()[0].Fields; got != `{"order_id":"42","attempt":3}` {
+		t.Errorf("expected accumulated and entry fields, got %q", got)
+	}
+}
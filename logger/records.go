@@ -8,8 +8,8 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
-	"time"
 
+	"github.com/TixiaOTA/gokit/factory"
 	"github.com/TixiaOTA/gokit/utils/timezone"
 	"github.com/google/uuid"
 )
@@ -18,7 +18,7 @@ import (
 func InitializeCron(endpoint string) (context.Context, DataLogger) {
 	var (
 		timezone = timezone.JakartaTz()
-		start    = time.Now().In(timezone)
+		start    = now().In(timezone)
 		lock     = new(Locker)
 		dl       DataLogger
 	)
@@ -42,6 +42,10 @@ func InitializeCron(endpoint string) (context.Context, DataLogger) {
 }
 
 func getServiceName() string {
+	if name := factory.GetServiceInfo().Name; name != "" {
+		return name
+	}
+
 	return filepath.Base(os.Args[0])
 }
 
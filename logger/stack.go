@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/TixiaOTA/gokit/utils/env"
+)
+
+const (
+	// stack trace capture mode from env LOG_ERROR_STACKTRACE
+	stackFirst = "first"
+	stackAll   = "all"
+	stackOff   = "off"
+
+	// maxStackDepth maximum frames captured into stack trace
+	maxStackDepth = 32
+
+	// loggerPackage prefix of logger internal function name
+	loggerPackage = "github.com/TixiaOTA/gokit/logger."
+)
+
+var (
+	// stackMode capture mode of error stack trace, see LOG_ERROR_STACKTRACE
+	stackMode     atomic.Value
+	stackModeOnce sync.Once
+)
+
+// errorStackMode capture mode of error stack trace, LOG_ERROR_STACKTRACE is read once by New or
+// on first use, so it is resolved once config.Load made the config file and env visible
+func errorStackMode() string {
+	stackModeOnce.Do(func() {
+		stackMode.Store(strings.ToLower(env.GetString("LOG_ERROR_STACKTRACE", stackFirst)))
+	})
+
+	return stackMode.Load().(string)
+}
+
+// SetErrorStacktrace capture mode of error stack trace, "first" (default), "all" or "off",
+// default from LOG_ERROR_STACKTRACE env
+func SetErrorStacktrace(mode string) {
+	stackModeOnce.Do(func() {})
+	stackMode.Store(strings.ToLower(mode))
+}
+
+// errorStack capture stack trace for error message according LOG_ERROR_STACKTRACE mode,
+// with mode "first" only the first error on the request capture the stack
+func errorStack(value Values) string {
+	switch errorStackMode() {
+	case stackOff:
+		return ""
+	case stackAll:
+	default:
+		if _, captured := value.Load(_StackCaptured); captured {
+			return ""
+		}
+		value.Set(_StackCaptured, true)
+	}
+
+	return captureStack()
+}
+
+// captureStack capture current stack trace, skipping runtime and logger internal frames, see trimStack
+func captureStack() string {
+	pcs := make([]uintptr, maxStackDepth+8)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var sb strings.Builder
+	for {
+		frame, more := frames.Next()
+		sb.WriteString(fmt.Sprintf("%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line))
+
+		if !more {
+			break
+		}
+	}
+
+	return trimStack(sb.String(), maxStackDepth, loggerPackage)
+}
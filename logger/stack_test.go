@@ -0,0 +1,71 @@
+package logger
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func errorMessages(t *testing.T, n int) []LogMessage {
+	t.Helper()
+
+	ctx := context.WithValue(context.Background(), LogKey, new(Locker))
+	for i := 0; i < n; i++ {
+		Log.Error(ctx, "failed")
+	}
+
+	value, _ := extract(ctx)
+	tmp, _ := value.Load(_LogMessages)
+	return tmp.([]LogMessage)
+}
+
+func TestErrorStack(t *testing.T) {
+	defer SetErrorStacktrace(stackFirst)
+
+	for mode, want := range map[string][]bool{
+		stackFirst: {true, false},
+		stackAll:   {true, true},
+		stackOff:   {false, false},
+	} {
+		SetErrorStacktrace(mode)
+
+		messages := errorMessages(t, 2)
+		for i, message := range messages {
+			if got := message.Stack != ""; got != want[i] {
+				t.Errorf("mode %s: expected stack on error %d %v, got %q", mode, i, want[i], message.Stack)
+			}
+		}
+	}
+}
+
+func TestErrorStackTrimmed(t *testing.T) {
+	SetErrorStacktrace(stackFirst)
+
+	// the test itself is a logger frame too
+	stack := errorMessages(t, 1)[0].Stack
+	if !strings.HasPrefix(stack, "testing.tRunner\n") || strings.Contains(stack, loggerPackage) {
+		t.Errorf("expected logger frames trimmed, got\n%s", stack)
+	}
+}
+
+func TestErrorStackResolvedOnce(t *testing.T) {
+	// config.Load runs after package init
+	stackModeOnce = sync.Once{}
+	viper.Set("LOG_ERROR_STACKTRACE", "OFF")
+	defer func() {
+		viper.Set("LOG_ERROR_STACKTRACE", nil)
+		SetErrorStacktrace(stackFirst)
+	}()
+
+	if mode := errorStackMode(); mode != stackOff {
+		t.Fatalf("expected mode from LOG_ERROR_STACKTRACE, got %s", mode)
+	}
+
+	viper.Set("LOG_ERROR_STACKTRACE", stackAll)
+	if mode := errorStackMode(); mode != stackOff {
+		t.Errorf("expected mode resolved once, got %s", mode)
+	}
+}
@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"io"
+	"log"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// stdPrefix date and time prefix of stdlib log, e.g. "2006/01/02 15:04:05.000000 "
+var stdPrefix = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} )?(\d{2}:\d{2}:\d{2}(\.\d+)? )?`)
+
+// WriterOption setter of stdlib writer adapter
+type WriterOption func(*stdWriter)
+
+// SingleEntry write one entry per Write instead of one entry per line
+func SingleEntry() WriterOption {
+	return func(w *stdWriter) {
+		w.single = true
+	}
+}
+
+// stdWriter io.Writer routing writes through zap at fixed level
+type stdWriter struct {
+	log    *zap.Logger
+	level  zapcore.Level
+	single bool
+}
+
+func (w *stdWriter) Write(p []byte) (int, error) {
+	text := strings.TrimRight(string(p), "\n")
+	if w.single {
+		w.write(text)
+		return len(p), nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) != "" {
+			w.write(line)
+		}
+	}
+
+	return len(p), nil
+}
+
+func (w *stdWriter) write(msg string) {
+	if ce := w.log.Check(w.level, stdPrefix.ReplaceAllString(msg, "")); ce != nil {
+		ce.Write()
+	}
+}
+
+// Writer io.Writer adapter logging every line at level, tagged with source="stdlog"
+func (l *Logger) Writer(level string, opts ...WriterOption) io.Writer {
+	w := &stdWriter{
+		log:   l.Logger.WithOptions(zap.WithCaller(false)).With(zap.String("source", "stdlog")),
+		level: parseLevel(level),
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// StdLogger stdlib *log.Logger adapter logging at level, e.g. for http.Server.ErrorLog
+func (l *Logger) StdLogger(level string, opts ...WriterOption) *log.Logger {
+	return log.New(l.Writer(level, opts...), "", 0)
+}
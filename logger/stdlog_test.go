@@ -0,0 +1,48 @@
+package logger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestStdLoggerHTTPServer(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := &Logger{Logger: zap.New(core)}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	srv.Config.ErrorLog = l.StdLogger("error", SingleEntry())
+	srv.Start()
+	defer srv.Close()
+
+	_, _ = http.Get(srv.URL)
+
+	entries := logs.FilterMessageSnippet("panic serving").AllUntimed()
+	if len(entries) != 1 {
+		t.Fatalf("expected one structured entry, got %d", logs.Len())
+	}
+
+	e := entries[0]
+	if e.Level != zapcore.ErrorLevel || e.ContextMap()["source"] != "stdlog" || !strings.HasPrefix(e.Message, "http: panic serving") {
+		t.Errorf("unexpected entry %v %v %q", e.Level, e.ContextMap(), e.Message)
+	}
+}
+
+func TestWriterSplitLines(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := &Logger{Logger: zap.New(core)}
+
+	_, _ = l.Writer("warn").Write([]byte("2024/01/02 15:04:05 first\nsecond\n"))
+
+	entries := logs.AllUntimed()
+	if len(entries) != 2 || entries[0].Message != "first" || entries[1].Message != "second" || entries[0].Level != zapcore.WarnLevel {
+		t.Errorf("expected one warn entry per line without stdlib prefix, got %+v", entries)
+	}
+}
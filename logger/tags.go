@@ -0,0 +1,135 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// tagsKey context key of tags applied to subsequent log messages
+type tagsKey struct{}
+
+// maxInternedTags tags kept by intern, tags beyond are used as is so dynamic tags cannot grow the pool
+const maxInternedTags = 1024
+
+// internedTags pool of tag strings, repeated tags share the same backing string
+var (
+	internedTags     sync.Map
+	internedTagCount atomic.Int64
+)
+
+func intern(tag string) string {
+	if v, ok := internedTags.Load(tag); ok {
+		return v.(string)
+	}
+
+	if internedTagCount.Load() >= maxInternedTags {
+		return tag
+	}
+
+	v, loaded := internedTags.LoadOrStore(tag, tag)
+	if !loaded {
+		internedTagCount.Add(1)
+	}
+	return v.(string)
+}
+
+// WithTags return context applying tags to every subsequent log message logged with it
+func WithTags(ctx context.Context, tags ...string) context.Context {
+	merged := mergeTags(contextTags(ctx), tags)
+	return context.WithValue(ctx, tagsKey{}, merged)
+}
+
+// contextTags tags of context, shared slice must not be mutated
+func contextTags(ctx context.Context) []string {
+	if ctx == nil {
+		return nil
+	}
+
+	tags, _ := ctx.Value(tagsKey{}).([]string)
+	return tags
+}
+
+// mergeTags merge tags without duplicate, base is returned as is when there is nothing to add
+func mergeTags(base, tags []string) []string {
+	merged := base
+
+next:
+	for _, t := range tags {
+		for _, m := range merged {
+			if m == t {
+				continue next
+			}
+		}
+
+		// copy base on the first new tag only, base is shared by the context
+		if len(merged) == len(base) {
+			merged = make([]string, len(base), len(base)+len(tags))
+			copy(merged, base)
+		}
+		merged = append(merged, intern(t))
+	}
+
+	return merged
+}
+
+// ErrorT log error message with tags in addition to context tags
+func (l *logger) ErrorT(ctx context.Context, tags []string, args ...interface{}) {
+	l.appendMessage(ctx, err, 1, fmt.Sprint(args...), tags, nil)
+}
+
+// PrintT log print message with tags in addition to context tags
+func (l *logger) PrintT(ctx context.Context, tags []string, args ...interface{}) {
+	l.appendMessage(ctx, print, 1, fmt.Sprint(args...), tags, nil)
+}
+
+// MessageFilter predicate of log message kept on flush
+type MessageFilter func(LogMessage) bool
+
+// HasTags keep messages having any of tags
+func HasTags(tags ...string) MessageFilter {
+	return func(m LogMessage) bool {
+		for _, t := range m.Tags {
+			for _, want := range tags {
+				if t == want {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+}
+
+// DropTags drop messages having any of tags, e.g. chatty tags in production
+func DropTags(tags ...string) MessageFilter {
+	has := HasTags(tags...)
+	return func(m LogMessage) bool {
+		return !has(m)
+	}
+}
+
+// filterMessages messages kept by filter, nil filter keeps all
+func filterMessages(messages []LogMessage, filter MessageFilter) []LogMessage {
+	if filter == nil {
+		return messages
+	}
+
+	kept := make([]LogMessage, 0, len(messages))
+	for _, m := range messages {
+		if filter(m) {
+			kept = append(kept, m)
+		}
+	}
+
+	return kept
+}
+
+// MarshalFiltered marshal data logger into json with only messages kept by filter
+func (d *DataLogger) MarshalFiltered(filter MessageFilter) ([]byte, error) {
+	cp := *d
+	cp.LogMessages = filterMessages(d.LogMessages, filter)
+	return json.Marshal(cp)
+}
@@ -0,0 +1,95 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestTags(t *testing.T) {
+	ctx := context.WithValue(context.Background(), LogKey, new(Locker))
+
+	Log.Print(ctx, "untagged")
+	billing := WithTags(ctx, "billing")
+	Log.Print(billing, "charge created")
+	Log.ErrorT(billing, []string{"gateway"}, "gateway timeout")
+	Log.PrintT(ctx, []string{"chatty"}, "cache hit")
+
+	value, _ := extract(ctx)
+	tmp, _ := value.Load(_LogMessages)
+	messages := tmp.([]LogMessage)
+
+	d := &DataLogger{LogMessages: messages}
+	raw, err := d.MarshalFiltered(HasTags("billing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out DataLogger
+	_ = json.Unmarshal(raw, &out)
+	if len(out.LogMessages) != 2 || out.LogMessages[1].Message != "gateway timeout" {
+		t.Fatalf("expected only billing messages, got %+v", out.LogMessages)
+	}
+
+	if tags := out.LogMessages[1].Tags; len(tags) != 2 || tags[0] != "billing" || tags[1] != "gateway" {
+		t.Errorf("expected context and explicit tags merged, got %v", tags)
+	}
+
+	if kept := filterMessages(messages, DropTags("chatty")); len(kept) != 3 {
+		t.Errorf("expected chatty message dropped, got %+v", kept)
+	}
+
+	if messages[3].File != "logger/tags_test.go:19" {
+		t.Errorf("expected caller of tagged method, got %s", messages[3].File)
+	}
+}
+
+func TestTaggedWithoutContextLogger(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+
+	Log.PrintT(nil, []string{"payment"}, "tagged")
+	Log.Print(nil, "untagged")
+
+	os.Stdout = stdout
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+
+	if want := "INFO: tagged (nil context)\nINFO: untagged (nil context)\n"; string(out) != want {
+		t.Errorf("expected %q, got %q", want, out)
+	}
+}
+
+func TestMergeTagsAllocations(t *testing.T) {
+	base := mergeTags(nil, []string{"billing", "gateway"})
+
+	if n := testing.AllocsPerRun(100, func() { mergeTags(base, nil) }); n != 0 {
+		t.Errorf("expected no allocation without per-call tags, got %v", n)
+	}
+	if n := testing.AllocsPerRun(100, func() { mergeTags(base, []string{"billing"}) }); n != 0 {
+		t.Errorf("expected no allocation for tags already in context, got %v", n)
+	}
+	if got := mergeTags(base, []string{"billing", "refund", "refund"}); len(got) != 3 || got[2] != "refund" || len(base) != 2 {
+		t.Errorf("expected new tag appended once on a copy, got %v and base %v", got, base)
+	}
+}
+
+func TestInternBounded(t *testing.T) {
+	for i := 0; i < maxInternedTags+10; i++ {
+		intern(fmt.Sprintf("order-%d", i))
+	}
+
+	if n := internedTagCount.Load(); n > maxInternedTags {
+		t.Errorf("expected at most %d interned tags, got %d", maxInternedTags, n)
+	}
+	if got := intern("order-beyond"); got != "order-beyond" {
+		t.Errorf("expected tag beyond the pool returned as is, got %q", got)
+	}
+}
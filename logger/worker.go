@@ -0,0 +1,56 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// NewContext return context holding a new logger store for one unit of work, e.g. a consumed message,
+// with requestId as its request id or a generated one when empty
+func NewContext(ctx context.Context, requestId string) context.Context {
+	if requestId == "" {
+		requestId = uuid.NewString()
+	}
+
+	lock := new(Locker)
+	lock.Set(RequestId, requestId)
+	return context.WithValue(ctx, LogKey, lock)
+}
+
+// workerIdKey context key of worker id stamped on subsequent log messages
+type workerIdKey struct{}
+
+// WithWorkerID return context stamping every subsequent log message with worker id,
+// e.g. queue name and lane index of the consumer handling the message
+func WithWorkerID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, workerIdKey{}, id)
+}
+
+// WorkerID worker id of context, empty when not set
+func WorkerID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	id, _ := ctx.Value(workerIdKey{}).(string)
+	return id
+}
+
+// WithContext returns a Logger carrying the worker id of ctx as "worker_id" field,
+// and baggage as "baggage" field when SetBaggageFields is enabled
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	var fields []zap.Field
+	if id := WorkerID(ctx); id != "" {
+		fields = append(fields, zap.String("worker_id", id))
+	}
+	if f, ok := baggageField(ctx); ok {
+		fields = append(fields, f)
+	}
+	if len(fields) < 1 {
+		return l
+	}
+
+	return l.With(fields...)
+}
@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWorkerID(t *testing.T) {
+	var (
+		wg       sync.WaitGroup
+		contexts = make([]context.Context, 2)
+	)
+	for i := range contexts {
+		ctx := context.WithValue(context.Background(), LogKey, new(Locker))
+		contexts[i] = WithWorkerID(ctx, fmt.Sprintf("orders#%d", i))
+
+		wg.Add(1)
+		go func(ctx context.Context) {
+			defer wg.Done()
+			Log.Print(ctx, "processing")
+			Log.ErrorT(ctx, []string{"gateway"}, "gateway timeout")
+		}(contexts[i])
+	}
+	wg.Wait()
+
+	for i, ctx := range contexts {
+		value, _ := extract(ctx)
+		tmp, _ := value.Load(_LogMessages)
+
+		raw, _ := json.Marshal(&DataLogger{LogMessages: tmp.([]LogMessage)})
+		var out DataLogger
+		_ = json.Unmarshal(raw, &out)
+
+		want := fmt.Sprintf("orders#%d", i)
+		if len(out.LogMessages) != 2 || out.LogMessages[0].WorkerId != want || out.LogMessages[1].WorkerId != want {
+			t.Errorf("expected messages stamped with %s, got %+v", want, out.LogMessages)
+		}
+		if !strings.Contains(string(raw), `"worker_id":"`+want+`"`) {
+			t.Errorf("expected worker_id on flushed json, got %s", raw)
+		}
+	}
+
+	core, logs := observer.New(zap.InfoLevel)
+	l := &Logger{Logger: zap.New(core)}
+	l.WithContext(contexts[1]).Info("bridged")
+	l.WithContext(context.Background()).Info("plain")
+
+	entries := logs.All()
+	if id := entries[0].ContextMap()["worker_id"]; id != "orders#1" {
+		t.Errorf("expected worker_id zap field, got %v", id)
+	}
+	if _, ok := entries[1].ContextMap()["worker_id"]; ok {
+		t.Error("expected no worker_id without worker context")
+	}
+}
@@ -0,0 +1,97 @@
+package loki
+
+import (
+	"sync"
+	"time"
+)
+
+// NoopClient Sink discarding every entry
+type NoopClient struct{}
+
+// NewNoopClient creates a client discarding every entry, useful when loki is disabled
+func NewNoopClient() *NoopClient {
+	return &NoopClient{}
+}
+
+// Log discard entry
+func (*NoopClient) Log(time.Time, string, string) {}
+
+// LogWithLabels discard entry
+func (*NoopClient) LogWithLabels(time.Time, string, string, map[string]string) {}
+
+// LogWithMetadata discard entry
+func (*NoopClient) LogWithMetadata(time.Time, string, string, map[string]string, map[string]string) {}
+
+// Stop nothing to stop
+func (*NoopClient) Stop() {}
+
+// CapturedEntry entry recorded by CaptureClient
+type CapturedEntry struct {
+	Timestamp time.Time
+	Level     string
+	Message   string
+	Labels    map[string]string
+	Metadata  map[string]string
+}
+
+// CaptureClient Sink recording entries in memory, useful on tests
+type CaptureClient struct {
+	mu      sync.Mutex
+	entries []CapturedEntry
+	stopped bool
+}
+
+// NewCaptureClient creates a client recording entries in memory
+func NewCaptureClient() *CaptureClient {
+	return &CaptureClient{}
+}
+
+// Log record entry
+func (c *CaptureClient) Log(timestamp time.Time, level, message string) {
+	c.LogWithLabels(timestamp, level, message, nil)
+}
+
+// LogWithLabels record entry with its labels
+func (c *CaptureClient) LogWithLabels(timestamp time.Time, level, message string, labels map[string]string) {
+	c.LogWithMetadata(timestamp, level, message, labels, nil)
+}
+
+// LogWithMetadata record entry with its labels and structured metadata
+func (c *CaptureClient) LogWithMetadata(timestamp time.Time, level, message string, labels, metadata map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = append(c.entries, CapturedEntry{Timestamp: timestamp, Level: level, Message: message, Labels: labels, Metadata: metadata})
+}
+
+// Stop mark client as stopped
+func (c *CaptureClient) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stopped = true
+}
+
+// Entries copy of recorded entries
+func (c *CaptureClient) Entries() []CapturedEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]CapturedEntry(nil), c.entries...)
+}
+
+// Stopped report whether Stop was called
+func (c *CaptureClient) Stopped() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stopped
+}
+
+// Reset discard recorded entries
+func (c *CaptureClient) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = nil
+}
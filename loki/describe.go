@@ -0,0 +1,102 @@
+package loki
+
+import (
+	"net/url"
+	"time"
+)
+
+// maskedSecret replacement of secrets on Description
+const maskedSecret = "xxxxx"
+
+// Description configuration and state of client for diagnostics, e.g. a debug endpoint, secrets are masked
+type Description struct {
+	URL           string            `json:"url"`                 // push URL, password of userinfo masked
+	Username      string            `json:"username,omitempty"`  // basic auth username, password is never described
+	TenantID      string            `json:"tenant_id,omitempty"` // X-Scope-OrgID header
+	Labels        map[string]string `json:"labels"`              // static labels of every stream
+	BatchSize     int               `json:"batch_size"`
+	BatchWait     string            `json:"batch_wait"`
+	QueueDepth    int               `json:"queue_depth"`
+	QueueCapacity int               `json:"queue_capacity"`
+	Healthy       bool              `json:"healthy"`
+	LastPush      *PushStatus       `json:"last_push,omitempty"` // nil until the first push
+
+	Secondary *MirrorDescription `json:"secondary,omitempty"` // nil without Config.SecondaryURL
+}
+
+// MirrorDescription secondary endpoint of Description
+type MirrorDescription struct {
+	URL        string  `json:"url"`
+	Username   string  `json:"username,omitempty"`
+	TenantID   string  `json:"tenant_id,omitempty"`
+	SampleRate float64 `json:"sample_rate"`
+}
+
+// PushStatus outcome of the last push to the primary endpoint
+type PushStatus struct {
+	Time  time.Time `json:"time"`
+	OK    bool      `json:"ok"`
+	Error string    `json:"error,omitempty"`
+}
+
+// DescribedSink Sink describing its configuration and state, see Client.Describe
+type DescribedSink interface {
+	Sink
+	Describe() Description
+}
+
+// Describe configuration and live state of client, safe to call concurrently with the pipeline
+func (c *Client) Describe() Description {
+	d := Description{
+		URL:           maskURL(c.URL),
+		Labels:        make(map[string]string, len(c.Labels)),
+		BatchSize:     int(c.liveBatchSize.Load()),
+		BatchWait:     time.Duration(c.liveBatchWait.Load()).String(),
+		QueueDepth:    len(c.entriesQueue),
+		QueueCapacity: cap(c.entriesQueue),
+		Healthy:       c.Healthy(),
+		LastPush:      c.lastPush.Load(),
+	}
+	for k, v := range c.Labels {
+		d.Labels[k] = v
+	}
+
+	if hs, ok := c.sender.(*httpSender); ok {
+		d.Username, d.TenantID = hs.username, hs.tenantID
+	}
+
+	if c.mirror != nil {
+		d.Secondary = &MirrorDescription{SampleRate: c.mirror.sampleRate}
+		if hs, ok := c.mirror.sender.(*httpSender); ok {
+			d.Secondary.URL, d.Secondary.Username, d.Secondary.TenantID = maskURL(hs.url), hs.username, hs.tenantID
+		}
+	}
+
+	return d
+}
+
+// recordPush keep outcome of a push to the primary endpoint for Describe
+func (c *Client) recordPush(err error) {
+	status := &PushStatus{Time: time.Now(), OK: err == nil}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	c.lastPush.Store(status)
+}
+
+// maskURL url with the password of userinfo masked, unparseable url is fully masked
+func maskURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return maskedSecret
+	}
+
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), maskedSecret)
+		}
+	}
+
+	return u.String()
+}
@@ -0,0 +1,167 @@
+package loki
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"unicode/utf8"
+)
+
+// bufferPool push payload buffers reused across batches
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// maxPooledBuffer buffers grown beyond this size are left to the GC instead of pooled
+const maxPooledBuffer = 16 << 20
+
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+
+	bufferPool.Put(buf)
+}
+
+// encodePushRequest stream encode req into buf, the output is the same as json.Marshal without building
+// intermediate values, see stream.MarshalJSON
+func encodePushRequest(buf *bytes.Buffer, req pushRequest) {
+	scratch := make([]byte, 0, 256)
+
+	buf.WriteString(`{"streams":`)
+	if req.Streams == nil {
+		buf.WriteString("null}")
+		return
+	}
+
+	buf.WriteByte('[')
+	for i, s := range req.Streams {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		buf.WriteString(`{"stream":`)
+		scratch = appendStringMap(scratch[:0], s.Stream)
+		buf.Write(scratch)
+
+		buf.WriteString(`,"values":`)
+		if s.Values == nil {
+			buf.WriteString("null}")
+			continue
+		}
+
+		buf.WriteByte('[')
+		for j, v := range s.Values {
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+
+			scratch = append(scratch[:0], '[')
+			for k, field := range v {
+				if k > 0 {
+					scratch = append(scratch, ',')
+				}
+				scratch = appendString(scratch, field)
+			}
+			if s.metadata != nil && s.metadata[j] != nil {
+				scratch = append(scratch, ',')
+				scratch = appendStringMap(scratch, s.metadata[j])
+			}
+			scratch = append(scratch, ']')
+			buf.Write(scratch)
+		}
+		buf.WriteString("]}")
+	}
+	buf.WriteString("]}")
+}
+
+// appendStringMap append m as json object with sorted keys like encoding/json
+func appendStringMap(dst []byte, m map[string]string) []byte {
+	if m == nil {
+		return append(dst, "null"...)
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	dst = append(dst, '{')
+	for i, k := range keys {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendString(dst, k)
+		dst = append(dst, ':')
+		dst = appendString(dst, m[k])
+	}
+
+	return append(dst, '}')
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendString append s as json string escaped the same as encoding/json, including HTML characters,
+// U+2028, U+2029 and invalid UTF-8 replaced by U+FFFD
+func appendString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				dst = append(dst, '\\', b)
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, "\uFFFD"...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
@@ -0,0 +1,81 @@
+package loki
+
+import (
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// DropFilter report whether entry is dropped instead of shipped to Loki, evaluated by the queue goroutine
+type DropFilter func(level, message string) bool
+
+// DropContaining drop entries whose message contains substr, e.g. "GET /health"
+func DropContaining(substr string) DropFilter {
+	return func(_, message string) bool {
+		return strings.Contains(message, substr)
+	}
+}
+
+// DropMatching drop entries whose message matches re
+func DropMatching(re *regexp.Regexp) DropFilter {
+	return func(_, message string) bool {
+		return re.MatchString(message)
+	}
+}
+
+// DropLevelMatching drop entries of level whose message matches re, e.g. a known benign error
+func DropLevelMatching(level string, re *regexp.Regexp) DropFilter {
+	return func(l, message string) bool {
+		return l == level && re.MatchString(message)
+	}
+}
+
+// dropFilters filters with dropped entry counter per filter index, replaced as a whole by SetDropFilters
+type dropFilters struct {
+	filters []DropFilter
+	dropped []atomic.Int64
+}
+
+func newDropFilters(filters []DropFilter) *dropFilters {
+	return &dropFilters{
+		filters: append([]DropFilter(nil), filters...),
+		dropped: make([]atomic.Int64, len(filters)),
+	}
+}
+
+// SetDropFilters replace drop filters at runtime, counters of the previous filters are reset
+func (c *Client) SetDropFilters(filters ...DropFilter) {
+	c.dropFilters.Store(newDropFilters(filters))
+}
+
+// dropped report whether entry matches one of drop filters
+func (c *Client) dropped(e entry) bool {
+	f := c.dropFilters.Load()
+	if f == nil {
+		return false
+	}
+
+	for i, filter := range f.filters {
+		if filter(e.Level, e.Message) {
+			f.dropped[i].Add(1)
+			return true
+		}
+	}
+
+	return false
+}
+
+// droppedByFilter number of entries dropped by each filter index
+func (c *Client) droppedByFilter() []int64 {
+	f := c.dropFilters.Load()
+	if f == nil || len(f.filters) < 1 {
+		return nil
+	}
+
+	counts := make([]int64, len(f.dropped))
+	for i := range f.dropped {
+		counts[i] = f.dropped[i].Load()
+	}
+
+	return counts
+}
@@ -0,0 +1,43 @@
+package loki
+
+import "time"
+
+// reasons of DroppedEntry
+const (
+	DropQueueFull  = "queue_full"  // queue of client is full, see Config.BatchSize
+	DropPushFailed = "push_failed" // push of its batch failed after retries
+)
+
+// DroppedEntry entry never shipped to Loki, reported to Config.OnDrop
+type DroppedEntry struct {
+	Timestamp time.Time
+	Level     string
+	Message   string
+	Labels    map[string]string
+	Reason    string // DropQueueFull or DropPushFailed
+}
+
+// reportError count a failed push and report it to OnError, or to the logger without hook
+func (c *Client) reportError(err error) {
+	c.primary.failed.Add(1)
+	if c.onError != nil {
+		c.onError(err)
+		return
+	}
+
+	c.logger.Logf(LevelError, "%v", err)
+}
+
+// reportDrop count a dropped entry and report it to OnDrop, or to the logger without hook. entries of a failed
+// push are only counted without hook, their push error is already reported
+func (c *Client) reportDrop(e entry, reason string) {
+	c.lost.Add(1)
+	if c.onDrop != nil {
+		c.onDrop(DroppedEntry{Timestamp: e.Timestamp, Level: e.Level, Message: e.Message, Labels: e.Labels, Reason: reason})
+		return
+	}
+
+	if reason == DropQueueFull {
+		c.logger.Logf(LevelWarn, "queue full, dropping log entry: %s", e.Message)
+	}
+}
@@ -0,0 +1,46 @@
+package loki
+
+import "os"
+
+// host label names added by Config.AutoHostLabels
+const (
+	LabelHost      = "host"
+	LabelPod       = "pod"
+	LabelNamespace = "namespace"
+	LabelNode      = "node"
+)
+
+// hostLabels resolve hostname and, when running in Kubernetes, pod, namespace and node from the
+// downward-API env POD_NAME, POD_NAMESPACE and NODE_NAME, labels on exclude are skipped
+func hostLabels(exclude []string) map[string]string {
+	labels := make(map[string]string, 4)
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		labels[LabelHost] = hostname
+	}
+
+	for label, key := range map[string]string{
+		LabelPod:       "POD_NAME",
+		LabelNamespace: "POD_NAMESPACE",
+		LabelNode:      "NODE_NAME",
+	} {
+		if v := os.Getenv(key); v != "" {
+			labels[label] = v
+		}
+	}
+
+	for _, label := range exclude {
+		delete(labels, label)
+	}
+
+	return labels
+}
+
+// mergeHostLabels merge host labels under explicit labels of the same name
+func mergeHostLabels(explicit map[string]string, exclude []string) map[string]string {
+	labels := hostLabels(exclude)
+	for k, v := range explicit {
+		labels[k] = v
+	}
+
+	return labels
+}
@@ -0,0 +1,110 @@
+package loki
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultMaxLineBytes default Config.MaxLineBytes, the default max_line_size of loki
+	DefaultMaxLineBytes = 256 << 10
+	// DefaultMaxLabelValueBytes default Config.MaxLabelValueBytes, the default max_label_value_length of loki
+	DefaultMaxLabelValueBytes = 2048
+
+	// MetadataFragmentId structured metadata shared by continuation entries of a split line
+	MetadataFragmentId = "fragment_id"
+	// MetadataFragment structured metadata of continuation entry position, e.g. "2/3"
+	MetadataFragment = "fragment"
+)
+
+// limitLine enforce MaxLineBytes on message, oversized message is truncated with a
+// "...[truncated N bytes]" suffix or split into continuation entries on SplitLongLines
+func (c *Client) limitLine(e entry) []entry {
+	if c.maxLineBytes <= 0 || len(e.Message) <= c.maxLineBytes {
+		return []entry{e}
+	}
+
+	if !c.splitLongLines {
+		c.truncated.Add(1)
+		e.Message = truncateLine(e.Message, c.maxLineBytes)
+		return []entry{e}
+	}
+
+	c.split.Add(1)
+	chunks := splitLine(e.Message, c.maxLineBytes)
+	id := fragmentId()
+
+	entries := make([]entry, 0, len(chunks))
+	for i, chunk := range chunks {
+		fragment := e
+		fragment.Message = chunk
+		fragment.Metadata = make(map[string]string, len(e.Metadata)+2)
+		for k, v := range e.Metadata {
+			fragment.Metadata[k] = v
+		}
+		fragment.Metadata[MetadataFragmentId] = id
+		fragment.Metadata[MetadataFragment] = fmt.Sprintf("%d/%d", i+1, len(chunks))
+		entries = append(entries, fragment)
+	}
+
+	return entries
+}
+
+// truncateLine cut line at UTF-8 boundary so that line with suffix fits max bytes
+func truncateLine(line string, max int) string {
+	// suffix with the biggest possible count never underestimates its own length
+	cut := max - len(fmt.Sprintf("...[truncated %d bytes]", len(line)))
+	if cut < 0 {
+		cut = 0
+	}
+
+	cut = runeBoundary(line, cut)
+	return line[:cut] + fmt.Sprintf("...[truncated %d bytes]", len(line)-cut)
+}
+
+// splitLine split line into chunks of at most max bytes at UTF-8 boundaries
+func splitLine(line string, max int) []string {
+	chunks := make([]string, 0, len(line)/max+1)
+	for len(line) > max {
+		cut := runeBoundary(line, max)
+		if cut == 0 {
+			// a single rune bigger than max, keep it whole
+			_, cut = utf8.DecodeRuneInString(line)
+		}
+
+		chunks = append(chunks, line[:cut])
+		line = line[cut:]
+	}
+
+	return append(chunks, line)
+}
+
+// clampLabel cut label value at UTF-8 boundary to max bytes, zero means unlimited
+func clampLabel(value string, max int) string {
+	if max <= 0 || len(value) <= max {
+		return value
+	}
+
+	return value[:runeBoundary(value, max)]
+}
+
+// runeBoundary largest index not after n that starts a rune
+func runeBoundary(s string, n int) int {
+	if n >= len(s) {
+		return len(s)
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return n
+}
+
+func fragmentId() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -1,32 +1,228 @@
 package loki
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/TixiaOTA/gokit/utils/env"
+	"github.com/TixiaOTA/gokit/utils/safe"
 )
 
+const (
+	// levels used to report client internal messages
+	LevelDebug = "debug"
+	LevelWarn  = "warn"
+	LevelError = "error"
+
+	// InternalField marks entries produced by the client itself, a logger bridging into loki must drop them
+	InternalField = "loki_internal"
+)
+
+// ErrInvalidBatching batch size or batch wait is not positive
+var ErrInvalidBatching = errors.New("loki: batch size and batch wait must be positive")
+
+// Sink surface of client used by loggers, implemented by Client, NoopClient and CaptureClient
+type Sink interface {
+	Log(timestamp time.Time, level, message string)
+	Stop()
+}
+
+// LabeledSink Sink accepting labels per entry, entries with the same labels share a stream
+type LabeledSink interface {
+	Sink
+	LogWithLabels(timestamp time.Time, level, message string, labels map[string]string)
+}
+
+// MetadataSink LabeledSink accepting structured metadata per entry, metadata never creates a stream
+type MetadataSink interface {
+	LabeledSink
+	LogWithMetadata(timestamp time.Time, level, message string, labels, metadata map[string]string)
+}
+
+// HealthySink Sink reporting whether entries are currently shipped, see Client.Healthy
+type HealthySink interface {
+	Sink
+	Healthy() bool
+}
+
+// unhealthyAfter consecutive failed pushes before the client reports unhealthy
+const unhealthyAfter = 3
+
+// Logger minimal logger to report client internal messages
+type Logger interface {
+	Logf(level, format string, args ...interface{})
+}
+
+// stderrLogger default Logger writing to stderr
+type stderrLogger struct{}
+
+func (stderrLogger) Logf(level, format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "loki [%s]: %s\n", level, fmt.Sprintf(format, args...))
+}
+
 // Client represents a Loki client for sending logs
 type Client struct {
-	URL          string
-	BatchSize    int
-	BatchWait    time.Duration
-	Labels       map[string]string
-	HTTPClient   *http.Client
-	entriesQueue chan entry
-	done         chan struct{}
+	URL        string
+	BatchSize  int
+	BatchWait  time.Duration
+	Labels     map[string]string
+	HTTPClient *http.Client
+	logger     Logger
+	streams    *streamTracker
+	sender     sender
+	// dedupTimestamps shift timestamps not after the previous one of the stream by 1ns, see Config.DisableTimestampDedup,
+	// clock keeps the last timestamp of every stream across batches, both owned by processQueue
+	dedupTimestamps bool
+	clock           *streamClock
+	entriesQueue    chan entry
+	done            chan struct{}
+
+	// shutdown, see Stop. stopMu is held for reading by Log while enqueuing so no entry is enqueued after
+	// the final drain, finished is closed once processQueue sent the last batch
+	stopMu      sync.RWMutex
+	stopped     bool
+	stopOnce    sync.Once
+	finished    chan struct{}
+	stopTimeout time.Duration
+
+	// live batching, applied by processQueue and published for Stats
+	batching      chan batching
+	liveBatchSize atomic.Int64
+	liveBatchWait atomic.Int64
+
+	// line and label limits, see Config.MaxLineBytes
+	maxLineBytes       int
+	splitLongLines     bool
+	maxLabelValueBytes int
+	allowedLabels      map[string]struct{} // nil allows every entry label, see Config.AllowedLabels
+	truncated          atomic.Int64
+	split              atomic.Int64
+
+	// entries matching one of filters are never shipped
+	dropFilters atomic.Pointer[dropFilters]
+
+	// retry of failed pushes, see Config.MaxRetries
+	maxRetries             int
+	minBackoff, maxBackoff time.Duration
+	maxRetryAfter          time.Duration
+	maxRetryDuration       time.Duration
+
+	// consecutive failed pushes, reset by a successful push
+	failures atomic.Int64
+	// outcome of the last push, see Describe
+	lastPush atomic.Pointer[PushStatus]
+
+	// hooks of failed pushes and dropped entries, see Config.OnError and Config.OnDrop
+	onError func(error)
+	onDrop  func(DroppedEntry)
+
+	// entries accepted by the queue, shipped by a successful push, and lost
+	enqueued, sentEntries, lost atomic.Int64
+
+	// push outcome of Config.URL, and of Config.SecondaryURL when mirror is set
+	primary endpointCounters
+	rejects rejectCounters
+	mirror  *mirror
+}
+
+// batching batch parameters sent to processQueue by SetBatching
+type batching struct {
+	size int
+	wait time.Duration
+}
+
+// Stats current state of client
+type Stats struct {
+	BatchSize int
+	BatchWait time.Duration
+	Queued    int
+	Truncated int64 // lines truncated by MaxLineBytes
+	Split     int64 // lines split into continuation entries by MaxLineBytes
+
+	Enqueued    int64 // entries accepted by the queue
+	EntriesSent int64 // entries of successful pushes
+	Dropped     int64 // entries lost by a full queue or a failed push, see Config.OnDrop
+
+	DroppedByFilter []int64 // entries dropped by each DropFilters index
+
+	Primary   EndpointStats // pushes to Config.URL, Sent are batches sent and Failed send errors
+	Rejected  RejectStats   // rejected pushes to Config.URL by class
+	Secondary EndpointStats // pushes to Config.SecondaryURL, zero without mirroring
 }
 
 // Config holds configuration for Loki client
 type Config struct {
-	URL        string            // Loki push API endpoint (e.g., "http://loki:3100/loki/api/v1/push")
+	URL        string            // Loki push API endpoint (e.g., "http://loki:3100/loki/api/v1/push"), PushPath is appended to base URL
 	BatchSize  int               // Number of entries to batch before sending
 	BatchWait  time.Duration     // Maximum time to wait before sending batch
 	Labels     map[string]string // Default labels to add to all log entries
 	HTTPClient *http.Client      // Custom HTTP client (optional)
+	Logger     Logger            // Logger for client internal messages (optional), default to stderr
+
+	// Username and Password basic auth of every push, Password is read from the environment variable
+	// PasswordEnv when empty, e.g. LOKI_PASSWORD, so it is never hardcoded
+	Username    string
+	Password    string
+	PasswordEnv string
+	TenantID    string            // X-Scope-OrgID header of every push, skipped when blank
+	Headers     map[string]string // Headers set on every push, e.g. a bearer token of a gateway
+
+	// AllowedLabels whitelist of entry label keys of LogWithLabels, other keys are stripped to bound cardinality.
+	// static Labels and level are always kept, empty allows every key
+	AllowedLabels []string
+
+	MaxActiveStreams    int           // Maximum distinct streams over ActiveStreamsWindow, zero means unlimited
+	ActiveStreamsWindow time.Duration // Sliding window for MaxActiveStreams, default 1 minute
+
+	DisableTimestampDedup bool // Send timestamps as is instead of shifting them by 1ns so they strictly increase within a stream across batches
+
+	AutoHostLabels    bool     // Add host label and, on Kubernetes, pod, namespace and node labels, explicit Labels win
+	ExcludeHostLabels []string // Host labels skipped by AutoHostLabels, e.g. "pod" to avoid high cardinality
+
+	MaxLineBytes       int  // Maximum bytes of line, default DefaultMaxLineBytes, negative means unlimited
+	SplitLongLines     bool // Split oversized line into continuation entries sharing MetadataFragmentId instead of truncating
+	MaxLabelValueBytes int  // Maximum bytes of label value, default DefaultMaxLabelValueBytes, negative means unlimited
+
+	ValidateOnStart bool // Push an empty request on NewClientE and log a diagnostic of the failure, see Validate
+	StrictStart     bool // NewClientE returns the validation failure instead of only logging it
+
+	DropFilters []DropFilter // Entries matching one of filters are dropped before batching, see SetDropFilters
+
+	// SecondaryURL Loki endpoint receiving a best-effort copy of every push, e.g. while migrating clusters.
+	// its failures are counted on Stats.Secondary and never retried nor affect the primary push
+	SecondaryURL      string
+	SecondaryUsername string  // Basic auth username of SecondaryURL
+	SecondaryPassword string  // Basic auth password of SecondaryURL
+	SecondaryTenantID string  // X-Scope-OrgID header of SecondaryURL
+	MirrorSampleRate  float64 // Fraction of batches mirrored to SecondaryURL, zero means all
+
+	// MaxRetries push attempts sent again after a 429, 5xx or transport failure, zero never retries.
+	// other 4xx responses are never retried, mirrored pushes neither
+	MaxRetries int
+	MinBackoff time.Duration // First retry delay doubled on every retry with jitter, default DefaultMinBackoff
+	MaxBackoff time.Duration // Maximum retry delay, default DefaultMaxBackoff
+	// MaxRetryAfter longest Retry-After of 429 or 503 waited for, the batch is given up on a longer one,
+	// default DefaultMaxRetryAfter
+	MaxRetryAfter time.Duration
+	// MaxRetryDuration total time a batch is retried for, waits included, the batch is given up once a retry
+	// would end past it so a failing endpoint does not hold the queue, default DefaultMaxRetryDuration
+	MaxRetryDuration time.Duration
+
+	// StopTimeout maximum time Stop waits for the queue to be flushed, default DefaultStopTimeout, see StopWithContext
+	StopTimeout time.Duration
+
+	// OnError called with the error of every failed push after retries instead of logging it on Logger.
+	// OnDrop called for every entry lost by a full queue or a failed push instead of logging a full queue.
+	// both are called synchronously, OnDrop of a full queue by the goroutine calling Log, they must not block
+	OnError func(error)
+	OnDrop  func(DroppedEntry)
 }
 
 // entry represents a log entry to be sent to Loki
@@ -34,12 +230,41 @@ type entry struct {
 	Timestamp time.Time
 	Message   string
 	Level     string
+	Labels    map[string]string
+	Metadata  map[string]string // structured metadata of entry
 }
 
 // stream represents a stream of log entries with the same labels
 type stream struct {
 	Stream map[string]string `json:"stream"`
 	Values [][]string        `json:"values"`
+
+	// metadata structured metadata of each value, nil when no value has metadata
+	metadata []map[string]string
+}
+
+// MarshalJSON encode values with structured metadata as [ts, line, {metadata}]
+func (s stream) MarshalJSON() ([]byte, error) {
+	type plain struct {
+		Stream map[string]string `json:"stream"`
+		Values [][]string        `json:"values"`
+	}
+	if s.metadata == nil {
+		return json.Marshal(plain{Stream: s.Stream, Values: s.Values})
+	}
+
+	values := make([][]interface{}, len(s.Values))
+	for i, v := range s.Values {
+		values[i] = []interface{}{v[0], v[1]}
+		if md := s.metadata[i]; md != nil {
+			values[i] = append(values[i], md)
+		}
+	}
+
+	return json.Marshal(struct {
+		Stream map[string]string `json:"stream"`
+		Values [][]interface{}   `json:"values"`
+	}{Stream: s.Stream, Values: values})
 }
 
 // pushRequest represents the request body for Loki's push API
@@ -47,8 +272,23 @@ type pushRequest struct {
 	Streams []stream `json:"streams"`
 }
 
-// NewClient creates a new Loki client
+// NewClient creates a new Loki client, with Config.ValidateOnStart the validation runs in background
+// and only logs its diagnostic, use NewClientE to fail fast
 func NewClient(config Config) *Client {
+	client := newClient(config)
+	if config.ValidateOnStart {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), validateTimeout)
+			defer cancel()
+
+			_ = client.Validate(ctx)
+		}()
+	}
+
+	return client
+}
+
+func newClient(config Config) *Client {
 	if config.BatchSize <= 0 {
 		config.BatchSize = 100
 	}
@@ -60,127 +300,257 @@ func NewClient(config Config) *Client {
 			Timeout: 5 * time.Second,
 		}
 	}
+	if config.Logger == nil {
+		config.Logger = stderrLogger{}
+	}
+	if config.ActiveStreamsWindow <= 0 {
+		config.ActiveStreamsWindow = time.Minute
+	}
+	if config.AutoHostLabels {
+		config.Labels = mergeHostLabels(config.Labels, config.ExcludeHostLabels)
+	}
+	config.URL = normalizeURL(config.URL)
+	if config.MaxLineBytes == 0 {
+		config.MaxLineBytes = DefaultMaxLineBytes
+	}
+	if config.MaxLabelValueBytes == 0 {
+		config.MaxLabelValueBytes = DefaultMaxLabelValueBytes
+	}
+	if config.Password == "" && config.PasswordEnv != "" {
+		config.Password = env.GetString(config.PasswordEnv)
+	}
+	if config.StopTimeout <= 0 {
+		config.StopTimeout = DefaultStopTimeout
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = DefaultMinBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = DefaultMaxBackoff
+	}
+	if config.MaxRetryAfter <= 0 {
+		config.MaxRetryAfter = DefaultMaxRetryAfter
+	}
+	if config.MaxRetryDuration <= 0 {
+		config.MaxRetryDuration = DefaultMaxRetryDuration
+	}
 
 	client := &Client{
-		URL:          config.URL,
-		BatchSize:    config.BatchSize,
-		BatchWait:    config.BatchWait,
-		Labels:       config.Labels,
-		HTTPClient:   config.HTTPClient,
-		entriesQueue: make(chan entry, config.BatchSize*2),
-		done:         make(chan struct{}),
+		URL:             config.URL,
+		BatchSize:       config.BatchSize,
+		BatchWait:       config.BatchWait,
+		Labels:          config.Labels,
+		HTTPClient:      config.HTTPClient,
+		logger:          config.Logger,
+		streams:         newStreamTracker(config.MaxActiveStreams, config.ActiveStreamsWindow),
+		dedupTimestamps: !config.DisableTimestampDedup,
+		clock:           newStreamClock(config.ActiveStreamsWindow),
+		sender:          newHTTPSender(config.URL, config.HTTPClient, config.Username, config.Password, config.TenantID, config.Headers),
+		entriesQueue:    make(chan entry, config.BatchSize*2),
+		done:            make(chan struct{}),
+		finished:        make(chan struct{}),
+		stopTimeout:     config.StopTimeout,
+		batching:        make(chan batching),
+
+		maxLineBytes:       config.MaxLineBytes,
+		splitLongLines:     config.SplitLongLines,
+		maxLabelValueBytes: config.MaxLabelValueBytes,
+		allowedLabels:      allowedLabels(config.AllowedLabels),
+
+		maxRetries: config.MaxRetries,
+		minBackoff: config.MinBackoff,
+		maxBackoff: config.MaxBackoff,
+
+		maxRetryAfter:    config.MaxRetryAfter,
+		maxRetryDuration: config.MaxRetryDuration,
+
+		onError: config.OnError,
+		onDrop:  config.OnDrop,
+
+		mirror: newMirror(config),
 	}
+	client.liveBatchSize.Store(int64(config.BatchSize))
+	client.liveBatchWait.Store(int64(config.BatchWait))
+	client.SetDropFilters(config.DropFilters...)
 
-	go client.processQueue()
+	safe.Go("loki", client.processQueue)
 	return client
 }
 
-// Stop gracefully shuts down the client
-func (c *Client) Stop() {
-	close(c.done)
+// SetBatching change batch size and batch wait without restarting the client, applied by the queue goroutine
+// before the next entry, a batch already reaching the new size is sent immediately
+func (c *Client) SetBatching(size int, wait time.Duration) error {
+	if size <= 0 || wait <= 0 {
+		return ErrInvalidBatching
+	}
+
+	select {
+	case c.batching <- batching{size: size, wait: wait}:
+	case <-c.done:
+	}
+
+	return nil
+}
+
+// Stats current batching and number of queued entries
+func (c *Client) Stats() Stats {
+	stats := Stats{
+		BatchSize: int(c.liveBatchSize.Load()),
+		BatchWait: time.Duration(c.liveBatchWait.Load()),
+		Queued:    len(c.entriesQueue),
+		Truncated: c.truncated.Load(),
+		Split:     c.split.Load(),
+
+		Enqueued:    c.enqueued.Load(),
+		EntriesSent: c.sentEntries.Load(),
+		Dropped:     c.lost.Load(),
+
+		DroppedByFilter: c.droppedByFilter(),
+	}
+	stats.Primary = c.primary.stats()
+	stats.Rejected = c.rejects.stats()
+	if c.mirror != nil {
+		stats.Secondary = c.mirror.counters.stats()
+	}
+
+	return stats
 }
 
-// Log sends a log entry to Loki
+// Log sends a log entry to Loki, message over MaxLineBytes is truncated or split, no-op after Stop
 func (c *Client) Log(timestamp time.Time, level, message string) {
-	select {
-	case c.entriesQueue <- entry{
-		Timestamp: timestamp,
-		Level:     level,
-		Message:   message,
-	}:
-	default:
-		// Queue is full, log to stderr
-		fmt.Printf("Loki client queue full, dropping log entry: %s\n", message)
+	c.LogWithLabels(timestamp, level, message, nil)
+}
+
+// LogWithLabels sends a log entry with labels merged over the static Labels to Loki
+func (c *Client) LogWithLabels(timestamp time.Time, level, message string, labels map[string]string) {
+	c.LogWithMetadata(timestamp, level, message, labels, nil)
+}
+
+// LogWithMetadata sends a log entry with labels and structured metadata to Loki
+func (c *Client) LogWithMetadata(timestamp time.Time, level, message string, labels, metadata map[string]string) {
+	// no-op after Stop
+	c.stopMu.RLock()
+	if c.stopped {
+		c.stopMu.RUnlock()
+		return
+	}
+
+	var dropped []entry
+	for _, e := range c.limitLine(entry{Timestamp: timestamp, Level: level, Message: message, Labels: labels, Metadata: metadata}) {
+		select {
+		case c.entriesQueue <- e:
+			c.enqueued.Add(1)
+		default:
+			dropped = append(dropped, e)
+		}
+	}
+	c.stopMu.RUnlock()
+
+	// Queue is full, report through OnDrop or the internal logger, never re-enqueue.
+	// reported once the lock is released so a hook calling Stop does not deadlock
+	for _, e := range dropped {
+		c.reportDrop(e, DropQueueFull)
 	}
 }
 
-// processQueue batches and sends log entries to Loki
+// processQueue batches and sends log entries to Loki,
+// BatchWait is measured since the last send instead of a fixed cadence
 func (c *Client) processQueue() {
-	ticker := time.NewTicker(c.BatchWait)
-	defer ticker.Stop()
+	size, wait := c.BatchSize, c.BatchWait
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	batch := make([]entry, 0, size)
+	flush := func() {
+		if len(batch) > 0 {
+			c.sendBatch(batch)
+			batch = make([]entry, 0, size)
+		}
+
+		timer.Reset(wait)
+	}
 
-	batch := make([]entry, 0, c.BatchSize)
 	for {
 		select {
 		case <-c.done:
-			if len(batch) > 0 {
-				c.sendBatch(batch)
-			}
+			c.flushQueue(batch, size)
+			close(c.finished)
 			return
+		case b := <-c.batching:
+			size, wait = b.size, b.wait
+			c.liveBatchSize.Store(int64(size))
+			c.liveBatchWait.Store(int64(wait))
+
+			if len(batch) >= size {
+				flush()
+				continue
+			}
+
+			timer.Reset(wait)
 		case e := <-c.entriesQueue:
+			if c.dropped(e) {
+				continue
+			}
+
 			batch = append(batch, e)
-			if len(batch) >= c.BatchSize {
-				c.sendBatch(batch)
-				batch = make([]entry, 0, c.BatchSize)
+			if len(batch) < size {
+				continue
 			}
-		case <-ticker.C:
-			if len(batch) > 0 {
-				c.sendBatch(batch)
-				batch = make([]entry, 0, c.BatchSize)
+
+			flush()
+
+			// coalesce entries queued while sending before waiting again
+			for c.drainQueue(&batch, size) {
+				flush()
 			}
+		case <-timer.C:
+			flush()
 		}
 	}
 }
 
-// sendBatch sends a batch of log entries to Loki
-func (c *Client) sendBatch(entries []entry) {
-	// Group entries by their level
-	entriesByLevel := make(map[string][]entry)
-	for _, e := range entries {
-		entriesByLevel[e.Level] = append(entriesByLevel[e.Level], e)
-	}
-
-	// Create a stream for each level
-	streams := make([]stream, 0, len(entriesByLevel))
-	for level, levelEntries := range entriesByLevel {
-		// Create labels for this stream
-		labels := make(map[string]string)
-		for k, v := range c.Labels {
-			labels[k] = v
-		}
-		labels["level"] = level
-
-		// Create values for this stream
-		values := make([][]string, 0, len(levelEntries))
-		for _, e := range levelEntries {
-			// Convert timestamp to nanosecond precision string
-			ts := fmt.Sprintf("%d", e.Timestamp.UnixNano())
-			values = append(values, []string{ts, e.Message})
+// drainQueue move immediately available entries into batch, report whether batch is full
+func (c *Client) drainQueue(batch *[]entry, size int) bool {
+	for len(*batch) < size {
+		select {
+		case e := <-c.entriesQueue:
+			if !c.dropped(e) {
+				*batch = append(*batch, e)
+			}
+		default:
+			return false
 		}
-
-		streams = append(streams, stream{
-			Stream: labels,
-			Values: values,
-		})
 	}
 
-	// Create push request
-	reqBody := pushRequest{Streams: streams}
-	jsonBody, err := json.Marshal(reqBody)
-	if err != nil {
-		fmt.Printf("Error marshalling Loki push request: %v\n", err)
-		return
-	}
+	return true
+}
 
-	// Send request to Loki
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// sendBatch sends a batch of log entries to Loki
+func (c *Client) sendBatch(entries []entry) {
+	streams := c.buildStreams(entries)
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.URL, bytes.NewReader(jsonBody))
-	if err != nil {
-		fmt.Printf("Error creating Loki push request: %v\n", err)
-		return
+	req := pushRequest{Streams: streams}
+	if c.mirror != nil {
+		c.mirror.push(req, c.logger)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := c.HTTPClient.Do(req)
+	// Send request to Loki
+	err := c.push(req)
+	c.recordPush(err)
 	if err != nil {
-		fmt.Printf("Error sending logs to Loki: %v\n", err)
+		c.failures.Add(1)
+		c.reportError(err)
+		for _, e := range entries {
+			c.reportDrop(e, DropPushFailed)
+		}
 		return
 	}
-	defer resp.Body.Close()
+	c.failures.Store(0)
+	c.primary.sent.Add(1)
+	c.sentEntries.Add(int64(len(entries)))
+}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		fmt.Printf("Error response from Loki: %s\n", resp.Status)
-	}
+// Healthy report false after 3 consecutive failed pushes until a push succeeds
+func (c *Client) Healthy() bool {
+	return c.failures.Load() < unhealthyAfter
 }
@@ -0,0 +1,1306 @@
+package loki
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/TixiaOTA/gokit/utils/env/envtest"
+)
+
+type fakeLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (f *fakeLogger) Logf(level, format string, args ...interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages = append(f.messages, level+": "+fmt.Sprintf(format, args...))
+}
+
+func (f *fakeLogger) contains(substr string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, m := range f.messages {
+		if strings.Contains(m, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestInternalErrorsReachLogger(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	fl := &fakeLogger{}
+	c := NewClient(Config{URL: srv.URL, BatchSize: 1, BatchWait: time.Hour, Logger: fl})
+	c.Log(time.Now(), "info", "hello")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !fl.contains("push rejected") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Stop()
+
+	if !fl.contains("error: push rejected") {
+		t.Fatalf("expected push error reported to logger, got %v", fl.messages)
+	}
+
+	if len(c.entriesQueue) != 0 {
+		t.Errorf("internal errors must not re-enter the queue, got %d entries", len(c.entriesQueue))
+	}
+}
+
+func TestMaxActiveStreams(t *testing.T) {
+	fl := &fakeLogger{}
+	c := &Client{logger: fl, streams: newStreamTracker(3, time.Minute)}
+
+	entries := make([]entry, 0, 50)
+	for i := 0; i < 50; i++ {
+		entries = append(entries, entry{
+			Timestamp: time.Now(),
+			Level:     "info",
+			Message:   "message",
+			Labels:    map[string]string{"tenant": fmt.Sprintf("tenant-%d", i)},
+		})
+	}
+
+	streams := c.buildStreams(entries)
+	if len(streams) != 4 {
+		t.Fatalf("expected 3 streams plus overflow stream, got %d", len(streams))
+	}
+
+	overflow := streams[3]
+	if overflow.Stream[overflowLabel] != "true" || len(overflow.Values) != 47 {
+		t.Errorf("expected 47 entries merged into overflow stream, got %v", overflow)
+	}
+
+	if !strings.Contains(overflow.Values[0][1], `tenant="tenant-3"`) {
+		t.Errorf("expected distinguishing labels moved into the line, got %s", overflow.Values[0][1])
+	}
+
+	if !fl.contains("max active streams") {
+		t.Error("expected warning when the limit trips")
+	}
+}
+
+func TestStreamTrackerExpiry(t *testing.T) {
+	tr := newStreamTracker(2, time.Minute)
+	start := time.Unix(1000, 0)
+
+	tr.admit("a", start)
+	tr.admit("b", start.Add(time.Second))
+	if ok, tripped := tr.admit("c", start.Add(2*time.Second)); ok || !tripped {
+		t.Fatalf("expected c sent to overflow, got admitted=%v tripped=%v", ok, tripped)
+	}
+	// no stream expires before a does, the next admit does not scan
+	if want := start.Add(time.Minute); !tr.nextExpiry.Equal(want) {
+		t.Errorf("expected next expiry %s, got %s", want, tr.nextExpiry)
+	}
+
+	// a is out of window, b is not
+	if ok, _ := tr.admit("c", start.Add(time.Minute+500*time.Millisecond)); !ok {
+		t.Fatal("expected c admitted once a expired")
+	}
+	if _, ok := tr.seen["b"]; !ok || len(tr.seen) != 2 {
+		t.Errorf("expected b kept in window, got %v", tr.seen)
+	}
+}
+
+func TestDedupTimestampsStrictlyIncreasing(t *testing.T) {
+	c := &Client{logger: &fakeLogger{}, dedupTimestamps: true}
+	rnd := rand.New(rand.NewSource(1))
+	base := time.Now()
+
+	for run := 0; run < 200; run++ {
+		entries := make([]entry, rnd.Intn(50)+1)
+		for i := range entries {
+			entries[i] = entry{
+				// few distinct timestamps out of order to force bursts
+				Timestamp: base.Add(time.Duration(rnd.Intn(5)) * time.Nanosecond),
+				Level:     []string{"info", "error"}[rnd.Intn(2)],
+				Message:   strconv.Itoa(i),
+			}
+		}
+
+		for _, s := range c.buildStreams(entries) {
+			var prev int64
+			for i, v := range s.Values {
+				ts, _ := strconv.ParseInt(v[0], 10, 64)
+				if i > 0 && ts <= prev {
+					t.Fatalf("expected strictly increasing timestamps, got %v", s.Values)
+				}
+				prev = ts
+			}
+		}
+	}
+}
+
+func TestDedupTimestampsPreserveOrder(t *testing.T) {
+	c := &Client{logger: &fakeLogger{}, dedupTimestamps: true}
+	now := time.Unix(0, 1000)
+
+	streams := c.buildStreams([]entry{
+		{Timestamp: now, Level: "info", Message: "first"},
+		{Timestamp: now, Level: "info", Message: "second"},
+		{Timestamp: now.Add(-time.Nanosecond), Level: "info", Message: "earlier"},
+		{Timestamp: now, Level: "info", Message: "third"},
+	})
+
+	want := [][]string{{"999", "earlier"}, {"1000", "first"}, {"1001", "second"}, {"1002", "third"}}
+	if fmt.Sprint(streams[0].Values) != fmt.Sprint(want) {
+		t.Errorf("expected adjusted timestamps %v, got %v", want, streams[0].Values)
+	}
+}
+
+func TestDedupTimestampsAcrossBatches(t *testing.T) {
+	c := &Client{logger: &fakeLogger{}, dedupTimestamps: true, clock: newStreamClock(time.Minute)}
+	now := time.Unix(0, 1000)
+
+	first := c.buildStreams([]entry{
+		{Timestamp: now, Level: "info", Message: "first"},
+		{Timestamp: now, Level: "info", Message: "second"},
+	})
+	second := c.buildStreams([]entry{
+		{Timestamp: now, Level: "info", Message: "third"},
+		{Timestamp: now, Level: "error", Message: "other stream"},
+	})
+
+	if want := [][]string{{"1000", "first"}, {"1001", "second"}}; fmt.Sprint(first[0].Values) != fmt.Sprint(want) {
+		t.Errorf("expected first batch %v, got %v", want, first[0].Values)
+	}
+	if want := [][]string{{"1002", "third"}}; fmt.Sprint(second[0].Values) != fmt.Sprint(want) {
+		t.Errorf("expected duplicate of previous batch shifted, got %v", second[0].Values)
+	}
+	if want := [][]string{{"1000", "other stream"}}; fmt.Sprint(second[1].Values) != fmt.Sprint(want) {
+		t.Errorf("expected other stream untouched, got %v", second[1].Values)
+	}
+}
+
+func TestStreamClockSweep(t *testing.T) {
+	sc := newStreamClock(time.Minute)
+	start := time.Unix(1000, 0)
+
+	sc.set("b", 1, start)
+	sc.set("a", 1, start.Add(10*time.Second))
+	sc.set("b", 2, start.Add(65*time.Second))
+	// a is idle over window but the sweep waits for the next window
+	sc.set("b", 3, start.Add(100*time.Second))
+	if sc.get("a") != 1 {
+		t.Fatal("expected a kept until the next sweep")
+	}
+
+	sc.set("b", 4, start.Add(130*time.Second))
+	if sc.get("a") != 0 || sc.get("b") != 4 {
+		t.Errorf("expected idle a forgotten and b kept, got %v", sc.last)
+	}
+}
+
+func TestDedupTimestampsDisabled(t *testing.T) {
+	c := newClient(Config{URL: "http://127.0.0.1:0", DisableTimestampDedup: true, Logger: &fakeLogger{}})
+	defer c.Stop()
+
+	now := time.Unix(0, 1000)
+	first := c.buildStreams([]entry{{Timestamp: now, Level: "info", Message: "first"}})
+	second := c.buildStreams([]entry{{Timestamp: now, Level: "info", Message: "second"}})
+	if first[0].Values[0][0] != "1000" || second[0].Values[0][0] != "1000" {
+		t.Errorf("expected timestamps sent as is, got %v and %v", first[0].Values, second[0].Values)
+	}
+}
+
+func TestBatchWaitResetAfterSizeSend(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	const total, batchSize = 100, 10
+	c := NewClient(Config{URL: srv.URL, BatchSize: batchSize, BatchWait: 200 * time.Millisecond, Logger: &fakeLogger{}})
+	defer c.Stop()
+
+	// steady producer filling a batch well within BatchWait
+	for i := 0; i < total; i++ {
+		c.Log(time.Now(), "info", strconv.Itoa(i))
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	// give a stale ticker the chance to flush an extra batch
+	time.Sleep(300 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := (total + batchSize - 1) / batchSize; requests != want {
+		t.Errorf("expected %d requests, got %d", want, requests)
+	}
+}
+
+func TestAutoHostLabels(t *testing.T) {
+	t.Setenv("POD_NAME", "order-7d9f-abcde")
+	t.Setenv("POD_NAMESPACE", "checkout")
+	t.Setenv("NODE_NAME", "node-1")
+
+	c := NewClient(Config{
+		URL:               "http://localhost:3100",
+		Labels:            map[string]string{"namespace": "explicit"},
+		AutoHostLabels:    true,
+		ExcludeHostLabels: []string{LabelNode},
+		Logger:            &fakeLogger{},
+	})
+	defer c.Stop()
+
+	labels := c.buildStreams([]entry{{Timestamp: time.Now(), Level: "info", Message: "hello"}})[0].Stream
+	if labels[LabelPod] != "order-7d9f-abcde" || labels[LabelHost] == "" {
+		t.Errorf("expected pod and host labels, got %v", labels)
+	}
+
+	if labels[LabelNamespace] != "explicit" {
+		t.Errorf("expected explicit label wins, got %v", labels)
+	}
+
+	if _, ok := labels[LabelNode]; ok {
+		t.Errorf("expected node label excluded, got %v", labels)
+	}
+}
+
+func TestSetBatching(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		sizes []int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req pushRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+
+		n := 0
+		for _, s := range req.Streams {
+			n += len(s.Values)
+		}
+
+		mu.Lock()
+		sizes = append(sizes, n)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	waitBatches := func(n int) []int {
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			mu.Lock()
+			got := append([]int(nil), sizes...)
+			mu.Unlock()
+			if len(got) >= n {
+				return got
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("expected %d batches, got %v", n, sizes)
+		return nil
+	}
+
+	c := NewClient(Config{URL: srv.URL, BatchSize: 5, BatchWait: time.Hour, Logger: &fakeLogger{}})
+	defer c.Stop()
+
+	for i := 0; i < 5; i++ {
+		c.Log(time.Now(), "info", strconv.Itoa(i))
+	}
+	waitBatches(1)
+
+	if err := c.SetBatching(2, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		c.Log(time.Now(), "info", strconv.Itoa(i))
+	}
+	waitBatches(3)
+
+	if err := c.SetBatching(100, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		c.Log(time.Now(), "info", strconv.Itoa(i))
+	}
+
+	if got := waitBatches(4); fmt.Sprint(got) != "[5 2 2 3]" {
+		t.Errorf("expected batches honor new parameters, got %v", got)
+	}
+
+	if stats := c.Stats(); stats.BatchSize != 100 || stats.BatchWait != 50*time.Millisecond {
+		t.Errorf("expected stats reflect current batching, got %+v", stats)
+	}
+
+	if err := c.SetBatching(0, time.Second); !errors.Is(err, ErrInvalidBatching) {
+		t.Errorf("expected invalid batch size rejected, got %v", err)
+	}
+}
+
+func TestTruncateLineUTF8Boundary(t *testing.T) {
+	c := &Client{logger: &fakeLogger{}, maxLineBytes: 40}
+
+	for _, line := range []string{strings.Repeat("é", 50), strings.Repeat("世", 50), "a" + strings.Repeat("世", 50)} {
+		entries := c.limitLine(entry{Message: line})
+		if len(entries) != 1 {
+			t.Fatalf("expected single truncated entry, got %d", len(entries))
+		}
+
+		got := entries[0].Message
+		if len(got) > 40 || !utf8.ValidString(got) || !strings.Contains(got, "...[truncated ") {
+			t.Errorf("expected valid truncated line within limit, got %q (%d bytes)", got, len(got))
+		}
+
+		kept := got[:strings.Index(got, "...[truncated ")]
+		if want := fmt.Sprintf("...[truncated %d bytes]", len(line)-len(kept)); !strings.HasSuffix(got, want) {
+			t.Errorf("expected suffix %q, got %q", want, got)
+		}
+	}
+
+	if stats := c.Stats(); stats.Truncated != 3 {
+		t.Errorf("expected 3 truncated lines, got %+v", stats)
+	}
+}
+
+func TestSplitLongLines(t *testing.T) {
+	c := &Client{logger: &fakeLogger{}, maxLineBytes: 10, splitLongLines: true}
+	line := strings.Repeat("ab世", 10)
+	now := time.Unix(0, 1000)
+
+	entries := c.limitLine(entry{Timestamp: now, Level: "info", Message: line})
+	if len(entries) < 2 {
+		t.Fatalf("expected continuation entries, got %d", len(entries))
+	}
+
+	streams := c.buildStreams(append(entries, entry{Timestamp: now, Level: "info", Message: "next"}))
+	values, metadata := streams[0].Values, streams[0].metadata
+
+	var reassembled strings.Builder
+	id := metadata[0][MetadataFragmentId]
+	for i := range entries {
+		if metadata[i][MetadataFragmentId] != id || metadata[i][MetadataFragment] != fmt.Sprintf("%d/%d", i+1, len(entries)) {
+			t.Fatalf("expected fragment %d of %d sharing id, got %v", i+1, len(entries), metadata[i])
+		}
+		if len(values[i][1]) > 10 || !utf8.ValidString(values[i][1]) {
+			t.Errorf("expected valid fragment within limit, got %q", values[i][1])
+		}
+		reassembled.WriteString(values[i][1])
+	}
+
+	if reassembled.String() != line {
+		t.Errorf("expected fragments reassemble to %q, got %q", line, reassembled.String())
+	}
+
+	if metadata[len(entries)] != nil {
+		t.Errorf("expected no metadata on regular entry, got %v", metadata[len(entries)])
+	}
+
+	raw, _ := json.Marshal(streams[0])
+	if !strings.Contains(string(raw), `"`+MetadataFragmentId+`":"`+id+`"`) || !strings.Contains(string(raw), `"next"]`) {
+		t.Errorf("expected structured metadata encoded on fragments only, got %s", raw)
+	}
+
+	if stats := c.Stats(); stats.Split != 1 {
+		t.Errorf("expected 1 split line, got %+v", stats)
+	}
+}
+
+func TestClampLabelValue(t *testing.T) {
+	c := &Client{logger: &fakeLogger{}, maxLabelValueBytes: 5}
+
+	labels := c.buildStreams([]entry{{
+		Timestamp: time.Now(),
+		Level:     "info",
+		Message:   "hello",
+		Labels:    map[string]string{"tenant": "ab世界"},
+	}})[0].Stream
+
+	if labels["tenant"] != "ab世" {
+		t.Errorf("expected label value clamped at rune boundary, got %q", labels["tenant"])
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	for raw, want := range map[string]string{
+		"http://loki:3100":                     "http://loki:3100" + PushPath,
+		"http://loki:3100/":                    "http://loki:3100" + PushPath,
+		"https://logs.example.com/custom/push": "https://logs.example.com/custom/push",
+	} {
+		if got := normalizeURL(raw); got != want {
+			t.Errorf("%s: expected %s, got %s", raw, want, got)
+		}
+	}
+}
+
+func TestValidateOnStart(t *testing.T) {
+	status := func(code int) string {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != PushPath {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(code)
+		}))
+		t.Cleanup(srv.Close)
+		return srv.URL
+	}
+
+	refused := func() string {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = l.Close()
+		return "http://" + l.Addr().String()
+	}
+
+	for name, tc := range map[string]struct {
+		url  string
+		want error
+	}{
+		"ok":           {url: status(http.StatusNoContent)},
+		"base url":     {url: status(http.StatusNoContent) + "/"},
+		"wrong path":   {url: status(http.StatusNoContent) + "/api/push", want: ErrEndpointNotFound},
+		"unauthorized": {url: status(http.StatusUnauthorized), want: ErrUnauthorized},
+		"server error": {url: status(http.StatusServiceUnavailable), want: ErrUnexpectedResponse},
+		"scheme":       {url: "ftp://loki:3100", want: ErrInvalidURL},
+		"refused":      {url: refused(), want: ErrConnectionRefused},
+		"dns":          {url: "http://loki.invalid:3100", want: ErrDNS},
+	} {
+		fl := &fakeLogger{}
+		c, err := NewClientE(Config{URL: tc.url, ValidateOnStart: true, StrictStart: true, Logger: fl})
+		if tc.want == nil {
+			if err != nil {
+				t.Errorf("%s: expected valid endpoint, got %v", name, err)
+				continue
+			}
+			c.Stop()
+			continue
+		}
+
+		if !errors.Is(err, tc.want) || c != nil {
+			t.Errorf("%s: expected %v, got %v", name, tc.want, err)
+		}
+
+		if !fl.contains("error: startup validation") {
+			t.Errorf("%s: expected diagnostic logged, got %v", name, fl.messages)
+		}
+	}
+
+	// lenient start only logs the failure
+	fl := &fakeLogger{}
+	c, err := NewClientE(Config{URL: status(http.StatusUnauthorized), ValidateOnStart: true, Logger: fl})
+	if err != nil || c == nil || !fl.contains("unauthorized") {
+		t.Errorf("expected client created with logged diagnostic, got %v %v", err, fl.messages)
+	}
+	c.Stop()
+}
+
+func TestDropFilters(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		lines []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Streams []struct {
+				Values [][]string `json:"values"`
+			} `json:"streams"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+
+		mu.Lock()
+		for _, s := range req.Streams {
+			for _, v := range s.Values {
+				lines = append(lines, v[1])
+			}
+		}
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := NewClient(Config{
+		URL:       srv.URL,
+		BatchSize: 100,
+		BatchWait: 20 * time.Millisecond,
+		Logger:    &fakeLogger{},
+		DropFilters: []DropFilter{
+			DropContaining("GET /health"),
+			DropLevelMatching(LevelError, regexp.MustCompile(`^context canceled`)),
+		},
+	})
+	defer c.Stop()
+
+	c.Log(time.Now(), "info", "GET /health 200")
+	c.Log(time.Now(), "info", "GET /orders 200")
+	c.Log(time.Now(), LevelError, "context canceled by client")
+	c.Log(time.Now(), LevelWarn, "context canceled by client")
+	time.Sleep(100 * time.Millisecond)
+
+	// filters replaced at runtime, health checks are shipped again
+	c.SetDropFilters(DropMatching(regexp.MustCompile(`^GET /orders`)))
+	c.Log(time.Now(), "info", "GET /health 200")
+	c.Log(time.Now(), "info", "GET /orders 200")
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"GET /orders 200", "context canceled by client", "GET /health 200"}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("expected shipped lines %v, got %v", want, lines)
+	}
+
+	if got := c.Stats().DroppedByFilter; len(got) != 1 || got[0] != 1 {
+		t.Errorf("expected one entry dropped by the runtime filter, got %v", got)
+	}
+}
+
+func TestHealthy(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := NewClient(Config{URL: srv.URL, BatchSize: 1, BatchWait: time.Hour, Logger: &fakeLogger{}})
+	defer c.Stop()
+
+	waitHealthy := func(want bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for c.Healthy() != want && time.Now().Before(deadline) {
+			c.Log(time.Now(), "info", "probe")
+			time.Sleep(10 * time.Millisecond)
+		}
+		if c.Healthy() != want {
+			t.Fatalf("expected healthy %v", want)
+		}
+	}
+
+	waitHealthy(false)
+	failing.Store(false)
+	waitHealthy(true)
+}
+
+func TestEncodePushRequest(t *testing.T) {
+	req := pushRequest{Streams: []stream{
+		{
+			Stream: map[string]string{"service": "billing", "level": "info", "path": "<a&b>"},
+			Values: [][]string{
+				{"1", "plain"},
+				{"2", "quote \" backslash \\ html <script>&amp; ctrl \n\r\t\b\f\x01\x1f"},
+				{"3", "unicode é 日本    invalid \xff\xfe end"},
+			},
+			metadata: []map[string]string{nil, {"fragment_id": "x", "a": "<"}, nil},
+		},
+		{Stream: map[string]string{}, Values: [][]string{}},
+		{Stream: nil, Values: nil},
+	}}
+
+	for _, r := range []pushRequest{req, {}, {Streams: []stream{}}} {
+		want, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		encodePushRequest(&buf, r)
+		if got := buf.String(); got != string(want) {
+			t.Errorf("expected encoding/json output\n%s\ngot\n%s", want, got)
+		}
+	}
+}
+
+// largeBatch push request of n entries over 10 streams
+func largeBatch(n int) pushRequest {
+	entries := make([]entry, n)
+	now := time.Now()
+	for i := range entries {
+		entries[i] = entry{
+			Timestamp: now.Add(time.Duration(i)),
+			Level:     "info",
+			Message:   fmt.Sprintf(`{"msg":"request handled","path":"/orders/%d","status":200,"latency_ms":12.5}`, i),
+			Labels:    map[string]string{"route": strconv.Itoa(i % 10)},
+		}
+	}
+
+	c := newClient(Config{URL: "http://loki", Logger: &fakeLogger{}})
+	return pushRequest{Streams: c.buildStreams(entries)}
+}
+
+func BenchmarkEncodePushRequest(b *testing.B) {
+	req := largeBatch(5000)
+
+	b.Run("json.Marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := json.Marshal(req); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("stream", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf := getBuffer()
+			encodePushRequest(buf, req)
+			putBuffer(buf)
+		}
+	})
+}
+
+func TestSecondaryMirror(t *testing.T) {
+	primaryHits := make(chan time.Time, 8)
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryHits <- time.Now()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer primary.Close()
+
+	var secondaryHits atomic.Int64
+	auth := make(chan [2]string, 2)
+	release := make(chan struct{})
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secondaryHits.Add(1)
+		user, _, _ := r.BasicAuth()
+		auth <- [2]string{r.Header.Get("X-Scope-OrgID"), user}
+		// a slow and failing secondary
+		<-release
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer secondary.Close()
+	defer close(release)
+
+	c := NewClient(Config{
+		URL: primary.URL, BatchSize: 1, BatchWait: time.Hour, Logger: &fakeLogger{},
+
+		SecondaryURL: secondary.URL, SecondaryUsername: "migrator", SecondaryPassword: "s3cret", SecondaryTenantID: "tenant-b",
+	})
+	defer c.Stop()
+
+	start := time.Now()
+	c.Log(start, "info", "first")
+	c.Log(start, "info", "second")
+	for i := 0; i < 2; i++ {
+		select {
+		case at := <-primaryHits:
+			if at.Sub(start) > time.Second {
+				t.Errorf("expected primary push not delayed by secondary, took %s", at.Sub(start))
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected primary push while secondary hangs")
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for secondaryHits.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if secondaryHits.Load() != 2 {
+		t.Fatalf("expected both batches mirrored, got %d", secondaryHits.Load())
+	}
+	if got := <-auth; got != [2]string{"tenant-b", "migrator"} {
+		t.Errorf("expected secondary tenant and basic auth, got %v", got)
+	}
+
+	release <- struct{}{}
+	release <- struct{}{}
+	deadline = time.Now().Add(2 * time.Second)
+	for c.Stats().Secondary.Failed < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s := c.Stats(); s.Primary.Sent != 2 || s.Primary.Failed != 0 || s.Secondary.Failed != 2 || !c.Healthy() {
+		t.Errorf("expected separate counters per endpoint, got primary %+v secondary %+v", s.Primary, s.Secondary)
+	}
+}
+
+func TestMirrorSampleRate(t *testing.T) {
+	m := newMirror(Config{SecondaryURL: "http://localhost:1", MirrorSampleRate: 0.000001})
+	for i := 0; i < 100; i++ {
+		m.push(pushRequest{}, &fakeLogger{})
+	}
+
+	if s := m.counters.stats(); s.Skipped < 99 {
+		t.Errorf("expected most batches skipped by sample rate, got %+v", s)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	authURL := strings.Replace(server.URL, "http://", "http://user:s3cret@", 1)
+	client := NewClient(Config{
+		URL:          authURL,
+		BatchSize:    1,
+		BatchWait:    time.Hour,
+		Labels:       map[string]string{"app": "orders"},
+		Logger:       &fakeLogger{},
+		SecondaryURL: strings.Replace(server.URL, "http://", "http://mirror:m1rror@", 1),
+	})
+	defer client.Stop()
+
+	// first entry is being pushed, the next ones wait on the queue
+	for i := 0; i < 3; i++ {
+		client.Log(time.Now(), "info", "line")
+	}
+
+	var d Description
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if d = client.Describe(); d.QueueDepth == 2 {
+			break
+		}
+	}
+	if d.QueueDepth != 2 || d.QueueCapacity != 2 || d.LastPush != nil {
+		t.Errorf("expected 2 queued entries behind the pending push, got %+v", d)
+	}
+
+	raw, _ := json.Marshal(d)
+	if strings.Contains(string(raw), "s3cret") || strings.Contains(string(raw), "m1rror") {
+		t.Errorf("expected passwords masked, got %s", raw)
+	}
+	if !strings.HasPrefix(d.URL, "http://user:xxxxx@") || !strings.HasPrefix(d.Secondary.URL, "http://mirror:xxxxx@") {
+		t.Errorf("expected masked userinfo, got %s and %s", d.URL, d.Secondary.URL)
+	}
+	if d.Labels["app"] != "orders" || d.BatchSize != 1 || d.BatchWait != "1h0m0s" || !d.Healthy {
+		t.Errorf("unexpected configuration %+v", d)
+	}
+
+	close(release)
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if d = client.Describe(); d.QueueDepth == 0 && d.LastPush != nil {
+			break
+		}
+	}
+	if d.QueueDepth != 0 || d.LastPush == nil || !d.LastPush.OK {
+		t.Errorf("expected drained queue and successful last push, got %+v", d)
+	}
+}
+
+func TestPushRetry(t *testing.T) {
+	serve := func(fail int, status int, header http.Header) (*httptest.Server, *atomic.Int64) {
+		var attempts atomic.Int64
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if attempts.Add(1) <= int64(fail) {
+				for k, v := range header {
+					w.Header()[k] = v
+				}
+				w.WriteHeader(status)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		t.Cleanup(srv.Close)
+		return srv, &attempts
+	}
+
+	req := pushRequest{Streams: []stream{{Stream: map[string]string{"level": "info"}, Values: [][]string{{"1", "hello"}}}}}
+
+	t.Run("retry server errors until success", func(t *testing.T) {
+		srv, attempts := serve(2, http.StatusServiceUnavailable, nil)
+		c := newClient(Config{URL: srv.URL, Logger: &fakeLogger{}, MaxRetries: 3, MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond})
+
+		if err := c.push(req); err != nil {
+			t.Fatalf("expected push to succeed after retries, got %v", err)
+		}
+		if attempts.Load() != 3 || c.Stats().Primary.Retried != 2 {
+			t.Errorf("expected 3 attempts and 2 retries, got %d and %d", attempts.Load(), c.Stats().Primary.Retried)
+		}
+	})
+
+	t.Run("give up after max retries", func(t *testing.T) {
+		srv, attempts := serve(10, http.StatusBadGateway, nil)
+		fl := &fakeLogger{}
+		c := newClient(Config{URL: srv.URL, Logger: fl, MaxRetries: 2, MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond})
+
+		if err := c.push(req); err == nil {
+			t.Fatal("expected push error after max retries")
+		}
+		if attempts.Load() != 3 || !fl.contains("warn: push attempt 2 failed") {
+			t.Errorf("expected 3 attempts with retries logged, got %d: %v", attempts.Load(), fl.messages)
+		}
+	})
+
+	t.Run("client errors are not retried", func(t *testing.T) {
+		srv, attempts := serve(10, http.StatusBadRequest, nil)
+		c := newClient(Config{URL: srv.URL, Logger: &fakeLogger{}, MaxRetries: 3, MinBackoff: time.Millisecond})
+
+		if err := c.push(req); err == nil || attempts.Load() != 1 {
+			t.Errorf("expected a single rejected attempt, got %d: %v", attempts.Load(), err)
+		}
+	})
+
+	t.Run("zero max retries never retry", func(t *testing.T) {
+		srv, attempts := serve(10, http.StatusServiceUnavailable, nil)
+		c := newClient(Config{URL: srv.URL, Logger: &fakeLogger{}})
+
+		if err := c.push(req); err == nil || attempts.Load() != 1 {
+			t.Errorf("expected a single failed attempt, got %d: %v", attempts.Load(), err)
+		}
+	})
+
+	t.Run("honor retry after beyond max backoff", func(t *testing.T) {
+		srv, attempts := serve(1, http.StatusTooManyRequests, http.Header{"Retry-After": {"1"}})
+		c := newClient(Config{URL: srv.URL, Logger: &fakeLogger{}, MaxRetries: 1, MinBackoff: time.Millisecond, MaxBackoff: 50 * time.Millisecond})
+
+		start := time.Now()
+		if err := c.push(req); err != nil {
+			t.Fatalf("expected push to succeed after 429, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < time.Second || elapsed > 5*time.Second || attempts.Load() != 2 {
+			t.Errorf("expected one retry after Retry-After, got %d attempts in %v", attempts.Load(), elapsed)
+		}
+	})
+
+	t.Run("give up retry after beyond max retry after", func(t *testing.T) {
+		srv, attempts := serve(1, http.StatusTooManyRequests, http.Header{"Retry-After": {"30"}})
+		c := newClient(Config{URL: srv.URL, Logger: &fakeLogger{}, MaxRetries: 3, MinBackoff: time.Millisecond, MaxRetryAfter: time.Second})
+
+		start := time.Now()
+		if err := c.push(req); err == nil || attempts.Load() != 1 || time.Since(start) > time.Second {
+			t.Errorf("expected batch given up without waiting, got %d attempts: %v", attempts.Load(), err)
+		}
+	})
+
+	t.Run("give up past max retry duration", func(t *testing.T) {
+		srv, attempts := serve(10, http.StatusTooManyRequests, http.Header{"Retry-After": {"1"}})
+		fl := &fakeLogger{}
+		c := newClient(Config{URL: srv.URL, Logger: fl, MaxRetries: 10, MinBackoff: time.Millisecond, MaxRetryDuration: 1500 * time.Millisecond})
+
+		start := time.Now()
+		if err := c.push(req); err == nil || attempts.Load() != 2 || time.Since(start) > 3*time.Second {
+			t.Errorf("expected batch given up within max retry duration, got %d attempts: %v", attempts.Load(), err)
+		}
+		if !fl.contains("giving up as retries exceed") {
+			t.Errorf("expected give up logged, got %v", fl.messages)
+		}
+	})
+
+	t.Run("stop abort the wait", func(t *testing.T) {
+		srv, _ := serve(10, http.StatusServiceUnavailable, nil)
+		c := newClient(Config{URL: srv.URL, Logger: &fakeLogger{}, MaxRetries: 5, MinBackoff: time.Hour, MaxBackoff: time.Hour, MaxRetryDuration: 10 * time.Hour})
+		close(c.done)
+
+		start := time.Now()
+		if err := c.push(req); err == nil || time.Since(start) > 5*time.Second {
+			t.Errorf("expected push to return the error once stopped, got %v", err)
+		}
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := map[string]time.Duration{
+		"":     0,
+		"3":    3 * time.Second,
+		"-1":   0,
+		"soon": 0,
+		now.Add(10 * time.Second).Format(http.TimeFormat): 10 * time.Second,
+		now.Add(-time.Minute).Format(http.TimeFormat):     0,
+	}
+	for value, want := range cases {
+		if got := parseRetryAfter(value, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestAllowedLabels(t *testing.T) {
+	c := newClient(Config{
+		URL:           "http://loki",
+		Labels:        map[string]string{"service": "order"},
+		AllowedLabels: []string{"tenant"},
+		Logger:        &fakeLogger{},
+	})
+
+	streams := c.buildStreams([]entry{
+		{Timestamp: time.Now(), Level: "info", Message: "a", Labels: map[string]string{"tenant": "acme", "request_id": "r1"}},
+		{Timestamp: time.Now(), Level: "info", Message: "b", Labels: map[string]string{"request_id": "r2", "tenant": "acme"}},
+		{Timestamp: time.Now(), Level: "info", Message: "c"},
+	})
+
+	if len(streams) != 2 {
+		t.Fatalf("expected entries sharing the allowed labels in one stream, got %v", streams)
+	}
+
+	for _, s := range streams {
+		if _, ok := s.Stream["request_id"]; ok {
+			t.Errorf("expected unknown label stripped, got %v", s.Stream)
+		}
+		if s.Stream["service"] != "order" || s.Stream["level"] != "info" {
+			t.Errorf("expected static labels and level kept, got %v", s.Stream)
+		}
+		if s.Stream["tenant"] == "acme" && len(s.Values) != 2 {
+			t.Errorf("expected two entries on the tenant stream, got %v", s.Values)
+		}
+	}
+}
+
+func TestHooksAndCounters(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		drops   = map[string]int{}
+		errs    int
+		release = make(chan struct{})
+		blocked = make(chan struct{})
+		failing atomic.Bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		close(blocked)
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	fl := &fakeLogger{}
+	c := NewClient(Config{
+		URL: srv.URL, BatchSize: 1, BatchWait: time.Hour, Logger: fl,
+		OnError: func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs++
+		},
+		OnDrop: func(e DroppedEntry) {
+			mu.Lock()
+			defer mu.Unlock()
+			drops[e.Reason]++
+		},
+	})
+	defer c.Stop()
+
+	// the first push blocks the queue goroutine, the queue of 2 entries fills up
+	const logged = 10
+	c.Log(time.Now(), "info", "entry 0")
+	<-blocked
+	for n := 1; n < logged; n++ {
+		c.Log(time.Now(), "info", fmt.Sprintf("entry %d", n))
+	}
+
+	st := c.Stats()
+	mu.Lock()
+	full := drops[DropQueueFull]
+	mu.Unlock()
+	if st.Enqueued != 3 || st.Dropped != logged-3 || int64(full) != st.Dropped {
+		t.Errorf("expected every entry enqueued or dropped once, got enqueued %d dropped %d hook %d", st.Enqueued, st.Dropped, full)
+	}
+	if fl.contains("queue full") {
+		t.Errorf("expected drops reported to OnDrop instead of the logger, got %v", fl.messages)
+	}
+
+	failing.Store(true)
+	close(release)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for c.Stats().Primary.Sent+c.Stats().Primary.Failed < st.Enqueued && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	st = c.Stats()
+	mu.Lock()
+	defer mu.Unlock()
+	if st.Primary.Sent != 1 || st.EntriesSent != 1 {
+		t.Errorf("expected the blocked push sent, got %d batches %d entries", st.Primary.Sent, st.EntriesSent)
+	}
+	if failed := st.Enqueued - 1; st.Primary.Failed != failed || int64(errs) != failed || int64(drops[DropPushFailed]) != failed {
+		t.Errorf("expected %d failed pushes reported, got failed %d errors %d drops %v", failed, st.Primary.Failed, errs, drops)
+	}
+	if st.Dropped != int64(full+drops[DropPushFailed]) {
+		t.Errorf("expected dropped to count full queue and failed pushes, got %d", st.Dropped)
+	}
+	if fl.contains("push rejected") {
+		t.Errorf("expected push errors reported to OnError instead of the logger, got %v", fl.messages)
+	}
+}
+
+func TestStopWaitsForMirror(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer primary.Close()
+
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// a slow secondary still pushing when Stop is called
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer secondary.Close()
+
+	c := NewClient(Config{URL: primary.URL, BatchSize: 1, BatchWait: time.Hour, Logger: &fakeLogger{}, SecondaryURL: secondary.URL})
+	c.Log(time.Now(), "info", "mirrored")
+	c.Stop()
+
+	if s := c.Stats(); s.Primary.Sent != 1 || s.Secondary.Sent != 1 {
+		t.Errorf("expected mirror push done once Stop returns, got primary %+v secondary %+v", s.Primary, s.Secondary)
+	}
+}
+
+func TestOnDropCallingStop(t *testing.T) {
+	release := make(chan struct{})
+	blocked := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		blocked <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	var c *Client
+	var once sync.Once
+	c = NewClient(Config{
+		URL: srv.URL, BatchSize: 1, BatchWait: time.Hour, StopTimeout: 50 * time.Millisecond, Logger: &fakeLogger{},
+		OnDrop: func(DroppedEntry) { once.Do(c.Stop) },
+	})
+
+	// the first push blocks the queue goroutine, the next entries fill the queue and the last one is dropped
+	c.Log(time.Now(), "info", "entry 0")
+	<-blocked
+	logged := make(chan struct{})
+	go func() {
+		for n := 1; n < 4; n++ {
+			c.Log(time.Now(), "info", fmt.Sprintf("entry %d", n))
+		}
+		close(logged)
+	}()
+
+	select {
+	case <-logged:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected Log to return when OnDrop calls Stop")
+	}
+}
+
+func TestStopFlushesQueue(t *testing.T) {
+	var received atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req pushRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode push: %v", err)
+		}
+		// slow pushes keep entries waiting on the queue when Stop is called
+		time.Sleep(5 * time.Millisecond)
+		for _, s := range req.Streams {
+			received.Add(int64(len(s.Values)))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	const logged = 500
+	c := NewClient(Config{URL: srv.URL, BatchSize: 300, BatchWait: time.Hour, Logger: &fakeLogger{}})
+	for n := 0; n < logged; n++ {
+		c.Log(time.Now(), "info", fmt.Sprintf("entry %d", n))
+	}
+	if st := c.Stats(); st.Enqueued != logged {
+		t.Fatalf("expected every entry enqueued, got %d", st.Enqueued)
+	}
+
+	c.Stop()
+	if n := received.Load(); n != logged {
+		t.Errorf("expected all %d entries received once Stop returns, got %d", logged, n)
+	}
+
+	// Log after Stop is a no-op, Stop again returns at once
+	c.Log(time.Now(), "info", "after stop")
+	c.Stop()
+	if st := c.Stats(); st.Enqueued != logged || st.Queued != 0 {
+		t.Errorf("expected entries after stop ignored, got %+v", st)
+	}
+}
+
+func TestStopWithContextDeadline(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	c := NewClient(Config{URL: srv.URL, BatchSize: 1, BatchWait: time.Hour, Logger: &fakeLogger{}})
+	c.Log(time.Now(), "info", "stuck")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.StopWithContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline of caller, got %v", err)
+	}
+}
+
+func TestRejectedPushBody(t *testing.T) {
+	bodies := []struct {
+		status int
+		body   string
+	}{
+		{http.StatusTooManyRequests, "Ingestion rate limit exceeded for user fake (limit: 4194304 bytes/sec) while attempting to ingest '120' lines totaling '1048576' bytes, reduce log volume or contact your Loki administrator to see if the limit can be increased"},
+		{http.StatusBadRequest, `entry with timestamp 2024-01-01 00:00:00 +0000 UTC ignored, reason: 'entry out of order' for stream: {level="info"},`},
+		{http.StatusBadRequest, `entry for stream '{level="info"}' has timestamp too old: 2020-01-01T00:00:00Z, oldest acceptable timestamp is: 2024-01-01T00:00:00Z`},
+		{http.StatusBadRequest, `error at least one label pair is required per stream`},
+		{http.StatusBadRequest, strings.Repeat("x", 3000)},
+	}
+
+	var (
+		next      atomic.Int64
+		userAgent atomic.Value
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent.Store(r.Header.Get("User-Agent"))
+		b := bodies[min(int(next.Add(1)-1), len(bodies)-1)]
+		w.WriteHeader(b.status)
+		_, _ = w.Write([]byte(b.body + "\n"))
+	}))
+	defer srv.Close()
+
+	fl := &fakeLogger{}
+	c := newClient(Config{URL: srv.URL, Logger: fl})
+	defer c.Stop()
+
+	var errs []error
+	for range bodies {
+		errs = append(errs, c.push(pushRequest{}))
+	}
+
+	want := RejectStats{RateLimited: 1, OutOfOrder: 1, TooOld: 1, Other: 2}
+	if got := c.Stats().Rejected; got != want {
+		t.Errorf("expected rejections classified %+v, got %+v", want, got)
+	}
+
+	if !strings.Contains(errs[1].Error(), "400 Bad Request: entry with timestamp") {
+		t.Errorf("expected message of loki on error, got %v", errs[1])
+	}
+	if msg := errs[4].Error(); !strings.HasSuffix(msg, "...(truncated)") || len(msg) > maxErrorBodyBytes+100 {
+		t.Errorf("expected body truncated to 2KB, got %d bytes", len(msg))
+	}
+
+	if ua, _ := userAgent.Load().(string); !strings.HasPrefix(ua, "gokit-loki/") || ua != UserAgent {
+		t.Errorf("expected gokit user agent, got %q", ua)
+	}
+
+	// the message of loki reaches the logger of failed batches
+	c.sendBatch([]entry{{Timestamp: time.Now(), Level: "info", Message: "x"}})
+	if !fl.contains("error: push rejected") {
+		t.Errorf("expected rejected push logged, got %v", fl.messages)
+	}
+}
+
+func TestPushAuthAndHeaders(t *testing.T) {
+	envtest.OverrideForTest(t, "TEST_LOKI_PASSWORD", "s3cret")
+
+	headers := make(chan http.Header, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := NewClient(Config{
+		URL: srv.URL, BatchSize: 1, BatchWait: time.Hour, Logger: &fakeLogger{},
+		Username:    "123456",
+		PasswordEnv: "TEST_LOKI_PASSWORD",
+		TenantID:    " tenant-a ",
+		Headers:     map[string]string{"X-Gateway-Token": "abc"},
+	})
+	defer c.Stop()
+	c.Log(time.Now(), "info", "hello")
+
+	var h http.Header
+	select {
+	case h = <-headers:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a push")
+	}
+
+	req := &http.Request{Header: h}
+	if user, pass, ok := req.BasicAuth(); !ok || user != "123456" || pass != "s3cret" {
+		t.Errorf("expected basic auth with password of env, got %q %q %v", user, pass, ok)
+	}
+	if got := h.Get("X-Scope-OrgID"); got != "tenant-a" {
+		t.Errorf("expected trimmed tenant header, got %q", got)
+	}
+	if got := h.Get("X-Gateway-Token"); got != "abc" {
+		t.Errorf("expected custom header, got %q", got)
+	}
+
+	// blank tenant and no credentials send neither header
+	for _, tenant := range []string{"", "   "} {
+		hs := newHTTPSender(srv.URL, http.DefaultClient, "", "", tenant, nil)
+		if err := hs.send(context.Background(), pushRequest{}); err != nil {
+			t.Fatal(err)
+		}
+
+		h = <-headers
+		if _, ok := h["X-Scope-Orgid"]; ok || h.Get("Authorization") != "" {
+			t.Errorf("tenant %q: expected no tenant nor auth header, got %v", tenant, h)
+		}
+	}
+}
+
+// roundTripFunc http.RoundTripper of a function
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestSendBodyReplayable(t *testing.T) {
+	var body, replay []byte
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ = io.ReadAll(req.Body)
+		if req.GetBody == nil {
+			t.Fatal("expected GetBody set for retries")
+		}
+		rc, err := req.GetBody()
+		if err != nil {
+			t.Fatal(err)
+		}
+		replay, _ = io.ReadAll(rc)
+		return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody}, nil
+	})}
+
+	hs := newHTTPSender("http://loki.local/loki/api/v1/push", client, "", "", "", nil)
+	if err := hs.send(context.Background(), pushRequest{Streams: []stream{{}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(body) < 1 || !bytes.Equal(body, replay) {
+		t.Errorf("expected replayed body equal to sent body, got %q and %q", body, replay)
+	}
+}
@@ -0,0 +1,88 @@
+package loki
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxMirrorInFlight pushes to the secondary endpoint running at once, batches beyond are skipped
+const maxMirrorInFlight = 4
+
+// EndpointStats push outcome of an endpoint
+type EndpointStats struct {
+	Sent    int64 // batches accepted
+	Failed  int64 // batches failed or rejected, after retries
+	Skipped int64 // batches not mirrored by MirrorSampleRate or the in-flight limit, secondary only
+	Retried int64 // push attempts sent again by Config.MaxRetries, primary only
+}
+
+// endpointCounters counters behind EndpointStats
+type endpointCounters struct {
+	sent, failed, skipped, retried atomic.Int64
+}
+
+func (c *endpointCounters) stats() EndpointStats {
+	return EndpointStats{Sent: c.sent.Load(), Failed: c.failed.Load(), Skipped: c.skipped.Load(), Retried: c.retried.Load()}
+}
+
+// mirror best-effort duplicate of pushes into a secondary endpoint, e.g. during a Loki migration.
+// a failing secondary is never retried and never delays nor fails the primary push
+type mirror struct {
+	sender     sender
+	sampleRate float64
+	slots      chan struct{}
+	counters   endpointCounters
+	inFlight   sync.WaitGroup // pushes in background, waited by Stop
+}
+
+func newMirror(config Config) *mirror {
+	if config.SecondaryURL == "" {
+		return nil
+	}
+
+	rate := config.MirrorSampleRate
+	if rate <= 0 || rate > 1 {
+		rate = 1
+	}
+
+	return &mirror{
+		sender: newHTTPSender(normalizeURL(config.SecondaryURL), config.HTTPClient,
+			config.SecondaryUsername, config.SecondaryPassword, config.SecondaryTenantID, nil),
+		sampleRate: rate,
+		slots:      make(chan struct{}, maxMirrorInFlight),
+	}
+}
+
+// push send req to the secondary endpoint in background when sampled and a slot is free
+func (m *mirror) push(req pushRequest, logger Logger) {
+	if m.sampleRate < 1 && rand.Float64() >= m.sampleRate {
+		m.counters.skipped.Add(1)
+		return
+	}
+
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		m.counters.skipped.Add(1)
+		return
+	}
+
+	m.inFlight.Add(1)
+	go func() {
+		defer m.inFlight.Done()
+		defer func() { <-m.slots }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := m.sender.send(ctx, req); err != nil {
+			m.counters.failed.Add(1)
+			logger.Logf(LevelWarn, "secondary: %v", err)
+			return
+		}
+		m.counters.sent.Add(1)
+	}()
+}
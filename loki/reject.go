@@ -0,0 +1,116 @@
+package loki
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
+)
+
+// maxErrorBodyBytes response body of a rejected push kept on its error
+const maxErrorBodyBytes = 2 << 10
+
+// modulePath module of client, its version identifies the client on User-Agent
+const modulePath = "github.com/TixiaOTA/gokit"
+
+// UserAgent User-Agent header of every request to Loki, e.g. "gokit-loki/v1.4.0"
+var UserAgent = "gokit-loki/" + moduleVersion()
+
+// classes of rejected pushes, see RejectStats
+const (
+	RejectRateLimited = "rate_limited"
+	RejectOutOfOrder  = "out_of_order"
+	RejectTooOld      = "too_old"
+	RejectOther       = "other"
+)
+
+// RejectStats rejected push responses of the primary endpoint by class of the message of Loki, retried attempts included
+type RejectStats struct {
+	RateLimited int64 // 429 or ingestion rate limit exceeded
+	OutOfOrder  int64 // entry out of order
+	TooOld      int64 // entry too far behind or older than the maximum sample age
+	Other       int64
+}
+
+// rejectCounters counters behind RejectStats
+type rejectCounters struct {
+	rateLimited, outOfOrder, tooOld, other atomic.Int64
+}
+
+func (c *rejectCounters) stats() RejectStats {
+	return RejectStats{
+		RateLimited: c.rateLimited.Load(),
+		OutOfOrder:  c.outOfOrder.Load(),
+		TooOld:      c.tooOld.Load(),
+		Other:       c.other.Load(),
+	}
+}
+
+// record count err when it is a rejected push
+func (c *rejectCounters) record(err error) {
+	var pe *pushError
+	if !errors.As(err, &pe) {
+		return
+	}
+
+	switch classifyReject(pe.status, pe.body) {
+	case RejectRateLimited:
+		c.rateLimited.Add(1)
+	case RejectOutOfOrder:
+		c.outOfOrder.Add(1)
+	case RejectTooOld:
+		c.tooOld.Add(1)
+	default:
+		c.other.Add(1)
+	}
+}
+
+// classifyReject class of a rejected push by status and the message of Loki on body
+func classifyReject(status int, body string) string {
+	msg := strings.ToLower(body)
+	switch {
+	case status == http.StatusTooManyRequests || strings.Contains(msg, "rate limit"):
+		return RejectRateLimited
+	case strings.Contains(msg, "out of order"):
+		return RejectOutOfOrder
+	case strings.Contains(msg, "too far behind"), strings.Contains(msg, "too old"), strings.Contains(msg, "greater_than_max_sample_age"):
+		return RejectTooOld
+	default:
+		return RejectOther
+	}
+}
+
+// readErrorBody response body capped to maxErrorBodyBytes, marked when truncated
+func readErrorBody(resp *http.Response) string {
+	buf, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes+1))
+	body := strings.TrimSpace(string(buf))
+	if len(buf) > maxErrorBodyBytes {
+		body = strings.TrimSpace(string(buf[:maxErrorBodyBytes])) + "...(truncated)"
+	}
+
+	return body
+}
+
+// moduleVersion version of the gokit module in the build, "devel" when unknown, e.g. in tests
+func moduleVersion() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "devel"
+	}
+
+	if bi.Main.Path == modulePath && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		return bi.Main.Version
+	}
+	for _, dep := range bi.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil && dep.Replace.Version != "" {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+
+	return "devel"
+}
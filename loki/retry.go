@@ -0,0 +1,133 @@
+package loki
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultMinBackoff first retry delay when Config.MaxRetries is set
+	DefaultMinBackoff = 500 * time.Millisecond
+	// DefaultMaxBackoff maximum retry delay when Config.MaxRetries is set
+	DefaultMaxBackoff = 5 * time.Second
+	// DefaultMaxRetryAfter longest Retry-After honored when Config.MaxRetries is set
+	DefaultMaxRetryAfter = 30 * time.Second
+	// DefaultMaxRetryDuration total retry time of a batch when Config.MaxRetries is set
+	DefaultMaxRetryDuration = 10 * time.Second
+)
+
+// pushError push rejected by loki with a non 2xx status
+type pushError struct {
+	status     int
+	statusText string
+	body       string        // message of Loki, capped to maxErrorBodyBytes
+	retryAfter time.Duration // Retry-After of the response, zero when absent
+}
+
+func (e *pushError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("push rejected: %s", e.statusText)
+	}
+
+	return fmt.Sprintf("push rejected: %s: %s", e.statusText, e.body)
+}
+
+// newPushError push error of a rejected response, reading its body
+func newPushError(resp *http.Response) *pushError {
+	return &pushError{
+		status:     resp.StatusCode,
+		statusText: resp.Status,
+		body:       readErrorBody(resp),
+		retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// retryable report whether a failed push may succeed when sent again, 429 and 5xx responses
+// and transport errors are retried, other 4xx responses never succeed
+func retryable(err error) bool {
+	var pe *pushError
+	if errors.As(err, &pe) {
+		return pe.status == http.StatusTooManyRequests || pe.status >= 500
+	}
+
+	return true
+}
+
+// parseRetryAfter delay of Retry-After in seconds or as http date, zero when absent or invalid
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+
+	return 0
+}
+
+// push send req to the primary endpoint, retryable failures are sent again up to maxRetries times with
+// exponential backoff and jitter capped by maxBackoff. Retry-After is honored up to maxRetryAfter, a longer
+// one gives up the batch instead of retrying too early. the batch is given up once a retry would end past
+// maxRetryDuration, pushes run on the queue goroutine and entries are dropped while it waits. waits are aborted by Stop
+func (c *Client) push(req pushRequest) error {
+	delay := c.minBackoff
+	deadline := time.Now().Add(c.maxRetryDuration)
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := c.sender.send(ctx, req)
+		cancel()
+		c.rejects.record(err)
+
+		if err == nil || attempt >= c.maxRetries || !retryable(err) {
+			return err
+		}
+
+		wait := delay
+		if delay > 1 {
+			wait += time.Duration(rand.Int63n(int64(delay / 2)))
+		}
+		if wait > c.maxBackoff {
+			wait = c.maxBackoff
+		}
+
+		var pe *pushError
+		if errors.As(err, &pe) && pe.retryAfter > wait {
+			if pe.retryAfter > c.maxRetryAfter {
+				c.logger.Logf(LevelWarn, "push attempt %d failed, giving up as Retry-After %s exceeds %s: %v", attempt+1, pe.retryAfter, c.maxRetryAfter, err)
+				return err
+			}
+			wait = pe.retryAfter
+		}
+
+		if time.Now().Add(wait).After(deadline) {
+			c.logger.Logf(LevelWarn, "push attempt %d failed, giving up as retries exceed %s: %v", attempt+1, c.maxRetryDuration, err)
+			return err
+		}
+
+		c.primary.retried.Add(1)
+		c.logger.Logf(LevelWarn, "push attempt %d failed, retrying in %s: %v", attempt+1, wait, err)
+
+		select {
+		case <-c.done:
+			return err
+		case <-time.After(wait):
+		}
+
+		if delay *= 2; delay > c.maxBackoff {
+			delay = c.maxBackoff
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package loki
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// sender transport of push request
+type sender interface {
+	send(ctx context.Context, req pushRequest) error
+}
+
+// httpSender push request to loki push API over http
+type httpSender struct {
+	url    string
+	client *http.Client
+
+	// basic auth and X-Scope-OrgID of push, skipped when empty
+	username, password string
+	tenantID           string
+	// headers set on every push after the others, e.g. a bearer token of a gateway
+	headers map[string]string
+}
+
+// newHTTPSender sender of url with basic auth, tenant and headers, blank tenant is skipped
+func newHTTPSender(url string, client *http.Client, username, password, tenantID string, headers map[string]string) *httpSender {
+	return &httpSender{
+		url:      url,
+		client:   client,
+		username: username,
+		password: password,
+		tenantID: strings.TrimSpace(tenantID),
+		headers:  headers,
+	}
+}
+
+// setHeaders set content type, user agent, basic auth, tenant and custom headers on req
+func (s *httpSender) setHeaders(req *http.Request) {
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", UserAgent)
+	if s.username != "" || s.password != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+	if s.tenantID != "" {
+		req.Header.Set("X-Scope-OrgID", s.tenantID)
+	}
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+}
+
+func (s *httpSender) send(ctx context.Context, req pushRequest) error {
+	// reused only once Do returns, the transport reads the body until then, GetBody included on retries
+	buf := getBuffer()
+	defer putBuffer(buf)
+	encodePushRequest(buf, req)
+
+	// bytes.Reader body sets ContentLength and GetBody
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return fmt.Errorf("create push request: %w", err)
+	}
+
+	s.setHeaders(httpReq)
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("send push request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newPushError(resp)
+	}
+
+	return nil
+}
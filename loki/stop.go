@@ -0,0 +1,71 @@
+package loki
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultStopTimeout maximum time Stop waits for the queue to be flushed
+const DefaultStopTimeout = 10 * time.Second
+
+// Stop gracefully shuts down the client, entries still queued are sent and Stop waits for the last push
+// up to Config.StopTimeout. Log after Stop is a no-op
+func (c *Client) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), c.stopTimeout)
+	defer cancel()
+
+	if err := c.StopWithContext(ctx); err != nil {
+		c.logger.Logf(LevelWarn, "stop: queue not flushed within %s, %d entries left", c.stopTimeout, len(c.entriesQueue))
+	}
+}
+
+// StopWithContext Stop waiting for the last push until ctx is done, the error of ctx is returned when the queue
+// is not flushed in time, the flush then goes on in background. calling it again waits for the same flush
+func (c *Client) StopWithContext(ctx context.Context) error {
+	c.stopOnce.Do(func() {
+		// wait for Log calls enqueuing, none enqueue once stopped
+		c.stopMu.Lock()
+		c.stopped = true
+		c.stopMu.Unlock()
+
+		close(c.done)
+	})
+
+	select {
+	case <-c.finished:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if c.mirror == nil {
+		return nil
+	}
+
+	mirrored := make(chan struct{})
+	go func() {
+		c.mirror.inFlight.Wait()
+		close(mirrored)
+	}()
+
+	select {
+	case <-mirrored:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// flushQueue send batch and every entry left on the queue in batches of size, run by processQueue on Stop
+func (c *Client) flushQueue(batch []entry, size int) {
+	for {
+		full := c.drainQueue(&batch, size)
+		if len(batch) > 0 {
+			c.sendBatch(batch)
+			batch = make([]entry, 0, size)
+		}
+
+		if !full {
+			return
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package loki
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// overflowLabel label marking the fallback stream when MaxActiveStreams is exceeded
+const overflowLabel = "overflow"
+
+// streamTracker track distinct active streams over a sliding window
+type streamTracker struct {
+	max      int
+	window   time.Duration
+	seen     map[string]time.Time
+	warnedAt time.Time
+	// no stream of seen expires before, see expire
+	nextExpiry time.Time
+}
+
+func newStreamTracker(max int, window time.Duration) *streamTracker {
+	return &streamTracker{
+		max:    max,
+		window: window,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// admit report whether stream key can be used as its own stream,
+// tripped is true the first time the limit is exceeded in the current window
+func (t *streamTracker) admit(key string, now time.Time) (admitted, tripped bool) {
+	if t == nil || t.max <= 0 {
+		return true, false
+	}
+
+	if _, ok := t.seen[key]; ok {
+		t.seen[key] = now
+		return true, false
+	}
+
+	// seen holds at most max streams, they are expired only once the limit is reached
+	if len(t.seen) >= t.max && !now.Before(t.nextExpiry) {
+		t.expire(now)
+	}
+
+	if len(t.seen) < t.max {
+		t.seen[key] = now
+		return true, false
+	}
+
+	if now.Sub(t.warnedAt) > t.window {
+		t.warnedAt = now
+		return false, true
+	}
+
+	return false, false
+}
+
+// expire forget streams out of window and note when the oldest stream left expires
+func (t *streamTracker) expire(now time.Time) {
+	oldest := now
+	for k, last := range t.seen {
+		if now.Sub(last) > t.window {
+			delete(t.seen, k)
+			continue
+		}
+		if last.Before(oldest) {
+			oldest = last
+		}
+	}
+
+	t.nextExpiry = oldest.Add(t.window)
+}
+
+// streamClock last timestamp sent by stream, streams idle over window are forgotten
+type streamClock struct {
+	window time.Duration
+	last   map[string]streamTick
+	// idle streams are swept at most once per window, see set
+	nextSweep time.Time
+}
+
+type streamTick struct {
+	ts   int64
+	seen time.Time
+}
+
+func newStreamClock(window time.Duration) *streamClock {
+	return &streamClock{
+		window: window,
+		last:   make(map[string]streamTick),
+	}
+}
+
+// get last timestamp of stream key, 0 when unknown
+func (sc *streamClock) get(key string) int64 {
+	if sc == nil {
+		return 0
+	}
+
+	return sc.last[key].ts
+}
+
+// set last timestamp of stream key, streams idle over window are forgotten once per window
+// so an idle stream is kept at most twice the window
+func (sc *streamClock) set(key string, ts int64, now time.Time) {
+	if sc == nil {
+		return
+	}
+
+	sc.last[key] = streamTick{ts: ts, seen: now}
+	if now.Before(sc.nextSweep) {
+		return
+	}
+
+	for k, tick := range sc.last {
+		if now.Sub(tick.seen) > sc.window {
+			delete(sc.last, k)
+		}
+	}
+	sc.nextSweep = now.Add(sc.window)
+}
+
+// streamLabels merge static labels, level and entry labels
+func (c *Client) streamLabels(e entry) map[string]string {
+	labels := make(map[string]string, len(c.Labels)+len(e.Labels)+1)
+	for k, v := range c.Labels {
+		labels[k] = clampLabel(v, c.maxLabelValueBytes)
+	}
+	for k, v := range e.Labels {
+		if c.allowedLabels != nil {
+			if _, ok := c.allowedLabels[k]; !ok {
+				continue
+			}
+		}
+		labels[k] = clampLabel(v, c.maxLabelValueBytes)
+	}
+	labels["level"] = e.Level
+
+	return labels
+}
+
+// allowedLabels set of Config.AllowedLabels, nil when empty
+func allowedLabels(keys []string) map[string]struct{} {
+	if len(keys) < 1 {
+		return nil
+	}
+
+	allowed := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		allowed[k] = struct{}{}
+	}
+
+	return allowed
+}
+
+// buildStreams group entries into streams by their full label set,
+// label combinations beyond MaxActiveStreams are merged into a per-level overflow stream
+func (c *Client) buildStreams(entries []entry) []stream {
+	var (
+		now     = time.Now()
+		streams = make([]stream, 0, len(entries))
+		values  = make([][]streamValue, 0, len(entries))
+		keys    = make([]string, 0, len(entries))
+		index   = make(map[string]int, len(entries))
+	)
+
+	for _, e := range entries {
+		labels := c.streamLabels(e)
+		key := labelsKey(labels)
+		line := e.Message
+
+		if admitted, tripped := c.streams.admit(key, now); !admitted {
+			if tripped {
+				c.logger.Logf(LevelWarn, "max active streams %d exceeded, merging new label sets into overflow stream", c.streams.max)
+			}
+
+			// move distinguishing labels into the line
+			line = fmt.Sprintf("%s %s", key, e.Message)
+			labels = c.streamLabels(entry{Level: e.Level})
+			labels[overflowLabel] = "true"
+			key = labelsKey(labels)
+		}
+
+		i, ok := index[key]
+		if !ok {
+			i = len(streams)
+			index[key] = i
+			streams = append(streams, stream{Stream: labels})
+			values = append(values, nil)
+			keys = append(keys, key)
+		}
+
+		values[i] = append(values[i], streamValue{ts: e.Timestamp.UnixNano(), line: line, metadata: e.Metadata})
+	}
+
+	for i := range streams {
+		var last int64
+		streams[i].Values, streams[i].metadata, last = c.orderValues(values[i], c.clock.get(keys[i]))
+		if c.dedupTimestamps {
+			c.clock.set(keys[i], last, now)
+		}
+	}
+
+	return streams
+}
+
+// streamValue single entry of stream before encoded
+type streamValue struct {
+	ts       int64
+	line     string
+	metadata map[string]string
+}
+
+// orderValues sort values by timestamp preserving arrival order of equal timestamps,
+// with dedupTimestamps a timestamp not after the previous one, or after the last one sent of the stream,
+// is shifted by 1ns past it so timestamps strictly increase within the stream across batches.
+// metadata is nil when no value has structured metadata
+func (c *Client) orderValues(values []streamValue, after int64) ([][]string, []map[string]string, int64) {
+	sort.SliceStable(values, func(i, j int) bool {
+		return values[i].ts < values[j].ts
+	})
+
+	var metadata []map[string]string
+	out := make([][]string, 0, len(values))
+	for i := range values {
+		if values[i].metadata != nil && metadata == nil {
+			metadata = make([]map[string]string, len(values))
+		}
+		if metadata != nil {
+			metadata[i] = values[i].metadata
+		}
+
+		if c.dedupTimestamps && values[i].ts <= after {
+			values[i].ts = after + 1
+		}
+		after = values[i].ts
+
+		// Convert timestamp to nanosecond precision string
+		out = append(out, []string{strconv.FormatInt(values[i].ts, 10), values[i].line})
+	}
+
+	return out, metadata, after
+}
+
+// labelsKey canonical key of label set, e.g. {a="1",b="2"}
+func labelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, labels[k]))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,125 @@
+package loki
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// PushPath path of loki push API, appended to URL given without path
+const PushPath = "/loki/api/v1/push"
+
+// validateTimeout default timeout of the startup validation push
+const validateTimeout = 5 * time.Second
+
+// failure classes of Validate, the returned error wraps one of them
+var (
+	ErrInvalidURL         = errors.New("loki: invalid URL")
+	ErrDNS                = errors.New("loki: DNS lookup failed")
+	ErrConnectionRefused  = errors.New("loki: connection refused")
+	ErrUnreachable        = errors.New("loki: endpoint unreachable")
+	ErrEndpointNotFound   = errors.New("loki: push endpoint not found")
+	ErrUnauthorized       = errors.New("loki: unauthorized")
+	ErrUnexpectedResponse = errors.New("loki: unexpected response")
+)
+
+// normalizeURL append PushPath to URL given as base URL only, e.g. "http://loki:3100"
+func normalizeURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+
+	if u.Path == "" || u.Path == "/" {
+		u.Path = PushPath
+	}
+
+	return u.String()
+}
+
+// NewClientE same as NewClient, with Config.ValidateOnStart and Config.StrictStart a failed validation
+// stops the client and is returned so the service can fail fast
+func NewClientE(config Config) (*Client, error) {
+	client := newClient(config)
+	if !config.ValidateOnStart {
+		return client, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), validateTimeout)
+	defer cancel()
+
+	if err := client.Validate(ctx); err != nil && config.StrictStart {
+		client.Stop()
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// Validate push an empty request to URL and report the failure class with a diagnostic through the logger,
+// see ErrDNS, ErrConnectionRefused, ErrEndpointNotFound, ErrUnauthorized
+func (c *Client) Validate(ctx context.Context) error {
+	err := c.validate(ctx)
+	if err != nil {
+		c.logger.Logf(LevelError, "startup validation of %s failed: %v", c.URL, err)
+		return err
+	}
+
+	c.logger.Logf(LevelDebug, "startup validation of %s succeeded", c.URL)
+	return nil
+}
+
+func (c *Client) validate(ctx context.Context) error {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q, expected http or https", ErrInvalidURL, u.Scheme)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader([]byte(`{"streams":[]}`)))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	// same credentials and headers as the pushes
+	if hs, ok := c.sender.(*httpSender); ok {
+		hs.setHeaders(req)
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", UserAgent)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		var dnsErr *net.DNSError
+		switch {
+		case errors.As(err, &dnsErr):
+			return fmt.Errorf("%w: host %q can not be resolved: %v", ErrDNS, dnsErr.Name, err)
+		case errors.Is(err, syscall.ECONNREFUSED):
+			return fmt.Errorf("%w: nothing listening on %s, check host and port: %v", ErrConnectionRefused, u.Host, err)
+		default:
+			return fmt.Errorf("%w: %v", ErrUnreachable, err)
+		}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: %s on %s, expected path %s", ErrEndpointNotFound, resp.Status, u.Path, PushPath)
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: %s, check credentials and tenant", ErrUnauthorized, resp.Status)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnexpectedResponse, strings.TrimSpace(resp.Status))
+	}
+}
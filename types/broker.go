@@ -1,5 +1,18 @@
 package types
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNonRetryable wrap handler error with this error to skip retry queues, e.g. fmt.Errorf("%w: invalid payload", types.ErrNonRetryable)
+var ErrNonRetryable = errors.New("non-retryable")
+
+// ErrHandlerTimeout handler did not return within BrokerHandler.HandlerTimeout, retried like any handler error
+var ErrHandlerTimeout = errors.New("handler timeout")
+
 // Broker is the type returned by a classifier broker
 type Broker string
 
@@ -12,6 +25,8 @@ const (
 	NSQ Broker = "nsq"
 	// Kafka Broker
 	Kafka Broker = "kafka"
+	// SQS Broker, AWS SQS consumer and SQS/SNS publisher
+	SQS Broker = "sqs"
 )
 
 func (b Broker) String() string {
@@ -23,18 +38,79 @@ type BrokerHandlerFunc func(ec *EventContext) error
 
 type BrokerHandlerOption func(*BrokerHandler)
 
+// KeyFunc entity key of message, messages sharing a key are handled in order, see SetBrokerOrdered
+type KeyFunc func(message []byte, header map[string]string) string
+
 // BrokerHandler instance
 type BrokerHandler struct {
-	Topic            string // topic broker
-	Exchange         string // exchange of broker
-	Queue            string // queue message
-	IsQueueDurable   bool   // durable of queue
-	IsQueueExclusive bool   // queue exclusive
-	Channel          string // channel app name
-	IsAutoAck        bool   // auto acknowledgement
+	Topic            string          // topic broker
+	Exchange         string          // exchange of broker
+	Queue            string          // queue message
+	IsQueueDurable   bool            // durable of queue
+	IsQueueExclusive bool            // queue exclusive
+	Channel          string          // channel app name
+	IsAutoAck        bool            // auto acknowledgement
+	RetryTiers       []time.Duration // delay of each retry attempt, e.g. 1m, 5m, 30m
+	KeyFunc          KeyFunc         // ordered dispatch key, nil means unordered
+	Lanes            int             // number of ordered lanes, default to max goroutines of worker
+	LaneSize         int             // bounded queue of each lane
+	HandlerTimeout   time.Duration   // deadline of handler context, zero means unlimited
 	HandlerFunc      BrokerHandlerFunc
 }
 
+// Call run HandlerFunc, with HandlerTimeout the handler runs on its own goroutine over a private copy of ec whose
+// context has the deadline. once the deadline expires Call returns ErrHandlerTimeout without waiting, so the
+// message is settled as failed even when the handler ignores its context, and a result returned after the
+// deadline, nil included, is discarded. the result of a handler returning in time is copied back into ec
+func (bh BrokerHandler) Call(ec *EventContext) error {
+	if bh.HandlerTimeout <= 0 {
+		return bh.HandlerFunc(ec)
+	}
+
+	ctx, cancel := context.WithTimeout(ec.Context(), bh.HandlerTimeout)
+	defer cancel()
+
+	private := ec.clone()
+	private.SetContext(ctx)
+
+	done := make(chan error, 1)
+	go func() {
+		// the worker recovering panics of the handler is on another goroutine
+		defer func() {
+			if re := recover(); re != nil {
+				done <- fmt.Errorf("%s", re)
+			}
+		}()
+
+		done <- bh.HandlerFunc(private)
+	}()
+
+	select {
+	case err := <-done:
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return bh.timeout(err)
+		}
+
+		ec.merge(private)
+		return err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return bh.timeout(nil)
+		}
+
+		return ctx.Err()
+	}
+}
+
+// timeout ErrHandlerTimeout wrapping err of handler, nil when the handler is still running
+func (bh BrokerHandler) timeout(err error) error {
+	if err == nil {
+		return fmt.Errorf("%w after %s", ErrHandlerTimeout, bh.HandlerTimeout)
+	}
+
+	return fmt.Errorf("%w after %s: %w", ErrHandlerTimeout, bh.HandlerTimeout, err)
+}
+
 // BrokerHandlerGroup group of broker handlers by topic, exchange, or queue with channels
 type BrokerHandlerGroup struct {
 	Handlers []BrokerHandler
@@ -98,3 +174,30 @@ func SetBrokerAutoAck(autoAck bool) BrokerHandlerOption {
 		bh.IsAutoAck = autoAck
 	}
 }
+
+// SetBrokerRetryTiers set delay of each retry attempt, failed message is delayed on retry queue of its tier
+// before redelivered to the original queue
+func SetBrokerRetryTiers(tiers ...time.Duration) BrokerHandlerOption {
+	return func(bh *BrokerHandler) {
+		bh.RetryTiers = tiers
+	}
+}
+
+// SetBrokerHandlerTimeout cancel context of handler after timeout, the message is then failed and retried
+// like any handler error without waiting for the handler, see ErrHandlerTimeout and BrokerHandler.Call
+func SetBrokerHandlerTimeout(timeout time.Duration) BrokerHandlerOption {
+	return func(bh *BrokerHandler) {
+		bh.HandlerTimeout = timeout
+	}
+}
+
+// SetBrokerOrdered handle messages sharing the key of keyFunc in order, messages are routed into one of lanes
+// by hashing the key, each lane handles its messages one by one with bounded queue of laneSize,
+// the worker stops fetching while the lane is full
+func SetBrokerOrdered(keyFunc KeyFunc, lanes, laneSize int) BrokerHandlerOption {
+	return func(bh *BrokerHandler) {
+		bh.KeyFunc = keyFunc
+		bh.Lanes = lanes
+		bh.LaneSize = laneSize
+	}
+}
@@ -0,0 +1,110 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBrokerHandlerCallTimeout(t *testing.T) {
+	bh := BrokerHandler{
+		HandlerTimeout: 50 * time.Millisecond,
+		HandlerFunc: func(ec *EventContext) error {
+			ec.PublishBuffer().Publish(PublisherArgument{Key: "order.paid"})
+			ec.Reply([]byte("ok"))
+			return nil
+		},
+	}
+
+	// result of a handler returning in time is copied back
+	ec := &EventContext{}
+	ec.SetContext(context.Background())
+	if err := bh.Call(ec); err != nil || ec.PublishBuffer().Len() != 1 || string(ec.ReplyPayload()) != "ok" {
+		t.Fatalf("expected handler result on the event context, got %v", err)
+	}
+
+	// a handler ignoring its context is not awaited, its late result is discarded
+	release := make(chan struct{})
+	bh.HandlerFunc = func(ec *EventContext) error {
+		<-release
+		ec.PublishBuffer().Publish(PublisherArgument{Key: "order.paid"})
+		return nil
+	}
+
+	ec = &EventContext{}
+	ec.SetContext(context.Background())
+	start := time.Now()
+	err := bh.Call(ec)
+	close(release)
+
+	if !errors.Is(err, ErrHandlerTimeout) || time.Since(start) > 200*time.Millisecond {
+		t.Errorf("expected ErrHandlerTimeout at the deadline, got %v after %s", err, time.Since(start))
+	}
+	if ec.PublishBuffer().Len() != 0 {
+		t.Error("expected late handler never touching the event context")
+	}
+}
+
+func TestPublisherArgumentValidate(t *testing.T) {
+	validate, err := JSONSchemaValidator(`{
+		"type": "object",
+		"required": ["order_id", "amount"],
+		"properties": {"order_id": {"type": "string"}, "amount": {"type": "number", "minimum": 0}}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterPayloadValidator("order.created", validate)
+
+	if err = (PublisherArgument{Topic: "order.created", Message: []byte(`{"order_id":"1","amount":10}`)}).Validate(); err != nil {
+		t.Errorf("expected valid payload, got %v", err)
+	}
+
+	err = (PublisherArgument{Key: "order.created", Message: []byte(`{"amount":-1}`)}).Validate()
+	var ip *ErrInvalidPayload
+	if !errors.As(err, &ip) || ip.Topic != "order.created" || len(ip.Violations) != 2 {
+		t.Fatalf("expected two violations, got %v", err)
+	}
+
+	if err = (PublisherArgument{Topic: "unknown", Message: []byte(`{}`)}).Validate(); err != nil {
+		t.Errorf("expected unknown topic allowed on lenient mode, got %v", err)
+	}
+
+	SetPayloadValidationStrict(true)
+	defer SetPayloadValidationStrict(false)
+	if err = (PublisherArgument{Topic: "unknown", Message: []byte(`{}`)}).Validate(); !errors.As(err, &ip) {
+		t.Errorf("expected unknown topic rejected on strict mode, got %v", err)
+	}
+}
+
+func TestTailPublisher(t *testing.T) {
+	var sent []string
+	fail := true
+	tail := NewTailPublisher(func(_ context.Context, req PublisherArgument) error {
+		if fail && len(sent) == 1 {
+			return errors.New("channel closed")
+		}
+		sent = append(sent, req.Key)
+		return nil
+	})
+	messages := []PublisherArgument{{Key: "order.paid"}, {Key: "order.packed"}}
+
+	if i, err := tail.Publish(context.Background(), "msg-1", messages); err == nil || i != 1 {
+		t.Fatalf("expected failure on the second message, got %d %v", i, err)
+	}
+
+	// the progress belongs to the instance, another publisher sends the whole buffer
+	other := NewTailPublisher(func(context.Context, PublisherArgument) error { return nil })
+	if other.Sent("msg-1") != 0 || tail.Sent("msg-1") != 1 {
+		t.Errorf("expected progress kept per publisher, got %d and %d", other.Sent("msg-1"), tail.Sent("msg-1"))
+	}
+
+	fail = false
+	if _, err := tail.Publish(context.Background(), "msg-1", messages); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 2 || sent[1] != "order.packed" || tail.Sent("msg-1") != 0 {
+		t.Errorf("expected only the unsent tail published, got %v", sent)
+	}
+}
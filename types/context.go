@@ -3,6 +3,7 @@ package types
 import (
 	"bytes"
 	"context"
+	"strings"
 )
 
 type EventContext struct {
@@ -10,9 +11,12 @@ type EventContext struct {
 	workerType   string
 	handlerRoute string
 	header       map[string]string
+	contentType  string
 	key          string
 	err          error
 	buff         *bytes.Buffer
+	publish      *PublishBuffer
+	reply        []byte
 }
 
 // SetContext setter context
@@ -35,6 +39,11 @@ func (e *EventContext) SetHeader(header map[string]string) {
 	e.header = header
 }
 
+// SetContentType setter content type of message
+func (e *EventContext) SetContentType(contentType string) {
+	e.contentType = contentType
+}
+
 // SetKey setter key context
 func (e *EventContext) SetKey(key string) {
 	e.key = key
@@ -45,6 +54,49 @@ func (e *EventContext) SetError(err error) {
 	e.err = err
 }
 
+// clone private copy of e for a handler running on another goroutine, see BrokerHandler.Call
+func (e *EventContext) clone() *EventContext {
+	c := *e
+	c.publish, c.reply = nil, nil
+
+	c.header = make(map[string]string, len(e.header))
+	for k, v := range e.header {
+		c.header[k] = v
+	}
+
+	if e.buff != nil {
+		c.buff = bytes.NewBuffer(append([]byte(nil), e.buff.Bytes()...))
+	}
+
+	return &c
+}
+
+// merge copy result of handler on private copy c back into e
+func (e *EventContext) merge(c *EventContext) {
+	e.err = c.err
+	e.publish = c.publish
+	e.reply = c.reply
+}
+
+// PublishBuffer get buffer of outgoing messages, published by worker only after handler returns nil
+func (e *EventContext) PublishBuffer() *PublishBuffer {
+	if e.publish == nil {
+		e.publish = &PublishBuffer{}
+	}
+
+	return e.publish
+}
+
+// Reply set reply payload of request-reply, published to the reply-to address of message after handler returns nil
+func (e *EventContext) Reply(payload []byte) {
+	e.reply = payload
+}
+
+// ReplyPayload get reply payload set by handler
+func (e *EventContext) ReplyPayload() []byte {
+	return e.reply
+}
+
 // Context get current context
 func (e *EventContext) Context() context.Context {
 	return e.ctx
@@ -60,6 +112,21 @@ func (e *EventContext) Header() map[string]string {
 	return e.header
 }
 
+// ContentType get content type of message, fallback to content-type header
+func (e *EventContext) ContentType() string {
+	if e.contentType != "" {
+		return e.contentType
+	}
+
+	for k, v := range e.header {
+		if strings.EqualFold(k, "content-type") || strings.EqualFold(k, "contenttype") {
+			return v
+		}
+	}
+
+	return ""
+}
+
 // Key get key
 func (e *EventContext) Key() string {
 	return e.key
@@ -0,0 +1,40 @@
+package types
+
+import (
+	"context"
+	"time"
+)
+
+// HeaderRequestId message header carrying the request id, logged by broker workers
+const HeaderRequestId = "x-request-id"
+
+// deliveryKey context key of Delivery
+type deliveryKey struct{}
+
+// Delivery metadata of consumed message, populated by broker worker when available
+type Delivery struct {
+	Queue       string
+	Exchange    string
+	Topic       string
+	RoutingKey  string
+	Partition   int32
+	Redelivered bool
+	Attempt     int
+	Headers     map[string]interface{}
+	EnqueuedAt  time.Time
+}
+
+// ContextWithDelivery set delivery metadata into context, used by broker workers
+func ContextWithDelivery(ctx context.Context, d Delivery) context.Context {
+	return context.WithValue(ctx, deliveryKey{}, d)
+}
+
+// DeliveryFromContext get delivery metadata of consumed message from handler context
+func DeliveryFromContext(ctx context.Context) (Delivery, bool) {
+	if ctx == nil {
+		return Delivery{}, false
+	}
+
+	d, ok := ctx.Value(deliveryKey{}).(Delivery)
+	return d, ok
+}
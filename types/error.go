@@ -0,0 +1,138 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorCode registered error code and its representation on every transport
+type ErrorCode struct {
+	Code           string
+	HTTPStatus     int
+	GRPCCode       codes.Code
+	DefaultMessage string
+}
+
+var (
+	errorRegistryMu sync.RWMutex
+	errorRegistry   = make(map[string]ErrorCode)
+
+	// unknownErrorCode used when error code is not registered
+	unknownErrorCode = ErrorCode{
+		HTTPStatus:     http.StatusInternalServerError,
+		GRPCCode:       codes.Internal,
+		DefaultMessage: "internal server error",
+	}
+)
+
+// RegisterError register error code with its http status, grpc code and default message,
+// registering the same code twice will replace the previous one
+func RegisterError(code string, httpStatus int, grpcCode codes.Code, defaultMessage string) {
+	errorRegistryMu.Lock()
+	defer errorRegistryMu.Unlock()
+
+	errorRegistry[code] = ErrorCode{
+		Code:           code,
+		HTTPStatus:     httpStatus,
+		GRPCCode:       grpcCode,
+		DefaultMessage: defaultMessage,
+	}
+}
+
+// LookupError get registered error code, unknown code fallback to 500/Internal
+func LookupError(code string) (ErrorCode, bool) {
+	errorRegistryMu.RLock()
+	defer errorRegistryMu.RUnlock()
+
+	ec, ok := errorRegistry[code]
+	if !ok {
+		ec = unknownErrorCode
+		ec.Code = code
+	}
+
+	return ec, ok
+}
+
+// CodedError error with registered code, render the same on REST and gRPC
+type CodedError struct {
+	Code    string
+	Message string
+	Details map[string]interface{}
+	Err     error
+}
+
+// NewCodedError create coded error, message default to the registered message of code
+func NewCodedError(code string, err error) *CodedError {
+	ec, _ := LookupError(code)
+
+	return &CodedError{
+		Code:    code,
+		Message: ec.DefaultMessage,
+		Err:     err,
+	}
+}
+
+// AsCodedError find the first CodedError in err chain
+func AsCodedError(err error) (*CodedError, bool) {
+	var ce *CodedError
+	ok := errors.As(err, &ce)
+	return ce, ok
+}
+
+// Error message of error
+func (e *CodedError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s", e.Code, e.Err.Error())
+	}
+
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Unwrap return the cause of error
+func (e *CodedError) Unwrap() error {
+	return e.Err
+}
+
+// Is report whether target has the same code
+func (e *CodedError) Is(target error) bool {
+	t, ok := target.(*CodedError)
+	return ok && t.Code == e.Code
+}
+
+// WithMessage replace message of error
+func (e *CodedError) WithMessage(message string) *CodedError {
+	e.Message = message
+	return e
+}
+
+// WithDetail add detail into error
+func (e *CodedError) WithDetail(key string, value interface{}) *CodedError {
+	if e.Details == nil {
+		e.Details = make(map[string]interface{})
+	}
+
+	e.Details[key] = value
+	return e
+}
+
+// HTTPStatus registered http status code
+func (e *CodedError) HTTPStatus() int {
+	ec, _ := LookupError(e.Code)
+	return ec.HTTPStatus
+}
+
+// GRPCCode registered grpc code
+func (e *CodedError) GRPCCode() codes.Code {
+	ec, _ := LookupError(e.Code)
+	return ec.GRPCCode
+}
+
+// GRPCStatus grpc status of error, used by grpc to convert returned error
+func (e *CodedError) GRPCStatus() *status.Status {
+	return status.New(e.GRPCCode(), e.Message)
+}
@@ -0,0 +1,42 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCodedErrorRegistry(t *testing.T) {
+	RegisterError("ORDER_NOT_FOUND", http.StatusNotFound, codes.NotFound, "order not found")
+
+	err := fmt.Errorf("get order: %w", NewCodedError("ORDER_NOT_FOUND", errors.New("no rows")))
+
+	ce, ok := AsCodedError(err)
+	if !ok {
+		t.Fatal("expected coded error in chain")
+	}
+
+	if ce.HTTPStatus() != http.StatusNotFound {
+		t.Errorf("expected http status 404, got %d", ce.HTTPStatus())
+	}
+
+	if code := status.Code(ce); code != codes.NotFound {
+		t.Errorf("expected grpc code NotFound, got %s", code)
+	}
+
+	if !errors.Is(err, &CodedError{Code: "ORDER_NOT_FOUND"}) {
+		t.Error("expected errors.Is match by code")
+	}
+}
+
+func TestCodedErrorUnknownCode(t *testing.T) {
+	ce := NewCodedError("UNREGISTERED", nil)
+
+	if ce.HTTPStatus() != http.StatusInternalServerError || ce.GRPCCode() != codes.Internal {
+		t.Errorf("expected 500/Internal fallback, got %d/%s", ce.HTTPStatus(), ce.GRPCCode())
+	}
+}
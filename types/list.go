@@ -0,0 +1,279 @@
+package types
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/TixiaOTA/gokit/types/listpb"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// DefaultListSize page size of ListRequest without size
+	DefaultListSize = 20
+	// DefaultMaxListSize maximum page size of ListPolicy without MaxSize
+	DefaultMaxListSize = 100
+)
+
+// Operator comparison of Filter
+type Operator string
+
+// operators of Filter
+const (
+	OpEq   Operator = "eq"
+	OpNe   Operator = "ne"
+	OpGt   Operator = "gt"
+	OpGte  Operator = "gte"
+	OpLt   Operator = "lt"
+	OpLte  Operator = "lte"
+	OpIn   Operator = "in"   // any of values
+	OpLike Operator = "like" // contains value
+)
+
+// operatorProto proto enum of each operator
+var operatorProto = map[Operator]listpb.Operator{
+	OpEq:   listpb.Operator_OPERATOR_EQ,
+	OpNe:   listpb.Operator_OPERATOR_NE,
+	OpGt:   listpb.Operator_OPERATOR_GT,
+	OpGte:  listpb.Operator_OPERATOR_GTE,
+	OpLt:   listpb.Operator_OPERATOR_LT,
+	OpLte:  listpb.Operator_OPERATOR_LTE,
+	OpIn:   listpb.Operator_OPERATOR_IN,
+	OpLike: listpb.Operator_OPERATOR_LIKE,
+}
+
+// Valid report whether op is one of the known operators
+func (op Operator) Valid() bool {
+	_, ok := operatorProto[op]
+	return ok
+}
+
+// SortField field sorted ascending unless Desc
+type SortField struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc"`
+}
+
+// Filter field compared with values, only OpIn takes more than one value
+type Filter struct {
+	Field    string   `json:"field"`
+	Operator Operator `json:"operator"`
+	Values   []string `json:"values"`
+}
+
+// ListRequest page, sort and filters of a list endpoint shared by REST and gRPC, pages start at 1
+type ListRequest struct {
+	Page    int         `json:"page"`
+	Size    int         `json:"size"`
+	SortBy  []SortField `json:"sort_by,omitempty"`
+	Filters []Filter    `json:"filters,omitempty"`
+}
+
+// ListResponse page of items of a list endpoint
+type ListResponse[T any] struct {
+	Items      []T   `json:"items"`
+	Page       int   `json:"page"`
+	Size       int   `json:"size"`
+	Total      int64 `json:"total"`
+	TotalPages int   `json:"total_pages"`
+}
+
+// NewListResponse page of items of req out of total items
+func NewListResponse[T any](req ListRequest, items []T, total int64) ListResponse[T] {
+	if items == nil {
+		items = []T{}
+	}
+
+	resp := ListResponse[T]{Items: items, Page: req.Page, Size: req.Size, Total: total}
+	if req.Size > 0 {
+		resp.TotalPages = int((total + int64(req.Size) - 1) / int64(req.Size))
+	}
+
+	return resp
+}
+
+// ListPolicy allowlist and limits of a list endpoint, maps public field names to their column,
+// fields missing from SortFields or FilterFields are rejected
+type ListPolicy struct {
+	DefaultSize  int               // page size when absent, default DefaultListSize
+	MaxSize      int               // maximum page size, default DefaultMaxListSize
+	SortFields   map[string]string // sortable field to column, e.g. "created_at": "o.created_at"
+	FilterFields map[string]string // filterable field to column
+}
+
+// ErrInvalidList list request rejected by ListPolicy
+type ErrInvalidList struct {
+	Reason string
+}
+
+// Error message of error
+func (e *ErrInvalidList) Error() string {
+	return fmt.Sprintf("invalid list request: %s", e.Reason)
+}
+
+// Validate apply defaults of policy and check req against it, page below 1 is the first page
+func (r *ListRequest) Validate(policy ListPolicy) error {
+	if policy.DefaultSize <= 0 {
+		policy.DefaultSize = DefaultListSize
+	}
+	if policy.MaxSize <= 0 {
+		policy.MaxSize = DefaultMaxListSize
+	}
+
+	if r.Page < 1 {
+		r.Page = 1
+	}
+	// page travels as int32 on gRPC
+	if r.Page > math.MaxInt32 {
+		return &ErrInvalidList{Reason: fmt.Sprintf("page %d exceeds maximum %d", r.Page, math.MaxInt32)}
+	}
+	if r.Size <= 0 {
+		r.Size = policy.DefaultSize
+	}
+	if r.Size > policy.MaxSize {
+		return &ErrInvalidList{Reason: fmt.Sprintf("size %d exceeds maximum %d", r.Size, policy.MaxSize)}
+	}
+
+	for _, s := range r.SortBy {
+		if _, ok := policy.SortFields[s.Field]; !ok {
+			return &ErrInvalidList{Reason: fmt.Sprintf("sort by %q is not allowed", s.Field)}
+		}
+	}
+
+	for _, f := range r.Filters {
+		if _, ok := policy.FilterFields[f.Field]; !ok {
+			return &ErrInvalidList{Reason: fmt.Sprintf("filter on %q is not allowed", f.Field)}
+		}
+		if !f.Operator.Valid() {
+			return &ErrInvalidList{Reason: fmt.Sprintf("unknown operator %q on %q", f.Operator, f.Field)}
+		}
+		if len(f.Values) < 1 || (f.Operator != OpIn && len(f.Values) > 1) {
+			return &ErrInvalidList{Reason: fmt.Sprintf("filter on %q takes one value, or more with in", f.Field)}
+		}
+	}
+
+	return nil
+}
+
+// Offset rows skipped before the page of r, DefaultListSize is the page size without size.
+// an offset past math.MaxInt64 is capped, such page is empty anyway
+func (r ListRequest) Offset() int64 {
+	if r.Page < 1 {
+		return 0
+	}
+
+	size := int64(r.size())
+	if int64(r.Page-1) > math.MaxInt64/size {
+		return math.MaxInt64
+	}
+
+	return int64(r.Page-1) * size
+}
+
+// size page size of r, DefaultListSize without size
+func (r ListRequest) size() int {
+	if r.Size <= 0 {
+		return DefaultListSize
+	}
+
+	return r.Size
+}
+
+// ParseFromQuery list request of query string, e.g. ?page=2&size=20&sort=-created_at,name&filter=status:eq:paid
+// &filter=id:in:1,2,3. "-" prefix sorts descending, filters are field:operator:value, see ListRequest.Validate
+func ParseFromQuery(c *fiber.Ctx) (ListRequest, error) {
+	var (
+		req  ListRequest
+		args = c.Context().QueryArgs()
+		err  error
+	)
+
+	if v := string(args.Peek("page")); v != "" {
+		if req.Page, err = strconv.Atoi(v); err != nil {
+			return req, &ErrInvalidList{Reason: fmt.Sprintf("page %q is not a number", v)}
+		}
+	}
+	if v := string(args.Peek("size")); v != "" {
+		if req.Size, err = strconv.Atoi(v); err != nil {
+			return req, &ErrInvalidList{Reason: fmt.Sprintf("size %q is not a number", v)}
+		}
+	}
+
+	for _, sort := range args.PeekMulti("sort") {
+		for _, field := range strings.Split(string(sort), ",") {
+			if field = strings.TrimSpace(field); field == "" {
+				continue
+			}
+			req.SortBy = append(req.SortBy, SortField{Field: strings.TrimPrefix(field, "-"), Desc: strings.HasPrefix(field, "-")})
+		}
+	}
+
+	for _, filter := range args.PeekMulti("filter") {
+		parts := strings.SplitN(string(filter), ":", 3)
+		if len(parts) != 3 || parts[0] == "" {
+			return req, &ErrInvalidList{Reason: fmt.Sprintf("filter %q is not field:operator:value", filter)}
+		}
+
+		f := Filter{Field: parts[0], Operator: Operator(strings.ToLower(parts[1])), Values: []string{parts[2]}}
+		if f.Operator == OpIn {
+			f.Values = strings.Split(parts[2], ",")
+		}
+		req.Filters = append(req.Filters, f)
+	}
+
+	return req, nil
+}
+
+// ToProto proto message of r
+func (r ListRequest) ToProto() *listpb.ListRequest {
+	msg := &listpb.ListRequest{Page: int32(r.Page), Size: int32(r.Size)}
+	for _, s := range r.SortBy {
+		msg.SortBy = append(msg.SortBy, &listpb.SortField{Field: s.Field, Desc: s.Desc})
+	}
+	for _, f := range r.Filters {
+		msg.Filters = append(msg.Filters, &listpb.Filter{Field: f.Field, Operator: operatorProto[f.Operator], Values: f.Values})
+	}
+
+	return msg
+}
+
+// ListRequestFromProto list request of proto message, unknown operators are left empty and rejected by Validate
+func ListRequestFromProto(msg *listpb.ListRequest) ListRequest {
+	req := ListRequest{Page: int(msg.GetPage()), Size: int(msg.GetSize())}
+	for _, s := range msg.GetSortBy() {
+		req.SortBy = append(req.SortBy, SortField{Field: s.GetField(), Desc: s.GetDesc()})
+	}
+	for _, f := range msg.GetFilters() {
+		filter := Filter{Field: f.GetField(), Values: f.GetValues()}
+		for op, p := range operatorProto {
+			if p == f.GetOperator() {
+				filter.Operator = op
+			}
+		}
+		req.Filters = append(req.Filters, filter)
+	}
+
+	return req
+}
+
+// PageInfo proto page info of r, items are set on the response message of the service
+func (r ListResponse[T]) PageInfo() *listpb.PageInfo {
+	return &listpb.PageInfo{Page: int32(r.Page), Size: int32(r.Size), Total: r.Total, TotalPages: int32(r.TotalPages)}
+}
+
+// ListResponseFromProto list response of items and proto page info
+func ListResponseFromProto[T any](items []T, info *listpb.PageInfo) ListResponse[T] {
+	if items == nil {
+		items = []T{}
+	}
+
+	return ListResponse[T]{
+		Items:      items,
+		Page:       int(info.GetPage()),
+		Size:       int(info.GetSize()),
+		Total:      info.GetTotal(),
+		TotalPages: int(info.GetTotalPages()),
+	}
+}
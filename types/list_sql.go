@@ -0,0 +1,81 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sqlOperators sql comparison of each operator, in and like are rendered separately
+var sqlOperators = map[Operator]string{
+	OpEq:  "=",
+	OpNe:  "<>",
+	OpGt:  ">",
+	OpGte: ">=",
+	OpLt:  "<",
+	OpLte: "<=",
+}
+
+// likeEscaper escape wildcards of like value, matched literally with ESCAPE '!'. backslash is a string
+// escape of MySQL and standard in PostgreSQL, '!' means the same on every database
+var likeEscaper = strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`)
+
+// OrderBy ORDER BY clause of sort fields rendered with the columns of policy, empty without sort.
+// only columns of policy reach the query, fields missing from policy are skipped
+func (r ListRequest) OrderBy(policy ListPolicy) string {
+	var columns []string
+	for _, s := range r.SortBy {
+		column, ok := policy.SortFields[s.Field]
+		if !ok {
+			continue
+		}
+
+		if s.Desc {
+			columns = append(columns, column+" DESC")
+		} else {
+			columns = append(columns, column+" ASC")
+		}
+	}
+
+	if len(columns) < 1 {
+		return ""
+	}
+
+	return "ORDER BY " + strings.Join(columns, ", ")
+}
+
+// Limit LIMIT and OFFSET clause of the page of r, DefaultListSize is the page size without size
+func (r ListRequest) Limit() string {
+	return fmt.Sprintf("LIMIT %d OFFSET %d", r.size(), r.Offset())
+}
+
+// Where WHERE conditions of filters joined by AND with ? placeholders and their args, empty without filters,
+// e.g. for gorm db.Where(query, args...). only columns of policy reach the query, values are always args
+func (r ListRequest) Where(policy ListPolicy) (query string, args []interface{}) {
+	var conditions []string
+	for _, f := range r.Filters {
+		column, ok := policy.FilterFields[f.Field]
+		if !ok || len(f.Values) < 1 {
+			continue
+		}
+
+		switch f.Operator {
+		case OpIn:
+			conditions = append(conditions, fmt.Sprintf("%s IN (?%s)", column, strings.Repeat(", ?", len(f.Values)-1)))
+			for _, v := range f.Values {
+				args = append(args, v)
+			}
+		case OpLike:
+			conditions = append(conditions, column+` LIKE ? ESCAPE '!'`)
+			args = append(args, "%"+likeEscaper.Replace(f.Values[0])+"%")
+		default:
+			op, ok := sqlOperators[f.Operator]
+			if !ok {
+				continue
+			}
+			conditions = append(conditions, fmt.Sprintf("%s %s ?", column, op))
+			args = append(args, f.Values[0])
+		}
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
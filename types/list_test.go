@@ -0,0 +1,160 @@
+package types
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/TixiaOTA/gokit/types/listpb"
+	"github.com/gofiber/fiber/v2"
+	"google.golang.org/protobuf/proto"
+)
+
+var orderPolicy = ListPolicy{
+	MaxSize:      50,
+	SortFields:   map[string]string{"created_at": "o.created_at", "amount": "o.amount"},
+	FilterFields: map[string]string{"status": "o.status", "id": "o.id", "note": "o.note"},
+}
+
+func parseQuery(t *testing.T, query string) (ListRequest, error) {
+	t.Helper()
+
+	var (
+		req ListRequest
+		err error
+	)
+	app := fiber.New()
+	app.Get("/orders", func(c *fiber.Ctx) error {
+		req, err = ParseFromQuery(c)
+		return nil
+	})
+	if _, terr := app.Test(httptest.NewRequest(http.MethodGet, "/orders?"+query, nil)); terr != nil {
+		t.Fatal(terr)
+	}
+
+	return req, err
+}
+
+func TestParseFromQuery(t *testing.T) {
+	req, err := parseQuery(t, "page=2&size=10&sort=-created_at,amount&filter=status:eq:paid&filter=id:IN:1,2,3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := ListRequest{
+		Page:   2,
+		Size:   10,
+		SortBy: []SortField{{Field: "created_at", Desc: true}, {Field: "amount"}},
+		Filters: []Filter{
+			{Field: "status", Operator: OpEq, Values: []string{"paid"}},
+			{Field: "id", Operator: OpIn, Values: []string{"1", "2", "3"}},
+		},
+	}
+	if !reflect.DeepEqual(req, want) {
+		t.Fatalf("unexpected list request %+v", req)
+	}
+	if err = req.Validate(orderPolicy); err != nil || req.Offset() != 10 {
+		t.Errorf("expected valid request at offset 10, got %d: %v", req.Offset(), err)
+	}
+
+	for _, query := range []string{"page=two", "filter=status", "size=x"} {
+		var invalid *ErrInvalidList
+		if _, err = parseQuery(t, query); !errors.As(err, &invalid) {
+			t.Errorf("%s: expected ErrInvalidList, got %v", query, err)
+		}
+	}
+}
+
+func TestListRequestValidate(t *testing.T) {
+	req := ListRequest{}
+	if err := req.Validate(orderPolicy); err != nil || req.Page != 1 || req.Size != DefaultListSize {
+		t.Errorf("expected defaults applied, got %+v: %v", req, err)
+	}
+
+	for name, req := range map[string]ListRequest{
+		"size over max":       {Size: 51},
+		"page over int32":     {Page: math.MaxInt32 + 1},
+		"sort not allowed":    {SortBy: []SortField{{Field: "password"}}},
+		"filter not allowed":  {Filters: []Filter{{Field: "password", Operator: OpEq, Values: []string{"x"}}}},
+		"unknown operator":    {Filters: []Filter{{Field: "status", Operator: "regex", Values: []string{"x"}}}},
+		"many values with eq": {Filters: []Filter{{Field: "status", Operator: OpEq, Values: []string{"a", "b"}}}},
+	} {
+		if err := req.Validate(orderPolicy); err == nil {
+			t.Errorf("%s: expected rejected", name)
+		}
+	}
+}
+
+func TestListProtoRoundTrip(t *testing.T) {
+	req := ListRequest{
+		Page:    3,
+		Size:    25,
+		SortBy:  []SortField{{Field: "amount", Desc: true}},
+		Filters: []Filter{{Field: "id", Operator: OpIn, Values: []string{"1", "2"}}, {Field: "note", Operator: OpLike, Values: []string{"gift"}}},
+	}
+
+	wire, err := proto.Marshal(req.ToProto())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg listpb.ListRequest
+	if err = proto.Unmarshal(wire, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if got := ListRequestFromProto(&msg); !reflect.DeepEqual(got, req) {
+		t.Errorf("expected round trip, got %+v", got)
+	}
+
+	resp := NewListResponse(ListRequest{Page: 2, Size: 10}, []string{"a"}, 21)
+	if resp.TotalPages != 3 {
+		t.Errorf("expected 3 pages, got %d", resp.TotalPages)
+	}
+	if got := ListResponseFromProto(resp.Items, resp.PageInfo()); !reflect.DeepEqual(got, resp) {
+		t.Errorf("expected page info round trip, got %+v", got)
+	}
+}
+
+func TestListSQL(t *testing.T) {
+	req := ListRequest{
+		Page: 3,
+		Size: 20,
+		SortBy: []SortField{
+			{Field: "created_at", Desc: true},
+			{Field: "amount; DROP TABLE orders--"},
+		},
+		Filters: []Filter{
+			{Field: "status", Operator: OpNe, Values: []string{"' OR 1=1 --"}},
+			{Field: "id", Operator: OpIn, Values: []string{"1", "2"}},
+			{Field: "note", Operator: OpLike, Values: []string{"50%_off!"}},
+			{Field: "1=1) OR (1", Operator: OpEq, Values: []string{"x"}},
+		},
+	}
+
+	if got := req.OrderBy(orderPolicy); got != "ORDER BY o.created_at DESC" {
+		t.Errorf("expected only allowlisted columns on order by, got %q", got)
+	}
+	if got := req.Limit(); got != "LIMIT 20 OFFSET 40" {
+		t.Errorf("unexpected limit %q", got)
+	}
+
+	query, args := req.Where(orderPolicy)
+	if want := `o.status <> ? AND o.id IN (?, ?) AND o.note LIKE ? ESCAPE '!'`; query != want {
+		t.Errorf("expected placeholders only, got %q", query)
+	}
+	if want := []interface{}{"' OR 1=1 --", "1", "2", `%50!%!_off!!%`}; !reflect.DeepEqual(args, want) {
+		t.Errorf("expected values as args, got %v", args)
+	}
+
+	if got := (ListRequest{}).OrderBy(orderPolicy); got != "" {
+		t.Errorf("expected no order by without sort, got %q", got)
+	}
+	if got := (ListRequest{Page: 2}).Limit(); got != "LIMIT 20 OFFSET 20" {
+		t.Errorf("expected default size without validate, got %q", got)
+	}
+	if got := (ListRequest{Page: math.MaxInt, Size: 100}).Offset(); got != math.MaxInt64 {
+		t.Errorf("expected offset capped, got %d", got)
+	}
+}
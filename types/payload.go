@@ -0,0 +1,211 @@
+package types
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// HeaderContentEncoding message header carrying the compression of payload
+	HeaderContentEncoding = "content-encoding"
+
+	// EncodingGzip gzip compressed payload
+	EncodingGzip = "gzip"
+	// EncodingZstd zstd compressed payload
+	EncodingZstd = "zstd"
+)
+
+// PayloadLimits size guard and compression of message payload, set per publisher and consumer
+type PayloadLimits struct {
+	// MaxMessageBytes maximum payload size after compression on publish and after decompression on consume,
+	// zero means unlimited
+	MaxMessageBytes int
+	// CompressThreshold payload bigger than threshold is compressed on publish, zero disables compression
+	CompressThreshold int
+	// Encoding compression of payload, EncodingGzip (default) or EncodingZstd
+	Encoding string
+}
+
+// PayloadStats size metrics of published payloads
+type PayloadStats struct {
+	Published       int64 // payloads encoded for publishing
+	Compressed      int64 // payloads compressed
+	Rejected        int64 // payloads rejected by MaxMessageBytes, on publish or consume
+	BytesBefore     int64 // total payload bytes before compression
+	BytesAfter      int64 // total payload bytes after compression
+	DecodedMessages int64 // compressed payloads decompressed on consume
+}
+
+// ErrMessageTooLarge payload exceeds PayloadLimits.MaxMessageBytes
+type ErrMessageTooLarge struct {
+	Topic string
+	Size  int
+	Max   int
+}
+
+// Error message of error
+func (e *ErrMessageTooLarge) Error() string {
+	return fmt.Sprintf("message of topic %s is %d bytes, exceeds limit %d", e.Topic, e.Size, e.Max)
+}
+
+var (
+	payloadPublished, payloadCompressed, payloadRejected  atomic.Int64
+	payloadBytesBefore, payloadBytesAfter, payloadDecoded atomic.Int64
+)
+
+// GetPayloadStats size metrics of payloads of every publisher and consumer since start
+func GetPayloadStats() PayloadStats {
+	return PayloadStats{
+		Published:       payloadPublished.Load(),
+		Compressed:      payloadCompressed.Load(),
+		Rejected:        payloadRejected.Load(),
+		BytesBefore:     payloadBytesBefore.Load(),
+		BytesAfter:      payloadBytesAfter.Load(),
+		DecodedMessages: payloadDecoded.Load(),
+	}
+}
+
+// Encode compress payload above limits.CompressThreshold setting HeaderContentEncoding and reject payload
+// above limits.MaxMessageBytes with ErrMessageTooLarge. every publisher must call it exactly once on
+// PublishMessage after Validate, payload already carrying HeaderContentEncoding is never compressed twice
+func (p PublisherArgument) Encode(limits PayloadLimits) (PublisherArgument, error) {
+	return p.encode(limits, false)
+}
+
+// EncodeBase64 like Encode for transports carrying text only, e.g. SQS, compressed payload is base64 encoded
+// and limits.MaxMessageBytes applies to the encoded payload
+func (p PublisherArgument) EncodeBase64(limits PayloadLimits) (PublisherArgument, error) {
+	return p.encode(limits, true)
+}
+
+func (p PublisherArgument) encode(limits PayloadLimits, text bool) (PublisherArgument, error) {
+	if limits.Encoding == "" {
+		limits.Encoding = EncodingGzip
+	}
+
+	topic := p.Topic
+	if topic == "" {
+		topic = p.Key
+	}
+
+	before := len(p.Message)
+	if _, encoded := p.Headers[HeaderContentEncoding]; !encoded && limits.CompressThreshold > 0 && before > limits.CompressThreshold {
+		compressed, err := compress(p.Message, limits.Encoding)
+		if err != nil {
+			return p, fmt.Errorf("encode message of topic %s: %w", topic, err)
+		}
+
+		headers := make(map[string]interface{}, len(p.Headers)+1)
+		for k, v := range p.Headers {
+			headers[k] = v
+		}
+		headers[HeaderContentEncoding] = limits.Encoding
+
+		p.Headers = headers
+		p.Message = compressed
+		if text {
+			p.Message = []byte(base64.StdEncoding.EncodeToString(compressed))
+		}
+		payloadCompressed.Add(1)
+	}
+
+	if limits.MaxMessageBytes > 0 && len(p.Message) > limits.MaxMessageBytes {
+		payloadRejected.Add(1)
+		return p, &ErrMessageTooLarge{Topic: topic, Size: len(p.Message), Max: limits.MaxMessageBytes}
+	}
+
+	payloadPublished.Add(1)
+	payloadBytesBefore.Add(int64(before))
+	payloadBytesAfter.Add(int64(len(p.Message)))
+
+	return p, nil
+}
+
+// DecodePayload decompress payload according to HeaderContentEncoding of header, payload without the header
+// is returned as is. payload bigger than limits.MaxMessageBytes fails with ErrNonRetryable.
+// every consumer must call it exactly once per message
+func DecodePayload(message []byte, header map[string]string, limits PayloadLimits) ([]byte, error) {
+	encoding := header[HeaderContentEncoding]
+	if encoding != "" {
+		decoded, err := decompress(message, encoding, limits.MaxMessageBytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: decode %s payload: %v", ErrNonRetryable, encoding, err)
+		}
+
+		message = decoded
+		payloadDecoded.Add(1)
+	}
+
+	if limits.MaxMessageBytes > 0 && len(message) > limits.MaxMessageBytes {
+		payloadRejected.Add(1)
+		return nil, fmt.Errorf("%w: payload exceeds limit %d", ErrNonRetryable, limits.MaxMessageBytes)
+	}
+
+	return message, nil
+}
+
+func compress(message []byte, encoding string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	switch encoding {
+	case EncodingGzip:
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(message); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	case EncodingZstd:
+		w, err := zstd.NewWriter(&buf)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = w.Write(message); err != nil {
+			return nil, err
+		}
+		if err = w.Close(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decompress payload reading at most max+1 bytes, so compression bombs are stopped early
+func decompress(message []byte, encoding string, max int) ([]byte, error) {
+	var r io.Reader
+
+	switch encoding {
+	case EncodingGzip:
+		gr, err := gzip.NewReader(bytes.NewReader(message))
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	case EncodingZstd:
+		zr, err := zstd.NewReader(bytes.NewReader(message))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+
+	if max > 0 {
+		r = io.LimitReader(r, int64(max)+1)
+	}
+
+	return io.ReadAll(r)
+}
@@ -0,0 +1,90 @@
+package types
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestPayloadCompressionRoundTrip(t *testing.T) {
+	payload := bytes.Repeat([]byte(`{"order_id":"1","amount":10},`), 100)
+	for _, encoding := range []string{EncodingGzip, EncodingZstd} {
+		limits := PayloadLimits{CompressThreshold: 256, Encoding: encoding}
+		before := GetPayloadStats()
+
+		args, err := PublisherArgument{Topic: "order.created", Message: payload}.Encode(limits)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if args.Headers[HeaderContentEncoding] != encoding || len(args.Message) >= len(payload) {
+			t.Fatalf("%s: expected compressed payload with header, got %d bytes %v", encoding, len(args.Message), args.Headers)
+		}
+
+		after := GetPayloadStats()
+		if after.Compressed-before.Compressed != 1 || after.BytesBefore-before.BytesBefore != int64(len(payload)) ||
+			after.BytesAfter-before.BytesAfter != int64(len(args.Message)) {
+			t.Errorf("%s: expected size metrics recorded, got %+v", encoding, after)
+		}
+
+		decoded, err := DecodePayload(args.Message, map[string]string{HeaderContentEncoding: encoding}, limits)
+		if err != nil || !bytes.Equal(decoded, payload) {
+			t.Errorf("%s: expected original payload, got %v", encoding, err)
+		}
+
+		// encoding twice never compresses again
+		if again, _ := args.Encode(limits); !bytes.Equal(again.Message, args.Message) {
+			t.Errorf("%s: expected encoded payload untouched", encoding)
+		}
+	}
+
+	small, _ := PublisherArgument{Message: []byte(`{}`)}.Encode(PayloadLimits{CompressThreshold: 256})
+	if _, ok := small.Headers[HeaderContentEncoding]; ok {
+		t.Error("expected payload under threshold left uncompressed")
+	}
+
+	if got, err := DecodePayload([]byte(`{}`), nil, PayloadLimits{}); err != nil || string(got) != `{}` {
+		t.Errorf("expected payload without header passed through, got %s %v", got, err)
+	}
+}
+
+func TestPayloadMaxMessageBytes(t *testing.T) {
+	limits := PayloadLimits{MaxMessageBytes: 64}
+
+	_, err := PublisherArgument{Key: "order.created", Message: bytes.Repeat([]byte("x"), 65)}.Encode(limits)
+	var tl *ErrMessageTooLarge
+	if !errors.As(err, &tl) || tl.Topic != "order.created" || tl.Size != 65 || tl.Max != 64 {
+		t.Fatalf("expected message too large, got %v", err)
+	}
+
+	// compressed bomb is stopped at the limit on consume
+	compressed, _ := compress(bytes.Repeat([]byte("x"), 1<<20), EncodingGzip)
+	if _, err = DecodePayload(compressed, map[string]string{HeaderContentEncoding: EncodingGzip}, limits); !errors.Is(err, ErrNonRetryable) {
+		t.Errorf("expected oversized payload non-retryable, got %v", err)
+	}
+
+	if _, err = DecodePayload([]byte("x"), map[string]string{HeaderContentEncoding: "br"}, limits); !errors.Is(err, ErrNonRetryable) {
+		t.Errorf("expected unknown encoding non-retryable, got %v", err)
+	}
+}
+
+func TestPayloadEncodeBase64(t *testing.T) {
+	payload := bytes.Repeat([]byte(`{"order_id":"1","amount":10},`), 100)
+	args, err := PublisherArgument{Queue: "orders", Message: payload}.EncodeBase64(PayloadLimits{CompressThreshold: 256})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compressed, err := base64.StdEncoding.DecodeString(string(args.Message))
+	if err != nil {
+		t.Fatalf("expected base64 encoded payload, got %v", err)
+	}
+
+	// the limit applies to the encoded payload, bigger than the compressed one
+	limits := PayloadLimits{CompressThreshold: 256, MaxMessageBytes: len(compressed)}
+	var tl *ErrMessageTooLarge
+	if _, err = (PublisherArgument{Queue: "orders", Message: payload}).EncodeBase64(limits); !errors.As(err, &tl) || tl.Size != len(args.Message) {
+		t.Errorf("expected encoded payload over the limit rejected, got %v", err)
+	}
+}
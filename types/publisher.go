@@ -1,5 +1,10 @@
 package types
 
+import (
+	"context"
+	"sync"
+)
+
 type PublisherArgument struct {
 	PriorityMessage int
 	CorrelationId   string
@@ -11,3 +16,118 @@ type PublisherArgument struct {
 	Headers         map[string]interface{}
 	Message         []byte
 }
+
+// PublishBuffer queue of outgoing messages published by worker after handler succeed,
+// so follow-up events are never emitted for a message that is going to be redelivered
+type PublishBuffer struct {
+	messages []PublisherArgument
+}
+
+// Publish queue message to be published after handler returns nil
+func (p *PublishBuffer) Publish(args PublisherArgument) {
+	p.messages = append(p.messages, args)
+}
+
+// Messages get queued messages
+func (p *PublishBuffer) Messages() []PublisherArgument {
+	return p.messages
+}
+
+// Len number of queued messages
+func (p *PublishBuffer) Len() int {
+	return len(p.messages)
+}
+
+// Reset discard queued messages
+func (p *PublishBuffer) Reset() {
+	p.messages = nil
+}
+
+// defaultPublishedCap maximum deliveries tracked by a TailPublisher
+const defaultPublishedCap = 10000
+
+// TailPublisher publisher of the messages buffered by a handler, it keeps per delivery id the number of
+// messages already published so a redelivered message publishes only the unsent tail of its buffer instead
+// of duplicating the sent head. the handler is expected to buffer the same messages in the same order on
+// redelivery. the progress belongs to the instance, the oldest entries are evicted beyond its capacity
+type TailPublisher struct {
+	publish func(ctx context.Context, req PublisherArgument) error
+
+	mu    sync.Mutex
+	sent  map[string]int
+	order []string
+}
+
+// NewTailPublisher tail publisher sending through publish, e.g. the PublishMessage method of a publisher
+func NewTailPublisher(publish func(ctx context.Context, req PublisherArgument) error) *TailPublisher {
+	return &TailPublisher{publish: publish}
+}
+
+// Publish messages of id in order skipping the ones already published, stop on the first failure and
+// return its index. the progress of id is forgotten once every message is published
+func (p *TailPublisher) Publish(ctx context.Context, id string, messages []PublisherArgument) (int, error) {
+	for i := p.Sent(id); i < len(messages); i++ {
+		if err := p.publish(ctx, messages[i]); err != nil {
+			p.record(id, i)
+			return i, err
+		}
+	}
+	p.forget(id)
+
+	return len(messages), nil
+}
+
+// Sent number of buffered messages of id already published
+func (p *TailPublisher) Sent(id string) int {
+	if id == "" {
+		return 0
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.sent[id]
+}
+
+// record n buffered messages of id published before a failure
+func (p *TailPublisher) record(id string, n int) {
+	if id == "" || n < 1 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.sent == nil {
+		p.sent = make(map[string]int)
+	}
+	if _, ok := p.sent[id]; !ok {
+		p.order = append(p.order, id)
+	}
+	p.sent[id] = n
+
+	for len(p.order) > defaultPublishedCap {
+		delete(p.sent, p.order[0])
+		p.order = p.order[1:]
+	}
+}
+
+// forget id once its whole buffer is published
+func (p *TailPublisher) forget(id string) {
+	if id == "" {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.sent[id]; !ok {
+		return
+	}
+	delete(p.sent, id)
+	for i, v := range p.order {
+		if v == id {
+			p.order = append(p.order[:i], p.order[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// HeaderReplyError reply header carrying the handler error of request-reply
+const HeaderReplyError = "x-reply-error"
+
+var (
+	// ErrReplyTimeout no reply received before the request context is done
+	ErrReplyTimeout = errors.New("reply timeout")
+	// ErrOrphanReply reply with no waiting request, e.g. received after its request timed out
+	ErrOrphanReply = errors.New("orphan reply")
+	// ErrRequesterClosed requester disconnected while waiting for reply
+	ErrRequesterClosed = errors.New("requester closed")
+)
+
+// ReplyError handler of request-reply returned error
+type ReplyError struct {
+	CorrelationId string
+	Message       string
+}
+
+// Error message of error
+func (e *ReplyError) Error() string {
+	return fmt.Sprintf("reply %s: handler error: %s", e.CorrelationId, e.Message)
+}
@@ -8,6 +8,8 @@ const (
 	REST Server = "rest"
 	// GRPC server
 	GRPC Server = "grpc"
+	// HTTP plain net/http server
+	HTTP Server = "http"
 )
 
 func (s Server) String() string {
@@ -0,0 +1,104 @@
+package types
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// ContentTypeJSON payload decoded by encoding/json
+	ContentTypeJSON = "application/json"
+	// ContentTypeProtobuf payload decoded by proto, the handler type must be a proto.Message
+	ContentTypeProtobuf = "application/x-protobuf"
+	// ContentTypeText payload decoded as is, the handler type must be a string or []byte
+	ContentTypeText = "text/plain"
+)
+
+var (
+	defaultContentTypeMu sync.RWMutex
+	defaultContentType   = ContentTypeJSON
+)
+
+// SetDefaultContentType content type used by typed handlers when message has no or unknown content type,
+// default is ContentTypeJSON
+func SetDefaultContentType(contentType string) {
+	defaultContentTypeMu.Lock()
+	defer defaultContentTypeMu.Unlock()
+
+	defaultContentType = contentType
+}
+
+// RegisterTypedHandler register handler of queue receiving payload decoded by content type of message,
+// see ContentTypeJSON, ContentTypeProtobuf and ContentTypeText. Undecodable payload fails with ErrNonRetryable
+// so it is dead-lettered instead of retried forever
+func RegisterTypedHandler[T any](group *BrokerHandlerGroup, queue string, fn func(ctx context.Context, payload T) error, opts ...BrokerHandlerOption) {
+	group.AddBrokerHandler(func(ec *EventContext) error {
+		payload, err := decodePayload[T](ec.ContentType(), ec.Message())
+		if err != nil {
+			return fmt.Errorf("%w: queue %s: %v", ErrNonRetryable, queue, err)
+		}
+
+		return fn(ec.Context(), payload)
+	}, append([]BrokerHandlerOption{SetBrokerQueue(queue)}, opts...)...)
+}
+
+// decodePayload decode payload into T by media type of contentType
+func decodePayload[T any](contentType string, payload []byte) (T, error) {
+	var v T
+
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	if !knownContentType(mediaType) {
+		defaultContentTypeMu.RLock()
+		mediaType = defaultContentType
+		defaultContentTypeMu.RUnlock()
+	}
+
+	switch {
+	case mediaType == ContentTypeJSON || strings.HasSuffix(mediaType, "+json"):
+		if err := json.Unmarshal(payload, &v); err != nil {
+			return v, fmt.Errorf("decode json payload: %w", err)
+		}
+	case mediaType == ContentTypeProtobuf || mediaType == "application/protobuf":
+		// generated messages implement proto.Message on pointer, allocate it when T is a pointer
+		if rt := reflect.TypeOf(v); rt != nil && rt.Kind() == reflect.Pointer {
+			v = reflect.New(rt.Elem()).Interface().(T)
+		}
+
+		m, ok := any(v).(proto.Message)
+		if !ok {
+			return v, fmt.Errorf("decode protobuf payload: %T is not a proto.Message", v)
+		}
+
+		if err := proto.Unmarshal(payload, m); err != nil {
+			return v, fmt.Errorf("decode protobuf payload: %w", err)
+		}
+	case mediaType == ContentTypeText:
+		switch p := any(&v).(type) {
+		case *string:
+			*p = string(payload)
+		case *[]byte:
+			*p = payload
+		default:
+			return v, fmt.Errorf("decode text payload: %T is not a string", v)
+		}
+	default:
+		return v, fmt.Errorf("unsupported content type %q", contentType)
+	}
+
+	return v, nil
+}
+
+func knownContentType(mediaType string) bool {
+	switch mediaType {
+	case ContentTypeJSON, ContentTypeProtobuf, "application/protobuf", ContentTypeText:
+		return true
+	}
+
+	return strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,88 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type orderCreated struct {
+	OrderId string `json:"order_id"`
+}
+
+// deliver run the handler of group the way workers do
+func deliver(t *testing.T, group *BrokerHandlerGroup, contentType string, payload []byte) error {
+	t.Helper()
+
+	var ec EventContext
+	ec.SetContext(context.Background())
+	ec.SetContentType(contentType)
+	_, _ = ec.Write(payload)
+
+	return group.Handlers[len(group.Handlers)-1].HandlerFunc(&ec)
+}
+
+func TestTypedHandlerContentTypes(t *testing.T) {
+	var group BrokerHandlerGroup
+
+	var order orderCreated
+	RegisterTypedHandler(&group, "order.created", func(_ context.Context, v orderCreated) error {
+		order = v
+		return nil
+	})
+	if err := deliver(t, &group, "application/json; charset=utf-8", []byte(`{"order_id":"o-1"}`)); err != nil || order.OrderId != "o-1" {
+		t.Errorf("expected json decoded, got %v %v", order, err)
+	}
+	if group.Handlers[0].Queue != "order.created" {
+		t.Errorf("expected queue registered, got %q", group.Handlers[0].Queue)
+	}
+
+	var name string
+	RegisterTypedHandler(&group, "order.renamed", func(_ context.Context, v *wrapperspb.StringValue) error {
+		name = v.GetValue()
+		return nil
+	})
+	payload, _ := proto.Marshal(wrapperspb.String("renamed"))
+	if err := deliver(t, &group, ContentTypeProtobuf, payload); err != nil || name != "renamed" {
+		t.Errorf("expected protobuf decoded, got %q %v", name, err)
+	}
+
+	var text string
+	RegisterTypedHandler(&group, "order.note", func(_ context.Context, v string) error {
+		text = v
+		return nil
+	})
+	if err := deliver(t, &group, ContentTypeText, []byte("hello")); err != nil || text != "hello" {
+		t.Errorf("expected text decoded, got %q %v", text, err)
+	}
+
+	// unknown content type falls back to the default
+	SetDefaultContentType(ContentTypeText)
+	defer SetDefaultContentType(ContentTypeJSON)
+	if err := deliver(t, &group, "application/octet-stream", []byte("fallback")); err != nil || text != "fallback" {
+		t.Errorf("expected default content type used, got %q %v", text, err)
+	}
+}
+
+func TestTypedHandlerUndecodable(t *testing.T) {
+	var group BrokerHandlerGroup
+
+	called := false
+	RegisterTypedHandler(&group, "order.created", func(context.Context, orderCreated) error {
+		called = true
+		return nil
+	})
+
+	err := deliver(t, &group, ContentTypeJSON, []byte("garbage"))
+	if !errors.Is(err, ErrNonRetryable) || called {
+		t.Errorf("expected non-retryable error without calling handler, got %v", err)
+	}
+
+	RegisterTypedHandler(&group, "order.renamed", func(context.Context, *wrapperspb.StringValue) error { return nil })
+	if err = deliver(t, &group, ContentTypeProtobuf, []byte{0xff, 0xff}); !errors.Is(err, ErrNonRetryable) {
+		t.Errorf("expected non-retryable error for garbage protobuf, got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// PayloadValidatorFunc validate message payload before published
+type PayloadValidatorFunc func(payload []byte) error
+
+var (
+	payloadValidatorMu sync.RWMutex
+	payloadValidators  = make(map[string]PayloadValidatorFunc)
+	payloadStrict      bool
+)
+
+// ErrInvalidPayload payload rejected by validator of its topic
+type ErrInvalidPayload struct {
+	Topic      string
+	Violations []string
+}
+
+// Error message of error
+func (e *ErrInvalidPayload) Error() string {
+	if len(e.Violations) < 1 {
+		return fmt.Sprintf("invalid payload for topic %s", e.Topic)
+	}
+
+	return fmt.Sprintf("invalid payload for topic %s: %s", e.Topic, strings.Join(e.Violations, "; "))
+}
+
+// RegisterPayloadValidator register validator of topic consulted by publishers before publish,
+// registering the same topic twice will replace the previous one
+func RegisterPayloadValidator(topic string, fn PayloadValidatorFunc) {
+	payloadValidatorMu.Lock()
+	defer payloadValidatorMu.Unlock()
+
+	payloadValidators[topic] = fn
+}
+
+// SetPayloadValidationStrict on strict mode publishing into topic without validator is rejected,
+// lenient mode (default) allows unknown topics
+func SetPayloadValidationStrict(strict bool) {
+	payloadValidatorMu.Lock()
+	defer payloadValidatorMu.Unlock()
+
+	payloadStrict = strict
+}
+
+// Validate validate message payload with validator of its topic, the topic is Topic or Key when Topic is empty.
+// the brokers of gokit call it on PublishMessage, publishers of other brokers given to server.SetBroker are wrapped to call it
+func (p PublisherArgument) Validate() error {
+	topic := p.Topic
+	if topic == "" {
+		topic = p.Key
+	}
+
+	payloadValidatorMu.RLock()
+	fn, ok := payloadValidators[topic]
+	strict := payloadStrict
+	payloadValidatorMu.RUnlock()
+
+	if !ok {
+		if strict {
+			return &ErrInvalidPayload{Topic: topic, Violations: []string{"no validator registered for topic"}}
+		}
+
+		return nil
+	}
+
+	err := fn(p.Message)
+	if err == nil {
+		return nil
+	}
+
+	if ip, ok := err.(*ErrInvalidPayload); ok {
+		ip.Topic = topic
+		return ip
+	}
+
+	return &ErrInvalidPayload{Topic: topic, Violations: []string{err.Error()}}
+}
+
+// JSONSchemaValidator create payload validator from JSON schema document
+func JSONSchemaValidator(schema string) (PayloadValidatorFunc, error) {
+	compiled, err := jsonschema.CompileString("schema.json", schema)
+	if err != nil {
+		return nil, fmt.Errorf("compile json schema: %w", err)
+	}
+
+	return func(payload []byte) error {
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader(payload))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return &ErrInvalidPayload{Violations: []string{fmt.Sprintf("malformed json: %s", err)}}
+		}
+
+		err := compiled.Validate(v)
+		if err == nil {
+			return nil
+		}
+
+		ve, ok := err.(*jsonschema.ValidationError)
+		if !ok {
+			return &ErrInvalidPayload{Violations: []string{err.Error()}}
+		}
+
+		var violations []string
+		for _, e := range ve.BasicOutput().Errors {
+			if e.KeywordLocation == "" || strings.HasPrefix(e.Error, "doesn't validate with") {
+				continue
+			}
+
+			violations = append(violations, fmt.Sprintf("%s: %s", e.InstanceLocation, e.Error))
+		}
+
+		return &ErrInvalidPayload{Violations: violations}
+	}, nil
+}
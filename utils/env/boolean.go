@@ -3,7 +3,7 @@ package env
 import (
 	"strconv"
 
-	"github.com/spf13/viper"
+	"github.com/spf13/cast"
 )
 
 func GetBool(key string, defaultValues ...bool) bool {
@@ -12,7 +12,7 @@ func GetBool(key string, defaultValues ...bool) bool {
 		defaultValue = defaultValues[0]
 	}
 
-	val, err := strconv.ParseBool(viper.GetString(key))
+	val, err := strconv.ParseBool(cast.ToString(get(key)))
 	if err != nil {
 		return defaultValue
 	}
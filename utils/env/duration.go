@@ -4,7 +4,7 @@ import (
 	"reflect"
 	"time"
 
-	"github.com/spf13/viper"
+	"github.com/spf13/cast"
 )
 
 func GetDuration(key string, defaultValues ...time.Duration) time.Duration {
@@ -13,7 +13,7 @@ func GetDuration(key string, defaultValues ...time.Duration) time.Duration {
 		defaultValue = defaultValues[0]
 	}
 
-	val, err := time.ParseDuration(viper.GetString(key))
+	val, err := time.ParseDuration(cast.ToString(get(key)))
 	if err != nil {
 		return defaultValue
 	}
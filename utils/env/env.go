@@ -0,0 +1,222 @@
+package env
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// Resolution order used by all getters in this package:
+//  1. override layer, set by Override, envtest.OverrideForTest or Restore
+//  2. viper resolution: viper.Set, environment variable (AutomaticEnv), config file, then viper default
+//
+// Keys are case-insensitive and dots are equivalent to underscores, "database.host" and "DATABASE_HOST"
+// resolve to the same environment variable DATABASE_HOST and the same config file key, see EnvName and BindAll.
+//
+// The override layer is authoritative, so tests never depend on viper caching of process env.
+
+// ValueSource where a value is resolved from, see Source
+type ValueSource string
+
+const (
+	SourceOverride ValueSource = "override"
+	SourceEnv      ValueSource = "env"
+	SourceFile     ValueSource = "file"
+	SourceDefault  ValueSource = "default"
+	SourceNone     ValueSource = "none"
+)
+
+// keyReplacer normalize dotted key into its environment variable name
+var keyReplacer = strings.NewReplacer(".", "_")
+
+// EnvName environment variable name of key, e.g. "database.host" is DATABASE_HOST
+func EnvName(key string) string {
+	return strings.ToUpper(keyReplacer.Replace(key))
+}
+
+// KeyReplacer replacer of dotted key into environment variable name, used with viper.SetEnvKeyReplacer
+func KeyReplacer() *strings.Replacer {
+	return keyReplacer
+}
+
+// BindAll bind every loaded config key to its environment variable, so nested config key
+// "database.host" is overridden by DATABASE_HOST, called after config is loaded
+func BindAll() {
+	for _, key := range viper.AllKeys() {
+		_ = viper.BindEnv(key, EnvName(key))
+	}
+}
+
+// Source report where value of key is resolved from
+func Source(key string) ValueSource {
+	overrideMu.RLock()
+	_, ok := overrides[overrideKey(key)]
+	overrideMu.RUnlock()
+
+	switch {
+	case ok:
+		return SourceOverride
+	case lookupEnv(key):
+		return SourceEnv
+	}
+
+	for _, k := range candidates(key) {
+		if viper.InConfig(k) {
+			return SourceFile
+		}
+	}
+
+	for _, k := range candidates(key) {
+		if viper.IsSet(k) {
+			return SourceDefault
+		}
+	}
+
+	return SourceNone
+}
+
+func lookupEnv(key string) bool {
+	_, ok := os.LookupEnv(EnvName(key))
+	return ok
+}
+
+// candidates config keys of key, dotted key falls back to its underscored form used by flat files like .env,
+// underscored key falls back to its dotted form used by nested files like yaml
+func candidates(key string) []string {
+	key = strings.ToLower(key)
+	if flat := keyReplacer.Replace(key); flat != key {
+		return []string{key, flat}
+	}
+
+	if nested := strings.ReplaceAll(key, "_", "."); nested != key {
+		return []string{key, nested}
+	}
+
+	return []string{key}
+}
+
+// overrideKey key of the override layer, "db.host" and "DB_HOST" override the same value like candidates
+func overrideKey(key string) string {
+	return strings.ToLower(keyReplacer.Replace(key))
+}
+
+var (
+	overrideMu sync.RWMutex
+	overrides  = make(map[string]string)
+)
+
+// State captured state of the override layer, process environment and viper values, see Snapshot
+type State struct {
+	overrides map[string]string
+	environ   map[string]string
+	settings  map[string]interface{}
+}
+
+// get resolve raw value of key
+func get(key string) interface{} {
+	recordRead(key)
+
+	overrideMu.RLock()
+	val, ok := overrides[overrideKey(key)]
+	overrideMu.RUnlock()
+
+	if ok {
+		return val
+	}
+
+	for _, k := range candidates(key) {
+		if v := viper.Get(k); v != nil {
+			return v
+		}
+	}
+
+	return nil
+}
+
+// Snapshot take snapshot of the override layer, process environment and values resolved by viper
+func Snapshot() State {
+	overrideMu.RLock()
+	defer overrideMu.RUnlock()
+
+	s := State{
+		overrides: make(map[string]string, len(overrides)),
+		environ:   environ(),
+		settings:  make(map[string]interface{}),
+	}
+	for k, v := range overrides {
+		s.overrides[k] = v
+	}
+	for _, k := range viper.AllKeys() {
+		s.settings[k] = viper.Get(k)
+	}
+
+	return s
+}
+
+// Restore restore the override layer, process environment and viper values from snapshot,
+// keys set on viper after the snapshot are reset to nil since viper has no way to unset them
+func Restore(s State) {
+	overrideMu.Lock()
+	defer overrideMu.Unlock()
+
+	overrides = make(map[string]string, len(s.overrides))
+	for k, v := range s.overrides {
+		overrides[k] = v
+	}
+
+	for k := range environ() {
+		if _, ok := s.environ[k]; !ok {
+			_ = os.Unsetenv(k)
+		}
+	}
+	for k, v := range s.environ {
+		if cur, ok := os.LookupEnv(k); !ok || cur != v {
+			_ = os.Setenv(k, v)
+		}
+	}
+
+	for _, k := range viper.AllKeys() {
+		if v, ok := s.settings[k]; !ok {
+			viper.Set(k, nil)
+		} else if !reflect.DeepEqual(viper.Get(k), v) {
+			viper.Set(k, v)
+		}
+	}
+}
+
+// Override override value of key in the override layer until restore is called,
+// restore puts back the previous value of key, see envtest.OverrideForTest
+func Override(key, value string) (restore func()) {
+	key = overrideKey(key)
+
+	overrideMu.Lock()
+	prev, existed := overrides[key]
+	overrides[key] = value
+	overrideMu.Unlock()
+
+	return func() {
+		overrideMu.Lock()
+		defer overrideMu.Unlock()
+
+		if existed {
+			overrides[key] = prev
+		} else {
+			delete(overrides, key)
+		}
+	}
+}
+
+// environ process environment by name
+func environ() map[string]string {
+	out := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			out[k] = v
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,64 @@
+package env
+
+import (
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestOverride(t *testing.T) {
+	restore := Override("TEST_ENV_OVERRIDE", "outer")
+	defer restore()
+
+	inner := Override("TEST_ENV_OVERRIDE", "inner")
+	if v := GetString("TEST_ENV_OVERRIDE"); v != "inner" {
+		t.Errorf("expected inner override, got %q", v)
+	}
+
+	inner()
+	if v := GetString("TEST_ENV_OVERRIDE"); v != "outer" {
+		t.Errorf("expected previous override restored, got %q", v)
+	}
+}
+
+func TestOverrideNormalized(t *testing.T) {
+	t.Cleanup(Override("TEST_ENV_DB_HOST", "override"))
+
+	if v := GetString("test.env.db.host"); v != "override" {
+		t.Errorf("expected override of underscored key on dotted lookup, got %q", v)
+	}
+	if s := Source("test.env.db.host"); s != SourceOverride {
+		t.Errorf("expected override source, got %q", s)
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	t.Setenv("TEST_ENV_SNAPSHOT_KEPT", "before")
+	viper.Set("test_env_snapshot_viper", "before")
+	t.Cleanup(func() { viper.Set("test_env_snapshot_viper", nil) })
+
+	s := Snapshot()
+	t.Cleanup(Override("TEST_ENV_SNAPSHOT", "true"))
+	t.Setenv("TEST_ENV_SNAPSHOT_KEPT", "after")
+	t.Setenv("TEST_ENV_SNAPSHOT_ADDED", "after")
+	viper.Set("test_env_snapshot_viper", "after")
+	viper.Set("test_env_snapshot_viper_added", "after")
+
+	Restore(s)
+	if GetBool("TEST_ENV_SNAPSHOT") {
+		t.Error("expected override removed after restore")
+	}
+	if v := os.Getenv("TEST_ENV_SNAPSHOT_KEPT"); v != "before" {
+		t.Errorf("expected process env restored, got %q", v)
+	}
+	if _, ok := os.LookupEnv("TEST_ENV_SNAPSHOT_ADDED"); ok {
+		t.Error("expected process env added after snapshot removed")
+	}
+	if v := GetString("test_env_snapshot_viper"); v != "before" {
+		t.Errorf("expected viper value restored, got %q", v)
+	}
+	if v := GetString("test_env_snapshot_viper_added"); v != "" {
+		t.Errorf("expected viper value set after snapshot reset, got %q", v)
+	}
+}
@@ -0,0 +1,16 @@
+// Package envtest test helpers of package env, kept apart so production binaries don't link package testing
+package envtest
+
+import (
+	"testing"
+
+	"github.com/TixiaOTA/gokit/utils/env"
+)
+
+// OverrideForTest override value of key for the duration of the test,
+// previous value is restored on t.Cleanup
+func OverrideForTest(t testing.TB, key, value string) {
+	t.Helper()
+
+	t.Cleanup(env.Override(key, value))
+}
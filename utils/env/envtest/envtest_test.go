@@ -0,0 +1,33 @@
+package envtest
+
+import (
+	"testing"
+
+	"github.com/TixiaOTA/gokit/utils/env"
+)
+
+func TestOverrideForTest(t *testing.T) {
+	t.Run("string", func(t *testing.T) {
+		t.Parallel()
+		OverrideForTest(t, "TEST_ENV_STRING", "value")
+
+		if v := env.GetString("TEST_ENV_STRING"); v != "value" {
+			t.Errorf("expected override value, got %q", v)
+		}
+	})
+
+	t.Run("integer", func(t *testing.T) {
+		t.Parallel()
+		OverrideForTest(t, "TEST_ENV_INTEGER", "42")
+
+		if v := env.GetInteger("TEST_ENV_INTEGER"); v != 42 {
+			t.Errorf("expected override value, got %d", v)
+		}
+	})
+
+	t.Cleanup(func() {
+		if v := env.GetString("TEST_ENV_STRING", "default"); v != "default" {
+			t.Errorf("expected override restored, got %q", v)
+		}
+	})
+}
@@ -3,13 +3,13 @@ package env
 import (
 	"reflect"
 
-	"github.com/spf13/viper"
+	"github.com/spf13/cast"
 )
 
 func GetFloat(key string, defaultValues ...float64) float64 {
 	var defaultValue float64 = -1
 
-	val := viper.GetFloat64(key)
+	val := cast.ToFloat64(get(key))
 	if reflect.ValueOf(val).IsZero() {
 		if len(defaultValues) > 0 {
 			defaultValue = defaultValues[0]
@@ -3,13 +3,13 @@ package env
 import (
 	"reflect"
 
-	"github.com/spf13/viper"
+	"github.com/spf13/cast"
 )
 
 func GetInteger(key string, defaultValues ...int) (resp int) {
 	defaultValue := 0
 
-	val := viper.GetInt(key)
+	val := cast.ToInt(get(key))
 	if reflect.ValueOf(val).IsZero() {
 		if len(defaultValues) > 0 {
 			defaultValue = defaultValues[0]
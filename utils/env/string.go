@@ -3,13 +3,13 @@ package env
 import (
 	"reflect"
 
-	"github.com/spf13/viper"
+	"github.com/spf13/cast"
 )
 
 func GetString(key string, defaultValues ...string) (resp string) {
 	defaultValue := ""
 
-	val := viper.GetString(key)
+	val := cast.ToString(get(key))
 	if reflect.ValueOf(val).IsZero() {
 		if len(defaultValues) > 0 {
 			defaultValue = defaultValues[0]
@@ -1,9 +1,11 @@
 package env
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
-	"github.com/spf13/viper"
+	"github.com/spf13/cast"
 )
 
 type OptionTime func(t *times)
@@ -11,6 +13,7 @@ type OptionTime func(t *times)
 type times struct {
 	defaultTime     time.Time
 	format          string
+	layouts         []string
 	parseInLocation bool
 	timezone        *time.Location
 }
@@ -29,6 +32,13 @@ func SetFormatTime(format string) OptionTime {
 	}
 }
 
+// SetLayouts parse value with time.RFC3339 then layouts in order instead of the single format
+func SetLayouts(layouts ...string) OptionTime {
+	return func(t *times) {
+		t.layouts = append([]string{time.RFC3339}, layouts...)
+	}
+}
+
 func SetDefaultValue(dv time.Time) OptionTime {
 	return func(t *times) {
 		t.defaultTime = dv
@@ -52,7 +62,12 @@ func GetTime(key string, options ...OptionTime) time.Time {
 	for _, option := range options {
 		option(&t)
 	}
-	val, err := time.Parse(t.format, viper.GetString(key))
+	layouts := t.layouts
+	if len(layouts) < 1 {
+		layouts = []string{t.format}
+	}
+
+	val, err := parseTime(strings.TrimSpace(cast.ToString(get(key))), layouts)
 	if err != nil {
 		return t.defaultTime
 	}
@@ -63,3 +78,39 @@ func GetTime(key string, options ...OptionTime) time.Time {
 
 	return val
 }
+
+func parseTime(value string, layouts []string) (val time.Time, err error) {
+	for _, layout := range layouts {
+		if val, err = time.Parse(layout, value); err == nil {
+			return val, nil
+		}
+	}
+
+	return val, err
+}
+
+// GetTimeOfDay hour and minute of "HH:MM" value, ok is false when value is empty or malformed
+func GetTimeOfDay(key string) (hour, minute int, ok bool) {
+	val, err := time.Parse("15:04", strings.TrimSpace(cast.ToString(get(key))))
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return val.Hour(), val.Minute(), true
+}
+
+// GetLocation load IANA time zone of value, e.g. "Asia/Jakarta", def is returned when value is empty
+// or is not a valid zone, the latter with error
+func GetLocation(key string, def *time.Location) (*time.Location, error) {
+	name := strings.TrimSpace(cast.ToString(get(key)))
+	if name == "" {
+		return def, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return def, fmt.Errorf("env: %s: invalid time zone %q: %w", EnvName(key), name, err)
+	}
+
+	return loc, nil
+}
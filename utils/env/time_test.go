@@ -0,0 +1,62 @@
+package env
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetTime(t *testing.T) {
+	t.Cleanup(Override("TEST_ENV_TIME_RFC3339", "2024-03-10T07:30:00Z"))
+	t.Cleanup(Override("TEST_ENV_TIME_DATE", "2024-03-10"))
+	t.Cleanup(Override("TEST_ENV_TIME_BAD", "10/03/2024"))
+
+	def := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	if v := GetTime("TEST_ENV_TIME_RFC3339", SetLayouts("2006-01-02")); !v.Equal(time.Date(2024, 3, 10, 7, 30, 0, 0, time.UTC)) {
+		t.Errorf("expected rfc3339 value, got %s", v)
+	}
+	if v := GetTime("TEST_ENV_TIME_DATE", SetLayouts("2006-01-02")); !v.Equal(time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected fallback layout value, got %s", v)
+	}
+	if v := GetTime("TEST_ENV_TIME_BAD", SetLayouts("2006-01-02"), SetDefaultValue(def)); !v.Equal(def) {
+		t.Errorf("expected default on malformed value, got %s", v)
+	}
+}
+
+func TestGetTimeOfDay(t *testing.T) {
+	t.Cleanup(Override("TEST_ENV_TIME_OF_DAY", "02:30"))
+	t.Cleanup(Override("TEST_ENV_TIME_OF_DAY_BAD", "25:00"))
+
+	if h, m, ok := GetTimeOfDay("TEST_ENV_TIME_OF_DAY"); !ok || h != 2 || m != 30 {
+		t.Errorf("expected 02:30, got %d:%d (%v)", h, m, ok)
+	}
+	if _, _, ok := GetTimeOfDay("TEST_ENV_TIME_OF_DAY_BAD"); ok {
+		t.Error("expected malformed time of day rejected")
+	}
+	if _, _, ok := GetTimeOfDay("TEST_ENV_TIME_OF_DAY_MISSING"); ok {
+		t.Error("expected missing time of day rejected")
+	}
+}
+
+func TestGetLocation(t *testing.T) {
+	t.Cleanup(Override("TEST_ENV_LOCATION", "America/New_York"))
+	t.Cleanup(Override("TEST_ENV_LOCATION_BAD", "Mars/Olympus"))
+
+	loc, err := GetLocation("TEST_ENV_LOCATION", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2024-03-10 is the DST switch on New York, the same UTC offset before and after differs by an hour
+	before := time.Date(2024, 3, 10, 6, 30, 0, 0, time.UTC).In(loc)
+	after := time.Date(2024, 3, 10, 7, 30, 0, 0, time.UTC).In(loc)
+	if before.Hour() != 1 || after.Hour() != 3 {
+		t.Errorf("expected 01:30 EST and 03:30 EDT, got %s and %s", before, after)
+	}
+
+	if loc, err = GetLocation("TEST_ENV_LOCATION_BAD", time.UTC); err == nil || loc != time.UTC {
+		t.Errorf("expected default and error on invalid zone, got %v, %v", loc, err)
+	}
+	if loc, err = GetLocation("TEST_ENV_LOCATION_MISSING", time.Local); err != nil || loc != time.Local {
+		t.Errorf("expected default on missing zone, got %v, %v", loc, err)
+	}
+}
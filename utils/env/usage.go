@@ -0,0 +1,53 @@
+package env
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// KeyUsage reads of key through the getters of this package
+type KeyUsage struct {
+	Key       string    `json:"key"`
+	FirstRead time.Time `json:"first_read"`
+	Count     uint64    `json:"count"`
+}
+
+// keyUsage live counter of key, firstRead is set once on creation
+type keyUsage struct {
+	firstRead time.Time
+	count     atomic.Uint64
+}
+
+// usage read counters by key as passed to the getters, merged by environment variable name on Usage
+var usage sync.Map
+
+// recordRead count read of key, an atomic increment once the key has been read before
+func recordRead(key string) {
+	v, ok := usage.Load(key)
+	if !ok {
+		v, _ = usage.LoadOrStore(key, &keyUsage{firstRead: time.Now()})
+	}
+
+	v.(*keyUsage).count.Add(1)
+}
+
+// Usage keys read since the process started by their environment variable name, e.g. "database.host" and
+// DATABASE_HOST are both counted as DATABASE_HOST
+func Usage() map[string]KeyUsage {
+	out := make(map[string]KeyUsage)
+	usage.Range(func(k, v interface{}) bool {
+		u, name := v.(*keyUsage), EnvName(k.(string))
+
+		ku, ok := out[name]
+		if !ok || u.firstRead.Before(ku.FirstRead) {
+			ku.FirstRead = u.firstRead
+		}
+		ku.Key = name
+		ku.Count += u.count.Load()
+		out[name] = ku
+		return true
+	})
+
+	return out
+}
@@ -7,13 +7,16 @@ const (
 	ServiceUnavailable = "Layanan tidak tersedia, silakan coba lagi nanti"
 
 	// Client Errors
-	BadRequest          = "Permintaan tidak lengkap atau tidak valid"
-	Unauthorized        = "Akses tidak diizinkan, silakan login terlebih dahulu"
-	Forbidden           = "Anda tidak memiliki izin untuk mengakses sumber daya ini"
-	NotFound            = "Sumber daya yang diminta tidak ditemukan"
-	MethodNotAllowed    = "Metode HTTP yang digunakan tidak diizinkan untuk permintaan ini"
-	Conflict            = "Terjadi konflik saat memproses permintaan, silakan coba lagi"
-	UnprocessableEntity = "Entitas tidak dapat diproses, periksa data yang dikirim"
+	BadRequest           = "Permintaan tidak lengkap atau tidak valid"
+	Unauthorized         = "Akses tidak diizinkan, silakan login terlebih dahulu"
+	Forbidden            = "Anda tidak memiliki izin untuk mengakses sumber daya ini"
+	NotFound             = "Sumber daya yang diminta tidak ditemukan"
+	MethodNotAllowed     = "Metode HTTP yang digunakan tidak diizinkan untuk permintaan ini"
+	Conflict             = "Terjadi konflik saat memproses permintaan, silakan coba lagi"
+	UnprocessableEntity  = "Entitas tidak dapat diproses, periksa data yang dikirim"
+	FileTooLarge         = "Ukuran file melebihi batas yang diizinkan"
+	UnsupportedMediaType = "Tipe file tidak didukung"
+	TooManyRequests      = "Terlalu banyak permintaan, silakan coba beberapa saat lagi"
 
 	// Validation Errors
 	ValidationError    = "Data yang dikirim tidak valid"
@@ -0,0 +1,158 @@
+// Package featureflag boolean feature flags with percentage rollout read from env, e.g.
+//
+//	FLAG_NEW_PRICING=true           enabled for everyone
+//	FLAG_NEW_PRICING=25%            enabled for 25% of stable keys
+//	FLAG_NEW_PRICING=on:user1,user2 enabled only for user1 and user2
+//	FLAG_NEW_PRICING=off:user3      enabled for everyone except user3
+//	FLAG_NEW_PRICING=10%;on:user1   clauses are combined with ";", off wins over on, on wins over percentage
+//
+// The order of clauses does not matter, a denylist enables everyone else only without allowlist and percentage.
+//
+// Values are read through env on every call, so changes are picked up live with config.Watch.
+package featureflag
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/TixiaOTA/gokit/utils/env"
+)
+
+// buckets resolution of percentage rollout, 0.01%
+const buckets = 10000
+
+// rule parsed value of flag
+type rule struct {
+	// percent enabled share of stable keys in buckets, buckets means everyone
+	percent int
+	allow   map[string]struct{}
+	deny    map[string]struct{}
+}
+
+// rules parsed rule by raw value, flags are evaluated on hot paths
+var rules sync.Map
+
+// IsEnabled report whether flag is enabled for everyone, percentage rollout below 100% and allowlist are disabled
+func IsEnabled(name string) bool {
+	r, ok := lookup(name)
+	return ok && r.percent >= buckets && len(r.deny) < 1
+}
+
+// IsEnabledFor report whether flag is enabled for stableKey, e.g. user id, the same key always gets the same
+// answer for the same percentage and keys enabled at a lower percentage stay enabled when the rollout grows
+func IsEnabledFor(name, stableKey string) bool {
+	r, ok := lookup(name)
+	if !ok {
+		return false
+	}
+
+	if _, denied := r.deny[stableKey]; denied {
+		return false
+	}
+
+	if _, allowed := r.allow[stableKey]; allowed {
+		return true
+	}
+
+	return bucket(name, stableKey) < r.percent
+}
+
+// lookup parsed rule of flag, unset or invalid flag is disabled
+func lookup(name string) (rule, bool) {
+	raw := strings.TrimSpace(env.GetString(name))
+	if raw == "" {
+		return rule{}, false
+	}
+
+	if r, ok := rules.Load(raw); ok {
+		return r.(rule), true
+	}
+
+	r, err := parse(raw)
+	if err != nil {
+		return rule{}, false
+	}
+
+	rules.Store(raw, r)
+	return r, true
+}
+
+// parse value of flag into rule, the outcome does not depend on the order of clauses
+func parse(raw string) (rule, error) {
+	var (
+		r       rule
+		percent bool
+	)
+
+	for _, clause := range strings.Split(raw, ";") {
+		clause = strings.TrimSpace(clause)
+
+		switch {
+		case clause == "":
+			continue
+		case strings.HasPrefix(clause, "on:"):
+			r.allow = keySet(r.allow, clause[len("on:"):])
+		case strings.HasPrefix(clause, "off:"):
+			r.deny = keySet(r.deny, clause[len("off:"):])
+		case strings.HasSuffix(clause, "%"):
+			pct, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(clause, "%")), 64)
+			if err != nil || pct < 0 || pct > 100 {
+				return rule{}, fmt.Errorf("featureflag: invalid percentage %q", clause)
+			}
+			r.percent = int(pct * buckets / 100)
+			percent = true
+		default:
+			switch strings.ToLower(clause) {
+			case "on":
+				r.percent = buckets
+			case "off":
+				r.percent = 0
+			default:
+				enabled, err := strconv.ParseBool(clause)
+				if err != nil {
+					return rule{}, fmt.Errorf("featureflag: invalid clause %q", clause)
+				}
+
+				r.percent = 0
+				if enabled {
+					r.percent = buckets
+				}
+			}
+			percent = true
+		}
+	}
+
+	// denylist alone enables everyone else
+	if r.deny != nil && r.allow == nil && !percent {
+		r.percent = buckets
+	}
+
+	return r, nil
+}
+
+func keySet(set map[string]struct{}, list string) map[string]struct{} {
+	if set == nil {
+		set = make(map[string]struct{})
+	}
+
+	for _, key := range strings.Split(list, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			set[key] = struct{}{}
+		}
+	}
+
+	return set
+}
+
+// bucket consistent bucket of stableKey for flag, flags are hashed independently
+func bucket(name, stableKey string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(strings.ToLower(name)))
+	_, _ = h.Write([]byte{':'})
+	_, _ = h.Write([]byte(stableKey))
+
+	return int(h.Sum32() % buckets)
+}
@@ -0,0 +1,104 @@
+package featureflag
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/TixiaOTA/gokit/utils/env/envtest"
+)
+
+func TestParse(t *testing.T) {
+	for raw, want := range map[string]rule{
+		"true":            {percent: buckets},
+		"off":             {},
+		"25%":             {percent: 2500},
+		"0.5%":            {percent: 50},
+		"on:user1, user2": {allow: map[string]struct{}{"user1": {}, "user2": {}}},
+		"off:user3":       {percent: buckets, deny: map[string]struct{}{"user3": {}}},
+		"10%;on:vip":      {percent: 1000, allow: map[string]struct{}{"vip": {}}},
+	} {
+		got, err := parse(raw)
+		if err != nil {
+			t.Fatalf("%q: %v", raw, err)
+		}
+
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%q: expected %+v, got %+v", raw, want, got)
+		}
+	}
+
+	for _, raw := range []string{"120%", "abc%", "maybe"} {
+		if _, err := parse(raw); err == nil {
+			t.Errorf("%q: expected invalid flag", raw)
+		}
+	}
+}
+
+func TestParseClauseOrder(t *testing.T) {
+	for _, clauses := range [][2]string{
+		{"off:u3;on:u1", "on:u1;off:u3"},
+		{"off:u3;25%", "25%;off:u3"},
+		{"off:u1;on:u1", "on:u1;off:u1"},
+	} {
+		first, _ := parse(clauses[0])
+		second, _ := parse(clauses[1])
+		if fmt.Sprint(first) != fmt.Sprint(second) {
+			t.Errorf("expected %q and %q equal, got %+v and %+v", clauses[0], clauses[1], first, second)
+		}
+	}
+
+	// explicit off wins over on, the allowlist alone keeps everyone else disabled
+	for _, raw := range []string{"off:u1;on:u1,u2", "on:u1,u2;off:u1"} {
+		envtest.OverrideForTest(t, "FLAG_ORDER", raw)
+		if IsEnabledFor("FLAG_ORDER", "u1") || !IsEnabledFor("FLAG_ORDER", "u2") || IsEnabledFor("FLAG_ORDER", "u3") {
+			t.Errorf("%q: expected only u2 enabled", raw)
+		}
+	}
+}
+
+func TestIsEnabledFor(t *testing.T) {
+	envtest.OverrideForTest(t, "FLAG_NEW_PRICING", "25%;on:vip;off:blocked")
+
+	enabled := 0
+	for i := 0; i < 10000; i++ {
+		if IsEnabledFor("FLAG_NEW_PRICING", fmt.Sprintf("user-%d", i)) {
+			enabled++
+		}
+	}
+
+	if enabled < 2300 || enabled > 2700 {
+		t.Errorf("expected about 25%% enabled, got %d of 10000", enabled)
+	}
+
+	if !IsEnabledFor("FLAG_NEW_PRICING", "vip") || IsEnabledFor("FLAG_NEW_PRICING", "blocked") {
+		t.Error("expected allowlist and denylist win over percentage")
+	}
+
+	if IsEnabled("FLAG_NEW_PRICING") {
+		t.Error("expected partial rollout disabled without stable key")
+	}
+}
+
+func TestIsEnabledForStableOnRollout(t *testing.T) {
+	envtest.OverrideForTest(t, "FLAG_CHECKOUT", "10%")
+
+	var early []string
+	for i := 0; i < 1000; i++ {
+		if key := fmt.Sprintf("user-%d", i); IsEnabledFor("FLAG_CHECKOUT", key) {
+			early = append(early, key)
+		}
+	}
+
+	// changed value is picked up on the next call
+	envtest.OverrideForTest(t, "FLAG_CHECKOUT", "50%")
+	for _, key := range early {
+		if !IsEnabledFor("FLAG_CHECKOUT", key) {
+			t.Fatalf("expected %s kept enabled when rollout grows", key)
+		}
+	}
+
+	envtest.OverrideForTest(t, "FLAG_CHECKOUT", "on")
+	if !IsEnabled("FLAG_CHECKOUT") || IsEnabled("FLAG_UNSET") {
+		t.Error("expected plain boolean flags")
+	}
+}
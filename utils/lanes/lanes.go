@@ -0,0 +1,91 @@
+// Package lanes dispatch jobs into a fixed number of serial lanes by key,
+// jobs sharing a key run in order while different keys run in parallel
+package lanes
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/TixiaOTA/gokit/utils/safe"
+)
+
+// Dispatcher fixed number of lanes, each lane has bounded queue and runs its jobs one by one
+type Dispatcher struct {
+	mu      sync.RWMutex
+	closed  bool
+	lanes   []chan func()
+	pending atomic.Int64
+	wg      sync.WaitGroup
+}
+
+// New create dispatcher with n lanes of queueSize, both default to 1
+func New(n, queueSize int) *Dispatcher {
+	if n < 1 {
+		n = 1
+	}
+	if queueSize < 1 {
+		queueSize = 1
+	}
+
+	d := &Dispatcher{lanes: make([]chan func(), n)}
+	for i := range d.lanes {
+		lane := make(chan func(), queueSize)
+		d.lanes[i] = lane
+
+		d.wg.Add(1)
+		safe.Go("lanes", func() { d.run(lane) })
+	}
+
+	return d
+}
+
+// Lane index of lane serving key
+func (d *Dispatcher) Lane(key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(d.lanes)))
+}
+
+// Dispatch queue job on the lane of key, blocks while the lane is full so the caller stops fetching,
+// return false when dispatcher is closed
+func (d *Dispatcher) Dispatch(key string, job func()) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		return false
+	}
+
+	d.pending.Add(1)
+	d.lanes[d.Lane(key)] <- job
+	return true
+}
+
+// Pending number of queued and running jobs
+func (d *Dispatcher) Pending() int {
+	return int(d.pending.Load())
+}
+
+// Close stop accepting jobs and wait every queued job done in order
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		for _, lane := range d.lanes {
+			close(lane)
+		}
+	}
+	d.mu.Unlock()
+
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) run(lane chan func()) {
+	defer d.wg.Done()
+
+	for job := range lane {
+		job()
+		d.pending.Add(-1)
+	}
+}
@@ -0,0 +1,64 @@
+package lanes
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDispatchOrderedPerKey(t *testing.T) {
+	d := New(4, 2)
+
+	keys := []string{"sku-1", "sku-2"}
+	for i := 0; d.Lane(keys[0]) == d.Lane(keys[1]); i++ {
+		keys[1] = fmt.Sprintf("sku-%d", i+3)
+	}
+
+	var (
+		mu               sync.Mutex
+		got              = make(map[string][]int)
+		running, maxSeen atomic.Int32
+	)
+
+	// interleaved events of two keys
+	for i := 0; i < 10; i++ {
+		for _, key := range keys {
+			key, seq := key, i
+			d.Dispatch(key, func() {
+				n := running.Add(1)
+				for m := maxSeen.Load(); n > m && !maxSeen.CompareAndSwap(m, n); m = maxSeen.Load() {
+				}
+				time.Sleep(5 * time.Millisecond)
+				running.Add(-1)
+
+				mu.Lock()
+				got[key] = append(got[key], seq)
+				mu.Unlock()
+			})
+		}
+	}
+
+	// shutdown drains queued events
+	d.Close()
+
+	for _, key := range keys {
+		if len(got[key]) != 10 {
+			t.Fatalf("expected every event of %s handled, got %v", key, got[key])
+		}
+		for i, seq := range got[key] {
+			if seq != i {
+				t.Fatalf("expected events of %s in order, got %v", key, got[key])
+			}
+		}
+	}
+
+	if maxSeen.Load() != 2 {
+		t.Errorf("expected both keys handled in parallel, max concurrency %d", maxSeen.Load())
+	}
+
+	if d.Dispatch(keys[0], func() {}) {
+		t.Error("expected dispatch rejected after close")
+	}
+}
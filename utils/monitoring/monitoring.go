@@ -27,13 +27,18 @@ var (
 )
 
 func NewPrometheus(serviceName string) {
+	NewPrometheusWithLabels(prometheus.Labels{"service": serviceName})
+}
+
+// NewPrometheusWithLabels register request metrics with const labels, e.g. factory.ServiceInfo labels
+func NewPrometheusWithLabels(constLabels prometheus.Labels) {
 	once.Do(func() {
 		str := []string{"code", "method", "path", "type"}
 
 		reqCounter := prometheus.NewCounterVec(prometheus.CounterOpts{
 			Help:        reqsHelp,
 			Name:        reqsName,
-			ConstLabels: prometheus.Labels{"service": serviceName},
+			ConstLabels: constLabels,
 		}, str)
 
 		if err := prometheus.Register(reqCounter); err != nil {
@@ -43,7 +48,7 @@ func NewPrometheus(serviceName string) {
 		reqLatency := prometheus.NewHistogramVec(prometheus.HistogramOpts{
 			Name:        latencyName,
 			Help:        latencyHelp,
-			ConstLabels: prometheus.Labels{"service": serviceName},
+			ConstLabels: constLabels,
 			Buckets:     DefaultBuckets,
 		}, str)
 
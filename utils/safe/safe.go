@@ -0,0 +1,38 @@
+package safe
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// CrashHandler handle panic of a component goroutine started by Go, the app runner reports it
+// to its crash hooks then exits
+type CrashHandler func(component string, r interface{}, stack []byte)
+
+var crashHandler atomic.Pointer[CrashHandler]
+
+// SetCrashHandler set handler of panics recovered by Go, set by the app runner on Run
+func SetCrashHandler(handler CrashHandler) {
+	crashHandler.Store(&handler)
+}
+
+// Go run fn of component on a goroutine, e.g. reconnect loops of brokers or cron jobs, a panic is
+// handed to the crash handler of the app runner. without crash handler the panic crashes the process as usual
+func Go(component string, fn func()) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				stack := debug.Stack()
+				if h := crashHandler.Load(); h != nil && *h != nil {
+					(*h)(component, r, stack)
+					return
+				}
+
+				panic(fmt.Sprintf("component %s: %v\n%s", component, r, stack))
+			}
+		}()
+
+		fn()
+	}()
+}
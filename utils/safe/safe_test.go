@@ -0,0 +1,27 @@
+package safe
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGoCrashHandler(t *testing.T) {
+	type crash struct {
+		component string
+		r         interface{}
+	}
+	crashed := make(chan crash, 1)
+	SetCrashHandler(func(component string, r interface{}, _ []byte) { crashed <- crash{component, r} })
+	t.Cleanup(func() { SetCrashHandler(nil) })
+
+	Go("worker", func() { panic("boom") })
+
+	select {
+	case c := <-crashed:
+		if c.component != "worker" || c.r != "boom" {
+			t.Errorf("expected panic of worker handed to crash handler, got %+v", c)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected crash handler called")
+	}
+}
@@ -0,0 +1,72 @@
+package signing
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/TixiaOTA/gokit/logger"
+)
+
+var (
+	// ErrEmptyKey salt key of the version is empty
+	ErrEmptyKey = errors.New("signing: salt key is empty")
+	// ErrMalformedSignature signature is not formatted as "<version>:<hex>"
+	ErrMalformedSignature = errors.New("signing: malformed signature")
+	// ErrUnknownVersion signature is signed by unknown key version
+	ErrUnknownVersion = errors.New("signing: unknown key version")
+	// ErrMismatch signature does not match the payload
+	ErrMismatch = errors.New("signing: signature mismatch")
+)
+
+// Sign compute HMAC-SHA256 of payload with the current salt key from context,
+// signature is prefixed with the key version, e.g. "v2:9f86d0..."
+func Sign(ctx context.Context, payload []byte) (string, error) {
+	keys := logger.GetSaltKeys(ctx)
+
+	key := keys.Keys[keys.Current]
+	if key == "" {
+		return "", ErrEmptyKey
+	}
+
+	return keys.Current + ":" + hex.EncodeToString(mac(key, payload)), nil
+}
+
+// Verify verify signature of payload against the salt key of its version,
+// any known version is accepted to support rotation
+func Verify(ctx context.Context, payload []byte, signature string) error {
+	version, digest, ok := strings.Cut(signature, ":")
+	if !ok || version == "" || digest == "" {
+		return ErrMalformedSignature
+	}
+
+	sum, err := hex.DecodeString(digest)
+	if err != nil {
+		return ErrMalformedSignature
+	}
+
+	key, ok := logger.GetSaltKeys(ctx).Keys[version]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownVersion, version)
+	}
+
+	if key == "" {
+		return ErrEmptyKey
+	}
+
+	if !hmac.Equal(sum, mac(key, payload)) {
+		return ErrMismatch
+	}
+
+	return nil
+}
+
+func mac(key string, payload []byte) []byte {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(payload)
+	return h.Sum(nil)
+}
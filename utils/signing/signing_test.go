@@ -0,0 +1,37 @@
+package signing
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/TixiaOTA/gokit/logger"
+)
+
+func TestSignRotation(t *testing.T) {
+	payload := []byte(`{"order_id":"123"}`)
+	ctx := context.WithValue(context.Background(), logger.LogKey, new(logger.Locker))
+
+	logger.SetSaltKeys(ctx, "v1", map[string]string{"v1": "old-secret"})
+	signature, err := Sign(ctx, payload)
+	if err != nil || !strings.HasPrefix(signature, "v1:") {
+		t.Fatalf("expected v1 signature, got %s %v", signature, err)
+	}
+
+	// rotate, v2 becomes current while v1 is still known
+	logger.SetSaltKeys(ctx, "v2", map[string]string{"v1": "old-secret", "v2": "new-secret"})
+	if err = Verify(ctx, payload, signature); err != nil {
+		t.Errorf("expected old signature still valid after rotation, got %v", err)
+	}
+
+	if err = Verify(ctx, []byte(`{"order_id":"124"}`), signature); !errors.Is(err, ErrMismatch) {
+		t.Errorf("expected mismatch, got %v", err)
+	}
+
+	// v1 retired
+	logger.SetSaltKeys(ctx, "v2", map[string]string{"v2": "new-secret"})
+	if err = Verify(ctx, payload, signature); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("expected unknown version, got %v", err)
+	}
+}